@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"hyprbot/internal/backup"
+	"hyprbot/internal/config"
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/storage"
+)
+
+// runCLI handles one-shot maintenance subcommands. It reports whether args
+// named a subcommand and the process exit code.
+func runCLI(args []string) (handled bool, code int) {
+	if len(args) == 0 {
+		return false, 0
+	}
+	switch args[0] {
+	case "backup":
+		return true, cliBackup(args[1:])
+	case "restore":
+		return true, cliRestore(args[1:])
+	default:
+		return false, 0
+	}
+}
+
+func cliBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("o", "", "output file (default: ./"+backup.FileName(time.Now())+")")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	store, cm, err := openForCLI(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.Close()
+
+	archive, err := backup.Create(ctx, store, cm)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "backup failed:", err)
+		return 1
+	}
+	path := *out
+	if path == "" {
+		path = backup.FileName(time.Now())
+	}
+	if err := os.WriteFile(path, archive, 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, "write backup:", err)
+		return 1
+	}
+	abs, _ := filepath.Abs(path)
+	fmt.Printf("backup written: %s (%d bytes)\n", abs, len(archive))
+	return 0
+}
+
+func cliRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	in := fs.String("i", "", "backup file to restore")
+	yes := fs.Bool("yes", false, "confirm that all current data will be replaced")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "usage: hyprbot restore -i <file> -yes")
+		return 2
+	}
+	if !*yes {
+		fmt.Fprintln(os.Stderr, "restore replaces every chat, provider, preset, user and audit row; rerun with -yes to confirm")
+		return 2
+	}
+
+	archive, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read backup:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	store, cm, err := openForCLI(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.Close()
+
+	snap, err := backup.Restore(ctx, store, cm, archive)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "restore failed:", err)
+		return 1
+	}
+	fmt.Printf("restored backup from %s: %d chats, %d providers, %d presets\n",
+		snap.CreatedAt.Format(time.RFC3339), len(snap.Chats), len(snap.Providers), len(snap.Presets))
+	return 0
+}
+
+func openForCLI(ctx context.Context) (*storage.Store, *crypto.Manager, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	cm, err := crypto.NewManager(cfg.Crypto.CurrentKeyID, cfg.Crypto.Keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init crypto manager: %w", err)
+	}
+	store, err := storage.Open(ctx, cfg.DB.Driver, cfg.DB.DSN, cfg.DB.AutoMigrate, "migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("open storage: %w", err)
+	}
+	return store, cm, nil
+}
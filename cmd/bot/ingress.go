@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// webhookIngress receives updates through the webhook and, with failover
+// on, switches to long polling while Telegram cannot register or reach it.
+// Only one ingress replica may run with failover: Telegram serves
+// getUpdates to a single poller and the webhook is shared by all replicas.
+type webhookIngress struct {
+	updater *ext.Updater
+	bot     *gotgbot.Bot
+	path    string
+	url     string
+	secret  string
+	allowed []string
+	// polling is 1 while updates come from the polling fallback.
+	polling prometheus.Gauge
+	log     zerolog.Logger
+
+	failover      bool
+	checkInterval time.Duration
+	threshold     int
+	retryInterval time.Duration
+
+	mu        sync.Mutex
+	isPolling bool
+}
+
+// start registers the webhook. Without failover a failed registration is
+// returned; with it the bot polls instead and run keeps retrying.
+func (in *webhookIngress) start() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err := in.updater.AddWebhook(in.bot, in.path, &ext.AddWebhookOpts{SecretToken: in.secret}); err != nil {
+		return err
+	}
+	if err := in.setWebhook(); err != nil {
+		if !in.failover {
+			return err
+		}
+		in.log.Warn().Err(err).Msg("failed to set telegram webhook, falling back to polling")
+		return in.switchToPolling()
+	}
+	in.log.Info().Str("webhook_url", in.url).Msg("webhook registered")
+	return nil
+}
+
+func (in *webhookIngress) setWebhook() error {
+	_, err := in.bot.SetWebhook(in.url, &gotgbot.SetWebhookOpts{
+		DropPendingUpdates: false,
+		SecretToken:        in.secret,
+		AllowedUpdates:     in.allowed,
+	})
+	return err
+}
+
+// run watches the webhook every checkInterval while it is in use and tries
+// to return to it every retryInterval while polling.
+func (in *webhookIngress) run(ctx context.Context) {
+	in.threshold = max(in.threshold, 1)
+	in.checkInterval = max(in.checkInterval, 10*time.Second)
+	in.retryInterval = max(in.retryInterval, 10*time.Second)
+	strikes := 0
+	lastCheck := time.Now()
+	for {
+		in.mu.Lock()
+		wait := in.checkInterval
+		if in.isPolling {
+			wait = in.retryInterval
+		}
+		in.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		in.mu.Lock()
+		if in.isPolling {
+			in.retryWebhook()
+			strikes, lastCheck = 0, time.Now()
+			in.mu.Unlock()
+			continue
+		}
+		if in.webhookFailing(lastCheck) {
+			strikes++
+		} else {
+			strikes = 0
+		}
+		lastCheck = time.Now()
+		if strikes >= in.threshold && ctx.Err() == nil {
+			in.log.Warn().Int("failed_checks", strikes).Msg("telegram cannot reach the webhook, falling back to polling")
+			if err := in.switchToPolling(); err != nil {
+				in.log.Error().Err(err).Msg("failed to start polling fallback")
+			}
+			strikes = 0
+		}
+		in.mu.Unlock()
+	}
+}
+
+// webhookFailing reports whether Telegram logged a delivery error since the
+// previous check while updates are waiting. A failed getWebhookInfo counts
+// as well: that is the same outage seen from this side.
+func (in *webhookIngress) webhookFailing(since time.Time) bool {
+	info, err := in.bot.GetWebhookInfo(nil)
+	if err != nil {
+		in.log.Warn().Err(err).Msg("failed to get webhook info")
+		return true
+	}
+	if info.LastErrorDate == 0 || info.PendingUpdateCount == 0 {
+		return false
+	}
+	if time.Unix(info.LastErrorDate, 0).Before(since) {
+		return false
+	}
+	in.log.Warn().
+		Str("last_error", info.LastErrorMessage).
+		Int64("pending_updates", info.PendingUpdateCount).
+		Msg("telegram reports webhook delivery errors")
+	return true
+}
+
+// switchToPolling drops the webhook mapping and polls. Pending updates are
+// kept, so nothing queued for the webhook is lost.
+func (in *webhookIngress) switchToPolling() error {
+	in.updater.StopBot(in.bot.Token)
+	err := in.updater.StartPolling(in.bot, &ext.PollingOpts{
+		EnableWebhookDeletion: true,
+		GetUpdatesOpts: &gotgbot.GetUpdatesOpts{
+			Timeout:        50,
+			AllowedUpdates: in.allowed,
+			RequestOpts: &gotgbot.RequestOpts{
+				Timeout: 60 * time.Second,
+			},
+		},
+	})
+	if err != nil {
+		// Keep serving whatever the webhook still delivers.
+		_ = in.updater.AddWebhook(in.bot, in.path, &ext.AddWebhookOpts{SecretToken: in.secret})
+		return err
+	}
+	in.isPolling = true
+	in.polling.Set(1)
+	in.log.Info().Msg("polling fallback started")
+	return nil
+}
+
+// retryWebhook moves back from polling to the webhook, or keeps polling if
+// Telegram still refuses it.
+func (in *webhookIngress) retryWebhook() {
+	in.updater.StopBot(in.bot.Token)
+	err := in.updater.AddWebhook(in.bot, in.path, &ext.AddWebhookOpts{SecretToken: in.secret})
+	if err == nil {
+		err = in.setWebhook()
+	}
+	if err != nil {
+		in.log.Warn().Err(err).Msg("webhook still unavailable, polling")
+		if err := in.switchToPolling(); err != nil {
+			in.log.Error().Err(err).Msg("failed to restart polling fallback")
+		}
+		return
+	}
+	in.isPolling = false
+	in.polling.Set(0)
+	in.log.Info().Str("webhook_url", in.url).Msg("webhook restored")
+}
@@ -2,31 +2,56 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"hyprbot/internal/backup"
+	"hyprbot/internal/billing"
+	"hyprbot/internal/buildinfo"
 	"hyprbot/internal/config"
+	"hyprbot/internal/controlplane"
 	"hyprbot/internal/crypto"
+	"hyprbot/internal/eventstream"
+	"hyprbot/internal/httpclient"
+	"hyprbot/internal/httpmw"
 	"hyprbot/internal/metrics"
+	"hyprbot/internal/notify"
+	"hyprbot/internal/objectstore"
+	"hyprbot/internal/plugin"
 	"hyprbot/internal/queue"
+	"hyprbot/internal/sandbox"
+	"hyprbot/internal/statsapi"
 	"hyprbot/internal/storage"
 	"hyprbot/internal/telegram"
+	"hyprbot/internal/tgsend"
+	"hyprbot/internal/tokenizer"
+	"hyprbot/internal/webfetch"
 	"hyprbot/internal/worker"
 )
 
 func main() {
+	if handled, code := runCLI(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to load config")
@@ -34,6 +59,8 @@ func main() {
 
 	setupLogger(cfg.Log.Level)
 	log.Info().
+		Str("version", buildinfo.Version).
+		Str("commit", buildinfo.Commit).
 		Str("mode", cfg.AppMode).
 		Str("access_mode", cfg.BotAccessMode).
 		Bool("dev_polling", cfg.DevPolling).
@@ -64,14 +91,154 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to initialize crypto manager")
 	}
 
-	bot, err := gotgbot.NewBot(cfg.BotToken, nil)
+	baseClient := &gotgbot.BaseBotClient{}
+	if cfg.TelegramProxyURL != "" {
+		// No client timeout: long polling and per-request contexts bound calls.
+		tr, err := httpclient.NewTransport(httpclient.Config{ProxyURL: cfg.TelegramProxyURL})
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid TELEGRAM_PROXY_URL")
+		}
+		baseClient.Client = http.Client{Transport: tr}
+	}
+	botOpts := &gotgbot.BotOpts{BotClient: &telegram.RateLimitedClient{
+		BotClient: baseClient,
+		Limiter:   queue.NewSendLimiter(rdb, cfg.TelegramSendLimits.GlobalPerSecond, cfg.TelegramSendLimits.GroupPerMinute),
+		MaxWait:   cfg.TelegramSendLimits.MaxWait,
+		Logger:    log.Logger,
+	}}
+	bot, err := gotgbot.NewBot(cfg.BotToken, botOpts)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create telegram bot")
 	}
 	log.Info().Str("bot_username", bot.User.Username).Int64("bot_id", bot.User.Id).Msg("telegram bot initialized")
 
 	m := metrics.Global()
+	store.Instrument(m)
+	metrics.RegisterDBStats(store.DB(), cfg.DB.Driver)
+	metrics.InstrumentRedis(rdb, m)
+	sender := tgsend.New(bot, tgsend.Options{
+		DropOrphanReplies: cfg.Worker.DropOrphanReplies,
+		Logger:            log.Logger,
+		Metrics:           m,
+	})
+
+	var objects *objectstore.Client
+	if cfg.Objects.Enabled() {
+		objects, err = objectstore.New(objectstore.Config{
+			Endpoint:  cfg.Objects.Endpoint,
+			Region:    cfg.Objects.Region,
+			Bucket:    cfg.Objects.Bucket,
+			AccessKey: cfg.Objects.AccessKey,
+			SecretKey: cfg.Objects.SecretKey,
+			PathStyle: cfg.Objects.PathStyle,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to initialize object store")
+		}
+		janitor := &objectstore.Janitor{
+			Client: objects,
+			Prefix: cfg.Objects.Prefix + "/artifacts/",
+			TTL:    cfg.Objects.ArtifactTTL,
+			Logger: log.Logger,
+		}
+		go func() {
+			_ = janitor.Run(ctx)
+		}()
+		log.Info().Str("endpoint", cfg.Objects.Endpoint).Str("bucket", cfg.Objects.Bucket).Msg("object store enabled")
+	}
+
 	jobQueue := queue.NewStreamQueue(rdb, cfg.Redis.QueueStream, cfg.Redis.QueueGroup, cfg.Worker.ConsumerName, cfg.Redis.QueueBlock)
+	queueEncoding, err := queue.ParseEncoding(cfg.Redis.QueueCompression)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid QUEUE_COMPRESSION")
+	}
+	jobQueue.SetCompression(queue.Compression{Encoding: queueEncoding, MinBytes: cfg.Redis.QueueCompressMin})
+	eventBus := queue.NewEventBus(rdb, cfg.Redis.EventsChannel)
+	presetPicks := queue.NewPickStore(rdb, 0)
+	answerMeta := queue.NewAnswerStore(rdb, 0)
+	fullAnswers := queue.NewFullAnswerStore(rdb, 0)
+	if objects != nil {
+		fullAnswers.OffloadTo(objects, cfg.Objects.Prefix+"/artifacts/answers/")
+	}
+	chatQuota := queue.NewChatQuota(rdb, cfg.Billing.FreeRequests)
+	maintenance := queue.NewMaintenance(rdb)
+	fallbackPresets := queue.NewFallbackPresets(rdb)
+	billingCfg := billing.Config{
+		FreeRequests:    cfg.Billing.FreeRequests,
+		CreditsRequired: cfg.Billing.CreditsRequired,
+		TokensPerCredit: cfg.Billing.TokensPerCredit,
+		PackCredits:     cfg.Billing.PackCredits,
+		PackPrice:       cfg.Billing.PackPrice,
+		Currency:        cfg.Billing.Currency,
+		ProviderToken:   cfg.Billing.ProviderToken,
+	}
+	providerHTTP, err := httpclient.New(httpclient.Config{
+		Timeout:             cfg.HTTP.ClientTimeout,
+		MaxIdleConns:        cfg.HTTP.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.HTTP.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.HTTP.IdleConnTimeout,
+		TLSHandshakeTimeout: cfg.HTTP.TLSHandshakeTimeout,
+		DisableHTTP2:        cfg.HTTP.DisableHTTP2,
+		CABundleFile:        cfg.HTTP.CABundleFile,
+		ProxyURL:            cfg.HTTP.ProxyURL,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to build provider http client")
+	}
+
+	// Every process emits events and delivers due webhook deliveries; they
+	// are claimed in the database, so none is sent twice.
+	notifier := notify.New(store, cryptoManager, notify.Config{
+		Timeout:     cfg.Notify.Timeout,
+		MaxAttempts: cfg.Notify.MaxAttempts,
+		Backoff:     cfg.Notify.Backoff,
+		MaxBackoff:  cfg.Notify.MaxBackoff,
+		Retention:   cfg.Notify.Retention,
+		Metrics:     m,
+		Logger:      log.Logger,
+	})
+	go notifier.Run(ctx)
+
+	// Never the stats token: whoever reads the feed could then hash known
+	// chat ids and recognize them.
+	statsHashKey := []byte(cfg.Webhook.StatsHashKey)
+	if len(statsHashKey) == 0 {
+		statsHashKey = cryptoManager.DeriveKey("stats-hash")
+	} else if cfg.Webhook.StatsHashKey == cfg.Webhook.StatsToken {
+		log.Fatal().Msg("STATS_HASH_KEY must differ from STATS_TOKEN")
+	}
+
+	var eventStream *eventstream.Publisher
+	if cfg.Events.URL != "" {
+		// Falls back to the stats key, so pseudonyms match /stats.
+		hashKey := []byte(cfg.Events.HashKey)
+		if len(hashKey) == 0 {
+			hashKey = statsHashKey
+		}
+		eventStream, err = eventstream.New(eventstream.Config{
+			URL:      cfg.Events.URL,
+			Subject:  cfg.Events.Subject,
+			ClientID: cfg.Events.ClientID,
+			HashKey:  hashKey,
+			Buffer:   cfg.Events.Buffer,
+			Timeout:  cfg.Events.Timeout,
+			Metrics:  m,
+			Logger:   log.Logger,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to set up the event stream")
+		}
+		go eventStream.Run(ctx)
+	}
+
+	runWorker := cfg.AppMode == config.ModeWorker || cfg.AppMode == config.ModeAll
+	// Entries older than three missed heartbeats are dead workers.
+	workerRegistry := queue.NewWorkerRegistry(rdb, 3*cfg.Worker.HeartbeatInterval)
+	var liveness *worker.Liveness
+	if runWorker {
+		liveness = worker.NewLiveness(cfg.Worker.StallTimeout)
+	}
 
 	errCh := make(chan error, 4)
 	var updater *ext.Updater
@@ -90,11 +257,16 @@ func main() {
 		if cfg.BotAccessMode == config.AccessModePrivate {
 			allowedUserID = cfg.AdminUserID
 		}
+		chatPauses := telegram.NewChatPauses(rdb, store, 0)
+		activity := telegram.NewActivityRecorder(store, log.Logger)
+		go activity.Run(ctx)
 		dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{
 			MaxRoutines:      100,
 			UnhandledErrFunc: logTelegramErr,
 			Processor: telegram.Processor{
-				Dedupe:        queue.NewUpdateDeduplicator(rdb, cfg.Redis.UpdateTTL),
+				Dedupe:        queue.NewUpdateDeduplicator(rdb, cfg.Redis.UpdateTTL, cfg.Redis.UpdateBucketSize),
+				Activity:      activity,
+				Pauses:        chatPauses,
 				Metrics:       m,
 				Logger:        log.Logger,
 				AllowedUserID: allowedUserID,
@@ -103,9 +275,23 @@ func main() {
 		service := telegram.NewService(telegram.Config{
 			Store:         store,
 			Queue:         jobQueue,
+			Events:        eventBus,
+			Picks:         presetPicks,
+			Answers:       answerMeta,
+			FullAnswers:   fullAnswers,
+			Quota:         chatQuota,
+			Maintenance:   maintenance,
+			Workers:       workerRegistry,
+			Fallback:      fallbackPresets,
+			Pauses:        chatPauses,
+			Billing:       billingCfg,
 			Crypto:        cryptoManager,
+			ProviderHTTP:  providerHTTP,
 			RateLimiter:   queue.NewRateLimiter(rdb, cfg.Rate.PerHour),
 			Redis:         rdb,
+			Sender:        sender,
+			Ephemeral:     queue.NewEphemeralStore(rdb),
+			EphemeralTTL:  cfg.EphemeralMessageTTL,
 			Logger:        log.Logger,
 			Metrics:       m,
 			AdminCacheTTL: cfg.Redis.AdminCacheTTL,
@@ -113,18 +299,51 @@ func main() {
 			BotUsername:   bot.User.Username,
 			AccessMode:    cfg.BotAccessMode,
 			AdminUserID:   cfg.AdminUserID,
+			AskEditWindow: cfg.AskEditWindow,
+			VerifyKeys:    cfg.VerifyProviderKeys,
+			DocsURL:       cfg.DocsURL,
+			Plugins:       pluginNames(cfg.Plugins),
+			Notify:        notifier,
+			EventStream:   eventStream,
+
+			PrivateSelfService: cfg.PrivateSelfService,
+
+			AllowAnonymousAdmins: cfg.AllowAnonymousAdmins,
 		})
 		service.Register(dispatcher)
+		go func() {
+			_ = service.RunEventListener(ctx, bot)
+		}()
+		go func() {
+			_ = service.RunLeftChatPurge(ctx, cfg.LeftChatPurgeAfter)
+		}()
+		go func() {
+			_ = service.RunUsageDigest(ctx, bot, cfg.UsageDigestInterval)
+		}()
+		go func() {
+			_ = service.RunEphemeralCleanup(ctx, bot)
+		}()
 		updater = ext.NewUpdater(dispatcher, &ext.UpdaterOpts{
 			UnhandledErrFunc: logTelegramErr,
 		})
 
+		allowedUpdates := cfg.AllowedUpdates
+		if allowedUpdates == nil {
+			allowedUpdates = telegram.AllowedUpdates
+		}
+		for _, t := range telegram.AllowedUpdates {
+			if !slices.Contains(allowedUpdates, t) {
+				log.Warn().Str("update_type", t).Msg("ALLOWED_UPDATES leaves out an update type the bot handles")
+			}
+		}
+
 		if runPolling {
 			if err := updater.StartPolling(bot, &ext.PollingOpts{
 				EnableWebhookDeletion: true,
 				DropPendingUpdates:    true,
 				GetUpdatesOpts: &gotgbot.GetUpdatesOpts{
-					Timeout: 50,
+					Timeout:        50,
+					AllowedUpdates: allowedUpdates,
 					RequestOpts: &gotgbot.RequestOpts{
 						Timeout: 60 * time.Second,
 					},
@@ -141,35 +360,68 @@ func main() {
 			if cfg.Webhook.PublicURL == "" {
 				log.Fatal().Msg("WEBHOOK_URL is required in webhook mode")
 			}
-			if err := updater.AddWebhook(bot, path, &ext.AddWebhookOpts{SecretToken: cfg.Webhook.SecretToken}); err != nil {
-				log.Fatal().Err(err).Msg("failed to configure webhook handler")
+			ingress := &webhookIngress{
+				updater:       updater,
+				bot:           bot,
+				path:          path,
+				url:           strings.TrimSuffix(cfg.Webhook.PublicURL, "/") + "/" + path,
+				secret:        cfg.Webhook.SecretToken,
+				allowed:       allowedUpdates,
+				polling:       metrics.Global().PollingFallback,
+				log:           log.Logger,
+				failover:      cfg.Webhook.Failover,
+				checkInterval: cfg.Webhook.FailoverCheckInterval,
+				threshold:     cfg.Webhook.FailoverThreshold,
+				retryInterval: cfg.Webhook.FailoverRetryInterval,
 			}
-
-			webhookURL := strings.TrimSuffix(cfg.Webhook.PublicURL, "/") + "/" + path
-			if _, err := bot.SetWebhook(webhookURL, &gotgbot.SetWebhookOpts{
-				DropPendingUpdates: false,
-				SecretToken:        cfg.Webhook.SecretToken,
-			}); err != nil {
+			if err := ingress.start(); err != nil {
 				log.Fatal().Err(err).Msg("failed to set telegram webhook")
 			}
-			log.Info().Str("webhook_url", webhookURL).Msg("webhook registered")
+			if cfg.Webhook.Failover {
+				go ingress.run(ctx)
+			}
 			webhookRoute = "/" + path
 			webhookHandler = updater.GetHandlerFunc("/")
 		}
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc(cfg.Webhook.HealthPath, func(w http.ResponseWriter, _ *http.Request) {
+	mux.HandleFunc(cfg.Webhook.HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		// Maintenance stays 200: ingress must keep accepting updates to
+		// answer them with the maintenance notice.
+		if liveness.Stalled(time.Now()) {
+			http.Error(w, "worker stalled", http.StatusServiceUnavailable)
+			return
+		}
+		body := "ok"
+		if _, on, err := maintenance.State(r.Context()); err == nil && on {
+			body = "maintenance"
+		}
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+		_, _ = w.Write([]byte(body))
 	})
-	mux.Handle(cfg.Webhook.MetricsPath, promhttp.Handler())
+	// OpenMetrics carries the trace_id exemplars of the job histograms.
+	mux.Handle(cfg.Webhook.MetricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+	mux.HandleFunc(cfg.Webhook.ScalingPath, func(w http.ResponseWriter, r *http.Request) {
+		// Queue depth for external autoscalers (KEDA, HPA external metrics).
+		depth, err := jobQueue.Depth(r.Context())
+		if err != nil {
+			http.Error(w, "queue unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(depth)
+	})
+	if cfg.Webhook.StatsToken != "" {
+		mux.Handle(cfg.Webhook.StatsPath, statsapi.New(store, cfg.Webhook.StatsToken, statsHashKey, log.Logger))
+	}
 	if webhookHandler != nil && webhookRoute != "" {
-		mux.HandleFunc(webhookRoute, webhookHandler)
+		mux.Handle(webhookRoute, httpmw.MaxBody(cfg.Webhook.MaxBodyBytes, webhookHandler))
 	}
 	httpServer = &http.Server{
 		Addr:              cfg.Webhook.ListenAddr,
-		Handler:           mux,
+		Handler:           httpmw.Recover(log.Logger, mux),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       cfg.Webhook.WebhookTimeout,
 	}
@@ -180,17 +432,153 @@ func main() {
 		}
 	}()
 
-	if cfg.AppMode == config.ModeWorker || cfg.AppMode == config.ModeAll {
+	// Workers never serve the control plane, so a worker fleet does not
+	// multiply the endpoints holding its token.
+	var controlServer *grpc.Server
+	if cfg.Control.Token != "" && cfg.AppMode != config.ModeWorker {
+		var opts []grpc.ServerOption
+		if cfg.Control.TLSCertFile != "" {
+			creds, err := credentials.NewServerTLSFromFile(cfg.Control.TLSCertFile, cfg.Control.TLSKeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to load the control plane TLS certificate")
+			}
+			opts = append(opts, grpc.Creds(creds))
+		} else if !isLoopbackAddr(cfg.Control.ListenAddr) {
+			log.Warn().Str("addr", cfg.Control.ListenAddr).Msg("control plane serves plaintext beyond loopback; set CONTROL_PLANE_TLS_CERT and CONTROL_PLANE_TLS_KEY")
+		}
+		lis, err := net.Listen("tcp", cfg.Control.ListenAddr)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to listen for the control plane")
+		}
+		controlServer = controlplane.New(controlplane.Config{
+			Store:       store,
+			Queue:       jobQueue,
+			Workers:     workerRegistry,
+			Maintenance: maintenance,
+			Crypto:      cryptoManager,
+			Token:       cfg.Control.Token,
+			Notify:      notifier,
+			EventStream: eventStream,
+			MaintenanceOver: func(notices []queue.MaintenanceNotice) {
+				telegram.AnnounceMaintenanceOver(sender, log.Logger, notices)
+			},
+			Logger: log.Logger,
+		}).GRPCServer(opts...)
+		go func() {
+			log.Info().Str("addr", cfg.Control.ListenAddr).Bool("tls", cfg.Control.TLSCertFile != "").Msg("control plane started")
+			if err := controlServer.Serve(lis); err != nil {
+				errCh <- fmt.Errorf("control plane: %w", err)
+			}
+		}()
+	}
+
+	if runWorker {
+		// A provider slot lease must outlive a call with all its retries.
+		slotLease := time.Duration(cfg.HTTP.MaxRetries+1) * (cfg.HTTP.ClientTimeout + cfg.HTTP.MaxRetryAfter)
+		var codeRunner *sandbox.Client
+		if cfg.Sandbox.Enabled() {
+			codeRunner, err = sandbox.New(sandbox.Config{
+				URL:            cfg.Sandbox.URL,
+				Token:          cfg.Sandbox.Token,
+				Timeout:        cfg.Sandbox.Timeout,
+				MemoryMB:       cfg.Sandbox.MemoryMB,
+				MaxCodeBytes:   cfg.Sandbox.MaxCodeBytes,
+				MaxOutputBytes: cfg.Sandbox.MaxOutputBytes,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to initialize code sandbox")
+			}
+		}
+		var fetcher *webfetch.Fetcher
+		if cfg.Fetch.Enabled {
+			fetcher = webfetch.New(webfetch.Config{
+				Timeout:   cfg.Fetch.Timeout,
+				MaxBytes:  cfg.Fetch.MaxBytes,
+				MaxTokens: cfg.Fetch.MaxTokens,
+			})
+		}
+		tokenizers, err := tokenizer.NewRegistry(cfg.Worker.TokenizerDir, cfg.Worker.TokenizerModels)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load tokenizers")
+		}
+		if missing := tokenizers.Missing(); len(missing) > 0 && cfg.Worker.TokenizerDir != "" {
+			log.Warn().Strs("encodings", missing).Str("dir", cfg.Worker.TokenizerDir).Msg("tokenizer rank files not found, estimating tokens instead")
+		}
+		var plugins []*plugin.Plugin
+		for _, pc := range cfg.Plugins {
+			pl, err := plugin.New(ctx, plugin.Config{
+				Name:    pc.Name,
+				Command: pc.Command,
+				Env:     pc.Env,
+				Timeout: pc.Timeout,
+				Order:   pc.Order,
+				Default: pc.Default,
+				Logger:  log.Logger,
+			})
+			if err != nil {
+				// One broken plugin should not take the bot down.
+				log.Error().Err(err).Str("plugin", pc.Name).Msg("failed to start plugin, skipping it")
+				continue
+			}
+			defer pl.Close()
+			plugins = append(plugins, pl)
+			log.Info().Str("plugin", pc.Name).Int("tools", len(pl.Info().Tools)).Bool("filter", pl.Info().Filter).Msg("plugin loaded")
+		}
 		w := worker.New(worker.Config{
 			Bot:             bot,
 			Store:           store,
 			Queue:           jobQueue,
+			Events:          eventBus,
+			Throttle:        queue.NewProviderThrottle(rdb),
+			Slots:           queue.NewProviderSlots(rdb, slotLease),
+			Picks:           presetPicks,
+			Answers:         answerMeta,
+			FullAnswers:     fullAnswers,
+			Fallback:        fallbackPresets,
+			FallbackLimiter: queue.NewNamedRateLimiter(rdb, "fallback", cfg.Rate.FallbackPerHour),
+			Outbox:          queue.NewOutboxStore(rdb, 0),
+			Quota:           chatQuota,
+			Billing:         billingCfg,
 			Crypto:          cryptoManager,
+			HTTPClient:      providerHTTP,
 			ProviderRetries: cfg.HTTP.MaxRetries,
 			BackoffBase:     cfg.HTTP.BackoffBase,
+			MaxRetryAfter:   cfg.HTTP.MaxRetryAfter,
 			MaxJobRetries:   cfg.Worker.MaxRetries,
-			Logger:          log.Logger,
-			Metrics:         m,
+			Sandbox:         codeRunner,
+			Fetcher:         fetcher,
+			Sender:          sender,
+			AnswerInStatus:  cfg.Worker.AnswerInStatus,
+			StoreHistory:    cfg.Worker.StoreHistory,
+			Scaling: worker.Scaling{
+				Max:       cfg.Worker.MaxConcurrency,
+				UpBacklog: cfg.Worker.ScaleUpBacklog,
+				DownAfter: cfg.Worker.ScaleDownIdle,
+			},
+			Retention: worker.Retention{
+				MaxLen:   cfg.Redis.QueueMaxLen,
+				MaxAge:   cfg.Redis.QueueMaxAge,
+				Interval: cfg.Redis.QueueTrimInterval,
+			},
+			Heartbeat: worker.Heartbeat{
+				Registry: workerRegistry,
+				Interval: cfg.Worker.HeartbeatInterval,
+			},
+			Cleanup: worker.Cleanup{
+				IdleAfter: cfg.Worker.DeadConsumerIdle,
+				Interval:  cfg.Worker.DeadConsumerInterval,
+			},
+			Logger:      log.Logger,
+			Metrics:     m,
+			Liveness:    liveness,
+			Tokenizers:  tokenizers,
+			Plugins:     plugins,
+			Notify:      notifier,
+			EventStream: eventStream,
+			BudgetAlerts: worker.BudgetAlerts{
+				QuotaPercents: cfg.Notify.QuotaPercents,
+				LowCredits:    cfg.Notify.LowCredits,
+			},
 		})
 		go func() {
 			if err := w.Start(ctx, cfg.Worker.Concurrency); err != nil && ctx.Err() == nil {
@@ -200,6 +588,29 @@ func main() {
 		log.Info().Int("concurrency", cfg.Worker.Concurrency).Msg("worker started")
 	}
 
+	var backupSink backup.Sink
+	switch {
+	case cfg.Backup.Dir != "":
+		backupSink = backup.LocalSink{Dir: cfg.Backup.Dir}
+	case objects != nil:
+		backupSink = backup.ObjectSink{Client: objects, Prefix: cfg.Objects.Prefix + "/backups/"}
+	}
+	if cfg.Backup.Interval > 0 && backupSink != nil {
+		scheduler := &backup.Scheduler{
+			Store:    store,
+			Crypto:   cryptoManager,
+			Redis:    rdb,
+			Sink:     backupSink,
+			Interval: cfg.Backup.Interval,
+			Keep:     cfg.Backup.Keep,
+			Logger:   log.Logger,
+		}
+		go func() {
+			_ = scheduler.Run(ctx)
+		}()
+		log.Info().Dur("interval", cfg.Backup.Interval).Msg("scheduled backups enabled")
+	}
+
 	select {
 	case <-ctx.Done():
 		log.Info().Msg("shutdown signal received")
@@ -220,10 +631,21 @@ func main() {
 			log.Error().Err(err).Msg("failed to stop http server")
 		}
 	}
+	if controlServer != nil {
+		controlServer.GracefulStop()
+	}
 
 	log.Info().Msg("stopped")
 }
 
+func pluginNames(plugins []config.PluginConfig) []string {
+	names := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
 func setupLogger(level string) {
 	zerolog.TimeFieldFormat = time.RFC3339
 	zerolog.SetGlobalLevel(parseLogLevel(level))
@@ -260,3 +682,17 @@ func sanitizeTelegramErr(err error, token string) string {
 	}
 	return msg
 }
+
+// isLoopbackAddr reports whether addr only listens on loopback. An empty
+// host listens on every interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
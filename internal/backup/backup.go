@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/storage"
+)
+
+const maxArchiveSize = 256 << 20
+
+// Create exports the database and returns an archive: the snapshot JSON,
+// gzip-compressed and sealed in a crypto envelope with the current master key.
+// Provider secrets stay in their own envelopes inside the snapshot.
+func Create(ctx context.Context, store *storage.Store, cm *crypto.Manager) ([]byte, error) {
+	snap, err := store.ExportSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		return nil, fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress snapshot: %w", err)
+	}
+
+	env, err := cm.Encrypt(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("encrypt snapshot: %w", err)
+	}
+	out, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshal archive: %w", err)
+	}
+	return out, nil
+}
+
+func Open(archive []byte, cm *crypto.Manager) (storage.Snapshot, error) {
+	var env crypto.Envelope
+	if err := json.Unmarshal(archive, &env); err != nil {
+		return storage.Snapshot{}, fmt.Errorf("decode archive: %w", err)
+	}
+	compressed, err := cm.Decrypt(env)
+	if err != nil {
+		return storage.Snapshot{}, fmt.Errorf("decrypt archive: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return storage.Snapshot{}, fmt.Errorf("decompress archive: %w", err)
+	}
+	defer zr.Close()
+
+	var snap storage.Snapshot
+	if err := json.NewDecoder(io.LimitReader(zr, maxArchiveSize)).Decode(&snap); err != nil {
+		return storage.Snapshot{}, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+func Restore(ctx context.Context, store *storage.Store, cm *crypto.Manager, archive []byte) (storage.Snapshot, error) {
+	snap, err := Open(archive, cm)
+	if err != nil {
+		return storage.Snapshot{}, err
+	}
+	if err := store.RestoreSnapshot(ctx, snap); err != nil {
+		return storage.Snapshot{}, err
+	}
+	return snap, nil
+}
+
+func FileName(at time.Time) string {
+	return "hyprbot-backup-" + at.UTC().Format("20060102T150405Z") + ".hbk"
+}
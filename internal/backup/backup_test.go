@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/storage"
+)
+
+func TestCreateRestoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cm, err := crypto.NewManager("k1", map[string][]byte{"k1": make([]byte, 32)})
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+
+	src := openStore(t, "src.db")
+	if err := src.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	key, err := cm.MarshalEncryptedString("sk-test")
+	if err != nil {
+		t.Fatalf("encrypt key: %v", err)
+	}
+	providerID, err := src.UpsertProviderInstance(ctx, storage.ProviderInstance{
+		ChatID: -100, Name: "grok", Kind: "openai_compat", BaseURL: "https://api.x.ai/v1", EncAPIKey: &key,
+	})
+	if err != nil {
+		t.Fatalf("upsert provider: %v", err)
+	}
+	if err := src.UpsertPreset(ctx, storage.Preset{ChatID: -100, Name: "default", ProviderInstanceID: providerID, Model: "grok-2"}); err != nil {
+		t.Fatalf("upsert preset: %v", err)
+	}
+	if err := src.SetDefaultPreset(ctx, -100, "default"); err != nil {
+		t.Fatalf("set default: %v", err)
+	}
+	if err := src.RecordActivity(ctx, []storage.UserActivity{
+		{ChatID: -100, UserID: 7, Username: "alice", FirstName: "Alice", Messages: 3},
+		{ChatID: -200, UserID: 7, Username: "alice", FirstName: "Alice", Messages: 5},
+	}); err != nil {
+		t.Fatalf("record activity: %v", err)
+	}
+	if err := src.SaveWebhook(ctx, storage.Webhook{Name: "ops", URL: "https://hooks.example.com/bot", EncSecret: key, Events: []string{"job.failed", "budget.exceeded"}}); err != nil {
+		t.Fatalf("save webhook: %v", err)
+	}
+	if err := src.QueueWebhookDeliveries(ctx, []storage.WebhookDelivery{{WebhookName: "ops", EventID: "ev1", EventType: "job.failed", Payload: "{}"}}); err != nil {
+		t.Fatalf("queue delivery: %v", err)
+	}
+
+	archive, err := Create(ctx, src, cm)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	dst := openStore(t, "dst.db")
+	if _, err := Restore(ctx, dst, cm, archive); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	got, err := dst.GetDefaultPresetWithProvider(ctx, -100)
+	if err != nil {
+		t.Fatalf("load restored preset: %v", err)
+	}
+	if got.Model != "grok-2" || got.Provider.Name != "grok" {
+		t.Fatalf("unexpected restored preset %+v", got)
+	}
+	plain, err := cm.UnmarshalEncryptedString(*got.Provider.EncAPIKey)
+	if err != nil || plain != "sk-test" {
+		t.Fatalf("restored api key mismatch: %q %v", plain, err)
+	}
+
+	member, err := dst.GetChatUserByUsername(ctx, -100, "@Alice")
+	if err != nil || member.ID != 7 || member.MessageCount != 3 {
+		t.Fatalf("restored chat user = %+v %v, want 3 messages in the chat", member, err)
+	}
+	if _, err := dst.GetChatUser(ctx, -300, 7); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("chat user in a chat never seen = %v, want ErrNotFound", err)
+	}
+
+	hooks, err := dst.ListWebhooks(ctx)
+	if err != nil || len(hooks) != 1 || hooks[0].URL != "https://hooks.example.com/bot" || len(hooks[0].Events) != 2 || hooks[0].EncSecret != key {
+		t.Fatalf("restored webhooks = %+v %v", hooks, err)
+	}
+	due, err := dst.DueWebhookDeliveries(ctx, 10)
+	if err != nil || len(due) != 1 || due[0].EventID != "ev1" {
+		t.Fatalf("restored due deliveries = %+v %v", due, err)
+	}
+	// New deliveries get ids past the restored ones.
+	if err := dst.QueueWebhookDeliveries(ctx, []storage.WebhookDelivery{{WebhookName: "ops", EventID: "ev2", EventType: "job.failed", Payload: "{}"}}); err != nil {
+		t.Fatalf("queue delivery after restore: %v", err)
+	}
+	recent, err := dst.RecentWebhookDeliveries(ctx, 10)
+	if err != nil || len(recent) != 2 || recent[0].ID <= due[0].ID {
+		t.Fatalf("deliveries after restore = %+v %v", recent, err)
+	}
+}
+
+func TestOpenRejectsForeignKey(t *testing.T) {
+	ctx := context.Background()
+	cm, _ := crypto.NewManager("k1", map[string][]byte{"k1": make([]byte, 32)})
+	other, _ := crypto.NewManager("k1", map[string][]byte{"k1": append(make([]byte, 31), 1)})
+
+	archive, err := Create(ctx, openStore(t, "src.db"), cm)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := Open(archive, other); err == nil {
+		t.Fatalf("expected decrypt error with a different key")
+	}
+}
+
+func openStore(t *testing.T, name string) *storage.Store {
+	t.Helper()
+	s, err := storage.Open(context.Background(), "sqlite", filepath.Join(t.TempDir(), name), true, "")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
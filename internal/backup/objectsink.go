@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"hyprbot/internal/objectstore"
+)
+
+type ObjectSink struct {
+	Client *objectstore.Client
+	Prefix string
+}
+
+func (o ObjectSink) Put(ctx context.Context, name string, data []byte) error {
+	return o.Client.Put(ctx, o.Prefix+name, data, "application/octet-stream")
+}
+
+func (o ObjectSink) Prune(ctx context.Context, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	objects, err := o.Client.List(ctx, o.Prefix+"hyprbot-backup-")
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Key, ".hbk") {
+			keys = append(keys, obj.Key)
+		}
+	}
+	sort.Strings(keys)
+	for len(keys) > keep {
+		if err := o.Client.Delete(ctx, keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/storage"
+)
+
+type Sink interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Prune(ctx context.Context, keep int) error
+}
+
+type LocalSink struct {
+	Dir string
+}
+
+func (l LocalSink) Put(_ context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(l.Dir, 0o700); err != nil {
+		return fmt.Errorf("create backup dir: %w", err)
+	}
+	tmp := filepath.Join(l.Dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.Dir, name)); err != nil {
+		return fmt.Errorf("finalize backup: %w", err)
+	}
+	return nil
+}
+
+func (l LocalSink) Prune(_ context.Context, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(l.Dir, "hyprbot-backup-*.hbk"))
+	if err != nil {
+		return err
+	}
+	// Names embed a sortable UTC timestamp.
+	sort.Strings(matches)
+	for len(matches) > keep {
+		if err := os.Remove(matches[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove old backup: %w", err)
+		}
+		matches = matches[1:]
+	}
+	return nil
+}
+
+type Scheduler struct {
+	Store    *storage.Store
+	Crypto   *crypto.Manager
+	Redis    *redis.Client
+	Sink     Sink
+	Interval time.Duration
+	Keep     int
+	Logger   zerolog.Logger
+}
+
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Interval <= 0 || s.Sink == nil {
+		return nil
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.runOnce(ctx); err != nil {
+				s.Logger.Error().Err(err).Msg("scheduled backup failed")
+			}
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context) error {
+	// Every replica runs the scheduler; the lock keeps one backup per interval.
+	if s.Redis != nil {
+		ok, err := s.Redis.SetNX(ctx, "hyprbot:backup:lock", "1", s.Interval-s.Interval/10).Result()
+		if err != nil {
+			return fmt.Errorf("acquire backup lock: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	now := time.Now().UTC()
+	archive, err := Create(ctx, s.Store, s.Crypto)
+	if err != nil {
+		return err
+	}
+	name := FileName(now)
+	if err := s.Sink.Put(ctx, name, archive); err != nil {
+		return err
+	}
+	if err := s.Sink.Prune(ctx, s.Keep); err != nil {
+		s.Logger.Warn().Err(err).Msg("failed to prune old backups")
+	}
+	s.Logger.Info().Str("name", name).Int("bytes", len(archive)).Msg("backup written")
+	return nil
+}
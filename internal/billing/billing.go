@@ -0,0 +1,113 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// CurrencyStars is the Telegram Stars currency; Stars invoices need no
+// payment provider token.
+const CurrencyStars = "XTR"
+
+const payloadPrefix = "credits:"
+
+var (
+	ErrInvalidPayload = errors.New("invalid invoice payload")
+	ErrPackChanged    = errors.New("pack changed since the invoice was sent")
+)
+
+// Config describes how chats pay for requests: a free monthly quota, then
+// credits granted by the owner or bought in packs.
+type Config struct {
+	// FreeRequests is the per-chat allowance per calendar month (UTC).
+	FreeRequests int64
+	// CreditsRequired limits chats to their credits even without a free
+	// quota.
+	CreditsRequired bool
+	// TokensPerCredit meters requests by token usage; 0 charges one credit
+	// per request.
+	TokensPerCredit int64
+	PackCredits     int64
+	// PackPrice is in the smallest unit of Currency (whole Stars for XTR).
+	PackPrice     int64
+	Currency      string
+	ProviderToken string
+}
+
+// Limited reports whether requests beyond the free quota need credits.
+func (c Config) Limited() bool {
+	return c.CreditsRequired || c.FreeRequests > 0
+}
+
+// PacksEnabled reports whether limited chats are offered packs to buy.
+func (c Config) PacksEnabled() bool {
+	return c.Limited() && c.PackCredits > 0 && c.PackPrice > 0
+}
+
+// Cost returns the credits an answered request spends, at least one.
+func (c Config) Cost(tokens int64) int64 {
+	if c.TokensPerCredit <= 0 || tokens <= c.TokensPerCredit {
+		return 1
+	}
+	return (tokens + c.TokensPerCredit - 1) / c.TokensPerCredit
+}
+
+// Payload is the invoice payload for a pack credited to chatID. Telegram
+// echoes it back on pre-checkout and on the successful payment.
+func Payload(chatID, credits int64) string {
+	return fmt.Sprintf("%s%d:%d", payloadPrefix, chatID, credits)
+}
+
+func ParsePayload(payload string) (chatID, credits int64, err error) {
+	rest, ok := strings.CutPrefix(payload, payloadPrefix)
+	if !ok {
+		return 0, 0, ErrInvalidPayload
+	}
+	rawChat, rawCredits, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, 0, ErrInvalidPayload
+	}
+	chatID, err = strconv.ParseInt(rawChat, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidPayload
+	}
+	credits, err = strconv.ParseInt(rawCredits, 10, 64)
+	if err != nil || credits <= 0 {
+		return 0, 0, ErrInvalidPayload
+	}
+	return chatID, credits, nil
+}
+
+// CheckPayment validates what Telegram reports for a checkout against the
+// current pack and returns the chat to credit.
+func (c Config) CheckPayment(payload, currency string, amount int64) (chatID, credits int64, err error) {
+	chatID, credits, err = ParsePayload(payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	if credits != c.PackCredits || currency != c.Currency || amount != c.PackPrice {
+		return 0, 0, ErrPackChanged
+	}
+	return chatID, credits, nil
+}
+
+// SendInvoice posts a pack invoice to chatID, replying to replyTo when set.
+func (c Config) SendInvoice(ctx context.Context, b *gotgbot.Bot, chatID, replyTo int64) error {
+	label := fmt.Sprintf("%d credits", c.PackCredits)
+	opts := &gotgbot.SendInvoiceOpts{ProviderToken: c.ProviderToken}
+	if replyTo > 0 {
+		opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: replyTo, AllowSendingWithoutReply: true}
+	}
+	description := fmt.Sprintf("Adds %d credits to this chat, spent on requests once the free requests of the month run out. Credits do not expire.", c.PackCredits)
+	_, err := b.SendInvoiceWithContext(ctx, chatID, label, description, Payload(chatID, c.PackCredits), c.Currency,
+		[]gotgbot.LabeledPrice{{Label: label, Amount: c.PackPrice}}, opts)
+	if err != nil {
+		return fmt.Errorf("send invoice: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package billing
+
+import "testing"
+
+func TestPayloadRoundTrip(t *testing.T) {
+	chatID, requests, err := ParsePayload(Payload(-1001234, 100))
+	if err != nil || chatID != -1001234 || requests != 100 {
+		t.Fatalf("round trip: chat=%d requests=%d err=%v", chatID, requests, err)
+	}
+	for _, bad := range []string{"", "credits:", "credits:1", "credits:x:5", "credits:1:0", "other:1:5"} {
+		if _, _, err := ParsePayload(bad); err == nil {
+			t.Fatalf("payload %q must be rejected", bad)
+		}
+	}
+}
+
+func TestCheckPayment(t *testing.T) {
+	cfg := Config{FreeRequests: 50, PackCredits: 100, PackPrice: 25, Currency: CurrencyStars}
+	if !cfg.PacksEnabled() {
+		t.Fatal("packs must be enabled")
+	}
+	if _, _, err := cfg.CheckPayment(Payload(7, 100), CurrencyStars, 25); err != nil {
+		t.Fatalf("valid payment rejected: %v", err)
+	}
+	if _, _, err := cfg.CheckPayment(Payload(7, 100), CurrencyStars, 20); err == nil {
+		t.Fatal("amount mismatch must be rejected")
+	}
+	if _, _, err := cfg.CheckPayment(Payload(7, 50), CurrencyStars, 25); err == nil {
+		t.Fatal("stale pack must be rejected")
+	}
+}
+
+func TestCost(t *testing.T) {
+	if got := (Config{}).Cost(5000); got != 1 {
+		t.Fatalf("unmetered cost = %d, want 1", got)
+	}
+	metered := Config{TokensPerCredit: 1000}
+	for tokens, want := range map[int64]int64{0: 1, 999: 1, 1000: 1, 1001: 2, 4500: 5} {
+		if got := metered.Cost(tokens); got != want {
+			t.Fatalf("cost(%d) = %d, want %d", tokens, got, want)
+		}
+	}
+}
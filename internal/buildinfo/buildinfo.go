@@ -0,0 +1,17 @@
+// Package buildinfo holds the release identifiers stamped into the binary
+// at build time:
+//
+//	go build -ldflags "-X hyprbot/internal/buildinfo.Version=v1.2.3 -X hyprbot/internal/buildinfo.Commit=$(git rev-parse --short HEAD)"
+package buildinfo
+
+import "runtime"
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// GoVersion is the toolchain the binary was built with.
+func GoVersion() string {
+	return runtime.Version()
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +28,8 @@ var (
 	ErrInvalidAccessMode  = errors.New("BOT_ACCESS_MODE must be 'public' or 'private'")
 	ErrMissingDatabaseDSN = errors.New("DB_DSN is required")
 	ErrMissingMasterKey   = errors.New("at least one master key is required")
+	ErrMissingPayToken    = errors.New("PAYMENT_PROVIDER_TOKEN is required for currencies other than XTR")
+	ErrPartialControlTLS  = errors.New("CONTROL_PLANE_TLS_CERT and CONTROL_PLANE_TLS_KEY must be set together")
 )
 
 type Config struct {
@@ -37,6 +41,37 @@ type Config struct {
 	BotUsername string
 
 	DevPolling bool
+	// AllowedUpdates limits the update types Telegram sends for polling and
+	// the webhook. Nil means the types the bot handles.
+	AllowedUpdates []string
+
+	// AskEditWindow is how long after /ask an edit replaces the queued job.
+	AskEditWindow time.Duration
+	// LeftChatPurgeAfter drops provider secrets of chats the bot was removed
+	// from once this long has passed. Zero keeps them.
+	LeftChatPurgeAfter time.Duration
+	// UsageDigestInterval is the period of the per-chat usage digest enabled
+	// by the digest setting. Zero disables digests.
+	UsageDigestInterval time.Duration
+	// EphemeralMessageTTL is how long warnings such as rate limit notices
+	// stay in the chat before the bot deletes them. Zero keeps them.
+	EphemeralMessageTTL time.Duration
+	// VerifyProviderKeys makes the provider wizard check a new API key
+	// against the provider's model list before saving it.
+	VerifyProviderKeys bool
+	// DocsURL is opened by the "Docs" button of the welcome message. Empty
+	// hides the button.
+	DocsURL string
+	// PrivateSelfService lets users configure their private chat with the
+	// bot as its admin, with their own provider keys.
+	PrivateSelfService bool
+	// AllowAnonymousAdmins accepts admin commands sent on behalf of the group.
+	AllowAnonymousAdmins bool
+
+	// TelegramProxyURL routes Bot API calls through an http(s)/socks5 proxy.
+	TelegramProxyURL string
+	// TelegramSendLimits keep sends and edits under the Bot API limits.
+	TelegramSendLimits TelegramSendLimits
 
 	Webhook WebhookConfig
 	Redis   RedisConfig
@@ -44,7 +79,16 @@ type Config struct {
 	Worker  WorkerConfig
 	HTTP    HTTPConfig
 	Rate    RateConfig
+	Billing BillingConfig
 	Crypto  CryptoConfig
+	Backup  BackupConfig
+	Objects ObjectStoreConfig
+	Sandbox SandboxConfig
+	Fetch   FetchConfig
+	Plugins []PluginConfig
+	Notify  NotifyConfig
+	Events  EventStreamConfig
+	Control ControlPlaneConfig
 	Log     LogConfig
 }
 
@@ -55,19 +99,49 @@ type WebhookConfig struct {
 	SecretToken    string
 	HealthPath     string
 	MetricsPath    string
+	ScalingPath    string
 	WebhookTimeout time.Duration
+	// MaxBodyBytes caps an incoming update; larger requests get 413.
+	MaxBodyBytes int64
+	// StatsPath serves anonymized per-chat usage; it is off while
+	// StatsToken is empty. StatsHashKey keys the chat id hashes; empty
+	// derives a key from the master key.
+	StatsPath    string
+	StatsToken   string
+	StatsHashKey string
+	// Failover polls while the webhook cannot be set or Telegram reports
+	// delivery errors on FailoverThreshold checks in a row, one every
+	// FailoverCheckInterval, and retries the webhook every
+	// FailoverRetryInterval.
+	Failover              bool
+	FailoverCheckInterval time.Duration
+	FailoverThreshold     int
+	FailoverRetryInterval time.Duration
 }
 
 type RedisConfig struct {
-	Addr          string
-	Password      string
-	DB            int
-	QueueStream   string
-	QueueGroup    string
-	QueueBlock    time.Duration
-	UpdateTTL     time.Duration
-	WizardTTL     time.Duration
-	AdminCacheTTL time.Duration
+	Addr        string
+	Password    string
+	DB          int
+	QueueStream string
+	QueueGroup  string
+	QueueBlock  time.Duration
+	// QueueCompression is off, gzip or zstd; payloads of at least
+	// QueueCompressMin bytes are compressed.
+	QueueCompression string
+	QueueCompressMin int
+	// QueueMaxLen and QueueMaxAge bound the job stream, trimmed every
+	// QueueTrimInterval; zero disables a limit.
+	QueueMaxLen       int64
+	QueueMaxAge       time.Duration
+	QueueTrimInterval time.Duration
+	EventsChannel     string
+	UpdateTTL         time.Duration
+	WizardTTL         time.Duration
+	AdminCacheTTL     time.Duration
+	// UpdateBucketSize is how many consecutive update ids share one
+	// dedupe hash in Redis.
+	UpdateBucketSize int64
 }
 
 type DBConfig struct {
@@ -77,19 +151,68 @@ type DBConfig struct {
 }
 
 type WorkerConfig struct {
-	Concurrency  int
-	ConsumerName string
-	MaxRetries   int
+	Concurrency       int
+	ConsumerName      string
+	MaxRetries        int
+	DropOrphanReplies bool
+	StoreHistory      bool
+	// AnswerInStatus edits the "Accepted" status message into the answer.
+	AnswerInStatus bool
+	// MaxConcurrency above Concurrency lets the worker add consumers while
+	// the queue backlog exceeds ScaleUpBacklog.
+	MaxConcurrency int
+	ScaleUpBacklog int64
+	ScaleDownIdle  time.Duration
+	// HeartbeatInterval is how often the worker publishes itself for
+	// /owner_workers; StallTimeout without stream reads or finished jobs
+	// makes the health endpoint fail.
+	HeartbeatInterval time.Duration
+	StallTimeout      time.Duration
+	// DeadConsumerIdle removes group consumers idle this long, checked
+	// every DeadConsumerInterval; zero disables it.
+	DeadConsumerIdle     time.Duration
+	DeadConsumerInterval time.Duration
+	// TokenizerDir holds tiktoken rank files named <encoding>.tiktoken;
+	// TokenizerModels maps model name prefixes to encodings on top of the
+	// built-in table.
+	TokenizerDir    string
+	TokenizerModels map[string]string
 }
 
 type HTTPConfig struct {
 	ClientTimeout time.Duration
 	MaxRetries    int
 	BackoffBase   time.Duration
+	MaxRetryAfter time.Duration
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	DisableHTTP2        bool
+	CABundleFile        string
+	ProxyURL            string
 }
 
 type RateConfig struct {
 	PerHour int64
+	// FallbackPerHour caps questions per chat answered by the owner's
+	// fallback preset in chats that have no presets yet.
+	FallbackPerHour int64
+}
+
+// BillingConfig limits chats to a free monthly quota and then to credits
+// granted by the owner or bought in packs. With FreeRequests 0 and
+// CreditsRequired off chats are unlimited.
+type BillingConfig struct {
+	FreeRequests    int64
+	CreditsRequired bool
+	TokensPerCredit int64
+	PackCredits     int64
+	PackPrice       int64
+	Currency        string
+	ProviderToken   string
 }
 
 type CryptoConfig struct {
@@ -97,6 +220,109 @@ type CryptoConfig struct {
 	Keys         map[string][]byte
 }
 
+type BackupConfig struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+}
+
+type ObjectStoreConfig struct {
+	Endpoint    string
+	Region      string
+	Bucket      string
+	AccessKey   string
+	SecretKey   string
+	PathStyle   bool
+	Prefix      string
+	ArtifactTTL time.Duration
+}
+
+func (c ObjectStoreConfig) Enabled() bool {
+	return c.Endpoint != "" && c.Bucket != ""
+}
+
+// SandboxConfig points at the owner's code runner offered to presets with
+// allow_tools. Each run is limited to Timeout and MemoryMB.
+type SandboxConfig struct {
+	URL            string
+	Token          string
+	Timeout        time.Duration
+	MemoryMB       int
+	MaxCodeBytes   int
+	MaxOutputBytes int
+}
+
+func (c SandboxConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// FetchConfig enables the fetch_url tool for presets with allow_tools.
+// Pages are read up to MaxBytes and cut to about MaxTokens of text.
+type FetchConfig struct {
+	Enabled   bool
+	Timeout   time.Duration
+	MaxBytes  int64
+	MaxTokens int
+}
+
+// PluginConfig declares a plugin process from PLUGINS_JSON, see package
+// plugin. Default enables it in chats that did not pick plugins with
+// /settings plugins.
+type PluginConfig struct {
+	Name    string
+	Command []string
+	// Env is passed to the plugin instead of the bot's environment.
+	Env     []string
+	Timeout time.Duration
+	Order   int
+	Default bool
+}
+
+// NotifyConfig tunes the outbound webhooks the owner adds with
+// /owner_webhook_add, see package notify. QuotaPercents and LowCredits are
+// where budget.threshold events fire: shares of the free monthly quota and
+// a credit balance.
+type NotifyConfig struct {
+	Timeout       time.Duration
+	MaxAttempts   int
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	Retention     time.Duration
+	QuotaPercents []int
+	LowCredits    int64
+}
+
+// EventStreamConfig publishes anonymized events to NATS or MQTT, see
+// package eventstream; it is off while URL is empty. HashKey falls back to
+// STATS_HASH_KEY.
+type EventStreamConfig struct {
+	URL      string
+	Subject  string
+	ClientID string
+	HashKey  string
+	Buffer   int
+	Timeout  time.Duration
+}
+
+// ControlPlaneConfig serves the gRPC control plane on ListenAddr for
+// callers bearing Token; it is off while Token is empty. Only ingress
+// processes (APP_MODE WEBHOOK or ALL) serve it. It speaks TLS when both
+// TLSCertFile and TLSKeyFile are set.
+type ControlPlaneConfig struct {
+	ListenAddr  string
+	Token       string
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+type TelegramSendLimits struct {
+	GlobalPerSecond int
+	GroupPerMinute  int
+	// MaxWait caps how long a call waits for a slot or a flood wait before
+	// it fails instead.
+	MaxWait time.Duration
+}
+
 type LogConfig struct {
 	Level string
 }
@@ -108,6 +334,22 @@ func Load() (*Config, error) {
 		BotAccessMode: strings.ToLower(mustEnv("BOT_ACCESS_MODE", AccessModePublic)),
 		AdminUserID:   mustInt64("ADMIN_USER_ID", 0),
 		DevPolling:    mustBool("DEV_POLLING", false),
+		AskEditWindow: mustDuration("ASK_EDIT_WINDOW", 60*time.Second),
+
+		LeftChatPurgeAfter:   mustDuration("LEFT_CHAT_PURGE_AFTER", 0),
+		UsageDigestInterval:  mustDuration("USAGE_DIGEST_INTERVAL", 168*time.Hour),
+		EphemeralMessageTTL:  mustDuration("EPHEMERAL_MESSAGE_TTL", time.Minute),
+		VerifyProviderKeys:   mustBool("VERIFY_PROVIDER_KEYS", true),
+		DocsURL:              mustEnv("DOCS_URL", "https://github.com/Mimic890/hyprbot#readme"),
+		PrivateSelfService:   mustBool("PRIVATE_SELF_SERVICE", true),
+		AllowAnonymousAdmins: mustBool("ALLOW_ANONYMOUS_ADMINS", true),
+
+		TelegramProxyURL: mustEnv("TELEGRAM_PROXY_URL", ""),
+		TelegramSendLimits: TelegramSendLimits{
+			GlobalPerSecond: mustInt("TELEGRAM_SENDS_PER_SECOND", 25),
+			GroupPerMinute:  mustInt("TELEGRAM_GROUP_SENDS_PER_MINUTE", 20),
+			MaxWait:         mustDuration("TELEGRAM_MAX_SEND_WAIT", time.Minute),
+		},
 		Webhook: WebhookConfig{
 			ListenAddr:     mustEnv("WEBHOOK_LISTEN_ADDR", ":8080"),
 			PublicURL:      mustEnv("WEBHOOK_URL", ""),
@@ -115,18 +357,35 @@ func Load() (*Config, error) {
 			SecretToken:    mustEnv("WEBHOOK_SECRET_TOKEN", ""),
 			HealthPath:     mustEnv("HEALTH_PATH", "/healthz"),
 			MetricsPath:    mustEnv("METRICS_PATH", "/metrics"),
+			ScalingPath:    mustEnv("SCALING_PATH", "/scaling"),
 			WebhookTimeout: mustDuration("WEBHOOK_TIMEOUT", 8*time.Second),
+			MaxBodyBytes:   mustInt64("WEBHOOK_MAX_BODY_BYTES", 1<<20),
+			StatsPath:      mustEnv("STATS_PATH", "/stats"),
+			StatsToken:     mustEnv("STATS_TOKEN", ""),
+			StatsHashKey:   mustEnv("STATS_HASH_KEY", ""),
+
+			Failover:              mustBool("WEBHOOK_FAILOVER", false),
+			FailoverCheckInterval: mustDuration("WEBHOOK_FAILOVER_CHECK_INTERVAL", time.Minute),
+			FailoverThreshold:     mustInt("WEBHOOK_FAILOVER_THRESHOLD", 3),
+			FailoverRetryInterval: mustDuration("WEBHOOK_FAILOVER_RETRY_INTERVAL", 5*time.Minute),
 		},
 		Redis: RedisConfig{
-			Addr:          mustEnv("REDIS_ADDR", "127.0.0.1:6379"),
-			Password:      mustEnv("REDIS_PASSWORD", ""),
-			DB:            mustInt("REDIS_DB", 0),
-			QueueStream:   mustEnv("QUEUE_STREAM", "hyprbot:jobs"),
-			QueueGroup:    mustEnv("QUEUE_GROUP", "hyprbot-workers"),
-			QueueBlock:    mustDuration("QUEUE_BLOCK", 5*time.Second),
-			UpdateTTL:     mustDuration("UPDATE_DEDUPE_TTL", 6*time.Hour),
-			WizardTTL:     mustDuration("WIZARD_TTL", 20*time.Minute),
-			AdminCacheTTL: mustDuration("ADMIN_CACHE_TTL", 10*time.Minute),
+			Addr:              mustEnv("REDIS_ADDR", "127.0.0.1:6379"),
+			Password:          mustEnv("REDIS_PASSWORD", ""),
+			DB:                mustInt("REDIS_DB", 0),
+			QueueStream:       mustEnv("QUEUE_STREAM", "hyprbot:jobs"),
+			QueueGroup:        mustEnv("QUEUE_GROUP", "hyprbot-workers"),
+			QueueBlock:        mustDuration("QUEUE_BLOCK", 5*time.Second),
+			QueueCompression:  strings.ToLower(mustEnv("QUEUE_COMPRESSION", "off")),
+			QueueCompressMin:  mustInt("QUEUE_COMPRESS_MIN_BYTES", 4096),
+			QueueMaxLen:       int64(mustInt("QUEUE_MAX_LEN", 100000)),
+			QueueMaxAge:       mustDuration("QUEUE_MAX_AGE", 24*time.Hour),
+			QueueTrimInterval: mustDuration("QUEUE_TRIM_INTERVAL", 5*time.Minute),
+			EventsChannel:     mustEnv("EVENTS_CHANNEL", "hyprbot:events"),
+			UpdateTTL:         mustDuration("UPDATE_DEDUPE_TTL", 6*time.Hour),
+			UpdateBucketSize:  mustInt64("UPDATE_DEDUPE_BUCKET_SIZE", 128),
+			WizardTTL:         mustDuration("WIZARD_TTL", 20*time.Minute),
+			AdminCacheTTL:     mustDuration("ADMIN_CACHE_TTL", 10*time.Minute),
 		},
 		DB: DBConfig{
 			Driver:      strings.ToLower(mustEnv("DB_DRIVER", "postgres")),
@@ -134,17 +393,101 @@ func Load() (*Config, error) {
 			AutoMigrate: mustBool("AUTO_MIGRATE", true),
 		},
 		Worker: WorkerConfig{
-			Concurrency:  mustInt("WORKER_CONCURRENCY", 4),
-			ConsumerName: mustEnv("WORKER_CONSUMER_NAME", hostnameOr("worker")),
-			MaxRetries:   mustInt("WORKER_MAX_RETRIES", 3),
+			Concurrency:       mustInt("WORKER_CONCURRENCY", 4),
+			ConsumerName:      mustEnv("WORKER_CONSUMER_NAME", hostnameOr("worker")),
+			MaxRetries:        mustInt("WORKER_MAX_RETRIES", 3),
+			DropOrphanReplies: mustBool("WORKER_DROP_ORPHAN_REPLIES", false),
+			StoreHistory:      mustBool("STORE_HISTORY", false),
+			AnswerInStatus:    mustBool("WORKER_ANSWER_IN_STATUS", true),
+			MaxConcurrency:    mustInt("WORKER_MAX_CONCURRENCY", 0),
+			ScaleUpBacklog:    int64(mustInt("WORKER_SCALE_UP_BACKLOG", 10)),
+			ScaleDownIdle:     mustDuration("WORKER_SCALE_DOWN_IDLE", time.Minute),
+			HeartbeatInterval: mustDuration("WORKER_HEARTBEAT_INTERVAL", 15*time.Second),
+			StallTimeout:      mustDuration("WORKER_STALL_TIMEOUT", 10*time.Minute),
+
+			DeadConsumerIdle:     mustDuration("WORKER_DEAD_CONSUMER_IDLE", time.Hour),
+			DeadConsumerInterval: mustDuration("WORKER_DEAD_CONSUMER_INTERVAL", 30*time.Minute),
+
+			TokenizerDir: mustEnv("TOKENIZER_DIR", ""),
 		},
 		HTTP: HTTPConfig{
 			ClientTimeout: mustDuration("HTTP_TIMEOUT", 30*time.Second),
 			MaxRetries:    mustInt("HTTP_MAX_RETRIES", 2),
 			BackoffBase:   mustDuration("HTTP_BACKOFF_BASE", 400*time.Millisecond),
+			MaxRetryAfter: mustDuration("HTTP_MAX_RETRY_AFTER", 30*time.Second),
+
+			MaxIdleConns:        mustInt("HTTP_MAX_IDLE_CONNS", 100),
+			MaxIdleConnsPerHost: mustInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 16),
+			MaxConnsPerHost:     mustInt("HTTP_MAX_CONNS_PER_HOST", 0),
+			IdleConnTimeout:     mustDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+			TLSHandshakeTimeout: mustDuration("HTTP_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+			DisableHTTP2:        mustBool("HTTP_DISABLE_HTTP2", false),
+			CABundleFile:        mustEnv("HTTP_CA_BUNDLE", ""),
+			ProxyURL:            mustEnv("PROVIDER_PROXY_URL", ""),
 		},
 		Rate: RateConfig{
-			PerHour: int64(mustInt("RATE_LIMIT_PER_HOUR", 30)),
+			PerHour:         int64(mustInt("RATE_LIMIT_PER_HOUR", 30)),
+			FallbackPerHour: int64(mustInt("FALLBACK_RATE_LIMIT_PER_HOUR", 5)),
+		},
+		Billing: BillingConfig{
+			FreeRequests:    mustInt64("FREE_REQUESTS_PER_MONTH", 0),
+			CreditsRequired: mustBool("CREDITS_ENABLED", false),
+			TokensPerCredit: mustInt64("TOKENS_PER_CREDIT", 0),
+			PackCredits:     mustInt64("CREDIT_PACK_SIZE", 100),
+			PackPrice:       mustInt64("CREDIT_PACK_PRICE", 50),
+			Currency:        strings.ToUpper(mustEnv("PAYMENT_CURRENCY", "XTR")),
+			ProviderToken:   mustEnv("PAYMENT_PROVIDER_TOKEN", ""),
+		},
+		Backup: BackupConfig{
+			Dir:      mustEnv("BACKUP_DIR", ""),
+			Interval: mustDuration("BACKUP_INTERVAL", 0),
+			Keep:     mustInt("BACKUP_KEEP", 7),
+		},
+		Objects: ObjectStoreConfig{
+			Endpoint:    mustEnv("S3_ENDPOINT", ""),
+			Region:      mustEnv("S3_REGION", "us-east-1"),
+			Bucket:      mustEnv("S3_BUCKET", ""),
+			AccessKey:   mustEnv("S3_ACCESS_KEY", ""),
+			SecretKey:   mustEnv("S3_SECRET_KEY", ""),
+			PathStyle:   mustBool("S3_PATH_STYLE", true),
+			Prefix:      strings.Trim(mustEnv("S3_PREFIX", "hyprbot"), "/"),
+			ArtifactTTL: mustDuration("ARTIFACT_TTL", 7*24*time.Hour),
+		},
+		Sandbox: SandboxConfig{
+			URL:            mustEnv("SANDBOX_URL", ""),
+			Token:          mustEnv("SANDBOX_TOKEN", ""),
+			Timeout:        mustDuration("SANDBOX_TIMEOUT", 10*time.Second),
+			MemoryMB:       mustInt("SANDBOX_MEMORY_MB", 256),
+			MaxCodeBytes:   mustInt("SANDBOX_MAX_CODE_BYTES", 16384),
+			MaxOutputBytes: mustInt("SANDBOX_MAX_OUTPUT_BYTES", 8192),
+		},
+		Fetch: FetchConfig{
+			Enabled:   mustBool("FETCH_URL_ENABLED", false),
+			Timeout:   mustDuration("FETCH_URL_TIMEOUT", 10*time.Second),
+			MaxBytes:  mustInt64("FETCH_URL_MAX_BYTES", 2<<20),
+			MaxTokens: mustInt("FETCH_URL_MAX_TOKENS", 3000),
+		},
+		Notify: NotifyConfig{
+			Timeout:     mustDuration("NOTIFY_WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts: mustInt("NOTIFY_WEBHOOK_MAX_ATTEMPTS", 8),
+			Backoff:     mustDuration("NOTIFY_WEBHOOK_BACKOFF", 30*time.Second),
+			MaxBackoff:  mustDuration("NOTIFY_WEBHOOK_MAX_BACKOFF", time.Hour),
+			Retention:   mustDuration("NOTIFY_DELIVERY_RETENTION", 30*24*time.Hour),
+			LowCredits:  mustInt64("NOTIFY_LOW_CREDITS", 10),
+		},
+		Events: EventStreamConfig{
+			URL:      mustEnv("EVENT_STREAM_URL", ""),
+			Subject:  mustEnv("EVENT_STREAM_SUBJECT", ""),
+			ClientID: mustEnv("EVENT_STREAM_CLIENT_ID", "hyprbot-"+hostnameOr("bot")),
+			HashKey:  mustEnv("EVENT_STREAM_HASH_KEY", mustEnv("STATS_HASH_KEY", "")),
+			Buffer:   mustInt("EVENT_STREAM_BUFFER", 1024),
+			Timeout:  mustDuration("EVENT_STREAM_TIMEOUT", 5*time.Second),
+		},
+		Control: ControlPlaneConfig{
+			ListenAddr:  mustEnv("CONTROL_PLANE_LISTEN_ADDR", "127.0.0.1:9090"),
+			Token:       mustEnv("CONTROL_PLANE_TOKEN", ""),
+			TLSCertFile: mustEnv("CONTROL_PLANE_TLS_CERT", ""),
+			TLSKeyFile:  mustEnv("CONTROL_PLANE_TLS_KEY", ""),
 		},
 		Log: LogConfig{
 			Level: strings.ToLower(mustEnv("LOG_LEVEL", "info")),
@@ -166,6 +509,37 @@ func Load() (*Config, error) {
 	if cfg.AppMode != ModeAll && cfg.AppMode != ModeWebhook && cfg.AppMode != ModeWorker {
 		return nil, fmt.Errorf("unsupported APP_MODE %q", cfg.AppMode)
 	}
+	if (cfg.Control.TLSCertFile == "") != (cfg.Control.TLSKeyFile == "") {
+		return nil, ErrPartialControlTLS
+	}
+	allowed, err := parseAllowedUpdates(mustEnv("ALLOWED_UPDATES", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.AllowedUpdates = allowed
+
+	if raw := mustEnv("TOKENIZER_MODELS_JSON", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Worker.TokenizerModels); err != nil {
+			return nil, fmt.Errorf("parse TOKENIZER_MODELS_JSON: %w", err)
+		}
+	}
+
+	percents, err := parsePercents(mustEnv("NOTIFY_QUOTA_PERCENTS", "80,100"))
+	if err != nil {
+		return nil, fmt.Errorf("NOTIFY_QUOTA_PERCENTS: %w", err)
+	}
+	cfg.Notify.QuotaPercents = percents
+
+	limited := cfg.Billing.FreeRequests > 0 || cfg.Billing.CreditsRequired
+	if limited && cfg.Billing.PackPrice > 0 && cfg.Billing.Currency != "XTR" && cfg.Billing.ProviderToken == "" {
+		return nil, ErrMissingPayToken
+	}
+
+	plugins, err := loadPlugins()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Plugins = plugins
 
 	cc, err := loadCryptoConfig()
 	if err != nil {
@@ -176,6 +550,57 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+var pluginNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// loadPlugins parses PLUGINS_JSON, a list such as
+// [{"name":"moderate","command":["/opt/moderate","--strict"],"env":{"API_URL":"..."},"timeout":"5s","order":400,"default":true}].
+func loadPlugins() ([]PluginConfig, error) {
+	raw := mustEnv("PLUGINS_JSON", "")
+	if raw == "" {
+		return nil, nil
+	}
+	var parsed []struct {
+		Name    string            `json:"name"`
+		Command []string          `json:"command"`
+		Env     map[string]string `json:"env"`
+		Timeout string            `json:"timeout"`
+		Order   int               `json:"order"`
+		Default bool              `json:"default"`
+	}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("parse PLUGINS_JSON: %w", err)
+	}
+	seen := map[string]bool{}
+	plugins := make([]PluginConfig, 0, len(parsed))
+	for _, p := range parsed {
+		if !pluginNameRe.MatchString(p.Name) || seen[p.Name] {
+			return nil, fmt.Errorf("PLUGINS_JSON: plugin name %q must be unique, lowercase letters, digits, - or _", p.Name)
+		}
+		seen[p.Name] = true
+		if len(p.Command) == 0 || strings.TrimSpace(p.Command[0]) == "" {
+			return nil, fmt.Errorf("PLUGINS_JSON: plugin %s needs a command", p.Name)
+		}
+		timeout := 10 * time.Second
+		if p.Timeout != "" {
+			d, err := time.ParseDuration(p.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("PLUGINS_JSON: plugin %s: invalid timeout %q", p.Name, p.Timeout)
+			}
+			timeout = d
+		}
+		env := make([]string, 0, len(p.Env))
+		for k, v := range p.Env {
+			if k == "" || strings.ContainsAny(k, "=\x00") {
+				return nil, fmt.Errorf("PLUGINS_JSON: plugin %s: invalid env name %q", p.Name, k)
+			}
+			env = append(env, k+"="+v)
+		}
+		slices.Sort(env)
+		plugins = append(plugins, PluginConfig{Name: p.Name, Command: p.Command, Env: env, Timeout: timeout, Order: p.Order, Default: p.Default})
+	}
+	return plugins, nil
+}
+
 func loadCryptoConfig() (CryptoConfig, error) {
 	keysB64 := map[string]string{}
 
@@ -251,6 +676,62 @@ func loadCryptoConfig() (CryptoConfig, error) {
 	}, nil
 }
 
+// TelegramUpdateTypes are the update types Telegram can deliver.
+var TelegramUpdateTypes = []string{
+	"message", "edited_message", "channel_post", "edited_channel_post",
+	"business_connection", "business_message", "edited_business_message", "deleted_business_messages",
+	"message_reaction", "message_reaction_count", "inline_query", "chosen_inline_result",
+	"callback_query", "shipping_query", "pre_checkout_query", "purchased_paid_media",
+	"poll", "poll_answer", "my_chat_member", "chat_member", "chat_join_request",
+	"chat_boost", "removed_chat_boost",
+}
+
+// parseAllowedUpdates reads a comma-separated list of update types; "all"
+// stands for every type and an empty value for the default.
+func parseAllowedUpdates(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(raw, "all") {
+		return append([]string(nil), TelegramUpdateTypes...), nil
+	}
+	var out []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || slices.Contains(out, t) {
+			continue
+		}
+		if !slices.Contains(TelegramUpdateTypes, t) {
+			return nil, fmt.Errorf("ALLOWED_UPDATES: unknown update type %q", t)
+		}
+		out = append(out, t)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("ALLOWED_UPDATES lists no update types")
+	}
+	return out, nil
+}
+
+// parsePercents reads a comma separated list of percentages from 1 to 100.
+func parsePercents(raw string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("invalid percentage %q", part)
+		}
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
 func mustEnv(key string, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return strings.TrimSpace(v)
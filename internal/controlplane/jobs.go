@@ -0,0 +1,85 @@
+package controlplane
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "hyprbot/internal/controlplane/controlplanev1"
+	"hyprbot/internal/queue"
+)
+
+func (s *Server) GetQueueDepth(ctx context.Context, _ *pb.GetQueueDepthRequest) (*pb.GetQueueDepthResponse, error) {
+	depth, err := s.queue.Depth(ctx)
+	if err != nil {
+		return nil, s.unavailable("queue depth", err)
+	}
+	return &pb.GetQueueDepthResponse{Waiting: depth.Waiting, Pending: depth.Pending}, nil
+}
+
+func (s *Server) ListWorkers(ctx context.Context, _ *pb.ListWorkersRequest) (*pb.ListWorkersResponse, error) {
+	workers, err := s.workers.Live(ctx, s.now())
+	if err != nil {
+		return nil, s.unavailable("list workers", err)
+	}
+	out := &pb.ListWorkersResponse{Workers: make([]*pb.Worker, 0, len(workers))}
+	for _, w := range workers {
+		out.Workers = append(out.Workers, &pb.Worker{
+			Consumer:      w.Consumer,
+			Hostname:      w.Hostname,
+			StartedAt:     timestamppb.New(w.StartedAt),
+			BeatAt:        timestamppb.New(w.BeatAt),
+			LastProgress:  timestamppb.New(w.LastProgress),
+			Consumers:     int32(w.Consumers),
+			JobsProcessed: w.JobsProcessed,
+			JobsFailed:    w.JobsFailed,
+			Stalled:       w.Stalled,
+		})
+	}
+	return out, nil
+}
+
+func (s *Server) GetMaintenance(ctx context.Context, _ *pb.GetMaintenanceRequest) (*pb.GetMaintenanceResponse, error) {
+	state, on, err := s.maintenance.State(ctx)
+	if err != nil {
+		return nil, s.unavailable("maintenance state", err)
+	}
+	if !on {
+		return &pb.GetMaintenanceResponse{}, nil
+	}
+	return &pb.GetMaintenanceResponse{Enabled: true, Since: timestamppb.New(state.Since), Message: state.Message}, nil
+}
+
+// SetMaintenance is audited as maintenance_on and maintenance_off under
+// chat 0, like /owner_maintenance.
+func (s *Server) SetMaintenance(ctx context.Context, req *pb.SetMaintenanceRequest) (*pb.SetMaintenanceResponse, error) {
+	if req.GetEnabled() {
+		ok, err := s.maintenance.Enable(ctx, queue.MaintenanceState{Since: s.now(), Message: req.GetMessage()})
+		if err != nil {
+			return nil, s.unavailable("enable maintenance", err)
+		}
+		if ok {
+			s.audit(ctx, 0, "maintenance_on", map[string]any{"message": req.GetMessage()})
+		}
+		return &pb.SetMaintenanceResponse{Changed: ok}, nil
+	}
+	notices, was, err := s.maintenance.Disable(ctx)
+	if err != nil {
+		return nil, s.unavailable("disable maintenance", err)
+	}
+	if was {
+		s.audit(ctx, 0, "maintenance_off", map[string]any{"notices": len(notices)})
+		if s.maintenanceOver != nil {
+			go s.maintenanceOver(notices)
+		}
+	}
+	return &pb.SetMaintenanceResponse{Changed: was}, nil
+}
+
+// unavailable logs a Redis failure and hides it from the client.
+func (s *Server) unavailable(op string, err error) error {
+	s.log.Error().Err(err).Str("op", op).Msg("control plane call failed")
+	return status.Error(codes.Unavailable, op+" failed")
+}
@@ -0,0 +1,77 @@
+package controlplane
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	pb "hyprbot/internal/controlplane/controlplanev1"
+	"hyprbot/internal/queue"
+)
+
+func TestServerJobs(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	registry := queue.NewWorkerRegistry(rdb, time.Minute)
+	if err := registry.Beat(ctx, queue.WorkerInfo{Consumer: "w1", Hostname: "host", BeatAt: time.Now(), JobsProcessed: 3}); err != nil {
+		t.Fatalf("beat: %v", err)
+	}
+	maintenance := queue.NewMaintenance(rdb)
+	over := make(chan []queue.MaintenanceNotice, 1)
+	client := startServerWith(t, Config{
+		Store:           openTestStore(t),
+		Workers:         registry,
+		Maintenance:     maintenance,
+		MaintenanceOver: func(n []queue.MaintenanceNotice) { over <- n },
+		Token:           "secret",
+	})
+	call := withToken("secret")
+
+	workers, err := client.ListWorkers(call, &pb.ListWorkersRequest{})
+	if err != nil {
+		t.Fatalf("ListWorkers: %v", err)
+	}
+	if len(workers.GetWorkers()) != 1 || workers.GetWorkers()[0].GetConsumer() != "w1" || workers.GetWorkers()[0].GetJobsProcessed() != 3 {
+		t.Errorf("ListWorkers = %v", workers)
+	}
+
+	res, err := client.SetMaintenance(call, &pb.SetMaintenanceRequest{Enabled: true, Message: "upgrading"})
+	if err != nil || !res.GetChanged() {
+		t.Fatalf("SetMaintenance(on) = %v, %v", res, err)
+	}
+	if res, err := client.SetMaintenance(call, &pb.SetMaintenanceRequest{Enabled: true}); err != nil || res.GetChanged() {
+		t.Errorf("SetMaintenance(on) twice = %v, %v; want unchanged", res, err)
+	}
+	state, err := client.GetMaintenance(call, &pb.GetMaintenanceRequest{})
+	if err != nil || !state.GetEnabled() || state.GetMessage() != "upgrading" {
+		t.Errorf("GetMaintenance = %v, %v", state, err)
+	}
+	if err := maintenance.AddNotice(ctx, queue.MaintenanceNotice{ChatID: -100, MessageID: 7}); err != nil {
+		t.Fatalf("add notice: %v", err)
+	}
+
+	if res, err := client.SetMaintenance(call, &pb.SetMaintenanceRequest{}); err != nil || !res.GetChanged() {
+		t.Fatalf("SetMaintenance(off) = %v, %v", res, err)
+	}
+	select {
+	case n := <-over:
+		if len(n) != 1 || n[0].MessageID != 7 {
+			t.Errorf("notices = %v, want message 7", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("maintenance notices were not handed over")
+	}
+	if state, err := client.GetMaintenance(call, &pb.GetMaintenanceRequest{}); err != nil || state.GetEnabled() {
+		t.Errorf("GetMaintenance after off = %v, %v", state, err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package controlplane serves the gRPC control-plane API described in
+// api/proto/hyprbot/controlplane/v1: typed management of the providers,
+// presets, job queue and usage of every chat for fleet tooling. Calls are
+// authorized with one bearer token; mutations are audited like the
+// Telegram commands they mirror.
+package controlplane
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "hyprbot/internal/controlplane/controlplanev1"
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/eventstream"
+	"hyprbot/internal/notify"
+	"hyprbot/internal/providers/anthropic_messages"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+const (
+	// defaultUsagePeriod is the usage window of requests that name none.
+	defaultUsagePeriod = 30 * 24 * time.Hour
+	// defaultPresetParams are the params of presets saved without any, as
+	// /ai_preset_add saves them.
+	defaultPresetParams = `{"max_tokens":1024,"temperature":0.7,"allow_tools":false}`
+)
+
+// providerNameRegex matches the provider names the /llm_add wizard accepts.
+var providerNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type Config struct {
+	Store       *storage.Store
+	Queue       *queue.StreamQueue
+	Workers     *queue.WorkerRegistry
+	Maintenance *queue.Maintenance
+	Crypto      *crypto.Manager
+	Token       string
+	Notify      *notify.Dispatcher
+	// EventStream may be nil.
+	EventStream *eventstream.Publisher
+	// MaintenanceOver edits the "under maintenance" notices once
+	// SetMaintenance ends it. Nil drops them.
+	MaintenanceOver func([]queue.MaintenanceNotice)
+	Logger          zerolog.Logger
+}
+
+type Server struct {
+	pb.UnimplementedControlPlaneServer
+
+	store           *storage.Store
+	queue           *queue.StreamQueue
+	workers         *queue.WorkerRegistry
+	maintenance     *queue.Maintenance
+	crypto          *crypto.Manager
+	token           string
+	notify          *notify.Dispatcher
+	stream          *eventstream.Publisher
+	maintenanceOver func([]queue.MaintenanceNotice)
+	log             zerolog.Logger
+	now             func() time.Time
+}
+
+func New(cfg Config) *Server {
+	return &Server{
+		store:           cfg.Store,
+		queue:           cfg.Queue,
+		workers:         cfg.Workers,
+		maintenance:     cfg.Maintenance,
+		crypto:          cfg.Crypto,
+		token:           cfg.Token,
+		notify:          cfg.Notify,
+		stream:          cfg.EventStream,
+		maintenanceOver: cfg.MaintenanceOver,
+		log:             cfg.Logger,
+		now:             time.Now,
+	}
+}
+
+// GRPCServer returns a grpc.Server with s registered behind the token check.
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(s.authorize))
+	srv := grpc.NewServer(opts...)
+	pb.RegisterControlPlaneServer(srv, s)
+	return srv
+}
+
+// authorize rejects calls without an "authorization: Bearer <token>"
+// metadata entry matching the configured token. An empty token rejects
+// everything.
+func (s *Server) authorize(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && s.token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1 {
+			return handler(ctx, req)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+func (s *Server) ListProviders(ctx context.Context, req *pb.ListProvidersRequest) (*pb.ListProvidersResponse, error) {
+	providers, err := s.store.ListProviders(ctx, req.GetChatId())
+	if err != nil {
+		return nil, s.internal("list providers", err)
+	}
+	out := &pb.ListProvidersResponse{Providers: make([]*pb.Provider, 0, len(providers))}
+	for _, p := range providers {
+		out.Providers = append(out.Providers, &pb.Provider{
+			Id:            p.ID,
+			Name:          p.Name,
+			Kind:          p.Kind,
+			BaseUrl:       p.BaseURL,
+			HasApiKey:     p.EncAPIKey != nil && *p.EncAPIKey != "",
+			CreatedAt:     timestamppb.New(p.CreatedAt),
+			LastSuccessAt: timestampOrNil(p.LastSuccessAt),
+			LastErrorAt:   timestampOrNil(p.LastErrorAt),
+			LastError:     p.LastErrorText,
+		})
+	}
+	return out, nil
+}
+
+// UpsertProvider saves a provider the way the /llm_add wizard does, with
+// the API key encrypted. It is audited as provider_add.
+func (s *Server) UpsertProvider(ctx context.Context, req *pb.UpsertProviderRequest) (*pb.UpsertProviderResponse, error) {
+	chatID, name := req.GetChatId(), strings.TrimSpace(req.GetName())
+	if !providerNameRegex.MatchString(name) {
+		return nil, status.Error(codes.InvalidArgument, "name must be 1-64 letters, digits, _ or -")
+	}
+	baseURL := strings.TrimSpace(req.GetBaseUrl())
+	cfg := map[string]any{}
+	switch kind := req.GetKind(); kind {
+	case "openai_compat":
+		if baseURL == "" {
+			return nil, status.Error(codes.InvalidArgument, "base_url is required for openai_compat")
+		}
+		endpoint := req.GetEndpoint()
+		if endpoint == "" {
+			endpoint = "chat_completions"
+		}
+		if endpoint != "chat_completions" && endpoint != "responses" {
+			return nil, status.Error(codes.InvalidArgument, "endpoint must be chat_completions or responses")
+		}
+		cfg["endpoint"] = endpoint
+	case "anthropic":
+		if baseURL == "" {
+			baseURL = anthropic_messages.DefaultBaseURL
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported kind %q: use openai_compat or anthropic", kind)
+	}
+
+	var encAPIKey *string
+	if key := strings.TrimSpace(req.GetApiKey()); key != "" {
+		v, err := s.crypto.MarshalEncryptedString(key)
+		if err != nil {
+			return nil, s.internal("encrypt api key", err)
+		}
+		encAPIKey = &v
+	}
+	cfgJSON, _ := json.Marshal(cfg)
+	id, err := s.store.UpsertProviderInstance(ctx, storage.ProviderInstance{
+		ChatID:     chatID,
+		Name:       name,
+		Kind:       req.GetKind(),
+		BaseURL:    baseURL,
+		EncAPIKey:  encAPIKey,
+		ConfigJSON: string(cfgJSON),
+	})
+	if err != nil {
+		return nil, s.internal("upsert provider", err)
+	}
+	s.audit(ctx, chatID, "provider_add", map[string]any{"name": name, "kind": req.GetKind()})
+	return &pb.UpsertProviderResponse{Id: id}, nil
+}
+
+// DeleteProvider is audited as provider_del, like /llm_del.
+func (s *Server) DeleteProvider(ctx context.Context, req *pb.DeleteProviderRequest) (*pb.DeleteProviderResponse, error) {
+	chatID, name := req.GetChatId(), strings.TrimSpace(req.GetName())
+	if err := s.store.DeleteProviderByName(ctx, chatID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "provider %q not found in chat %d", name, chatID)
+		}
+		return nil, s.internal("delete provider", err)
+	}
+	s.audit(ctx, chatID, "provider_del", map[string]any{"name": name})
+	return &pb.DeleteProviderResponse{}, nil
+}
+
+func (s *Server) ListPresets(ctx context.Context, req *pb.ListPresetsRequest) (*pb.ListPresetsResponse, error) {
+	presets, err := s.store.ListPresets(ctx, req.GetChatId())
+	if err != nil {
+		return nil, s.internal("list presets", err)
+	}
+	def, err := s.store.GetDefaultPresetName(ctx, req.GetChatId())
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, s.internal("get default preset", err)
+	}
+	out := &pb.ListPresetsResponse{Presets: make([]*pb.Preset, 0, len(presets))}
+	for _, p := range presets {
+		out.Presets = append(out.Presets, &pb.Preset{
+			Name:           p.Name,
+			ProviderId:     p.ProviderInstanceID,
+			Model:          p.Model,
+			SystemPrompt:   p.SystemPrompt,
+			ParamsJson:     p.ParamsJSON,
+			IsDefault:      p.Name == def,
+			DegradedReason: p.DegradedReason,
+			CreatedAt:      timestamppb.New(p.CreatedAt),
+		})
+	}
+	return out, nil
+}
+
+// UpsertPreset saves a preset the way /ai_preset_add does, making it the
+// default of a chat that has none. It is audited as preset_add.
+func (s *Server) UpsertPreset(ctx context.Context, req *pb.UpsertPresetRequest) (*pb.UpsertPresetResponse, error) {
+	chatID, name := req.GetChatId(), strings.TrimSpace(req.GetName())
+	providerName, model := strings.TrimSpace(req.GetProvider()), strings.TrimSpace(req.GetModel())
+	if name == "" || providerName == "" || model == "" {
+		return nil, status.Error(codes.InvalidArgument, "name, provider and model are required")
+	}
+	params := strings.TrimSpace(req.GetParamsJson())
+	if params == "" {
+		params = defaultPresetParams
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(params), &obj); err != nil || obj == nil {
+		return nil, status.Error(codes.InvalidArgument, "params_json must be a JSON object")
+	}
+	provider, err := s.store.GetProviderByName(ctx, chatID, providerName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "provider %q not found in chat %d", providerName, chatID)
+		}
+		return nil, s.internal("get provider", err)
+	}
+	if err := s.store.UpsertPreset(ctx, storage.Preset{
+		ChatID:             chatID,
+		Name:               name,
+		ProviderInstanceID: provider.ID,
+		Model:              model,
+		SystemPrompt:       strings.TrimSpace(req.GetSystemPrompt()),
+		ParamsJSON:         params,
+	}); err != nil {
+		return nil, s.internal("upsert preset", err)
+	}
+	if _, err := s.store.GetDefaultPresetName(ctx, chatID); errors.Is(err, storage.ErrNotFound) {
+		_ = s.store.SetDefaultPreset(ctx, chatID, name)
+	}
+	s.audit(ctx, chatID, "preset_add", map[string]any{"name": name, "provider": providerName, "model": model})
+	return &pb.UpsertPresetResponse{}, nil
+}
+
+// DeletePreset clears the slots that pointed at the preset and is audited
+// as preset_del, like /ai_preset_del.
+func (s *Server) DeletePreset(ctx context.Context, req *pb.DeletePresetRequest) (*pb.DeletePresetResponse, error) {
+	chatID, name := req.GetChatId(), strings.TrimSpace(req.GetName())
+	if err := s.store.DeletePreset(ctx, chatID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "preset %q not found in chat %d", name, chatID)
+		}
+		return nil, s.internal("delete preset", err)
+	}
+	for _, slot := range storage.DefaultSlots {
+		if def, err := s.store.GetSlotPresetName(ctx, chatID, slot); err == nil && def == name {
+			_ = s.store.ClearSlotPreset(ctx, chatID, slot)
+		}
+	}
+	s.audit(ctx, chatID, "preset_del", map[string]any{"name": name})
+	return &pb.DeletePresetResponse{}, nil
+}
+
+// SetDefaultPreset is audited and announced like the Telegram command, with
+// user id 0 for the control plane.
+func (s *Server) SetDefaultPreset(ctx context.Context, req *pb.SetDefaultPresetRequest) (*pb.SetDefaultPresetResponse, error) {
+	chatID, name := req.GetChatId(), strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if _, err := s.store.GetPresetWithProviderByName(ctx, chatID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "preset %q not found in chat %d", name, chatID)
+		}
+		return nil, s.internal("get preset", err)
+	}
+	if err := s.store.SetDefaultPreset(ctx, chatID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "chat %d not found", chatID)
+		}
+		return nil, s.internal("set default preset", err)
+	}
+	s.audit(ctx, chatID, "preset_default", map[string]any{"name": name})
+	return &pb.SetDefaultPresetResponse{}, nil
+}
+
+func (s *Server) GetUsage(ctx context.Context, req *pb.GetUsageRequest) (*pb.GetUsageResponse, error) {
+	if req.GetPeriodSeconds() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "period_seconds must not be negative")
+	}
+	period := time.Duration(req.GetPeriodSeconds()) * time.Second
+	if period == 0 {
+		period = defaultUsagePeriod
+	}
+	usage, err := s.store.UsageSummary(ctx, req.GetChatId(), period)
+	if err != nil {
+		return nil, s.internal("usage summary", err)
+	}
+	return &pb.GetUsageResponse{
+		Requests:     usage.Requests,
+		Failed:       usage.Failed,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+	}, nil
+}
+
+func (s *Server) audit(ctx context.Context, chatID int64, action string, meta map[string]any) {
+	meta["source"] = "control_plane"
+	b, _ := json.Marshal(meta)
+	s.notify.Emit(ctx, notify.Event{
+		Type:   notify.EventConfigChanged,
+		ChatID: chatID,
+		Text:   "Chat config changed over the control plane: " + action,
+		Data:   map[string]any{"action": action, "meta": meta},
+	})
+	s.stream.Publish(eventstream.EventConfigChanged, chatID, 0, map[string]any{"action": action})
+	if err := s.store.LogAction(ctx, storage.AuditEntry{ChatID: chatID, Action: action, MetaJSON: string(b)}); err != nil {
+		s.log.Warn().Err(err).Int64("chat_id", chatID).Str("action", action).Msg("control plane: audit failed")
+	}
+}
+
+// internal logs err and hides it from the client.
+func (s *Server) internal(op string, err error) error {
+	s.log.Error().Err(err).Str("op", op).Msg("control plane call failed")
+	return status.Error(codes.Internal, op+" failed")
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,195 @@
+package controlplane
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "hyprbot/internal/controlplane/controlplanev1"
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/providers/anthropic_messages"
+	"hyprbot/internal/storage"
+)
+
+func startServer(t *testing.T, store *storage.Store, token string) pb.ControlPlaneClient {
+	t.Helper()
+	return startServerWith(t, Config{Store: store, Token: token})
+}
+
+func startServerWith(t *testing.T, cfg Config) pb.ControlPlaneClient {
+	t.Helper()
+	cfg.Logger = zerolog.Nop()
+	lis := bufconn.Listen(1 << 20)
+	srv := New(cfg).GRPCServer()
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewControlPlaneClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	store := openTestStore(t)
+	client := startServer(t, store, "secret")
+
+	for _, ctx := range []context.Context{context.Background(), withToken("wrong")} {
+		_, err := client.ListPresets(ctx, &pb.ListPresetsRequest{ChatId: -100})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ListPresets = %v, want Unauthenticated", err)
+		}
+	}
+	if _, err := client.ListPresets(withToken("secret"), &pb.ListPresetsRequest{ChatId: -100}); err != nil {
+		t.Errorf("ListPresets with the token: %v", err)
+	}
+
+	// Without a configured token nothing gets through.
+	client = startServer(t, store, "")
+	if _, err := client.ListPresets(withToken(""), &pb.ListPresetsRequest{ChatId: -100}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListPresets without a configured token = %v, want Unauthenticated", err)
+	}
+}
+
+func TestServerPresets(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	key := "encrypted"
+	providerID, err := store.UpsertProviderInstance(ctx, storage.ProviderInstance{ChatID: -100, Name: "openai", Kind: "openai_compat", BaseURL: "https://api.openai.com/v1", EncAPIKey: &key})
+	if err != nil {
+		t.Fatalf("upsert provider: %v", err)
+	}
+	for _, name := range []string{"fast", "smart"} {
+		if err := store.UpsertPreset(ctx, storage.Preset{ChatID: -100, Name: name, ProviderInstanceID: providerID, Model: "gpt-4o-mini", ParamsJSON: "{}"}); err != nil {
+			t.Fatalf("upsert preset: %v", err)
+		}
+	}
+	client := startServer(t, store, "secret")
+	call := withToken("secret")
+
+	providers, err := client.ListProviders(call, &pb.ListProvidersRequest{ChatId: -100})
+	if err != nil {
+		t.Fatalf("ListProviders: %v", err)
+	}
+	if len(providers.GetProviders()) != 1 || !providers.GetProviders()[0].GetHasApiKey() || providers.GetProviders()[0].GetName() != "openai" {
+		t.Errorf("ListProviders = %v", providers)
+	}
+
+	if _, err := client.SetDefaultPreset(call, &pb.SetDefaultPresetRequest{ChatId: -100, Name: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("SetDefaultPreset(missing) = %v, want NotFound", err)
+	}
+	if _, err := client.SetDefaultPreset(call, &pb.SetDefaultPresetRequest{ChatId: -100, Name: "smart"}); err != nil {
+		t.Fatalf("SetDefaultPreset: %v", err)
+	}
+	presets, err := client.ListPresets(call, &pb.ListPresetsRequest{ChatId: -100})
+	if err != nil {
+		t.Fatalf("ListPresets: %v", err)
+	}
+	defaults := map[string]bool{}
+	for _, p := range presets.GetPresets() {
+		defaults[p.GetName()] = p.GetIsDefault()
+	}
+	if len(defaults) != 2 || defaults["fast"] || !defaults["smart"] {
+		t.Errorf("ListPresets defaults = %v, want only smart", defaults)
+	}
+
+	if _, err := client.GetUsage(call, &pb.GetUsageRequest{ChatId: -100, PeriodSeconds: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetUsage(-1) = %v, want InvalidArgument", err)
+	}
+	usage, err := client.GetUsage(call, &pb.GetUsageRequest{ChatId: -100})
+	if err != nil || usage.GetRequests() != 0 {
+		t.Errorf("GetUsage = %v, %v", usage, err)
+	}
+}
+
+func TestServerManagesProvidersAndPresets(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	cm, err := crypto.NewManager("k1", map[string][]byte{"k1": make([]byte, 32)})
+	if err != nil {
+		t.Fatalf("crypto manager: %v", err)
+	}
+	client := startServerWith(t, Config{Store: store, Crypto: cm, Token: "secret"})
+	call := withToken("secret")
+
+	if _, err := client.UpsertProvider(call, &pb.UpsertProviderRequest{ChatId: -100, Name: "bad name", Kind: "anthropic"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpsertProvider(bad name) = %v, want InvalidArgument", err)
+	}
+	if _, err := client.UpsertProvider(call, &pb.UpsertProviderRequest{ChatId: -100, Name: "x", Kind: "openai_compat"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpsertProvider without base_url = %v, want InvalidArgument", err)
+	}
+	if _, err := client.UpsertProvider(call, &pb.UpsertProviderRequest{ChatId: -100, Name: "claude", Kind: "anthropic", ApiKey: "sk-secret"}); err != nil {
+		t.Fatalf("UpsertProvider: %v", err)
+	}
+	saved, err := store.GetProviderByName(ctx, -100, "claude")
+	if err != nil {
+		t.Fatalf("get provider: %v", err)
+	}
+	if saved.BaseURL != anthropic_messages.DefaultBaseURL || saved.EncAPIKey == nil || strings.Contains(*saved.EncAPIKey, "sk-secret") {
+		t.Errorf("saved provider = %+v, want the default base URL and an encrypted key", saved)
+	}
+	if key, err := cm.UnmarshalEncryptedString(*saved.EncAPIKey); err != nil || key != "sk-secret" {
+		t.Errorf("decrypted key = %q, %v", key, err)
+	}
+
+	if _, err := client.UpsertPreset(call, &pb.UpsertPresetRequest{ChatId: -100, Name: "p", Provider: "missing", Model: "m"}); status.Code(err) != codes.NotFound {
+		t.Errorf("UpsertPreset(missing provider) = %v, want NotFound", err)
+	}
+	if _, err := client.UpsertPreset(call, &pb.UpsertPresetRequest{ChatId: -100, Name: "p", Provider: "claude", Model: "m", ParamsJson: "[]"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpsertPreset(params []) = %v, want InvalidArgument", err)
+	}
+	if _, err := client.UpsertPreset(call, &pb.UpsertPresetRequest{ChatId: -100, Name: "sonnet", Provider: "claude", Model: "claude-sonnet-4-5"}); err != nil {
+		t.Fatalf("UpsertPreset: %v", err)
+	}
+	presets, err := client.ListPresets(call, &pb.ListPresetsRequest{ChatId: -100})
+	if err != nil || len(presets.GetPresets()) != 1 || !presets.GetPresets()[0].GetIsDefault() || presets.GetPresets()[0].GetParamsJson() != defaultPresetParams {
+		t.Fatalf("ListPresets = %v, %v; want sonnet as the default with default params", presets, err)
+	}
+
+	if _, err := client.DeletePreset(call, &pb.DeletePresetRequest{ChatId: -100, Name: "sonnet"}); err != nil {
+		t.Fatalf("DeletePreset: %v", err)
+	}
+	if _, err := client.DeletePreset(call, &pb.DeletePresetRequest{ChatId: -100, Name: "sonnet"}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeletePreset twice = %v, want NotFound", err)
+	}
+	if _, err := client.DeleteProvider(call, &pb.DeleteProviderRequest{ChatId: -100, Name: "claude"}); err != nil {
+		t.Fatalf("DeleteProvider: %v", err)
+	}
+	if _, err := client.DeleteProvider(call, &pb.DeleteProviderRequest{ChatId: -100, Name: "claude"}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteProvider twice = %v, want NotFound", err)
+	}
+}
+
+func openTestStore(t *testing.T) *storage.Store {
+	t.Helper()
+	s, err := storage.Open(context.Background(), "sqlite", filepath.Join(t.TempDir(), "bot.db"), true, "")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
@@ -3,7 +3,9 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -44,6 +46,14 @@ func NewManager(currentKeyID string, keys map[string][]byte) (*Manager, error) {
 	return &Manager{currentKeyID: currentKeyID, keys: cp}, nil
 }
 
+// DeriveKey returns a 32-byte key for purpose, derived from the current
+// master key. It changes when the current key is rotated.
+func (m *Manager) DeriveKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, m.keys[m.currentKeyID])
+	mac.Write([]byte("hyprbot:" + purpose))
+	return mac.Sum(nil)
+}
+
 func (m *Manager) Encrypt(plaintext []byte) (Envelope, error) {
 	key := m.keys[m.currentKeyID]
 	block, err := aes.NewCipher(key)
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"encoding/base64"
 	"testing"
 )
@@ -70,6 +71,18 @@ func TestRotationDecryptOldEncryptNew(t *testing.T) {
 	}
 }
 
+func TestDeriveKey(t *testing.T) {
+	m1, _ := NewManager("k1", map[string][]byte{"k1": mustKey(t, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")})
+	m2, _ := NewManager("k2", map[string][]byte{"k2": mustKey(t, "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=")})
+	a := m1.DeriveKey("stats")
+	if len(a) != 32 || !bytes.Equal(a, m1.DeriveKey("stats")) {
+		t.Fatalf("DeriveKey must be a stable 32-byte key, got %x", a)
+	}
+	if bytes.Equal(a, m1.DeriveKey("events")) || bytes.Equal(a, m2.DeriveKey("stats")) {
+		t.Fatal("keys must differ per purpose and master key")
+	}
+}
+
 func mustKey(t *testing.T, b64 string) []byte {
 	t.Helper()
 	k, err := base64.StdEncoding.DecodeString(b64)
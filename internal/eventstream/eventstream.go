@@ -0,0 +1,214 @@
+// Package eventstream publishes anonymized bot events to a NATS subject or
+// an MQTT topic for analytics pipelines. Chats and users appear only as
+// keyed hashes. Events are queued in memory and sent by Run; while the
+// broker is slow or down they are dropped, so publishing never holds up a
+// job.
+package eventstream
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/metrics"
+)
+
+// Event types.
+const (
+	EventJobStarted    = "job.started"
+	EventJobFinished   = "job.finished"
+	EventJobError      = "job.error"
+	EventConfigChanged = "config.changed"
+)
+
+// Event is the JSON payload of a message.
+type Event struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+	// Chat and User are the first 16 hex digits of the HMAC-SHA256 of the
+	// ids under the hash key, stable while the key is.
+	Chat string         `json:"chat,omitempty"`
+	User string         `json:"user,omitempty"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+type Config struct {
+	// URL is nats://, tls:// (NATS over TLS), mqtt:// or mqtts://, with
+	// optional user:password; a NATS token goes in the user part.
+	URL string
+	// Subject is the NATS subject or MQTT topic prefix; the event type is
+	// appended as ".job.started" or "/job/started".
+	Subject  string
+	ClientID string
+	HashKey  []byte
+	// Buffer is how many events wait for the broker before new ones are
+	// dropped.
+	Buffer int
+	// Timeout bounds connecting and each write.
+	Timeout time.Duration
+	Metrics *metrics.Metrics
+	Logger  zerolog.Logger
+}
+
+// conn is a connection to the broker.
+type conn interface {
+	publish(topic string, payload []byte) error
+	close() error
+}
+
+// Publisher queues and sends events. A nil Publisher drops them.
+type Publisher struct {
+	cfg     Config
+	url     *url.URL
+	mqtt    bool
+	dial    func(ctx context.Context) (conn, error)
+	events  chan Event
+	metrics *metrics.Metrics
+	now     func() time.Time
+}
+
+const (
+	defaultNATSSubject = "hyprbot.events"
+	defaultMQTTTopic   = "hyprbot/events"
+	// maxRedial bounds the wait between connection attempts.
+	maxRedial = time.Minute
+)
+
+func New(cfg Config) (*Publisher, error) {
+	u, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid event stream url %q", cfg.URL)
+	}
+	if len(cfg.HashKey) == 0 {
+		return nil, fmt.Errorf("event stream needs a hash key")
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 1024
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "hyprbot"
+	}
+	m := cfg.Metrics
+	if m == nil {
+		m = metrics.Global()
+	}
+	p := &Publisher{cfg: cfg, url: u, events: make(chan Event, cfg.Buffer), metrics: m, now: time.Now}
+	switch u.Scheme {
+	case "nats", "tls":
+		if p.cfg.Subject == "" {
+			p.cfg.Subject = defaultNATSSubject
+		}
+		p.dial = func(ctx context.Context) (conn, error) { return dialNATS(ctx, u, p.cfg.ClientID, p.cfg.Timeout) }
+	case "mqtt", "mqtts":
+		p.mqtt = true
+		if p.cfg.Subject == "" {
+			p.cfg.Subject = defaultMQTTTopic
+		}
+		p.dial = func(ctx context.Context) (conn, error) { return dialMQTT(ctx, u, p.cfg.ClientID, p.cfg.Timeout) }
+	default:
+		return nil, fmt.Errorf("event stream url scheme must be nats, tls, mqtt or mqtts, not %q", u.Scheme)
+	}
+	return p, nil
+}
+
+// Publish queues an event about chatID and userID; zero ids are left out.
+// It never blocks: with the queue full the event is dropped.
+func (p *Publisher) Publish(typ string, chatID, userID int64, data map[string]any) {
+	if p == nil {
+		return
+	}
+	ev := Event{Type: typ, At: p.now().UTC(), Data: data}
+	if chatID != 0 {
+		ev.Chat = p.hash(chatID)
+	}
+	if userID != 0 {
+		ev.User = p.hash(userID)
+	}
+	select {
+	case p.events <- ev:
+	default:
+		p.metrics.EventStreamEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (p *Publisher) hash(id int64) string {
+	mac := hmac.New(sha256.New, p.cfg.HashKey)
+	mac.Write([]byte(strconv.FormatInt(id, 10)))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// topic is where events of typ go.
+func (p *Publisher) topic(typ string) string {
+	if p.mqtt {
+		return p.cfg.Subject + "/" + strings.ReplaceAll(typ, ".", "/")
+	}
+	return p.cfg.Subject + "." + typ
+}
+
+// Run sends queued events until ctx ends, reconnecting with a growing wait
+// after failures. Events queued while the broker is down wait in the
+// buffer.
+func (p *Publisher) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	log := p.cfg.Logger.With().Str("component", "event_stream").Str("host", p.url.Host).Logger()
+	var c conn
+	defer func() {
+		if c != nil {
+			_ = c.close()
+		}
+	}()
+	wait := time.Second
+	for {
+		if c == nil {
+			var err error
+			c, err = p.dial(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Warn().Err(err).Dur("retry_in", wait).Msg("failed to connect to the event stream broker")
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+				wait = min(2*wait, maxRedial)
+				continue
+			}
+			wait = time.Second
+			log.Info().Msg("connected to the event stream broker")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-p.events:
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				p.metrics.EventStreamEvents.WithLabelValues("failed").Inc()
+				continue
+			}
+			if err := c.publish(p.topic(ev.Type), payload); err != nil {
+				p.metrics.EventStreamEvents.WithLabelValues("failed").Inc()
+				log.Warn().Err(err).Msg("event stream publish failed, reconnecting")
+				_ = c.close()
+				c = nil
+				continue
+			}
+			p.metrics.EventStreamEvents.WithLabelValues("published").Inc()
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"hyprbot/internal/metrics"
+)
+
+func newTestPublisher(t *testing.T, url string) *Publisher {
+	t.Helper()
+	p, err := New(Config{URL: url, HashKey: []byte("key"), Buffer: 4, Timeout: 2 * time.Second, Metrics: metrics.Global()})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	return p
+}
+
+// listen starts a one-connection broker running serve.
+func listen(t *testing.T, serve func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+		serve(c)
+	}()
+	return ln.Addr().String()
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+func TestPublishNATS(t *testing.T) {
+	got := make(chan message, 1)
+	addr := listen(t, func(c net.Conn) {
+		r := bufio.NewReader(c)
+		fmt.Fprint(c, "INFO {\"server_id\":\"test\"}\r\n")
+		line, _ := r.ReadString('\n')
+		if !strings.HasPrefix(line, "CONNECT ") || !strings.Contains(line, `"auth_token":"secret"`) {
+			fmt.Fprint(c, "-ERR 'Authorization Violation'\r\n")
+			return
+		}
+		if line, _ = r.ReadString('\n'); strings.TrimSpace(line) != "PING" {
+			return
+		}
+		fmt.Fprint(c, "PONG\r\n")
+		line, _ = r.ReadString('\n')
+		var subject string
+		var n int
+		if _, err := fmt.Sscanf(line, "PUB %s %d", &subject, &n); err != nil {
+			return
+		}
+		payload := make([]byte, n+2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		got <- message{subject, payload[:n]}
+	})
+
+	p := newTestPublisher(t, "nats://secret@"+addr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.Publish(EventJobStarted, -100123, 42, map[string]any{"attempt": 1})
+
+	select {
+	case m := <-got:
+		if m.topic != "hyprbot.events.job.started" {
+			t.Fatalf("subject = %q", m.topic)
+		}
+		checkEvent(t, p, m.payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+	}
+}
+
+func TestPublishMQTT(t *testing.T) {
+	got := make(chan message, 1)
+	connect := make(chan []byte, 1)
+	addr := listen(t, func(c net.Conn) {
+		r := bufio.NewReader(c)
+		typ, body, err := readMQTTPacket(r)
+		if err != nil || typ != mqttConnect {
+			return
+		}
+		connect <- body
+		c.Write([]byte{mqttConnAck, 2, 0, 0})
+		typ, body, err = readMQTTPacket(r)
+		if err != nil || typ != mqttPublish {
+			return
+		}
+		n := int(body[0])<<8 | int(body[1])
+		got <- message{string(body[2 : 2+n]), body[2+n:]}
+	})
+
+	p := newTestPublisher(t, "mqtt://bot:pw@"+addr)
+	p.cfg.ClientID = "hyprbot-test"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.Publish(EventJobStarted, -100123, 42, map[string]any{"attempt": 1})
+
+	select {
+	case body := <-connect:
+		want := mqttConnectPacket("hyprbot-test", "bot", "pw", true, true)
+		if string(body) != string(want[2:]) {
+			t.Fatalf("connect = %x, want %x", body, want[2:])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connect")
+	}
+	select {
+	case m := <-got:
+		if m.topic != "hyprbot/events/job/started" {
+			t.Fatalf("topic = %q", m.topic)
+		}
+		checkEvent(t, p, m.payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+	}
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return typ, body, err
+}
+
+func checkEvent(t *testing.T, p *Publisher, payload []byte) {
+	t.Helper()
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		t.Fatalf("payload %s: %v", payload, err)
+	}
+	if ev.Type != EventJobStarted || ev.Chat != p.hash(-100123) || ev.User != p.hash(42) || ev.Data["attempt"] != float64(1) {
+		t.Fatalf("event = %+v", ev)
+	}
+	if strings.Contains(string(payload), "100123") {
+		t.Fatalf("payload leaks the chat id: %s", payload)
+	}
+}
+
+func TestPublishDropsWhenFull(t *testing.T) {
+	p := newTestPublisher(t, "nats://127.0.0.1:1")
+	for range 10 {
+		p.Publish(EventConfigChanged, 1, 2, nil)
+	}
+	if len(p.events) != 4 {
+		t.Fatalf("queued %d events, want 4", len(p.events))
+	}
+}
+
+func TestHash(t *testing.T) {
+	p := newTestPublisher(t, "mqtt://127.0.0.1")
+	h := p.hash(42)
+	if len(h) != 16 || h != p.hash(42) || h == p.hash(43) {
+		t.Fatalf("hash(42) = %q", h)
+	}
+	other, _ := New(Config{URL: "mqtt://127.0.0.1", HashKey: []byte("other")})
+	if other.hash(42) == h {
+		t.Fatal("hash does not depend on the key")
+	}
+}
+
+func TestNewRejectsBadURL(t *testing.T) {
+	for _, u := range []string{"", "http://broker", "nats://"} {
+		if _, err := New(Config{URL: u, HashKey: []byte("key")}); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+	if _, err := New(Config{URL: "nats://broker"}); err == nil {
+		t.Error("New without hash key succeeded")
+	}
+}
+
+func TestNilPublisher(t *testing.T) {
+	var p *Publisher
+	p.Publish(EventJobStarted, 1, 2, nil)
+	p.Run(context.Background())
+}
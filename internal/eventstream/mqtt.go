@@ -0,0 +1,206 @@
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types, in the high nibble of the first byte.
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPingReq    = 0xc0
+	mqttDisconnect = 0xe0
+)
+
+// mqttKeepAlive is announced in CONNECT; a PINGREQ goes out at half of it.
+const mqttKeepAlive = 60 * time.Second
+
+// mqttConn publishes at QoS 0 over MQTT 3.1.1.
+type mqttConn struct {
+	nc      net.Conn
+	timeout time.Duration
+	done    chan struct{}
+
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+func dialMQTT(ctx context.Context, u *url.URL, clientID string, timeout time.Duration) (*mqttConn, error) {
+	port := "1883"
+	if u.Scheme == "mqtts" {
+		port = "8883"
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	d := net.Dialer{Timeout: timeout}
+	nc, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "mqtts" {
+		tc := tls.Client(nc, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("mqtt tls handshake: %w", err)
+		}
+		nc = tc
+	}
+	_ = nc.SetDeadline(time.Now().Add(timeout))
+
+	var user, pass string
+	var hasUser, hasPass bool
+	if u.User != nil {
+		user, hasUser = u.User.Username(), true
+		pass, hasPass = u.User.Password()
+	}
+	if _, err := nc.Write(mqttConnectPacket(clientID, user, pass, hasUser, hasPass)); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("send mqtt connect: %w", err)
+	}
+	r := bufio.NewReader(nc)
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(r, ack); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("read mqtt connack: %w", err)
+	}
+	if ack[0] != mqttConnAck || ack[1] != 2 {
+		nc.Close()
+		return nil, fmt.Errorf("unexpected mqtt connack %x", ack)
+	}
+	if ack[3] != 0 {
+		nc.Close()
+		return nil, fmt.Errorf("mqtt connection refused, return code %d", ack[3])
+	}
+	_ = nc.SetDeadline(time.Time{})
+
+	c := &mqttConn{nc: nc, timeout: timeout, done: make(chan struct{}), w: bufio.NewWriter(nc)}
+	go c.read(r)
+	go c.keepAlive()
+	return c, nil
+}
+
+func mqttConnectPacket(clientID, user, pass string, hasUser, hasPass bool) []byte {
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	flags := byte(0x02) // clean session
+	if hasUser {
+		flags |= 0x80
+	}
+	if hasPass {
+		flags |= 0x40
+	}
+	body = append(body, 4, flags, byte(mqttKeepAlive/time.Second>>8), byte(mqttKeepAlive/time.Second))
+	body = appendMQTTString(body, clientID)
+	if hasUser {
+		body = appendMQTTString(body, user)
+	}
+	if hasPass {
+		body = appendMQTTString(body, pass)
+	}
+	return mqttPacket(mqttConnect, body)
+}
+
+func mqttPublishPacket(topic string, payload []byte) []byte {
+	body := appendMQTTString(nil, topic)
+	return mqttPacket(mqttPublish, append(body, payload...))
+}
+
+// mqttPacket prefixes body with the fixed header: the type and the
+// remaining length in base 128.
+func mqttPacket(typ byte, body []byte) []byte {
+	out := []byte{typ}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// read drains what the broker sends, PINGRESP only at QoS 0, and notes the
+// end of the connection.
+func (c *mqttConn) read(r *bufio.Reader) {
+	_, err := io.Copy(io.Discard, r)
+	if err == nil {
+		err = io.EOF
+	}
+	c.fail(fmt.Errorf("mqtt connection closed: %w", err))
+	close(c.done)
+}
+
+func (c *mqttConn) keepAlive() {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write([]byte{mqttPingReq, 0}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *mqttConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+}
+
+func (c *mqttConn) write(packet []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	_ = c.nc.SetWriteDeadline(time.Now().Add(c.timeout))
+	c.w.Write(packet)
+	if err := c.w.Flush(); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte) error {
+	return c.write(mqttPublishPacket(topic, payload))
+}
+
+func (c *mqttConn) close() error {
+	_ = c.write([]byte{mqttDisconnect, 0})
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("mqtt connection closed")
+	}
+	c.mu.Unlock()
+	return c.nc.Close()
+}
@@ -0,0 +1,171 @@
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"hyprbot/internal/buildinfo"
+)
+
+// natsConn speaks the publishing half of the NATS client protocol: INFO,
+// CONNECT, PUB and PING/PONG.
+type natsConn struct {
+	nc      net.Conn
+	timeout time.Duration
+
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+func dialNATS(ctx context.Context, u *url.URL, name string, timeout time.Duration) (*natsConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	d := net.Dialer{Timeout: timeout}
+	nc, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(nc)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("read nats info: %w", err)
+	}
+	op, body, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if !strings.EqualFold(op, "INFO") {
+		nc.Close()
+		return nil, fmt.Errorf("unexpected nats greeting %q", op)
+	}
+	var info natsInfo
+	_ = json.Unmarshal([]byte(body), &info)
+	if u.Scheme == "tls" || info.TLSRequired {
+		tc := tls.Client(nc, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("nats tls handshake: %w", err)
+		}
+		nc, r = tc, bufio.NewReader(tc)
+	}
+
+	connect := natsConnect{Name: name, Lang: "go", Version: buildinfo.Version, Protocol: 1}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect.User, connect.Pass = u.User.Username(), pass
+		} else {
+			connect.AuthToken = u.User.Username()
+		}
+	}
+	b, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(nc, "CONNECT %s\r\nPING\r\n", b); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("send nats connect: %w", err)
+	}
+	// The server answers the PING once CONNECT is accepted; an -ERR comes
+	// first otherwise.
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("read nats connect reply: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-ERR") {
+			nc.Close()
+			return nil, fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		if strings.EqualFold(line, "PONG") {
+			break
+		}
+	}
+	_ = nc.SetDeadline(time.Time{})
+
+	c := &natsConn{nc: nc, timeout: timeout, w: bufio.NewWriter(nc)}
+	go c.read(r)
+	return c, nil
+}
+
+// read answers the server's PINGs and notes errors and the end of the
+// connection for the next publish.
+func (c *natsConn) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			c.fail(fmt.Errorf("nats connection closed: %w", err))
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.EqualFold(line, "PING"):
+			c.mu.Lock()
+			_ = c.nc.SetWriteDeadline(time.Now().Add(c.timeout))
+			_, _ = c.w.WriteString("PONG\r\n")
+			_ = c.w.Flush()
+			c.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			c.fail(fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+	}
+}
+
+func (c *natsConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+}
+
+func (c *natsConn) publish(subject string, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	_ = c.nc.SetWriteDeadline(time.Now().Add(c.timeout))
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(payload))
+	c.w.Write(payload)
+	c.w.WriteString("\r\n")
+	if err := c.w.Flush(); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+func (c *natsConn) close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("nats connection closed")
+	}
+	c.mu.Unlock()
+	return c.nc.Close()
+}
@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// TLSMaterial is the per-provider TLS setup stored (encrypted) in the
+// provider config.
+type TLSMaterial struct {
+	CAPEM         string `json:"ca_pem,omitempty"`
+	ClientCertPEM string `json:"client_cert_pem,omitempty"`
+	ClientKeyPEM  string `json:"client_key_pem,omitempty"`
+	ServerName    string `json:"server_name,omitempty"`
+}
+
+func (m TLSMaterial) Empty() bool {
+	return strings.TrimSpace(m.CAPEM) == "" && strings.TrimSpace(m.ClientCertPEM) == "" &&
+		strings.TrimSpace(m.ClientKeyPEM) == "" && strings.TrimSpace(m.ServerName) == ""
+}
+
+func (m TLSMaterial) Validate() error {
+	_, err := m.apply(nil)
+	return err
+}
+
+func (m TLSMaterial) apply(base *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if strings.TrimSpace(m.CAPEM) != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(m.CAPEM)) {
+			return nil, fmt.Errorf("ca_pem contains no certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	hasCert, hasKey := strings.TrimSpace(m.ClientCertPEM) != "", strings.TrimSpace(m.ClientKeyPEM) != ""
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("client_cert_pem and client_key_pem must be set together")
+	}
+	if hasCert {
+		pair, err := tls.X509KeyPair([]byte(m.ClientCertPEM), []byte(m.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("parse client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	if strings.TrimSpace(m.ServerName) != "" {
+		cfg.ServerName = strings.TrimSpace(m.ServerName)
+	}
+	return cfg, nil
+}
+
+func (m TLSMaterial) fingerprint() string {
+	h := sha256.New()
+	for _, part := range []string{m.CAPEM, m.ClientCertPEM, m.ClientKeyPEM, m.ServerName} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const maxTLSClients = 256
+
+type tlsClientKey struct {
+	base        *http.Client
+	fingerprint string
+}
+
+var (
+	tlsClientsMu sync.Mutex
+	tlsClients   = map[tlsClientKey]*http.Client{}
+)
+
+// WithTLS returns a client that shares base's settings but uses m for TLS.
+// Clients are cached so repeated jobs against the same provider reuse pooled
+// connections instead of opening a transport per request.
+func WithTLS(base *http.Client, m TLSMaterial) (*http.Client, error) {
+	if m.Empty() {
+		return base, nil
+	}
+	if base == nil {
+		base = http.DefaultClient
+	}
+	key := tlsClientKey{base: base, fingerprint: m.fingerprint()}
+
+	tlsClientsMu.Lock()
+	defer tlsClientsMu.Unlock()
+	if c, ok := tlsClients[key]; ok {
+		return c, nil
+	}
+
+	baseTransport, ok := base.Transport.(*http.Transport)
+	if !ok || baseTransport == nil {
+		baseTransport = http.DefaultTransport.(*http.Transport)
+	}
+	t := baseTransport.Clone()
+	tlsCfg, err := m.apply(baseTransport.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsCfg
+
+	if len(tlsClients) >= maxTLSClients {
+		for k, c := range tlsClients {
+			c.CloseIdleConnections()
+			delete(tlsClients, k)
+		}
+	}
+	c := &http.Client{Timeout: base.Timeout, Transport: t, CheckRedirect: base.CheckRedirect, Jar: base.Jar}
+	tlsClients[key] = c
+	return c, nil
+}
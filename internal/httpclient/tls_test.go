@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithTLSTrustsProviderCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	base, err := New(Config{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := base.Get(srv.URL); err == nil {
+		t.Fatalf("expected base client to reject the test server certificate")
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	m := TLSMaterial{CAPEM: string(caPEM)}
+	c, err := WithTLS(base, m)
+	if err != nil {
+		t.Fatalf("with tls: %v", err)
+	}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with provider ca: %v", err)
+	}
+	resp.Body.Close()
+
+	again, err := WithTLS(base, m)
+	if err != nil {
+		t.Fatalf("with tls again: %v", err)
+	}
+	if again != c {
+		t.Fatalf("expected cached client for identical tls material")
+	}
+}
+
+func TestTLSMaterialValidate(t *testing.T) {
+	if err := (TLSMaterial{ClientCertPEM: "x"}).Validate(); err == nil {
+		t.Fatalf("expected error for cert without key")
+	}
+	if err := (TLSMaterial{CAPEM: "garbage"}).Validate(); err == nil {
+		t.Fatalf("expected error for invalid ca")
+	}
+	if err := (TLSMaterial{ServerName: "gateway.internal"}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Package httpmw holds the middleware wrapped around the bot's HTTP server.
+package httpmw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+)
+
+// MaxBody buffers request bodies of at most limit bytes and answers larger
+// ones with 413 before next runs, so an oversized or endless upload never
+// reaches the update decoder.
+func MaxBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Recover turns a panic in next into a logged stack trace and a 500, so
+// one bad request cannot take the handler down with it. http.ErrAbortHandler
+// is passed through as net/http expects.
+func Recover(log zerolog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.Error().
+				Interface("panic", rec).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Bytes("stack", debug.Stack()).
+				Msg("http handler panicked")
+			// Headers may already be out; then this is a no-op on the status.
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
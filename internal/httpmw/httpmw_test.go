@@ -0,0 +1,58 @@
+package httpmw
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestMaxBodyRejectsLargeBodies(t *testing.T) {
+	var got string
+	h := MaxBody(8, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345678")))
+	if rec.Code != http.StatusOK || got != "12345678" {
+		t.Fatalf("body at the limit: code %d, body %q", rec.Code, got)
+	}
+
+	got = ""
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456789")))
+	if rec.Code != http.StatusRequestEntityTooLarge || got != "" {
+		t.Fatalf("declared oversize body: code %d, body %q", rec.Code, got)
+	}
+
+	// Without a Content-Length the limit is enforced while reading.
+	req := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("12345"), strings.NewReader("6789")))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge || got != "" {
+		t.Fatalf("streamed oversize body: code %d, body %q", rec.Code, got)
+	}
+}
+
+func TestRecoverLogsPanicAsServerError(t *testing.T) {
+	var logs bytes.Buffer
+	h := Recover(zerolog.New(&logs), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("malformed update")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/telegram", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "malformed update") || !strings.Contains(logs.String(), "httpmw.TestRecoverLogsPanicAsServerError") {
+		t.Fatalf("log lacks panic or stack: %s", logs.String())
+	}
+}
@@ -0,0 +1,56 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"role": {"enum": ["admin", "user"]}
+	},
+	"required": ["name"],
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(personSchema))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	cases := []struct {
+		doc     string
+		wantErr string
+	}{
+		{`{"name":"ann","age":3,"tags":["a"],"role":"user"}`, ""},
+		{`{"age":3}`, `missing required property "name"`},
+		{`{"name":"ann","age":3.5}`, "$.age: expected integer"},
+		{`{"name":"ann","tags":[1]}`, "$.tags[0]: expected string"},
+		{`{"name":"ann","role":"root"}`, "not in enum"},
+		{`{"name":"ann","extra":true}`, `unexpected property "extra"`},
+		{`{"name":`, "invalid json"},
+	}
+	for _, tc := range cases {
+		err := s.Validate([]byte(tc.doc))
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.doc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.doc, tc.wantErr, err)
+		}
+	}
+}
+
+func TestParseRejectsUnknownType(t *testing.T) {
+	if _, err := Parse([]byte(`{"type":"object","properties":{"a":{"type":"str"}}}`)); err == nil {
+		t.Fatalf("expected error for unknown type")
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+// InstrumentRedis counts failed commands of rdb in m.RedisErrors and
+// exports its connection pool stats. Call it once per client.
+func InstrumentRedis(rdb *redis.Client, m *Metrics) {
+	rdb.AddHook(redisHook{m: m})
+	stat := func(name, help string, value func(*redis.PoolStats) uint32) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "hyprbot", Name: name, Help: help}, func() float64 {
+			return float64(value(rdb.PoolStats()))
+		})
+	}
+	register(
+		stat("redis_pool_total_conns", "Connections in the Redis pool", func(s *redis.PoolStats) uint32 { return s.TotalConns }),
+		stat("redis_pool_idle_conns", "Idle connections in the Redis pool", func(s *redis.PoolStats) uint32 { return s.IdleConns }),
+		stat("redis_pool_hits", "Times a free connection was found in the Redis pool", func(s *redis.PoolStats) uint32 { return s.Hits }),
+		stat("redis_pool_misses", "Times no free connection was found in the Redis pool", func(s *redis.PoolStats) uint32 { return s.Misses }),
+		stat("redis_pool_timeouts", "Times waiting for a Redis pool connection timed out", func(s *redis.PoolStats) uint32 { return s.Timeouts }),
+	)
+}
+
+// RegisterDBStats exports the sql.DBStats of db (open, in use and idle
+// connections, waits) labeled with name.
+func RegisterDBStats(db *sql.DB, name string) {
+	register(collectors.NewDBStatsCollector(db, name))
+}
+
+func register(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := prometheus.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				panic(err)
+			}
+		}
+	}
+}
+
+type redisHook struct {
+	m *Metrics
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			h.m.RedisErrors.WithLabelValues("dial").Inc()
+		}
+		return conn, err
+	}
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.observe(cmd)
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			h.observe(cmd)
+		}
+		return err
+	}
+}
+
+func (h redisHook) observe(cmd redis.Cmder) {
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled) {
+		h.m.RedisErrors.WithLabelValues(cmd.Name()).Inc()
+	}
+}
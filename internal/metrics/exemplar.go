@@ -0,0 +1,15 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ObserveWithTrace records v and, when the observer supports exemplars,
+// attaches traceID so a dashboard can jump from a bucket to the trace or
+// the logs of one request. Exemplars are only exposed in the OpenMetrics
+// format, which the /metrics handler negotiates.
+func ObserveWithTrace(o prometheus.Observer, v float64, traceID string) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	o.Observe(v)
+}
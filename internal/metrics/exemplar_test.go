@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveWithTraceAttachesExemplar(t *testing.T) {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Buckets: []float64{1, 10}})
+	ObserveWithTrace(h, 3, "abc123")
+	ObserveWithTrace(h, 0.5, "")
+
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	hist := m.GetHistogram()
+	if hist.GetSampleCount() != 2 {
+		t.Fatalf("expected 2 samples, got %d", hist.GetSampleCount())
+	}
+	if ex := hist.GetBucket()[0].GetExemplar(); ex != nil {
+		t.Fatalf("untraced observation got exemplar %v", ex)
+	}
+	ex := hist.GetBucket()[1].GetExemplar()
+	if ex == nil || ex.GetValue() != 3 || len(ex.GetLabel()) != 1 || ex.GetLabel()[0].GetValue() != "abc123" {
+		t.Fatalf("unexpected exemplar %v", ex)
+	}
+}
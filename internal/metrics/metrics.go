@@ -4,13 +4,56 @@ import (
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"hyprbot/internal/buildinfo"
 )
 
 type Metrics struct {
-	EnqueuedJobs  prometheus.Counter
-	ProcessedJobs prometheus.Counter
-	FailedJobs    prometheus.Counter
-	UpdatesTotal  prometheus.Counter
+	EnqueuedJobs    prometheus.Counter
+	ProcessedJobs   prometheus.Counter
+	FailedJobs      prometheus.Counter
+	UpdatesTotal    prometheus.Counter
+	QuarantinedJobs prometheus.Counter
+	TrimmedJobs     prometheus.Counter
+	// UpdateDedupe counts dedupe lookups by result: first, duplicate or
+	// error.
+	UpdateDedupe *prometheus.CounterVec
+
+	TelegramMessagesSent prometheus.Counter
+	TelegramSendFailures prometheus.Counter
+	// PollingFallback is 1 while webhook failover polls for updates.
+	PollingFallback prometheus.Gauge
+
+	// Commands counts handled commands by command, chat_type and outcome;
+	// CommandDuration times them by command.
+	Commands        *prometheus.CounterVec
+	CommandDuration *prometheus.HistogramVec
+
+	// JobDuration times one handling of a job by type and outcome;
+	// JobLatency is enqueue to final outcome, retries included. Both carry
+	// the job id as trace_id exemplar.
+	JobDuration *prometheus.HistogramVec
+	JobLatency  *prometheus.HistogramVec
+
+	ActiveConsumers prometheus.Gauge
+	QueueBacklog    prometheus.Gauge
+
+	// RedisErrors counts failed Redis commands by command; DBQueryErrors
+	// and DBQueryDuration cover store statements by operation.
+	RedisErrors     *prometheus.CounterVec
+	DBQueryErrors   *prometheus.CounterVec
+	DBQueryDuration *prometheus.HistogramVec
+
+	// PromptTrimmed counts requests shortened to fit the model's context
+	// window, by the part cut: knowledge or quoted.
+	PromptTrimmed *prometheus.CounterVec
+
+	// WebhookDeliveries counts outbound webhook attempts by outcome:
+	// delivered, retry or failed.
+	WebhookDeliveries *prometheus.CounterVec
+	// EventStreamEvents counts event stream messages by outcome:
+	// published, dropped or failed.
+	EventStreamEvents *prometheus.CounterVec
 }
 
 var (
@@ -41,8 +84,122 @@ func Global() *Metrics {
 				Name:      "telegram_updates_total",
 				Help:      "Total telegram updates received",
 			}),
+			UpdateDedupe: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_update_dedupe_total",
+				Help:      "Update dedupe lookups by result: first delivery, duplicate (dropped) or error (processed anyway)",
+			}, []string{"result"}),
+			QuarantinedJobs: prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "queue_quarantined_total",
+				Help:      "Total stream entries moved to the quarantine stream because they could not be decoded or routed",
+			}),
+			TrimmedJobs: prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "queue_trimmed_total",
+				Help:      "Total stream entries removed by the retention policy",
+			}),
+			TelegramMessagesSent: prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_messages_sent_total",
+				Help:      "Total text messages sent to telegram",
+			}),
+			TelegramSendFailures: prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_send_failures_total",
+				Help:      "Total text messages telegram refused after fallbacks",
+			}),
+			PollingFallback: prometheus.NewGauge(prometheus.GaugeOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_polling_fallback",
+				Help:      "1 while updates are polled because the webhook could not be registered or reached",
+			}),
+			Commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_commands_total",
+				Help:      "Total bot commands and button presses handled, by command, chat type and outcome",
+			}, []string{"command", "chat_type", "outcome"}),
+			CommandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "hyprbot",
+				Name:      "telegram_command_duration_seconds",
+				Help:      "Time spent handling a bot command or button press, by command",
+				Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			}, []string{"command"}),
+			JobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "hyprbot",
+				Name:      "job_duration_seconds",
+				Help:      "Time a worker spent on one attempt of a job, by job type and outcome (done, retry, failed)",
+				Buckets:   jobBuckets,
+			}, []string{"type", "outcome"}),
+			JobLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "hyprbot",
+				Name:      "job_latency_seconds",
+				Help:      "Time from enqueue to the final outcome of a job, queueing and retries included, by job type and outcome",
+				Buckets:   jobBuckets,
+			}, []string{"type", "outcome"}),
+			ActiveConsumers: prometheus.NewGauge(prometheus.GaugeOpts{
+				Namespace: "hyprbot",
+				Name:      "worker_active_consumers",
+				Help:      "Consumer goroutines currently running in this worker",
+			}),
+			QueueBacklog: prometheus.NewGauge(prometheus.GaugeOpts{
+				Namespace: "hyprbot",
+				Name:      "queue_backlog",
+				Help:      "Jobs in the redis stream not yet read by any worker",
+			}),
+			RedisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "redis_errors_total",
+				Help:      "Total Redis commands that failed, by command; missing keys are not errors",
+			}, []string{"command"}),
+			DBQueryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "db_errors_total",
+				Help:      "Total database statements that failed, by operation; empty results are not errors",
+			}, []string{"operation"}),
+			DBQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "hyprbot",
+				Name:      "db_query_duration_seconds",
+				Help:      "Time spent on database statements, by operation",
+				Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+			}, []string{"operation"}),
+			PromptTrimmed: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "prompt_trimmed_total",
+				Help:      "Total provider requests shortened to fit the model's context window, by the part cut (knowledge, quoted)",
+			}, []string{"part"}),
+			WebhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "webhook_deliveries_total",
+				Help:      "Total outbound webhook delivery attempts, by outcome (delivered, retry, failed)",
+			}, []string{"outcome"}),
+			EventStreamEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "hyprbot",
+				Name:      "event_stream_events_total",
+				Help:      "Total events for the NATS/MQTT event stream, by outcome (published, dropped, failed)",
+			}, []string{"outcome"}),
 		}
-		prometheus.MustRegister(global.EnqueuedJobs, global.ProcessedJobs, global.FailedJobs, global.UpdatesTotal)
+		prometheus.MustRegister(global.EnqueuedJobs, global.ProcessedJobs, global.FailedJobs, global.UpdatesTotal, global.UpdateDedupe,
+			global.QuarantinedJobs, global.TrimmedJobs, global.TelegramMessagesSent, global.TelegramSendFailures,
+			global.PollingFallback,
+			global.Commands, global.CommandDuration, global.JobDuration, global.JobLatency,
+			global.ActiveConsumers, global.QueueBacklog, global.RedisErrors, global.DBQueryErrors, global.DBQueryDuration,
+			global.PromptTrimmed, global.WebhookDeliveries, global.EventStreamEvents)
+		buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "hyprbot",
+			Name:      "build_info",
+			Help:      "Always 1; labels identify the running release",
+			ConstLabels: prometheus.Labels{
+				"version":    buildinfo.Version,
+				"commit":     buildinfo.Commit,
+				"go_version": buildinfo.GoVersion(),
+			},
+		})
+		buildInfo.Set(1)
+		prometheus.MustRegister(buildInfo)
 	})
 	return global
 }
+
+// jobBuckets span a cached answer to a slow model with tool calls.
+var jobBuckets = []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
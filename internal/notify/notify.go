@@ -0,0 +1,393 @@
+// Package notify sends bot events to the outbound webhooks the owner adds
+// with /owner_webhook_add. Emit records one delivery per matching webhook
+// in the database; Run posts due deliveries, signed with the webhook's
+// secret, and retries failures with exponential backoff. Deliveries are
+// claimed in the database, so every process may emit and run.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/metrics"
+	"hyprbot/internal/storage"
+)
+
+// Event types.
+const (
+	EventJobDone         = "job.done"
+	EventJobFailed       = "job.failed"
+	EventConfigChanged   = "config.changed"
+	EventBudgetThreshold = "budget.threshold"
+)
+
+// EventTypes lists every event type, for validating webhook filters.
+var EventTypes = []string{EventJobDone, EventJobFailed, EventConfigChanged, EventBudgetThreshold}
+
+// Headers of a delivery. The signature is "sha256=" and the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook's secret, see Sign.
+const (
+	HeaderEvent     = "X-Hyprbot-Event"
+	HeaderDelivery  = "X-Hyprbot-Delivery"
+	HeaderTimestamp = "X-Hyprbot-Timestamp"
+	HeaderSignature = "X-Hyprbot-Signature"
+)
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	At     time.Time `json:"at"`
+	ChatID int64     `json:"chat_id,omitempty"`
+	UserID int64     `json:"user_id,omitempty"`
+	// Text is a one line summary; Slack incoming webhooks show it as the
+	// message.
+	Text string         `json:"text"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+const (
+	// hookCacheTTL bounds how long a process keeps sending to a webhook
+	// another process deleted, or misses one it added.
+	hookCacheTTL = 30 * time.Second
+	// dueBatch is how many due deliveries are read at once.
+	dueBatch = 20
+	// maxErrorBody is how much of a failed response is kept in the log.
+	maxErrorBody = 200
+)
+
+type store interface {
+	ListWebhooks(ctx context.Context) ([]storage.Webhook, error)
+	QueueWebhookDeliveries(ctx context.Context, deliveries []storage.WebhookDelivery) error
+	DueWebhookDeliveries(ctx context.Context, limit int) ([]storage.WebhookDelivery, error)
+	ClaimWebhookDelivery(ctx context.Context, id int64, attempts int, lease time.Duration) (bool, error)
+	FinishWebhookDelivery(ctx context.Context, d storage.WebhookDelivery, retryIn time.Duration) error
+	PruneWebhookDeliveries(ctx context.Context, age time.Duration) (int64, error)
+}
+
+type secrets interface {
+	UnmarshalEncryptedString(raw string) (string, error)
+}
+
+type Config struct {
+	// Timeout bounds one delivery attempt.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed. Backoff is the wait after the first failure, doubled after
+	// each further one up to MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// Retention is how long finished deliveries stay in the log; zero keeps
+	// them.
+	Retention time.Duration
+	// PollInterval is how often Run looks for due deliveries; deliveries
+	// emitted in the same process are sent right away.
+	PollInterval time.Duration
+	HTTPClient   *http.Client
+	Metrics      *metrics.Metrics
+	Logger       zerolog.Logger
+}
+
+// Dispatcher emits events and delivers them. A nil Dispatcher drops events.
+type Dispatcher struct {
+	store   store
+	secrets secrets
+	cfg     Config
+	metrics *metrics.Metrics
+	now     func() time.Time
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	hooks   []storage.Webhook
+	hooksAt time.Time
+}
+
+func New(store *storage.Store, secrets *crypto.Manager, cfg Config) *Dispatcher {
+	return newDispatcher(store, secrets, cfg)
+}
+
+func newDispatcher(store store, secrets secrets, cfg Config) *Dispatcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = max(time.Hour, cfg.Backoff)
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 15 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	m := cfg.Metrics
+	if m == nil {
+		m = metrics.Global()
+	}
+	return &Dispatcher{store: store, secrets: secrets, cfg: cfg, metrics: m, now: time.Now, wake: make(chan struct{}, 1)}
+}
+
+// Emit queues ev for every webhook that receives its type. It fills ID and
+// At when unset. Failures are logged: an event never holds up the work it
+// reports.
+func (d *Dispatcher) Emit(ctx context.Context, ev Event) {
+	if d == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	hooks, err := d.webhooks(ctx)
+	if err != nil {
+		d.cfg.Logger.Warn().Err(err).Str("event", ev.Type).Msg("failed to load webhooks, event dropped")
+		return
+	}
+	var targets []storage.Webhook
+	for _, h := range hooks {
+		if Receives(h, ev.Type) {
+			targets = append(targets, h)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	if ev.ID == "" {
+		ev.ID = newEventID()
+	}
+	if ev.At.IsZero() {
+		ev.At = d.now().UTC()
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		d.cfg.Logger.Warn().Err(err).Str("event", ev.Type).Msg("failed to encode event")
+		return
+	}
+	deliveries := make([]storage.WebhookDelivery, 0, len(targets))
+	for _, h := range targets {
+		deliveries = append(deliveries, storage.WebhookDelivery{WebhookName: h.Name, EventID: ev.ID, EventType: ev.Type, Payload: string(payload)})
+	}
+	if err := d.store.QueueWebhookDeliveries(ctx, deliveries); err != nil {
+		d.cfg.Logger.Warn().Err(err).Str("event", ev.Type).Msg("failed to queue webhook deliveries")
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Receives reports whether h gets events of type typ.
+func Receives(h storage.Webhook, typ string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// Invalidate makes the next Emit reload the webhooks, after one was added
+// or deleted.
+func (d *Dispatcher) Invalidate() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.hooksAt = time.Time{}
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) webhooks(ctx context.Context) ([]storage.Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.hooksAt.IsZero() && d.now().Sub(d.hooksAt) < hookCacheTTL {
+		return d.hooks, nil
+	}
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.hooks, d.hooksAt = hooks, d.now()
+	return hooks, nil
+}
+
+// Run delivers due deliveries until ctx ends and prunes the log once an
+// hour.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+	for {
+		d.deliverDue(ctx)
+		if d.cfg.Retention > 0 && d.now().Sub(lastPrune) >= time.Hour {
+			lastPrune = d.now()
+			if n, err := d.store.PruneWebhookDeliveries(ctx, d.cfg.Retention); err != nil && ctx.Err() == nil {
+				d.cfg.Logger.Warn().Err(err).Msg("failed to prune webhook deliveries")
+			} else if n > 0 {
+				d.cfg.Logger.Info().Int64("pruned", n).Msg("pruned webhook delivery log")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.store.DueWebhookDeliveries(ctx, dueBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				d.cfg.Logger.Warn().Err(err).Msg("failed to load due webhook deliveries")
+			}
+			return
+		}
+		for _, del := range due {
+			d.deliver(ctx, del)
+		}
+		if len(due) < dueBatch {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at del unless another process claimed it.
+func (d *Dispatcher) deliver(ctx context.Context, del storage.WebhookDelivery) {
+	claimed, err := d.store.ClaimWebhookDelivery(ctx, del.ID, del.Attempts, 2*d.cfg.Timeout)
+	if err != nil || !claimed {
+		return
+	}
+	del.Attempts++
+	log := d.cfg.Logger.With().Str("webhook", del.WebhookName).Int64("delivery_id", del.ID).Str("event", del.EventType).Int("attempt", del.Attempts).Logger()
+
+	code, sendErr := d.send(ctx, del)
+	del.LastStatusCode, del.LastError = code, ""
+	var retryIn time.Duration
+	switch {
+	case sendErr == nil:
+		del.Status = storage.DeliveryDelivered
+	case del.Attempts >= d.cfg.MaxAttempts || errors.Is(sendErr, errWebhookGone):
+		del.Status, del.LastError = storage.DeliveryFailed, sendErr.Error()
+		log.Warn().Err(sendErr).Msg("webhook delivery failed, giving up")
+	default:
+		del.Status, del.LastError = storage.DeliveryPending, sendErr.Error()
+		retryIn = d.backoff(del.Attempts)
+		log.Info().Err(sendErr).Dur("retry_in", retryIn).Msg("webhook delivery failed, will retry")
+	}
+	outcome := del.Status
+	if outcome == storage.DeliveryPending {
+		outcome = "retry"
+	}
+	d.metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+	// Recorded even during shutdown, or the attempt would repeat after the
+	// lease.
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.FinishWebhookDelivery(finishCtx, del, retryIn); err != nil {
+		log.Error().Err(err).Msg("failed to record webhook delivery")
+	}
+}
+
+// backoff is the wait after the attempt-th failure.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempt && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.MaxBackoff)
+}
+
+// errWebhookGone fails deliveries of a webhook deleted since they were
+// queued.
+var errWebhookGone = errors.New("webhook no longer exists")
+
+func (d *Dispatcher) send(ctx context.Context, del storage.WebhookDelivery) (int, error) {
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("load webhook: %w", err)
+	}
+	i := slices.IndexFunc(hooks, func(h storage.Webhook) bool { return h.Name == del.WebhookName })
+	if i < 0 {
+		return 0, errWebhookGone
+	}
+	hook := hooks[i]
+	secret, err := d.secrets.UnmarshalEncryptedString(hook.EncSecret)
+	if err != nil {
+		return 0, fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	body := []byte(del.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hyprbot-webhook")
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, del.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, ts, body))
+	resp, err := d.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of body sent at ts.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random webhook secret.
+func NewSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func newEventID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("ev-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/metrics"
+	"hyprbot/internal/storage"
+)
+
+type fakeStore struct {
+	mu         sync.Mutex
+	hooks      []storage.Webhook
+	deliveries []storage.WebhookDelivery
+	// due lists the ids whose next attempt is due.
+	due map[int64]bool
+}
+
+func (f *fakeStore) ListWebhooks(context.Context) ([]storage.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]storage.Webhook(nil), f.hooks...), nil
+}
+
+func (f *fakeStore) QueueWebhookDeliveries(_ context.Context, ds []storage.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range ds {
+		d.ID = int64(len(f.deliveries) + 1)
+		d.Status = storage.DeliveryPending
+		f.deliveries = append(f.deliveries, d)
+		f.due[d.ID] = true
+	}
+	return nil
+}
+
+func (f *fakeStore) DueWebhookDeliveries(_ context.Context, limit int) ([]storage.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []storage.WebhookDelivery
+	for _, d := range f.deliveries {
+		if d.Status == storage.DeliveryPending && f.due[d.ID] && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) ClaimWebhookDelivery(_ context.Context, id int64, attempts int, _ time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := &f.deliveries[id-1]
+	if d.Attempts != attempts || d.Status != storage.DeliveryPending {
+		return false, nil
+	}
+	d.Attempts++
+	f.due[id] = false
+	return true, nil
+}
+
+func (f *fakeStore) FinishWebhookDelivery(_ context.Context, d storage.WebhookDelivery, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveries[d.ID-1] = d
+	return nil
+}
+
+func (f *fakeStore) PruneWebhookDeliveries(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+// retryAll makes every pending delivery due, as if its backoff passed.
+func (f *fakeStore) retryAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range f.deliveries {
+		f.due[d.ID] = d.Status == storage.DeliveryPending
+	}
+}
+
+type plainSecrets struct{}
+
+func (plainSecrets) UnmarshalEncryptedString(raw string) (string, error) { return raw, nil }
+
+func newTestDispatcher(store *fakeStore, maxAttempts int) *Dispatcher {
+	return newDispatcher(store, plainSecrets{}, Config{
+		MaxAttempts: maxAttempts,
+		Metrics:     metrics.Global(),
+		Logger:      zerolog.Nop(),
+	})
+}
+
+func TestEmitQueuesMatchingWebhooks(t *testing.T) {
+	store := &fakeStore{due: map[int64]bool{}, hooks: []storage.Webhook{
+		{Name: "all", URL: "http://a"},
+		{Name: "jobs", URL: "http://b", Events: []string{EventJobDone, EventJobFailed}},
+		{Name: "config", URL: "http://c", Events: []string{EventConfigChanged}},
+	}}
+	d := newTestDispatcher(store, 3)
+	d.Emit(context.Background(), Event{Type: EventJobDone, ChatID: -100, Text: "done"})
+
+	if len(store.deliveries) != 2 {
+		t.Fatalf("deliveries = %d, want 2", len(store.deliveries))
+	}
+	if store.deliveries[0].WebhookName != "all" || store.deliveries[1].WebhookName != "jobs" {
+		t.Fatalf("deliveries went to %s and %s", store.deliveries[0].WebhookName, store.deliveries[1].WebhookName)
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(store.deliveries[0].Payload), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID == "" || ev.At.IsZero() || ev.ChatID != -100 || ev.Text != "done" {
+		t.Fatalf("payload = %+v", ev)
+	}
+	if store.deliveries[1].EventID != ev.ID {
+		t.Fatalf("event ids differ: %s, %s", store.deliveries[1].EventID, ev.ID)
+	}
+}
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	var gotBody []byte
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		if calls == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{due: map[int64]bool{}, hooks: []storage.Webhook{{Name: "ops", URL: srv.URL, EncSecret: "s3cret"}}}
+	d := newTestDispatcher(store, 3)
+	d.Emit(context.Background(), Event{Type: EventConfigChanged, Text: "changed"})
+
+	d.deliverDue(context.Background())
+	if got := store.deliveries[0]; got.Status != storage.DeliveryPending || got.LastStatusCode != 503 || got.LastError == "" {
+		t.Fatalf("after first attempt: %+v", got)
+	}
+	store.retryAll()
+	d.deliverDue(context.Background())
+	got := store.deliveries[0]
+	if got.Status != storage.DeliveryDelivered || got.Attempts != 2 || got.LastError != "" {
+		t.Fatalf("after second attempt: %+v", got)
+	}
+
+	ts, err := strconv.ParseInt(gotHeader.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := Sign("s3cret", ts, gotBody); gotHeader.Get(HeaderSignature) != want {
+		t.Fatalf("signature = %q, want %q", gotHeader.Get(HeaderSignature), want)
+	}
+	if gotHeader.Get(HeaderEvent) != EventConfigChanged || gotHeader.Get(HeaderDelivery) != got.EventID {
+		t.Fatalf("headers = %v", gotHeader)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{due: map[int64]bool{}, hooks: []storage.Webhook{{Name: "ops", URL: srv.URL}}}
+	d := newTestDispatcher(store, 2)
+	d.Emit(context.Background(), Event{Type: EventJobFailed})
+	for range 3 {
+		d.deliverDue(context.Background())
+		store.retryAll()
+	}
+	if got := store.deliveries[0]; got.Status != storage.DeliveryFailed || got.Attempts != 2 {
+		t.Fatalf("delivery = %+v", got)
+	}
+}
+
+func TestDeliverDeletedWebhookFails(t *testing.T) {
+	store := &fakeStore{due: map[int64]bool{}, hooks: []storage.Webhook{{Name: "ops", URL: "http://127.0.0.1:1"}}}
+	d := newTestDispatcher(store, 5)
+	d.Emit(context.Background(), Event{Type: EventJobDone})
+	store.hooks = nil
+	d.deliverDue(context.Background())
+	if got := store.deliveries[0]; got.Status != storage.DeliveryFailed || got.Attempts != 1 {
+		t.Fatalf("delivery = %+v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := newDispatcher(&fakeStore{}, plainSecrets{}, Config{Backoff: time.Second, MaxBackoff: 5 * time.Second, Logger: zerolog.Nop()})
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Fatalf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestNilDispatcher(t *testing.T) {
+	var d *Dispatcher
+	d.Emit(context.Background(), Event{Type: EventJobDone})
+	d.Invalidate()
+	d.Run(context.Background())
+}
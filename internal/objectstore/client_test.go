@@ -0,0 +1,109 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientRoundTripAndJanitor(t *testing.T) {
+	fake := newFakeS3()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c, err := New(Config{Endpoint: srv.URL, Bucket: "b", AccessKey: "ak", SecretKey: "sk", PathStyle: true})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := c.Put(ctx, "artifacts/old.txt", []byte("old"), "text/plain"); err != nil {
+		t.Fatalf("put old: %v", err)
+	}
+	if err := c.Put(ctx, "artifacts/new.txt", []byte("new"), "text/plain"); err != nil {
+		t.Fatalf("put new: %v", err)
+	}
+	fake.setModified("b/artifacts/old.txt", time.Now().Add(-48*time.Hour))
+
+	got, err := c.Get(ctx, "artifacts/new.txt")
+	if err != nil || string(got) != "new" {
+		t.Fatalf("get: %q %v", got, err)
+	}
+
+	j := &Janitor{Client: c, Prefix: "artifacts/", TTL: 24 * time.Hour}
+	n, err := j.Sweep(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 expired object removed, got %d", n)
+	}
+	if _, err := c.Get(ctx, "artifacts/old.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected old artifact to be gone, got %v", err)
+	}
+}
+
+type fakeS3 struct {
+	mu       sync.Mutex
+	data     map[string][]byte
+	modified map[string]time.Time
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{data: map[string][]byte{}, modified: map[string]time.Time{}}
+}
+
+func (f *fakeS3) setModified(key string, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.modified[key] = at
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		f.data[key] = b
+		f.modified[key] = time.Now()
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		prefix := strings.TrimSuffix(key, "/") + "/" + r.URL.Query().Get("prefix")
+		keys := make([]string, 0)
+		for k := range f.data {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "<ListBucketResult>")
+		for _, k := range keys {
+			fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified></Contents>",
+				strings.SplitN(k, "/", 2)[1], len(f.data[k]), f.modified[k].UTC().Format(time.RFC3339))
+		}
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+	case r.Method == http.MethodGet:
+		b, ok := f.data[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(f.data, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
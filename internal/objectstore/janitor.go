@@ -0,0 +1,63 @@
+package objectstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Janitor deletes objects under Prefix once they are older than TTL. It stands
+// in for bucket lifecycle rules, which not every S3-compatible server supports.
+type Janitor struct {
+	Client   *Client
+	Prefix   string
+	TTL      time.Duration
+	Interval time.Duration
+	Logger   zerolog.Logger
+}
+
+func (j *Janitor) Run(ctx context.Context) error {
+	if j.Client == nil || j.TTL <= 0 {
+		return nil
+	}
+	interval := j.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			n, err := j.Sweep(ctx, time.Now())
+			if err != nil {
+				j.Logger.Error().Err(err).Str("prefix", j.Prefix).Msg("artifact cleanup failed")
+				continue
+			}
+			if n > 0 {
+				j.Logger.Info().Int("deleted", n).Str("prefix", j.Prefix).Msg("expired artifacts removed")
+			}
+		}
+	}
+}
+
+func (j *Janitor) Sweep(ctx context.Context, now time.Time) (int, error) {
+	objects, err := j.Client.List(ctx, j.Prefix)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, o := range objects {
+		if now.Sub(o.LastModified) < j.TTL {
+			continue
+		}
+		if err := j.Client.Delete(ctx, o.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,290 @@
+// Package plugin runs operator extensions as child processes. A plugin
+// reads one JSON request per line on stdin and writes one JSON response per
+// line on stdout; stderr is logged. Requests are
+//
+//	{"id":1,"method":"describe"}
+//	{"id":2,"method":"tool","params":{"name":"...","arguments":"{...}","chat_id":1,"user_id":2}}
+//	{"id":3,"method":"filter","params":{"chat_id":1,"user_id":2,"preset":"...","model":"...","text":"...","markdown":false}}
+//
+// and responses {"id":N,"result":{...}} or {"id":N,"error":"..."}. describe
+// answers {"tools":[{"name","description","parameters"}],"filter":true};
+// tool answers {"report","note"}; filter answers {"text"} to replace the
+// answer, {"refuse"} to withhold it or {} to keep it.
+//
+// The process is started on New and restarted on the next call after it
+// exits, answers garbage or misses the timeout. It does not inherit the
+// bot's environment, which holds the bot token and master keys: it gets
+// PATH and Config.Env only.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxLineBytes bounds one response line.
+const maxLineBytes = 4 << 20
+
+type Config struct {
+	Name    string
+	Command []string
+	// Env is the plugin's environment as KEY=VALUE, next to the bot's PATH.
+	Env []string
+	// Timeout bounds each call, the process start included.
+	Timeout time.Duration
+	// Order places the filter in the worker pipeline; 0 is the default.
+	Order int
+	// Default enables the plugin in chats that did not pick plugins.
+	Default bool
+	Logger  zerolog.Logger
+}
+
+// Tool is a tool the plugin answers; Parameters is a JSON Schema object.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// Info is what a plugin offers, from describe.
+type Info struct {
+	Tools  []Tool `json:"tools"`
+	Filter bool   `json:"filter"`
+}
+
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	ChatID    int64  `json:"chat_id"`
+	UserID    int64  `json:"user_id"`
+}
+
+// ToolResult is sent back to the model as Report; Note is shown under the
+// answer.
+type ToolResult struct {
+	Report string `json:"report"`
+	Note   string `json:"note"`
+}
+
+type FilterRequest struct {
+	ChatID   int64  `json:"chat_id"`
+	UserID   int64  `json:"user_id"`
+	Preset   string `json:"preset"`
+	Model    string `json:"model"`
+	Text     string `json:"text"`
+	Markdown bool   `json:"markdown"`
+}
+
+// FilterResult replaces the answer with Text when set, or withholds it and
+// sends Refuse when that is set.
+type FilterResult struct {
+	Text   *string `json:"text"`
+	Refuse string  `json:"refuse"`
+}
+
+type request struct {
+	ID     int64  `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// Plugin is one plugin process. Calls are serialized.
+type Plugin struct {
+	cfg  Config
+	info Info
+
+	mu     sync.Mutex
+	nextID int64
+	proc   *process
+}
+
+type process struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan response
+	// done is closed when stdout ends; err says why.
+	done chan struct{}
+	err  error
+}
+
+// New starts the plugin and asks what it offers.
+func New(ctx context.Context, cfg Config) (*Plugin, error) {
+	if cfg.Name == "" || len(cfg.Command) == 0 {
+		return nil, errors.New("plugin needs a name and a command")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	p := &Plugin{cfg: cfg}
+	if err := p.call(ctx, "describe", nil, &p.info); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: describe: %w", cfg.Name, err)
+	}
+	return p, nil
+}
+
+func (p *Plugin) Name() string  { return p.cfg.Name }
+func (p *Plugin) Info() Info    { return p.info }
+func (p *Plugin) Order() int    { return p.cfg.Order }
+func (p *Plugin) Default() bool { return p.cfg.Default }
+
+// CallTool answers a tool call of the model.
+func (p *Plugin) CallTool(ctx context.Context, call ToolCall) (ToolResult, error) {
+	var res ToolResult
+	err := p.call(ctx, "tool", call, &res)
+	return res, err
+}
+
+// Filter runs the plugin's filter on an answer.
+func (p *Plugin) Filter(ctx context.Context, req FilterRequest) (FilterResult, error) {
+	var res FilterResult
+	err := p.call(ctx, "filter", req, &res)
+	return res, err
+}
+
+// Close stops the process.
+func (p *Plugin) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+func (p *Plugin) call(ctx context.Context, method string, params, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc != nil {
+		select {
+		case <-p.proc.done:
+			// Exited since the last call.
+			p.stop()
+		default:
+		}
+	}
+	if p.proc == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+	p.nextID++
+	id := p.nextID
+	line, err := json.Marshal(request{ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if _, err := p.proc.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		return fmt.Errorf("write request: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// A late answer would be read as the next call's; start over.
+			p.stop()
+			return ctx.Err()
+		case <-p.proc.done:
+			err := p.proc.err
+			p.stop()
+			return fmt.Errorf("plugin exited: %w", err)
+		case resp := <-p.proc.responses:
+			if resp.ID != id {
+				continue
+			}
+			if resp.Error != "" {
+				return errors.New(resp.Error)
+			}
+			if out == nil || len(resp.Result) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(resp.Result, out); err != nil {
+				return fmt.Errorf("decode result: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+func (p *Plugin) start() error {
+	cmd := exec.Command(p.cfg.Command[0], p.cfg.Command[1:]...)
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, p.cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start plugin: %w", err)
+	}
+	proc := &process{cmd: cmd, stdin: stdin, responses: make(chan response), done: make(chan struct{})}
+	go proc.read(stdout)
+	go p.logStderr(stderr)
+	p.proc = proc
+	p.cfg.Logger.Info().Str("plugin", p.cfg.Name).Int("pid", cmd.Process.Pid).Msg("plugin started")
+	return nil
+}
+
+func (proc *process) read(stdout io.Reader) {
+	defer close(proc.done)
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64<<10), maxLineBytes)
+	for sc.Scan() {
+		var resp response
+		if err := json.Unmarshal(sc.Bytes(), &resp); err != nil {
+			proc.err = fmt.Errorf("invalid response line: %w", err)
+			return
+		}
+		select {
+		case proc.responses <- resp:
+		case <-time.After(time.Second):
+			// Nobody waits for it: the call timed out and stop is closing
+			// the process.
+		}
+	}
+	proc.err = sc.Err()
+	if proc.err == nil {
+		proc.err = io.EOF
+	}
+}
+
+func (p *Plugin) logStderr(stderr io.Reader) {
+	sc := bufio.NewScanner(stderr)
+	for sc.Scan() {
+		p.cfg.Logger.Info().Str("plugin", p.cfg.Name).Str("stderr", sc.Text()).Msg("plugin output")
+	}
+}
+
+// stop kills the process; the next call starts a new one.
+func (p *Plugin) stop() {
+	if p.proc == nil {
+		return
+	}
+	_ = p.proc.stdin.Close()
+	_ = p.proc.cmd.Process.Kill()
+	_ = p.proc.cmd.Wait()
+	p.proc = nil
+}
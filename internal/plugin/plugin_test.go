@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a plugin when started by a test.
+func TestMain(m *testing.M) {
+	if os.Getenv("HYPRBOT_TEST_PLUGIN") == "1" {
+		runTestPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin offers an "upper" tool and a filter that refuses answers
+// containing "secret"; a "sleep" tool never answers, "exit" exits and "env"
+// reports the variable named by its arguments.
+func runTestPlugin() {
+	sc := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	for sc.Scan() {
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.Unmarshal(sc.Bytes(), &req)
+		var result any
+		switch req.Method {
+		case "describe":
+			result = Info{Tools: []Tool{{Name: "upper", Parameters: json.RawMessage(`{"type":"object"}`)}, {Name: "sleep"}}, Filter: true}
+		case "tool":
+			var call ToolCall
+			_ = json.Unmarshal(req.Params, &call)
+			switch call.Name {
+			case "sleep":
+				time.Sleep(time.Minute)
+			case "exit":
+				os.Exit(0)
+			case "env":
+				_ = out.Encode(map[string]any{"id": req.ID, "result": ToolResult{Report: os.Getenv(call.Arguments)}})
+				continue
+			}
+			fmt.Fprintln(os.Stderr, "tool called")
+			result = ToolResult{Report: strings.ToUpper(call.Arguments), Note: "upper"}
+		case "filter":
+			var f FilterRequest
+			_ = json.Unmarshal(req.Params, &f)
+			if strings.Contains(f.Text, "secret") {
+				result = FilterResult{Refuse: "withheld"}
+			} else {
+				result = struct{}{}
+			}
+		default:
+			_ = out.Encode(map[string]any{"id": req.ID, "error": "unknown method"})
+			continue
+		}
+		_ = out.Encode(map[string]any{"id": req.ID, "result": result})
+	}
+}
+
+func startTestPlugin(t *testing.T, timeout time.Duration) *Plugin {
+	t.Helper()
+	p, err := New(context.Background(), Config{Name: "test", Command: []string{os.Args[0]}, Env: []string{"HYPRBOT_TEST_PLUGIN=1"}, Timeout: timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPluginToolsAndFilter(t *testing.T) {
+	p := startTestPlugin(t, 5*time.Second)
+	if info := p.Info(); len(info.Tools) != 2 || info.Tools[0].Name != "upper" || !info.Filter {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	res, err := p.CallTool(context.Background(), ToolCall{Name: "upper", Arguments: `{"q":"hi"}`})
+	if err != nil || res.Report != `{"Q":"HI"}` || res.Note != "upper" {
+		t.Fatalf("CallTool = %+v, %v", res, err)
+	}
+	f, err := p.Filter(context.Background(), FilterRequest{Text: "the secret is 42"})
+	if err != nil || f.Refuse != "withheld" || f.Text != nil {
+		t.Fatalf("Filter = %+v, %v", f, err)
+	}
+	f, err = p.Filter(context.Background(), FilterRequest{Text: "fine"})
+	if err != nil || f.Refuse != "" || f.Text != nil {
+		t.Fatalf("Filter = %+v, %v", f, err)
+	}
+}
+
+func TestPluginRestartsAfterTimeout(t *testing.T) {
+	p := startTestPlugin(t, 300*time.Millisecond)
+	if _, err := p.CallTool(context.Background(), ToolCall{Name: "sleep"}); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	res, err := p.CallTool(context.Background(), ToolCall{Name: "upper", Arguments: "ok"})
+	if err != nil || res.Report != "OK" {
+		t.Fatalf("CallTool after restart = %+v, %v", res, err)
+	}
+}
+
+func TestPluginRestartsAfterExit(t *testing.T) {
+	p := startTestPlugin(t, 5*time.Second)
+	if _, err := p.CallTool(context.Background(), ToolCall{Name: "exit"}); err == nil {
+		t.Fatal("expected the exiting call to fail")
+	}
+	res, err := p.CallTool(context.Background(), ToolCall{Name: "upper", Arguments: "ok"})
+	if err != nil || res.Report != "OK" {
+		t.Fatalf("CallTool after exit = %+v, %v", res, err)
+	}
+	// The process is gone by the time of this call, not mid-call.
+	p.mu.Lock()
+	_ = p.proc.cmd.Process.Kill()
+	<-p.proc.done
+	p.mu.Unlock()
+	res, err = p.CallTool(context.Background(), ToolCall{Name: "upper", Arguments: "again"})
+	if err != nil || res.Report != "AGAIN" {
+		t.Fatalf("CallTool after the process died = %+v, %v", res, err)
+	}
+}
+
+func TestPluginDoesNotInheritEnvironment(t *testing.T) {
+	t.Setenv("BOT_TOKEN", "123:secret")
+	p := startTestPlugin(t, 5*time.Second)
+	for name, want := range map[string]string{"BOT_TOKEN": "", "HYPRBOT_TEST_PLUGIN": "1", "PATH": os.Getenv("PATH")} {
+		res, err := p.CallTool(context.Background(), ToolCall{Name: "env", Arguments: name})
+		if err != nil || res.Report != want {
+			t.Errorf("plugin sees %s = %q, %v; want %q", name, res.Report, err, want)
+		}
+	}
+}
+
+func TestNewFailsForMissingCommand(t *testing.T) {
+	if _, err := New(context.Background(), Config{Name: "missing", Command: []string{"/nonexistent/plugin"}}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
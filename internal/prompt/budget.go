@@ -0,0 +1,177 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tokenizer counts the tokens a model makes of a text, see package
+// tokenizer. Counts are estimates used to stay inside the context window.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// modelFamily describes models whose name, without a "vendor/" prefix,
+// starts with Prefix. Families are matched in order, so more specific
+// prefixes come first.
+type modelFamily struct {
+	Prefix string
+	Window int
+}
+
+var modelFamilies = []modelFamily{
+	{Prefix: "gpt-4.1", Window: 1047576},
+	{Prefix: "gpt-4o", Window: 128000},
+	{Prefix: "gpt-4-turbo", Window: 128000},
+	{Prefix: "gpt-4", Window: 8192},
+	{Prefix: "gpt-3.5", Window: 16385},
+	{Prefix: "gpt-5", Window: 400000},
+	{Prefix: "o1", Window: 200000},
+	{Prefix: "o3", Window: 200000},
+	{Prefix: "o4", Window: 200000},
+	{Prefix: "claude", Window: 200000},
+	{Prefix: "gemini", Window: 1048576},
+	{Prefix: "mistral", Window: 32000},
+}
+
+// DefaultContextWindow is assumed for models of no known family; presets
+// of larger models set context_window.
+const DefaultContextWindow = 8192
+
+// DefaultOutputReserve is kept free for the answer when a preset sets no
+// max_tokens.
+const DefaultOutputReserve = 1024
+
+// messageOverhead covers the role and framing tokens providers add around
+// the system and user messages.
+const messageOverhead = 16
+
+func family(model string) (modelFamily, bool) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, f := range modelFamilies {
+		if strings.HasPrefix(name, f.Prefix) {
+			return f, true
+		}
+	}
+	return modelFamily{}, false
+}
+
+// ContextWindow returns the context window of a model in tokens.
+func ContextWindow(model string) int {
+	if f, ok := family(model); ok {
+		return f.Window
+	}
+	return DefaultContextWindow
+}
+
+// Request holds the parts of a provider request Fit may shorten.
+type Request struct {
+	// System is the preset, persona and language prompt; kept whole.
+	System string
+	// Knowledge is the chat knowledge, dropped from the end first.
+	Knowledge []string
+	// QuotedAuthor and QuotedText are the message the question replies to;
+	// the text is cut after the knowledge is gone.
+	QuotedAuthor string
+	QuotedText   string
+	// Text is the question itself; kept whole.
+	Text string
+}
+
+// SystemPrompt renders the system message.
+func (r Request) SystemPrompt() string {
+	return WithKnowledge(r.System, r.Knowledge)
+}
+
+// UserPrompt renders the user message.
+func (r Request) UserPrompt() string {
+	return User(r.Text, r.QuotedAuthor, r.QuotedText)
+}
+
+// Budget is the room a request has: the model's context window minus
+// Reserve tokens kept for the answer.
+type Budget struct {
+	Window    int
+	Reserve   int
+	Tokenizer Tokenizer
+}
+
+func (b Budget) limit() int {
+	return b.Window - b.Reserve - messageOverhead
+}
+
+func (b Budget) tokens(r Request) int {
+	return b.Tokenizer.Count(r.SystemPrompt()) + b.Tokenizer.Count(r.UserPrompt())
+}
+
+// Trimmed reports what Fit left out.
+type Trimmed struct {
+	// Knowledge is the number of knowledge entries dropped.
+	Knowledge int
+	// Quoted is set when the quoted message was cut or dropped.
+	Quoted bool
+}
+
+// Any reports whether anything was left out.
+func (t Trimmed) Any() bool {
+	return t.Knowledge > 0 || t.Quoted
+}
+
+// TooLargeError is returned by Fit when the system prompt and the question
+// alone do not fit, so nothing can be trimmed to make room.
+type TooLargeError struct {
+	Tokens int
+	Limit  int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("prompt needs about %d tokens, the model accepts %d", e.Tokens, e.Limit)
+}
+
+// Fit shortens r until its estimated size fits b: chat knowledge goes
+// first, then the quoted message is cut from its end. The system prompt and
+// the question are never changed; a *TooLargeError reports when they alone
+// are over the limit.
+func Fit(r Request, b Budget) (Request, Trimmed, error) {
+	var trimmed Trimmed
+	limit := b.limit()
+	if b.tokens(r) <= limit {
+		return r, trimmed, nil
+	}
+	bare := Request{System: r.System, Text: r.Text}
+	if n := b.tokens(bare); n > limit {
+		return r, trimmed, &TooLargeError{Tokens: n, Limit: max(limit, 0)}
+	}
+
+	r.Knowledge = append([]string(nil), r.Knowledge...)
+	for len(r.Knowledge) > 0 && b.tokens(r) > limit {
+		r.Knowledge = r.Knowledge[:len(r.Knowledge)-1]
+		trimmed.Knowledge++
+	}
+	if b.tokens(r) <= limit {
+		return r, trimmed, nil
+	}
+
+	// Find the longest prefix of the quoted text that still fits.
+	quoted := []rune(r.QuotedText)
+	lo, hi := 0, len(quoted)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		r.QuotedText = string(quoted[:mid]) + "…"
+		if b.tokens(r) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	trimmed.Quoted = true
+	if lo == 0 {
+		r.QuotedAuthor, r.QuotedText = "", ""
+	} else {
+		r.QuotedText = string(quoted[:lo]) + "…"
+	}
+	return r, trimmed, nil
+}
@@ -0,0 +1,33 @@
+package prompt
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxKnowledgeRunes bounds the chat knowledge added to a system prompt;
+// entries beyond it are left out.
+const maxKnowledgeRunes = 6000
+
+// WithKnowledge appends the chat's pinned messages and description to a
+// system prompt as reference material, in the order given.
+func WithKnowledge(system string, entries []string) string {
+	var kept []string
+	budget := maxKnowledgeRunes
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		n := utf8.RuneCountInString(e)
+		if e == "" || n > budget {
+			continue
+		}
+		kept = append(kept, e)
+		budget -= n
+	}
+	if len(kept) == 0 {
+		return system
+	}
+	block := "Reference information from this chat's pinned messages and description. " +
+		"Use it to answer questions about the chat, such as its rules; do not mention it otherwise.\n---\n" +
+		strings.Join(kept, "\n---\n")
+	return strings.TrimSpace(system + "\n\n" + block)
+}
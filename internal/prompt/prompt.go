@@ -0,0 +1,32 @@
+// Package prompt assembles the messages sent to providers, so the worker and
+// /preview render them the same way.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"hyprbot/internal/storage"
+)
+
+// System returns the system prompt for a preset in a chat: the chat persona,
+// the preset's own prompt and the reply language instruction.
+func System(presetPrompt string, settings storage.ChatSettings) string {
+	system := presetPrompt
+	if persona := strings.TrimSpace(settings.Get(storage.SettingPersona)); persona != "" {
+		system = strings.TrimSpace(persona + "\n\n" + system)
+	}
+	if lang := settings.Get(storage.SettingReplyLanguage); lang != "" {
+		system = strings.TrimSpace(system + "\n\nAlways answer in " + lang + ".")
+	}
+	return system
+}
+
+// User returns the user message. When the question replies to another
+// message, that message and its author are included as context.
+func User(text, quotedAuthor, quotedText string) string {
+	if strings.TrimSpace(quotedText) == "" {
+		return text
+	}
+	return fmt.Sprintf("Context: a message from %s that the user is replying to:\n\"\"\"\n%s\n\"\"\"\n\n%s", quotedAuthor, quotedText, text)
+}
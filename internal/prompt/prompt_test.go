@@ -0,0 +1,153 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tokenizer"
+)
+
+func TestSystem(t *testing.T) {
+	if got := System("Be brief.", storage.ChatSettings{}); got != "Be brief." {
+		t.Fatalf("unexpected default system prompt %q", got)
+	}
+	got := System("Be brief.", storage.ChatSettings{
+		storage.SettingPersona:       "You are Hypr.",
+		storage.SettingReplyLanguage: "German",
+	})
+	if got != "You are Hypr.\n\nBe brief.\n\nAlways answer in German." {
+		t.Fatalf("unexpected system prompt %q", got)
+	}
+}
+
+func TestUser(t *testing.T) {
+	if got := User("hi", "", ""); got != "hi" {
+		t.Fatalf("unexpected user prompt %q", got)
+	}
+	got := User("is this right?", "Ann (@ann)", "2+2=5")
+	if !strings.Contains(got, "Ann (@ann)") || !strings.Contains(got, "2+2=5") || !strings.HasSuffix(got, "is this right?") {
+		t.Fatalf("unexpected user prompt %q", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		text, quoted, want string
+	}{
+		{"what's the weather like on Mars?", "", ClassChat},
+		{"why does this panic?", "func main() {\n\tvar m map[string]int\n\tm[\"a\"] = 1\n}", ClassCode},
+		{"write a regex for emails", "", ClassCode},
+		{"write an essay about tea", "", ClassLong},
+		{"tl;dr please", strings.Repeat("words ", 300), ClassLong},
+	}
+	for _, c := range cases {
+		if got := Classify(c.text, c.quoted); got != c.want {
+			t.Errorf("Classify(%q) = %s, want %s", c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	if got, ok := ParseClass("Code."); !ok || got != ClassCode {
+		t.Fatalf("unexpected class %q %v", got, ok)
+	}
+	if got, ok := ParseClass("The answer: long"); !ok || got != ClassLong {
+		t.Fatalf("unexpected class %q %v", got, ok)
+	}
+	if _, ok := ParseClass("unsure"); ok {
+		t.Fatal("expected no class")
+	}
+}
+
+func TestRender(t *testing.T) {
+	body := "Review this code focusing on {{focus}}: {{ input }}"
+	if got := TemplateVars(body + " {{focus}}"); len(got) != 2 || got[0] != "focus" || got[1] != "input" {
+		t.Fatalf("unexpected vars %v", got)
+	}
+	got := Render(body, map[string]string{"focus": "security", "input": "x := 1"})
+	if got != "Review this code focusing on security: x := 1" {
+		t.Fatalf("unexpected render %q", got)
+	}
+	if got := Render("Summarize {{topic}}.", map[string]string{"input": "text"}); got != "Summarize .\n\ntext" {
+		t.Fatalf("unexpected render without input var %q", got)
+	}
+}
+
+func TestWithKnowledge(t *testing.T) {
+	if got := WithKnowledge("Be brief.", nil); got != "Be brief." {
+		t.Fatalf("unexpected prompt without knowledge %q", got)
+	}
+	got := WithKnowledge("Be brief.", []string{"Rules: be nice", strings.Repeat("x", maxKnowledgeRunes), "wifi: hunter2"})
+	if !strings.HasPrefix(got, "Be brief.\n\n") || !strings.Contains(got, "Rules: be nice\n---\nwifi: hunter2") || strings.Contains(got, "xxx") {
+		t.Fatalf("unexpected prompt %q", got)
+	}
+}
+
+func TestParseTranslation(t *testing.T) {
+	source, text := ParseTranslation("[German]\nHello,\n\n- *world*")
+	if source != "German" || text != "Hello,\n\n- *world*" {
+		t.Fatalf("unexpected translation %q %q", source, text)
+	}
+	if source, text := ParseTranslation("Hello"); source != "" || text != "Hello" {
+		t.Fatalf("unexpected translation without header %q %q", source, text)
+	}
+	if LanguageName("DE") != "German" || LanguageName("Latin") != "Latin" {
+		t.Fatal("unexpected language names")
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	if got := ContextWindow("openai/gpt-4o-mini"); got != 128000 {
+		t.Fatalf("ContextWindow(gpt-4o-mini) = %d", got)
+	}
+	if got := ContextWindow("gpt-4-0613"); got != 8192 {
+		t.Fatalf("ContextWindow(gpt-4) = %d", got)
+	}
+	if got := ContextWindow("my-local-model"); got != DefaultContextWindow {
+		t.Fatalf("ContextWindow(unknown) = %d", got)
+	}
+}
+
+func TestFitDropsKnowledgeThenCutsQuote(t *testing.T) {
+	r := Request{
+		System:       "Be brief.",
+		Knowledge:    []string{strings.Repeat("rule ", 40), strings.Repeat("pin ", 400)},
+		QuotedAuthor: "Ann",
+		QuotedText:   strings.Repeat("long quote ", 300),
+		Text:         "what does this mean?",
+	}
+	b := Budget{Window: 1000, Reserve: 400, Tokenizer: tokenizer.Heuristic{CharsPerToken: 4}}
+
+	fitted, trimmed, err := Fit(r, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trimmed.Knowledge != 2 || !trimmed.Quoted || len(fitted.Knowledge) != 0 {
+		t.Fatalf("unexpected trim %+v", trimmed)
+	}
+	if !strings.HasSuffix(fitted.QuotedText, "…") || !strings.HasSuffix(fitted.UserPrompt(), "what does this mean?") {
+		t.Fatalf("unexpected user prompt %q", fitted.UserPrompt())
+	}
+	if n := b.tokens(fitted); n > b.limit() {
+		t.Fatalf("fitted request has %d tokens, limit %d", n, b.limit())
+	}
+	if len(r.Knowledge) != 2 {
+		t.Fatal("Fit modified the caller's knowledge")
+	}
+
+	small := Request{System: "Be brief.", Text: "hi"}
+	if got, trimmed, err := Fit(small, b); err != nil || trimmed.Any() || got.UserPrompt() != "hi" {
+		t.Fatalf("Fit(small) = %+v, %+v, %v", got, trimmed, err)
+	}
+}
+
+func TestFitRejectsOversizedQuestion(t *testing.T) {
+	r := Request{System: "Be brief.", Knowledge: []string{"rules"}, Text: strings.Repeat("word ", 1000)}
+	_, _, err := Fit(r, Budget{Window: 1000, Reserve: 400, Tokenizer: tokenizer.Heuristic{CharsPerToken: 4}})
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 584 || tooLarge.Tokens <= tooLarge.Limit {
+		t.Fatalf("Fit error = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package prompt
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Classes a router preset dispatches prompts by.
+const (
+	ClassCode = "code"
+	ClassChat = "chat"
+	ClassLong = "long"
+)
+
+// ClassifierSystem is the system prompt of the classification call a router
+// preset makes in model mode.
+const ClassifierSystem = "Classify the user's message for routing. Reply with exactly one word:\n" +
+	"code - writing, reviewing or debugging code, shell commands, SQL or regular expressions\n" +
+	"long - essays, articles, stories, detailed explanations or summaries of long texts\n" +
+	"chat - anything else"
+
+// longPromptRunes is the prompt length, quoted context included, from which
+// Classify treats a prompt as long-form.
+const longPromptRunes = 1500
+
+var (
+	codeSyntax   = regexp.MustCompile("```|(?m)^\\s*(func|def|class|import|package|#include|SELECT|public|fn|const|let|var)\\s|=>|:=|\\);\\s*$|Traceback \\(most recent call last\\)|\\bat [\\w.$]+\\(\\w+\\.\\w+:\\d+\\)")
+	codeWords    = regexp.MustCompile(`(?i)\b(code|function|method|bug|stack ?trace|compile[rd]?|regex|regexp|sql|query|script|bash|python|golang|javascript|typescript|rust|java|api|json|yaml|dockerfile|refactor|exception)\b`)
+	longFormWord = regexp.MustCompile(`(?i)\b(essay|article|story|blog post|report|in detail|detailed|step by step|summari[sz]e|outline|long)\b`)
+)
+
+// Classify picks a class from the prompt text alone: code when it contains
+// code or talks about programming, long for long prompts or long-form
+// requests, chat otherwise.
+func Classify(text, quotedText string) string {
+	all := text + "\n" + quotedText
+	if codeSyntax.MatchString(all) || codeWords.MatchString(text) {
+		return ClassCode
+	}
+	if utf8.RuneCountInString(all) >= longPromptRunes || longFormWord.MatchString(text) {
+		return ClassLong
+	}
+	return ClassChat
+}
+
+// ParseClass reads the class from a classifier reply, tolerating case,
+// punctuation and extra words.
+func ParseClass(reply string) (string, bool) {
+	for _, word := range strings.Fields(strings.ToLower(reply)) {
+		word = strings.Trim(word, ".,:;!\"'`*")
+		switch word {
+		case ClassCode, ClassChat, ClassLong:
+			return word, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+package prompt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TemplateInput is the template variable holding the free text after the
+// variables of /tpl.
+const TemplateInput = "input"
+
+var templateVar = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// TemplateVars returns the variables body uses, in order of first use.
+func TemplateVars(body string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range templateVar.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+// Render fills the variables of body from vars; missing ones become empty.
+// When body has no {{input}}, a non-empty input is appended after it.
+func Render(body string, vars map[string]string) string {
+	usesInput := false
+	out := templateVar.ReplaceAllStringFunc(body, func(m string) string {
+		name := templateVar.FindStringSubmatch(m)[1]
+		if name == TemplateInput {
+			usesInput = true
+		}
+		return vars[name]
+	})
+	if input := strings.TrimSpace(vars[TemplateInput]); !usesInput && input != "" {
+		out = strings.TrimSpace(out) + "\n\n" + input
+	}
+	return out
+}
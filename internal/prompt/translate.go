@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Language is a translation target offered by /tr and its answer buttons.
+type Language struct {
+	Code string
+	Name string
+}
+
+// TranslateLanguages are the targets offered as buttons under translations.
+var TranslateLanguages = []Language{
+	{"en", "English"},
+	{"ru", "Russian"},
+	{"uk", "Ukrainian"},
+	{"de", "German"},
+	{"fr", "French"},
+	{"es", "Spanish"},
+	{"zh", "Chinese"},
+}
+
+// LanguageName resolves a language code of TranslateLanguages to its name;
+// anything else is taken as a language name.
+func LanguageName(s string) string {
+	for _, l := range TranslateLanguages {
+		if strings.EqualFold(l.Code, s) || strings.EqualFold(l.Name, s) {
+			return l.Name
+		}
+	}
+	return s
+}
+
+// TranslateSystem is the system prompt of /tr jobs. The reply starts with
+// the detected source language in brackets, see ParseTranslation.
+func TranslateSystem(target string) string {
+	return "You are a translator. Detect the language of the user's text and translate it into " + target + ". " +
+		"If the text is already in " + target + ", translate it into English instead, or keep it when " + target + " is English. " +
+		"Preserve the meaning, tone, line breaks, lists, Markdown, emoji, URLs, @mentions, names and code blocks exactly; " +
+		"translate only human language. Do not answer or comment on the text.\n" +
+		"Start the reply with the source language name in square brackets on its own line, e.g. [German], then the translation."
+}
+
+var translationHeader = regexp.MustCompile(`^\s*\[([^\]\n]{1,40})\]\s*\n?`)
+
+// ParseTranslation splits a /tr reply into the detected source language and
+// the translation. source is empty when the model left out the header.
+func ParseTranslation(reply string) (source, text string) {
+	m := translationHeader.FindStringSubmatch(reply)
+	if m == nil {
+		return "", strings.TrimSpace(reply)
+	}
+	return strings.TrimSpace(m[1]), strings.TrimSpace(reply[len(m[0]):])
+}
@@ -0,0 +1,306 @@
+package anthropic_messages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"hyprbot/internal/providers"
+)
+
+const (
+	DefaultBaseURL = "https://api.anthropic.com"
+	APIVersion     = "2023-06-01"
+	// defaultMaxTokens is used when the preset does not set max_tokens; the
+	// Messages API requires it.
+	defaultMaxTokens = 1024
+)
+
+type Config struct {
+	BaseURL       string
+	APIKey        string
+	Headers       map[string]string
+	HTTPClient    *http.Client
+	MaxRetries    int
+	BackoffBase   time.Duration
+	MaxRetryAfter time.Duration
+	// Zero means providers.DefaultMaxBodyBytes.
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
+}
+
+type Client struct {
+	cfg Config
+}
+
+func New(cfg Config) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if strings.TrimSpace(cfg.BaseURL) == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = 400 * time.Millisecond
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = 30 * time.Second
+	}
+	if cfg.MaxRequestBytes <= 0 {
+		cfg.MaxRequestBytes = providers.DefaultMaxBodyBytes
+	}
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = providers.DefaultMaxBodyBytes
+	}
+	return &Client{cfg: cfg}
+}
+
+var (
+	_ providers.Provider    = (*Client)(nil)
+	_ providers.ModelLister = (*Client)(nil)
+)
+
+func (c *Client) Chat(ctx context.Context, req providers.ChatRequest) (providers.ChatResponse, error) {
+	body, err := buildPayload(req)
+	if err != nil {
+		return providers.ChatResponse{}, err
+	}
+	if err := providers.CheckRequestSize(body, c.cfg.MaxRequestBytes); err != nil {
+		return providers.ChatResponse{}, err
+	}
+	endpointURL, err := c.apiURL("/v1/messages")
+	if err != nil {
+		return providers.ChatResponse{}, err
+	}
+
+	var lastErr error
+	trace := providers.ChatResponse{Endpoint: endpointURL}
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		trace.Attempts++
+		resp, retry, err := c.callOnce(ctx, endpointURL, body)
+		if err == nil {
+			resp.Endpoint, resp.Attempts = trace.Endpoint, trace.Attempts
+			return resp, nil
+		}
+		lastErr = err
+		if !retry || attempt == c.cfg.MaxRetries {
+			break
+		}
+		backoff, ok := providers.RetryDelay(err, c.cfg.BackoffBase*(1<<attempt), c.cfg.MaxRetryAfter)
+		if !ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return trace, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	return trace, lastErr
+}
+
+// buildPayload maps a ChatRequest onto the Messages API. With extended
+// thinking enabled max_tokens must exceed the budget and temperature must be
+// left at its default, so both are adjusted here rather than rejected.
+func buildPayload(req providers.ChatRequest) ([]byte, error) {
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	payload := map[string]any{
+		"model": req.Model,
+		"messages": []map[string]string{
+			{"role": "user", "content": req.UserPrompt},
+		},
+	}
+	system := req.SystemPrompt
+	if req.WantsJSON() {
+		// The Messages API has no JSON mode; instruct the model instead and
+		// let the worker validate the answer.
+		system = strings.TrimSpace(system + "\n\nRespond with a single JSON value only, without prose or code fences.")
+		if len(req.JSONSchema) > 0 {
+			system += " It must match this JSON Schema: " + string(req.JSONSchema)
+		}
+	}
+	if strings.TrimSpace(system) != "" {
+		payload["system"] = system
+	}
+	if req.ThinkingBudget > 0 {
+		if maxTokens <= req.ThinkingBudget {
+			maxTokens += req.ThinkingBudget
+		}
+		payload["thinking"] = map[string]any{"type": "enabled", "budget_tokens": req.ThinkingBudget}
+	} else if req.Temperature > 0 {
+		payload["temperature"] = req.Temperature
+	}
+	payload["max_tokens"] = maxTokens
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal messages payload: %w", err)
+	}
+	return b, nil
+}
+
+func (c *Client) callOnce(ctx context.Context, endpointURL string, body []byte) (out providers.ChatResponse, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return providers.ChatResponse{}, false, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.applyAuth(req)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return providers.ChatResponse{}, true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := providers.ReadResponse(resp.Body, c.cfg.MaxResponseBytes)
+	if err != nil {
+		return providers.ChatResponse{}, false, fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if wait := providers.RetryAfter(resp.Header, time.Now()); wait > 0 || resp.StatusCode == http.StatusTooManyRequests {
+			return providers.ChatResponse{}, true, &providers.RateLimitError{Status: resp.StatusCode, RetryAfter: wait}
+		}
+	}
+	// 529 is Anthropic's "overloaded" status.
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return providers.ChatResponse{}, true, fmt.Errorf("provider temporary status %d", resp.StatusCode)
+	}
+	if providers.IsModelNotFound(resp.StatusCode, respBody) {
+		return providers.ChatResponse{}, false, fmt.Errorf("provider status %d: %w", resp.StatusCode, providers.ErrModelNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return providers.ChatResponse{}, false, fmt.Errorf("provider status %d", resp.StatusCode)
+	}
+
+	out, err = parseMessages(respBody)
+	if err != nil {
+		return providers.ChatResponse{}, false, err
+	}
+	return out, false, nil
+}
+
+func parseMessages(body []byte) (providers.ChatResponse, error) {
+	var resp struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Thinking string `json:"thinking"`
+		} `json:"content"`
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return providers.ChatResponse{}, fmt.Errorf("decode messages response: %w", err)
+	}
+	var text, thinking []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			if strings.TrimSpace(block.Text) != "" {
+				text = append(text, block.Text)
+			}
+		case "thinking":
+			if strings.TrimSpace(block.Thinking) != "" {
+				thinking = append(thinking, block.Thinking)
+			}
+		}
+	}
+	if len(text) == 0 {
+		return providers.ChatResponse{}, fmt.Errorf("missing text content in messages response")
+	}
+	return providers.ChatResponse{
+		Text:      strings.Join(text, "\n"),
+		Reasoning: strings.Join(thinking, "\n\n"),
+		Model:     resp.Model,
+		Usage:     providers.Usage{InputTokens: resp.Usage.InputTokens, OutputTokens: resp.Usage.OutputTokens},
+	}, nil
+}
+
+func (c *Client) applyAuth(req *http.Request) {
+	if strings.TrimSpace(c.cfg.APIKey) != "" {
+		req.Header.Set("x-api-key", c.cfg.APIKey)
+	}
+	req.Header.Set("anthropic-version", APIVersion)
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, strings.ReplaceAll(v, "{{api_key}}", c.cfg.APIKey))
+	}
+}
+
+// ListModels queries GET /v1/models.
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	modelsURL, err := c.apiURL("/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL+"?limit=1000", nil)
+	if err != nil {
+		return nil, fmt.Errorf("build models request: %w", err)
+	}
+	c.applyAuth(req)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("models request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := providers.ReadResponse(resp.Body, c.cfg.MaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("read models response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("provider status %d: %w", resp.StatusCode, providers.ErrUnauthorized)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("provider status %d", resp.StatusCode)
+	}
+
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("decode models response: %w", err)
+	}
+	ids := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		if m.ID != "" {
+			ids = append(ids, m.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// apiURL joins path onto the base URL, tolerating a base that already ends
+// in /v1 or /v1/messages.
+func (c *Client) apiURL(path string) (string, error) {
+	base := strings.TrimSpace(c.cfg.BaseURL)
+	base = strings.TrimSuffix(base, "/")
+	base = strings.TrimSuffix(base, "/v1/messages")
+	base = strings.TrimSuffix(base, "/v1")
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse base url: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String(), nil
+}
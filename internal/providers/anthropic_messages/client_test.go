@@ -0,0 +1,63 @@
+package anthropic_messages
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hyprbot/internal/providers"
+)
+
+func TestBuildPayloadThinking(t *testing.T) {
+	body, err := buildPayload(providers.ChatRequest{
+		Model:          "claude-sonnet-4-5",
+		UserPrompt:     "hi",
+		MaxTokens:      1024,
+		Temperature:    0.7,
+		ThinkingBudget: 2048,
+	})
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if _, ok := payload["temperature"]; ok {
+		t.Fatalf("temperature must be omitted with thinking enabled")
+	}
+	if payload["max_tokens"].(float64) != 3072 {
+		t.Fatalf("expected max_tokens to exceed budget, got %v", payload["max_tokens"])
+	}
+	thinking, _ := payload["thinking"].(map[string]any)
+	if thinking["budget_tokens"].(float64) != 2048 {
+		t.Fatalf("unexpected thinking %#v", payload["thinking"])
+	}
+}
+
+func TestChatSplitsThinking(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "k" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers")
+		}
+		_, _ = w.Write([]byte(`{"content":[{"type":"thinking","thinking":"step 1"},{"type":"text","text":"answer"}],"model":"m-2025","usage":{"input_tokens":12,"output_tokens":30}}`))
+	}))
+	defer srv.Close()
+
+	c := New(Config{BaseURL: srv.URL, APIKey: "k"})
+	resp, err := c.Chat(context.Background(), providers.ChatRequest{Model: "m", UserPrompt: "q", ThinkingBudget: 1024})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if resp.Text != "answer" || resp.Reasoning != "step 1" {
+		t.Fatalf("unexpected response %#v", resp)
+	}
+	if resp.Model != "m-2025" || resp.Usage.Total() != 42 {
+		t.Fatalf("unexpected model/usage %q %#v", resp.Model, resp.Usage)
+	}
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// ErrUnauthorized means the provider rejected the credentials.
+var ErrUnauthorized = errors.New("provider rejected the api key")
+
+// keyPrefixes are the key shapes of well-known hosts. Keys for other hosts
+// (gateways, self-hosted servers) only get the generic checks.
+var keyPrefixes = map[string][]string{
+	"api.openai.com":    {"sk-"},
+	"api.anthropic.com": {"sk-ant-"},
+	"api.x.ai":          {"xai-"},
+	"openrouter.ai":     {"sk-or-"},
+	"api.groq.com":      {"gsk_"},
+}
+
+// CheckAPIKey refuses input that cannot be an API key for baseURL: pasted
+// URLs or commands, keys with spaces, the "Bearer " scheme or a prefix the
+// host never issues.
+func CheckAPIKey(baseURL, key string) error {
+	if strings.HasPrefix(strings.ToLower(key), "bearer ") {
+		return fmt.Errorf("send the key without the \"Bearer \" prefix")
+	}
+	if strings.HasPrefix(key, "/") || strings.Contains(key, "://") {
+		return fmt.Errorf("this looks like a command or URL, not an API key")
+	}
+	if strings.IndexFunc(key, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }) >= 0 {
+		return fmt.Errorf("API keys contain no spaces or line breaks")
+	}
+	if len(key) < 8 {
+		return fmt.Errorf("the key is too short")
+	}
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return nil
+	}
+	prefixes := keyPrefixes[strings.ToLower(u.Hostname())]
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p) {
+			return nil
+		}
+	}
+	if len(prefixes) > 0 {
+		return fmt.Errorf("keys for %s start with %s", u.Hostname(), strings.Join(prefixes, " or "))
+	}
+	return nil
+}
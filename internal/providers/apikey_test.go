@@ -0,0 +1,28 @@
+package providers
+
+import "testing"
+
+func TestCheckAPIKey(t *testing.T) {
+	cases := []struct {
+		baseURL string
+		key     string
+		ok      bool
+	}{
+		{"https://api.openai.com/v1", "sk-proj-abcdef123456", true},
+		{"https://api.openai.com/v1", "xai-abcdef123456", false},
+		{"https://api.anthropic.com", "sk-ant-api03-abcdef", true},
+		{"https://api.anthropic.com", "sk-proj-abcdef123456", false},
+		{"https://gateway.example.com/v1", "anything-goes-here", true},
+		{"https://gateway.example.com/v1", "Bearer sk-abcdef123456", false},
+		{"https://gateway.example.com/v1", "sk-abc def123456", false},
+		{"https://gateway.example.com/v1", "https://api.openai.com", false},
+		{"https://gateway.example.com/v1", "/cancel", false},
+		{"https://gateway.example.com/v1", "short", false},
+	}
+	for _, tc := range cases {
+		err := CheckAPIKey(tc.baseURL, tc.key)
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckAPIKey(%q, %q) = %v, want ok=%v", tc.baseURL, tc.key, err, tc.ok)
+		}
+	}
+}
@@ -7,26 +7,38 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
-	"text/template"
 	"time"
 
 	"hyprbot/internal/providers"
 )
 
 type Config struct {
-	URL          string
-	APIKey       string
-	Headers      map[string]string
-	BodyTemplate string
-	Method       string
-	HTTPClient   *http.Client
-	MaxRetries   int
-	BackoffBase  time.Duration
+	URL           string
+	APIKey        string
+	Headers       map[string]string
+	BodyTemplate  string
+	Method        string
+	HTTPClient    *http.Client
+	MaxRetries    int
+	BackoffBase   time.Duration
+	MaxRetryAfter time.Duration
+	// Zero means providers.DefaultMaxBodyBytes.
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
+	// ResponsePath selects the answer instead of guessing common keys.
+	ResponsePath string
+	Signing      *Signing
+	// Query maps parameter names to templates rendered with the same data as
+	// BodyTemplate and URL-encoded into the request URL.
+	Query map[string]string
 }
 
 type Client struct {
-	cfg Config
+	cfg        Config
+	extract    responseExtractor
+	extractErr error
 }
 
 func New(cfg Config) *Client {
@@ -42,35 +54,73 @@ func New(cfg Config) *Client {
 	if cfg.MaxRetries < 0 {
 		cfg.MaxRetries = 0
 	}
-	return &Client{cfg: cfg}
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = 30 * time.Second
+	}
+	if cfg.MaxRequestBytes <= 0 {
+		cfg.MaxRequestBytes = providers.DefaultMaxBodyBytes
+	}
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = providers.DefaultMaxBodyBytes
+	}
+	c := &Client{cfg: cfg}
+	if strings.TrimSpace(cfg.ResponsePath) != "" {
+		c.extract, c.extractErr = compileResponsePath(cfg.ResponsePath)
+	}
+	return c
 }
 
 var _ providers.Provider = (*Client)(nil)
 
 func (c *Client) Chat(ctx context.Context, req providers.ChatRequest) (providers.ChatResponse, error) {
-	body, err := c.renderBody(req)
+	if c.extractErr != nil {
+		return providers.ChatResponse{}, c.extractErr
+	}
+	if c.cfg.Signing != nil {
+		if err := c.cfg.Signing.Validate(); err != nil {
+			return providers.ChatResponse{}, err
+		}
+	}
+	endpointURL, err := c.renderURL(req)
 	if err != nil {
 		return providers.ChatResponse{}, err
 	}
+	var body []byte
+	if c.sendsBody() {
+		body, err = c.renderBody(req)
+		if err != nil {
+			return providers.ChatResponse{}, err
+		}
+		if err := providers.CheckRequestSize(body, c.cfg.MaxRequestBytes); err != nil {
+			return providers.ChatResponse{}, err
+		}
+	}
 
 	var lastErr error
+	trace := providers.ChatResponse{Endpoint: endpointURL}
 	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
-		text, retry, err := c.callOnce(ctx, body)
+		trace.Attempts++
+		text, retry, err := c.callOnce(ctx, endpointURL, body)
 		if err == nil {
-			return providers.ChatResponse{Text: text}, nil
+			trace.Text = text
+			return trace, nil
 		}
 		lastErr = err
 		if !retry || attempt == c.cfg.MaxRetries {
 			break
 		}
+		backoff, ok := providers.RetryDelay(err, c.cfg.BackoffBase*(1<<attempt), c.cfg.MaxRetryAfter)
+		if !ok {
+			break
+		}
 		select {
 		case <-ctx.Done():
-			return providers.ChatResponse{}, ctx.Err()
-		case <-time.After(c.cfg.BackoffBase * (1 << attempt)):
+			return trace, ctx.Err()
+		case <-time.After(backoff):
 		}
 	}
 
-	return providers.ChatResponse{}, lastErr
+	return trace, lastErr
 }
 
 func (c *Client) renderBody(req providers.ChatRequest) ([]byte, error) {
@@ -90,40 +140,88 @@ func (c *Client) renderBody(req providers.ChatRequest) ([]byte, error) {
 		return b, nil
 	}
 
-	tpl, err := template.New("custom_http_body").Option("missingkey=zero").Parse(c.cfg.BodyTemplate)
+	tpl, err := parseTemplate("custom_http_body", c.cfg.BodyTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("parse body template: %w", err)
 	}
 	var buf bytes.Buffer
-	if err := tpl.Execute(&buf, map[string]any{
-		"Model":        req.Model,
-		"SystemPrompt": req.SystemPrompt,
-		"UserPrompt":   req.UserPrompt,
-		"MaxTokens":    req.MaxTokens,
-		"Temperature":  req.Temperature,
-		"AllowTools":   req.AllowTools,
-		"APIKey":       c.cfg.APIKey,
-	}); err != nil {
+	if err := tpl.Execute(&buf, c.templateData(req)); err != nil {
 		return nil, fmt.Errorf("execute body template: %w", err)
 	}
 	return buf.Bytes(), nil
 }
 
-func (c *Client) callOnce(ctx context.Context, body []byte) (text string, retry bool, err error) {
+func (c *Client) templateData(req providers.ChatRequest) map[string]any {
+	return map[string]any{
+		"Model":           req.Model,
+		"SystemPrompt":    req.SystemPrompt,
+		"UserPrompt":      req.UserPrompt,
+		"MaxTokens":       req.MaxTokens,
+		"Temperature":     req.Temperature,
+		"AllowTools":      req.AllowTools,
+		"ReasoningEffort": req.ReasoningEffort,
+		"ThinkingBudget":  req.ThinkingBudget,
+		"ResponseFormat":  req.ResponseFormat,
+		"JSONSchema":      string(req.JSONSchema),
+		"APIKey":          c.cfg.APIKey,
+	}
+}
+
+// sendsBody is false for GET/HEAD, which are driven by query parameters only.
+func (c *Client) sendsBody() bool {
+	return c.cfg.Method != http.MethodGet && c.cfg.Method != http.MethodHead
+}
+
+func (c *Client) renderURL(req providers.ChatRequest) (string, error) {
 	if strings.TrimSpace(c.cfg.URL) == "" {
-		return "", false, fmt.Errorf("custom http url is empty")
+		return "", fmt.Errorf("custom http url is empty")
 	}
-	req, err := http.NewRequestWithContext(ctx, c.cfg.Method, c.cfg.URL, bytes.NewReader(body))
+	if len(c.cfg.Query) == 0 {
+		return c.cfg.URL, nil
+	}
+	u, err := url.Parse(c.cfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("parse custom url: %w", err)
+	}
+	q := u.Query()
+	data := c.templateData(req)
+	for name, raw := range c.cfg.Query {
+		tpl, err := parseTemplate("custom_http_query", raw)
+		if err != nil {
+			return "", fmt.Errorf("parse query template %s: %w", name, err)
+		}
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("execute query template %s: %w", name, err)
+		}
+		q.Set(name, buf.String())
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *Client) callOnce(ctx context.Context, endpointURL string, body []byte) (text string, retry bool, err error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.cfg.Method, endpointURL, reqBody)
 	if err != nil {
 		return "", false, fmt.Errorf("build custom request: %w", err)
 	}
 	if len(c.cfg.Headers) == 0 {
-		req.Header.Set("Content-Type", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 	} else {
 		for k, v := range c.cfg.Headers {
 			req.Header.Set(k, strings.ReplaceAll(v, "{{api_key}}", c.cfg.APIKey))
 		}
 	}
+	// Signed last so every header set above is covered.
+	if c.cfg.Signing != nil {
+		c.cfg.Signing.apply(req, body, time.Now())
+	}
 
 	resp, err := c.cfg.HTTPClient.Do(req)
 	if err != nil {
@@ -131,18 +229,41 @@ func (c *Client) callOnce(ctx context.Context, body []byte) (text string, retry
 	}
 	defer resp.Body.Close()
 
-	b, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	b, err := providers.ReadResponse(resp.Body, c.cfg.MaxResponseBytes)
 	if err != nil {
 		return "", false, fmt.Errorf("read custom response: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if wait := providers.RetryAfter(resp.Header, time.Now()); wait > 0 || resp.StatusCode == http.StatusTooManyRequests {
+			return "", true, &providers.RateLimitError{Status: resp.StatusCode, RetryAfter: wait}
+		}
+	}
 	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
 		return "", true, fmt.Errorf("custom provider temporary status %d", resp.StatusCode)
 	}
+	if providers.IsModelNotFound(resp.StatusCode, b) {
+		return "", false, fmt.Errorf("custom provider status %d: %w", resp.StatusCode, providers.ErrModelNotFound)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return "", false, fmt.Errorf("custom provider status %d", resp.StatusCode)
 	}
 
+	if c.extract != nil {
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return "", false, fmt.Errorf("decode custom response: %w", err)
+		}
+		text, err = c.extract(doc)
+		if err != nil {
+			return "", false, err
+		}
+		if strings.TrimSpace(text) == "" {
+			return "", false, fmt.Errorf("response path %s matched an empty value", c.cfg.ResponsePath)
+		}
+		return text, false, nil
+	}
+
 	text, err = extractText(b)
 	if err != nil {
 		return "", false, err
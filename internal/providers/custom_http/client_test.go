@@ -0,0 +1,80 @@
+package custom_http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hyprbot/internal/providers"
+)
+
+func TestChatGETWithQueryTemplate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		if r.ContentLength > 0 || r.Header.Get("Content-Type") != "" {
+			t.Errorf("GET must not carry a body")
+		}
+		if got := r.URL.Query().Get("q"); got != `say "hi" & bye` {
+			t.Errorf("unexpected q %q", got)
+		}
+		if got := r.URL.Query().Get("lang"); got != "en" {
+			t.Errorf("static query parameter lost: %q", got)
+		}
+		_, _ = w.Write([]byte(`{"answer":"ok"}`))
+	}))
+	defer srv.Close()
+
+	c := New(Config{
+		URL:    srv.URL + "/search?lang=en",
+		Method: http.MethodGet,
+		Query:  map[string]string{"q": "{{.UserPrompt}}"},
+	})
+	resp, err := c.Chat(context.Background(), providers.ChatRequest{UserPrompt: `say "hi" & bye`})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if resp.Text != "ok" {
+		t.Fatalf("unexpected text %q", resp.Text)
+	}
+}
+
+func TestBodyTemplateHelpersEscapePrompts(t *testing.T) {
+	c := New(Config{
+		URL:          "https://gw.internal/v1/messages",
+		BodyTemplate: `{"model":{{json .Model}},"system":{{json .SystemPrompt}},"messages":{{anthropic_messages .UserPrompt}},"alt":{{messages .SystemPrompt .UserPrompt}}}`,
+	})
+	prompt := "line one\n\"quoted\" \\ backslash"
+	body, err := c.renderBody(providers.ChatRequest{Model: "m", SystemPrompt: "be brief", UserPrompt: prompt})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	var payload struct {
+		Model    string `json:"model"`
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+		Alt []map[string]string `json:"alt"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("rendered body is not valid JSON: %v\n%s", err, body)
+	}
+	if payload.Messages[0].Content[0].Text != prompt || payload.System != "be brief" {
+		t.Fatalf("prompt not preserved: %+v", payload)
+	}
+	if len(payload.Alt) != 2 || payload.Alt[0]["role"] != "system" || payload.Alt[1]["content"] != prompt {
+		t.Fatalf("unexpected openai messages: %+v", payload.Alt)
+	}
+
+	if err := ValidateTemplate(`{{unknown .UserPrompt}}`); err == nil {
+		t.Fatalf("expected unknown function to be rejected")
+	}
+}
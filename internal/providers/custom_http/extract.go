@@ -0,0 +1,136 @@
+package custom_http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// responseExtractor pulls the answer out of a decoded JSON response.
+type responseExtractor func(doc any) (string, error)
+
+// ValidateResponsePath reports whether path is a usable response_path: either
+// a Go template ({{.data.answer}}) or a dotted JSONPath ($.choices[0].text).
+func ValidateResponsePath(path string) error {
+	_, err := compileResponsePath(path)
+	return err
+}
+
+func compileResponsePath(path string) (responseExtractor, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("response path is empty")
+	}
+	if strings.Contains(path, "{{") {
+		tpl, err := template.New("custom_http_response").Option("missingkey=zero").Parse(path)
+		if err != nil {
+			return nil, fmt.Errorf("parse response template: %w", err)
+		}
+		return func(doc any) (string, error) {
+			var buf bytes.Buffer
+			if err := tpl.Execute(&buf, doc); err != nil {
+				return "", fmt.Errorf("execute response template: %w", err)
+			}
+			return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+		}, nil
+	}
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc any) (string, error) {
+		cur := doc
+		for _, st := range steps {
+			switch v := cur.(type) {
+			case map[string]any:
+				next, ok := v[st.key]
+				if !ok {
+					return "", fmt.Errorf("response path %s: key %q not found", path, st.key)
+				}
+				cur = next
+			case []any:
+				if !st.isIndex || st.index < 0 || st.index >= len(v) {
+					return "", fmt.Errorf("response path %s: index out of range", path)
+				}
+				cur = v[st.index]
+			default:
+				return "", fmt.Errorf("response path %s: cannot descend into %T", path, cur)
+			}
+		}
+		return valueToText(cur), nil
+	}, nil
+}
+
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath accepts the dotted subset of JSONPath: $.a.b[0].c, a.b.0.c
+// and ['quoted key'] segments.
+func parseJSONPath(path string) ([]pathStep, error) {
+	p := strings.TrimPrefix(path, "$")
+	var steps []pathStep
+	for len(p) > 0 {
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+		case strings.HasPrefix(p, "['"):
+			end := strings.Index(p, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("response path %s: unterminated bracket", path)
+			}
+			steps = append(steps, pathStep{key: p[2:end]})
+			p = p[end+2:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("response path %s: unterminated bracket", path)
+			}
+			n, err := strconv.Atoi(p[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("response path %s: invalid index %q", path, p[1:end])
+			}
+			steps = append(steps, pathStep{key: p[1:end], index: n, isIndex: true})
+			p = p[end+1:]
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			seg := p[:end]
+			if n, err := strconv.Atoi(seg); err == nil {
+				steps = append(steps, pathStep{key: seg, index: n, isIndex: true})
+			} else {
+				steps = append(steps, pathStep{key: seg})
+			}
+			p = p[end:]
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("response path %s selects nothing", path)
+	}
+	return steps, nil
+}
+
+func valueToText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64, bool:
+		return fmt.Sprint(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprint(t)
+		}
+		return string(b)
+	}
+}
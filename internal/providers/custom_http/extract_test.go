@@ -0,0 +1,49 @@
+package custom_http
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompileResponsePath(t *testing.T) {
+	var doc any
+	raw := `{"data":{"answer":"hi","items":[{"text":"first"},{"text":"second"}],"n":3},"weird key":{"0":"zero"}}`
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	cases := map[string]string{
+		"$.data.answer":      "hi",
+		"data.items[1].text": "second",
+		"data.items.0.text":  "first",
+		"$['weird key'].0":   "zero",
+		"$.data.n":           "3",
+		"{{.data.answer}}!":  "hi!",
+		"{{.data.missing}}":  "",
+		"$.data.items[0]":    `{"text":"first"}`,
+	}
+	for path, want := range cases {
+		ex, err := compileResponsePath(path)
+		if err != nil {
+			t.Fatalf("%s: compile: %v", path, err)
+		}
+		got, err := ex(doc)
+		if err != nil {
+			t.Fatalf("%s: extract: %v", path, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %q, want %q", path, got, want)
+		}
+	}
+
+	for _, path := range []string{"", "$", "data.items[x]", "{{.data", "$['open"} {
+		if err := ValidateResponsePath(path); err == nil {
+			t.Fatalf("expected %q to be rejected", path)
+		}
+	}
+
+	ex, _ := compileResponsePath("$.data.items[5].text")
+	if _, err := ex(doc); err == nil {
+		t.Fatalf("expected out of range error")
+	}
+}
@@ -0,0 +1,55 @@
+package custom_http
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are available in body and query templates so prompts can be
+// embedded in JSON without manual escaping:
+//
+//	{"model": {{json .Model}}, "messages": {{messages .SystemPrompt .UserPrompt}}}
+var templateFuncs = template.FuncMap{
+	"json":               toJSON,
+	"messages":           openAIMessages,
+	"anthropic_messages": anthropicMessages,
+}
+
+// ValidateTemplate parses a body or query template with the helper functions.
+func ValidateTemplate(tpl string) error {
+	_, err := parseTemplate("custom_http", tpl)
+	return err
+}
+
+func parseTemplate(name, tpl string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(tpl)
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// openAIMessages renders a chat-completions messages array; an empty system
+// prompt is omitted.
+func openAIMessages(system, user string) (string, error) {
+	msgs := make([]map[string]string, 0, 2)
+	if strings.TrimSpace(system) != "" {
+		msgs = append(msgs, map[string]string{"role": "system", "content": system})
+	}
+	msgs = append(msgs, map[string]string{"role": "user", "content": user})
+	return toJSON(msgs)
+}
+
+// anthropicMessages renders a Messages API array. The system prompt is a
+// top-level field there, so use {{json .SystemPrompt}} for it separately.
+func anthropicMessages(user string) (string, error) {
+	return toJSON([]map[string]any{{
+		"role":    "user",
+		"content": []map[string]string{{"type": "text", "text": user}},
+	}})
+}
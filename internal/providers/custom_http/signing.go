@@ -0,0 +1,79 @@
+package custom_http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hyprbot/internal/sigv4"
+)
+
+const (
+	SigningHMACSHA256 = "hmac-sha256"
+	SigningSigV4      = "sigv4"
+)
+
+// Signing is the request signing setup for gateways that authenticate the
+// body rather than a bearer token. It is stored encrypted in the provider
+// config.
+type Signing struct {
+	Type string `json:"type"`
+
+	// hmac-sha256: hex HMAC of the body (or "<timestamp>.<body>" when
+	// TimestampHeader is set) written to Header with an optional Prefix.
+	Secret          string `json:"secret,omitempty"`
+	Header          string `json:"header,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`
+	TimestampHeader string `json:"timestamp_header,omitempty"`
+
+	// sigv4
+	Region       string `json:"region,omitempty"`
+	Service      string `json:"service,omitempty"`
+	AccessKey    string `json:"access_key,omitempty"`
+	SecretKey    string `json:"secret_key,omitempty"`
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+func (s Signing) Validate() error {
+	switch s.Type {
+	case SigningHMACSHA256:
+		if s.Secret == "" {
+			return fmt.Errorf("hmac-sha256 signing requires secret")
+		}
+	case SigningSigV4:
+		if s.Region == "" || s.Service == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return fmt.Errorf("sigv4 signing requires region, service, access_key and secret_key")
+		}
+	default:
+		return fmt.Errorf("unsupported signing type %q (use %s or %s)", s.Type, SigningHMACSHA256, SigningSigV4)
+	}
+	return nil
+}
+
+func (s Signing) apply(req *http.Request, body []byte, now time.Time) {
+	switch s.Type {
+	case SigningHMACSHA256:
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		if s.TimestampHeader != "" {
+			ts := strconv.FormatInt(now.Unix(), 10)
+			req.Header.Set(s.TimestampHeader, ts)
+			mac.Write([]byte(ts + "."))
+		}
+		mac.Write(body)
+		header := s.Header
+		if strings.TrimSpace(header) == "" {
+			header = "X-Signature"
+		}
+		req.Header.Set(header, s.Prefix+hex.EncodeToString(mac.Sum(nil)))
+	case SigningSigV4:
+		if s.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		}
+		sigv4.Sign(req, s.AccessKey, s.SecretKey, s.Region, s.Service, sigv4.HashHex(body), now)
+	}
+}
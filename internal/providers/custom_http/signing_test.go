@@ -0,0 +1,55 @@
+package custom_http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningHMAC(t *testing.T) {
+	body := []byte(`{"prompt":"hi"}`)
+	now := time.Unix(1770976800, 0)
+	req, _ := http.NewRequest(http.MethodPost, "https://gw.internal/ask", nil)
+
+	sg := Signing{Type: SigningHMACSHA256, Secret: "s3cret", Prefix: "sha256=", TimestampHeader: "X-Timestamp"}
+	if err := sg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	sg.apply(req, body, now)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1770976800."))
+	mac.Write(body)
+	if got, want := req.Header.Get("X-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("unexpected signature %q, want %q", got, want)
+	}
+	if req.Header.Get("X-Timestamp") != "1770976800" {
+		t.Fatalf("missing timestamp header")
+	}
+}
+
+func TestSigningSigV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://abc.execute-api.eu-west-1.amazonaws.com/prod/ask", nil)
+	sg := Signing{Type: SigningSigV4, Region: "eu-west-1", Service: "execute-api", AccessKey: "AKID", SecretKey: "secret", SessionToken: "tok"}
+	sg.apply(req, []byte("{}"), time.Date(2026, 2, 13, 10, 0, 0, 0, time.UTC))
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260213/eu-west-1/execute-api/aws4_request") {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+	if !strings.Contains(auth, "x-amz-security-token") {
+		t.Fatalf("session token must be signed: %q", auth)
+	}
+}
+
+func TestSigningValidate(t *testing.T) {
+	for _, sg := range []Signing{{Type: "md5"}, {Type: SigningHMACSHA256}, {Type: SigningSigV4, Region: "us-east-1"}} {
+		if err := sg.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", sg)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+)
+
+const DefaultMaxBodyBytes int64 = 4 << 20
+
+// BodyTooLargeError reports a request or response body over the provider's
+// configured limit. It is not retryable.
+type BodyTooLargeError struct {
+	Direction string
+	Limit     int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("%s body exceeds limit of %d bytes", e.Direction, e.Limit)
+}
+
+func CheckRequestSize(body []byte, limit int64) error {
+	if limit > 0 && int64(len(body)) > limit {
+		return &BodyTooLargeError{Direction: "request", Limit: limit}
+	}
+	return nil
+}
+
+// ReadResponse reads at most limit bytes and fails instead of silently
+// truncating when the body is longer.
+func ReadResponse(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, &BodyTooLargeError{Direction: "response", Limit: limit}
+	}
+	return b, nil
+}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadResponseLimit(t *testing.T) {
+	b, err := ReadResponse(strings.NewReader("hello"), 5)
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("expected exact-limit body to pass, got %q err=%v", b, err)
+	}
+
+	_, err = ReadResponse(strings.NewReader("hello!"), 5)
+	var tooLarge *BodyTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Direction != "response" {
+		t.Fatalf("expected response too large error, got %v", err)
+	}
+}
+
+func TestCheckRequestSize(t *testing.T) {
+	if err := CheckRequestSize([]byte("abc"), 0); err != nil {
+		t.Fatalf("zero limit must disable the check: %v", err)
+	}
+	if err := CheckRequestSize([]byte("abcd"), 3); err == nil {
+		t.Fatalf("expected request too large error")
+	}
+}
+
+func TestIsModelNotFound(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{404, `{"error":{"message":"The model ` + "`gpt-9`" + ` does not exist","code":"model_not_found"}}`, true},
+		{400, `{"error":"Model grok-1 has been decommissioned"}`, true},
+		{400, `{"error":"max_tokens too large"}`, false},
+		{500, `model not found`, false},
+	}
+	for _, tc := range cases {
+		if got := IsModelNotFound(tc.status, []byte(tc.body)); got != tc.want {
+			t.Errorf("status %d body %s: got %v", tc.status, tc.body, got)
+		}
+	}
+}
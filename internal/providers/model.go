@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrModelNotFound means the provider rejected the requested model, usually
+// because it was renamed or retired.
+var ErrModelNotFound = errors.New("model not found")
+
+var modelNotFoundHints = []string{
+	"model_not_found",
+	"model not found",
+	"unknown model",
+	"no such model",
+	"invalid model",
+	"decommissioned",
+}
+
+// IsModelNotFound recognises the "unknown model" error bodies of common
+// OpenAI-compatible and gateway APIs.
+func IsModelNotFound(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusNotFound && status != http.StatusUnprocessableEntity {
+		return false
+	}
+	msg := strings.ToLower(string(body))
+	for _, hint := range modelNotFoundHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return strings.Contains(msg, "model") && strings.Contains(msg, "does not exist")
+}
@@ -5,9 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,13 +15,17 @@ import (
 )
 
 type Config struct {
-	BaseURL     string
-	APIKey      string
-	Headers     map[string]string
-	Endpoint    string
-	HTTPClient  *http.Client
-	MaxRetries  int
-	BackoffBase time.Duration
+	BaseURL       string
+	APIKey        string
+	Headers       map[string]string
+	Endpoint      string
+	HTTPClient    *http.Client
+	MaxRetries    int
+	BackoffBase   time.Duration
+	MaxRetryAfter time.Duration
+	// Zero means providers.DefaultMaxBodyBytes.
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
 }
 
 type Client struct {
@@ -41,36 +45,57 @@ func New(cfg Config) *Client {
 	if cfg.MaxRetries < 0 {
 		cfg.MaxRetries = 0
 	}
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = 30 * time.Second
+	}
+	if cfg.MaxRequestBytes <= 0 {
+		cfg.MaxRequestBytes = providers.DefaultMaxBodyBytes
+	}
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = providers.DefaultMaxBodyBytes
+	}
 	return &Client{cfg: cfg}
 }
 
-var _ providers.Provider = (*Client)(nil)
+var (
+	_ providers.Provider    = (*Client)(nil)
+	_ providers.ModelLister = (*Client)(nil)
+)
 
 func (c *Client) Chat(ctx context.Context, req providers.ChatRequest) (providers.ChatResponse, error) {
 	body, endpointURL, err := c.buildPayload(req)
 	if err != nil {
 		return providers.ChatResponse{}, err
 	}
+	if err := providers.CheckRequestSize(body, c.cfg.MaxRequestBytes); err != nil {
+		return providers.ChatResponse{}, err
+	}
 
 	var lastErr error
+	trace := providers.ChatResponse{Endpoint: endpointURL}
 	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
-		text, retry, err := c.callOnce(ctx, endpointURL, body)
+		trace.Attempts++
+		resp, retry, err := c.callOnce(ctx, endpointURL, body)
 		if err == nil {
-			return providers.ChatResponse{Text: text}, nil
+			resp.Endpoint, resp.Attempts = trace.Endpoint, trace.Attempts
+			return resp, nil
 		}
 		lastErr = err
 		if !retry || attempt == c.cfg.MaxRetries {
 			break
 		}
-		backoff := c.cfg.BackoffBase * (1 << attempt)
+		backoff, ok := providers.RetryDelay(err, c.cfg.BackoffBase*(1<<attempt), c.cfg.MaxRetryAfter)
+		if !ok {
+			break
+		}
 		select {
 		case <-ctx.Done():
-			return providers.ChatResponse{}, ctx.Err()
+			return trace, ctx.Err()
 		case <-time.After(backoff):
 		}
 	}
 
-	return providers.ChatResponse{}, lastErr
+	return trace, lastErr
 }
 
 func (c *Client) buildPayload(req providers.ChatRequest) ([]byte, string, error) {
@@ -93,6 +118,16 @@ func (c *Client) buildPayload(req providers.ChatRequest) ([]byte, string, error)
 		if req.Temperature > 0 {
 			payload["temperature"] = req.Temperature
 		}
+		if req.ReasoningEffort != "" {
+			payload["reasoning"] = map[string]any{"effort": req.ReasoningEffort, "summary": "auto"}
+		}
+		if format := responseFormat(req); format != nil {
+			// The responses API flattens the schema into text.format.
+			if schema, ok := format["json_schema"].(map[string]any); ok {
+				format = map[string]any{"type": providers.ResponseFormatJSONSchema, "name": schema["name"], "schema": schema["schema"]}
+			}
+			payload["text"] = map[string]any{"format": format}
+		}
 		b, err := json.Marshal(payload)
 		if err != nil {
 			return nil, "", fmt.Errorf("marshal responses payload: %w", err)
@@ -100,22 +135,32 @@ func (c *Client) buildPayload(req providers.ChatRequest) ([]byte, string, error)
 		return b, endpointURL, nil
 	}
 
-	messages := []map[string]string{}
+	messages := []map[string]any{}
 	if strings.TrimSpace(req.SystemPrompt) != "" {
-		messages = append(messages, map[string]string{"role": "system", "content": req.SystemPrompt})
+		messages = append(messages, map[string]any{"role": "system", "content": req.SystemPrompt})
 	}
-	messages = append(messages, map[string]string{"role": "user", "content": req.UserPrompt})
+	messages = append(messages, map[string]any{"role": "user", "content": req.UserPrompt})
+	messages = append(messages, toolMessages(req.Turns)...)
 
 	payload := map[string]any{
 		"model":    req.Model,
 		"messages": messages,
 	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = toolDefinitions(req.Tools)
+	}
 	if req.MaxTokens > 0 {
 		payload["max_tokens"] = req.MaxTokens
 	}
 	if req.Temperature > 0 {
 		payload["temperature"] = req.Temperature
 	}
+	if req.ReasoningEffort != "" {
+		payload["reasoning_effort"] = req.ReasoningEffort
+	}
+	if format := responseFormat(req); format != nil {
+		payload["response_format"] = format
+	}
 	b, err := json.Marshal(payload)
 	if err != nil {
 		return nil, "", fmt.Errorf("marshal chat completion payload: %w", err)
@@ -123,50 +168,177 @@ func (c *Client) buildPayload(req providers.ChatRequest) ([]byte, string, error)
 	return b, endpointURL, nil
 }
 
-func (c *Client) callOnce(ctx context.Context, endpointURL string, body []byte) (text string, retry bool, err error) {
+// responseFormat builds the chat completions response_format object, or nil
+// for plain text.
+func responseFormat(req providers.ChatRequest) map[string]any {
+	switch {
+	case req.ResponseFormat == providers.ResponseFormatJSONSchema && len(req.JSONSchema) > 0:
+		return map[string]any{
+			"type": providers.ResponseFormatJSONSchema,
+			"json_schema": map[string]any{
+				"name":   "response",
+				"schema": req.JSONSchema,
+			},
+		}
+	case req.WantsJSON():
+		return map[string]any{"type": providers.ResponseFormatJSONObject}
+	}
+	return nil
+}
+
+func toolDefinitions(tools []providers.Tool) []map[string]any {
+	out := make([]map[string]any, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  t.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+// toolMessages replays tool rounds as assistant tool_calls messages followed
+// by one tool message per result.
+func toolMessages(turns []providers.ToolTurn) []map[string]any {
+	var out []map[string]any
+	for _, turn := range turns {
+		calls := make([]map[string]any, 0, len(turn.Calls))
+		for _, c := range turn.Calls {
+			calls = append(calls, map[string]any{
+				"id":       c.ID,
+				"type":     "function",
+				"function": map[string]any{"name": c.Name, "arguments": c.Arguments},
+			})
+		}
+		msg := map[string]any{"role": "assistant", "tool_calls": calls}
+		if turn.Text != "" {
+			msg["content"] = turn.Text
+		}
+		out = append(out, msg)
+		for _, r := range turn.Results {
+			out = append(out, map[string]any{"role": "tool", "tool_call_id": r.CallID, "content": r.Content})
+		}
+	}
+	return out
+}
+
+func (c *Client) callOnce(ctx context.Context, endpointURL string, body []byte) (out providers.ChatResponse, retry bool, err error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
 	if err != nil {
-		return "", false, fmt.Errorf("build request: %w", err)
+		return providers.ChatResponse{}, false, fmt.Errorf("build request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.applyAuth(req)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return providers.ChatResponse{}, true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := providers.ReadResponse(resp.Body, c.cfg.MaxResponseBytes)
+	if err != nil {
+		return providers.ChatResponse{}, false, fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if wait := providers.RetryAfter(resp.Header, time.Now()); wait > 0 || resp.StatusCode == http.StatusTooManyRequests {
+			return providers.ChatResponse{}, true, &providers.RateLimitError{Status: resp.StatusCode, RetryAfter: wait}
+		}
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return providers.ChatResponse{}, true, fmt.Errorf("provider temporary status %d", resp.StatusCode)
+	}
+	if providers.IsModelNotFound(resp.StatusCode, respBody) {
+		return providers.ChatResponse{}, false, fmt.Errorf("provider status %d: %w", resp.StatusCode, providers.ErrModelNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return providers.ChatResponse{}, false, fmt.Errorf("provider status %d", resp.StatusCode)
+	}
+
+	if isResponsesEndpoint(c.cfg.Endpoint) {
+		out, err = parseResponsesAPI(respBody)
+	} else {
+		out, err = parseChatCompletions(respBody)
+	}
+	if err != nil {
+		return providers.ChatResponse{}, false, err
+	}
+	return out, false, nil
+}
+
+func (c *Client) applyAuth(req *http.Request) {
 	if strings.TrimSpace(c.cfg.APIKey) != "" {
 		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
 	}
 	for k, v := range c.cfg.Headers {
 		req.Header.Set(k, strings.ReplaceAll(v, "{{api_key}}", c.cfg.APIKey))
 	}
+}
+
+// ListModels queries the OpenAI-style GET /models endpoint.
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	modelsURL, err := c.buildModelsURL()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build models request: %w", err)
+	}
+	c.applyAuth(req)
 
 	resp, err := c.cfg.HTTPClient.Do(req)
 	if err != nil {
-		return "", true, fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("models request failed: %w", err)
 	}
 	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	body, err := providers.ReadResponse(resp.Body, c.cfg.MaxResponseBytes)
 	if err != nil {
-		return "", false, fmt.Errorf("read response body: %w", err)
+		return nil, fmt.Errorf("read models response: %w", err)
 	}
-
-	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
-		return "", true, fmt.Errorf("provider temporary status %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("provider status %d: %w", resp.StatusCode, providers.ErrUnauthorized)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", false, fmt.Errorf("provider status %d", resp.StatusCode)
+		return nil, fmt.Errorf("provider status %d", resp.StatusCode)
 	}
 
-	if isResponsesEndpoint(c.cfg.Endpoint) {
-		text, err := parseResponsesAPI(respBody)
-		if err != nil {
-			return "", false, err
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("decode models response: %w", err)
+	}
+	ids := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		if m.ID != "" {
+			ids = append(ids, m.ID)
 		}
-		return text, false, nil
 	}
+	sort.Strings(ids)
+	return ids, nil
+}
 
-	text, err = parseChatCompletions(respBody)
+func (c *Client) buildModelsURL() (string, error) {
+	base := strings.TrimSpace(c.cfg.BaseURL)
+	if base == "" {
+		return "", fmt.Errorf("base url is empty")
+	}
+	base = strings.TrimSuffix(base, "/chat/completions")
+	base = strings.TrimSuffix(base, "/responses")
+	u, err := url.Parse(base)
 	if err != nil {
-		return "", false, err
+		return "", fmt.Errorf("parse base url: %w", err)
 	}
-	return text, false, nil
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/models"
+	return u.String(), nil
 }
 
 func (c *Client) buildEndpointURL() (string, error) {
@@ -191,49 +363,119 @@ func (c *Client) buildEndpointURL() (string, error) {
 	return u.String(), nil
 }
 
-func parseChatCompletions(body []byte) (string, error) {
+func parseChatCompletions(body []byte) (providers.ChatResponse, error) {
 	var resp struct {
 		Choices []struct {
 			Message struct {
 				Content any `json:"content"`
+				// reasoning_content is used by DeepSeek-style reasoners,
+				// reasoning by OpenRouter.
+				ReasoningContent string `json:"reasoning_content"`
+				Reasoning        string `json:"reasoning"`
+				ToolCalls        []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
 			} `json:"message"`
 			Text string `json:"text"`
 		} `json:"choices"`
+		Model string `json:"model"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.Unmarshal(body, &resp); err != nil {
-		return "", fmt.Errorf("decode chat completion response: %w", err)
+		return providers.ChatResponse{}, fmt.Errorf("decode chat completion response: %w", err)
 	}
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("empty choices in chat completion response")
+		return providers.ChatResponse{}, fmt.Errorf("empty choices in chat completion response")
+	}
+	choice := resp.Choices[0]
+	out := providers.ChatResponse{
+		Reasoning: choice.Message.ReasoningContent,
+		Model:     resp.Model,
+		Usage:     providers.Usage{InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens},
 	}
-	if resp.Choices[0].Text != "" {
-		return resp.Choices[0].Text, nil
+	if out.Reasoning == "" {
+		out.Reasoning = choice.Message.Reasoning
 	}
-	if content := anyToText(resp.Choices[0].Message.Content); strings.TrimSpace(content) != "" {
-		return content, nil
+	for _, tc := range choice.Message.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, providers.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
 	}
-	return "", fmt.Errorf("missing message content in chat completion response")
+	if len(out.ToolCalls) > 0 {
+		// The content of a tool call turn is optional commentary.
+		out.Text = anyToText(choice.Message.Content)
+		return out, nil
+	}
+	if choice.Text != "" {
+		out.Text = choice.Text
+		return out, nil
+	}
+	if content := anyToText(choice.Message.Content); strings.TrimSpace(content) != "" {
+		out.Text = content
+		return out, nil
+	}
+	return providers.ChatResponse{}, fmt.Errorf("missing message content in chat completion response")
 }
 
-func parseResponsesAPI(body []byte) (string, error) {
+// parseResponsesAPI collects message text and reasoning summaries. Reasoning
+// models put a "reasoning" item before the message, so output[0] is not
+// necessarily the answer.
+func parseResponsesAPI(body []byte) (providers.ChatResponse, error) {
 	var resp struct {
 		OutputText string `json:"output_text"`
 		Output     []struct {
+			Type    string `json:"type"`
 			Content []struct {
 				Text string `json:"text"`
 			} `json:"content"`
+			Summary []struct {
+				Text string `json:"text"`
+			} `json:"summary"`
 		} `json:"output"`
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.Unmarshal(body, &resp); err != nil {
-		return "", fmt.Errorf("decode responses api response: %w", err)
+		return providers.ChatResponse{}, fmt.Errorf("decode responses api response: %w", err)
+	}
+	var text, reasoning []string
+	for _, item := range resp.Output {
+		if item.Type == "reasoning" {
+			for _, s := range item.Summary {
+				if strings.TrimSpace(s.Text) != "" {
+					reasoning = append(reasoning, s.Text)
+				}
+			}
+			continue
+		}
+		for _, c := range item.Content {
+			if strings.TrimSpace(c.Text) != "" {
+				text = append(text, c.Text)
+			}
+		}
 	}
-	if strings.TrimSpace(resp.OutputText) != "" {
-		return resp.OutputText, nil
+	out := providers.ChatResponse{
+		Reasoning: strings.Join(reasoning, "\n\n"),
+		Model:     resp.Model,
+		Usage:     providers.Usage{InputTokens: resp.Usage.InputTokens, OutputTokens: resp.Usage.OutputTokens},
 	}
-	if len(resp.Output) > 0 && len(resp.Output[0].Content) > 0 && strings.TrimSpace(resp.Output[0].Content[0].Text) != "" {
-		return resp.Output[0].Content[0].Text, nil
+	switch {
+	case strings.TrimSpace(resp.OutputText) != "":
+		out.Text = resp.OutputText
+	case len(text) > 0:
+		out.Text = strings.Join(text, "\n")
+	default:
+		return providers.ChatResponse{}, fmt.Errorf("missing output text in responses api response")
 	}
-	return "", fmt.Errorf("missing output text in responses api response")
+	return out, nil
 }
 
 func anyToText(v any) string {
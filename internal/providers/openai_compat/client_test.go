@@ -1,7 +1,10 @@
 package openai_compat
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"hyprbot/internal/providers"
@@ -47,3 +50,162 @@ func TestBuildPayloadResponsesEndpoint(t *testing.T) {
 		t.Fatalf("unexpected endpoint %q", endpoint)
 	}
 }
+
+func TestListModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("missing auth header")
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"grok-3"},{"id":"grok-2-latest"}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(Config{BaseURL: srv.URL + "/v1/chat/completions", APIKey: "k"})
+	models, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("list models: %v", err)
+	}
+	if len(models) != 2 || models[0] != "grok-2-latest" || models[1] != "grok-3" {
+		t.Fatalf("unexpected models %v", models)
+	}
+}
+
+func TestReasoningEffortMapping(t *testing.T) {
+	req := providers.ChatRequest{Model: "o4-mini", UserPrompt: "hello", ReasoningEffort: "high"}
+
+	body, _, err := New(Config{BaseURL: "https://api.openai.com/v1"}).buildPayload(req)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var chat map[string]any
+	_ = json.Unmarshal(body, &chat)
+	if chat["reasoning_effort"] != "high" {
+		t.Fatalf("expected reasoning_effort, got %#v", chat)
+	}
+
+	body, _, err = New(Config{BaseURL: "https://api.openai.com/v1", Endpoint: "responses"}).buildPayload(req)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var responses struct {
+		Reasoning map[string]string `json:"reasoning"`
+	}
+	_ = json.Unmarshal(body, &responses)
+	if responses.Reasoning["effort"] != "high" {
+		t.Fatalf("expected reasoning.effort, got %s", body)
+	}
+}
+
+func TestParseResponsesAPIReasoningItem(t *testing.T) {
+	resp, err := parseResponsesAPI([]byte(`{"output":[
+		{"type":"reasoning","summary":[{"type":"summary_text","text":"thought"}]},
+		{"type":"message","content":[{"type":"output_text","text":"answer"}]}
+	],"usage":{"input_tokens":5,"output_tokens":7}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if resp.Text != "answer" || resp.Reasoning != "thought" {
+		t.Fatalf("unexpected response %#v", resp)
+	}
+	if resp.Usage.Total() != 12 {
+		t.Fatalf("unexpected usage %#v", resp.Usage)
+	}
+}
+
+func TestParseChatCompletionsUsage(t *testing.T) {
+	resp, err := parseChatCompletions([]byte(`{"model":"gpt-4.1-2025-04-14","choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if resp.Text != "hi" || resp.Model != "gpt-4.1-2025-04-14" || resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 3 {
+		t.Fatalf("unexpected response %#v", resp)
+	}
+}
+
+func TestResponseFormatMapping(t *testing.T) {
+	req := providers.ChatRequest{
+		Model:          "gpt-4.1",
+		UserPrompt:     "hello",
+		ResponseFormat: providers.ResponseFormatJSONSchema,
+		JSONSchema:     json.RawMessage(`{"type":"object"}`),
+	}
+
+	body, _, err := New(Config{BaseURL: "https://api.openai.com/v1"}).buildPayload(req)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var chat struct {
+		ResponseFormat struct {
+			Type       string `json:"type"`
+			JSONSchema struct {
+				Schema map[string]any `json:"schema"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+	}
+	_ = json.Unmarshal(body, &chat)
+	if chat.ResponseFormat.Type != "json_schema" || chat.ResponseFormat.JSONSchema.Schema["type"] != "object" {
+		t.Fatalf("unexpected response_format in %s", body)
+	}
+
+	body, _, err = New(Config{BaseURL: "https://api.openai.com/v1", Endpoint: "responses"}).buildPayload(req)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var responses struct {
+		Text struct {
+			Format struct {
+				Type   string         `json:"type"`
+				Schema map[string]any `json:"schema"`
+			} `json:"format"`
+		} `json:"text"`
+	}
+	_ = json.Unmarshal(body, &responses)
+	if responses.Text.Format.Type != "json_schema" || responses.Text.Format.Schema["type"] != "object" {
+		t.Fatalf("unexpected text.format in %s", body)
+	}
+}
+
+func TestToolCallRoundTrip(t *testing.T) {
+	resp, err := parseChatCompletions([]byte(`{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"run_python","arguments":"{\"code\":\"print(2+2)\"}"}}]}}]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "call_1" || resp.ToolCalls[0].Name != "run_python" {
+		t.Fatalf("unexpected tool calls %#v", resp.ToolCalls)
+	}
+
+	body, _, err := New(Config{BaseURL: "https://api.openai.com/v1"}).buildPayload(providers.ChatRequest{
+		Model:      "gpt-4.1",
+		UserPrompt: "what is 2+2",
+		Tools:      []providers.Tool{{Name: "run_python", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		Turns: []providers.ToolTurn{{
+			Calls:   resp.ToolCalls,
+			Results: []providers.ToolResult{{CallID: "call_1", Content: "4"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	var payload struct {
+		Tools []struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+		Messages []struct {
+			Role       string `json:"role"`
+			ToolCallID string `json:"tool_call_id"`
+			ToolCalls  []any  `json:"tool_calls"`
+		} `json:"messages"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	if len(payload.Tools) != 1 || payload.Tools[0].Function.Name != "run_python" {
+		t.Fatalf("unexpected tools in %s", body)
+	}
+	if len(payload.Messages) != 3 || len(payload.Messages[1].ToolCalls) != 1 || payload.Messages[2].Role != "tool" || payload.Messages[2].ToolCallID != "call_1" {
+		t.Fatalf("unexpected messages in %s", body)
+	}
+}
@@ -1,6 +1,14 @@
 package providers
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
 
 type ChatRequest struct {
 	Model        string
@@ -9,12 +17,58 @@ type ChatRequest struct {
 	MaxTokens    int
 	Temperature  float64
 	AllowTools   bool
+	// ReasoningEffort is passed to providers that support it ("low",
+	// "medium", "high"); empty leaves the provider default.
+	ReasoningEffort string
+	// ThinkingBudget enables extended thinking with this many tokens.
+	ThinkingBudget int
+	// ResponseFormat asks for structured output: ResponseFormatJSONObject or
+	// ResponseFormatJSONSchema with JSONSchema set. Empty means plain text.
+	ResponseFormat string
+	JSONSchema     json.RawMessage
+	// Tools are offered to the model by providers that support tool
+	// calling; others ignore them. Turns replays earlier rounds of the loop.
+	Tools []Tool
+	Turns []ToolTurn
+}
+
+// WantsJSON reports whether the request asks for a JSON answer.
+func (r ChatRequest) WantsJSON() bool {
+	return r.ResponseFormat == ResponseFormatJSONObject || r.ResponseFormat == ResponseFormatJSONSchema
 }
 
 type ChatResponse struct {
 	Text string
+	// Reasoning holds the model's thinking/reasoning text when the provider
+	// returns it separately from the answer.
+	Reasoning string
+	// Model is the model the provider reports having used, which may be more
+	// specific than the requested one. Empty if not reported.
+	Model string
+	Usage Usage
+	// Endpoint is the URL called and Attempts the number of HTTP calls made.
+	// Both are also set when Chat fails after building the request.
+	Endpoint string
+	Attempts int
+	// ToolCalls are the calls the model wants answered before it replies.
+	ToolCalls []ToolCall
+}
+
+// Usage is the token accounting reported by the provider; zero if unknown.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
 }
 
 type Provider interface {
 	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
 }
+
+// ModelLister is implemented by providers that expose a model catalogue.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitError is returned when a provider throttles the request. RetryAfter
+// is zero when the response carried no usable hint.
+type RateLimitError struct {
+	Status     int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("provider rate limited (status %d, retry after %s)", e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("provider rate limited (status %d)", e.Status)
+}
+
+// RetryAfter reads the standard Retry-After header and the common
+// x-ratelimit-reset variants and returns the longest wait they ask for.
+func RetryAfter(h http.Header, now time.Time) time.Duration {
+	var wait time.Duration
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			wait = maxDuration(wait, time.Duration(secs*float64(time.Second)))
+		} else if at, err := http.ParseTime(v); err == nil {
+			wait = maxDuration(wait, at.Sub(now))
+		}
+	}
+	if v := strings.TrimSpace(h.Get("Retry-After-Ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil {
+			wait = maxDuration(wait, time.Duration(ms*float64(time.Millisecond)))
+		}
+	}
+	// OpenAI-style resets are Go-like durations ("1s", "6m0s", "20ms").
+	for _, key := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if v := strings.TrimSpace(h.Get(key)); v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				wait = maxDuration(wait, d)
+			}
+		}
+	}
+	// Generic reset is either an epoch timestamp or a number of seconds.
+	if v := strings.TrimSpace(h.Get("X-Ratelimit-Reset")); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			if n > 1e9 {
+				wait = maxDuration(wait, time.Unix(int64(n), 0).Sub(now))
+			} else {
+				wait = maxDuration(wait, time.Duration(n*float64(time.Second)))
+			}
+		}
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// RetryDelay picks the wait before the next in-process attempt. A provider
+// hint longer than limit is not slept through; ok is false and the caller
+// should give up so the job can be rescheduled.
+func RetryDelay(err error, backoff, limit time.Duration) (wait time.Duration, ok bool) {
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter <= backoff {
+		return backoff, true
+	}
+	if limit > 0 && rl.RetryAfter > limit {
+		return 0, false
+	}
+	return rl.RetryAfter, true
+}
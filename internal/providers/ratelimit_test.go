@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 2, 13, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{"http date", map[string]string{"Retry-After": now.Add(5 * time.Second).Format(http.TimeFormat)}, 5 * time.Second},
+		{"openai reset", map[string]string{"x-ratelimit-reset-requests": "1s", "x-ratelimit-reset-tokens": "6m0s"}, 6 * time.Minute},
+		{"epoch reset", map[string]string{"X-RateLimit-Reset": "1770976810"}, 10 * time.Second},
+		{"past date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+	}
+	for _, tc := range cases {
+		h := http.Header{}
+		for k, v := range tc.headers {
+			h.Set(k, v)
+		}
+		if got := RetryAfter(h, now); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	backoff := 400 * time.Millisecond
+	if wait, ok := RetryDelay(errors.New("boom"), backoff, 30*time.Second); !ok || wait != backoff {
+		t.Fatalf("plain error: got %s ok=%v", wait, ok)
+	}
+	if wait, ok := RetryDelay(&RateLimitError{Status: 429, RetryAfter: 5 * time.Second}, backoff, 30*time.Second); !ok || wait != 5*time.Second {
+		t.Fatalf("hinted error: got %s ok=%v", wait, ok)
+	}
+	if _, ok := RetryDelay(&RateLimitError{Status: 429, RetryAfter: time.Minute}, backoff, 30*time.Second); ok {
+		t.Fatalf("expected hint above limit to stop in-process retries")
+	}
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// secretQueryParams are query parameter name fragments treated as
+// credentials, e.g. ?key=… for Gemini or ?sig=… for pre-signed URLs.
+var secretQueryParams = []string{"key", "token", "secret", "sig", "password", "auth", "credential"}
+
+// RedactURL masks the password and credential-looking query values of an
+// endpoint URL so it can be shown to users. Unparsable input is withheld.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid url)"
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			lower := strings.ToLower(name)
+			for _, s := range secretQueryParams {
+				if strings.Contains(lower, s) {
+					q.Set(name, "xxxxx")
+					break
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+var (
+	urlInText    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	bearerInText = regexp.MustCompile(`(?i)\bbearer\s+\S+`)
+	keyInText    = regexp.MustCompile(`\b(sk|xai|gsk)[-_][A-Za-z0-9_-]{8,}`)
+)
+
+// RedactText masks URLs, bearer tokens and key-shaped strings in free text
+// such as provider error messages before it is stored or shown.
+func RedactText(text string) string {
+	text = urlInText.ReplaceAllStringFunc(text, RedactURL)
+	text = bearerInText.ReplaceAllString(text, "Bearer xxxxx")
+	return keyInText.ReplaceAllString(text, "xxxxx")
+}
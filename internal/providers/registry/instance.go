@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"hyprbot/internal/crypto"
+	"hyprbot/internal/httpclient"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/custom_http"
+	"hyprbot/internal/storage"
+)
+
+// FromInstance decrypts a stored provider instance and builds it. Transport
+// and retry settings are taken from base.
+func FromInstance(p storage.ProviderInstance, cm *crypto.Manager, base BuildOptions) (providers.Provider, error) {
+	apiKey, err := decryptOptional(cm, p.EncAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt api key: %w", err)
+	}
+	headers := map[string]string{}
+	if raw, err := decryptOptional(cm, p.EncHeadersJSON); err != nil {
+		return nil, fmt.Errorf("decrypt headers: %w", err)
+	} else if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+			return nil, fmt.Errorf("parse headers json: %w", err)
+		}
+	}
+
+	providerCfg := map[string]any{}
+	if strings.TrimSpace(p.ConfigJSON) != "" {
+		if err := json.Unmarshal([]byte(p.ConfigJSON), &providerCfg); err != nil {
+			return nil, fmt.Errorf("parse provider config: %w", err)
+		}
+	}
+
+	var tlsMaterial httpclient.TLSMaterial
+	if _, err := decryptConfigJSON(cm, providerCfg, "enc_tls", &tlsMaterial); err != nil {
+		return nil, err
+	}
+	var signing custom_http.Signing
+	hasSigning, err := decryptConfigJSON(cm, providerCfg, "enc_signing", &signing)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := base
+	opts.Kind = p.Kind
+	opts.BaseURL = p.BaseURL
+	opts.APIKey = apiKey
+	opts.Headers = headers
+	opts.Config = providerCfg
+	opts.TLS = &tlsMaterial
+	if hasSigning {
+		opts.Signing = &signing
+	}
+	return Build(opts)
+}
+
+// MaxConcurrency returns the provider's max_concurrency setting, the number of
+// requests allowed in flight across all workers. Zero means unlimited.
+func MaxConcurrency(p storage.ProviderInstance) int64 {
+	providerCfg := map[string]any{}
+	if strings.TrimSpace(p.ConfigJSON) == "" || json.Unmarshal([]byte(p.ConfigJSON), &providerCfg) != nil {
+		return 0
+	}
+	return intOption(providerCfg, "max_concurrency")
+}
+
+func decryptOptional(cm *crypto.Manager, raw *string) (string, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return "", nil
+	}
+	return cm.UnmarshalEncryptedString(*raw)
+}
+
+// decryptConfigJSON decodes an encrypted JSON value stored under key in the
+// provider config. It reports false when the key is absent.
+func decryptConfigJSON(cm *crypto.Manager, providerCfg map[string]any, key string, out any) (bool, error) {
+	raw, _ := providerCfg[key].(string)
+	plain, err := decryptOptional(cm, &raw)
+	if err != nil {
+		return false, fmt.Errorf("decrypt provider %s: %w", key, err)
+	}
+	if strings.TrimSpace(plain) == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(plain), out); err != nil {
+		return false, fmt.Errorf("parse provider %s: %w", key, err)
+	}
+	return true, nil
+}
@@ -3,28 +3,45 @@ package registry
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"hyprbot/internal/httpclient"
 	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/anthropic_messages"
 	"hyprbot/internal/providers/custom_http"
 	"hyprbot/internal/providers/openai_compat"
 )
 
 type BuildOptions struct {
-	Kind        string
-	BaseURL     string
-	APIKey      string
-	Headers     map[string]string
-	Config      map[string]any
-	HTTPClient  *http.Client
-	MaxRetries  int
-	BackoffBase time.Duration
+	Kind          string
+	BaseURL       string
+	APIKey        string
+	Headers       map[string]string
+	Config        map[string]any
+	HTTPClient    *http.Client
+	MaxRetries    int
+	BackoffBase   time.Duration
+	MaxRetryAfter time.Duration
+	// TLS is the decrypted per-provider CA / client certificate, if any.
+	TLS *httpclient.TLSMaterial
+	// Signing is the decrypted custom_http request signing setup, if any.
+	Signing *custom_http.Signing
 }
 
 func Build(opts BuildOptions) (providers.Provider, error) {
 	if opts.Config == nil {
 		opts.Config = map[string]any{}
 	}
+	if opts.TLS != nil && !opts.TLS.Empty() {
+		c, err := httpclient.WithTLS(opts.HTTPClient, *opts.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("provider tls: %w", err)
+		}
+		opts.HTTPClient = c
+	}
+	maxRequest := intOption(opts.Config, "max_request_bytes")
+	maxResponse := intOption(opts.Config, "max_response_bytes")
 	switch opts.Kind {
 	case "openai_compat", "openai-compatible", "openai":
 		endpoint := "chat_completions"
@@ -32,13 +49,29 @@ func Build(opts BuildOptions) (providers.Provider, error) {
 			endpoint = v
 		}
 		return openai_compat.New(openai_compat.Config{
-			BaseURL:     opts.BaseURL,
-			APIKey:      opts.APIKey,
-			Headers:     opts.Headers,
-			Endpoint:    endpoint,
-			HTTPClient:  opts.HTTPClient,
-			MaxRetries:  opts.MaxRetries,
-			BackoffBase: opts.BackoffBase,
+			BaseURL:          opts.BaseURL,
+			APIKey:           opts.APIKey,
+			Headers:          opts.Headers,
+			Endpoint:         endpoint,
+			HTTPClient:       opts.HTTPClient,
+			MaxRetries:       opts.MaxRetries,
+			BackoffBase:      opts.BackoffBase,
+			MaxRetryAfter:    opts.MaxRetryAfter,
+			MaxRequestBytes:  maxRequest,
+			MaxResponseBytes: maxResponse,
+		}), nil
+
+	case "anthropic", "anthropic_messages":
+		return anthropic_messages.New(anthropic_messages.Config{
+			BaseURL:          opts.BaseURL,
+			APIKey:           opts.APIKey,
+			Headers:          opts.Headers,
+			HTTPClient:       opts.HTTPClient,
+			MaxRetries:       opts.MaxRetries,
+			BackoffBase:      opts.BackoffBase,
+			MaxRetryAfter:    opts.MaxRetryAfter,
+			MaxRequestBytes:  maxRequest,
+			MaxResponseBytes: maxResponse,
 		}), nil
 
 	case "custom_http", "custom-http":
@@ -48,20 +81,48 @@ func Build(opts BuildOptions) (providers.Provider, error) {
 		}
 		method := "POST"
 		if v, ok := opts.Config["method"].(string); ok && v != "" {
-			method = v
+			method = strings.ToUpper(v)
 		}
+		query := map[string]string{}
+		if raw, ok := opts.Config["query"].(map[string]any); ok {
+			for k, v := range raw {
+				if tpl, ok := v.(string); ok {
+					query[k] = tpl
+				}
+			}
+		}
+		responsePath, _ := opts.Config["response_path"].(string)
 		return custom_http.New(custom_http.Config{
-			URL:          opts.BaseURL,
-			APIKey:       opts.APIKey,
-			Headers:      opts.Headers,
-			BodyTemplate: bodyTemplate,
-			Method:       method,
-			HTTPClient:   opts.HTTPClient,
-			MaxRetries:   opts.MaxRetries,
-			BackoffBase:  opts.BackoffBase,
+			URL:              opts.BaseURL,
+			APIKey:           opts.APIKey,
+			Headers:          opts.Headers,
+			BodyTemplate:     bodyTemplate,
+			Method:           method,
+			HTTPClient:       opts.HTTPClient,
+			MaxRetries:       opts.MaxRetries,
+			BackoffBase:      opts.BackoffBase,
+			MaxRetryAfter:    opts.MaxRetryAfter,
+			MaxRequestBytes:  maxRequest,
+			MaxResponseBytes: maxResponse,
+			ResponsePath:     responsePath,
+			Signing:          opts.Signing,
+			Query:            query,
 		}), nil
 
 	default:
 		return nil, fmt.Errorf("unsupported provider kind %q", opts.Kind)
 	}
 }
+
+// intOption reads a numeric config_json value; JSON numbers decode as float64.
+func intOption(cfg map[string]any, key string) int64 {
+	switch v := cfg[key].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
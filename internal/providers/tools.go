@@ -0,0 +1,30 @@
+package providers
+
+import "encoding/json"
+
+// Tool is a function the model may call. Parameters is a JSON Schema object.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a call the model asked for; Arguments is the raw JSON object.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// ToolTurn is one round of a tool loop: the calls the model made, with the
+// text it sent alongside, and the results fed back to it.
+type ToolTurn struct {
+	Text    string
+	Calls   []ToolCall
+	Results []ToolResult
+}
+
+type ToolResult struct {
+	CallID  string
+	Content string
+}
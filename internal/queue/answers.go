@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FeedbackCallbackPrefix starts the data of the vote buttons under answers,
+// followed by "<job id>:up" or "<job id>:down".
+const FeedbackCallbackPrefix = "hb:fb:"
+
+// AnswerMeta describes an answer so a later vote can be attributed to the
+// preset and model that produced it, and to the A/B arm that chose the preset.
+type AnswerMeta struct {
+	ChatID     int64  `json:"chat_id"`
+	PresetName string `json:"preset_name"`
+	Model      string `json:"model"`
+	LatencyMs  int64  `json:"latency_ms"`
+	ABArm      string `json:"ab_arm,omitempty"`
+}
+
+// AnswerStore keeps AnswerMeta by job id while answers can be voted on.
+type AnswerStore struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewAnswerStore(rdb *redis.Client, ttl time.Duration) *AnswerStore {
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &AnswerStore{redis: rdb, ttl: ttl}
+}
+
+func (a *AnswerStore) Save(ctx context.Context, jobID string, meta AnswerMeta) error {
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("marshal answer meta: %w", err)
+	}
+	if err := a.redis.Set(ctx, answerKey(jobID), payload, a.ttl).Err(); err != nil {
+		return fmt.Errorf("save answer meta: %w", err)
+	}
+	return nil
+}
+
+func (a *AnswerStore) Get(ctx context.Context, jobID string) (AnswerMeta, bool, error) {
+	raw, err := a.redis.Get(ctx, answerKey(jobID)).Bytes()
+	if err == redis.Nil {
+		return AnswerMeta{}, false, nil
+	}
+	if err != nil {
+		return AnswerMeta{}, false, fmt.Errorf("get answer meta: %w", err)
+	}
+	var meta AnswerMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return AnswerMeta{}, false, fmt.Errorf("decode answer meta: %w", err)
+	}
+	return meta, true, nil
+}
+
+func answerKey(jobID string) string {
+	return "hyprbot:answer:" + jobID
+}
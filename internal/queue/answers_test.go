@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAnswerStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	answers := NewAnswerStore(rdb, time.Hour)
+
+	if _, found, err := answers.Get(ctx, "missing"); err != nil || found {
+		t.Fatalf("expected no meta, got found=%v err=%v", found, err)
+	}
+	want := AnswerMeta{ChatID: -100, PresetName: "coder", Model: "gpt-4o", LatencyMs: 1200}
+	if err := answers.Save(ctx, "job1", want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, found, err := answers.Get(ctx, "job1")
+	if err != nil || !found || got != want {
+		t.Fatalf("get: %+v found=%v err=%v", got, found, err)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if _, found, _ := answers.Get(ctx, "job1"); found {
+		t.Fatal("meta must expire after the ttl")
+	}
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs are tracked by (chat, prompt message) for a short window so an edited
+// prompt can replace a job that no worker has started yet. The tracking key
+// holds the stream entry id; cancellation swaps it for a tombstone.
+const canceledMarker = "canceled"
+
+// cancelScript removes a tracked entry that has not been claimed and returns
+// its fields. The tombstone keeps the remaining TTL so a worker that already
+// read the entry still sees it was canceled.
+var cancelScript = redis.NewScript(`
+local id = redis.call("GET", KEYS[1])
+if not id or id == ARGV[1] then
+  return false
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl <= 0 then
+  return false
+end
+local entries = redis.call("XRANGE", KEYS[2], id, id)
+redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+redis.call("XDEL", KEYS[2], id)
+if #entries == 0 then
+  return false
+end
+return entries[1][2]
+`)
+
+// claimScript lets a worker take a tracked entry. Untracked entries (window
+// expired, or re-enqueued retries) are always claimable.
+var claimScript = redis.NewScript(`
+local id = redis.call("GET", KEYS[1])
+if not id then
+  return 1
+end
+if id ~= ARGV[1] then
+  return 0
+end
+redis.call("DEL", KEYS[1])
+return 1
+`)
+
+// Track makes the entry cancelable via Cancel for window.
+func (q *StreamQueue) Track(ctx context.Context, job AskJob, entryID string, window time.Duration) error {
+	if window <= 0 || job.MessageID <= 0 {
+		return nil
+	}
+	if err := q.redis.Set(ctx, q.trackKey(job.ChatID, job.MessageID), entryID, window).Err(); err != nil {
+		return fmt.Errorf("track job: %w", err)
+	}
+	return nil
+}
+
+// Cancel drops the queued job for the prompt message. It reports false when
+// there is nothing to cancel: the job was never tracked, the window passed,
+// or a worker already claimed it.
+func (q *StreamQueue) Cancel(ctx context.Context, chatID, messageID int64) (AskJob, bool, error) {
+	fields, err := cancelScript.Run(ctx, q.redis, []string{q.trackKey(chatID, messageID), q.stream}, canceledMarker).StringSlice()
+	if err == redis.Nil {
+		return AskJob{}, false, nil
+	}
+	if err != nil {
+		return AskJob{}, false, fmt.Errorf("cancel job: %w", err)
+	}
+	values := make(map[string]any, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		values[fields[i]] = fields[i+1]
+	}
+	env, err := envelopeFromValues(values)
+	if err != nil {
+		return AskJob{}, false, fmt.Errorf("decode canceled job: %w", err)
+	}
+	job, err := DecodeAskJob(env.Payload)
+	if err != nil {
+		return AskJob{}, false, fmt.Errorf("decode canceled job: %w", err)
+	}
+	return job, true, nil
+}
+
+// Claim reports whether the worker should run msg. It is false when the job
+// was canceled or replaced by an edit after it was read from the stream.
+func (q *StreamQueue) Claim(ctx context.Context, msg Message) (bool, error) {
+	if msg.Job.MessageID <= 0 {
+		return true, nil
+	}
+	n, err := claimScript.Run(ctx, q.redis, []string{q.trackKey(msg.Job.ChatID, msg.Job.MessageID)}, msg.ID).Int()
+	if err != nil {
+		return true, fmt.Errorf("claim job: %w", err)
+	}
+	return n == 1, nil
+}
+
+func (q *StreamQueue) trackKey(chatID, messageID int64) string {
+	return fmt.Sprintf("%s:msg:%d:%d", q.stream, chatID, messageID)
+}
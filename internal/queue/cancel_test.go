@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamQueueCancel(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewStreamQueue(rdb, "jobs", "workers", "w1", 0)
+	if err := q.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+
+	job := AskJob{ChatID: -100, MessageID: 7, Prompt: "hello", StatusMessageID: 8}
+	id, err := q.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Track(ctx, job, id, time.Minute); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+
+	// The worker read the entry but has not claimed it yet.
+	msgs, err := q.Read(ctx, 1)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("read: %v %v", msgs, err)
+	}
+
+	canceled, ok, err := q.Cancel(ctx, -100, 7)
+	if err != nil || !ok {
+		t.Fatalf("cancel: ok=%v err=%v", ok, err)
+	}
+	if canceled.Prompt != "hello" || canceled.StatusMessageID != 8 {
+		t.Fatalf("unexpected canceled job %+v", canceled)
+	}
+	if _, ok, _ := q.Cancel(ctx, -100, 7); ok {
+		t.Fatal("second cancel must report nothing to cancel")
+	}
+	if claimed, err := q.Claim(ctx, msgs[0]); err != nil || claimed {
+		t.Fatalf("canceled job must not be claimable: claimed=%v err=%v", claimed, err)
+	}
+
+	// The edited job replaces the tombstone and is claimable.
+	job.Prompt = "hello again"
+	id2, err := q.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatalf("enqueue edited: %v", err)
+	}
+	if err := q.Track(ctx, job, id2, time.Minute); err != nil {
+		t.Fatalf("track edited: %v", err)
+	}
+	if claimed, _ := q.Claim(ctx, msgs[0]); claimed {
+		t.Fatal("replaced job must not be claimable")
+	}
+	msgs, err = q.Read(ctx, 1)
+	if err != nil || len(msgs) != 1 || msgs[0].Job.Prompt != "hello again" {
+		t.Fatalf("read edited: %v %v", msgs, err)
+	}
+	if claimed, err := q.Claim(ctx, msgs[0]); err != nil || !claimed {
+		t.Fatalf("claim edited: claimed=%v err=%v", claimed, err)
+	}
+	if _, ok, _ := q.Cancel(ctx, -100, 7); ok {
+		t.Fatal("claimed job must not be cancelable")
+	}
+
+	// Untracked jobs, e.g. after the window, are always claimable.
+	if claimed, err := q.Claim(ctx, Message{ID: "1-1", Job: AskJob{ChatID: 1, MessageID: 2}}); err != nil || !claimed {
+		t.Fatalf("claim untracked: claimed=%v err=%v", claimed, err)
+	}
+}
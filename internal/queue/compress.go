@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Payload encodings recorded in a stream entry's "enc" field. Entries
+// without the field are plain JSON.
+const (
+	EncodingIdentity = ""
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+// maxDecodedPayload bounds decompression so a corrupt or hostile entry
+// cannot exhaust worker memory.
+const maxDecodedPayload = 64 << 20
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecodedPayload))
+)
+
+// Compression makes Enqueue compress payloads of at least MinBytes. Enable
+// it only once every worker runs a release that understands Encoding.
+type Compression struct {
+	Encoding string
+	MinBytes int
+}
+
+func ParseEncoding(s string) (string, error) {
+	switch s {
+	case "", "off", "none":
+		return EncodingIdentity, nil
+	case EncodingGzip, EncodingZstd:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown payload encoding %q (want off, gzip or zstd)", s)
+}
+
+func compressPayload(encoding string, payload []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingIdentity:
+		return payload, nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, fmt.Errorf("gzip payload: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip payload: %w", err)
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(payload, nil), nil
+	}
+	return nil, fmt.Errorf("unknown payload encoding %q", encoding)
+}
+
+func decompressPayload(encoding string, payload []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingIdentity:
+		return payload, nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("gunzip payload: %w", err)
+		}
+		defer zr.Close()
+		out, err := io.ReadAll(io.LimitReader(zr, maxDecodedPayload+1))
+		if err != nil {
+			return nil, fmt.Errorf("gunzip payload: %w", err)
+		}
+		if len(out) > maxDecodedPayload {
+			return nil, fmt.Errorf("gunzip payload: exceeds %d bytes", maxDecodedPayload)
+		}
+		return out, nil
+	case EncodingZstd:
+		out, err := zstdDecoder.DecodeAll(payload, nil)
+		if err != nil {
+			return nil, fmt.Errorf("zstd payload: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown payload encoding %q", encoding)
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamQueueCompression(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	prompt := strings.Repeat("retrieved context ", 500)
+
+	for _, enc := range []string{EncodingGzip, EncodingZstd} {
+		stream := "jobs:" + enc
+		q := NewStreamQueue(rdb, stream, "workers", "w1", 0)
+		q.SetCompression(Compression{Encoding: enc, MinBytes: 1024})
+		if err := q.EnsureGroup(ctx); err != nil {
+			t.Fatalf("ensure group: %v", err)
+		}
+
+		if _, err := q.Enqueue(ctx, AskJob{ChatID: -100, MessageID: 1, Prompt: prompt}); err != nil {
+			t.Fatalf("%s: enqueue: %v", enc, err)
+		}
+		if _, err := q.Enqueue(ctx, AskJob{ChatID: -100, MessageID: 2, Prompt: "short"}); err != nil {
+			t.Fatalf("%s: enqueue short: %v", enc, err)
+		}
+		entries, err := rdb.XRange(ctx, stream, "-", "+").Result()
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("%s: xrange: %v %v", enc, entries, err)
+		}
+		if entries[0].Values["enc"] != enc || len(entries[0].Values["payload"].(string)) >= len(prompt) {
+			t.Fatalf("%s: large payload must be stored compressed", enc)
+		}
+		if _, ok := entries[1].Values["enc"]; ok {
+			t.Fatalf("%s: payloads under MinBytes must stay plain", enc)
+		}
+
+		msgs, err := q.Read(ctx, 2)
+		if err != nil || len(msgs) != 2 {
+			t.Fatalf("%s: read: %v %v", enc, msgs, err)
+		}
+		if msgs[0].Err != nil || msgs[0].Job.Prompt != prompt || msgs[1].Job.Prompt != "short" {
+			t.Fatalf("%s: payloads must decode transparently: %v", enc, msgs[0].Err)
+		}
+
+		// Cancel decodes the compressed entry too.
+		job := AskJob{ChatID: -100, MessageID: 3, Prompt: prompt}
+		id, err := q.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatalf("%s: enqueue tracked: %v", enc, err)
+		}
+		if err := q.Track(ctx, job, id, time.Minute); err != nil {
+			t.Fatalf("%s: track: %v", enc, err)
+		}
+		canceled, ok, err := q.Cancel(ctx, -100, 3)
+		if err != nil || !ok || canceled.Prompt != prompt {
+			t.Fatalf("%s: cancel: ok=%v err=%v", enc, ok, err)
+		}
+	}
+}
+
+func TestDecompressPayloadRejectsGarbage(t *testing.T) {
+	for _, enc := range []string{EncodingGzip, EncodingZstd, "brotli"} {
+		if _, err := decompressPayload(enc, []byte("not compressed")); err == nil {
+			t.Fatalf("%s: garbage must not decode", enc)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reclaimBatch is how many pending entries one XPENDING/XCLAIM round moves.
+const reclaimBatch = 100
+
+// RemovedConsumer is a consumer dropped by RemoveDeadConsumers with the
+// number of its pending entries put back on the stream.
+type RemovedConsumer struct {
+	Name     string
+	Idle     time.Duration
+	Requeued int
+}
+
+// RemoveDeadConsumers deletes the group's consumers idle for at least
+// idleAfter, other than this one and those keep reports as alive. Their
+// pending entries are claimed and added to the stream again, so other
+// consumers pick them up, before the consumer is deleted with its empty
+// pending list. Names come from hostnames, so without this every redeploy
+// leaves one behind.
+func (q *StreamQueue) RemoveDeadConsumers(ctx context.Context, idleAfter time.Duration, keep func(name string) bool) ([]RemovedConsumer, error) {
+	consumers, err := q.redis.XInfoConsumers(ctx, q.stream, q.group).Result()
+	if err != nil {
+		return nil, fmt.Errorf("xinfo consumers: %w", err)
+	}
+	var removed []RemovedConsumer
+	for _, c := range consumers {
+		if c.Name == q.consumer || c.Idle < idleAfter || (keep != nil && keep(c.Name)) {
+			continue
+		}
+		requeued, err := q.requeuePending(ctx, c.Name, idleAfter)
+		if err != nil {
+			return removed, err
+		}
+		// A consumer that came back meanwhile is no longer idle; deleting
+		// it would drop the entries it just read.
+		if idle, err := q.consumerIdle(ctx, c.Name); err != nil || idle < idleAfter {
+			continue
+		}
+		if err := q.redis.XGroupDelConsumer(ctx, q.stream, q.group, c.Name).Err(); err != nil {
+			return removed, fmt.Errorf("delete consumer %s: %w", c.Name, err)
+		}
+		removed = append(removed, RemovedConsumer{Name: c.Name, Idle: c.Idle, Requeued: requeued})
+	}
+	return removed, nil
+}
+
+// requeuePending moves the pending entries of consumer to the end of the
+// stream. XCLAIM with minIdle ensures a concurrent cleanup on another
+// replica cannot take the same entry twice.
+func (q *StreamQueue) requeuePending(ctx context.Context, consumer string, minIdle time.Duration) (int, error) {
+	requeued := 0
+	for {
+		pending, err := q.pendingOf(ctx, consumer, reclaimBatch)
+		if err != nil || len(pending) == 0 {
+			return requeued, err
+		}
+		ids := make([]string, len(pending))
+		for i, p := range pending {
+			ids[i] = p.ID
+		}
+		claimed, err := q.redis.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   q.stream,
+			Group:    q.group,
+			Consumer: q.consumer,
+			MinIdle:  minIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			return requeued, fmt.Errorf("xclaim: %w", err)
+		}
+		if len(claimed) == 0 {
+			// Another replica claimed them, or the entries were trimmed and
+			// only their pending records are left; deleting the consumer
+			// drops those.
+			return requeued, nil
+		}
+		_, err = q.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, msg := range claimed {
+				pipe.XAdd(ctx, &redis.XAddArgs{Stream: q.stream, Values: msg.Values})
+				pipe.XAck(ctx, q.stream, q.group, msg.ID)
+				pipe.XDel(ctx, q.stream, msg.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return requeued, fmt.Errorf("requeue pending entries: %w", err)
+		}
+		requeued += len(claimed)
+		if len(claimed) < len(ids) {
+			return requeued, nil
+		}
+	}
+}
+
+// consumerIdle is how long consumer has not read from the group; a missing
+// consumer counts as idle forever.
+func (q *StreamQueue) consumerIdle(ctx context.Context, consumer string) (time.Duration, error) {
+	consumers, err := q.redis.XInfoConsumers(ctx, q.stream, q.group).Result()
+	if err != nil {
+		return 0, fmt.Errorf("xinfo consumers: %w", err)
+	}
+	for _, c := range consumers {
+		if c.Name == consumer {
+			return c.Idle, nil
+		}
+	}
+	return time.Duration(math.MaxInt64), nil
+}
+
+func (q *StreamQueue) pendingOf(ctx context.Context, consumer string, count int64) ([]redis.XPendingExt, error) {
+	pending, err := q.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   q.stream,
+		Group:    q.group,
+		Start:    "-",
+		End:      "+",
+		Count:    count,
+		Consumer: consumer,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("xpending: %w", err)
+	}
+	return pending, nil
+}
@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRemoveDeadConsumersRequeuesPending(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	dead := NewStreamQueue(rdb, "jobs", "workers", "old-host", 0)
+	busy := NewStreamQueue(rdb, "jobs", "workers", "busy-host", 0)
+	q := NewStreamQueue(rdb, "jobs", "workers", "new-host", 0)
+	if err := q.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue(ctx, AskJob{JobID: id, ChatID: -100, Prompt: "hi"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if msgs, err := dead.Read(ctx, 2); err != nil || len(msgs) != 2 {
+		t.Fatalf("dead read: %v %v", msgs, err)
+	}
+	if msgs, err := busy.Read(ctx, 1); err != nil || len(msgs) != 1 {
+		t.Fatalf("busy read: %v %v", msgs, err)
+	}
+
+	// miniredis tracks consumer idle time only on XCLAIM; a claim of a
+	// missing entry stamps it without moving anything.
+	start := time.Now()
+	mr.SetTime(start)
+	for _, name := range []string{"old-host", "busy-host"} {
+		if err := rdb.XClaim(ctx, &redis.XClaimArgs{Stream: "jobs", Group: "workers", Consumer: name, Messages: []string{"1-0"}}).Err(); err != nil {
+			t.Fatalf("stamp %s: %v", name, err)
+		}
+	}
+	mr.SetTime(start.Add(2 * time.Hour))
+	removed, err := q.RemoveDeadConsumers(ctx, time.Hour, func(name string) bool { return name == "busy-host" })
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != "old-host" || removed[0].Requeued != 2 {
+		t.Fatalf("unexpected removed consumers %+v", removed)
+	}
+
+	consumers, err := rdb.XInfoConsumers(ctx, "jobs", "workers").Result()
+	if err != nil {
+		t.Fatalf("xinfo: %v", err)
+	}
+	for _, c := range consumers {
+		if c.Name == "old-host" {
+			t.Fatal("dead consumer still in the group")
+		}
+	}
+	msgs, err := q.Read(ctx, 10)
+	if err != nil || len(msgs) != 2 || msgs[0].Job.JobID != "a" || msgs[1].Job.JobID != "b" {
+		t.Fatalf("expected requeued jobs a and b, got %+v %v", msgs, err)
+	}
+	if d, err := q.Depth(ctx); err != nil || d.Pending != 3 {
+		t.Fatalf("expected busy entry and the two requeued ones pending, got %+v %v", d, err)
+	}
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultDedupeBucketSize keeps each bucket within Redis's default
+// hash-max-listpack-entries, so buckets stay in the compact encoding.
+const DefaultDedupeBucketSize = 128
+
+// markUpdateScript records an update in its bucket and pushes the bucket's
+// expiry out, so every entry lives at least the TTL after it was added.
+var markUpdateScript = redis.NewScript(`
+local added = redis.call("HSETNX", KEYS[1], ARGV[1], "1")
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return added
+`)
+
+// UpdateDeduplicator drops updates Telegram delivers twice. Update ids grow
+// by one per update, so consecutive ids share a hash bucket: one Redis key
+// per bucketSize updates instead of one per update. Lookups stay exact.
+type UpdateDeduplicator struct {
+	redis      *redis.Client
+	ttl        time.Duration
+	bucketSize int64
+}
+
+func NewUpdateDeduplicator(rdb *redis.Client, ttl time.Duration, bucketSize int64) *UpdateDeduplicator {
+	if bucketSize < 1 {
+		bucketSize = DefaultDedupeBucketSize
+	}
+	return &UpdateDeduplicator{redis: rdb, ttl: ttl, bucketSize: bucketSize}
+}
+
+// MarkFirst reports whether updateID is seen for the first time.
+func (d *UpdateDeduplicator) MarkFirst(ctx context.Context, updateID int64) (bool, error) {
+	bucket, field := updateID/d.bucketSize, updateID%d.bucketSize
+	key := fmt.Sprintf("hyprbot:updates:%d:%d", d.bucketSize, bucket)
+	ttl := max(d.ttl.Milliseconds(), 1)
+	added, err := markUpdateScript.Run(ctx, d.redis, []string{key}, strconv.FormatInt(field, 10), ttl).Int64()
+	if err != nil {
+		return false, fmt.Errorf("dedupe update: %w", err)
+	}
+	return added == 1, nil
+}
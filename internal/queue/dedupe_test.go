@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUpdateDeduplicatorBuckets(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	d := NewUpdateDeduplicator(rdb, time.Hour, 4)
+	for id := int64(100); id < 108; id++ {
+		first, err := d.MarkFirst(ctx, id)
+		if err != nil || !first {
+			t.Fatalf("update %d: first=%v err=%v", id, first, err)
+		}
+	}
+	if first, err := d.MarkFirst(ctx, 105); err != nil || first {
+		t.Fatalf("redelivered update: first=%v err=%v", first, err)
+	}
+	if keys := mr.Keys(); len(keys) != 2 {
+		t.Fatalf("expected 2 bucket keys for 8 updates, got %v", keys)
+	}
+
+	// A bucket lives the TTL after its last update.
+	mr.FastForward(50 * time.Minute)
+	if _, err := d.MarkFirst(ctx, 108); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	mr.FastForward(20 * time.Minute)
+	if first, _ := d.MarkFirst(ctx, 101); !first {
+		t.Fatal("expected expired bucket to forget update 101")
+	}
+	if first, _ := d.MarkFirst(ctx, 108); first {
+		t.Fatal("expected fresh bucket to remember update 108")
+	}
+}
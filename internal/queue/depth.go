@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Depth is the stream backlog as seen by the consumer group. Acked entries
+// are deleted, so the stream only holds jobs nobody read yet (Waiting) and
+// jobs a worker read but has not finished (Pending).
+type Depth struct {
+	Waiting int64 `json:"waiting"`
+	Pending int64 `json:"pending"`
+}
+
+func (q *StreamQueue) Depth(ctx context.Context) (Depth, error) {
+	length, err := q.redis.XLen(ctx, q.stream).Result()
+	if err != nil {
+		return Depth{}, fmt.Errorf("xlen: %w", err)
+	}
+	var pending int64
+	summary, err := q.redis.XPending(ctx, q.stream, q.group).Result()
+	switch {
+	case err == nil:
+		pending = summary.Count
+	case err == redis.Nil, strings.Contains(err.Error(), "NOGROUP"):
+		// No group yet means nothing was read either.
+	default:
+		return Depth{}, fmt.Errorf("xpending: %w", err)
+	}
+	// Entries canceled after a read are deleted but stay pending until the
+	// worker acks them, so the difference can briefly go negative.
+	return Depth{Waiting: max(length-pending, 0), Pending: pending}, nil
+}
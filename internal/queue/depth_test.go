@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamQueueDepth(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewStreamQueue(rdb, "jobs", "workers", "w1", 0)
+	if d, err := q.Depth(ctx); err != nil || d != (Depth{}) {
+		t.Fatalf("depth of missing stream: %+v %v", d, err)
+	}
+	if err := q.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := q.Enqueue(ctx, AskJob{ChatID: -100, Prompt: "hi"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	msgs, err := q.Read(ctx, 1)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("read: %v %v", msgs, err)
+	}
+	if d, err := q.Depth(ctx); err != nil || d != (Depth{Waiting: 2, Pending: 1}) {
+		t.Fatalf("depth after read: %+v %v", d, err)
+	}
+
+	if err := q.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if d, err := q.Depth(ctx); err != nil || d != (Depth{Waiting: 2}) {
+		t.Fatalf("depth after ack: %+v %v", d, err)
+	}
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// JobType names the payload carried by a stream entry. Entries written before
+// job types existed have no type field and decode as JobTypeAsk.
+type JobType string
+
+const JobTypeAsk JobType = "ask"
+
+// Envelope is a typed job as stored in a stream entry: the type and version
+// sit in their own fields next to the JSON payload, so workers from before
+// the envelope still find an AskJob under "payload". Payload is always the
+// decoded JSON; compression only applies to the stored entry.
+type Envelope struct {
+	Type    JobType
+	Version int
+	Payload json.RawMessage
+}
+
+func (e Envelope) values(c Compression) (map[string]any, error) {
+	values := map[string]any{
+		"type": string(e.Type),
+		"v":    strconv.Itoa(e.Version),
+	}
+	payload := []byte(e.Payload)
+	if c.Encoding != EncodingIdentity && len(payload) >= c.MinBytes {
+		compressed, err := compressPayload(c.Encoding, payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = compressed
+		values["enc"] = c.Encoding
+	}
+	values["payload"] = payload
+	return values, nil
+}
+
+func envelopeFromValues(values map[string]any) (Envelope, error) {
+	payload, ok := fieldBytes(values["payload"])
+	if !ok {
+		return Envelope{}, fmt.Errorf("entry has no payload")
+	}
+	if enc, ok := fieldBytes(values["enc"]); ok {
+		decoded, err := decompressPayload(string(enc), payload)
+		if err != nil {
+			return Envelope{}, err
+		}
+		payload = decoded
+	}
+	env := Envelope{Type: JobTypeAsk, Version: 1, Payload: payload}
+	if raw, ok := fieldBytes(values["type"]); ok && len(raw) > 0 {
+		env.Type = JobType(raw)
+	}
+	if raw, ok := fieldBytes(values["v"]); ok {
+		v, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return Envelope{}, fmt.Errorf("invalid job version %q", raw)
+		}
+		env.Version = v
+	}
+	return env, nil
+}
+
+func fieldBytes(raw any) ([]byte, bool) {
+	switch v := raw.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
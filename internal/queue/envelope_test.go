@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamQueueEnvelopes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewStreamQueue(rdb, "jobs", "workers", "w1", 0)
+	if err := q.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+
+	// Entries from before the envelope carry only the AskJob payload.
+	legacy := `{"job_id":"old","chat_id":-100,"prompt":"hi"}`
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "jobs", Values: map[string]any{"payload": legacy}}).Err(); err != nil {
+		t.Fatalf("xadd legacy: %v", err)
+	}
+	if _, err := q.Enqueue(ctx, AskJob{JobID: "new", ChatID: -100, Prompt: "hello"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := q.EnqueueEnvelope(ctx, Envelope{Type: "image", Version: 2, Payload: []byte(`{"size":"1024x1024"}`)}); err != nil {
+		t.Fatalf("enqueue envelope: %v", err)
+	}
+
+	msgs, err := q.Read(ctx, 10)
+	if err != nil || len(msgs) != 3 {
+		t.Fatalf("read: %v %v", msgs, err)
+	}
+	if msgs[0].Envelope.Type != JobTypeAsk || msgs[0].Envelope.Version != 1 || msgs[0].Job.JobID != "old" {
+		t.Fatalf("legacy entry must decode as an ask job: %+v", msgs[0])
+	}
+	if msgs[1].Envelope.Version != AskJobVersion || msgs[1].Job.Prompt != "hello" {
+		t.Fatalf("unexpected ask entry %+v", msgs[1])
+	}
+	if msgs[2].Envelope.Type != "image" || msgs[2].Envelope.Version != 2 || string(msgs[2].Envelope.Payload) != `{"size":"1024x1024"}` || msgs[2].Job.JobID != "" {
+		t.Fatalf("unexpected typed entry %+v", msgs[2])
+	}
+
+	if q.ForType(JobTypeAsk) != q {
+		t.Fatal("ask jobs must stay on the base stream")
+	}
+	images := q.ForType("image")
+	if err := images.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure image group: %v", err)
+	}
+	if _, err := images.EnqueueEnvelope(ctx, Envelope{Type: "image", Version: 1, Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("enqueue image: %v", err)
+	}
+	if n, _ := rdb.XLen(ctx, "jobs:image").Result(); n != 1 {
+		t.Fatalf("image stream length = %d, want 1", n)
+	}
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const ephemeralKey = "hyprbot:ephemeral"
+
+// popDueScript removes and returns up to ARGV[2] members due by ARGV[1], so
+// each message is handed to exactly one replica.
+var popDueScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+if #due > 0 then
+  redis.call("ZREM", KEYS[1], unpack(due))
+end
+return due
+`)
+
+// EphemeralMessage is a bot message that should disappear after a while,
+// like a rate limit warning.
+type EphemeralMessage struct {
+	ChatID    int64
+	MessageID int64
+}
+
+// EphemeralStore schedules bot messages for deletion in one sorted set
+// scored by due time, so any replica can run the cleanup.
+type EphemeralStore struct {
+	redis *redis.Client
+}
+
+func NewEphemeralStore(rdb *redis.Client) *EphemeralStore {
+	return &EphemeralStore{redis: rdb}
+}
+
+func (s *EphemeralStore) Schedule(ctx context.Context, m EphemeralMessage, at time.Time) error {
+	member := fmt.Sprintf("%d:%d", m.ChatID, m.MessageID)
+	if err := s.redis.ZAdd(ctx, ephemeralKey, redis.Z{Score: float64(at.Unix()), Member: member}).Err(); err != nil {
+		return fmt.Errorf("schedule ephemeral message: %w", err)
+	}
+	return nil
+}
+
+// Due removes and returns up to limit messages due by now.
+func (s *EphemeralStore) Due(ctx context.Context, now time.Time, limit int) ([]EphemeralMessage, error) {
+	members, err := popDueScript.Run(ctx, s.redis, []string{ephemeralKey}, now.Unix(), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("pop due ephemeral messages: %w", err)
+	}
+	out := make([]EphemeralMessage, 0, len(members))
+	for _, member := range members {
+		chat, msg, ok := strings.Cut(member, ":")
+		if !ok {
+			continue
+		}
+		chatID, err1 := strconv.ParseInt(chat, 10, 64)
+		msgID, err2 := strconv.ParseInt(msg, 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, EphemeralMessage{ChatID: chatID, MessageID: msgID})
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestEphemeralStoreDue(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	store := NewEphemeralStore(rdb)
+	now := time.Now()
+
+	_ = store.Schedule(ctx, EphemeralMessage{ChatID: -100, MessageID: 1}, now.Add(-time.Second))
+	_ = store.Schedule(ctx, EphemeralMessage{ChatID: -100, MessageID: 2}, now)
+	_ = store.Schedule(ctx, EphemeralMessage{ChatID: 5, MessageID: 3}, now.Add(time.Minute))
+
+	due, err := store.Due(ctx, now, 1)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 1 || due[0] != (EphemeralMessage{ChatID: -100, MessageID: 1}) {
+		t.Fatalf("due = %+v, want the oldest message first", due)
+	}
+	due, _ = store.Due(ctx, now, 10)
+	if len(due) != 1 || due[0].MessageID != 2 {
+		t.Fatalf("due = %+v, want message 2", due)
+	}
+	if due, _ := store.Due(ctx, now, 10); len(due) != 0 {
+		t.Fatalf("due messages must be handed out once, got %+v", due)
+	}
+	due, _ = store.Due(ctx, now.Add(time.Minute), 10)
+	if len(due) != 1 || due[0] != (EphemeralMessage{ChatID: 5, MessageID: 3}) {
+		t.Fatalf("due = %+v, want message 3", due)
+	}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateAnswering = "answering"
+	JobStateDone      = "done"
+	JobStateFailed    = "failed"
+)
+
+type JobEvent struct {
+	JobID           string    `json:"job_id"`
+	ChatID          int64     `json:"chat_id"`
+	StatusMessageID int64     `json:"status_message_id"`
+	State           string    `json:"state"`
+	Attempt         int       `json:"attempt"`
+	At              time.Time `json:"at"`
+}
+
+// eventClaimTTL keeps an event claimed long enough for every ingress node
+// to have received it.
+const eventClaimTTL = time.Minute
+
+type EventBus struct {
+	redis   *redis.Client
+	channel string
+}
+
+func NewEventBus(rdb *redis.Client, channel string) *EventBus {
+	return &EventBus{redis: rdb, channel: channel}
+}
+
+func (b *EventBus) Publish(ctx context.Context, ev JobEvent) error {
+	if b == nil {
+		return nil
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal job event: %w", err)
+	}
+	if err := b.redis.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("publish job event: %w", err)
+	}
+	return nil
+}
+
+// Claim reports whether this node is the first to take ev. Every ingress
+// node receives every event; only the one that claims it touches the
+// status message.
+func (b *EventBus) Claim(ctx context.Context, ev JobEvent) (bool, error) {
+	key := fmt.Sprintf("%s:claim:%s:%s:%d", b.channel, ev.JobID, ev.State, ev.Attempt)
+	ok, err := b.redis.SetNX(ctx, key, "1", eventClaimTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("claim job event: %w", err)
+	}
+	return ok, nil
+}
+
+func (b *EventBus) Subscribe(ctx context.Context, fn func(JobEvent)) error {
+	if b == nil {
+		return fmt.Errorf("event bus is nil")
+	}
+	sub := b.redis.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe job events: %w", err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var ev JobEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+				continue
+			}
+			fn(ev)
+		}
+	}
+}
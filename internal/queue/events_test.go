@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestEventBusPublishSubscribe(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	bus := NewEventBus(rdb, "hyprbot:events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan JobEvent, 1)
+	go func() {
+		_ = bus.Subscribe(ctx, func(ev JobEvent) { got <- ev })
+	}()
+
+	// Publish until the subscriber is attached; pub/sub drops messages sent
+	// before SUBSCRIBE completes.
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if err := bus.Publish(ctx, JobEvent{JobID: "j1", ChatID: -100, StatusMessageID: 7, State: JobStateRunning}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		select {
+		case ev := <-got:
+			if ev.JobID != "j1" || ev.StatusMessageID != 7 || ev.State != JobStateRunning {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+			if ev.At.IsZero() {
+				t.Fatalf("expected publish to stamp event time")
+			}
+			return
+		case <-ticker.C:
+		case <-ctx.Done():
+			t.Fatalf("event not received")
+		}
+	}
+}
+
+func TestEventBusClaim(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	// Two ingress nodes share the channel and the claims.
+	a, b := NewEventBus(rdb, "hyprbot:events"), NewEventBus(rdb, "hyprbot:events")
+	ctx := context.Background()
+	ev := JobEvent{JobID: "j1", ChatID: -100, StatusMessageID: 7, State: JobStateRunning}
+
+	if ok, err := a.Claim(ctx, ev); err != nil || !ok {
+		t.Fatalf("first claim = %v %v, want true", ok, err)
+	}
+	if ok, err := b.Claim(ctx, ev); err != nil || ok {
+		t.Fatalf("second claim = %v %v, want false", ok, err)
+	}
+	ev.State = JobStateDone
+	if ok, _ := b.Claim(ctx, ev); !ok {
+		t.Fatal("expected the next state to be claimable")
+	}
+	mr.FastForward(eventClaimTTL + time.Second)
+	ev.State = JobStateRunning
+	if ok, _ := b.Claim(ctx, ev); !ok {
+		t.Fatal("expected the claim to expire")
+	}
+}
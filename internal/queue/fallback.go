@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const fallbackPresetKey = "hyprbot:fallback_preset"
+
+// FallbackPreset names the preset, kept in the owner's chat, that answers
+// /ask in chats that have no presets of their own yet.
+type FallbackPreset struct {
+	ChatID int64     `json:"chat_id"`
+	Preset string    `json:"preset"`
+	By     int64     `json:"by"`
+	Since  time.Time `json:"since"`
+}
+
+// FallbackPresets keeps the bot-wide fallback in Redis so every ingress and
+// worker replica sees the same one.
+type FallbackPresets struct {
+	redis *redis.Client
+}
+
+func NewFallbackPresets(rdb *redis.Client) *FallbackPresets {
+	return &FallbackPresets{redis: rdb}
+}
+
+func (f *FallbackPresets) Set(ctx context.Context, p FallbackPreset) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal fallback preset: %w", err)
+	}
+	if err := f.redis.Set(ctx, fallbackPresetKey, payload, 0).Err(); err != nil {
+		return fmt.Errorf("set fallback preset: %w", err)
+	}
+	return nil
+}
+
+func (f *FallbackPresets) Get(ctx context.Context) (FallbackPreset, bool, error) {
+	raw, err := f.redis.Get(ctx, fallbackPresetKey).Bytes()
+	if err == redis.Nil {
+		return FallbackPreset{}, false, nil
+	}
+	if err != nil {
+		return FallbackPreset{}, false, fmt.Errorf("get fallback preset: %w", err)
+	}
+	var p FallbackPreset
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return FallbackPreset{}, false, fmt.Errorf("decode fallback preset: %w", err)
+	}
+	return p, true, nil
+}
+
+// Clear removes the fallback. It reports false if none was set.
+func (f *FallbackPresets) Clear(ctx context.Context) (bool, error) {
+	n, err := f.redis.Del(ctx, fallbackPresetKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("clear fallback preset: %w", err)
+	}
+	return n > 0, nil
+}
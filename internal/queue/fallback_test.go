@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFallbackPresets(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	f := NewFallbackPresets(rdb)
+	if _, ok, err := f.Get(ctx); err != nil || ok {
+		t.Fatalf("expected no fallback, got ok=%v err=%v", ok, err)
+	}
+	want := FallbackPreset{ChatID: -100, Preset: "trial", By: 7, Since: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if err := f.Set(ctx, want); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got, ok, err := f.Get(ctx)
+	if err != nil || !ok || got != want {
+		t.Fatalf("get: %+v ok=%v err=%v", got, ok, err)
+	}
+	if cleared, err := f.Clear(ctx); err != nil || !cleared {
+		t.Fatalf("clear: cleared=%v err=%v", cleared, err)
+	}
+	if cleared, _ := f.Clear(ctx); cleared {
+		t.Fatal("second clear must report nothing was set")
+	}
+
+	// The fallback limit counts apart from the per-user limit.
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	user := NewRateLimiter(rdb, 1)
+	trial := NewNamedRateLimiter(rdb, "fallback", 1)
+	if ok, _, _, _ := user.Allow(ctx, 1, 0, now); !ok {
+		t.Fatal("first user call must pass")
+	}
+	if ok, _, _, _ := trial.Allow(ctx, 1, 0, now); !ok {
+		t.Fatal("fallback limiter must not share the user counter")
+	}
+}
@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"hyprbot/internal/objectstore"
+)
+
+// ExpandCallbackPrefix starts the data of the "Show full answer" button under
+// answer previews, followed by the job id.
+const ExpandCallbackPrefix = "hb:more:"
+
+// PrivateAnswerStartPrefix starts the /start payload of the deep link that
+// hands a kept answer to an asker the bot could not message first, followed
+// by the job id.
+const PrivateAnswerStartPrefix = "ans_"
+
+// FullAnswer is the complete text behind an answer preview. Mode is the
+// chat's long_answers setting when the answer was sent, so changing the
+// setting does not change what existing buttons do.
+type FullAnswer struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+	// PreviewRunes is how much of Text the preview showed.
+	PreviewRunes int    `json:"preview_runes"`
+	Mode         string `json:"mode"`
+	// UserID is set on answers kept for private delivery; only that user
+	// may fetch them.
+	UserID int64 `json:"user_id,omitempty"`
+	// Object is the object store key holding Text when the store moved it
+	// out of Redis; Text is empty in Redis then.
+	Object string `json:"object,omitempty"`
+}
+
+// Rest is the part of the answer the preview left out.
+func (a FullAnswer) Rest() string {
+	r := []rune(a.Text)
+	if a.PreviewRunes >= len(r) {
+		return ""
+	}
+	return string(r[a.PreviewRunes:])
+}
+
+// OffloadMinBytes is the text size from which FullAnswerStore moves answers
+// to the object store, once OffloadTo set one.
+const OffloadMinBytes = 16 << 10
+
+// ObjectStore is where FullAnswerStore keeps long texts; Get returns
+// objectstore.ErrNotFound for missing objects.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// FullAnswerStore keeps FullAnswer by job id while previews can be expanded.
+type FullAnswerStore struct {
+	redis *redis.Client
+	ttl   time.Duration
+
+	objects ObjectStore
+	prefix  string
+}
+
+func NewFullAnswerStore(rdb *redis.Client, ttl time.Duration) *FullAnswerStore {
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &FullAnswerStore{redis: rdb, ttl: ttl}
+}
+
+// OffloadTo keeps texts of OffloadMinBytes or more in objects under prefix
+// instead of Redis. The objects need a lifecycle rule or janitor at least as
+// long as the store's ttl.
+func (s *FullAnswerStore) OffloadTo(objects ObjectStore, prefix string) {
+	s.objects, s.prefix = objects, prefix
+}
+
+// Save keeps a for the store's ttl. A text that cannot be offloaded stays in
+// Redis.
+func (s *FullAnswerStore) Save(ctx context.Context, jobID string, a FullAnswer) error {
+	if s.objects != nil && len(a.Text) >= OffloadMinBytes {
+		key := s.prefix + jobID + ".txt"
+		if err := s.objects.Put(ctx, key, []byte(a.Text), "text/plain; charset=utf-8"); err == nil {
+			a.Object, a.Text = key, ""
+		}
+	}
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal full answer: %w", err)
+	}
+	if err := s.redis.Set(ctx, fullAnswerKey(jobID), payload, s.ttl).Err(); err != nil {
+		return fmt.Errorf("save full answer: %w", err)
+	}
+	return nil
+}
+
+func (s *FullAnswerStore) Get(ctx context.Context, jobID string) (FullAnswer, bool, error) {
+	raw, err := s.redis.Get(ctx, fullAnswerKey(jobID)).Bytes()
+	if err == redis.Nil {
+		return FullAnswer{}, false, nil
+	}
+	if err != nil {
+		return FullAnswer{}, false, fmt.Errorf("get full answer: %w", err)
+	}
+	var a FullAnswer
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return FullAnswer{}, false, fmt.Errorf("decode full answer: %w", err)
+	}
+	if a.Object == "" {
+		return a, true, nil
+	}
+	if s.objects == nil {
+		return FullAnswer{}, false, fmt.Errorf("full answer %s is in the object store, which is not configured", jobID)
+	}
+	text, err := s.objects.Get(ctx, a.Object)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return FullAnswer{}, false, nil
+	}
+	if err != nil {
+		return FullAnswer{}, false, fmt.Errorf("get full answer object: %w", err)
+	}
+	a.Text, a.Object = string(text), ""
+	return a, true, nil
+}
+
+func fullAnswerKey(jobID string) string {
+	return "hyprbot:fullanswer:" + jobID
+}
@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"hyprbot/internal/objectstore"
+)
+
+func TestFullAnswerStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	store := NewFullAnswerStore(rdb, time.Hour)
+
+	if _, found, err := store.Get(ctx, "missing"); err != nil || found {
+		t.Fatalf("expected no answer, got found=%v err=%v", found, err)
+	}
+	want := FullAnswer{ChatID: -100, Text: "привет мир", PreviewRunes: 7, Mode: "expand"}
+	if err := store.Save(ctx, "job1", want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, found, err := store.Get(ctx, "job1")
+	if err != nil || !found || got != want {
+		t.Fatalf("get: %+v found=%v err=%v", got, found, err)
+	}
+	if rest := got.Rest(); rest != "мир" {
+		t.Fatalf("rest = %q, want the runes after the preview", rest)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if _, found, _ := store.Get(ctx, "job1"); found {
+		t.Fatal("answer must expire after the ttl")
+	}
+}
+
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) Put(_ context.Context, key string, data []byte, _ string) error {
+	f[key] = data
+	return nil
+}
+
+func (f fakeObjects) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := f[key]
+	if !ok {
+		return nil, fmt.Errorf("get %s: %w", key, objectstore.ErrNotFound)
+	}
+	return data, nil
+}
+
+func TestFullAnswerStoreOffload(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	objects := fakeObjects{}
+	store := NewFullAnswerStore(rdb, time.Hour)
+	store.OffloadTo(objects, "hyprbot/artifacts/answers/")
+
+	short := FullAnswer{ChatID: -100, Text: "short", Mode: "file"}
+	long := FullAnswer{ChatID: -100, Text: strings.Repeat("a", OffloadMinBytes), Mode: "file"}
+	if err := store.Save(ctx, "short", short); err != nil {
+		t.Fatalf("save short: %v", err)
+	}
+	if err := store.Save(ctx, "long", long); err != nil {
+		t.Fatalf("save long: %v", err)
+	}
+	if len(objects) != 1 || len(objects["hyprbot/artifacts/answers/long.txt"]) != OffloadMinBytes {
+		t.Fatalf("expected only the long text offloaded, got %d objects", len(objects))
+	}
+	if raw, _ := mr.Get(fullAnswerKey("long")); strings.Contains(raw, "aaaa") {
+		t.Fatal("offloaded text must not stay in redis")
+	}
+	for id, want := range map[string]FullAnswer{"short": short, "long": long} {
+		got, found, err := store.Get(ctx, id)
+		if err != nil || !found || got != want {
+			t.Fatalf("get %s: found=%v err=%v", id, found, err)
+		}
+	}
+
+	// An object removed by the janitor makes the answer unavailable.
+	delete(objects, "hyprbot/artifacts/answers/long.txt")
+	if _, found, err := store.Get(ctx, "long"); err != nil || found {
+		t.Fatalf("expected the expired object to be gone, got found=%v err=%v", found, err)
+	}
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AskJobVersion is the AskJob payload version this binary writes. Bump it
+// and append to askJobUpgrades when a field is renamed or changes meaning;
+// purely additive fields need neither. Workers decode newer payloads best
+// effort, so during a rollout new fields must be optional for old workers.
+const AskJobVersion = 1
+
+// askJobUpgrades[v] rewrites a version v payload into version v+1. Version 0
+// is anything written before AskJob carried a version; it already has the
+// version 1 shape.
+var askJobUpgrades = []func(fields map[string]json.RawMessage) error{
+	0: func(map[string]json.RawMessage) error { return nil },
+}
+
+// DecodeAskJob decodes an AskJob payload of any version, upgrading older
+// ones to AskJobVersion.
+func DecodeAskJob(raw []byte) (AskJob, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return AskJob{}, fmt.Errorf("decode ask job: %w", err)
+	}
+	version := 0
+	if v, ok := fields["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return AskJob{}, fmt.Errorf("decode ask job version: %w", err)
+		}
+	}
+	if version < AskJobVersion {
+		for v := version; v < AskJobVersion; v++ {
+			if err := askJobUpgrades[v](fields); err != nil {
+				return AskJob{}, fmt.Errorf("upgrade ask job from version %d: %w", v, err)
+			}
+		}
+		fields["version"] = json.RawMessage(fmt.Sprint(AskJobVersion))
+		upgraded, err := json.Marshal(fields)
+		if err != nil {
+			return AskJob{}, fmt.Errorf("encode upgraded ask job: %w", err)
+		}
+		raw = upgraded
+	}
+	var job AskJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return AskJob{}, fmt.Errorf("decode ask job: %w", err)
+	}
+	return job, nil
+}
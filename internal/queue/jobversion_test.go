@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDecodeAskJob(t *testing.T) {
+	job, err := DecodeAskJob([]byte(`{"job_id":"old","chat_id":-100,"prompt":"hi","attempts":1}`))
+	if err != nil {
+		t.Fatalf("decode unversioned: %v", err)
+	}
+	if job.Version != AskJobVersion || job.JobID != "old" || job.ChatID != -100 || job.Attempts != 1 {
+		t.Fatalf("unversioned payload must upgrade to the current version: %+v", job)
+	}
+
+	job, err = DecodeAskJob([]byte(`{"version":99,"job_id":"new","prompt":"hi","field_from_the_future":true}`))
+	if err != nil {
+		t.Fatalf("decode newer: %v", err)
+	}
+	if job.Version != 99 || job.JobID != "new" {
+		t.Fatalf("newer payload must decode best effort: %+v", job)
+	}
+
+	if _, err := DecodeAskJob([]byte(`{"chat_id":"not a number"}`)); err == nil {
+		t.Fatal("mistyped payload must fail")
+	}
+	if _, err := DecodeAskJob([]byte(`not json`)); err == nil {
+		t.Fatal("invalid json must fail")
+	}
+}
+
+func TestStreamQueueQuarantine(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewStreamQueue(rdb, "jobs", "workers", "w1", 0)
+	if err := q.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+	for _, values := range []map[string]any{
+		{"payload": "not json"},
+		{"body": "no payload field"},
+	} {
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "jobs", Values: values}).Err(); err != nil {
+			t.Fatalf("xadd: %v", err)
+		}
+	}
+
+	// Undecodable entries are handed to the worker to ack rather than left
+	// pending without a trace.
+	msgs, err := q.Read(ctx, 10)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("read: %v %v", msgs, err)
+	}
+	for _, msg := range msgs {
+		if msg.Err == nil {
+			t.Fatalf("entry %s must carry a decode error", msg.ID)
+		}
+		if err := q.Quarantine(ctx, msg, msg.Err.Error()); err != nil {
+			t.Fatalf("quarantine: %v", err)
+		}
+	}
+
+	if d, err := q.Depth(ctx); err != nil || d != (Depth{}) {
+		t.Fatalf("quarantined entries must leave the stream: %+v %v", d, err)
+	}
+	quarantined, err := rdb.XRange(ctx, q.QuarantineStream(), "-", "+").Result()
+	if err != nil || len(quarantined) != 2 {
+		t.Fatalf("quarantine stream: %v %v", quarantined, err)
+	}
+	first := quarantined[0].Values
+	if first["payload"] != "not json" || first["quarantine_id"] != msgs[0].ID || first["quarantine_reason"] == "" {
+		t.Fatalf("quarantined entry must keep its fields, id and reason: %v", first)
+	}
+}
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	maintenanceKey        = "hyprbot:maintenance"
+	maintenanceNoticesKey = "hyprbot:maintenance:notices"
+	// maxMaintenanceNotices bounds the replies edited when maintenance ends.
+	maxMaintenanceNotices = 1000
+)
+
+// MaintenanceState is set while the owner drains the queue: ingress refuses
+// new questions and workers finish the backlog.
+type MaintenanceState struct {
+	Since   time.Time `json:"since"`
+	By      int64     `json:"by"`
+	Message string    `json:"message,omitempty"`
+}
+
+// MaintenanceNotice is a "under maintenance" reply to edit once it ends.
+type MaintenanceNotice struct {
+	ChatID    int64
+	MessageID int64
+}
+
+// Maintenance keeps the drain state in Redis so every ingress replica sees
+// it.
+type Maintenance struct {
+	redis *redis.Client
+}
+
+func NewMaintenance(rdb *redis.Client) *Maintenance {
+	return &Maintenance{redis: rdb}
+}
+
+// Enable starts maintenance. It reports false if it was already on.
+func (m *Maintenance) Enable(ctx context.Context, state MaintenanceState) (bool, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return false, fmt.Errorf("marshal maintenance state: %w", err)
+	}
+	ok, err := m.redis.SetNX(ctx, maintenanceKey, payload, 0).Result()
+	if err != nil {
+		return false, fmt.Errorf("enable maintenance: %w", err)
+	}
+	return ok, nil
+}
+
+func (m *Maintenance) State(ctx context.Context) (MaintenanceState, bool, error) {
+	raw, err := m.redis.Get(ctx, maintenanceKey).Bytes()
+	if err == redis.Nil {
+		return MaintenanceState{}, false, nil
+	}
+	if err != nil {
+		return MaintenanceState{}, false, fmt.Errorf("get maintenance state: %w", err)
+	}
+	var state MaintenanceState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return MaintenanceState{}, false, fmt.Errorf("decode maintenance state: %w", err)
+	}
+	return state, true, nil
+}
+
+// AddNotice remembers a maintenance reply so Disable can hand it back.
+func (m *Maintenance) AddNotice(ctx context.Context, n MaintenanceNotice) error {
+	_, err := m.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, maintenanceNoticesKey, fmt.Sprintf("%d:%d", n.ChatID, n.MessageID))
+		pipe.LTrim(ctx, maintenanceNoticesKey, -maxMaintenanceNotices, -1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add maintenance notice: %w", err)
+	}
+	return nil
+}
+
+// Disable ends maintenance and returns the replies sent meanwhile. It
+// reports false if maintenance was not on.
+func (m *Maintenance) Disable(ctx context.Context) ([]MaintenanceNotice, bool, error) {
+	var del *redis.IntCmd
+	var notices *redis.StringSliceCmd
+	_, err := m.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, maintenanceKey)
+		notices = pipe.LRange(ctx, maintenanceNoticesKey, 0, -1)
+		pipe.Del(ctx, maintenanceNoticesKey)
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("disable maintenance: %w", err)
+	}
+	var out []MaintenanceNotice
+	for _, raw := range notices.Val() {
+		chat, msg, ok := strings.Cut(raw, ":")
+		if !ok {
+			continue
+		}
+		chatID, err1 := strconv.ParseInt(chat, 10, 64)
+		messageID, err2 := strconv.ParseInt(msg, 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, MaintenanceNotice{ChatID: chatID, MessageID: messageID})
+	}
+	return out, del.Val() > 0, nil
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMaintenance(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	m := NewMaintenance(rdb)
+
+	if _, on, err := m.State(ctx); err != nil || on {
+		t.Fatalf("state before enable: on=%v err=%v", on, err)
+	}
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if ok, err := m.Enable(ctx, MaintenanceState{Since: since, By: 1, Message: "upgrading"}); err != nil || !ok {
+		t.Fatalf("enable: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := m.Enable(ctx, MaintenanceState{Since: since, By: 2}); ok {
+		t.Fatal("second enable must report maintenance already on")
+	}
+	state, on, err := m.State(ctx)
+	if err != nil || !on || state.By != 1 || state.Message != "upgrading" || !state.Since.Equal(since) {
+		t.Fatalf("unexpected state %+v on=%v err=%v", state, on, err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if err := m.AddNotice(ctx, MaintenanceNotice{ChatID: -100, MessageID: i}); err != nil {
+			t.Fatalf("add notice: %v", err)
+		}
+	}
+	notices, was, err := m.Disable(ctx)
+	if err != nil || !was || len(notices) != 3 || notices[2].MessageID != 3 || notices[0].ChatID != -100 {
+		t.Fatalf("disable: notices=%v was=%v err=%v", notices, was, err)
+	}
+	if notices, was, _ := m.Disable(ctx); was || len(notices) != 0 {
+		t.Fatalf("second disable: notices=%v was=%v", notices, was)
+	}
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reply formats of an OutboxEntry.
+const (
+	ReplyPlain    = "plain"
+	ReplyMarkdown = "markdown"
+	ReplyJSON     = "json"
+)
+
+// OutboxEntry is a rendered answer waiting to be sent, with what the worker
+// records once it is delivered. A retry of the job resends it instead of
+// calling the provider again.
+type OutboxEntry struct {
+	Format   string `json:"format"`
+	Reply    string `json:"reply"`
+	Footer   string `json:"footer,omitempty"`
+	Feedback bool   `json:"feedback,omitempty"`
+	// More marks Reply as a preview of an answer kept in FullAnswerStore.
+	More bool `json:"more,omitempty"`
+	// Private sends the answer to the asker's private chat.
+	Private bool `json:"private,omitempty"`
+	// Translate is the PresetPick token behind the language buttons of a
+	// /tr answer.
+	Translate string `json:"translate,omitempty"`
+	// Disclosure is the chat's disclosure line, added by the sender.
+	Disclosure string `json:"disclosure,omitempty"`
+
+	// Answer is the plain answer text kept in the conversation history.
+	Answer       string `json:"answer"`
+	PresetName   string `json:"preset_name"`
+	Model        string `json:"model"`
+	ABArm        string `json:"ab_arm,omitempty"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	LatencyMs    int64  `json:"latency_ms"`
+}
+
+// OutboxStore keeps rendered answers by job id until they are sent.
+type OutboxStore struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewOutboxStore(rdb *redis.Client, ttl time.Duration) *OutboxStore {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &OutboxStore{redis: rdb, ttl: ttl}
+}
+
+func (o *OutboxStore) Save(ctx context.Context, jobID string, entry OutboxEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal outbox entry: %w", err)
+	}
+	if err := o.redis.Set(ctx, outboxKey(jobID), payload, o.ttl).Err(); err != nil {
+		return fmt.Errorf("save outbox entry: %w", err)
+	}
+	return nil
+}
+
+func (o *OutboxStore) Get(ctx context.Context, jobID string) (OutboxEntry, bool, error) {
+	raw, err := o.redis.Get(ctx, outboxKey(jobID)).Bytes()
+	if err == redis.Nil {
+		return OutboxEntry{}, false, nil
+	}
+	if err != nil {
+		return OutboxEntry{}, false, fmt.Errorf("get outbox entry: %w", err)
+	}
+	var entry OutboxEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return OutboxEntry{}, false, fmt.Errorf("decode outbox entry: %w", err)
+	}
+	return entry, true, nil
+}
+
+func (o *OutboxStore) Delete(ctx context.Context, jobID string) error {
+	if err := o.redis.Del(ctx, outboxKey(jobID)).Err(); err != nil {
+		return fmt.Errorf("delete outbox entry: %w", err)
+	}
+	return nil
+}
+
+func outboxKey(jobID string) string {
+	return "hyprbot:outbox:" + jobID
+}
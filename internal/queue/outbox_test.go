@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOutboxStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	outbox := NewOutboxStore(rdb, time.Minute)
+
+	if _, found, err := outbox.Get(ctx, "job1"); err != nil || found {
+		t.Fatalf("empty outbox: found=%v err=%v", found, err)
+	}
+	entry := OutboxEntry{Format: ReplyMarkdown, Reply: "*hi*", Feedback: true, Answer: "hi", PresetName: "coder", OutputTokens: 12}
+	if err := outbox.Save(ctx, "job1", entry); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, found, err := outbox.Get(ctx, "job1")
+	if err != nil || !found || got != entry {
+		t.Fatalf("get: %+v found=%v err=%v", got, found, err)
+	}
+
+	if err := outbox.Delete(ctx, "job1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, found, _ := outbox.Get(ctx, "job1"); found {
+		t.Fatal("delivered entry must be gone")
+	}
+
+	_ = outbox.Save(ctx, "job2", entry)
+	mr.FastForward(2 * time.Minute)
+	if _, found, _ := outbox.Get(ctx, "job2"); found {
+		t.Fatal("entry must expire after the ttl")
+	}
+}
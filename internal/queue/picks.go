@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Callback data prefixes of buttons backed by a PresetPick. They live under
+// the telegram package's "hb:" namespace so the shared callback router
+// receives them. Picker buttons append "<token>:<preset index>", retry
+// buttons just the token and translate buttons "<token>:<language code>".
+const (
+	PickCallbackPrefix      = "hb:pick:"
+	RetryCallbackPrefix     = "hb:retry:"
+	TranslateCallbackPrefix = "hb:tr:"
+)
+
+// PresetPick is a failed job parked until the asker taps a button: one of
+// Presets when its preset was missing, or Retry (no Presets) after a
+// terminal provider failure.
+type PresetPick struct {
+	Job     AskJob   `json:"job"`
+	Presets []string `json:"presets"`
+}
+
+// PickStore keeps preset picks between the worker offering them and the
+// ingress handling the button tap. Callback data is too small to carry the
+// job, so buttons only reference a token.
+type PickStore struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewPickStore(rdb *redis.Client, ttl time.Duration) *PickStore {
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return &PickStore{redis: rdb, ttl: ttl}
+}
+
+func (p *PickStore) Save(ctx context.Context, pick PresetPick) (string, error) {
+	payload, err := json.Marshal(pick)
+	if err != nil {
+		return "", fmt.Errorf("marshal preset pick: %w", err)
+	}
+	token := newJobID()
+	if err := p.redis.Set(ctx, pickKey(token), payload, p.ttl).Err(); err != nil {
+		return "", fmt.Errorf("save preset pick: %w", err)
+	}
+	return token, nil
+}
+
+func (p *PickStore) Get(ctx context.Context, token string) (PresetPick, bool, error) {
+	raw, err := p.redis.Get(ctx, pickKey(token)).Bytes()
+	if err == redis.Nil {
+		return PresetPick{}, false, nil
+	}
+	if err != nil {
+		return PresetPick{}, false, fmt.Errorf("get preset pick: %w", err)
+	}
+	var pick PresetPick
+	if err := json.Unmarshal(raw, &pick); err != nil {
+		return PresetPick{}, false, fmt.Errorf("decode preset pick: %w", err)
+	}
+	return pick, true, nil
+}
+
+// Delete consumes the pick. It reports false if another tap got there first.
+func (p *PickStore) Delete(ctx context.Context, token string) (bool, error) {
+	n, err := p.redis.Del(ctx, pickKey(token)).Result()
+	if err != nil {
+		return false, fmt.Errorf("delete preset pick: %w", err)
+	}
+	return n > 0, nil
+}
+
+func pickKey(token string) string {
+	return "hyprbot:pick:" + token
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPickStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	picks := NewPickStore(rdb, time.Minute)
+
+	token, err := picks.Save(ctx, PresetPick{
+		Job:     AskJob{ChatID: -100, UserID: 5, Prompt: "hi", PresetName: "gone"},
+		Presets: []string{"coder", "translator"},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	pick, found, err := picks.Get(ctx, token)
+	if err != nil || !found {
+		t.Fatalf("get: found=%v err=%v", found, err)
+	}
+	if pick.Job.Prompt != "hi" || len(pick.Presets) != 2 || pick.Presets[1] != "translator" {
+		t.Fatalf("unexpected pick %+v", pick)
+	}
+
+	if ok, err := picks.Delete(ctx, token); err != nil || !ok {
+		t.Fatalf("delete: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := picks.Delete(ctx, token); ok {
+		t.Fatal("second delete must report the pick as already taken")
+	}
+
+	token, _ = picks.Save(ctx, PresetPick{Presets: []string{"a"}})
+	mr.FastForward(2 * time.Minute)
+	if _, found, _ := picks.Get(ctx, token); found {
+		t.Fatal("pick must expire after the ttl")
+	}
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxQuarantined caps the quarantine stream; the oldest entries go first.
+const maxQuarantined = 10000
+
+// Quarantine moves an entry the worker cannot handle to the quarantine
+// stream, with its original id and the reason, and acks it in the same
+// transaction so it neither stays pending nor gets lost.
+func (q *StreamQueue) Quarantine(ctx context.Context, msg Message, reason string) error {
+	values := make(map[string]any, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["quarantine_id"] = msg.ID
+	values["quarantine_reason"] = reason
+
+	_, err := q.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: q.QuarantineStream(),
+			MaxLen: maxQuarantined,
+			Approx: true,
+			Values: values,
+		})
+		pipe.XAck(ctx, q.stream, q.group, msg.ID)
+		pipe.XDel(ctx, q.stream, msg.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("quarantine entry: %w", err)
+	}
+	return nil
+}
+
+func (q *StreamQueue) QuarantineStream() string {
+	return q.stream + ":quarantine"
+}
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeQuotaScript counts a request unless the counter already reached the
+// limit, so requests over the quota never inflate it.
+var takeQuotaScript = redis.NewScript(`
+local c = tonumber(redis.call("GET", KEYS[1]) or "0")
+if c >= tonumber(ARGV[1]) then
+  return 0
+end
+c = redis.call("INCR", KEYS[1])
+if c == 1 then
+  redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// ChatQuota counts each chat's requests per calendar month (UTC) against a
+// free allowance.
+type ChatQuota struct {
+	redis *redis.Client
+	free  int64
+}
+
+func NewChatQuota(rdb *redis.Client, free int64) *ChatQuota {
+	return &ChatQuota{redis: rdb, free: free}
+}
+
+// Take counts one request. It reports false, without counting, once the
+// month's free requests are used up.
+func (q *ChatQuota) Take(ctx context.Context, chatID int64, now time.Time) (bool, error) {
+	key, ttl := q.key(chatID, now)
+	n, err := takeQuotaScript.Run(ctx, q.redis, []string{key}, q.free, int64(ttl.Seconds())).Int()
+	if err != nil {
+		return false, fmt.Errorf("take quota: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Used returns the free requests the chat used this month.
+func (q *ChatQuota) Used(ctx context.Context, chatID int64, now time.Time) (int64, error) {
+	key, _ := q.key(chatID, now)
+	n, err := q.redis.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get quota: %w", err)
+	}
+	return n, nil
+}
+
+// ClaimInvoice reports whether the chat may get a credits invoice now: true
+// for the first claim per window, false until it passes, so refused requests
+// do not flood the chat with invoices.
+func (q *ChatQuota) ClaimInvoice(ctx context.Context, chatID int64, window time.Duration) (bool, error) {
+	ok, err := q.redis.SetNX(ctx, fmt.Sprintf("hyprbot:invoice:%d", chatID), 1, window).Result()
+	if err != nil {
+		return false, fmt.Errorf("claim invoice: %w", err)
+	}
+	return ok, nil
+}
+
+// key returns the chat's counter for the month of now and how long it must
+// live: until a day after the month ends.
+func (q *ChatQuota) key(chatID int64, now time.Time) (string, time.Duration) {
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	ttl := monthStart.AddDate(0, 1, 1).Sub(now)
+	return fmt.Sprintf("hyprbot:quota:%d:%s", chatID, monthStart.Format("200601")), ttl
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestChatQuota(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewChatQuota(rdb, 2)
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, err := q.Take(ctx, -100, now); err != nil || !ok {
+			t.Fatalf("take %d: ok=%v err=%v", i, ok, err)
+		}
+	}
+	if ok, _ := q.Take(ctx, -100, now); ok {
+		t.Fatal("third request must exceed the quota")
+	}
+	if used, _ := q.Used(ctx, -100, now); used != 2 {
+		t.Fatalf("expected 2 used, got %d", used)
+	}
+	if ok, _ := q.Take(ctx, -200, now); !ok {
+		t.Fatal("quota must be per chat")
+	}
+
+	// A new month starts from zero.
+	if ok, _ := q.Take(ctx, -100, now.Add(2*time.Hour)); !ok {
+		t.Fatal("quota must reset with the month")
+	}
+}
+
+func TestChatQuotaClaimInvoice(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewChatQuota(rdb, 0)
+	if ok, err := q.ClaimInvoice(ctx, -100, time.Minute); err != nil || !ok {
+		t.Fatalf("first claim: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := q.ClaimInvoice(ctx, -100, time.Minute); ok {
+		t.Fatal("second claim in the window must be refused")
+	}
+	if ok, _ := q.ClaimInvoice(ctx, -200, time.Minute); !ok {
+		t.Fatal("claims must be per chat")
+	}
+	mr.FastForward(time.Minute)
+	if ok, _ := q.ClaimInvoice(ctx, -100, time.Minute); !ok {
+		t.Fatal("claim after the window must succeed")
+	}
+}
@@ -17,44 +17,81 @@ return c
 `)
 
 type RateLimiter struct {
-	redis *redis.Client
-	limit int64
+	redis  *redis.Client
+	limit  int64
+	prefix string
 }
 
 func NewRateLimiter(rdb *redis.Client, limit int64) *RateLimiter {
-	return &RateLimiter{redis: rdb, limit: limit}
+	return &RateLimiter{redis: rdb, limit: limit, prefix: "hyprbot:ratelimit"}
+}
+
+// NewNamedRateLimiter counts separately from the per-user limit, e.g. for
+// questions answered by the bot-wide fallback preset.
+func NewNamedRateLimiter(rdb *redis.Client, name string, limit int64) *RateLimiter {
+	return &RateLimiter{redis: rdb, limit: limit, prefix: "hyprbot:ratelimit:" + name}
+}
+
+// Limit is the number of requests allowed per user and hour.
+func (r *RateLimiter) Limit() int64 {
+	return r.limit
 }
 
 func (r *RateLimiter) Allow(ctx context.Context, chatID, userID int64, now time.Time) (allowed bool, used int64, resetAt time.Time, err error) {
 	windowStart := now.UTC().Truncate(time.Hour)
-	windowEnd := windowStart.Add(time.Hour)
+	return r.count(ctx, r.hourlyKey(chatID, userID, windowStart), r.limit, now, windowStart.Add(time.Hour))
+}
+
+// Used reports the hour's count without adding to it, for /stats.
+func (r *RateLimiter) Used(ctx context.Context, chatID, userID int64, now time.Time) (used int64, resetAt time.Time, err error) {
+	windowStart := now.UTC().Truncate(time.Hour)
+	used, err = r.redis.Get(ctx, r.hourlyKey(chatID, userID, windowStart)).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, time.Time{}, fmt.Errorf("get hourly count: %w", err)
+	}
+	return used, windowStart.Add(time.Hour), nil
+}
+
+// AllowDaily counts toward a per-user cap for the UTC day. The cap is the
+// chat's own, so it is passed in rather than fixed on the limiter.
+func (r *RateLimiter) AllowDaily(ctx context.Context, chatID, userID, limit int64, now time.Time) (allowed bool, used int64, resetAt time.Time, err error) {
+	dayStart, dayEnd := utcDay(now)
+	return r.count(ctx, r.dailyKey(chatID, userID, dayStart), limit, now, dayEnd)
+}
+
+// UsedDaily reports the day's count without adding to it, for showing the
+// daily window while the hourly one refuses requests.
+func (r *RateLimiter) UsedDaily(ctx context.Context, chatID, userID int64, now time.Time) (used int64, resetAt time.Time, err error) {
+	dayStart, dayEnd := utcDay(now)
+	used, err = r.redis.Get(ctx, r.dailyKey(chatID, userID, dayStart)).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, time.Time{}, fmt.Errorf("get daily count: %w", err)
+	}
+	return used, dayEnd, nil
+}
+
+func (r *RateLimiter) count(ctx context.Context, key string, limit int64, now, windowEnd time.Time) (bool, int64, time.Time, error) {
 	ttl := int64(windowEnd.Sub(now.UTC()).Seconds())
 	if ttl < 1 {
 		ttl = 1
 	}
-
-	key := fmt.Sprintf("hyprbot:ratelimit:%d:%d:%s", chatID, userID, windowStart.Format("2006010215"))
 	res, err := incrWithTTLScript.Run(ctx, r.redis, []string{key}, ttl).Int64()
 	if err != nil {
 		return false, 0, time.Time{}, fmt.Errorf("rate limit script: %w", err)
 	}
-	return res <= r.limit, res, windowEnd, nil
+	return res <= limit, res, windowEnd, nil
 }
 
-type UpdateDeduplicator struct {
-	redis *redis.Client
-	ttl   time.Duration
+func (r *RateLimiter) hourlyKey(chatID, userID int64, windowStart time.Time) string {
+	return fmt.Sprintf("%s:%d:%d:%s", r.prefix, chatID, userID, windowStart.Format("2006010215"))
 }
 
-func NewUpdateDeduplicator(rdb *redis.Client, ttl time.Duration) *UpdateDeduplicator {
-	return &UpdateDeduplicator{redis: rdb, ttl: ttl}
+func (r *RateLimiter) dailyKey(chatID, userID int64, dayStart time.Time) string {
+	return fmt.Sprintf("%s:%d:%d:d%s", r.prefix, chatID, userID, dayStart.Format("20060102"))
 }
 
-func (d *UpdateDeduplicator) MarkFirst(ctx context.Context, updateID int64) (bool, error) {
-	key := fmt.Sprintf("hyprbot:update:%d", updateID)
-	ok, err := d.redis.SetNX(ctx, key, "1", d.ttl).Result()
-	if err != nil {
-		return false, fmt.Errorf("dedupe setnx: %w", err)
-	}
-	return ok, nil
+func utcDay(now time.Time) (start, end time.Time) {
+	y, m, d := now.UTC().Date()
+	start = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
 }
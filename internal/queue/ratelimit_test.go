@@ -46,3 +46,77 @@ func TestRateLimiterAllow(t *testing.T) {
 		t.Fatalf("expected third call denied with used=3, got allowed=%v used=%d", allowed, used)
 	}
 }
+
+func TestRateLimiterAllowDaily(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	rl := NewRateLimiter(rdb, 100)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 13, 22, 30, 0, 0, time.UTC)
+
+	if used, _, err := rl.UsedDaily(ctx, 1, 10, now); err != nil || used != 0 {
+		t.Fatalf("expected nothing used yet, got %d %v", used, err)
+	}
+	for i := 0; i < 2; i++ {
+		if allowed, _, _, err := rl.AllowDaily(ctx, 1, 10, 2, now); err != nil || !allowed {
+			t.Fatalf("allow#%d: allowed=%v err=%v", i+1, allowed, err)
+		}
+	}
+	allowed, used, resetAt, err := rl.AllowDaily(ctx, 1, 10, 2, now.Add(time.Hour))
+	if err != nil || allowed || used != 3 {
+		t.Fatalf("expected third call denied with used=3, got allowed=%v used=%d err=%v", allowed, used, err)
+	}
+	if want := time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC); !resetAt.Equal(want) {
+		t.Fatalf("resetAt = %v, want %v", resetAt, want)
+	}
+	if used, _, _ := rl.UsedDaily(ctx, 1, 10, now); used != 3 {
+		t.Fatalf("UsedDaily = %d, want 3", used)
+	}
+	if allowed, _, _, _ := rl.AllowDaily(ctx, 1, 10, 2, now.Add(2*time.Hour)); !allowed {
+		t.Fatal("expected a new day to reset the cap")
+	}
+}
+
+func TestRateLimiterUsed(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	rl := NewRateLimiter(rdb, 5)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 13, 10, 20, 0, 0, time.UTC)
+
+	if used, _, err := rl.Used(ctx, 1, 10, now); err != nil || used != 0 {
+		t.Fatalf("expected nothing used yet, got %d %v", used, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := rl.Allow(ctx, 1, 10, now); err != nil {
+			t.Fatalf("allow#%d: %v", i+1, err)
+		}
+	}
+	used, resetAt, err := rl.Used(ctx, 1, 10, now.Add(10*time.Minute))
+	if err != nil || used != 2 {
+		t.Fatalf("Used = %d %v, want 2", used, err)
+	}
+	if want := time.Date(2026, 2, 13, 11, 0, 0, 0, time.UTC); !resetAt.Equal(want) {
+		t.Fatalf("resetAt = %v, want %v", resetAt, want)
+	}
+	if used, _, _ := rl.Used(ctx, 1, 10, now); used != 2 {
+		t.Fatalf("Used counted toward the limit: %d", used)
+	}
+	if used, _, _ := rl.Used(ctx, 1, 10, now.Add(time.Hour)); used != 0 {
+		t.Fatalf("expected a new hour to start at 0, got %d", used)
+	}
+}
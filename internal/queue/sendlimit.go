@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sendSlotScript takes one Telegram send from the global per-second budget
+// and, for group chats, from the chat's per-minute budget. It returns 0 when
+// the send may go out now, otherwise the milliseconds to wait: a flood wait
+// recorded for the chat, or until the exhausted window rolls over.
+var sendSlotScript = redis.NewScript(`
+local blocked = redis.call("PTTL", KEYS[1])
+if blocked > 0 then
+  return blocked
+end
+local now = tonumber(ARGV[1])
+local globalLimit = tonumber(ARGV[2])
+local chatLimit = tonumber(ARGV[3])
+if globalLimit > 0 and tonumber(redis.call("GET", KEYS[2]) or "0") >= globalLimit then
+  return 1000 - now % 1000
+end
+if chatLimit > 0 and tonumber(redis.call("GET", KEYS[3]) or "0") >= chatLimit then
+  return 60000 - now % 60000
+end
+if globalLimit > 0 then
+  redis.call("INCR", KEYS[2])
+  redis.call("PEXPIRE", KEYS[2], 2000)
+end
+if chatLimit > 0 then
+  redis.call("INCR", KEYS[3])
+  redis.call("PEXPIRE", KEYS[3], 120000)
+end
+return 0
+`)
+
+// SendLimiter keeps Telegram sends of all replicas under the Bot API limits:
+// a global rate per second, a rate per minute for each group chat, and the
+// flood waits Telegram asks for with 429 responses. Zero rates disable the
+// respective budget.
+type SendLimiter struct {
+	redis        *redis.Client
+	globalPerSec int
+	groupPerMin  int
+}
+
+func NewSendLimiter(rdb *redis.Client, globalPerSec, groupPerMin int) *SendLimiter {
+	return &SendLimiter{redis: rdb, globalPerSec: globalPerSec, groupPerMin: groupPerMin}
+}
+
+// Reserve takes a send slot for chatID, or returns how long to wait before
+// asking again. chatID 0 only checks the global budget.
+func (l *SendLimiter) Reserve(ctx context.Context, chatID int64, now time.Time) (time.Duration, error) {
+	ms := now.UnixMilli()
+	chatLimit := 0
+	if chatID < 0 {
+		chatLimit = l.groupPerMin
+	}
+	keys := []string{
+		fmt.Sprintf("hyprbot:tgsend:block:%d", chatID),
+		fmt.Sprintf("hyprbot:tgsend:global:%d", ms/1000),
+		fmt.Sprintf("hyprbot:tgsend:chat:%d:%d", chatID, ms/60000),
+	}
+	wait, err := sendSlotScript.Run(ctx, l.redis, keys, ms, l.globalPerSec, chatLimit).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("reserve telegram send: %w", err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Block holds back sends to chatID for d after Telegram answered with a
+// flood wait. chatID 0 blocks the sends that have no chat.
+func (l *SendLimiter) Block(ctx context.Context, chatID int64, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if err := l.redis.Set(ctx, fmt.Sprintf("hyprbot:tgsend:block:%d", chatID), "1", d).Err(); err != nil {
+		return fmt.Errorf("block telegram sends: %w", err)
+	}
+	return nil
+}
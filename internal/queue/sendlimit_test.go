@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSendLimiter(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	limiter := NewSendLimiter(rdb, 3, 2)
+	now := time.UnixMilli(1_700_000_000_250)
+
+	for i := 0; i < 2; i++ {
+		if wait, err := limiter.Reserve(ctx, -100, now); err != nil || wait != 0 {
+			t.Fatalf("group send %d: wait=%v err=%v", i, wait, err)
+		}
+	}
+	if wait, _ := limiter.Reserve(ctx, -100, now); wait <= 0 || wait > time.Minute {
+		t.Fatalf("group over its per-minute budget must wait, got %v", wait)
+	}
+
+	// Private chats only count against the global budget.
+	if wait, _ := limiter.Reserve(ctx, 42, now); wait != 0 {
+		t.Fatalf("private send: wait=%v", wait)
+	}
+	if wait, _ := limiter.Reserve(ctx, 43, now); wait != 750*time.Millisecond {
+		t.Fatalf("global budget exhausted: want wait until the next second, got %v", wait)
+	}
+	if wait, _ := limiter.Reserve(ctx, 43, now.Add(time.Second)); wait != 0 {
+		t.Fatalf("next second must have budget again, got %v", wait)
+	}
+
+	if err := limiter.Block(ctx, 44, 5*time.Second); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	if wait, _ := limiter.Reserve(ctx, 44, now.Add(time.Second)); wait <= 4*time.Second || wait > 5*time.Second {
+		t.Fatalf("flood wait must hold the chat back, got %v", wait)
+	}
+}
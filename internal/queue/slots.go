@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireSlotScript adds a lease to the provider's sorted set when fewer than
+// the limit are live. Scores are lease deadlines, so leases of crashed
+// workers drop out on their own.
+var acquireSlotScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+  return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return 1
+`)
+
+// ProviderSlots is a semaphore per provider instance shared by all worker
+// replicas, capping requests in flight to providers that throttle under
+// parallel load.
+type ProviderSlots struct {
+	redis *redis.Client
+	lease time.Duration
+	poll  time.Duration
+}
+
+// NewProviderSlots returns slots whose leases expire after lease unless
+// released, which must outlast the longest provider call.
+func NewProviderSlots(rdb *redis.Client, lease time.Duration) *ProviderSlots {
+	if lease <= 0 {
+		lease = 5 * time.Minute
+	}
+	return &ProviderSlots{redis: rdb, lease: lease, poll: 250 * time.Millisecond}
+}
+
+// TryAcquire takes a slot if fewer than limit are in use and returns the
+// lease token to pass to Release.
+func (s *ProviderSlots) TryAcquire(ctx context.Context, providerID, limit int64, now time.Time) (string, bool, error) {
+	token := newJobID()
+	deadline := now.Add(s.lease)
+	ok, err := acquireSlotScript.Run(ctx, s.redis, []string{slotsKey(providerID)},
+		now.UnixMilli(), deadline.UnixMilli(), limit, token, s.lease.Milliseconds()).Int()
+	if err != nil {
+		return "", false, fmt.Errorf("acquire provider slot: %w", err)
+	}
+	if ok != 1 {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// Acquire waits until a slot is free or ctx is done.
+func (s *ProviderSlots) Acquire(ctx context.Context, providerID, limit int64) (string, error) {
+	for {
+		token, ok, err := s.TryAcquire(ctx, providerID, limit, time.Now())
+		if err != nil || ok {
+			return token, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(s.poll):
+		}
+	}
+}
+
+func (s *ProviderSlots) Release(ctx context.Context, providerID int64, token string) error {
+	if err := s.redis.ZRem(ctx, slotsKey(providerID), token).Err(); err != nil {
+		return fmt.Errorf("release provider slot: %w", err)
+	}
+	return nil
+}
+
+func slotsKey(providerID int64) string {
+	return fmt.Sprintf("hyprbot:slots:%d", providerID)
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestProviderSlots(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	slots := NewProviderSlots(rdb, time.Minute)
+	now := time.Now()
+
+	first, ok, err := slots.TryAcquire(ctx, 1, 2, now)
+	if err != nil || !ok {
+		t.Fatalf("first acquire: ok=%v err=%v", ok, err)
+	}
+	if _, ok, _ := slots.TryAcquire(ctx, 1, 2, now); !ok {
+		t.Fatal("second acquire must fit the limit")
+	}
+	if _, ok, _ := slots.TryAcquire(ctx, 1, 2, now); ok {
+		t.Fatal("third acquire must exceed the limit")
+	}
+	if _, ok, _ := slots.TryAcquire(ctx, 2, 1, now); !ok {
+		t.Fatal("other providers have their own slots")
+	}
+
+	if err := slots.Release(ctx, 1, first); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, ok, _ := slots.TryAcquire(ctx, 1, 2, now); !ok {
+		t.Fatal("released slot must be reusable")
+	}
+
+	// Leases of workers that never released expire.
+	if _, ok, _ := slots.TryAcquire(ctx, 1, 2, now.Add(2*time.Minute)); !ok {
+		t.Fatal("expired leases must free their slots")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if _, err := slots.Acquire(waitCtx, 2, 1); err == nil {
+		t.Fatal("acquire must give up when ctx is done")
+	}
+}
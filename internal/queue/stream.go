@@ -14,15 +14,34 @@ import (
 )
 
 type AskJob struct {
-	JobID      string    `json:"job_id"`
-	ChatID     int64     `json:"chat_id"`
-	ChatType   string    `json:"chat_type"`
-	UserID     int64     `json:"user_id"`
-	MessageID  int64     `json:"message_id"`
-	Prompt     string    `json:"prompt"`
-	PresetName string    `json:"preset_name"`
-	EnqueuedAt time.Time `json:"enqueued_at"`
-	Attempts   int       `json:"attempts"`
+	// Version is the payload schema version, see AskJobVersion.
+	Version         int       `json:"version,omitempty"`
+	JobID           string    `json:"job_id"`
+	ChatID          int64     `json:"chat_id"`
+	ChatType        string    `json:"chat_type"`
+	UserID          int64     `json:"user_id"`
+	MessageID       int64     `json:"message_id"`
+	ThreadID        int64     `json:"thread_id,omitempty"`
+	StatusMessageID int64     `json:"status_message_id,omitempty"`
+	Prompt          string    `json:"prompt"`
+	PresetName      string    `json:"preset_name"`
+	QuotedText      string    `json:"quoted_text,omitempty"`
+	QuotedAuthor    string    `json:"quoted_author,omitempty"`
+	AckReaction     bool      `json:"ack_reaction,omitempty"`
+	EnqueuedAt      time.Time `json:"enqueued_at"`
+	Attempts        int       `json:"attempts"`
+	// Debug asks the worker to follow the answer with request metadata.
+	Debug bool `json:"debug,omitempty"`
+	// Slot is the default slot (see storage.DefaultSlots) answering jobs
+	// without PresetName; empty means the general default.
+	Slot string `json:"slot,omitempty"`
+	// Template names a chat template the worker renders into Prompt with
+	// TemplateVars; Prompt holds the template's input until then.
+	Template     string            `json:"template,omitempty"`
+	TemplateVars map[string]string `json:"template_vars,omitempty"`
+	// TranslateTo marks a /tr job: the worker translates Prompt into this
+	// language instead of answering it.
+	TranslateTo string `json:"translate_to,omitempty"`
 }
 
 type StreamQueue struct {
@@ -31,11 +50,19 @@ type StreamQueue struct {
 	group    string
 	consumer string
 	block    time.Duration
+	compress Compression
 }
 
+// Message is a stream entry read by a worker. Job is only set for
+// JobTypeAsk; other types decode Envelope.Payload themselves. Err is set
+// when the entry could not be decoded; the worker must Quarantine it.
 type Message struct {
-	ID  string
-	Job AskJob
+	ID       string
+	Envelope Envelope
+	Job      AskJob
+	Err      error
+	// Values are the raw entry fields, kept for Quarantine.
+	Values map[string]any
 }
 
 func NewStreamQueue(rdb *redis.Client, stream, group, consumer string, block time.Duration) *StreamQueue {
@@ -66,14 +93,27 @@ func (q *StreamQueue) Enqueue(ctx context.Context, job AskJob) (string, error) {
 	if job.EnqueuedAt.IsZero() {
 		job.EnqueuedAt = time.Now().UTC()
 	}
+	job.Version = AskJobVersion
 	payload, err := json.Marshal(job)
 	if err != nil {
 		return "", fmt.Errorf("marshal job: %w", err)
 	}
+	return q.EnqueueEnvelope(ctx, Envelope{Type: JobTypeAsk, Version: AskJobVersion, Payload: payload})
+}
+
+// SetCompression makes Enqueue compress large payloads, see Compression.
+func (q *StreamQueue) SetCompression(c Compression) {
+	q.compress = c
+}
 
+func (q *StreamQueue) EnqueueEnvelope(ctx context.Context, env Envelope) (string, error) {
+	values, err := env.values(q.compress)
+	if err != nil {
+		return "", fmt.Errorf("encode job: %w", err)
+	}
 	id, err := q.redis.XAdd(ctx, &redis.XAddArgs{
 		Stream: q.stream,
-		Values: map[string]any{"payload": payload},
+		Values: values,
 	}).Result()
 	if err != nil {
 		return "", fmt.Errorf("enqueue: %w", err)
@@ -100,43 +140,47 @@ func (q *StreamQueue) Read(ctx context.Context, count int64) ([]Message, error)
 	out := make([]Message, 0)
 	for _, s := range res {
 		for _, m := range s.Messages {
-			raw, ok := m.Values["payload"]
-			if !ok {
-				continue
-			}
-
-			var b []byte
-			switch v := raw.(type) {
-			case string:
-				b = []byte(v)
-			case []byte:
-				b = v
-			default:
-				continue
+			env, err := envelopeFromValues(m.Values)
+			msg := Message{ID: m.ID, Envelope: env, Err: err, Values: m.Values}
+			if err == nil && env.Type == JobTypeAsk {
+				msg.Job, msg.Err = DecodeAskJob(env.Payload)
 			}
-
-			var job AskJob
-			if err := json.Unmarshal(b, &job); err != nil {
-				continue
-			}
-
-			out = append(out, Message{ID: m.ID, Job: job})
+			out = append(out, msg)
 		}
 	}
 
 	return out, nil
 }
 
-func (q *StreamQueue) Ack(ctx context.Context, messageID string) error {
-	if err := q.redis.XAck(ctx, q.stream, q.group, messageID).Err(); err != nil {
+func (q *StreamQueue) Ack(ctx context.Context, messageIDs ...string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	pipe := q.redis.Pipeline()
+	ack := pipe.XAck(ctx, q.stream, q.group, messageIDs...)
+	del := pipe.XDel(ctx, q.stream, messageIDs...)
+	_, _ = pipe.Exec(ctx)
+	if err := ack.Err(); err != nil {
 		return fmt.Errorf("xack: %w", err)
 	}
-	if err := q.redis.XDel(ctx, q.stream, messageID).Err(); err != nil {
+	if err := del.Err(); err != nil {
 		return fmt.Errorf("xdel: %w", err)
 	}
 	return nil
 }
 
+// ForType returns a queue on a separate stream for job type t, sharing the
+// group and consumer name. Ask jobs stay on the base stream so entries
+// queued before per-type streams existed are still consumed.
+func (q *StreamQueue) ForType(t JobType) *StreamQueue {
+	if t == JobTypeAsk {
+		return q
+	}
+	typed := NewStreamQueue(q.redis, q.stream+":"+string(t), q.group, q.consumer, q.block)
+	typed.compress = q.compress
+	return typed
+}
+
 func (q *StreamQueue) Consumer() string {
 	return q.consumer
 }
@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// extendUntilScript only moves the deadline forward so a short hint from one
+// worker never cancels a longer one recorded by another.
+var extendUntilScript = redis.NewScript(`
+local cur = tonumber(redis.call("GET", KEYS[1]) or "0")
+local untilMs = tonumber(ARGV[1])
+if cur >= untilMs then
+  return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// ProviderThrottle shares provider rate-limit deadlines between workers.
+type ProviderThrottle struct {
+	redis *redis.Client
+}
+
+func NewProviderThrottle(rdb *redis.Client) *ProviderThrottle {
+	return &ProviderThrottle{redis: rdb}
+}
+
+func (t *ProviderThrottle) Mark(ctx context.Context, providerID int64, until, now time.Time) error {
+	ttl := until.Sub(now)
+	if ttl <= 0 {
+		return nil
+	}
+	err := extendUntilScript.Run(ctx, t.redis, []string{throttleKey(providerID)}, until.UnixMilli(), ttl.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("mark provider throttle: %w", err)
+	}
+	return nil
+}
+
+// Remaining returns how long callers should wait before using the provider.
+func (t *ProviderThrottle) Remaining(ctx context.Context, providerID int64, now time.Time) (time.Duration, error) {
+	raw, err := t.redis.Get(ctx, throttleKey(providerID)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get provider throttle: %w", err)
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	wait := time.UnixMilli(ms).Sub(now)
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+func throttleKey(providerID int64) string {
+	return fmt.Sprintf("hyprbot:throttle:%d", providerID)
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestProviderThrottle(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	th := NewProviderThrottle(rdb)
+	now := time.Date(2026, 2, 13, 10, 0, 0, 0, time.UTC)
+
+	if wait, err := th.Remaining(ctx, 7, now); err != nil || wait != 0 {
+		t.Fatalf("expected no throttle, got wait=%s err=%v", wait, err)
+	}
+
+	if err := th.Mark(ctx, 7, now.Add(10*time.Second), now); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	// A shorter deadline must not override the longer one.
+	if err := th.Mark(ctx, 7, now.Add(2*time.Second), now); err != nil {
+		t.Fatalf("mark shorter: %v", err)
+	}
+	wait, err := th.Remaining(ctx, 7, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("remaining: %v", err)
+	}
+	if wait != 9*time.Second {
+		t.Fatalf("expected 9s remaining, got %s", wait)
+	}
+
+	if wait, _ := th.Remaining(ctx, 8, now); wait != 0 {
+		t.Fatalf("throttle leaked to another provider: %s", wait)
+	}
+}
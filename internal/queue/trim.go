@@ -0,0 +1,33 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Trim enforces the stream retention: at most maxLen entries and none older
+// than maxAge, either limit disabled when zero. Acked entries are already
+// deleted, so this only catches what crashed or buggy flows left behind.
+// Trimming is approximate, as with MAXLEN ~, and reports how many entries
+// were removed.
+func (q *StreamQueue) Trim(ctx context.Context, maxLen int64, maxAge time.Duration, now time.Time) (int64, error) {
+	var trimmed int64
+	if maxAge > 0 {
+		minID := strconv.FormatInt(now.Add(-maxAge).UnixMilli(), 10) + "-0"
+		n, err := q.redis.XTrimMinIDApprox(ctx, q.stream, minID, 0).Result()
+		if err != nil {
+			return trimmed, fmt.Errorf("xtrim minid: %w", err)
+		}
+		trimmed += n
+	}
+	if maxLen > 0 {
+		n, err := q.redis.XTrimMaxLenApprox(ctx, q.stream, maxLen, 0).Result()
+		if err != nil {
+			return trimmed, fmt.Errorf("xtrim maxlen: %w", err)
+		}
+		trimmed += n
+	}
+	return trimmed, nil
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamQueueTrim(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	q := NewStreamQueue(rdb, "jobs", "workers", "w1", 0)
+	now := time.Now()
+
+	// Two entries left behind two days ago, five recent ones.
+	for i, at := range []time.Time{now.Add(-48 * time.Hour), now.Add(-47 * time.Hour)} {
+		id := fmt.Sprintf("%d-%d", at.UnixMilli(), i)
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "jobs", ID: id, Values: map[string]any{"payload": "{}"}}).Err(); err != nil {
+			t.Fatalf("xadd old: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enqueue(ctx, AskJob{ChatID: int64(i)}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	n, err := q.Trim(ctx, 0, 24*time.Hour, now)
+	if err != nil || n != 2 {
+		t.Fatalf("trim by age: n=%d err=%v", n, err)
+	}
+	n, err = q.Trim(ctx, 3, 24*time.Hour, now)
+	if err != nil || n != 2 {
+		t.Fatalf("trim by length: n=%d err=%v", n, err)
+	}
+	if l, _ := rdb.XLen(ctx, "jobs").Result(); l != 3 {
+		t.Fatalf("stream length = %d, want 3", l)
+	}
+	if n, err := q.Trim(ctx, 0, 0, now); err != nil || n != 0 {
+		t.Fatalf("disabled retention must not trim: n=%d err=%v", n, err)
+	}
+}
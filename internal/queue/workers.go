@@ -0,0 +1,87 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const workersKey = "hyprbot:workers"
+
+// WorkerInfo is the heartbeat a worker process publishes. Consumer is its
+// name in the stream's consumer group.
+type WorkerInfo struct {
+	Consumer      string    `json:"consumer"`
+	Hostname      string    `json:"hostname"`
+	StartedAt     time.Time `json:"started_at"`
+	BeatAt        time.Time `json:"beat_at"`
+	LastProgress  time.Time `json:"last_progress"`
+	Consumers     int       `json:"consumers"`
+	JobsProcessed int64     `json:"jobs_processed"`
+	JobsFailed    int64     `json:"jobs_failed"`
+	Stalled       bool      `json:"stalled,omitempty"`
+}
+
+// WorkerRegistry keeps the latest heartbeat of every worker in one hash.
+// Entries older than staleAfter belong to workers that died without
+// deregistering and are dropped when listed.
+type WorkerRegistry struct {
+	redis      *redis.Client
+	staleAfter time.Duration
+}
+
+func NewWorkerRegistry(rdb *redis.Client, staleAfter time.Duration) *WorkerRegistry {
+	if staleAfter <= 0 {
+		staleAfter = time.Minute
+	}
+	return &WorkerRegistry{redis: rdb, staleAfter: staleAfter}
+}
+
+func (r *WorkerRegistry) Beat(ctx context.Context, info WorkerInfo) error {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal worker heartbeat: %w", err)
+	}
+	if err := r.redis.HSet(ctx, workersKey, info.Consumer, payload).Err(); err != nil {
+		return fmt.Errorf("worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// Remove deregisters a worker that is shutting down.
+func (r *WorkerRegistry) Remove(ctx context.Context, consumer string) error {
+	if err := r.redis.HDel(ctx, workersKey, consumer).Err(); err != nil {
+		return fmt.Errorf("remove worker: %w", err)
+	}
+	return nil
+}
+
+// Live lists the workers that sent a heartbeat within staleAfter of now,
+// sorted by consumer name.
+func (r *WorkerRegistry) Live(ctx context.Context, now time.Time) ([]WorkerInfo, error) {
+	raw, err := r.redis.HGetAll(ctx, workersKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list workers: %w", err)
+	}
+	var live []WorkerInfo
+	var stale []string
+	for consumer, payload := range raw {
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(payload), &info); err != nil || now.Sub(info.BeatAt) > r.staleAfter {
+			stale = append(stale, consumer)
+			continue
+		}
+		live = append(live, info)
+	}
+	if len(stale) > 0 {
+		if err := r.redis.HDel(ctx, workersKey, stale...).Err(); err != nil {
+			return nil, fmt.Errorf("drop stale workers: %w", err)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Consumer < live[j].Consumer })
+	return live, nil
+}
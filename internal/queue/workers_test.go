@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestWorkerRegistryDropsStaleWorkers(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	reg := NewWorkerRegistry(rdb, time.Minute)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, info := range []WorkerInfo{
+		{Consumer: "worker-b", BeatAt: now.Add(-10 * time.Second), JobsProcessed: 7},
+		{Consumer: "worker-a", BeatAt: now},
+		{Consumer: "worker-dead", BeatAt: now.Add(-5 * time.Minute)},
+	} {
+		if err := reg.Beat(ctx, info); err != nil {
+			t.Fatalf("beat: %v", err)
+		}
+	}
+
+	live, err := reg.Live(ctx, now)
+	if err != nil {
+		t.Fatalf("live: %v", err)
+	}
+	if len(live) != 2 || live[0].Consumer != "worker-a" || live[1].Consumer != "worker-b" || live[1].JobsProcessed != 7 {
+		t.Fatalf("unexpected workers %+v", live)
+	}
+	if mr.HGet(workersKey, "worker-dead") != "" {
+		t.Fatal("expected stale worker to be dropped")
+	}
+
+	if err := reg.Remove(ctx, "worker-a"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if live, _ := reg.Live(ctx, now); len(live) != 1 {
+		t.Fatalf("expected one worker after remove, got %+v", live)
+	}
+}
@@ -0,0 +1,148 @@
+// Package sandbox runs model-written code on an isolated runner service the
+// owner deploys (a container per run or a hosted code execution API). The
+// bot never executes code itself; it only forwards code with the limits the
+// runner must enforce and reads back what the program printed.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LanguagePython is the only language offered to models so far.
+const LanguagePython = "python"
+
+var ErrCodeTooLarge = errors.New("code exceeds the sandbox size limit")
+
+type Config struct {
+	// URL receives a POST per run. Token, when set, is sent as a bearer token.
+	URL   string
+	Token string
+	// Timeout bounds one run on the runner; the request itself gets a few
+	// extra seconds for the round trip.
+	Timeout        time.Duration
+	MemoryMB       int
+	MaxCodeBytes   int
+	MaxOutputBytes int
+	HTTPClient     *http.Client
+}
+
+type Client struct {
+	cfg Config
+}
+
+// Result is what a run printed. Output beyond MaxOutputBytes is cut and
+// Truncated is set.
+type Result struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	TimedOut  bool
+	Truncated bool
+}
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid sandbox url %q", cfg.URL)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MemoryMB <= 0 {
+		cfg.MemoryMB = 256
+	}
+	if cfg.MaxCodeBytes <= 0 {
+		cfg.MaxCodeBytes = 16 << 10
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 8 << 10
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	return &Client{cfg: cfg}, nil
+}
+
+type runRequest struct {
+	Language       string `json:"language"`
+	Code           string `json:"code"`
+	TimeoutMs      int64  `json:"timeout_ms"`
+	MemoryMB       int    `json:"memory_mb"`
+	MaxOutputBytes int    `json:"max_output_bytes"`
+	Network        bool   `json:"network"`
+}
+
+type runResponse struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+// Run executes code on the runner. Network access is always refused.
+func (c *Client) Run(ctx context.Context, language, code string) (Result, error) {
+	if len(code) > c.cfg.MaxCodeBytes {
+		return Result{}, ErrCodeTooLarge
+	}
+	body, err := json.Marshal(runRequest{
+		Language:       language,
+		Code:           code,
+		TimeoutMs:      c.cfg.Timeout.Milliseconds(),
+		MemoryMB:       c.cfg.MemoryMB,
+		MaxOutputBytes: c.cfg.MaxOutputBytes,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout+5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("sandbox request: %w", err)
+	}
+	defer resp.Body.Close()
+	// Stdout and stderr are each capped, plus room for the JSON around them.
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, int64(4*c.cfg.MaxOutputBytes+4096)))
+	if err != nil {
+		return Result{}, fmt.Errorf("sandbox response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return Result{}, fmt.Errorf("sandbox status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var out runResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return Result{}, fmt.Errorf("decode sandbox response: %w", err)
+	}
+	res := Result{ExitCode: out.ExitCode, TimedOut: out.TimedOut}
+	var cut bool
+	res.Stdout, cut = clip(out.Stdout, c.cfg.MaxOutputBytes)
+	res.Truncated = cut
+	res.Stderr, cut = clip(out.Stderr, c.cfg.MaxOutputBytes)
+	res.Truncated = res.Truncated || cut
+	return res, nil
+}
+
+// clip cuts s to at most n bytes without splitting a UTF-8 sequence.
+func clip(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	return strings.ToValidUTF8(s[:n], ""), true
+}
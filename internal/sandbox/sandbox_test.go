@@ -0,0 +1,62 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSendsLimitsAndClipsOutput(t *testing.T) {
+	var got runRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(runResponse{Stdout: strings.Repeat("x", 20), Stderr: "warn", ExitCode: 1})
+	}))
+	defer srv.Close()
+
+	c, err := New(Config{URL: srv.URL, Token: "secret", Timeout: 3 * time.Second, MaxOutputBytes: 10})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res, err := c.Run(context.Background(), LanguagePython, "print('x'*20)")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got.Language != LanguagePython || got.TimeoutMs != 3000 || got.MemoryMB != 256 || got.MaxOutputBytes != 10 || got.Network {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if res.Stdout != strings.Repeat("x", 10) || !res.Truncated || res.Stderr != "warn" || res.ExitCode != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestRunRejectsLargeCodeAndRunnerErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := New(Config{URL: srv.URL, MaxCodeBytes: 4})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := c.Run(context.Background(), LanguagePython, "print(1)"); !errors.Is(err, ErrCodeTooLarge) {
+		t.Fatalf("expected ErrCodeTooLarge, got %v", err)
+	}
+	if _, err := c.Run(context.Background(), LanguagePython, "1"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if _, err := New(Config{URL: "ftp://runner"}); err == nil {
+		t.Fatal("expected invalid url error")
+	}
+}
@@ -0,0 +1,138 @@
+// Package sanitize defuses model output before the bot posts it: text the
+// model was tricked into writing, such as "/llm_del x", a tg://user link or
+// an @mention, must not read as a bot command, open a client action or
+// ping a member once it is in the chat.
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filters a chat can pick for the output_filter setting.
+const (
+	FilterCommands = "commands"
+	FilterLinks    = "links"
+	FilterMentions = "mentions"
+)
+
+// wordJoiner is inserted after "/" and "@": the text looks the same but
+// Telegram no longer detects a command or a mention in it.
+const wordJoiner = "\u2060"
+
+// Options selects what Text neutralizes.
+type Options struct {
+	// Commands breaks "/command" at the start of a word.
+	Commands bool
+	// Links removes tg:// links, keeping the label of Markdown and HTML
+	// links so the sentence still reads.
+	Links bool
+	// Mentions breaks "@username" so nobody is notified.
+	Mentions bool
+	// Markdown leaves code spans and fences alone, since Telegram shows
+	// them verbatim; plain text has no code to skip.
+	Markdown bool
+}
+
+// All enables every filter.
+func All() Options {
+	return Options{Commands: true, Links: true, Mentions: true}
+}
+
+// Enabled reports whether Text changes anything with these options.
+func (o Options) Enabled() bool {
+	return o.Commands || o.Links || o.Mentions
+}
+
+// ParseFilter reads an output_filter value: empty means every filter, "off"
+// none, otherwise a comma-separated list of filter names.
+func ParseFilter(value string) (Options, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	switch value {
+	case "":
+		return All(), nil
+	case "off":
+		return Options{}, nil
+	}
+	var o Options
+	for _, name := range strings.Split(value, ",") {
+		switch strings.TrimSpace(name) {
+		case FilterCommands:
+			o.Commands = true
+		case FilterLinks:
+			o.Links = true
+		case FilterMentions:
+			o.Mentions = true
+		default:
+			return Options{}, fmt.Errorf("unknown output filter %q", strings.TrimSpace(name))
+		}
+	}
+	return o, nil
+}
+
+// String is the canonical setting value of o, the inverse of ParseFilter.
+func (o Options) String() string {
+	if o.Commands && o.Links && o.Mentions {
+		return ""
+	}
+	var names []string
+	for _, f := range []struct {
+		on   bool
+		name string
+	}{{o.Commands, FilterCommands}, {o.Links, FilterLinks}, {o.Mentions, FilterMentions}} {
+		if f.on {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return "off"
+	}
+	return strings.Join(names, ",")
+}
+
+var (
+	codeRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	// A command starts a word; "a/b", "./run" and URLs are left alone.
+	commandRe      = regexp.MustCompile(`(^|[^\w/:.\-])/([A-Za-z][A-Za-z0-9_]*)`)
+	markdownLinkRe = regexp.MustCompile(`\[([^\]\n]*)\]\(\s*tg://[^)\s]*\s*\)`)
+	htmlLinkRe     = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']?tg://[^>]*>(.*?)</a>`)
+	bareLinkRe     = regexp.MustCompile(`(?i)tg://\S+`)
+	// Telegram usernames are 5-32 characters; the prefix rules out e-mail
+	// addresses and already broken mentions.
+	mentionRe = regexp.MustCompile(`(^|[^\w@.` + wordJoiner + `])@([A-Za-z][A-Za-z0-9_]{4,31})\b`)
+)
+
+// Text applies the filters in o to s.
+func Text(s string, o Options) string {
+	if !o.Enabled() || s == "" {
+		return s
+	}
+	if !o.Markdown {
+		return filter(s, o)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range codeRe.FindAllStringIndex(s, -1) {
+		b.WriteString(filter(s[last:loc[0]], o))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(filter(s[last:], o))
+	return b.String()
+}
+
+func filter(s string, o Options) string {
+	if o.Links && strings.Contains(strings.ToLower(s), "tg://") {
+		s = markdownLinkRe.ReplaceAllString(s, "$1")
+		s = htmlLinkRe.ReplaceAllString(s, "$1")
+		s = bareLinkRe.ReplaceAllString(s, "")
+	}
+	if o.Commands && strings.Contains(s, "/") {
+		s = commandRe.ReplaceAllString(s, "${1}/"+wordJoiner+"${2}")
+	}
+	if o.Mentions && strings.Contains(s, "@") {
+		s = mentionRe.ReplaceAllString(s, "${1}@"+wordJoiner+"${2}")
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextNeutralizesCommandsLinksAndMentions(t *testing.T) {
+	in := "Run /llm_del x, ask @admin_team or [him](tg://user?id=42), see tg://resolve?domain=x and a/b"
+	got := Text(in, All())
+	want := "Run /⁠llm_del x, ask @⁠admin_team or him, see  and a/b"
+	if got != want {
+		t.Fatalf("Text = %q, want %q", got, want)
+	}
+	if strings.ReplaceAll(Text("/start", All()), wordJoiner, "") != "/start" {
+		t.Fatal("neutralized command should read the same")
+	}
+}
+
+func TestTextKeepsEmailsURLsAndShortHandles(t *testing.T) {
+	in := "mail a.b@example.com, open https://example.com/path, hi @bob"
+	if got := Text(in, All()); got != in {
+		t.Fatalf("Text = %q, want it unchanged", got)
+	}
+}
+
+func TestTextSkipsCodeInMarkdown(t *testing.T) {
+	in := "use `/usr/bin` or\n```\n@Override /cmd\n```\nthen /cmd"
+	o := All()
+	o.Markdown = true
+	want := "use `/usr/bin` or\n```\n@Override /cmd\n```\nthen /⁠cmd"
+	if got := Text(in, o); got != want {
+		t.Fatalf("Text = %q, want %q", got, want)
+	}
+	if got := Text("`/cmd`", All()); got != "`/⁠cmd`" {
+		t.Fatalf("plain Text = %q", got)
+	}
+}
+
+func TestTextHTMLLink(t *testing.T) {
+	got := Text(`hi <a href="tg://user?id=1">Ann</a>!`, Options{Links: true})
+	if got != "hi Ann!" {
+		t.Fatalf("Text = %q", got)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	for value, want := range map[string]Options{
+		"":                        All(),
+		"off":                     {},
+		"Commands":                {Commands: true},
+		"links, mentions":         {Links: true, Mentions: true},
+		"commands,links,mentions": All(),
+	} {
+		got, err := ParseFilter(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseFilter(%q) = %+v, %v", value, got, err)
+		}
+		if again, _ := ParseFilter(got.String()); again != got {
+			t.Fatalf("String(%+v) = %q does not round-trip", got, got.String())
+		}
+	}
+	if _, err := ParseFilter("commands,pings"); err == nil {
+		t.Fatal("expected an error for an unknown filter")
+	}
+	if Text("/cmd @someone", Options{}) != "/cmd @someone" {
+		t.Fatal("disabled filters must not change the text")
+	}
+}
@@ -0,0 +1,160 @@
+// Package statsapi serves anonymized per-chat usage as JSON for external
+// dashboards. Chats are identified by a keyed hash of their id, so the
+// feed can be shared without revealing which chats use the bot.
+package statsapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/storage"
+)
+
+// Windows are the periods reported when the request names none.
+var Windows = []Window{
+	{Name: "1h", Period: time.Hour},
+	{Name: "24h", Period: 24 * time.Hour},
+	{Name: "7d", Period: 7 * 24 * time.Hour},
+	{Name: "30d", Period: 30 * 24 * time.Hour},
+}
+
+type Window struct {
+	Name   string
+	Period time.Duration
+}
+
+type usageSource interface {
+	UsageByChat(ctx context.Context, period time.Duration) ([]storage.ChatUsage, error)
+}
+
+type Handler struct {
+	store usageSource
+	token string
+	key   []byte
+	now   func() time.Time
+	log   zerolog.Logger
+}
+
+// New serves the stats of store to requests bearing token. Chat ids are
+// hashed with hashKey.
+func New(store *storage.Store, token string, hashKey []byte, log zerolog.Logger) *Handler {
+	return &Handler{store: store, token: token, key: hashKey, now: time.Now, log: log}
+}
+
+type report struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Windows     map[string]windowStats `json:"windows"`
+}
+
+type windowStats struct {
+	Totals chatStats   `json:"totals"`
+	Chats  []chatStats `json:"chats"`
+}
+
+type chatStats struct {
+	Chat         string  `json:"chat,omitempty"`
+	Requests     int64   `json:"requests"`
+	Failed       int64   `json:"failed"`
+	ErrorRate    float64 `json:"error_rate"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	AvgLatencyMs float64 `json:"avg_latency_ms,omitempty"`
+}
+
+// ServeHTTP answers GET with the report for every window, or only for
+// ?window=<name>. The token goes in an "Authorization: Bearer" header.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="stats"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	windows := Windows
+	if name := r.URL.Query().Get("window"); name != "" {
+		windows = nil
+		for _, win := range Windows {
+			if win.Name == name {
+				windows = []Window{win}
+			}
+		}
+		if windows == nil {
+			http.Error(w, "unknown window", http.StatusBadRequest)
+			return
+		}
+	}
+
+	out := report{GeneratedAt: h.now().UTC(), Windows: map[string]windowStats{}}
+	for _, win := range windows {
+		usage, err := h.store.UsageByChat(r.Context(), win.Period)
+		if err != nil {
+			h.log.Error().Err(err).Str("window", win.Name).Msg("stats query failed")
+			http.Error(w, "stats unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		out.Windows[win.Name] = h.aggregate(usage)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) == 1
+}
+
+func (h *Handler) aggregate(usage []storage.ChatUsage) windowStats {
+	stats := windowStats{Chats: make([]chatStats, 0, len(usage))}
+	var latencySum float64
+	for _, u := range usage {
+		c := chatStats{
+			Chat:         h.chatHash(u.ChatID),
+			Requests:     u.Requests,
+			Failed:       u.Failed,
+			ErrorRate:    errorRate(u.Failed, u.Requests),
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			AvgLatencyMs: u.AvgLatencyMs,
+		}
+		stats.Chats = append(stats.Chats, c)
+		stats.Totals.Requests += u.Requests
+		stats.Totals.Failed += u.Failed
+		stats.Totals.InputTokens += u.InputTokens
+		stats.Totals.OutputTokens += u.OutputTokens
+		latencySum += u.AvgLatencyMs * float64(u.Requests)
+	}
+	stats.Totals.ErrorRate = errorRate(stats.Totals.Failed, stats.Totals.Requests)
+	if stats.Totals.Requests > 0 {
+		stats.Totals.AvgLatencyMs = latencySum / float64(stats.Totals.Requests)
+	}
+	return stats
+}
+
+// chatHash is a stable pseudonym for chatID: the first 16 hex digits of
+// its HMAC-SHA256 under the hash key.
+func (h *Handler) chatHash(chatID int64) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(strconv.FormatInt(chatID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func errorRate(failed, requests int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(failed) / float64(requests)
+}
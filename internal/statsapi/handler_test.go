@@ -0,0 +1,77 @@
+package statsapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/storage"
+)
+
+type fakeUsage []storage.ChatUsage
+
+func (f fakeUsage) UsageByChat(context.Context, time.Duration) ([]storage.ChatUsage, error) {
+	return f, nil
+}
+
+func TestHandlerHashesChatsAndAggregates(t *testing.T) {
+	h := &Handler{
+		store: fakeUsage{
+			{ChatID: -100123, Requests: 3, Failed: 1, InputTokens: 30, OutputTokens: 12, AvgLatencyMs: 100},
+			{ChatID: -100456, Requests: 1, InputTokens: 5, OutputTokens: 5, AvgLatencyMs: 500},
+		},
+		token: "secret",
+		key:   []byte("k"),
+		now:   time.Now,
+		log:   zerolog.Nop(),
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/stats?window=24h", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "100123") {
+		t.Fatalf("chat id leaked: %s", rec.Body)
+	}
+	var got report
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	win, ok := got.Windows["24h"]
+	if !ok || len(got.Windows) != 1 || len(win.Chats) != 2 {
+		t.Fatalf("unexpected report %+v", got)
+	}
+	if win.Totals.Requests != 4 || win.Totals.Failed != 1 || win.Totals.ErrorRate != 0.25 || win.Totals.AvgLatencyMs != 200 {
+		t.Fatalf("unexpected totals %+v", win.Totals)
+	}
+	if c := win.Chats[0]; c.Chat != h.chatHash(-100123) || len(c.Chat) != 16 || c.ErrorRate != 1.0/3 {
+		t.Fatalf("unexpected chat %+v", c)
+	}
+	if _, err := strconv.ParseUint(win.Chats[0].Chat, 16, 64); err != nil {
+		t.Fatalf("chat is not a hex hash: %q", win.Chats[0].Chat)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/stats?window=1y", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown window, got %d", rec.Code)
+	}
+}
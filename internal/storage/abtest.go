@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// A/B experiment arms, as stored in answer_feedback.ab_arm.
+const (
+	ABArmA = "A"
+	ABArmB = "B"
+)
+
+// ABExperiment splits a chat's default-preset traffic between two presets.
+// SplitPct is the share of requests, in percent, routed to PresetA.
+type ABExperiment struct {
+	ChatID     int64
+	PresetA    string
+	PresetB    string
+	SplitPct   int
+	ServedA    int64
+	ServedB    int64
+	LatencyMsA int64
+	LatencyMsB int64
+	StartedAt  time.Time
+}
+
+// Route picks the arm for a request; roll is uniform in [0, 100).
+func (e ABExperiment) Route(roll int) (arm, preset string) {
+	if roll < e.SplitPct {
+		return ABArmA, e.PresetA
+	}
+	return ABArmB, e.PresetB
+}
+
+// ABVotes counts feedback votes for one arm.
+type ABVotes struct {
+	Up   int64
+	Down int64
+}
+
+var abExperimentColumns = []string{"chat_id", "preset_a", "preset_b", "split_pct", "served_a", "served_b", "latency_ms_a", "latency_ms_b", "started_at"}
+
+func scanABExperiment(row interface{ Scan(...any) error }) (ABExperiment, error) {
+	var e ABExperiment
+	err := row.Scan(&e.ChatID, &e.PresetA, &e.PresetB, &e.SplitPct, &e.ServedA, &e.ServedB, &e.LatencyMsA, &e.LatencyMsB, &e.StartedAt)
+	return e, err
+}
+
+// StartABExperiment replaces the chat's experiment, resetting its counters.
+func (s *Store) StartABExperiment(ctx context.Context, chatID int64, presetA, presetB string, splitPct int) error {
+	if err := s.chatExists(ctx, chatID); err != nil {
+		return err
+	}
+	q := s.sql.Insert("ab_experiments").
+		Columns("chat_id", "preset_a", "preset_b", "split_pct", "started_at").
+		Values(chatID, presetA, presetB, splitPct, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(chat_id) DO UPDATE SET preset_a=excluded.preset_a, preset_b=excluded.preset_b, split_pct=excluded.split_pct, " +
+			"served_a=0, served_b=0, latency_ms_a=0, latency_ms_b=0, started_at=excluded.started_at")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build start ab experiment query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("start ab experiment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetABExperiment(ctx context.Context, chatID int64) (ABExperiment, error) {
+	sqlStr, args, err := s.sql.Select(abExperimentColumns...).From("ab_experiments").Where(sq.Eq{"chat_id": chatID}).ToSql()
+	if err != nil {
+		return ABExperiment{}, fmt.Errorf("build get ab experiment query: %w", err)
+	}
+	e, err := scanABExperiment(s.db.QueryRowContext(ctx, sqlStr, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ABExperiment{}, ErrNotFound
+	}
+	if err != nil {
+		return ABExperiment{}, fmt.Errorf("get ab experiment: %w", err)
+	}
+	return e, nil
+}
+
+// StopABExperiment ends the chat's experiment; ErrNotFound if none runs.
+func (s *Store) StopABExperiment(ctx context.Context, chatID int64) error {
+	sqlStr, args, err := s.sql.Delete("ab_experiments").Where(sq.Eq{"chat_id": chatID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build stop ab experiment query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("stop ab experiment: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordABServe counts an answer produced by arm and its provider latency.
+func (s *Store) RecordABServe(ctx context.Context, chatID int64, arm string, latencyMs int64) error {
+	served, latency := "served_a", "latency_ms_a"
+	if arm == ABArmB {
+		served, latency = "served_b", "latency_ms_b"
+	}
+	q := s.sql.Update("ab_experiments").
+		Set(served, sq.Expr(served+" + 1")).
+		Set(latency, sq.Expr(latency+" + ?", latencyMs)).
+		Where(sq.Eq{"chat_id": chatID})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build record ab serve query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("record ab serve: %w", err)
+	}
+	return nil
+}
+
+// ABFeedback counts votes per arm on answers given since the experiment
+// started.
+func (s *Store) ABFeedback(ctx context.Context, e ABExperiment) (map[string]ABVotes, error) {
+	// Filtered in Go for the same reason as PurgeLeftChatSecrets.
+	q := s.sql.Select("ab_arm", "vote", "created_at").
+		From("answer_feedback").
+		Where(sq.And{sq.Eq{"chat_id": e.ChatID}, sq.NotEq{"ab_arm": ""}})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build ab feedback query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ab feedback: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]ABVotes{}
+	for rows.Next() {
+		var arm string
+		var vote int
+		var createdAt time.Time
+		if err := rows.Scan(&arm, &vote, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan ab feedback: %w", err)
+		}
+		if createdAt.Before(e.StartedAt) {
+			continue
+		}
+		v := out[arm]
+		if vote > 0 {
+			v.Up++
+		} else {
+			v.Down++
+		}
+		out[arm] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ab feedback: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Credit ledger reasons.
+const (
+	CreditReasonPurchase = "purchase"
+	CreditReasonGrant    = "grant"
+	CreditReasonUsage    = "usage"
+)
+
+var creditEntryColumns = []string{"id", "chat_id", "user_id", "delta", "reason", "charge_id", "created_at"}
+
+// AddCredits records a purchase or an owner grant. A charge id already in the ledger is not
+// credited twice; added is false then. It returns ErrNotFound when the chat
+// is not registered.
+func (s *Store) AddCredits(ctx context.Context, e CreditEntry) (bool, error) {
+	if err := s.chatExists(ctx, e.ChatID); err != nil {
+		return false, err
+	}
+	q := s.sql.Insert("credit_ledger").
+		Columns("chat_id", "user_id", "delta", "reason", "charge_id", "created_at").
+		Values(e.ChatID, e.UserID, e.Delta, e.Reason, e.ChargeID, nowExpr(s.driver)).
+		Suffix("ON CONFLICT DO NOTHING")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build add credits query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return false, fmt.Errorf("add credits: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0, nil
+}
+
+// CreditBalance returns the chat's remaining credits. It returns
+// ErrNotFound when the chat is not registered.
+func (s *Store) CreditBalance(ctx context.Context, chatID int64) (int64, error) {
+	if err := s.chatExists(ctx, chatID); err != nil {
+		return 0, err
+	}
+	q := s.sql.Select("COALESCE(SUM(delta), 0)").From("credit_ledger").Where(sq.Eq{"chat_id": chatID})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build credit balance query: %w", err)
+	}
+	var balance int64
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("query credit balance: %w", err)
+	}
+	return balance, nil
+}
+
+// DeductCredits spends n credits of the chat on a request. The balance may
+// go below zero: requests are admitted while it is positive and charged
+// once answered.
+func (s *Store) DeductCredits(ctx context.Context, chatID, userID, n int64) error {
+	q := s.sql.Insert("credit_ledger").
+		Columns("chat_id", "user_id", "delta", "reason", "created_at").
+		Values(chatID, userID, -n, CreditReasonUsage, nowExpr(s.driver))
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build deduct credits query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("deduct credits: %w", err)
+	}
+	return nil
+}
@@ -14,7 +14,7 @@ import (
 )
 
 type Store struct {
-	db     *sql.DB
+	db     *instrumentedDB
 	driver string
 	sql    sq.StatementBuilderType
 }
@@ -76,7 +76,7 @@ func Open(ctx context.Context, driver, dsn string, autoMigrate bool, migrationsD
 	}
 
 	return &Store{
-		db:     db,
+		db:     &instrumentedDB{DB: db},
 		driver: driver,
 		sql:    sq.StatementBuilder.PlaceholderFormat(placeholder),
 	}, nil
@@ -102,7 +102,7 @@ func (s *Store) Close() error {
 }
 
 func (s *Store) DB() *sql.DB {
-	return s.db
+	return s.db.DB
 }
 
 func initSQLiteSchema(ctx context.Context, db *sql.DB) error {
@@ -112,8 +112,16 @@ CREATE TABLE IF NOT EXISTS chats (
     type TEXT NOT NULL,
     title TEXT NOT NULL DEFAULT '',
     default_preset_name TEXT,
+    left_at DATETIME,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, key)
+);
 CREATE TABLE IF NOT EXISTS chat_admin_cache (
     chat_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
@@ -130,6 +138,9 @@ CREATE TABLE IF NOT EXISTS provider_instances (
     enc_api_key TEXT,
     enc_headers_json TEXT,
     config_json TEXT NOT NULL DEFAULT '{}',
+    last_success_at DATETIME,
+    last_error_at DATETIME,
+    last_error_text TEXT NOT NULL DEFAULT '',
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(chat_id, name)
 );
@@ -140,6 +151,8 @@ CREATE TABLE IF NOT EXISTS presets (
     model TEXT NOT NULL,
     system_prompt TEXT NOT NULL DEFAULT '',
     params_json TEXT NOT NULL DEFAULT '{}',
+    degraded_reason TEXT NOT NULL DEFAULT '',
+    degraded_at DATETIME,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (chat_id, name)
 );
@@ -151,10 +164,220 @@ CREATE TABLE IF NOT EXISTS audit_log (
     meta_json TEXT NOT NULL DEFAULT '{}',
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY,
+    username TEXT NOT NULL DEFAULT '',
+    first_name TEXT NOT NULL DEFAULT '',
+    first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_active_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    message_count INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS chat_users (
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_active_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (chat_id, user_id)
+);
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    job_id TEXT NOT NULL DEFAULT '',
+    preset_name TEXT NOT NULL DEFAULT '',
+    prompt TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS answer_feedback (
+    job_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    chat_id INTEGER NOT NULL,
+    preset_name TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    vote INTEGER NOT NULL,
+    ab_arm TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (job_id, user_id)
+);
+CREATE TABLE IF NOT EXISTS preset_revisions (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    preset_name TEXT NOT NULL,
+    rev INTEGER NOT NULL,
+    provider_instance_id INTEGER NOT NULL,
+    model TEXT NOT NULL,
+    system_prompt TEXT NOT NULL DEFAULT '',
+    params_json TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, preset_name, rev)
+);
+CREATE TABLE IF NOT EXISTS usage_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    preset_name TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    failed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS ab_experiments (
+    chat_id INTEGER PRIMARY KEY REFERENCES chats(id) ON DELETE CASCADE,
+    preset_a TEXT NOT NULL,
+    preset_b TEXT NOT NULL,
+    split_pct INTEGER NOT NULL,
+    served_a INTEGER NOT NULL DEFAULT 0,
+    served_b INTEGER NOT NULL DEFAULT 0,
+    latency_ms_a INTEGER NOT NULL DEFAULT 0,
+    latency_ms_b INTEGER NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS credit_ledger (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    delta INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    charge_id TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS referrals (
+    chat_id INTEGER PRIMARY KEY REFERENCES chats(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS chat_templates (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, name)
+);
+CREATE TABLE IF NOT EXISTS chat_knowledge (
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message_id INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, message_id)
+);
+CREATE TABLE IF NOT EXISTS webhooks (
+    name TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    enc_secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '',
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_name TEXT NOT NULL REFERENCES webhooks(name) ON DELETE CASCADE,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME
+);
 CREATE INDEX IF NOT EXISTS idx_provider_instances_chat_id ON provider_instances(chat_id);
 CREATE INDEX IF NOT EXISTS idx_presets_chat_id ON presets(chat_id);
 CREATE INDEX IF NOT EXISTS idx_audit_log_chat_id_created_at ON audit_log(chat_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(LOWER(username));
+CREATE INDEX IF NOT EXISTS idx_chat_users_user ON chat_users(user_id);
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_chat_user ON conversation_messages(chat_id, user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_answer_feedback_chat_preset ON answer_feedback(chat_id, preset_name);
+CREATE INDEX IF NOT EXISTS idx_usage_events_chat_created_at ON usage_events(chat_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_credit_ledger_chat ON credit_ledger(chat_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_code ON referrals(code);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_ledger_charge ON credit_ledger(charge_id) WHERE charge_id <> '';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
 `
-	_, err := db.ExecContext(ctx, schema)
-	return err
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	// CREATE TABLE IF NOT EXISTS leaves older databases untouched, so columns
+	// added after the initial schema are backfilled here.
+	for _, c := range sqliteAddedColumns {
+		_, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.ddl))
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "duplicate column") {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return migrateSQLiteChatSettings(ctx, db)
+}
+
+// sqliteLegacyChatSettings maps chats columns that predate chat_settings to
+// their setting key and the SQL expression producing the stored value.
+var sqliteLegacyChatSettings = []struct {
+	column string
+	key    string
+	value  string
+	skip   string
+}{
+	{"command_prefixes", SettingCommandPrefixes, "command_prefixes", "command_prefixes = ''"},
+	{"exports_allowed", SettingExports, "'off'", "exports_allowed <> 0"},
+	{"persona", SettingPersona, "persona", "persona = ''"},
+	{"mention_trigger", SettingMention, "'on'", "mention_trigger = 0"},
+	{"ack_style", SettingAck, "ack_style", "ack_style = 'message'"},
+}
+
+// migrateSQLiteChatSettings copies legacy chats columns into chat_settings
+// and drops them, mirroring migration 00009 for Postgres.
+func migrateSQLiteChatSettings(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('chats')")
+	if err != nil {
+		return fmt.Errorf("inspect chats columns: %w", err)
+	}
+	present := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan chats column: %w", err)
+		}
+		present[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspect chats columns: %w", err)
+	}
+
+	for _, l := range sqliteLegacyChatSettings {
+		if !present[l.column] {
+			continue
+		}
+		copyStmt := fmt.Sprintf("INSERT OR IGNORE INTO chat_settings (chat_id, key, value) SELECT id, '%s', %s FROM chats WHERE NOT (%s)", l.key, l.value, l.skip)
+		if _, err := db.ExecContext(ctx, copyStmt); err != nil {
+			return fmt.Errorf("copy chats.%s to chat_settings: %w", l.column, err)
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE chats DROP COLUMN "+l.column); err != nil {
+			return fmt.Errorf("drop chats.%s: %w", l.column, err)
+		}
+	}
+	return nil
+}
+
+var sqliteAddedColumns = []struct {
+	table  string
+	column string
+	ddl    string
+}{
+	{"presets", "degraded_reason", "TEXT NOT NULL DEFAULT ''"},
+	{"presets", "degraded_at", "DATETIME"},
+	{"chats", "left_at", "DATETIME"},
+	{"answer_feedback", "ab_arm", "TEXT NOT NULL DEFAULT ''"},
+	{"provider_instances", "last_success_at", "DATETIME"},
+	{"provider_instances", "last_error_at", "DATETIME"},
+	{"provider_instances", "last_error_text", "TEXT NOT NULL DEFAULT ''"},
 }
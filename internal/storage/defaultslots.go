@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// Default slots map command types to presets. The general slot is the chat's
+// default preset; the others fall back to it while unset.
+const (
+	SlotGeneral   = "general"
+	SlotCode      = "code"
+	SlotTranslate = "translate"
+)
+
+// DefaultSlots lists the slots in display order.
+var DefaultSlots = []string{SlotGeneral, SlotCode, SlotTranslate}
+
+var slotSettings = map[string]string{
+	SlotCode:      SettingDefaultCode,
+	SlotTranslate: SettingDefaultTranslate,
+}
+
+func ValidDefaultSlot(slot string) bool {
+	_, ok := slotSettings[slot]
+	return ok || slot == SlotGeneral
+}
+
+// GetSlotPresetName returns the preset set for slot, without falling back to
+// the general default. It returns ErrNotFound when the slot is unset.
+func (s *Store) GetSlotPresetName(ctx context.Context, chatID int64, slot string) (string, error) {
+	key, ok := slotSettings[slot]
+	if !ok {
+		return s.GetDefaultPresetName(ctx, chatID)
+	}
+	name, err := s.GetChatSetting(ctx, chatID, key)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", ErrNotFound
+	}
+	return name, nil
+}
+
+func (s *Store) SetSlotPreset(ctx context.Context, chatID int64, slot, name string) error {
+	key, ok := slotSettings[slot]
+	if !ok {
+		return s.SetDefaultPreset(ctx, chatID, name)
+	}
+	return s.SetChatSetting(ctx, chatID, key, name)
+}
+
+func (s *Store) ClearSlotPreset(ctx context.Context, chatID int64, slot string) error {
+	key, ok := slotSettings[slot]
+	if !ok {
+		return s.ClearDefaultPreset(ctx, chatID)
+	}
+	return s.SetChatSetting(ctx, chatID, key, "")
+}
+
+// GetSlotPresetWithProvider resolves slot to its preset, falling back to the
+// general default when the slot is unset or its preset is gone.
+func (s *Store) GetSlotPresetWithProvider(ctx context.Context, chatID int64, slot string) (PresetWithProvider, error) {
+	if _, ok := slotSettings[slot]; ok {
+		name, err := s.GetSlotPresetName(ctx, chatID, slot)
+		if err == nil {
+			pp, err := s.GetPresetWithProviderByName(ctx, chatID, name)
+			if !errors.Is(err, ErrNotFound) {
+				return pp, err
+			}
+		} else if !errors.Is(err, ErrNotFound) {
+			return PresetWithProvider{}, err
+		}
+	}
+	return s.GetDefaultPresetWithProvider(ctx, chatID)
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SaveFeedback records a vote; voting again on the same answer replaces it.
+func (s *Store) SaveFeedback(ctx context.Context, f Feedback) error {
+	q := s.sql.Insert("answer_feedback").
+		Columns("job_id", "user_id", "chat_id", "preset_name", "model", "latency_ms", "vote", "ab_arm", "created_at").
+		Values(f.JobID, f.UserID, f.ChatID, f.PresetName, f.Model, f.LatencyMs, f.Vote, f.ABArm, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(job_id, user_id) DO UPDATE SET vote=excluded.vote, created_at=excluded.created_at")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build save feedback query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("save feedback: %w", err)
+	}
+	return nil
+}
+
+// PresetFeedback aggregates votes per preset and model, most voted first.
+func (s *Store) PresetFeedback(ctx context.Context, chatID int64) ([]PresetFeedbackStats, error) {
+	q := s.sql.Select(
+		"preset_name",
+		"model",
+		"SUM(CASE WHEN vote > 0 THEN 1 ELSE 0 END)",
+		"SUM(CASE WHEN vote < 0 THEN 1 ELSE 0 END)",
+		"AVG(latency_ms)",
+	).
+		From("answer_feedback").
+		Where(sq.Eq{"chat_id": chatID}).
+		GroupBy("preset_name", "model").
+		OrderBy("COUNT(*) DESC", "preset_name", "model")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build preset feedback query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("preset feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PresetFeedbackStats
+	for rows.Next() {
+		var st PresetFeedbackStats
+		if err := rows.Scan(&st.PresetName, &st.Model, &st.Up, &st.Down, &st.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("scan preset feedback: %w", err)
+		}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate preset feedback: %w", err)
+	}
+	return out, nil
+}
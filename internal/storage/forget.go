@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Tables holding personal data, keyed by the column that identifies the
+// subject. New tables with per-user or per-chat rows must be listed here so
+// /forget_me and /forget_chat keep covering everything. credit_ledger is only
+// chat-scoped: dropping a member's purchases would take credits from the chat.
+var (
+	userScopedTables = []scopedTable{
+		{"answer_feedback", "user_id", "ratings you gave to answers"},
+		{"audit_log", "user_id", "audit entries of actions you performed"},
+		{"chat_admin_cache", "user_id", "cached admin rights"},
+		{"chat_users", "user_id", "your per-chat activity records"},
+		{"conversation_messages", "user_id", "your conversation history with the bot"},
+		{"referrals", "user_id", "referrals credited to you"},
+		{"usage_events", "user_id", "usage records of your requests"},
+		{"users", "id", "your user profile and activity counters"},
+	}
+	chatScopedTables = []scopedTable{
+		{"ab_experiments", "chat_id", "A/B experiments"},
+		{"answer_feedback", "chat_id", "answer ratings"},
+		{"audit_log", "chat_id", "the audit log"},
+		{"chat_admin_cache", "chat_id", "cached admin rights"},
+		{"chat_knowledge", "chat_id", "the knowledge base"},
+		{"chat_settings", "chat_id", "chat settings"},
+		{"chat_templates", "chat_id", "prompt templates"},
+		{"chat_users", "chat_id", "member activity records"},
+		{"conversation_messages", "chat_id", "conversation history"},
+		{"credit_ledger", "chat_id", "the credit balance and its ledger"},
+		{"preset_revisions", "chat_id", "preset revision history"},
+		{"presets", "chat_id", "presets"},
+		{"provider_instances", "chat_id", "providers (including encrypted API keys)"},
+		{"referrals", "chat_id", "the referral record"},
+		{"usage_events", "chat_id", "usage records"},
+		{"chats", "id", "the chat record itself"},
+	}
+)
+
+type scopedTable struct {
+	table  string
+	column string
+	// what describes the rows for the /forget confirmation prompt.
+	what string
+}
+
+// ForgetUserScope lists, for the confirmation prompt, what ForgetUser
+// deletes.
+func ForgetUserScope() []string { return describeScope(userScopedTables) }
+
+// ForgetChatScope lists, for the confirmation prompt, what ForgetChat
+// deletes.
+func ForgetChatScope() []string { return describeScope(chatScopedTables) }
+
+func describeScope(tables []scopedTable) []string {
+	out := make([]string, 0, len(tables))
+	for _, t := range tables {
+		out = append(out, t.what)
+	}
+	return out
+}
+
+func (s *Store) ForgetUser(ctx context.Context, userID int64) (int64, error) {
+	return s.forget(ctx, userScopedTables, userID)
+}
+
+func (s *Store) ForgetChat(ctx context.Context, chatID int64) (int64, error) {
+	return s.forget(ctx, chatScopedTables, chatID)
+}
+
+func (s *Store) forget(ctx context.Context, tables []scopedTable, id int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin forget tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var total int64
+	for _, t := range tables {
+		sqlStr, args, err := s.sql.Delete(t.table).Where(sq.Eq{t.column: id}).ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("build forget %s query: %w", t.table, err)
+		}
+		res, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			return 0, fmt.Errorf("forget %s: %w", t.table, err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			total += n
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit forget: %w", err)
+	}
+	return total, nil
+}
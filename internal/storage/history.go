@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func (s *Store) SaveConversationMessage(ctx context.Context, m ConversationMessage) error {
+	q := s.sql.Insert("conversation_messages").
+		Columns("chat_id", "user_id", "job_id", "preset_name", "prompt", "answer").
+		Values(m.ChatID, m.UserID, m.JobID, m.PresetName, m.Prompt, m.Answer)
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build save conversation query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("save conversation message: %w", err)
+	}
+	return nil
+}
+
+// ListConversation returns the user's latest limit messages in the chat
+// (all of them for 0), oldest first.
+func (s *Store) ListConversation(ctx context.Context, chatID, userID int64, limit uint64) ([]ConversationMessage, error) {
+	q := s.sql.Select("id", "chat_id", "user_id", "job_id", "preset_name", "prompt", "answer", "created_at").
+		From("conversation_messages").
+		Where(sq.Eq{"chat_id": chatID, "user_id": userID}).
+		OrderBy("created_at DESC", "id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build list conversation query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list conversation: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]ConversationMessage, 0)
+	for rows.Next() {
+		var m ConversationMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.UserID, &m.JobID, &m.PresetName, &m.Prompt, &m.Answer, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan conversation row: %w", err)
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate conversation rows: %w", err)
+	}
+	slices.Reverse(out)
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"hyprbot/internal/metrics"
+)
+
+// instrumentedDB times the statements of the Store and counts their
+// errors once Instrument is called. Statements inside transactions are
+// only covered by the "begin" operation.
+type instrumentedDB struct {
+	*sql.DB
+	metrics *metrics.Metrics
+}
+
+// Instrument records statement latency and errors in m. Call it before
+// the store is shared.
+func (s *Store) Instrument(m *metrics.Metrics) {
+	s.db.metrics = m
+}
+
+func (d *instrumentedDB) observe(op string, started time.Time, err error) {
+	if d.metrics == nil {
+		return
+	}
+	d.metrics.DBQueryDuration.WithLabelValues(op).Observe(time.Since(started).Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, context.Canceled) {
+		d.metrics.DBQueryErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func (d *instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	started := time.Now()
+	res, err := d.DB.ExecContext(ctx, query, args...)
+	d.observe("exec", started, err)
+	return res, err
+}
+
+func (d *instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	started := time.Now()
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	d.observe("query", started, err)
+	return rows, err
+}
+
+func (d *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	started := time.Now()
+	row := d.DB.QueryRowContext(ctx, query, args...)
+	// Err reports the query error without consuming the row.
+	d.observe("query_row", started, row.Err())
+	return row
+}
+
+func (d *instrumentedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	started := time.Now()
+	tx, err := d.DB.BeginTx(ctx, opts)
+	d.observe("begin", started, err)
+	return tx, err
+}
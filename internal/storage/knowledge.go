@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// MaxKnowledgePins bounds the pinned messages kept per chat; older ones are
+// dropped as new messages are pinned.
+const MaxKnowledgePins = 20
+
+var knowledgeColumns = []string{"chat_id", "message_id", "text", "created_at"}
+
+// SaveKnowledge stores e, replacing the entry of the same message, and drops
+// the oldest pins beyond MaxKnowledgePins.
+func (s *Store) SaveKnowledge(ctx context.Context, e KnowledgeEntry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin save knowledge tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := s.sql.Insert("chat_knowledge").
+		Columns(knowledgeColumns...).
+		Values(e.ChatID, e.MessageID, e.Text, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(chat_id, message_id) DO UPDATE SET text=excluded.text, created_at=excluded.created_at")
+	if err := execTx(ctx, tx, q); err != nil {
+		return fmt.Errorf("save knowledge: %w", err)
+	}
+	keep := sq.Select("message_id").From("chat_knowledge").
+		Where(sq.Eq{"chat_id": e.ChatID}).Where(sq.NotEq{"message_id": 0}).
+		OrderBy("message_id DESC").Limit(MaxKnowledgePins)
+	trim := s.sql.Delete("chat_knowledge").
+		Where(sq.Eq{"chat_id": e.ChatID}).Where(sq.NotEq{"message_id": 0}).
+		Where(keep.Prefix("message_id NOT IN (").Suffix(")"))
+	if err := execTx(ctx, tx, trim); err != nil {
+		return fmt.Errorf("trim knowledge: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit save knowledge: %w", err)
+	}
+	return nil
+}
+
+// ListKnowledge returns the chat description first, then pins newest first.
+func (s *Store) ListKnowledge(ctx context.Context, chatID int64) ([]KnowledgeEntry, error) {
+	q := s.sql.Select(knowledgeColumns...).From("chat_knowledge").
+		Where(sq.Eq{"chat_id": chatID}).
+		OrderBy("CASE WHEN message_id = 0 THEN 0 ELSE 1 END", "message_id DESC")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build list knowledge query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list knowledge: %w", err)
+	}
+	defer rows.Close()
+	var out []KnowledgeEntry
+	for rows.Next() {
+		var e KnowledgeEntry
+		if err := rows.Scan(&e.ChatID, &e.MessageID, &e.Text, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan knowledge: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// KnowledgeTexts returns the texts of ListKnowledge.
+func (s *Store) KnowledgeTexts(ctx context.Context, chatID int64) ([]string, error) {
+	entries, err := s.ListKnowledge(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Text)
+	}
+	return out, nil
+}
+
+func (s *Store) DeleteKnowledge(ctx context.Context, chatID, messageID int64) error {
+	sqlStr, args, err := s.sql.Delete("chat_knowledge").Where(sq.Eq{"chat_id": chatID, "message_id": messageID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build delete knowledge query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("delete knowledge: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearKnowledge drops every entry of the chat.
+func (s *Store) ClearKnowledge(ctx context.Context, chatID int64) error {
+	sqlStr, args, err := s.sql.Delete("chat_knowledge").Where(sq.Eq{"chat_id": chatID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build clear knowledge query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("clear knowledge: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// MarkChatLeft records that the bot was removed from the chat. EnsureChat
+// clears the mark once the bot sees the chat again.
+func (s *Store) MarkChatLeft(ctx context.Context, chatID int64) error {
+	q := s.sql.Update("chats").
+		Set("left_at", nowExpr(s.driver)).
+		Where(sq.And{sq.Eq{"id": chatID}, sq.Eq{"left_at": nil}})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build mark chat left query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("mark chat left: %w", err)
+	}
+	return nil
+}
+
+// PurgeLeftChatSecrets drops provider API keys and headers of chats the bot
+// left before the cutoff. Providers and presets stay so a returning chat only
+// has to re-enter its keys.
+func (s *Store) PurgeLeftChatSecrets(ctx context.Context, before time.Time) (int64, error) {
+	// The cutoff is applied in Go: SQLite keeps CURRENT_TIMESTAMP as text, so
+	// comparing it with a bound time value is not reliable.
+	sqlStr, args, err := s.sql.Select("id", "left_at").From("chats").Where(sq.NotEq{"left_at": nil}).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build left chats query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, fmt.Errorf("list left chats: %w", err)
+	}
+	var chatIDs []int64
+	for rows.Next() {
+		var id int64
+		var leftAt time.Time
+		if err := rows.Scan(&id, &leftAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan left chat: %w", err)
+		}
+		if leftAt.Before(before) {
+			chatIDs = append(chatIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate left chats: %w", err)
+	}
+	if len(chatIDs) == 0 {
+		return 0, nil
+	}
+
+	q := s.sql.Update("provider_instances").
+		Set("enc_api_key", nil).
+		Set("enc_headers_json", nil).
+		Where(sq.And{
+			sq.Eq{"chat_id": chatIDs},
+			sq.Or{sq.NotEq{"enc_api_key": nil}, sq.NotEq{"enc_headers_json": nil}},
+		})
+	sqlStr, args, err = q.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build purge left chat secrets query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, fmt.Errorf("purge left chat secrets: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
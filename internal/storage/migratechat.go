@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// MigrateChat moves everything stored for fromID to toID, for groups that
+// Telegram upgraded to a supergroup under a new id. The old chat's config
+// wins over anything already stored for toID; conflicting providers or
+// presets abort the migration. It returns ErrNotFound if fromID is unknown.
+func (s *Store) MigrateChat(ctx context.Context, fromID, toID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migrate chat tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The new row must exist before children move because of the chat_id
+	// foreign keys; the old one is dropped once nothing references it.
+	q := s.sql.Insert("chats").
+		Columns("id", "type", "title", "default_preset_name", "created_at").
+		Select(sq.Select().
+			Column(sq.Expr("CAST(? AS BIGINT)", toID)).
+			Column("?", "supergroup").
+			Columns("title", "default_preset_name", "created_at").
+			From("chats").
+			Where(sq.Eq{"id": fromID})).
+		Suffix("ON CONFLICT(id) DO UPDATE SET type=excluded.type, title=excluded.title, default_preset_name=excluded.default_preset_name")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build migrate chat query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("copy chat: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	// Admin flags, settings, templates, knowledge, experiments, referrals
+	// and member activity recorded for the new id are stale compared to the
+	// migrated ones.
+	for _, table := range []string{"chat_admin_cache", "chat_settings", "chat_templates", "chat_knowledge", "ab_experiments", "referrals", "chat_users"} {
+		if err := execTx(ctx, tx, s.sql.Delete(table).Where(sq.Eq{"chat_id": toID})); err != nil {
+			return fmt.Errorf("clear %s for migrated chat: %w", table, err)
+		}
+	}
+	for _, t := range chatScopedTables {
+		if t.table == "chats" {
+			continue
+		}
+		if err := execTx(ctx, tx, s.sql.Update(t.table).Set(t.column, toID).Where(sq.Eq{t.column: fromID})); err != nil {
+			return fmt.Errorf("migrate %s: %w", t.table, err)
+		}
+	}
+	if err := execTx(ctx, tx, s.sql.Delete("chats").Where(sq.Eq{"id": fromID})); err != nil {
+		return fmt.Errorf("delete migrated chat: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migrate chat: %w", err)
+	}
+	return nil
+}
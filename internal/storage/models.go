@@ -7,7 +7,9 @@ type Chat struct {
 	Type              string
 	Title             string
 	DefaultPresetName *string
-	CreatedAt         time.Time
+	// LeftAt is set while the bot is not a member of the chat.
+	LeftAt    *time.Time
+	CreatedAt time.Time
 }
 
 type ProviderInstance struct {
@@ -20,6 +22,17 @@ type ProviderInstance struct {
 	EncHeadersJSON *string
 	ConfigJSON     string
 	CreatedAt      time.Time
+	// LastSuccessAt, LastErrorAt and LastErrorText are the outcome of the
+	// latest calls the worker made; only ListProviders and
+	// GetProviderByName load them.
+	LastSuccessAt *time.Time
+	LastErrorAt   *time.Time
+	LastErrorText string
+}
+
+// Failing reports that the provider's latest call failed.
+func (p ProviderInstance) Failing() bool {
+	return p.LastErrorAt != nil && (p.LastSuccessAt == nil || p.LastErrorAt.After(*p.LastSuccessAt))
 }
 
 type Preset struct {
@@ -29,6 +42,21 @@ type Preset struct {
 	Model              string
 	SystemPrompt       string
 	ParamsJSON         string
+	DegradedReason     string
+	DegradedAt         *time.Time
+	CreatedAt          time.Time
+}
+
+// PresetRevision is a preset configuration as it was before being overwritten
+// or deleted. Rev increases per preset.
+type PresetRevision struct {
+	ChatID             int64
+	PresetName         string
+	Rev                int
+	ProviderInstanceID int64
+	Model              string
+	SystemPrompt       string
+	ParamsJSON         string
 	CreatedAt          time.Time
 }
 
@@ -43,3 +71,186 @@ type AuditEntry struct {
 	Action   string
 	MetaJSON string
 }
+
+type User struct {
+	ID           int64
+	Username     string
+	FirstName    string
+	FirstSeenAt  time.Time
+	LastActiveAt time.Time
+	MessageCount int64
+}
+
+// ChatUser is a member's activity in one chat.
+type ChatUser struct {
+	ChatID       int64
+	UserID       int64
+	FirstSeenAt  time.Time
+	LastActiveAt time.Time
+	MessageCount int64
+}
+
+// UserActivity is what the processor saw of a user in a chat since the last
+// flush. ChatID 0 records the user only.
+type UserActivity struct {
+	ChatID    int64
+	UserID    int64
+	Username  string
+	FirstName string
+	Messages  int64
+}
+
+type ConversationMessage struct {
+	ID         int64
+	ChatID     int64
+	UserID     int64
+	JobID      string
+	PresetName string
+	Prompt     string
+	Answer     string
+	CreatedAt  time.Time
+}
+
+// Feedback is one user's vote on one answer. Vote is 1 or -1. ABArm is set
+// when an A/B experiment picked the preset.
+type Feedback struct {
+	JobID      string
+	UserID     int64
+	ChatID     int64
+	PresetName string
+	Model      string
+	LatencyMs  int64
+	Vote       int
+	ABArm      string
+	CreatedAt  time.Time
+}
+
+// UsageEvent is one finished /ask job. Failed jobs carry no tokens.
+type UsageEvent struct {
+	ID           int64
+	ChatID       int64
+	UserID       int64
+	PresetName   string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	LatencyMs    int64
+	Failed       bool
+	CreatedAt    time.Time
+}
+
+// CreditEntry is a change to a chat's credit balance: purchases and owner
+// grants add credits, answered requests beyond the free quota spend them.
+type CreditEntry struct {
+	ID     int64
+	ChatID int64
+	UserID int64
+	Delta  int64
+	Reason string
+	// ChargeID is the Telegram payment charge id of a purchase.
+	ChargeID  string
+	CreatedAt time.Time
+}
+
+// ChatTemplate is a prompt with {{variables}} an admin saved for a chat,
+// used with /tpl.
+type ChatTemplate struct {
+	ChatID    int64
+	Name      string
+	Body      string
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// KnowledgeEntry is a pinned message, or with MessageID 0 the chat
+// description, given to the model as reference for questions about the chat.
+type KnowledgeEntry struct {
+	ChatID    int64
+	MessageID int64
+	Text      string
+	CreatedAt time.Time
+}
+
+// Referral records the deep link code a chat first reached the bot through.
+type Referral struct {
+	ChatID    int64
+	Code      string
+	UserID    int64
+	CreatedAt time.Time
+}
+
+// ReferralStats summarises the chats brought by one referral code.
+type ReferralStats struct {
+	Code        string
+	Chats       int64
+	ActiveChats int64
+	Requests    int64
+}
+
+type UsageCount struct {
+	Label string
+	Count int64
+}
+
+// UsageSummary aggregates a chat's usage events over a period.
+type UsageSummary struct {
+	Requests     int64
+	Failed       int64
+	InputTokens  int64
+	OutputTokens int64
+	TopUsers     []UsageCount
+	TopPresets   []UsageCount
+}
+
+// ChatUsage is one chat's usage events over a period.
+type ChatUsage struct {
+	ChatID       int64
+	Requests     int64
+	Failed       int64
+	InputTokens  int64
+	OutputTokens int64
+	AvgLatencyMs float64
+}
+
+type PresetFeedbackStats struct {
+	PresetName   string
+	Model        string
+	Up           int64
+	Down         int64
+	AvgLatencyMs float64
+}
+
+// Webhook is an outbound webhook the owner added. Events lists the event
+// types it receives; empty means all of them.
+type Webhook struct {
+	Name      string
+	URL       string
+	EncSecret string
+	Events    []string
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent, or still to be sent, to one webhook.
+type WebhookDelivery struct {
+	ID          int64
+	WebhookName string
+	EventID     string
+	EventType   string
+	Payload     string
+	Status      string
+	Attempts    int
+	// LastStatusCode and LastError are the outcome of the latest attempt;
+	// the code is 0 when no response came.
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// maxPresetRevisions bounds the history kept per preset; older revisions are
+// pruned when a new one is archived.
+const maxPresetRevisions = 20
+
+// ErrProviderGone is returned by RollbackPreset when the revision's provider
+// has been deleted since.
+var ErrProviderGone = errors.New("provider no longer exists")
+
+var presetRevisionColumns = []string{"chat_id", "preset_name", "rev", "provider_instance_id", "model", "system_prompt", "params_json", "created_at"}
+
+func scanPresetRevision(row interface{ Scan(...any) error }) (PresetRevision, error) {
+	var r PresetRevision
+	err := row.Scan(&r.ChatID, &r.PresetName, &r.Rev, &r.ProviderInstanceID, &r.Model, &r.SystemPrompt, &r.ParamsJSON, &r.CreatedAt)
+	return r, err
+}
+
+// archivePreset copies the current configuration of a preset into
+// preset_revisions before it is changed. It is a no-op for new presets.
+func (s *Store) archivePreset(ctx context.Context, tx *sql.Tx, chatID int64, name string) error {
+	sqlStr, args, err := s.sql.Select("COALESCE(MAX(rev), 0)").From("preset_revisions").
+		Where(sq.Eq{"chat_id": chatID, "preset_name": name}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build preset revision query: %w", err)
+	}
+	var last int
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&last); err != nil {
+		return fmt.Errorf("get last preset revision: %w", err)
+	}
+	rev := last + 1
+
+	q := s.sql.Insert("preset_revisions").
+		Columns("chat_id", "preset_name", "rev", "provider_instance_id", "model", "system_prompt", "params_json").
+		Select(sq.Select().
+			Columns("chat_id", "name").
+			Column(sq.Expr("CAST(? AS INTEGER)", rev)).
+			Columns("provider_instance_id", "model", "system_prompt", "params_json").
+			From("presets").
+			Where(sq.Eq{"chat_id": chatID, "name": name}))
+	sqlStr, args, err = q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build archive preset query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("archive preset: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil
+	}
+	prune := s.sql.Delete("preset_revisions").
+		Where(sq.Eq{"chat_id": chatID, "preset_name": name}).
+		Where(sq.LtOrEq{"rev": rev - maxPresetRevisions})
+	if err := execTx(ctx, tx, prune); err != nil {
+		return fmt.Errorf("prune preset revisions: %w", err)
+	}
+	return nil
+}
+
+// ListPresetRevisions returns the archived configurations of a preset,
+// newest first. Deleted presets keep their history.
+func (s *Store) ListPresetRevisions(ctx context.Context, chatID int64, name string) ([]PresetRevision, error) {
+	q := s.sql.Select(presetRevisionColumns...).
+		From("preset_revisions").
+		Where(sq.Eq{"chat_id": chatID, "preset_name": name}).
+		OrderBy("rev DESC")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build list preset revisions query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list preset revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PresetRevision
+	for rows.Next() {
+		r, err := scanPresetRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan preset revision: %w", err)
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate preset revisions: %w", err)
+	}
+	return out, nil
+}
+
+// RollbackPreset restores revision rev of a preset, recreating it if it was
+// deleted. The configuration being replaced is archived as a new revision.
+func (s *Store) RollbackPreset(ctx context.Context, chatID int64, name string, rev int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin rollback preset tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	sqlStr, args, err := s.sql.Select(presetRevisionColumns...).From("preset_revisions").
+		Where(sq.Eq{"chat_id": chatID, "preset_name": name, "rev": rev}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build get preset revision query: %w", err)
+	}
+	r, err := scanPresetRevision(tx.QueryRowContext(ctx, sqlStr, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get preset revision: %w", err)
+	}
+
+	sqlStr, args, err = s.sql.Select("COUNT(*)").From("provider_instances").
+		Where(sq.Eq{"id": r.ProviderInstanceID, "chat_id": chatID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build provider exists query: %w", err)
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&n); err != nil {
+		return fmt.Errorf("check revision provider: %w", err)
+	}
+	if n == 0 {
+		return ErrProviderGone
+	}
+
+	if err := s.archivePreset(ctx, tx, chatID, name); err != nil {
+		return err
+	}
+	if err := execTx(ctx, tx, s.upsertPresetQuery(Preset{
+		ChatID:             chatID,
+		Name:               name,
+		ProviderInstanceID: r.ProviderInstanceID,
+		Model:              r.Model,
+		SystemPrompt:       r.SystemPrompt,
+		ParamsJSON:         r.ParamsJSON,
+	})); err != nil {
+		return fmt.Errorf("restore preset revision: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rollback preset: %w", err)
+	}
+	return nil
+}
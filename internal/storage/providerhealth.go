@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// RecordProviderSuccess notes that a call to the provider just succeeded.
+func (s *Store) RecordProviderSuccess(ctx context.Context, providerID int64) error {
+	q := s.sql.Update("provider_instances").
+		Set("last_success_at", nowExpr(s.driver)).
+		Where(sq.Eq{"id": providerID})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build record provider success query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("record provider success: %w", err)
+	}
+	return nil
+}
+
+// RecordProviderError notes that a call to the provider just failed. text is
+// shown to admins, so callers scrub secrets from it first.
+func (s *Store) RecordProviderError(ctx context.Context, providerID int64, text string) error {
+	q := s.sql.Update("provider_instances").
+		Set("last_error_at", nowExpr(s.driver)).
+		Set("last_error_text", text).
+		Where(sq.Eq{"id": providerID})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build record provider error query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("record provider error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// RecordReferral stores the chat's referral unless it already has one: the
+// first link a chat arrived through keeps the credit.
+func (s *Store) RecordReferral(ctx context.Context, r Referral) (bool, error) {
+	q := s.sql.Insert("referrals").
+		Columns("chat_id", "code", "user_id", "created_at").
+		Values(r.ChatID, r.Code, r.UserID, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(chat_id) DO NOTHING")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build record referral query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return false, fmt.Errorf("record referral: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0, nil
+}
+
+// ReferralStats reports per code how many chats it brought, how many still
+// have the bot, and their requests within the last period.
+func (s *Store) ReferralStats(ctx context.Context, period time.Duration) ([]ReferralStats, error) {
+	// The subquery keeps squirrel's default placeholders; the outer builder
+	// numbers them for Postgres.
+	usage := sq.Select("chat_id", "COUNT(*) AS n").
+		From("usage_events").
+		Where(sinceExpr(s.driver, "created_at", period)).
+		GroupBy("chat_id")
+	q := s.sql.Select("r.code", "COUNT(*)", "COALESCE(SUM(CASE WHEN c.left_at IS NULL THEN 1 ELSE 0 END), 0)", "COALESCE(SUM(u.n), 0)").
+		From("referrals r").
+		Join("chats c ON c.id = r.chat_id").
+		JoinClause(usage.Prefix("LEFT JOIN (").Suffix(") u ON u.chat_id = r.chat_id")).
+		GroupBy("r.code").
+		OrderBy("COUNT(*) DESC", "r.code")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build referral stats query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query referral stats: %w", err)
+	}
+	defer rows.Close()
+	var out []ReferralStats
+	for rows.Next() {
+		var st ReferralStats
+		if err := rows.Scan(&st.Code, &st.Chats, &st.ActiveChats, &st.Requests); err != nil {
+			return nil, fmt.Errorf("scan referral stats: %w", err)
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// ChatCounts returns the number of known chats and of those the bot is
+// still a member of.
+func (s *Store) ChatCounts(ctx context.Context) (total, active int64, err error) {
+	q := s.sql.Select("COUNT(*)", "COALESCE(SUM(CASE WHEN left_at IS NULL THEN 1 ELSE 0 END), 0)").From("chats")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return 0, 0, fmt.Errorf("build chat counts query: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&total, &active); err != nil {
+		return 0, 0, fmt.Errorf("query chat counts: %w", err)
+	}
+	return total, active, nil
+}
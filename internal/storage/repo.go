@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 )
@@ -20,7 +21,7 @@ func (s *Store) EnsureChat(ctx context.Context, chatID int64, chatType, title st
 	q := s.sql.Insert("chats").
 		Columns("id", "type", "title").
 		Values(chatID, chatType, title).
-		Suffix("ON CONFLICT(id) DO UPDATE SET type=excluded.type, title=excluded.title")
+		Suffix("ON CONFLICT(id) DO UPDATE SET type=excluded.type, title=excluded.title, left_at=NULL")
 
 	sqlStr, args, err := q.ToSql()
 	if err != nil {
@@ -68,6 +69,23 @@ func (s *Store) GetAdminCache(ctx context.Context, chatID, userID int64) (isAdmi
 	return isAdmin, true, nil
 }
 
+// DeleteAdminCache forgets cached rights of one user, or of everyone in the
+// chat when userID is 0.
+func (s *Store) DeleteAdminCache(ctx context.Context, chatID, userID int64) error {
+	where := sq.Eq{"chat_id": chatID}
+	if userID != 0 {
+		where["user_id"] = userID
+	}
+	sqlStr, args, err := s.sql.Delete("chat_admin_cache").Where(where).ToSql()
+	if err != nil {
+		return fmt.Errorf("build delete admin cache query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("delete admin cache: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) UpsertProviderInstance(ctx context.Context, p ProviderInstance) (int64, error) {
 	if p.ConfigJSON == "" {
 		p.ConfigJSON = "{}"
@@ -105,7 +123,7 @@ func (s *Store) GetProviderInstanceID(ctx context.Context, chatID int64, name st
 }
 
 func (s *Store) GetProviderByName(ctx context.Context, chatID int64, name string) (ProviderInstance, error) {
-	q := s.sql.Select("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at").
+	q := s.sql.Select("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at", "last_success_at", "last_error_at", "last_error_text").
 		From("provider_instances").
 		Where(sq.Eq{"chat_id": chatID, "name": name})
 	sqlStr, args, err := q.ToSql()
@@ -115,6 +133,7 @@ func (s *Store) GetProviderByName(ctx context.Context, chatID int64, name string
 
 	var p ProviderInstance
 	var encAPIKey, encHeaders sql.NullString
+	var lastSuccess, lastError sql.NullTime
 	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(
 		&p.ID,
 		&p.ChatID,
@@ -125,6 +144,9 @@ func (s *Store) GetProviderByName(ctx context.Context, chatID int64, name string
 		&encHeaders,
 		&p.ConfigJSON,
 		&p.CreatedAt,
+		&lastSuccess,
+		&lastError,
+		&p.LastErrorText,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ProviderInstance{}, ErrNotFound
@@ -137,6 +159,12 @@ func (s *Store) GetProviderByName(ctx context.Context, chatID int64, name string
 	if encHeaders.Valid {
 		p.EncHeadersJSON = &encHeaders.String
 	}
+	if lastSuccess.Valid {
+		p.LastSuccessAt = &lastSuccess.Time
+	}
+	if lastError.Valid {
+		p.LastErrorAt = &lastError.Time
+	}
 	return p, nil
 }
 
@@ -177,7 +205,7 @@ func (s *Store) GetProviderByID(ctx context.Context, chatID int64, providerID in
 }
 
 func (s *Store) ListProviders(ctx context.Context, chatID int64) ([]ProviderInstance, error) {
-	q := s.sql.Select("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at").
+	q := s.sql.Select("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at", "last_success_at", "last_error_at", "last_error_text").
 		From("provider_instances").
 		Where(sq.Eq{"chat_id": chatID}).
 		OrderBy("created_at ASC")
@@ -196,6 +224,7 @@ func (s *Store) ListProviders(ctx context.Context, chatID int64) ([]ProviderInst
 	for rows.Next() {
 		var p ProviderInstance
 		var encAPIKey, encHeaders sql.NullString
+		var lastSuccess, lastError sql.NullTime
 		if err := rows.Scan(
 			&p.ID,
 			&p.ChatID,
@@ -206,6 +235,9 @@ func (s *Store) ListProviders(ctx context.Context, chatID int64) ([]ProviderInst
 			&encHeaders,
 			&p.ConfigJSON,
 			&p.CreatedAt,
+			&lastSuccess,
+			&lastError,
+			&p.LastErrorText,
 		); err != nil {
 			return nil, fmt.Errorf("scan provider row: %w", err)
 		}
@@ -215,6 +247,12 @@ func (s *Store) ListProviders(ctx context.Context, chatID int64) ([]ProviderInst
 		if encHeaders.Valid {
 			p.EncHeadersJSON = &encHeaders.String
 		}
+		if lastSuccess.Valid {
+			p.LastSuccessAt = &lastSuccess.Time
+		}
+		if lastError.Valid {
+			p.LastErrorAt = &lastError.Time
+		}
 		out = append(out, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -240,32 +278,145 @@ func (s *Store) DeleteProviderByName(ctx context.Context, chatID int64, name str
 	return nil
 }
 
+func (s *Store) SetProviderConfig(ctx context.Context, chatID int64, name, configJSON string) error {
+	q := s.sql.Update("provider_instances").
+		Set("config_json", configJSON).
+		Where(sq.Eq{"chat_id": chatID, "name": name})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build set provider config query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("set provider config: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetPresetParams replaces a preset's params, archiving the previous
+// configuration.
+func (s *Store) SetPresetParams(ctx context.Context, chatID int64, name, paramsJSON string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin set preset params tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.archivePreset(ctx, tx, chatID, name); err != nil {
+		return err
+	}
+	q := s.sql.Update("presets").
+		Set("params_json", paramsJSON).
+		Where(sq.Eq{"chat_id": chatID, "name": name})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build set preset params query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("set preset params: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit set preset params: %w", err)
+	}
+	return nil
+}
+
+// UpsertPreset creates or overwrites a preset. An overwritten configuration
+// is archived in preset_revisions.
 func (s *Store) UpsertPreset(ctx context.Context, p Preset) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin upsert preset tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.archivePreset(ctx, tx, p.ChatID, p.Name); err != nil {
+		return err
+	}
+	if err := execTx(ctx, tx, s.upsertPresetQuery(p)); err != nil {
+		return fmt.Errorf("upsert preset: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit upsert preset: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) upsertPresetQuery(p Preset) sq.InsertBuilder {
 	if p.ParamsJSON == "" {
 		p.ParamsJSON = "{}"
 	}
-	q := s.sql.Insert("presets").
+	return s.sql.Insert("presets").
 		Columns("chat_id", "name", "provider_instance_id", "model", "system_prompt", "params_json").
 		Values(p.ChatID, p.Name, p.ProviderInstanceID, p.Model, p.SystemPrompt, p.ParamsJSON).
-		Suffix("ON CONFLICT(chat_id, name) DO UPDATE SET provider_instance_id=excluded.provider_instance_id, model=excluded.model, system_prompt=excluded.system_prompt, params_json=excluded.params_json")
+		Suffix("ON CONFLICT(chat_id, name) DO UPDATE SET provider_instance_id=excluded.provider_instance_id, model=excluded.model, system_prompt=excluded.system_prompt, params_json=excluded.params_json, degraded_reason='', degraded_at=NULL")
+}
 
+// MarkPresetDegraded flags a preset whose model the provider rejects. It
+// reports true only for the call that flipped the flag, so callers can alert
+// once.
+func (s *Store) MarkPresetDegraded(ctx context.Context, chatID int64, name, reason string) (bool, error) {
+	if reason == "" {
+		reason = "model unavailable"
+	}
+	q := s.sql.Update("presets").
+		Set("degraded_reason", reason).
+		Set("degraded_at", nowExpr(s.driver)).
+		Where(sq.Eq{"chat_id": chatID, "name": name, "degraded_reason": ""})
 	sqlStr, args, err := q.ToSql()
 	if err != nil {
-		return fmt.Errorf("build preset upsert query: %w", err)
+		return false, fmt.Errorf("build mark preset degraded query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return false, fmt.Errorf("mark preset degraded: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0, nil
+}
+
+func (s *Store) ClearPresetDegraded(ctx context.Context, chatID int64, name string) error {
+	q := s.sql.Update("presets").
+		Set("degraded_reason", "").
+		Set("degraded_at", nil).
+		Where(sq.Eq{"chat_id": chatID, "name": name})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build clear preset degraded query: %w", err)
 	}
 	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
-		return fmt.Errorf("upsert preset: %w", err)
+		return fmt.Errorf("clear preset degraded: %w", err)
 	}
 	return nil
 }
 
+// DeletePreset removes a preset; its last configuration stays in
+// preset_revisions so it can be restored.
 func (s *Store) DeletePreset(ctx context.Context, chatID int64, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete preset tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.archivePreset(ctx, tx, chatID, name); err != nil {
+		return err
+	}
 	q := s.sql.Delete("presets").Where(sq.Eq{"chat_id": chatID, "name": name})
 	sqlStr, args, err := q.ToSql()
 	if err != nil {
 		return fmt.Errorf("build delete preset query: %w", err)
 	}
-	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
 	if err != nil {
 		return fmt.Errorf("delete preset: %w", err)
 	}
@@ -273,6 +424,9 @@ func (s *Store) DeletePreset(ctx context.Context, chatID int64, name string) err
 	if err == nil && n == 0 {
 		return ErrNotFound
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete preset: %w", err)
+	}
 	return nil
 }
 
@@ -330,7 +484,7 @@ func (s *Store) GetDefaultPresetName(ctx context.Context, chatID int64) (string,
 }
 
 func (s *Store) ListPresets(ctx context.Context, chatID int64) ([]Preset, error) {
-	q := s.sql.Select("chat_id", "name", "provider_instance_id", "model", "system_prompt", "params_json", "created_at").
+	q := s.sql.Select("chat_id", "name", "provider_instance_id", "model", "system_prompt", "params_json", "degraded_reason", "degraded_at", "created_at").
 		From("presets").
 		Where(sq.Eq{"chat_id": chatID}).
 		OrderBy("created_at ASC")
@@ -347,9 +501,13 @@ func (s *Store) ListPresets(ctx context.Context, chatID int64) ([]Preset, error)
 	out := make([]Preset, 0)
 	for rows.Next() {
 		var p Preset
-		if err := rows.Scan(&p.ChatID, &p.Name, &p.ProviderInstanceID, &p.Model, &p.SystemPrompt, &p.ParamsJSON, &p.CreatedAt); err != nil {
+		var degradedAt sql.NullTime
+		if err := rows.Scan(&p.ChatID, &p.Name, &p.ProviderInstanceID, &p.Model, &p.SystemPrompt, &p.ParamsJSON, &p.DegradedReason, &degradedAt, &p.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan preset row: %w", err)
 		}
+		if degradedAt.Valid {
+			p.DegradedAt = &degradedAt.Time
+		}
 		out = append(out, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -383,7 +541,7 @@ func (s *Store) GetDefaultPresetWithProvider(ctx context.Context, chatID int64)
 
 func (s *Store) getPresetWithProvider(ctx context.Context, where sq.Sqlizer) (PresetWithProvider, error) {
 	q := s.sql.Select(
-		"p.chat_id", "p.name", "p.provider_instance_id", "p.model", "p.system_prompt", "p.params_json", "p.created_at",
+		"p.chat_id", "p.name", "p.provider_instance_id", "p.model", "p.system_prompt", "p.params_json", "p.degraded_reason", "p.created_at",
 		"pr.id", "pr.chat_id", "pr.name", "pr.kind", "pr.base_url", "pr.enc_api_key", "pr.enc_headers_json", "pr.config_json", "pr.created_at",
 	).From("presets p").
 		Join("provider_instances pr ON p.provider_instance_id = pr.id").
@@ -403,6 +561,7 @@ func (s *Store) getPresetWithProvider(ctx context.Context, where sq.Sqlizer) (Pr
 		&out.Preset.Model,
 		&out.Preset.SystemPrompt,
 		&out.Preset.ParamsJSON,
+		&out.Preset.DegradedReason,
 		&out.Preset.CreatedAt,
 		&out.Provider.ID,
 		&out.Provider.ChatID,
@@ -456,3 +615,14 @@ func nowExpr(driver string) any {
 	}
 	return sq.Expr("CURRENT_TIMESTAMP")
 }
+
+// sinceExpr matches rows whose column is within d of now. The cutoff is
+// computed by the database so SQLite compares timestamps in its own text
+// format.
+func sinceExpr(driver, column string, d time.Duration) sq.Sqlizer {
+	secs := int64(d / time.Second)
+	if driver == "postgres" {
+		return sq.Expr(column+" >= NOW() - CAST(? AS INTERVAL)", fmt.Sprintf("%d seconds", secs))
+	}
+	return sq.Expr(column+" >= datetime('now', ?)", fmt.Sprintf("-%d seconds", secs))
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Chat setting keys stored in chat_settings. A missing row means the default.
+const (
+	SettingCommandPrefixes = "prefixes"
+	SettingExports         = "exports"
+	SettingPersona         = "persona"
+	SettingMention         = "mention"
+	SettingAck             = "ack"
+	SettingReplyLanguage   = "reply_language"
+	SettingFormatting      = "formatting"
+	SettingFooter          = "footer"
+	SettingChangeNotices   = "change_notices"
+	SettingDigest          = "digest"
+	SettingLongAnswers     = "long_answers"
+	SettingPrivateAnswers  = "private_answers"
+	SettingKnowledge       = "knowledge"
+	// SettingDisclosure is a line such as "#AIgenerated" the sender adds to
+	// every model answer; empty adds nothing.
+	SettingDisclosure = "disclosure"
+	// SettingDailyLimit caps requests per user and UTC day on top of the
+	// hourly limit; empty means no daily cap.
+	SettingDailyLimit = "daily_limit"
+	// SettingRateExempt lists the comma-separated ids of users the rate
+	// limits skip, managed by /ratelimit_exempt.
+	SettingRateExempt = "ratelimit_exempt"
+	// SettingPaused is managed by /bot_off and /bot_on, not /settings.
+	SettingPaused = "paused"
+	// SettingOnboarding tracks the welcome flow of groups the bot joined;
+	// empty for chats that predate it.
+	SettingOnboarding = "onboarding"
+	// SettingDefaultCode and SettingDefaultTranslate hold the presets of the
+	// code and translate default slots, managed by /ai_default.
+	SettingDefaultCode      = "default_code"
+	SettingDefaultTranslate = "default_translate"
+	// SettingOutputFilter lists what the worker neutralizes in model
+	// answers (commands, links, mentions); empty means all, "off" none.
+	SettingOutputFilter = "output_filter"
+	// SettingPlugins lists the plugins the chat uses; empty means those
+	// enabled by default, "off" none.
+	SettingPlugins = "plugins"
+)
+
+const (
+	SettingOn  = "on"
+	SettingOff = "off"
+
+	AckStyleMessage  = "message"
+	AckStyleReaction = "reaction"
+
+	FormattingPlain    = "plain"
+	FormattingMarkdown = "markdown"
+
+	DigestGroup  = "group"
+	DigestAdmins = "admins"
+
+	LongAnswersSplit  = "split"
+	LongAnswersExpand = "expand"
+	LongAnswersDM     = "dm"
+	LongAnswersFile   = "file"
+
+	KnowledgePins            = "pins"
+	KnowledgePinsDescription = "pins_description"
+
+	OnboardingPending = "pending"
+	OnboardingShared  = "shared"
+	OnboardingCustom  = "custom"
+	OnboardingDone    = "done"
+)
+
+// SettingDefaults holds the value used when a chat has no row for a key.
+var SettingDefaults = map[string]string{
+	SettingCommandPrefixes:  "",
+	SettingExports:          SettingOn,
+	SettingPersona:          "",
+	SettingMention:          SettingOff,
+	SettingAck:              AckStyleMessage,
+	SettingReplyLanguage:    "",
+	SettingFormatting:       FormattingPlain,
+	SettingFooter:           SettingOff,
+	SettingChangeNotices:    SettingOff,
+	SettingDigest:           SettingOff,
+	SettingLongAnswers:      LongAnswersSplit,
+	SettingPrivateAnswers:   SettingOff,
+	SettingKnowledge:        SettingOff,
+	SettingDisclosure:       "",
+	SettingDailyLimit:       "",
+	SettingRateExempt:       "",
+	SettingPaused:           SettingOff,
+	SettingOnboarding:       "",
+	SettingDefaultCode:      "",
+	SettingDefaultTranslate: "",
+	SettingOutputFilter:     "",
+	SettingPlugins:          "",
+}
+
+type ChatSetting struct {
+	ChatID    int64
+	Key       string
+	Value     string
+	UpdatedAt time.Time
+}
+
+// ChatSettings is a chat's settings with defaults applied.
+type ChatSettings map[string]string
+
+func (c ChatSettings) Get(key string) string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	return SettingDefaults[key]
+}
+
+func (c ChatSettings) Bool(key string) bool {
+	return c.Get(key) == SettingOn
+}
+
+func (s *Store) GetChatSettings(ctx context.Context, chatID int64) (ChatSettings, error) {
+	q := s.sql.Select("key", "value").From("chat_settings").Where(sq.Eq{"chat_id": chatID})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build chat settings query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get chat settings: %w", err)
+	}
+	defer rows.Close()
+
+	out := ChatSettings{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("scan chat setting: %w", err)
+		}
+		out[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate chat settings: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Store) GetChatSetting(ctx context.Context, chatID int64, key string) (string, error) {
+	settings, err := s.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+	return settings.Get(key), nil
+}
+
+// SetChatSetting stores value for key; setting the default removes the row.
+// It returns ErrNotFound when the chat is not registered.
+func (s *Store) SetChatSetting(ctx context.Context, chatID int64, key, value string) error {
+	if value == SettingDefaults[key] {
+		return s.deleteChatSetting(ctx, chatID, key)
+	}
+	q := s.sql.Insert("chat_settings").
+		Columns("chat_id", "key", "value", "updated_at").
+		Select(sq.Select("id").Column("?", key).Column("?", value).Column(nowExpr(s.driver)).From("chats").Where(sq.Eq{"id": chatID})).
+		Suffix("ON CONFLICT(chat_id, key) DO UPDATE SET value=excluded.value, updated_at=excluded.updated_at")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build set chat setting query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("set chat setting: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ChatsWithSetting returns the chats that store a non-default value for key,
+// mapped to that value.
+func (s *Store) ChatsWithSetting(ctx context.Context, key string) (map[int64]string, error) {
+	q := s.sql.Select("chat_id", "value").From("chat_settings").Where(sq.Eq{"key": key})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build chats with setting query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query chats with setting: %w", err)
+	}
+	defer rows.Close()
+	out := map[int64]string{}
+	for rows.Next() {
+		var chatID int64
+		var value string
+		if err := rows.Scan(&chatID, &value); err != nil {
+			return nil, fmt.Errorf("scan chat setting: %w", err)
+		}
+		out[chatID] = value
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) deleteChatSetting(ctx context.Context, chatID int64, key string) error {
+	if err := s.chatExists(ctx, chatID); err != nil {
+		return err
+	}
+	sqlStr, args, err := s.sql.Delete("chat_settings").Where(sq.Eq{"chat_id": chatID, "key": key}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build delete chat setting query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("delete chat setting: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) chatExists(ctx context.Context, chatID int64) error {
+	sqlStr, args, err := s.sql.Select("COUNT(*)").From("chats").Where(sq.Eq{"id": chatID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build chat exists query: %w", err)
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&n); err != nil {
+		return fmt.Errorf("check chat exists: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetCommandPrefixes(ctx context.Context, chatID int64) (string, error) {
+	return s.GetChatSetting(ctx, chatID, SettingCommandPrefixes)
+}
+
+func (s *Store) SetCommandPrefixes(ctx context.Context, chatID int64, prefixes string) error {
+	return s.SetChatSetting(ctx, chatID, SettingCommandPrefixes, prefixes)
+}
+
+func (s *Store) GetExportsAllowed(ctx context.Context, chatID int64) (bool, error) {
+	v, err := s.GetChatSetting(ctx, chatID, SettingExports)
+	return v == SettingOn, err
+}
+
+func (s *Store) SetExportsAllowed(ctx context.Context, chatID int64, allowed bool) error {
+	return s.SetChatSetting(ctx, chatID, SettingExports, onOff(allowed))
+}
+
+func (s *Store) GetChatPersona(ctx context.Context, chatID int64) (string, error) {
+	return s.GetChatSetting(ctx, chatID, SettingPersona)
+}
+
+func (s *Store) SetChatPersona(ctx context.Context, chatID int64, persona string) error {
+	return s.SetChatSetting(ctx, chatID, SettingPersona, persona)
+}
+
+func (s *Store) GetMentionTrigger(ctx context.Context, chatID int64) (bool, error) {
+	v, err := s.GetChatSetting(ctx, chatID, SettingMention)
+	return v == SettingOn, err
+}
+
+func (s *Store) SetMentionTrigger(ctx context.Context, chatID int64, enabled bool) error {
+	return s.SetChatSetting(ctx, chatID, SettingMention, onOff(enabled))
+}
+
+func (s *Store) GetAckStyle(ctx context.Context, chatID int64) (string, error) {
+	return s.GetChatSetting(ctx, chatID, SettingAck)
+}
+
+func onOff(v bool) string {
+	if v {
+		return SettingOn
+	}
+	return SettingOff
+}
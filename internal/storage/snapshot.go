@@ -0,0 +1,496 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SnapshotVersion 2 moved per-chat settings from chats columns to
+// ChatSettings. Version 1 snapshots still restore, with default settings.
+const SnapshotVersion = 2
+
+type Snapshot struct {
+	Version    int                   `json:"version"`
+	CreatedAt  time.Time             `json:"created_at"`
+	Chats      []Chat                `json:"chats"`
+	Settings   []ChatSetting         `json:"chat_settings"`
+	Providers  []ProviderInstance    `json:"providers"`
+	Presets    []Preset              `json:"presets"`
+	Revisions  []PresetRevision      `json:"preset_revisions"`
+	Users      []User                `json:"users"`
+	Members    []ChatUser            `json:"chat_users"`
+	AuditLog   []AuditRecord         `json:"audit_log"`
+	History    []ConversationMessage `json:"history"`
+	Feedback   []Feedback            `json:"feedback"`
+	Usage      []UsageEvent          `json:"usage_events"`
+	Credits    []CreditEntry         `json:"credit_ledger"`
+	Referrals  []Referral            `json:"referrals"`
+	ABTests    []ABExperiment        `json:"ab_experiments"`
+	Templates  []ChatTemplate        `json:"chat_templates"`
+	Knowledge  []KnowledgeEntry      `json:"chat_knowledge"`
+	Webhooks   []Webhook             `json:"webhooks"`
+	Deliveries []WebhookDelivery     `json:"webhook_deliveries"`
+}
+
+type AuditRecord struct {
+	ID int64 `json:"id"`
+	AuditEntry
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// snapshotTables lists tables in restore order; deletion runs in reverse so
+// foreign keys are never violated.
+var snapshotTables = []string{"chats", "chat_settings", "users", "chat_users", "provider_instances", "presets", "preset_revisions", "audit_log", "conversation_messages", "answer_feedback", "usage_events", "credit_ledger", "referrals", "ab_experiments", "chat_templates", "chat_knowledge", "webhooks", "webhook_deliveries"}
+
+func (s *Store) ExportSnapshot(ctx context.Context) (Snapshot, error) {
+	snap := Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC()}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: s.driver == "postgres"})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("begin export tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := exportRows(ctx, tx, s.sql.Select("id", "type", "title", "default_preset_name", "left_at", "created_at").From("chats").OrderBy("id"), func(rows *sql.Rows) error {
+		var c Chat
+		var def sql.NullString
+		var leftAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Type, &c.Title, &def, &leftAt, &c.CreatedAt); err != nil {
+			return err
+		}
+		if def.Valid {
+			c.DefaultPresetName = &def.String
+		}
+		if leftAt.Valid {
+			c.LeftAt = &leftAt.Time
+		}
+		snap.Chats = append(snap.Chats, c)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export chats: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("chat_id", "key", "value", "updated_at").From("chat_settings").OrderBy("chat_id", "key"), func(rows *sql.Rows) error {
+		var cs ChatSetting
+		if err := rows.Scan(&cs.ChatID, &cs.Key, &cs.Value, &cs.UpdatedAt); err != nil {
+			return err
+		}
+		snap.Settings = append(snap.Settings, cs)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export chat settings: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at").From("provider_instances").OrderBy("id"), func(rows *sql.Rows) error {
+		var p ProviderInstance
+		var encAPIKey, encHeaders sql.NullString
+		if err := rows.Scan(&p.ID, &p.ChatID, &p.Name, &p.Kind, &p.BaseURL, &encAPIKey, &encHeaders, &p.ConfigJSON, &p.CreatedAt); err != nil {
+			return err
+		}
+		if encAPIKey.Valid {
+			p.EncAPIKey = &encAPIKey.String
+		}
+		if encHeaders.Valid {
+			p.EncHeadersJSON = &encHeaders.String
+		}
+		snap.Providers = append(snap.Providers, p)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export providers: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("chat_id", "name", "provider_instance_id", "model", "system_prompt", "params_json", "degraded_reason", "degraded_at", "created_at").From("presets").OrderBy("chat_id", "name"), func(rows *sql.Rows) error {
+		var p Preset
+		var degradedAt sql.NullTime
+		if err := rows.Scan(&p.ChatID, &p.Name, &p.ProviderInstanceID, &p.Model, &p.SystemPrompt, &p.ParamsJSON, &p.DegradedReason, &degradedAt, &p.CreatedAt); err != nil {
+			return err
+		}
+		if degradedAt.Valid {
+			p.DegradedAt = &degradedAt.Time
+		}
+		snap.Presets = append(snap.Presets, p)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export presets: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(presetRevisionColumns...).From("preset_revisions").OrderBy("chat_id", "preset_name", "rev"), func(rows *sql.Rows) error {
+		r, err := scanPresetRevision(rows)
+		if err != nil {
+			return err
+		}
+		snap.Revisions = append(snap.Revisions, r)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export preset revisions: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("id", "username", "first_name", "first_seen_at", "last_active_at", "message_count").From("users").OrderBy("id"), func(rows *sql.Rows) error {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.FirstName, &u.FirstSeenAt, &u.LastActiveAt, &u.MessageCount); err != nil {
+			return err
+		}
+		snap.Users = append(snap.Users, u)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export users: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("chat_id", "user_id", "first_seen_at", "last_active_at", "message_count").From("chat_users").OrderBy("chat_id", "user_id"), func(rows *sql.Rows) error {
+		var m ChatUser
+		if err := rows.Scan(&m.ChatID, &m.UserID, &m.FirstSeenAt, &m.LastActiveAt, &m.MessageCount); err != nil {
+			return err
+		}
+		snap.Members = append(snap.Members, m)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export chat users: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("id", "chat_id", "user_id", "action", "meta_json", "created_at").From("audit_log").OrderBy("id"), func(rows *sql.Rows) error {
+		var a AuditRecord
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.UserID, &a.Action, &a.MetaJSON, &a.CreatedAt); err != nil {
+			return err
+		}
+		snap.AuditLog = append(snap.AuditLog, a)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export audit log: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("id", "chat_id", "user_id", "job_id", "preset_name", "prompt", "answer", "created_at").From("conversation_messages").OrderBy("id"), func(rows *sql.Rows) error {
+		var m ConversationMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.UserID, &m.JobID, &m.PresetName, &m.Prompt, &m.Answer, &m.CreatedAt); err != nil {
+			return err
+		}
+		snap.History = append(snap.History, m)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export history: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("job_id", "user_id", "chat_id", "preset_name", "model", "latency_ms", "vote", "ab_arm", "created_at").From("answer_feedback").OrderBy("created_at", "job_id", "user_id"), func(rows *sql.Rows) error {
+		var f Feedback
+		if err := rows.Scan(&f.JobID, &f.UserID, &f.ChatID, &f.PresetName, &f.Model, &f.LatencyMs, &f.Vote, &f.ABArm, &f.CreatedAt); err != nil {
+			return err
+		}
+		snap.Feedback = append(snap.Feedback, f)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export feedback: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(usageEventColumns...).From("usage_events").OrderBy("id"), func(rows *sql.Rows) error {
+		var e UsageEvent
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.UserID, &e.PresetName, &e.Model, &e.InputTokens, &e.OutputTokens, &e.LatencyMs, &e.Failed, &e.CreatedAt); err != nil {
+			return err
+		}
+		snap.Usage = append(snap.Usage, e)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export usage events: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(creditEntryColumns...).From("credit_ledger").OrderBy("id"), func(rows *sql.Rows) error {
+		var e CreditEntry
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.UserID, &e.Delta, &e.Reason, &e.ChargeID, &e.CreatedAt); err != nil {
+			return err
+		}
+		snap.Credits = append(snap.Credits, e)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export credit ledger: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select("chat_id", "code", "user_id", "created_at").From("referrals").OrderBy("chat_id"), func(rows *sql.Rows) error {
+		var r Referral
+		if err := rows.Scan(&r.ChatID, &r.Code, &r.UserID, &r.CreatedAt); err != nil {
+			return err
+		}
+		snap.Referrals = append(snap.Referrals, r)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export referrals: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(abExperimentColumns...).From("ab_experiments").OrderBy("chat_id"), func(rows *sql.Rows) error {
+		e, err := scanABExperiment(rows)
+		if err != nil {
+			return err
+		}
+		snap.ABTests = append(snap.ABTests, e)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export ab experiments: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(chatTemplateColumns...).From("chat_templates").OrderBy("chat_id", "name"), func(rows *sql.Rows) error {
+		var t ChatTemplate
+		if err := rows.Scan(&t.ChatID, &t.Name, &t.Body, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return err
+		}
+		snap.Templates = append(snap.Templates, t)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export chat templates: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(knowledgeColumns...).From("chat_knowledge").OrderBy("chat_id", "message_id"), func(rows *sql.Rows) error {
+		var e KnowledgeEntry
+		if err := rows.Scan(&e.ChatID, &e.MessageID, &e.Text, &e.CreatedAt); err != nil {
+			return err
+		}
+		snap.Knowledge = append(snap.Knowledge, e)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export chat knowledge: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(webhookColumns...).From("webhooks").OrderBy("name"), func(rows *sql.Rows) error {
+		var w Webhook
+		var events string
+		if err := rows.Scan(&w.Name, &w.URL, &w.EncSecret, &events, &w.CreatedBy, &w.CreatedAt); err != nil {
+			return err
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		snap.Webhooks = append(snap.Webhooks, w)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export webhooks: %w", err)
+	}
+
+	if err := exportRows(ctx, tx, s.sql.Select(webhookDeliveryColumns...).From("webhook_deliveries").OrderBy("id"), func(rows *sql.Rows) error {
+		var d WebhookDelivery
+		var delivered sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookName, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &delivered); err != nil {
+			return err
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		snap.Deliveries = append(snap.Deliveries, d)
+		return nil
+	}); err != nil {
+		return Snapshot{}, fmt.Errorf("export webhook deliveries: %w", err)
+	}
+
+	return snap, nil
+}
+
+// RestoreSnapshot replaces the content of every snapshot table with snap in a
+// single transaction.
+func (s *Store) RestoreSnapshot(ctx context.Context, snap Snapshot) error {
+	if snap.Version != SnapshotVersion && snap.Version != 1 {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin restore tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i := len(snapshotTables) - 1; i >= 0; i-- {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+snapshotTables[i]); err != nil {
+			return fmt.Errorf("clear %s: %w", snapshotTables[i], err)
+		}
+	}
+
+	for _, c := range snap.Chats {
+		q := s.sql.Insert("chats").
+			Columns("id", "type", "title", "default_preset_name", "left_at", "created_at").
+			Values(c.ID, c.Type, c.Title, c.DefaultPresetName, c.LeftAt, c.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore chat %d: %w", c.ID, err)
+		}
+	}
+	for _, cs := range snap.Settings {
+		q := s.sql.Insert("chat_settings").
+			Columns("chat_id", "key", "value", "updated_at").
+			Values(cs.ChatID, cs.Key, cs.Value, cs.UpdatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore chat setting %d/%s: %w", cs.ChatID, cs.Key, err)
+		}
+	}
+	for _, u := range snap.Users {
+		q := s.sql.Insert("users").
+			Columns("id", "username", "first_name", "first_seen_at", "last_active_at", "message_count").
+			Values(u.ID, u.Username, u.FirstName, u.FirstSeenAt, u.LastActiveAt, u.MessageCount)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore user %d: %w", u.ID, err)
+		}
+	}
+	for _, m := range snap.Members {
+		q := s.sql.Insert("chat_users").
+			Columns("chat_id", "user_id", "first_seen_at", "last_active_at", "message_count").
+			Values(m.ChatID, m.UserID, m.FirstSeenAt, m.LastActiveAt, m.MessageCount)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore chat user %d/%d: %w", m.ChatID, m.UserID, err)
+		}
+	}
+	for _, p := range snap.Providers {
+		q := s.sql.Insert("provider_instances").
+			Columns("id", "chat_id", "name", "kind", "base_url", "enc_api_key", "enc_headers_json", "config_json", "created_at").
+			Values(p.ID, p.ChatID, p.Name, p.Kind, p.BaseURL, p.EncAPIKey, p.EncHeadersJSON, p.ConfigJSON, p.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore provider %d: %w", p.ID, err)
+		}
+	}
+	for _, p := range snap.Presets {
+		q := s.sql.Insert("presets").
+			Columns("chat_id", "name", "provider_instance_id", "model", "system_prompt", "params_json", "degraded_reason", "degraded_at", "created_at").
+			Values(p.ChatID, p.Name, p.ProviderInstanceID, p.Model, p.SystemPrompt, p.ParamsJSON, p.DegradedReason, p.DegradedAt, p.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore preset %s: %w", p.Name, err)
+		}
+	}
+	for _, r := range snap.Revisions {
+		q := s.sql.Insert("preset_revisions").
+			Columns(presetRevisionColumns...).
+			Values(r.ChatID, r.PresetName, r.Rev, r.ProviderInstanceID, r.Model, r.SystemPrompt, r.ParamsJSON, r.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore preset revision %s/%d: %w", r.PresetName, r.Rev, err)
+		}
+	}
+	for _, a := range snap.AuditLog {
+		q := s.sql.Insert("audit_log").
+			Columns("id", "chat_id", "user_id", "action", "meta_json", "created_at").
+			Values(a.ID, a.ChatID, a.UserID, a.Action, a.MetaJSON, a.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore audit entry %d: %w", a.ID, err)
+		}
+	}
+	for _, m := range snap.History {
+		q := s.sql.Insert("conversation_messages").
+			Columns("id", "chat_id", "user_id", "job_id", "preset_name", "prompt", "answer", "created_at").
+			Values(m.ID, m.ChatID, m.UserID, m.JobID, m.PresetName, m.Prompt, m.Answer, m.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore history entry %d: %w", m.ID, err)
+		}
+	}
+	for _, f := range snap.Feedback {
+		q := s.sql.Insert("answer_feedback").
+			Columns("job_id", "user_id", "chat_id", "preset_name", "model", "latency_ms", "vote", "ab_arm", "created_at").
+			Values(f.JobID, f.UserID, f.ChatID, f.PresetName, f.Model, f.LatencyMs, f.Vote, f.ABArm, f.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore feedback %s/%d: %w", f.JobID, f.UserID, err)
+		}
+	}
+	for _, e := range snap.Usage {
+		q := s.sql.Insert("usage_events").
+			Columns(usageEventColumns...).
+			Values(e.ID, e.ChatID, e.UserID, e.PresetName, e.Model, e.InputTokens, e.OutputTokens, e.LatencyMs, e.Failed, e.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore usage event %d: %w", e.ID, err)
+		}
+	}
+	for _, e := range snap.Credits {
+		q := s.sql.Insert("credit_ledger").
+			Columns(creditEntryColumns...).
+			Values(e.ID, e.ChatID, e.UserID, e.Delta, e.Reason, e.ChargeID, e.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore credit entry %d: %w", e.ID, err)
+		}
+	}
+	for _, r := range snap.Referrals {
+		q := s.sql.Insert("referrals").
+			Columns("chat_id", "code", "user_id", "created_at").
+			Values(r.ChatID, r.Code, r.UserID, r.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore referral for chat %d: %w", r.ChatID, err)
+		}
+	}
+	for _, e := range snap.ABTests {
+		q := s.sql.Insert("ab_experiments").
+			Columns(abExperimentColumns...).
+			Values(e.ChatID, e.PresetA, e.PresetB, e.SplitPct, e.ServedA, e.ServedB, e.LatencyMsA, e.LatencyMsB, e.StartedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore ab experiment %d: %w", e.ChatID, err)
+		}
+	}
+	for _, t := range snap.Templates {
+		q := s.sql.Insert("chat_templates").
+			Columns(chatTemplateColumns...).
+			Values(t.ChatID, t.Name, t.Body, t.CreatedBy, t.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore chat template %d/%s: %w", t.ChatID, t.Name, err)
+		}
+	}
+	for _, e := range snap.Knowledge {
+		q := s.sql.Insert("chat_knowledge").
+			Columns(knowledgeColumns...).
+			Values(e.ChatID, e.MessageID, e.Text, e.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore chat knowledge %d/%d: %w", e.ChatID, e.MessageID, err)
+		}
+	}
+	for _, w := range snap.Webhooks {
+		q := s.sql.Insert("webhooks").
+			Columns(webhookColumns...).
+			Values(w.Name, w.URL, w.EncSecret, strings.Join(w.Events, ","), w.CreatedBy, w.CreatedAt)
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore webhook %s: %w", w.Name, err)
+		}
+	}
+	// Pending deliveries are due right away: the restored bot retries them
+	// instead of waiting out a backoff from before the backup.
+	for _, d := range snap.Deliveries {
+		q := s.sql.Insert("webhook_deliveries").
+			Columns(webhookDeliveryColumns...).
+			Columns("next_attempt_at").
+			Values(d.ID, d.WebhookName, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts, d.LastStatusCode, d.LastError, d.CreatedAt, d.DeliveredAt, nowExpr(s.driver))
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("restore webhook delivery %d: %w", d.ID, err)
+		}
+	}
+
+	if s.driver == "postgres" {
+		// Explicit ids bypass BIGSERIAL, so move the sequences past them.
+		for _, table := range []string{"provider_instances", "audit_log", "conversation_messages", "usage_events", "credit_ledger", "webhook_deliveries"} {
+			stmt := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE((SELECT MAX(id) FROM %s), 0) + 1, false)", table, table)
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("reset %s sequence: %w", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit restore: %w", err)
+	}
+	return nil
+}
+
+func exportRows(ctx context.Context, tx *sql.Tx, q sq.Sqlizer, scan func(*sql.Rows) error) error {
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func execTx(ctx context.Context, tx *sql.Tx, q sq.Sqlizer) error {
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var chatTemplateColumns = []string{"chat_id", "name", "body", "created_by", "created_at"}
+
+// UpsertChatTemplate saves t, replacing a template of the same name.
+func (s *Store) UpsertChatTemplate(ctx context.Context, t ChatTemplate) error {
+	q := s.sql.Insert("chat_templates").
+		Columns(chatTemplateColumns...).
+		Values(t.ChatID, t.Name, t.Body, t.CreatedBy, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(chat_id, name) DO UPDATE SET body=excluded.body, created_by=excluded.created_by, created_at=excluded.created_at")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build upsert chat template query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("upsert chat template: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetChatTemplate(ctx context.Context, chatID int64, name string) (ChatTemplate, error) {
+	q := s.sql.Select(chatTemplateColumns...).From("chat_templates").Where(sq.Eq{"chat_id": chatID, "name": name})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return ChatTemplate{}, fmt.Errorf("build get chat template query: %w", err)
+	}
+	var t ChatTemplate
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&t.ChatID, &t.Name, &t.Body, &t.CreatedBy, &t.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ChatTemplate{}, ErrNotFound
+		}
+		return ChatTemplate{}, fmt.Errorf("get chat template: %w", err)
+	}
+	return t, nil
+}
+
+func (s *Store) ListChatTemplates(ctx context.Context, chatID int64) ([]ChatTemplate, error) {
+	q := s.sql.Select(chatTemplateColumns...).From("chat_templates").Where(sq.Eq{"chat_id": chatID}).OrderBy("name")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build list chat templates query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list chat templates: %w", err)
+	}
+	defer rows.Close()
+	var out []ChatTemplate
+	for rows.Next() {
+		var t ChatTemplate
+		if err := rows.Scan(&t.ChatID, &t.Name, &t.Body, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan chat template: %w", err)
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeleteChatTemplate(ctx context.Context, chatID int64, name string) error {
+	sqlStr, args, err := s.sql.Delete("chat_templates").Where(sq.Eq{"chat_id": chatID, "name": name}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build delete chat template query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("delete chat template: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
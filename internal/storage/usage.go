@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var usageEventColumns = []string{"id", "chat_id", "user_id", "preset_name", "model", "input_tokens", "output_tokens", "latency_ms", "failed", "created_at"}
+
+// usageTopN bounds the top users and presets lists of a UsageSummary.
+const usageTopN = 5
+
+func (s *Store) RecordUsage(ctx context.Context, e UsageEvent) error {
+	q := s.sql.Insert("usage_events").
+		Columns("chat_id", "user_id", "preset_name", "model", "input_tokens", "output_tokens", "latency_ms", "failed", "created_at").
+		Values(e.ChatID, e.UserID, e.PresetName, e.Model, e.InputTokens, e.OutputTokens, e.LatencyMs, e.Failed, nowExpr(s.driver))
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build record usage query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("record usage: %w", err)
+	}
+	return nil
+}
+
+// UsageSummary aggregates the chat's usage events of the last period.
+func (s *Store) UsageSummary(ctx context.Context, chatID int64, period time.Duration) (UsageSummary, error) {
+	var sum UsageSummary
+	where := sq.And{sq.Eq{"chat_id": chatID}, sinceExpr(s.driver, "created_at", period)}
+
+	q := s.sql.Select(
+		"COUNT(*)",
+		"COALESCE(SUM(CASE WHEN failed THEN 1 ELSE 0 END), 0)",
+		"COALESCE(SUM(input_tokens), 0)",
+		"COALESCE(SUM(output_tokens), 0)",
+	).From("usage_events").Where(where)
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return UsageSummary{}, fmt.Errorf("build usage summary query: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&sum.Requests, &sum.Failed, &sum.InputTokens, &sum.OutputTokens); err != nil {
+		return UsageSummary{}, fmt.Errorf("query usage summary: %w", err)
+	}
+	if sum.Requests == 0 {
+		return sum, nil
+	}
+
+	// Users are labelled by username, then first name, then id.
+	userLabel := "COALESCE(NULLIF(u.username, ''), NULLIF(u.first_name, ''), CAST(e.user_id AS TEXT))"
+	users := s.sql.Select(userLabel, "COUNT(*)").
+		From("usage_events e").
+		LeftJoin("users u ON u.id = e.user_id").
+		Where(sq.And{sq.Eq{"e.chat_id": chatID}, sinceExpr(s.driver, "e.created_at", period)}).
+		GroupBy("e.user_id", "u.username", "u.first_name").
+		OrderBy("COUNT(*) DESC", "e.user_id").
+		Limit(usageTopN)
+	if sum.TopUsers, err = s.usageCounts(ctx, users); err != nil {
+		return UsageSummary{}, fmt.Errorf("query top users: %w", err)
+	}
+
+	presets := s.sql.Select("preset_name", "COUNT(*)").
+		From("usage_events").
+		Where(append(where, sq.NotEq{"preset_name": ""})).
+		GroupBy("preset_name").
+		OrderBy("COUNT(*) DESC", "preset_name").
+		Limit(usageTopN)
+	if sum.TopPresets, err = s.usageCounts(ctx, presets); err != nil {
+		return UsageSummary{}, fmt.Errorf("query top presets: %w", err)
+	}
+	return sum, nil
+}
+
+// UserUsage totals one member's usage events in the chat over the last
+// period; the top lists stay empty.
+func (s *Store) UserUsage(ctx context.Context, chatID, userID int64, period time.Duration) (UsageSummary, error) {
+	q := s.sql.Select(
+		"COUNT(*)",
+		"COALESCE(SUM(CASE WHEN failed THEN 1 ELSE 0 END), 0)",
+		"COALESCE(SUM(input_tokens), 0)",
+		"COALESCE(SUM(output_tokens), 0)",
+	).From("usage_events").
+		Where(sq.And{sq.Eq{"chat_id": chatID, "user_id": userID}, sinceExpr(s.driver, "created_at", period)})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return UsageSummary{}, fmt.Errorf("build user usage query: %w", err)
+	}
+	var sum UsageSummary
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&sum.Requests, &sum.Failed, &sum.InputTokens, &sum.OutputTokens); err != nil {
+		return UsageSummary{}, fmt.Errorf("query user usage: %w", err)
+	}
+	return sum, nil
+}
+
+// UsageByChat aggregates the usage events of the last period per chat,
+// busiest chats first.
+func (s *Store) UsageByChat(ctx context.Context, period time.Duration) ([]ChatUsage, error) {
+	q := s.sql.Select(
+		"chat_id",
+		"COUNT(*)",
+		"COALESCE(SUM(CASE WHEN failed THEN 1 ELSE 0 END), 0)",
+		"COALESCE(SUM(input_tokens), 0)",
+		"COALESCE(SUM(output_tokens), 0)",
+		"COALESCE(AVG(latency_ms), 0)",
+	).From("usage_events").
+		Where(sinceExpr(s.driver, "created_at", period)).
+		GroupBy("chat_id").
+		OrderBy("COUNT(*) DESC", "chat_id")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build usage by chat query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query usage by chat: %w", err)
+	}
+	defer rows.Close()
+	var out []ChatUsage
+	for rows.Next() {
+		var u ChatUsage
+		if err := rows.Scan(&u.ChatID, &u.Requests, &u.Failed, &u.InputTokens, &u.OutputTokens, &u.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("scan usage by chat: %w", err)
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) usageCounts(ctx context.Context, q sq.SelectBuilder) ([]UsageCount, error) {
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UsageCount
+	for rows.Next() {
+		var c UsageCount
+		if err := rows.Scan(&c.Label, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ProviderLastUsed returns when a preset of the provider last served a
+// request, or nil if none did. Usage is recorded per preset, so this follows
+// the presets as they point now.
+func (s *Store) ProviderLastUsed(ctx context.Context, chatID, providerID int64) (*time.Time, error) {
+	q := s.sql.Select("e.created_at").
+		From("usage_events e").
+		Join("presets p ON p.chat_id = e.chat_id AND p.name = e.preset_name").
+		Where(sq.Eq{"e.chat_id": chatID, "p.provider_instance_id": providerID}).
+		OrderBy("e.created_at DESC").
+		Limit(1)
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build provider last used query: %w", err)
+	}
+	var at time.Time
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&at); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query provider last used: %w", err)
+	}
+	return &at, nil
+}
+
+// ChatHasUsage reports whether the chat ever got an answer or error from a
+// provider.
+func (s *Store) ChatHasUsage(ctx context.Context, chatID int64) (bool, error) {
+	q := s.sql.Select("1").From("usage_events").Where(sq.Eq{"chat_id": chatID}).Limit(1)
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build chat usage query: %w", err)
+	}
+	var one int
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(&one); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("query chat usage: %w", err)
+	}
+	return true, nil
+}
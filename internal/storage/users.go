@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// RecordActivity upserts a batch of user activity: the users' names and
+// totals, and their activity per chat.
+func (s *Store) RecordActivity(ctx context.Context, batch []UserActivity) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin record activity tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, a := range batch {
+		q := s.sql.Insert("users").
+			Columns("id", "username", "first_name", "last_active_at", "message_count").
+			Values(a.UserID, a.Username, a.FirstName, nowExpr(s.driver), a.Messages).
+			Suffix("ON CONFLICT(id) DO UPDATE SET username=excluded.username, first_name=excluded.first_name, last_active_at=excluded.last_active_at, message_count=users.message_count+excluded.message_count")
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("touch user %d: %w", a.UserID, err)
+		}
+		if a.ChatID == 0 {
+			continue
+		}
+		q = s.sql.Insert("chat_users").
+			Columns("chat_id", "user_id", "last_active_at", "message_count").
+			Values(a.ChatID, a.UserID, nowExpr(s.driver), a.Messages).
+			Suffix("ON CONFLICT(chat_id, user_id) DO UPDATE SET last_active_at=excluded.last_active_at, message_count=chat_users.message_count+excluded.message_count")
+		if err := execTx(ctx, tx, q); err != nil {
+			return fmt.Errorf("touch chat user %d/%d: %w", a.ChatID, a.UserID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit record activity: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetUser(ctx context.Context, userID int64) (User, error) {
+	return s.getUser(ctx, sq.Eq{"id": userID})
+}
+
+func (s *Store) getUser(ctx context.Context, where sq.Sqlizer) (User, error) {
+	q := s.sql.Select("id", "username", "first_name", "first_seen_at", "last_active_at", "message_count").
+		From("users").
+		Where(where).
+		OrderBy("last_active_at DESC").
+		Limit(1)
+	return s.scanUser(ctx, q)
+}
+
+// GetChatUser returns a user the bot saw in chatID, with the first seen,
+// last active and message count of that chat only. Users never seen there
+// are ErrNotFound, so chat admins cannot look up activity elsewhere.
+func (s *Store) GetChatUser(ctx context.Context, chatID, userID int64) (User, error) {
+	return s.getChatUser(ctx, chatID, sq.Eq{"u.id": userID})
+}
+
+func (s *Store) GetChatUserByUsername(ctx context.Context, chatID int64, username string) (User, error) {
+	username = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+	if username == "" {
+		return User{}, ErrNotFound
+	}
+	return s.getChatUser(ctx, chatID, sq.Expr("LOWER(u.username) = ?", username))
+}
+
+func (s *Store) getChatUser(ctx context.Context, chatID int64, where sq.Sqlizer) (User, error) {
+	q := s.sql.Select("u.id", "u.username", "u.first_name", "cu.first_seen_at", "cu.last_active_at", "cu.message_count").
+		From("chat_users cu").
+		Join("users u ON u.id = cu.user_id").
+		Where(sq.And{sq.Eq{"cu.chat_id": chatID}, where}).
+		OrderBy("cu.last_active_at DESC").
+		Limit(1)
+	return s.scanUser(ctx, q)
+}
+
+func (s *Store) scanUser(ctx context.Context, q sq.SelectBuilder) (User, error) {
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return User{}, fmt.Errorf("build get user query: %w", err)
+	}
+
+	var u User
+	if err := s.db.QueryRowContext(ctx, sqlStr, args...).Scan(
+		&u.ID,
+		&u.Username,
+		&u.FirstName,
+		&u.FirstSeenAt,
+		&u.LastActiveAt,
+		&u.MessageCount,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("get user: %w", err)
+	}
+	return u, nil
+}
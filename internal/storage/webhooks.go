@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var webhookColumns = []string{"name", "url", "enc_secret", "events", "created_by", "created_at"}
+
+var webhookDeliveryColumns = []string{"id", "webhook_name", "event_id", "event_type", "payload", "status", "attempts", "last_status_code", "last_error", "created_at", "delivered_at"}
+
+// maxDeliveryErrorRunes bounds the error text kept per delivery.
+const maxDeliveryErrorRunes = 500
+
+// SaveWebhook adds w or replaces the webhook of the same name.
+func (s *Store) SaveWebhook(ctx context.Context, w Webhook) error {
+	q := s.sql.Insert("webhooks").
+		Columns(webhookColumns...).
+		Values(w.Name, w.URL, w.EncSecret, strings.Join(w.Events, ","), w.CreatedBy, nowExpr(s.driver)).
+		Suffix("ON CONFLICT(name) DO UPDATE SET url=excluded.url, enc_secret=excluded.enc_secret, events=excluded.events, created_by=excluded.created_by, created_at=excluded.created_at")
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build save webhook query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("save webhook: %w", err)
+	}
+	return nil
+}
+
+// ListWebhooks returns the webhooks by name.
+func (s *Store) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	sqlStr, args, err := s.sql.Select(webhookColumns...).From("webhooks").OrderBy("name").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build list webhooks query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer rows.Close()
+	var out []Webhook
+	for rows.Next() {
+		var w Webhook
+		var events string
+		if err := rows.Scan(&w.Name, &w.URL, &w.EncSecret, &events, &w.CreatedBy, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// DeleteWebhook removes the webhook and its delivery log. It returns
+// ErrNotFound when there is no webhook of that name.
+func (s *Store) DeleteWebhook(ctx context.Context, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete webhook tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// SQLite does not enforce the foreign key, so the log goes explicitly.
+	if err := execTx(ctx, tx, s.sql.Delete("webhook_deliveries").Where(sq.Eq{"webhook_name": name})); err != nil {
+		return fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+	sqlStr, args, err := s.sql.Delete("webhooks").Where(sq.Eq{"name": name}).ToSql()
+	if err != nil {
+		return fmt.Errorf("build delete webhook query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete webhook: %w", err)
+	}
+	return nil
+}
+
+// QueueWebhookDeliveries records deliveries due now.
+func (s *Store) QueueWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	q := s.sql.Insert("webhook_deliveries").
+		Columns("webhook_name", "event_id", "event_type", "payload", "status", "next_attempt_at", "created_at")
+	for _, d := range deliveries {
+		q = q.Values(d.WebhookName, d.EventID, d.EventType, d.Payload, DeliveryPending, nowExpr(s.driver), nowExpr(s.driver))
+	}
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build queue webhook deliveries query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries whose next
+// attempt is due, oldest first.
+func (s *Store) DueWebhookDeliveries(ctx context.Context, limit int) ([]WebhookDelivery, error) {
+	q := s.sql.Select(webhookDeliveryColumns...).From("webhook_deliveries").
+		Where(sq.Eq{"status": DeliveryPending}).
+		Where(sq.Expr("next_attempt_at <= ?", offsetExpr(s.driver, 0))).
+		OrderBy("id").Limit(uint64(limit))
+	return s.queryWebhookDeliveries(ctx, q)
+}
+
+// RecentWebhookDeliveries returns the latest limit deliveries, newest
+// first.
+func (s *Store) RecentWebhookDeliveries(ctx context.Context, limit int) ([]WebhookDelivery, error) {
+	q := s.sql.Select(webhookDeliveryColumns...).From("webhook_deliveries").
+		OrderBy("id DESC").Limit(uint64(limit))
+	return s.queryWebhookDeliveries(ctx, q)
+}
+
+func (s *Store) queryWebhookDeliveries(ctx context.Context, q sq.SelectBuilder) ([]WebhookDelivery, error) {
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build webhook deliveries query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var delivered sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookName, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &delivered); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ClaimWebhookDelivery takes the delivery for one attempt, which must end
+// within lease; after it the delivery is due again. It reports false when
+// another process claimed the delivery since it was read with attempts.
+func (s *Store) ClaimWebhookDelivery(ctx context.Context, id int64, attempts int, lease time.Duration) (bool, error) {
+	q := s.sql.Update("webhook_deliveries").
+		Set("attempts", attempts+1).
+		Set("next_attempt_at", offsetExpr(s.driver, lease)).
+		Where(sq.Eq{"id": id, "attempts": attempts, "status": DeliveryPending})
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build claim webhook delivery query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return false, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0, nil
+}
+
+// FinishWebhookDelivery records the outcome of an attempt. A pending status
+// makes the delivery due again after retryIn.
+func (s *Store) FinishWebhookDelivery(ctx context.Context, d WebhookDelivery, retryIn time.Duration) error {
+	errText := d.LastError
+	if r := []rune(errText); len(r) > maxDeliveryErrorRunes {
+		errText = string(r[:maxDeliveryErrorRunes])
+	}
+	q := s.sql.Update("webhook_deliveries").
+		Set("status", d.Status).
+		Set("last_status_code", d.LastStatusCode).
+		Set("last_error", errText).
+		Where(sq.Eq{"id": d.ID})
+	switch d.Status {
+	case DeliveryDelivered:
+		q = q.Set("delivered_at", nowExpr(s.driver))
+	case DeliveryPending:
+		q = q.Set("next_attempt_at", offsetExpr(s.driver, retryIn))
+	}
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("build finish webhook delivery query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("finish webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries drops finished deliveries older than age.
+func (s *Store) PruneWebhookDeliveries(ctx context.Context, age time.Duration) (int64, error) {
+	q := s.sql.Delete("webhook_deliveries").
+		Where(sq.NotEq{"status": DeliveryPending}).
+		Where(sq.Expr("created_at < ?", offsetExpr(s.driver, -age)))
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build prune webhook deliveries query: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// offsetExpr is now plus d, computed by the database like sinceExpr.
+func offsetExpr(driver string, d time.Duration) sq.Sqlizer {
+	secs := int64(d / time.Second)
+	if driver == "postgres" {
+		return sq.Expr("NOW() + CAST(? AS INTERVAL)", fmt.Sprintf("%d seconds", secs))
+	}
+	return sq.Expr("datetime('now', ?)", fmt.Sprintf("%+d seconds", secs))
+}
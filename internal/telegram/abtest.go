@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const abStartUsage = "Usage: /ab_start <presetA> <presetB> [percent for A, default 50]"
+
+// abStart routes the chat's default-preset requests between two presets.
+// Requests naming a preset (/ai <preset>) are not part of the experiment.
+func (s *Service) abStart(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, userID, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if len(args) < 2 || len(args) > 3 {
+		return s.reply(ctx, b, abStartUsage)
+	}
+	presetA, presetB := args[0], args[1]
+	if presetA == presetB {
+		return s.reply(ctx, b, "Pick two different presets.")
+	}
+	split := 50
+	if len(args) == 3 {
+		n, err := strconv.Atoi(strings.TrimSuffix(args[2], "%"))
+		if err != nil || n < 1 || n > 99 {
+			return s.reply(ctx, b, "Percent for A must be between 1 and 99.")
+		}
+		split = n
+	}
+	for _, name := range []string{presetA, presetB} {
+		if _, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return s.reply(ctx, b, fmt.Sprintf("Preset %s not found.", name))
+			}
+			return s.reply(ctx, b, "Failed to read preset.")
+		}
+	}
+	if err := s.store.StartABExperiment(context.Background(), chatID, presetA, presetB, split); err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("start ab experiment failed")
+		return s.reply(ctx, b, "Failed to start the experiment.")
+	}
+	_ = s.audit(chatID, userID, "ab_start", map[string]any{"preset_a": presetA, "preset_b": presetB, "split_pct": split})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("started an A/B test: %d%% %s, %d%% %s", split, presetA, 100-split, presetB))
+	return s.reply(ctx, b, fmt.Sprintf(
+		"A/B experiment started: %d%% of /ask requests go to %s, %d%% to %s. Requests naming a preset are not affected. See /ab_report, end it with /ab_stop.",
+		split, presetA, 100-split, presetB))
+}
+
+func (s *Service) abStop(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, userID, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	report, err := s.abReportText(chatID)
+	if err != nil {
+		return s.reply(ctx, b, report)
+	}
+	if err := s.store.StopABExperiment(context.Background(), chatID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("stop ab experiment failed")
+		return s.reply(ctx, b, "Failed to stop the experiment.")
+	}
+	_ = s.audit(chatID, userID, "ab_stop", nil)
+	s.notifyChange(b, ctx, chatID, "stopped the A/B test")
+	return s.reply(ctx, b, "A/B experiment stopped. Final results:\n\n"+report)
+}
+
+func (s *Service) abReport(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	report, _ := s.abReportText(chatID)
+	return s.reply(ctx, b, report)
+}
+
+// abReportText renders the running experiment. On error the text explains the
+// problem to the admin.
+func (s *Service) abReportText(chatID int64) (string, error) {
+	exp, err := s.store.GetABExperiment(context.Background(), chatID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "No A/B experiment is running. Start one with /ab_start <presetA> <presetB> [percent].", err
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("get ab experiment failed")
+		return "Failed to load the experiment.", err
+	}
+	votes, err := s.store.ABFeedback(context.Background(), exp)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("ab feedback failed")
+		return "Failed to load experiment feedback.", err
+	}
+
+	arms := []struct {
+		arm, preset     string
+		share           int
+		served, latency int64
+	}{
+		{storage.ABArmA, exp.PresetA, exp.SplitPct, exp.ServedA, exp.LatencyMsA},
+		{storage.ABArmB, exp.PresetB, 100 - exp.SplitPct, exp.ServedB, exp.LatencyMsB},
+	}
+	lines := []string{fmt.Sprintf("A/B experiment since %s UTC:", exp.StartedAt.UTC().Format("2006-01-02 15:04"))}
+	for _, a := range arms {
+		line := fmt.Sprintf("%s) %s (%d%%): %d answers", a.arm, a.preset, a.share, a.served)
+		if a.served > 0 {
+			line += fmt.Sprintf(", avg %.1fs", float64(a.latency)/float64(a.served)/1000)
+		}
+		v := votes[a.arm]
+		if total := v.Up + v.Down; total > 0 {
+			line += fmt.Sprintf(", 👍 %d / 👎 %d (%d%% positive)", v.Up, v.Down, v.Up*100/total)
+		} else {
+			line += ", no votes yet"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
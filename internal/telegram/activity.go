@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/storage"
+)
+
+// activityFlushInterval is how long user activity collects in memory
+// before it is written in one transaction.
+const activityFlushInterval = time.Minute
+
+// ActivityRecorder collects what the processor sees of users and writes it
+// in batches, so updates do not wait on a database write each.
+type ActivityRecorder struct {
+	store  *storage.Store
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending map[[2]int64]*storage.UserActivity
+}
+
+func NewActivityRecorder(store *storage.Store, logger zerolog.Logger) *ActivityRecorder {
+	return &ActivityRecorder{store: store, logger: logger, pending: map[[2]int64]*storage.UserActivity{}}
+}
+
+// Record notes u in chatID (0 for updates without a chat); messages count
+// toward the message totals.
+func (r *ActivityRecorder) Record(chatID int64, u *gotgbot.User, isMessage bool) {
+	if r == nil || u == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]int64{chatID, u.Id}
+	a := r.pending[key]
+	if a == nil {
+		a = &storage.UserActivity{ChatID: chatID, UserID: u.Id}
+		r.pending[key] = a
+	}
+	a.Username, a.FirstName = u.Username, u.FirstName
+	if isMessage {
+		a.Messages++
+	}
+}
+
+// Run writes the collected activity every activityFlushInterval and once
+// more when ctx ends.
+func (r *ActivityRecorder) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ticker := time.NewTicker(activityFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			r.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+func (r *ActivityRecorder) flush(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = map[[2]int64]*storage.UserActivity{}
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	batch := make([]storage.UserActivity, 0, len(pending))
+	for _, a := range pending {
+		batch = append(batch, *a)
+	}
+	if err := r.store.RecordActivity(ctx, batch); err != nil {
+		r.logger.Warn().Err(err).Int("users", len(batch)).Msg("failed to record user activity")
+	}
+}
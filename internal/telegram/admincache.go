@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// AllowedUpdates lists the update types the bot handles. chat_member is not
+// delivered unless requested explicitly.
+var AllowedUpdates = []string{
+	"message",
+	"edited_message",
+	"callback_query",
+	"my_chat_member",
+	"chat_member",
+	"pre_checkout_query",
+}
+
+func isAdminStatus(status string) bool {
+	return status == "administrator" || status == "creator"
+}
+
+// chatMember drops cached rights as soon as a user is promoted or demoted
+// instead of waiting for ADMIN_CACHE_TTL.
+func (s *Service) chatMember(b *gotgbot.Bot, ctx *ext.Context) error {
+	upd := ctx.ChatMember
+	if upd == nil {
+		return nil
+	}
+	if isAdminStatus(upd.OldChatMember.GetStatus()) == isAdminStatus(upd.NewChatMember.GetStatus()) {
+		return nil
+	}
+	user := upd.NewChatMember.GetUser()
+	s.invalidateAdmin(context.Background(), upd.Chat.Id, user.Id)
+	s.logger.Debug().Int64("chat_id", upd.Chat.Id).Int64("user_id", user.Id).Str("status", upd.NewChatMember.GetStatus()).Msg("admin rights changed, cache invalidated")
+	return nil
+}
+
+// invalidateAdmin clears cached rights of userID, or of the whole chat when
+// userID is 0.
+func (s *Service) invalidateAdmin(ctx context.Context, chatID, userID int64) {
+	if userID == 0 {
+		s.deleteRedisKeys(fmt.Sprintf("hyprbot:admin:%d:*", chatID))
+	} else {
+		_ = s.redis.Del(ctx, adminCacheKey(chatID, userID)).Err()
+	}
+	if err := s.store.DeleteAdminCache(ctx, chatID, userID); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to clear admin cache")
+	}
+}
+
+// adminRefresh is open to everyone: a freshly promoted admin is exactly the
+// user the stale cache locks out.
+func (s *Service) adminRefresh(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	if ctx.EffectiveChat.Type == "private" {
+		return s.reply(ctx, b, "Run this command in group/supergroup.")
+	}
+	s.invalidateAdmin(context.Background(), ctx.EffectiveChat.Id, 0)
+	return s.reply(ctx, b, "Admin rights will be rechecked on the next admin command.")
+}
@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/backup"
+)
+
+func (s *Service) isOwner(ctx *ext.Context) bool {
+	return s.adminUserID > 0 && ctx.EffectiveUser != nil && ctx.EffectiveUser.Id == s.adminUserID
+}
+
+func (s *Service) backup(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	archive, err := backup.Create(context.Background(), s.store, s.crypto)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("backup failed")
+		return s.reply(ctx, b, "Backup failed. Check logs.")
+	}
+	name := backup.FileName(s.now())
+	_, err = b.SendDocument(ctx.EffectiveChat.Id, gotgbot.InputFileByReader(name, bytes.NewReader(archive)), &gotgbot.SendDocumentOpts{
+		Caption: fmt.Sprintf("Encrypted backup (%d bytes). Restore with: hyprbot restore -i %s -yes", len(archive), name),
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Msg("send backup failed")
+		return s.reply(ctx, b, "Failed to send backup file.")
+	}
+	_ = s.audit(0, ctx.EffectiveUser.Id, "backup_export", map[string]any{"bytes": len(archive)})
+	return nil
+}
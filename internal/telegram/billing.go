@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/billing"
+	"hyprbot/internal/storage"
+)
+
+const packsDisabledText = "Credit packs are not sold on this bot."
+
+// balance shows the chat's free requests left this month and its credits.
+func (s *Service) balance(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	if !s.billing.Limited() {
+		return s.reply(ctx, b, "Requests are not limited on this bot.")
+	}
+	chatID := ctx.EffectiveChat.Id
+	var lines []string
+	if s.billing.FreeRequests > 0 && s.quota != nil {
+		used, err := s.quota.Used(context.Background(), chatID, time.Now())
+		if err != nil {
+			s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("failed to read chat quota")
+			return s.reply(ctx, b, "Failed to load the balance.")
+		}
+		lines = append(lines, fmt.Sprintf("Free requests left this month: %d of %d", max(s.billing.FreeRequests-used, 0), s.billing.FreeRequests))
+	}
+	credits, err := s.store.CreditBalance(context.Background(), chatID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("failed to load credit balance")
+		return s.reply(ctx, b, "Failed to load the balance.")
+	}
+	lines = append(lines, fmt.Sprintf("Credits: %d", credits), s.costText())
+	if s.billing.PacksEnabled() {
+		lines = append(lines, s.packText())
+	}
+	return s.reply(ctx, b, strings.Join(lines, "\n"))
+}
+
+// ownerGrant adds (or, with a negative amount, removes) credits for any chat.
+func (s *Service) ownerGrant(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	const usage = "Usage: /owner_grant <chat_id> <credits>"
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.Text))
+	if len(args) != 2 {
+		return s.reply(ctx, b, usage)
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return s.reply(ctx, b, usage)
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || n == 0 {
+		return s.reply(ctx, b, usage)
+	}
+	uid := ctx.EffectiveUser.Id
+	if _, err := s.store.AddCredits(context.Background(), storage.CreditEntry{
+		ChatID: chatID,
+		UserID: uid,
+		Delta:  n,
+		Reason: storage.CreditReasonGrant,
+	}); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Unknown chat.")
+		}
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("grant credits failed")
+		return s.reply(ctx, b, "Failed to grant credits.")
+	}
+	_ = s.audit(chatID, uid, "credits_granted", map[string]any{"credits": n})
+	balance, _ := s.store.CreditBalance(context.Background(), chatID)
+	return s.reply(ctx, b, fmt.Sprintf("Chat %d now has %d credits.", chatID, balance))
+}
+
+// buy sends an invoice for a credit pack credited to the current chat.
+// Anyone in the chat may pay.
+func (s *Service) buy(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	if !s.billing.PacksEnabled() {
+		return s.reply(ctx, b, packsDisabledText)
+	}
+	var replyTo int64
+	if ctx.EffectiveMessage != nil {
+		replyTo = ctx.EffectiveMessage.MessageId
+	}
+	if err := s.billing.SendInvoice(context.Background(), b, ctx.EffectiveChat.Id, replyTo); err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", ctx.EffectiveChat.Id).Msg("failed to send invoice")
+		return s.reply(ctx, b, "Failed to create the invoice.")
+	}
+	return nil
+}
+
+// preCheckout confirms a payment only if the invoice still matches the
+// current pack and the chat it credits is known.
+func (s *Service) preCheckout(b *gotgbot.Bot, ctx *ext.Context) error {
+	q := ctx.PreCheckoutQuery
+	if q == nil {
+		return nil
+	}
+	reject := func(reason string) error {
+		_, err := b.AnswerPreCheckoutQuery(q.Id, false, &gotgbot.AnswerPreCheckoutQueryOpts{ErrorMessage: reason})
+		return err
+	}
+	if !s.billing.PacksEnabled() {
+		return reject(packsDisabledText)
+	}
+	chatID, _, err := s.billing.CheckPayment(q.InvoicePayload, q.Currency, q.TotalAmount)
+	if err != nil {
+		return reject("This invoice is outdated. Use /buy for a new one.")
+	}
+	if _, err := s.store.CreditBalance(context.Background(), chatID); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("pre-checkout chat lookup failed")
+		}
+		return reject("This chat can no longer receive credits.")
+	}
+	_, err = b.AnswerPreCheckoutQuery(q.Id, true, nil)
+	return err
+}
+
+func matchSuccessfulPayment(msg *gotgbot.Message) bool {
+	return msg.SuccessfulPayment != nil
+}
+
+// successfulPayment credits the pack. Telegram may deliver the message more
+// than once; the charge id keeps the credit single.
+func (s *Service) successfulPayment(b *gotgbot.Bot, ctx *ext.Context) error {
+	pay := ctx.EffectiveMessage.SuccessfulPayment
+	chatID, credits, err := s.billing.CheckPayment(pay.InvoicePayload, pay.Currency, pay.TotalAmount)
+	if errors.Is(err, billing.ErrPackChanged) {
+		// Already paid: honour the invoice even if the pack changed since.
+		chatID, credits, err = billing.ParsePayload(pay.InvoicePayload)
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("charge_id", pay.TelegramPaymentChargeId).Msg("unexpected payment payload")
+		return s.reply(ctx, b, "Payment received, but it could not be matched to a chat. Contact the bot admin with charge id "+pay.TelegramPaymentChargeId+".")
+	}
+	var userID int64
+	if ctx.EffectiveUser != nil {
+		userID = ctx.EffectiveUser.Id
+	}
+	added, err := s.store.AddCredits(context.Background(), storage.CreditEntry{
+		ChatID:   chatID,
+		UserID:   userID,
+		Delta:    credits,
+		Reason:   storage.CreditReasonPurchase,
+		ChargeID: pay.TelegramPaymentChargeId,
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Str("charge_id", pay.TelegramPaymentChargeId).Msg("failed to add credits")
+		return s.reply(ctx, b, "Payment received, but crediting failed. Contact the bot admin with charge id "+pay.TelegramPaymentChargeId+".")
+	}
+	if !added {
+		return nil
+	}
+	_ = s.audit(chatID, userID, "credits_purchased", map[string]any{"credits": credits, "amount": pay.TotalAmount, "currency": pay.Currency})
+	balance, _ := s.store.CreditBalance(context.Background(), chatID)
+	return s.reply(ctx, b, fmt.Sprintf("Thanks! Added %d credits. Credits: %d.", credits, balance))
+}
+
+func (s *Service) costText() string {
+	if s.billing.TokensPerCredit > 0 {
+		return fmt.Sprintf("Each answered request costs 1 credit per %d tokens (at least 1).", s.billing.TokensPerCredit)
+	}
+	return "Each answered request costs 1 credit."
+}
+
+// packText describes the pack. Prices in other currencies are in minor
+// units, so only the invoice shows them.
+func (s *Service) packText() string {
+	if s.billing.Currency == billing.CurrencyStars {
+		return fmt.Sprintf("Packs: %d credits for %d ⭐. Use /buy to get one.", s.billing.PackCredits, s.billing.PackPrice)
+	}
+	return fmt.Sprintf("Packs: %d credits. Use /buy to get an invoice.", s.billing.PackCredits)
+}
@@ -1,11 +1,14 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
 )
 
 func (s *Service) onCallback(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -16,6 +19,31 @@ func (s *Service) onCallback(b *gotgbot.Bot, ctx *ext.Context) error {
 	data := strings.TrimSpace(ctx.CallbackQuery.Data)
 	s.answerCallback(b, ctx, "", false)
 
+	if key, ok := strings.CutPrefix(data, cbSettingToggle); ok {
+		return s.toggleSetting(b, ctx, key)
+	}
+	if rest, ok := strings.CutPrefix(data, queue.PickCallbackPrefix); ok {
+		return s.pickPreset(b, ctx, rest)
+	}
+	if token, ok := strings.CutPrefix(data, queue.RetryCallbackPrefix); ok {
+		return s.retryJob(b, ctx, token)
+	}
+	if rest, ok := strings.CutPrefix(data, queue.TranslateCallbackPrefix); ok {
+		return s.translateAgain(b, ctx, rest)
+	}
+	if rest, ok := strings.CutPrefix(data, queue.FeedbackCallbackPrefix); ok {
+		return s.vote(b, ctx, rest)
+	}
+	if jobID, ok := strings.CutPrefix(data, queue.ExpandCallbackPrefix); ok {
+		return s.showFullAnswer(b, ctx, jobID)
+	}
+	if name, ok := strings.CutPrefix(data, cbSetupDefault); ok {
+		return s.setupDefault(b, ctx, name)
+	}
+	if chatID, ok := strings.CutPrefix(data, cbWizardPick); ok {
+		return s.pickWizard(b, ctx, chatID)
+	}
+
 	switch data {
 	case cbMenu:
 		return s.editOrReplyCallback(ctx, b, s.mainMenuText(ctx), s.mainMenuKeyboard())
@@ -27,7 +55,11 @@ func (s *Service) onCallback(b *gotgbot.Bot, ctx *ext.Context) error {
 		return s.editOrReplyCallback(ctx, b, s.aiUsageText(), s.backToMenuKeyboard())
 
 	case cbSetup:
-		return s.editOrReplyCallback(ctx, b, s.setupText(), s.setupKeyboard())
+		text, markup := s.setupView(ctx)
+		return s.editOrReplyCallback(ctx, b, text, markup)
+
+	case cbSetupTemplates:
+		return s.setupTemplates(b, ctx)
 
 	case cbStatus:
 		return s.editOrReplyCallback(ctx, b, s.statusText(ctx), s.backToMenuKeyboard())
@@ -69,9 +101,19 @@ func (s *Service) onCallback(b *gotgbot.Bot, ctx *ext.Context) error {
 		if err := s.llmAdd(b, ctx); err != nil {
 			return err
 		}
+		if ctx.EffectiveChat != nil && ctx.EffectiveChat.Type == "private" {
+			s.answerCallback(b, ctx, "Wizard started.", false)
+			return nil
+		}
 		s.answerCallback(b, ctx, "Deep-link sent to chat.", false)
 		return nil
 
+	case cbOnboardShared:
+		return s.onboardShared(b, ctx)
+
+	case cbOnboardCustom:
+		return s.onboardCustom(b, ctx)
+
 	case cbActLlmList:
 		if _, _, ok := s.requireAdmin(b, ctx); !ok {
 			s.answerCallback(b, ctx, "Only chat admins can list providers.", true)
@@ -83,6 +125,15 @@ func (s *Service) onCallback(b *gotgbot.Bot, ctx *ext.Context) error {
 		s.answerCallback(b, ctx, "Provider summary sent.", false)
 		return nil
 
+	case cbForgetMeConfirm:
+		return s.confirmForgetMe(b, ctx)
+
+	case cbForgetChatConfirm:
+		return s.confirmForgetChat(b, ctx)
+
+	case cbForgetCancel:
+		return s.editOrReplyCallback(ctx, b, "Deletion canceled.", nil)
+
 	default:
 		s.answerCallback(b, ctx, fmt.Sprintf("Unknown action: %s", data), true)
 		return nil
@@ -102,15 +153,8 @@ func (s *Service) answerCallback(b *gotgbot.Bot, ctx *ext.Context, text string,
 
 func (s *Service) editOrReplyCallback(ctx *ext.Context, b *gotgbot.Bot, text string, markup *gotgbot.InlineKeyboardMarkup) error {
 	if ctx != nil && ctx.CallbackQuery != nil && ctx.CallbackQuery.Message != nil {
-		opts := &gotgbot.EditMessageTextOpts{}
-		if markup != nil {
-			opts.ReplyMarkup = *markup
-		}
-		_, _, err := ctx.CallbackQuery.Message.EditText(b, text, opts)
-		if err == nil {
-			return nil
-		}
-		if strings.Contains(strings.ToLower(err.Error()), "message is not modified") {
+		msg := ctx.CallbackQuery.Message
+		if err := s.sender.Edit(context.Background(), msg.GetChat().Id, msg.GetMessageId(), text, markup); err == nil {
 			return nil
 		}
 		// Fallback to sending a regular message if edit failed.
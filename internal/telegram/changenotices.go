@@ -0,0 +1,40 @@
+package telegram
+
+import (
+	"context"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+// notifyChange posts "<admin> <change>" in the group when the chat enabled
+// change notices, so admins see provider and preset changes made by others,
+// including those made in private chat with the bot.
+func (s *Service) notifyChange(b *gotgbot.Bot, ctx *ext.Context, chatID int64, change string) {
+	settings, err := s.store.GetChatSettings(context.Background(), chatID)
+	if err != nil || !settings.Bool(storage.SettingChangeNotices) {
+		return
+	}
+	text := changeActor(ctx) + " " + change
+	if _, err := s.sender.Send(context.Background(), tgsend.Message{ChatID: chatID, Text: text}); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("send change notice failed")
+	}
+}
+
+func changeActor(ctx *ext.Context) string {
+	if isAnonymousAdmin(ctx) {
+		return "An anonymous admin"
+	}
+	u := ctx.EffectiveUser
+	switch {
+	case u == nil:
+		return "An admin"
+	case u.Username != "":
+		return "@" + u.Username
+	default:
+		return u.FirstName
+	}
+}
@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+const (
+	maxTemplateRunes = 4000
+	tplAddUsage      = "Usage: /tpl_add <name> <prompt with {{variables}}>, e.g.\n/tpl_add review \"Review this code focusing on {{focus}}: {{input}}\""
+	tplUsage         = "Usage: /tpl <name> [var=value ...] <input>, e.g. /tpl review focus=security <code>. Quote values with spaces: focus=\"error handling\"."
+)
+
+var (
+	templateNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	templateArgRe  = regexp.MustCompile(`^([A-Za-z0-9_]+)=`)
+)
+
+func (s *Service) tplAdd(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, body := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	body = strings.TrimSpace(body)
+	if len(body) >= 2 && body[0] == '"' && body[len(body)-1] == '"' {
+		body = strings.TrimSpace(body[1 : len(body)-1])
+	}
+	if name == "" || body == "" {
+		return s.reply(ctx, b, tplAddUsage)
+	}
+	if !templateNameRe.MatchString(name) {
+		return s.reply(ctx, b, "Template names use letters, digits, _ and -, up to 32 characters.")
+	}
+	if utf8.RuneCountInString(body) > maxTemplateRunes {
+		return s.reply(ctx, b, fmt.Sprintf("Templates are limited to %d characters.", maxTemplateRunes))
+	}
+	if err := s.store.UpsertChatTemplate(context.Background(), storage.ChatTemplate{ChatID: chatID, Name: name, Body: body, CreatedBy: uid}); err != nil {
+		s.logger.Error().Err(err).Msg("save chat template failed")
+		return s.reply(ctx, b, "Failed to save template.")
+	}
+	_ = s.audit(chatID, uid, "template_add", map[string]any{"name": name})
+	s.notifyChange(b, ctx, chatID, "saved template "+name)
+	return s.reply(ctx, b, fmt.Sprintf("Template %s saved. Use: %s", name, templateUsageLine(name, body)))
+}
+
+func (s *Service) tplDel(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if name == "" {
+		return s.reply(ctx, b, "Usage: /tpl_del <name>")
+	}
+	if err := s.store.DeleteChatTemplate(context.Background(), chatID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Template not found.")
+		}
+		s.logger.Error().Err(err).Msg("delete chat template failed")
+		return s.reply(ctx, b, "Failed to delete template.")
+	}
+	_ = s.audit(chatID, uid, "template_del", map[string]any{"name": name})
+	s.notifyChange(b, ctx, chatID, "deleted template "+name)
+	return s.reply(ctx, b, "Template deleted.")
+}
+
+func (s *Service) tplList(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	return s.reply(ctx, b, s.templateListText(ctx.EffectiveChat.Id))
+}
+
+func (s *Service) templateListText(chatID int64) string {
+	templates, err := s.store.ListChatTemplates(context.Background(), chatID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list chat templates failed")
+		return "Failed to list templates."
+	}
+	if len(templates) == 0 {
+		return "No templates in this chat. Admins add them with /tpl_add."
+	}
+	lines := []string{"Templates:"}
+	for _, t := range templates {
+		lines = append(lines, "- "+templateUsageLine(t.Name, t.Body))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// templateUsageLine shows how to call a template, e.g.
+// "/tpl review focus=... <input>".
+func templateUsageLine(name, body string) string {
+	parts := []string{"/tpl", name}
+	for _, v := range prompt.TemplateVars(body) {
+		if v != prompt.TemplateInput {
+			parts = append(parts, v+"=...")
+		}
+	}
+	return strings.Join(append(parts, "<input>"), " ")
+}
+
+// tpl asks the chat's default preset with a template. Variables are checked
+// here; the worker renders the template.
+func (s *Service) tpl(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	if msg == nil || ctx.EffectiveChat == nil {
+		return nil
+	}
+	name, rest := splitFirstWord(commandRemainder(msg.GetText()))
+	if name == "" {
+		return s.reply(ctx, b, s.templateListText(ctx.EffectiveChat.Id)+"\n\n"+tplUsage)
+	}
+	vars, input, err := parseTemplateArgs(rest)
+	if err != nil {
+		return s.reply(ctx, b, err.Error()+"\n"+tplUsage)
+	}
+	tplRow, err := s.store.GetChatTemplate(context.Background(), ctx.EffectiveChat.Id, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Template not found. See /tpl_list.")
+		}
+		s.logger.Error().Err(err).Msg("get chat template failed")
+		return s.reply(ctx, b, "Failed to load template.")
+	}
+	if v, ok := vars[prompt.TemplateInput]; ok {
+		input = strings.TrimSpace(v + " " + input)
+		delete(vars, prompt.TemplateInput)
+	}
+	author, quoted := quotedContext(msg)
+	if input == "" && quoted != "" {
+		// A reply without text uses the replied-to message as the input.
+		input, author, quoted = quoted, "", ""
+	}
+	var missing []string
+	for _, v := range prompt.TemplateVars(tplRow.Body) {
+		if _, ok := vars[v]; !ok && v != prompt.TemplateInput {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return s.reply(ctx, b, "Missing "+strings.Join(missing, ", ")+". Use: "+templateUsageLine(tplRow.Name, tplRow.Body))
+	}
+
+	if !s.allowRate(ctx.EffectiveChat.Id, userID(ctx), b, ctx) {
+		return nil
+	}
+	s.ensureChat(context.Background(), msg)
+	return s.enqueueAsk(b, ctx, queue.AskJob{
+		ChatID:       ctx.EffectiveChat.Id,
+		ChatType:     ctx.EffectiveChat.Type,
+		UserID:       userID(ctx),
+		MessageID:    msg.MessageId,
+		Prompt:       input,
+		QuotedText:   quoted,
+		QuotedAuthor: author,
+		Template:     tplRow.Name,
+		TemplateVars: vars,
+	})
+}
+
+// parseTemplateArgs reads leading var=value pairs, with "double quotes"
+// around values containing spaces. The remaining text is the input.
+func parseTemplateArgs(text string) (map[string]string, string, error) {
+	vars := map[string]string{}
+	rest := strings.TrimSpace(text)
+	for {
+		m := templateArgRe.FindStringSubmatch(rest)
+		if m == nil {
+			return vars, rest, nil
+		}
+		value := rest[len(m[0]):]
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return nil, "", fmt.Errorf("unclosed quote in %s", m[1])
+			}
+			vars[m[1]], rest = value[1:end+1], value[end+2:]
+		} else {
+			end := strings.IndexFunc(value, unicode.IsSpace)
+			if end < 0 {
+				end = len(value)
+			}
+			vars[m[1]], rest = value[:end], value[end:]
+		}
+		rest = strings.TrimSpace(rest)
+	}
+}
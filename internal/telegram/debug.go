@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"context"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// debugFlag, as the first argument of /ask or /ai, makes the worker follow
+// the answer with the resolved request metadata.
+const debugFlag = "--debug"
+
+func cutDebugFlag(args string) (string, bool) {
+	first, rest := splitFirstWord(args)
+	if first != debugFlag {
+		return args, false
+	}
+	return rest, true
+}
+
+// allowDebug reports whether the sender may see debug output, which names the
+// provider endpoint and preset parameters. In private chats everyone may.
+func (s *Service) allowDebug(b *gotgbot.Bot, ctx *ext.Context) bool {
+	if ctx.EffectiveChat.Type == "private" {
+		return true
+	}
+	if s.allowAnonymousAdmins && isAnonymousAdmin(ctx) {
+		return true
+	}
+	if ctx.EffectiveUser == nil {
+		return false
+	}
+	admin, err := s.isAdmin(context.Background(), b, ctx.EffectiveChat.Id, ctx.EffectiveUser.Id)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", ctx.EffectiveChat.Id).Msg("admin check failed")
+		_ = s.reply(ctx, b, "Failed to verify admin rights.")
+		return false
+	}
+	if !admin {
+		_ = s.reply(ctx, b, "Only chat admins can use "+debugFlag+".")
+		return false
+	}
+	return true
+}
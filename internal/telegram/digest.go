@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+// RunUsageDigest sends each chat with the digest setting enabled a summary
+// of the last interval of usage, once per interval. It checks hourly; a
+// per-chat Redis key holds the next digest back for the interval, so restarts
+// and several replicas still send one digest per period.
+func (s *Service) RunUsageDigest(ctx context.Context, b *gotgbot.Bot, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.sendUsageDigests(ctx, b, interval); err != nil {
+				s.logger.Error().Err(err).Msg("usage digest failed")
+			}
+		}
+	}
+}
+
+func (s *Service) sendUsageDigests(ctx context.Context, b *gotgbot.Bot, interval time.Duration) error {
+	chats, err := s.store.ChatsWithSetting(ctx, storage.SettingDigest)
+	if err != nil {
+		return err
+	}
+	for chatID, mode := range chats {
+		if mode != storage.DigestGroup && mode != storage.DigestAdmins {
+			continue
+		}
+		ok, err := s.redis.SetNX(ctx, fmt.Sprintf("hyprbot:digest:%d", chatID), "1", interval).Result()
+		if err != nil {
+			return fmt.Errorf("acquire digest lock: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		sum, err := s.store.UsageSummary(ctx, chatID, interval)
+		if err != nil {
+			s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("failed to build usage digest")
+			continue
+		}
+		if sum.Requests == 0 {
+			continue
+		}
+		if mode == storage.DigestGroup {
+			if _, err := s.sender.Send(ctx, tgsend.Message{ChatID: chatID, Text: usageDigestText("Usage digest", sum, interval)}); err != nil {
+				s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to post usage digest")
+			}
+			continue
+		}
+		s.sendAdminDigest(ctx, b, chatID, sum, interval)
+	}
+	return nil
+}
+
+// sendAdminDigest DMs the digest to every human admin of the chat. Admins
+// who never started the bot cannot be messaged and are skipped.
+func (s *Service) sendAdminDigest(ctx context.Context, b *gotgbot.Bot, chatID int64, sum storage.UsageSummary, interval time.Duration) {
+	admins, err := b.GetChatAdministratorsWithContext(ctx, chatID, nil)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to list admins for usage digest")
+		return
+	}
+	title := strconv.FormatInt(chatID, 10)
+	if chat, err := b.GetChatWithContext(ctx, chatID, nil); err == nil && chat.Title != "" {
+		title = chat.Title
+	}
+	text := usageDigestText("Usage digest for "+title, sum, interval)
+	for _, admin := range admins {
+		user := admin.GetUser()
+		if user.IsBot {
+			continue
+		}
+		if _, err := s.sender.Send(ctx, tgsend.Message{ChatID: user.Id, Text: text}); err != nil {
+			s.logger.Debug().Err(err).Int64("chat_id", chatID).Int64("user_id", user.Id).Msg("failed to send usage digest to admin")
+		}
+	}
+}
+
+func usageDigestText(heading string, sum storage.UsageSummary, interval time.Duration) string {
+	lines := []string{
+		fmt.Sprintf("%s (last %s)", heading, periodLabel(interval)),
+		fmt.Sprintf("Requests: %d, failed: %d (%.1f%%)", sum.Requests, sum.Failed, float64(sum.Failed)*100/float64(sum.Requests)),
+		fmt.Sprintf("Tokens: %d in, %d out", sum.InputTokens, sum.OutputTokens),
+	}
+	if len(sum.TopUsers) > 0 {
+		lines = append(lines, "Top users: "+usageCounts(sum.TopUsers))
+	}
+	if len(sum.TopPresets) > 0 {
+		lines = append(lines, "Top presets: "+usageCounts(sum.TopPresets))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func usageCounts(counts []storage.UsageCount) string {
+	parts := make([]string, 0, len(counts))
+	for _, c := range counts {
+		parts = append(parts, fmt.Sprintf("%s (%d)", c.Label, c.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func periodLabel(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		days := int(d / (24 * time.Hour))
+		if days == 1 {
+			return "day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
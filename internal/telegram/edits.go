@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+)
+
+func matchEditedAsk(msg *gotgbot.Message) bool {
+	if msg == nil || msg.EditDate == 0 || msg.From == nil || msg.From.IsBot {
+		return false
+	}
+	cmd, _ := editedAskCommand(msg.Text)
+	return cmd != ""
+}
+
+// editedAskCommand returns "ask" or "ai" and the @bot suffix, if any, when
+// text is one of the commands that enqueue a job.
+func editedAskCommand(text string) (cmd, target string) {
+	first, _ := splitFirstWord(text)
+	if !strings.HasPrefix(first, "/") {
+		return "", ""
+	}
+	cmd, target, _ = strings.Cut(strings.ToLower(first[1:]), "@")
+	if cmd != "ask" && cmd != "ai" {
+		return "", ""
+	}
+	return cmd, target
+}
+
+// editedAsk replaces a still-queued /ask or /ai job with the edited prompt.
+// Edits after the window or once a worker picked the job up are ignored.
+func (s *Service) editedAsk(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EditedMessage
+	if msg == nil || s.askEditWindow <= 0 {
+		return nil
+	}
+	cmd, target := editedAskCommand(msg.Text)
+	if target != "" && !strings.EqualFold(target, b.Username) {
+		return nil
+	}
+	// Debug output stays as granted when the job was first submitted.
+	prompt, _ := cutDebugFlag(strings.TrimSpace(commandRemainder(msg.Text)))
+	preset := ""
+	if cmd == "ai" {
+		preset, prompt = splitFirstWord(prompt)
+	}
+	if prompt == "" {
+		return nil
+	}
+
+	old, ok, err := s.queue.Cancel(context.Background(), msg.Chat.Id, msg.MessageId)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", msg.Chat.Id).Msg("failed to cancel edited ask job")
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	author, quoted := quotedContext(msg)
+	s.logger.Debug().Str("job_id", old.JobID).Int64("chat_id", msg.Chat.Id).Msg("replacing ask job after prompt edit")
+	return s.submitAsk(b, ctx, queue.AskJob{
+		ChatID:          old.ChatID,
+		ChatType:        old.ChatType,
+		UserID:          old.UserID,
+		MessageID:       old.MessageID,
+		ThreadID:        old.ThreadID,
+		StatusMessageID: old.StatusMessageID,
+		Prompt:          prompt,
+		PresetName:      preset,
+		QuotedText:      quoted,
+		QuotedAuthor:    author,
+		AckReaction:     old.AckReaction,
+		Debug:           old.Debug,
+	})
+}
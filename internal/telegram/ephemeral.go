@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"context"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/tgsend"
+)
+
+const (
+	ephemeralCleanupInterval = 5 * time.Second
+	ephemeralBatch           = 100
+)
+
+// replyEphemeral replies like reply and schedules the message for deletion
+// after ephemeralTTL, for notices that are useless once read.
+func (s *Service) replyEphemeral(ctx *ext.Context, b *gotgbot.Bot, text string) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	msg, err := s.sender.Send(context.Background(), tgsend.Message{ChatID: ctx.EffectiveChat.Id, ThreadID: threadID(ctx), Text: text})
+	if err != nil || msg == nil {
+		return err
+	}
+	s.expire(msg.Chat.Id, msg.MessageId)
+	return nil
+}
+
+// expire schedules a bot message for deletion after ephemeralTTL.
+func (s *Service) expire(chatID, messageID int64) {
+	if s.ephemeral == nil || s.ephemeralTTL <= 0 || messageID <= 0 {
+		return
+	}
+	m := queue.EphemeralMessage{ChatID: chatID, MessageID: messageID}
+	if err := s.ephemeral.Schedule(context.Background(), m, s.now().Add(s.ephemeralTTL)); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to schedule message deletion")
+	}
+}
+
+// RunEphemeralCleanup deletes scheduled messages once they are due. Due
+// entries are popped atomically, so every replica can run it.
+func (s *Service) RunEphemeralCleanup(ctx context.Context, b *gotgbot.Bot) error {
+	if s.ephemeral == nil || s.ephemeralTTL <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(ephemeralCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.deleteDueMessages(ctx, b)
+		}
+	}
+}
+
+func (s *Service) deleteDueMessages(ctx context.Context, b *gotgbot.Bot) {
+	for {
+		due, err := s.ephemeral.Due(ctx, s.now(), ephemeralBatch)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("failed to load due ephemeral messages")
+			return
+		}
+		for _, m := range due {
+			_, err := b.DeleteMessageWithContext(ctx, m.ChatID, m.MessageID, nil)
+			if err != nil && !isStaleMessageErr(err) {
+				s.logger.Debug().Err(err).Int64("chat_id", m.ChatID).Msg("failed to delete ephemeral message")
+			}
+		}
+		if len(due) < ephemeralBatch {
+			return
+		}
+	}
+}
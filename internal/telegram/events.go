@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"hyprbot/internal/queue"
+)
+
+const (
+	// eventWorkers apply job events off the subscribe loop; events of one
+	// job always go to the same worker, so they stay in order.
+	eventWorkers = 8
+	// eventBacklog is how many events wait per worker before new ones are
+	// dropped; a later event of the job fixes the status message anyway.
+	eventBacklog = 64
+	// maxResubscribeWait bounds the wait between subscribe attempts.
+	maxResubscribeWait = time.Minute
+)
+
+// RunEventListener updates status messages from job events until ctx ends.
+// A lost subscription is retried with a growing wait instead of stopping
+// the bot; status messages just stay as they are meanwhile.
+func (s *Service) RunEventListener(ctx context.Context, b *gotgbot.Bot) error {
+	if s.events == nil {
+		return nil
+	}
+	var wg sync.WaitGroup
+	queues := make([]chan queue.JobEvent, eventWorkers)
+	for i := range queues {
+		queues[i] = make(chan queue.JobEvent, eventBacklog)
+		wg.Add(1)
+		go func(events <-chan queue.JobEvent) {
+			defer wg.Done()
+			for ev := range events {
+				s.applyJobEvent(ctx, b, ev)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
+
+	wait := time.Second
+	for {
+		started := time.Now()
+		err := s.events.Subscribe(ctx, func(ev queue.JobEvent) {
+			h := fnv.New32a()
+			h.Write([]byte(ev.JobID))
+			select {
+			case queues[h.Sum32()%eventWorkers] <- ev:
+			default:
+				s.logger.Debug().Str("job_id", ev.JobID).Str("state", ev.State).Msg("job event backlog full, event dropped")
+			}
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(started) > maxResubscribeWait {
+			wait = time.Second
+		}
+		s.logger.Warn().Err(err).Dur("retry_in", wait).Msg("job event subscription lost, resubscribing")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, maxResubscribeWait)
+	}
+}
+
+func (s *Service) applyJobEvent(ctx context.Context, b *gotgbot.Bot, ev queue.JobEvent) {
+	if ev.ChatID == 0 || ev.StatusMessageID <= 0 {
+		return
+	}
+	// With the claim store down every node applies the event, as before
+	// claims existed.
+	if claimed, err := s.events.Claim(ctx, ev); err != nil {
+		s.logger.Debug().Err(err).Str("job_id", ev.JobID).Msg("failed to claim job event")
+	} else if !claimed {
+		return
+	}
+	switch ev.State {
+	case queue.JobStateDone, queue.JobStateFailed:
+		// The answer (or error reply) is already in the chat, so the progress
+		// message has nothing left to say.
+		_, err := b.DeleteMessageWithContext(ctx, ev.ChatID, ev.StatusMessageID, nil)
+		if err != nil && !isStaleMessageErr(err) {
+			s.logger.Debug().Err(err).Str("job_id", ev.JobID).Msg("failed to delete status message")
+		}
+	default:
+		err := s.sender.Edit(ctx, ev.ChatID, ev.StatusMessageID, jobStateText(ev.State, ev.Attempt), nil)
+		if err != nil && !isStaleMessageErr(err) {
+			s.logger.Debug().Err(err).Str("job_id", ev.JobID).Str("state", ev.State).Msg("failed to update status message")
+		}
+	}
+}
+
+func jobStateText(state string, attempt int) string {
+	switch state {
+	case queue.JobStateQueued:
+		if attempt > 0 {
+			return fmt.Sprintf("Retrying (attempt %d). Waiting in queue.", attempt+1)
+		}
+		return "Accepted. Processing in queue."
+	case queue.JobStateRunning:
+		return "Processing..."
+	case queue.JobStateAnswering:
+		return "Sending answer..."
+	default:
+		return "Processing..."
+	}
+}
+
+// A message the user or an earlier edit already changed or removed is
+// expected and not worth logging.
+func isStaleMessageErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "message is not modified") ||
+		strings.Contains(msg, "message to edit not found") ||
+		strings.Contains(msg, "message to delete not found")
+}
@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+const maxExportMessages = 5000
+
+type exportEntry struct {
+	At     time.Time `json:"at"`
+	Preset string    `json:"preset,omitempty"`
+	Prompt string    `json:"prompt"`
+	Answer string    `json:"answer"`
+}
+
+func (s *Service) export(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil || ctx.EffectiveMessage == nil {
+		return nil
+	}
+	format := strings.ToLower(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())))
+	if format == "" {
+		format = "md"
+	}
+	if format != "md" && format != "json" {
+		return s.reply(ctx, b, "Usage: /export [md|json]")
+	}
+
+	chatID := ctx.EffectiveChat.Id
+	if ctx.EffectiveChat.Type != "private" {
+		allowed, err := s.store.GetExportsAllowed(context.Background(), chatID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("load export policy failed")
+			return s.reply(ctx, b, "Failed to load export policy.")
+		}
+		if !allowed {
+			return s.reply(ctx, b, "Chat admins have disabled conversation exports here.")
+		}
+	}
+
+	// One more than fits tells whether older messages were left out.
+	items, err := s.store.ListConversation(context.Background(), chatID, ctx.EffectiveUser.Id, maxExportMessages+1)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list conversation failed")
+		return s.reply(ctx, b, "Failed to load conversation history.")
+	}
+	if len(items) == 0 {
+		return s.reply(ctx, b, "No stored conversation history for you in this chat.")
+	}
+	caption := fmt.Sprintf("Conversation export: %d messages.", len(items))
+	if len(items) > maxExportMessages {
+		items = items[len(items)-maxExportMessages:]
+		caption = fmt.Sprintf("Conversation export: your latest %d messages; older ones are not included.", maxExportMessages)
+	}
+
+	var body []byte
+	if format == "json" {
+		body, err = renderExportJSON(items)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to build export.")
+		}
+	} else {
+		body = renderExportMarkdown(items)
+	}
+
+	// The file holds the caller's prompts and answers, so it only ever goes
+	// to their private chat, never to the group.
+	private := ctx.EffectiveChat.Type == "private"
+	opts := &gotgbot.SendDocumentOpts{Caption: caption}
+	if private {
+		opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: ctx.EffectiveMessage.MessageId, AllowSendingWithoutReply: true}
+	}
+	name := fmt.Sprintf("hyprbot-export-%d-%s.%s", chatID, s.now().Format("20060102"), format)
+	_, err = b.SendDocument(ctx.EffectiveUser.Id, gotgbot.InputFileByReader(name, bytes.NewReader(body)), opts)
+	if err != nil {
+		if !private && tgsend.IsUnreachable(err) {
+			return s.exportStartPrompt(b, ctx)
+		}
+		s.logger.Error().Err(err).Msg("send export failed")
+		return s.reply(ctx, b, "Failed to send export file.")
+	}
+	if !private {
+		return s.reply(ctx, b, "Sent your export in private chat.")
+	}
+	return nil
+}
+
+// exportStartPrompt asks a user the bot cannot message yet to start it in
+// private chat; the export is not kept, they run /export again.
+func (s *Service) exportStartPrompt(b *gotgbot.Bot, ctx *ext.Context) error {
+	text := "I can't message you privately yet. Start a private chat with the bot, then run /export here again."
+	link := s.deepLink(b, "")
+	if link == "" {
+		return s.reply(ctx, b, text)
+	}
+	return s.replyWithMarkup(ctx, b, text, &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{Text: "Open private chat", Url: link}}},
+	})
+}
+
+func (s *Service) exportPolicy(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	arg := strings.ToLower(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())))
+	if arg == "" {
+		allowed, err := s.store.GetExportsAllowed(context.Background(), chatID)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to load export policy.")
+		}
+		state := "disabled"
+		if allowed {
+			state = "enabled"
+		}
+		return s.reply(ctx, b, "Conversation exports are "+state+". Usage: /export_policy <on|off>")
+	}
+	if arg != "on" && arg != "off" {
+		return s.reply(ctx, b, "Usage: /export_policy <on|off>")
+	}
+	if err := s.store.SetExportsAllowed(context.Background(), chatID, arg == "on"); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		return s.reply(ctx, b, "Failed to save export policy.")
+	}
+	_ = s.audit(chatID, uid, "export_policy", map[string]any{"allowed": arg == "on"})
+	return s.reply(ctx, b, "Export policy updated.")
+}
+
+func renderExportJSON(items []storage.ConversationMessage) ([]byte, error) {
+	out := make([]exportEntry, 0, len(items))
+	for _, m := range items {
+		out = append(out, exportEntry{At: m.CreatedAt.UTC(), Preset: m.PresetName, Prompt: m.Prompt, Answer: m.Answer})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+func renderExportMarkdown(items []storage.ConversationMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Conversation export\n")
+	for _, m := range items {
+		fmt.Fprintf(&buf, "\n## %s", m.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"))
+		if m.PresetName != "" {
+			fmt.Fprintf(&buf, " (%s)", m.PresetName)
+		}
+		fmt.Fprintf(&buf, "\n\n**You:**\n\n%s\n\n**Bot:**\n\n%s\n", m.Prompt, m.Answer)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"hyprbot/internal/storage"
+)
+
+func TestListConversationKeepsLatest(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := store.SaveConversationMessage(ctx, storage.ConversationMessage{ChatID: -100, UserID: 7, Prompt: fmt.Sprint(i), Answer: "a"}); err != nil {
+			t.Fatalf("save message: %v", err)
+		}
+	}
+	items, err := store.ListConversation(ctx, -100, 7, 2)
+	if err != nil {
+		t.Fatalf("list conversation: %v", err)
+	}
+	if len(items) != 2 || items[0].Prompt != "2" || items[1].Prompt != "3" {
+		t.Fatalf("ListConversation(limit 2) = %+v, want messages 2 and 3", items)
+	}
+}
@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+const fallbackUsage = "Usage: /owner_fallback <chat_id> <preset> | off"
+
+// ownerFallback sets the preset, kept in one of the owner's chats, that
+// answers /ask in chats without presets of their own.
+func (s *Service) ownerFallback(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) || s.fallback == nil {
+		return nil
+	}
+	bg := context.Background()
+	uid := ctx.EffectiveUser.Id
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.Text))
+	switch {
+	case len(args) == 0:
+		fb, ok, err := s.fallback.Get(bg)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to read the fallback preset.")
+		}
+		if !ok {
+			return s.reply(ctx, b, "No fallback preset is set.\n"+fallbackUsage)
+		}
+		return s.reply(ctx, b, fmt.Sprintf("Fallback preset: %s from chat %d.\n%s", fb.Preset, fb.ChatID, fallbackUsage))
+	case len(args) == 1 && strings.EqualFold(args[0], "off"):
+		cleared, err := s.fallback.Clear(bg)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("clear fallback preset failed")
+			return s.reply(ctx, b, "Failed to clear the fallback preset.")
+		}
+		if !cleared {
+			return s.reply(ctx, b, "No fallback preset is set.")
+		}
+		_ = s.audit(0, uid, "fallback_preset_off", nil)
+		return s.reply(ctx, b, "Fallback preset removed. Chats without presets get setup instructions again.")
+	case len(args) == 2:
+		chatID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return s.reply(ctx, b, fallbackUsage)
+		}
+		if _, err := s.store.GetPresetWithProviderByName(bg, chatID, args[1]); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return s.reply(ctx, b, "That chat has no such preset.")
+			}
+			return s.reply(ctx, b, "Failed to load the preset.")
+		}
+		if err := s.fallback.Set(bg, queue.FallbackPreset{ChatID: chatID, Preset: args[1], By: uid, Since: s.now()}); err != nil {
+			s.logger.Error().Err(err).Msg("set fallback preset failed")
+			return s.reply(ctx, b, "Failed to set the fallback preset.")
+		}
+		_ = s.audit(0, uid, "fallback_preset_set", map[string]any{"chat_id": chatID, "preset": args[1]})
+		return s.reply(ctx, b, fmt.Sprintf("Fallback preset set to %s from chat %d. Chats without presets now get answers from it, within the fallback rate limit.", args[1], chatID))
+	}
+	return s.reply(ctx, b, fallbackUsage)
+}
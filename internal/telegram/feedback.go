@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+// vote records a 👍/👎 on an answer. data is "<job id>:up" or "<job id>:down";
+// every chat member gets one vote per answer and may change it.
+func (s *Service) vote(b *gotgbot.Bot, ctx *ext.Context, data string) error {
+	if s.answers == nil || ctx.EffectiveUser == nil {
+		return nil
+	}
+	jobID, dir, _ := strings.Cut(data, ":")
+	value := 1
+	switch dir {
+	case "up":
+	case "down":
+		value = -1
+	default:
+		s.answerCallback(b, ctx, "Unknown vote.", true)
+		return nil
+	}
+
+	meta, found, err := s.answers.Get(context.Background(), jobID)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to load answer meta")
+		s.answerCallback(b, ctx, "Failed to record the vote.", true)
+		return nil
+	}
+	if !found {
+		s.answerCallback(b, ctx, "Voting on this answer has closed.", false)
+		return nil
+	}
+	if err := s.store.SaveFeedback(context.Background(), storage.Feedback{
+		JobID:      jobID,
+		UserID:     ctx.EffectiveUser.Id,
+		ChatID:     meta.ChatID,
+		PresetName: meta.PresetName,
+		Model:      meta.Model,
+		LatencyMs:  meta.LatencyMs,
+		Vote:       value,
+		ABArm:      meta.ABArm,
+	}); err != nil {
+		s.logger.Error().Err(err).Str("job_id", jobID).Msg("save feedback failed")
+		s.answerCallback(b, ctx, "Failed to record the vote.", true)
+		return nil
+	}
+	s.answerCallback(b, ctx, "Thanks for the feedback.", false)
+	return nil
+}
+
+func (s *Service) presetStats(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	stats, err := s.store.PresetFeedback(context.Background(), chatID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("preset feedback failed")
+		return s.reply(ctx, b, "Failed to load feedback.")
+	}
+	if len(stats) == 0 {
+		return s.reply(ctx, b, "No feedback yet. Members can rate answers with the 👍/👎 buttons.")
+	}
+	lines := []string{"Answer feedback by preset:"}
+	for _, st := range stats {
+		total := st.Up + st.Down
+		lines = append(lines, fmt.Sprintf("- %s (%s): 👍 %d / 👎 %d, %d%% positive, avg %.1fs",
+			st.PresetName, st.Model, st.Up, st.Down, st.Up*100/total, st.AvgLatencyMs/1000))
+	}
+	return s.reply(ctx, b, strings.Join(lines, "\n"))
+}
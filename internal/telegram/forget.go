@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const (
+	cbForgetMeConfirm   = cbPrefix + "forget_me_ok"
+	cbForgetChatConfirm = cbPrefix + "forget_chat_ok"
+	cbForgetCancel      = cbPrefix + "forget_cancel"
+)
+
+func (s *Service) forgetMe(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveUser == nil {
+		return nil
+	}
+	return s.replyWithMarkup(ctx, b, forgetPrompt("This permanently deletes everything the bot stores about you:",
+		storage.ForgetUserScope()), forgetKeyboard(cbForgetMeConfirm))
+}
+
+func (s *Service) forgetChat(b *gotgbot.Bot, ctx *ext.Context) error {
+	if _, _, ok := s.requireAdmin(b, ctx); !ok {
+		return nil
+	}
+	return s.replyWithMarkup(ctx, b, forgetPrompt("This permanently deletes everything the bot stores about this chat:",
+		storage.ForgetChatScope()), forgetKeyboard(cbForgetChatConfirm))
+}
+
+func (s *Service) confirmForgetMe(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveUser == nil {
+		return nil
+	}
+	uid := ctx.EffectiveUser.Id
+	n, err := s.store.ForgetUser(context.Background(), uid)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("forget user failed")
+		s.answerCallback(b, ctx, "Deletion failed. Please retry later.", true)
+		return nil
+	}
+	_ = s.wizard.ClearAll(context.Background(), uid)
+	s.deleteRedisKeys(fmt.Sprintf("hyprbot:admin:*:%d", uid))
+	s.deleteRedisKeys(fmt.Sprintf("hyprbot:ratelimit:*:%d:*", uid))
+	// The record intentionally carries no user id: it proves the erasure
+	// happened without re-introducing the subject.
+	_ = s.audit(0, 0, "forget_user", map[string]any{"deleted_rows": n})
+	return s.editOrReplyCallback(ctx, b, "Your data has been deleted.", nil)
+}
+
+func (s *Service) confirmForgetChat(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	n, err := s.store.ForgetChat(context.Background(), chatID)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("forget chat failed")
+		s.answerCallback(b, ctx, "Deletion failed. Please retry later.", true)
+		return nil
+	}
+	s.deleteRedisKeys(fmt.Sprintf("hyprbot:admin:%d:*", chatID))
+	s.deleteRedisKeys(fmt.Sprintf("hyprbot:ratelimit:%d:*", chatID))
+	_ = s.audit(chatID, uid, "forget_chat", map[string]any{"deleted_rows": n})
+	return s.editOrReplyCallback(ctx, b, "All data for this chat has been deleted.", nil)
+}
+
+func (s *Service) deleteRedisKeys(pattern string) {
+	ctx := context.Background()
+	iter := s.redis.Scan(ctx, 0, pattern, 200).Iterator()
+	keys := make([]string, 0)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		s.logger.Warn().Err(err).Str("pattern", pattern).Msg("failed to scan redis keys")
+		return
+	}
+	if len(keys) > 0 {
+		_ = s.redis.Del(ctx, keys...).Err()
+	}
+}
+
+// forgetPrompt lists scope, which comes from the tables the deletion
+// actually touches, so the prompt cannot drift from what gets deleted.
+func forgetPrompt(header string, scope []string) string {
+	lines := []string{header}
+	for _, what := range scope {
+		lines = append(lines, "- "+what)
+	}
+	lines = append(lines, "", "This cannot be undone.")
+	return strings.Join(lines, "\n")
+}
+
+func forgetKeyboard(confirm string) *gotgbot.InlineKeyboardMarkup {
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+		{
+			{Text: "Delete permanently", CallbackData: confirm},
+			{Text: "Cancel", CallbackData: cbForgetCancel},
+		},
+	}}
+}
@@ -13,12 +13,23 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/redis/go-redis/v9"
 
+	"hyprbot/internal/eventstream"
+	"hyprbot/internal/httpclient"
+	"hyprbot/internal/notify"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/anthropic_messages"
+	"hyprbot/internal/providers/custom_http"
 	"hyprbot/internal/queue"
 	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
 )
 
 var providerNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
 
+const signingWizardPrompt = `Send request signing JSON or '-'. HMAC: {"type":"hmac-sha256","secret":"...","header":"X-Signature","prefix":"sha256=","timestamp_header":""}. SigV4: {"type":"sigv4","region":"eu-west-1","service":"execute-api","access_key":"...","secret_key":"..."}`
+
+const tlsWizardPrompt = `Send TLS JSON for private CA / mTLS (keys: ca_pem, client_cert_pem, client_key_pem, server_name) or '-'`
+
 func (s *Service) help(b *gotgbot.Bot, ctx *ext.Context) error {
 	return s.sendMainMenu(ctx, b)
 }
@@ -35,6 +46,14 @@ func (s *Service) start(b *gotgbot.Bot, ctx *ext.Context) error {
 		}
 		return s.beginLLMAddWizard(ctx, b, chatID)
 	}
+	if ctx.EffectiveChat.Type == "private" && len(args) > 1 && strings.HasPrefix(args[1], queue.PrivateAnswerStartPrefix) {
+		return s.sendPrivateAnswer(ctx, b, strings.TrimPrefix(args[1], queue.PrivateAnswerStartPrefix))
+	}
+	if len(args) > 1 {
+		if code, ok := referralCode(args[1]); ok {
+			s.recordReferral(ctx, code)
+		}
+	}
 	return s.sendMainMenu(ctx, b)
 }
 
@@ -42,20 +61,40 @@ func (s *Service) cancelWizard(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil || ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	if err := s.wizard.Clear(context.Background(), ctx.EffectiveUser.Id); err != nil {
+	// With several sessions and none active there is nothing to pick from
+	// here, so all of them are canceled.
+	state, err := s.wizard.Get(context.Background(), ctx.EffectiveUser.Id)
+	if err == nil && state != nil {
+		err = s.wizard.Clear(context.Background(), ctx.EffectiveUser.Id, state.TargetChatID)
+	} else if err == nil {
+		err = s.wizard.ClearAll(context.Background(), ctx.EffectiveUser.Id)
+	}
+	if err != nil {
 		return s.reply(ctx, b, "Failed to cancel wizard right now.")
 	}
+	if state != nil {
+		return s.reply(ctx, b, "Wizard for "+state.label()+" canceled.")
+	}
 	return s.reply(ctx, b, "Wizard canceled.")
 }
 
 func (s *Service) ask(b *gotgbot.Bot, ctx *ext.Context) error {
+	return s.slotAsk(b, ctx, "", "Usage: /ask [--debug] <text>")
+}
+
+// slotAsk enqueues the command's text for the preset of a default slot; an
+// empty slot is the general default.
+func (s *Service) slotAsk(b *gotgbot.Bot, ctx *ext.Context, slot, usage string) error {
 	msg := ctx.EffectiveMessage
 	if msg == nil || ctx.EffectiveChat == nil {
 		return nil
 	}
-	prompt := strings.TrimSpace(commandRemainder(msg.GetText()))
+	prompt, debug := cutDebugFlag(strings.TrimSpace(commandRemainder(msg.GetText())))
 	if prompt == "" {
-		return s.reply(ctx, b, "Usage: /ask <text>")
+		return s.reply(ctx, b, usage)
+	}
+	if debug && !s.allowDebug(b, ctx) {
+		return nil
 	}
 
 	if !s.allowRate(ctx.EffectiveChat.Id, userID(ctx), b, ctx) {
@@ -63,19 +102,18 @@ func (s *Service) ask(b *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	s.ensureChat(context.Background(), msg)
-	job := queue.AskJob{
-		ChatID:    ctx.EffectiveChat.Id,
-		ChatType:  ctx.EffectiveChat.Type,
-		UserID:    userID(ctx),
-		MessageID: msg.MessageId,
-		Prompt:    prompt,
-	}
-	if _, err := s.queue.Enqueue(context.Background(), job); err != nil {
-		s.logger.Error().Err(err).Msg("failed to enqueue /ask job")
-		return s.reply(ctx, b, "Queue is unavailable right now.")
-	}
-	s.metrics.EnqueuedJobs.Inc()
-	return s.reply(ctx, b, "Accepted. Processing in queue.")
+	author, quoted := quotedContext(msg)
+	return s.enqueueAsk(b, ctx, queue.AskJob{
+		ChatID:       ctx.EffectiveChat.Id,
+		ChatType:     ctx.EffectiveChat.Type,
+		UserID:       userID(ctx),
+		MessageID:    msg.MessageId,
+		Prompt:       prompt,
+		Slot:         slot,
+		QuotedText:   quoted,
+		QuotedAuthor: author,
+		Debug:        debug,
+	})
 }
 
 func (s *Service) ai(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -83,10 +121,13 @@ func (s *Service) ai(b *gotgbot.Bot, ctx *ext.Context) error {
 	if msg == nil || ctx.EffectiveChat == nil {
 		return nil
 	}
-	rest := strings.TrimSpace(commandRemainder(msg.GetText()))
+	rest, debug := cutDebugFlag(strings.TrimSpace(commandRemainder(msg.GetText())))
 	preset, prompt := splitFirstWord(rest)
 	if preset == "" || prompt == "" {
-		return s.reply(ctx, b, "Usage: /ai <preset> <text>")
+		return s.reply(ctx, b, "Usage: /ai [--debug] <preset> <text>")
+	}
+	if debug && !s.allowDebug(b, ctx) {
+		return nil
 	}
 
 	if !s.allowRate(ctx.EffectiveChat.Id, userID(ctx), b, ctx) {
@@ -94,20 +135,111 @@ func (s *Service) ai(b *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	s.ensureChat(context.Background(), msg)
-	job := queue.AskJob{
-		ChatID:     ctx.EffectiveChat.Id,
-		ChatType:   ctx.EffectiveChat.Type,
-		UserID:     userID(ctx),
-		MessageID:  msg.MessageId,
-		Prompt:     prompt,
-		PresetName: preset,
-	}
-	if _, err := s.queue.Enqueue(context.Background(), job); err != nil {
-		s.logger.Error().Err(err).Msg("failed to enqueue /ai job")
-		return s.reply(ctx, b, "Queue is unavailable right now.")
+	author, quoted := quotedContext(msg)
+	return s.enqueueAsk(b, ctx, queue.AskJob{
+		ChatID:       ctx.EffectiveChat.Id,
+		ChatType:     ctx.EffectiveChat.Type,
+		UserID:       userID(ctx),
+		MessageID:    msg.MessageId,
+		Prompt:       prompt,
+		PresetName:   preset,
+		QuotedText:   quoted,
+		QuotedAuthor: author,
+		Debug:        debug,
+	})
+}
+
+const maxQuotedRunes = 3000
+
+// quotedContext returns the author and text of the message a command replies
+// to. A manual partial quote wins over the full text. Replies to one's own
+// message and the implicit reply to a forum topic's root are ignored.
+func quotedContext(msg *gotgbot.Message) (author, text string) {
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.ForumTopicCreated != nil {
+		return "", ""
+	}
+	if reply.From != nil && msg.From != nil && reply.From.Id == msg.From.Id {
+		return "", ""
+	}
+	text = reply.GetText()
+	if text == "" {
+		text = reply.Caption
+	}
+	if msg.Quote != nil && strings.TrimSpace(msg.Quote.Text) != "" {
+		text = msg.Quote.Text
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ""
+	}
+	if r := []rune(text); len(r) > maxQuotedRunes {
+		text = string(r[:maxQuotedRunes])
+	}
+	author = "unknown"
+	if reply.From != nil {
+		author = strings.TrimSpace(reply.From.FirstName + " " + reply.From.LastName)
+		if reply.From.Username != "" {
+			author += " (@" + reply.From.Username + ")"
+		}
+	} else if reply.SenderChat != nil {
+		author = reply.SenderChat.Title
+	}
+	return author, text
+}
+
+func (s *Service) enqueueAsk(b *gotgbot.Bot, ctx *ext.Context, job queue.AskJob) error {
+	job.ThreadID = threadID(ctx)
+	if s.refuseDuringMaintenance(b, job) {
+		return nil
+	}
+	// The acknowledgement is sent first so its id travels with the job and
+	// ingress nodes can update it from worker lifecycle events. Chats using
+	// reactions get an emoji on the prompt instead; if reactions are not
+	// allowed there, fall back to the status message.
+	if style, err := s.store.GetAckStyle(context.Background(), job.ChatID); err == nil && style == storage.AckStyleReaction {
+		job.AckReaction = s.react(b, job.ChatID, job.MessageID, ackReactionEmoji)
+	}
+	if !job.AckReaction {
+		status, err := s.sender.Send(context.Background(), tgsend.Message{ChatID: job.ChatID, ThreadID: job.ThreadID, ReplyTo: job.MessageID, Text: jobStateText(queue.JobStateQueued, 0)})
+		if err == nil && status != nil {
+			job.StatusMessageID = status.MessageId
+		}
+	}
+	return s.submitAsk(b, ctx, job)
+}
+
+// submitAsk enqueues an acknowledged job and keeps it cancelable by prompt
+// edits for askEditWindow.
+func (s *Service) submitAsk(b *gotgbot.Bot, ctx *ext.Context, job queue.AskJob) error {
+	entryID, err := s.queue.Enqueue(context.Background(), job)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to enqueue ask job")
+		if job.StatusMessageID > 0 {
+			_ = s.sender.Edit(context.Background(), job.ChatID, job.StatusMessageID, "Queue is unavailable right now.", nil)
+			s.expire(job.ChatID, job.StatusMessageID)
+			return nil
+		}
+		return s.replyEphemeral(ctx, b, "Queue is unavailable right now.")
+	}
+	if err := s.queue.Track(context.Background(), job, entryID, s.askEditWindow); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to track ask job for edits")
 	}
 	s.metrics.EnqueuedJobs.Inc()
-	return s.reply(ctx, b, "Accepted. Processing in queue.")
+	return nil
+}
+
+const ackReactionEmoji = "👀"
+
+func (s *Service) react(b *gotgbot.Bot, chatID, messageID int64, emoji string) bool {
+	_, err := b.SetMessageReaction(chatID, messageID, &gotgbot.SetMessageReactionOpts{
+		Reaction: []gotgbot.ReactionType{gotgbot.ReactionTypeEmoji{Emoji: emoji}},
+	})
+	if err != nil {
+		s.logger.Debug().Err(err).Int64("chat_id", chatID).Msg("set message reaction failed")
+		return false
+	}
+	return true
 }
 
 func (s *Service) aiList(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -180,6 +312,7 @@ func (s *Service) aiPresetAdd(b *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	_ = s.audit(chatID, userID, "preset_add", map[string]any{"name": name, "provider": providerName, "model": model})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("saved preset %s (%s via %s)", name, model, providerName))
 	return s.reply(ctx, b, "Preset saved.")
 }
 
@@ -199,10 +332,13 @@ func (s *Service) aiPresetDel(b *gotgbot.Bot, ctx *ext.Context) error {
 		s.logger.Error().Err(err).Msg("delete preset failed")
 		return s.reply(ctx, b, "Failed to delete preset.")
 	}
-	if def, err := s.store.GetDefaultPresetName(context.Background(), chatID); err == nil && def == name {
-		_ = s.store.ClearDefaultPreset(context.Background(), chatID)
+	for _, slot := range storage.DefaultSlots {
+		if def, err := s.store.GetSlotPresetName(context.Background(), chatID, slot); err == nil && def == name {
+			_ = s.store.ClearSlotPreset(context.Background(), chatID, slot)
+		}
 	}
 	_ = s.audit(chatID, userID, "preset_del", map[string]any{"name": name})
+	s.notifyChange(b, ctx, chatID, "deleted preset "+name)
 	return s.reply(ctx, b, "Preset deleted.")
 }
 
@@ -211,9 +347,22 @@ func (s *Service) aiDefault(b *gotgbot.Bot, ctx *ext.Context) error {
 	if !ok {
 		return nil
 	}
-	name := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
-	if name == "" {
-		return s.reply(ctx, b, "Usage: /ai_default <name>")
+	slot, name := splitFirstWord(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())))
+	if slot == "" {
+		return s.reply(ctx, b, s.defaultSlotsText(chatID)+"\n\n"+aiDefaultUsage)
+	}
+	if name == "" || !storage.ValidDefaultSlot(slot) {
+		// A single word is the general default, even if a preset is named
+		// like a slot.
+		slot, name = storage.SlotGeneral, strings.TrimSpace(slot+" "+name)
+	}
+	if name == "off" && slot != storage.SlotGeneral {
+		if err := s.store.ClearSlotPreset(context.Background(), chatID, slot); err != nil {
+			return s.reply(ctx, b, "Failed to clear default preset.")
+		}
+		_ = s.audit(chatID, userID, "preset_default", map[string]any{"slot": slot, "name": ""})
+		s.notifyChange(b, ctx, chatID, "cleared the "+slot+" default preset")
+		return s.reply(ctx, b, "Default preset for "+slot+" cleared; it falls back to the general default.")
 	}
 	if _, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
@@ -221,11 +370,17 @@ func (s *Service) aiDefault(b *gotgbot.Bot, ctx *ext.Context) error {
 		}
 		return s.reply(ctx, b, "Failed to read preset.")
 	}
-	if err := s.store.SetDefaultPreset(context.Background(), chatID, name); err != nil {
+	if err := s.store.SetSlotPreset(context.Background(), chatID, slot, name); err != nil {
 		return s.reply(ctx, b, "Failed to set default preset.")
 	}
-	_ = s.audit(chatID, userID, "preset_default", map[string]any{"name": name})
-	return s.reply(ctx, b, "Default preset updated.")
+	if slot == storage.SlotGeneral {
+		_ = s.audit(chatID, userID, "preset_default", map[string]any{"name": name})
+		s.notifyChange(b, ctx, chatID, "set default preset to "+name)
+		return s.reply(ctx, b, "Default preset updated.")
+	}
+	_ = s.audit(chatID, userID, "preset_default", map[string]any{"slot": slot, "name": name})
+	s.notifyChange(b, ctx, chatID, "set the "+slot+" default preset to "+name)
+	return s.reply(ctx, b, "Default preset for "+slot+" updated.")
 }
 
 func (s *Service) llmAdd(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -233,7 +388,10 @@ func (s *Service) llmAdd(b *gotgbot.Bot, ctx *ext.Context) error {
 		return nil
 	}
 	if ctx.EffectiveChat.Type == "private" {
-		return s.reply(ctx, b, "Run /llm_add in your group/supergroup first.")
+		if !s.privateSelfService {
+			return s.reply(ctx, b, "Run /llm_add in your group/supergroup first.")
+		}
+		return s.beginLLMAddWizard(ctx, b, ctx.EffectiveChat.Id)
 	}
 
 	chatID, _, ok := s.requireAdmin(b, ctx)
@@ -248,12 +406,10 @@ func (s *Service) llmAdd(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat == nil {
 		return nil
 	}
-	_, err := b.SendMessage(ctx.EffectiveChat.Id, "Continue in private chat using the button below.", &gotgbot.SendMessageOpts{
-		ReplyMarkup: gotgbot.InlineKeyboardMarkup{
-			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
-				{
-					{Text: "Open private chat", Url: link},
-				},
+	err := s.replyWithMarkup(ctx, b, "Continue in private chat using the button below.", &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Open private chat", Url: link},
 			},
 		},
 	})
@@ -275,10 +431,30 @@ func (s *Service) llmList(b *gotgbot.Bot, ctx *ext.Context) error {
 	lines := []string{"Providers:"}
 	for _, p := range items {
 		lines = append(lines, fmt.Sprintf("- %s [%s] %s", p.Name, p.Kind, p.BaseURL))
+		if health := providerHealthLine(p); health != "" {
+			lines = append(lines, "  "+health)
+		}
 	}
 	return s.reply(ctx, b, strings.Join(lines, "\n"))
 }
 
+// providerHealthLine summarises the worker's latest calls to p; empty when it
+// was never called.
+func providerHealthLine(p storage.ProviderInstance) string {
+	const layout = "2006-01-02 15:04 UTC"
+	switch {
+	case p.Failing():
+		line := "FAILING since " + p.LastErrorAt.UTC().Format(layout) + ": " + p.LastErrorText
+		if p.LastSuccessAt != nil {
+			line += " (last success " + p.LastSuccessAt.UTC().Format(layout) + ")"
+		}
+		return line
+	case p.LastSuccessAt != nil:
+		return "ok, last success " + p.LastSuccessAt.UTC().Format(layout)
+	}
+	return ""
+}
+
 func (s *Service) llmDel(b *gotgbot.Bot, ctx *ext.Context) error {
 	chatID, userID, ok := s.requireAdmin(b, ctx)
 	if !ok {
@@ -295,9 +471,65 @@ func (s *Service) llmDel(b *gotgbot.Bot, ctx *ext.Context) error {
 		return s.reply(ctx, b, "Failed to delete provider.")
 	}
 	_ = s.audit(chatID, userID, "provider_del", map[string]any{"name": name})
+	s.notifyChange(b, ctx, chatID, "deleted provider "+name)
 	return s.reply(ctx, b, "Provider deleted.")
 }
 
+// whois shows what the bot saw of a member in this chat. It only works in
+// groups: activity in other chats is none of the chat admins' business.
+func (s *Service) whois(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat != nil && ctx.EffectiveChat.Type == "private" {
+		return s.reply(ctx, b, "Use /whois in a group, about its members.")
+	}
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	msg := ctx.EffectiveMessage
+	target := strings.TrimSpace(commandRemainder(msg.GetText()))
+	if target == "" && (msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil) {
+		return s.reply(ctx, b, "Usage: /whois <@username|user_id> or reply to a message with /whois")
+	}
+	user, err := s.lookupUser(chatID, msg, target)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "User not seen in this chat yet.")
+		}
+		s.logger.Error().Err(err).Msg("whois lookup failed")
+		return s.reply(ctx, b, "Failed to load user.")
+	}
+
+	username := "<none>"
+	if user.Username != "" {
+		username = "@" + user.Username
+	}
+	return s.reply(ctx, b, strings.Join([]string{
+		"User info",
+		fmt.Sprintf("user_id: %d", user.ID),
+		fmt.Sprintf("username: %s", username),
+		fmt.Sprintf("first_name: %s", user.FirstName),
+		fmt.Sprintf("first_seen: %s", user.FirstSeenAt.UTC().Format("2006-01-02 15:04 UTC")),
+		fmt.Sprintf("last_active: %s", user.LastActiveAt.UTC().Format("2006-01-02 15:04 UTC")),
+		fmt.Sprintf("messages: %d", user.MessageCount),
+	}, "\n"))
+}
+
+// lookupUser finds the member of chatID named by target, an @username or
+// user id, or the author of the message msg replies to when target is empty.
+// Users the bot never saw in chatID are ErrNotFound.
+func (s *Service) lookupUser(chatID int64, msg *gotgbot.Message, target string) (storage.User, error) {
+	switch {
+	case target == "" && msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil:
+		return s.store.GetChatUser(context.Background(), chatID, msg.ReplyToMessage.From.Id)
+	case target == "":
+		return storage.User{}, storage.ErrNotFound
+	}
+	if id, err := strconv.ParseInt(target, 10, 64); err == nil {
+		return s.store.GetChatUser(context.Background(), chatID, id)
+	}
+	return s.store.GetChatUserByUsername(context.Background(), chatID, target)
+}
+
 func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil || ctx.EffectiveMessage == nil {
 		return nil
@@ -316,14 +548,14 @@ func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 		return s.reply(ctx, b, "Wizard state error. Start again with /llm_add.")
 	}
 	if state == nil {
-		return nil
+		return s.offerWizardPicker(ctx, b)
 	}
 
 	switch state.Step {
 	case "kind":
 		kind := normalizeProviderKind(text)
 		if kind == "" {
-			return s.reply(ctx, b, "Send provider type: openai-compat or custom-http")
+			return s.reply(ctx, b, "Send provider type: openai-compat, anthropic or custom-http")
 		}
 		state.Kind = kind
 		state.Step = "name"
@@ -344,10 +576,23 @@ func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 		if state.Kind == "openai_compat" {
 			return s.reply(ctx, b, "Send base URL (example: https://api.x.ai/v1)")
 		}
+		if state.Kind == "anthropic" {
+			return s.reply(ctx, b, "Send base URL or '-' for "+anthropic_messages.DefaultBaseURL)
+		}
 		return s.reply(ctx, b, "Send custom endpoint URL")
 
 	case "base_url":
 		state.BaseURL = text
+		if state.Kind == "anthropic" {
+			if text == "-" {
+				state.BaseURL = anthropic_messages.DefaultBaseURL
+			}
+			state.Step = "tls"
+			if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
+				return s.reply(ctx, b, "Failed to persist wizard state.")
+			}
+			return s.reply(ctx, b, tlsWizardPrompt)
+		}
 		if state.Kind == "openai_compat" {
 			state.Step = "endpoint"
 			if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
@@ -367,11 +612,11 @@ func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 			return s.reply(ctx, b, "Supported endpoint modes: chat_completions or responses")
 		}
 		state.Endpoint = mode
-		state.Step = "api_key"
+		state.Step = "tls"
 		if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
 			return s.reply(ctx, b, "Failed to persist wizard state.")
 		}
-		return s.reply(ctx, b, "Send API key (or '-' for empty).")
+		return s.reply(ctx, b, tlsWizardPrompt)
 
 	case "headers":
 		if text == "-" {
@@ -383,6 +628,59 @@ func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 			}
 			state.HeadersJSON = text
 		}
+		state.Step = "response_path"
+		if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
+			return s.reply(ctx, b, "Failed to persist wizard state.")
+		}
+		return s.reply(ctx, b, "Send response path to the answer (JSONPath like $.data.answer or template like {{.data.answer}}) or '-' to auto-detect")
+
+	case "response_path":
+		if text == "-" {
+			state.ResponsePath = ""
+		} else {
+			if err := custom_http.ValidateResponsePath(text); err != nil {
+				return s.reply(ctx, b, "Invalid response path: "+err.Error())
+			}
+			state.ResponsePath = text
+		}
+		state.Step = "signing"
+		if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
+			return s.reply(ctx, b, "Failed to persist wizard state.")
+		}
+		return s.reply(ctx, b, signingWizardPrompt)
+
+	case "signing":
+		if text == "-" {
+			state.SigningJSON = ""
+		} else {
+			var sg custom_http.Signing
+			if err := json.Unmarshal([]byte(text), &sg); err != nil {
+				return s.reply(ctx, b, "Invalid JSON. "+signingWizardPrompt)
+			}
+			if err := sg.Validate(); err != nil {
+				return s.reply(ctx, b, "Invalid signing settings: "+err.Error())
+			}
+			state.SigningJSON = text
+		}
+		state.Step = "tls"
+		if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
+			return s.reply(ctx, b, "Failed to persist wizard state.")
+		}
+		return s.reply(ctx, b, tlsWizardPrompt)
+
+	case "tls":
+		if text == "-" {
+			state.TLSJSON = ""
+		} else {
+			var m httpclient.TLSMaterial
+			if err := json.Unmarshal([]byte(text), &m); err != nil {
+				return s.reply(ctx, b, "Invalid JSON. "+tlsWizardPrompt)
+			}
+			if err := m.Validate(); err != nil {
+				return s.reply(ctx, b, "Invalid TLS settings: "+err.Error())
+			}
+			state.TLSJSON = text
+		}
 		state.Step = "api_key"
 		if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, *state); err != nil {
 			return s.reply(ctx, b, "Failed to persist wizard state.")
@@ -394,22 +692,60 @@ func (s *Service) privateText(b *gotgbot.Bot, ctx *ext.Context) error {
 		if apiKey == "-" {
 			apiKey = ""
 		}
+		// The key is in memory now; it should not stay in the chat history
+		// whatever happens next.
+		note := ""
+		if apiKey != "" {
+			note = " " + s.deleteKeyMessage(b, ctx)
+			if err := providers.CheckAPIKey(state.BaseURL, apiKey); err != nil {
+				return s.reply(ctx, b, "That does not look like an API key: "+err.Error()+". Send the key again (or '-' for empty)."+note)
+			}
+		}
+		verifyErr := s.verifyWizardKey(state, apiKey)
+		if errors.Is(verifyErr, providers.ErrUnauthorized) {
+			return s.reply(ctx, b, "The provider rejected this API key. Send the correct key (or '-' for empty)."+note)
+		}
 		if err := s.finishWizard(ctx.EffectiveUser.Id, state, apiKey); err != nil {
 			s.logger.Error().Err(err).Msg("finish wizard failed")
-			return s.reply(ctx, b, "Failed to save provider. Try again with /llm_add.")
+			return s.reply(ctx, b, "Failed to save provider. Try again with /llm_add."+note)
+		}
+		_ = s.wizard.Clear(context.Background(), ctx.EffectiveUser.Id, state.TargetChatID)
+		s.notifyChange(b, ctx, state.TargetChatID, fmt.Sprintf("added provider %s (%s)", state.Name, state.Kind))
+		where := "in group"
+		if state.TargetChatID == ctx.EffectiveUser.Id {
+			where = "here"
 		}
-		_ = s.wizard.Clear(context.Background(), ctx.EffectiveUser.Id)
-		return s.reply(ctx, b, "Provider saved. Use /llm_list in group.")
+		if verifyErr != nil {
+			return s.reply(ctx, b, "Provider saved, but the key could not be checked: the provider did not answer the model list request. Use /llm_list "+where+"."+note)
+		}
+		return s.reply(ctx, b, "Provider saved. Use /llm_list "+where+"."+note)
 	}
 
 	return nil
 }
 
+// deleteKeyMessage removes the message carrying an API key and returns the
+// sentence telling the user whether that worked.
+func (s *Service) deleteKeyMessage(b *gotgbot.Bot, ctx *ext.Context) string {
+	msg := ctx.EffectiveMessage
+	if _, err := b.DeleteMessageWithContext(context.Background(), msg.Chat.Id, msg.MessageId, nil); err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", ctx.EffectiveUser.Id).Msg("failed to delete api key message")
+		return "Delete your message with the key from this chat yourself."
+	}
+	return "Your message with the key was deleted for safety."
+}
+
 func (s *Service) beginLLMAddWizard(ctx *ext.Context, b *gotgbot.Bot, targetChatID int64) error {
 	if ctx.EffectiveUser == nil || ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	admin, err := s.isAdmin(context.Background(), b, targetChatID, ctx.EffectiveUser.Id)
+	// The deep link for the user's own private chat comes from /llm_add
+	// there, one of privateSelfServiceActions.
+	admin := s.privateSelfService
+	var err error
+	if targetChatID != ctx.EffectiveUser.Id {
+		admin, err = s.isAdmin(context.Background(), b, targetChatID, ctx.EffectiveUser.Id)
+	}
 	if err != nil {
 		s.logger.Error().Err(err).Int64("chat_id", targetChatID).Msg("admin check failed in dm wizard")
 		return s.reply(ctx, b, "Could not verify admin rights. Please retry.")
@@ -417,20 +753,50 @@ func (s *Service) beginLLMAddWizard(ctx *ext.Context, b *gotgbot.Bot, targetChat
 	if !admin {
 		return s.reply(ctx, b, "You are not an admin in that chat.")
 	}
-	_ = s.store.EnsureChat(context.Background(), targetChatID, "group", "")
 	state := llmWizardState{TargetChatID: targetChatID, Step: "kind"}
+	if targetChatID == ctx.EffectiveUser.Id {
+		_ = s.store.EnsureChat(context.Background(), targetChatID, "private", "")
+		state.TargetTitle = "this private chat"
+	} else {
+		_ = s.store.EnsureChat(context.Background(), targetChatID, "group", "")
+		if chat, err := b.GetChat(targetChatID, nil); err == nil {
+			state.TargetTitle = chat.Title
+		}
+	}
+	others, _ := s.wizard.Sessions(context.Background(), ctx.EffectiveUser.Id)
 	if err := s.wizard.Set(context.Background(), ctx.EffectiveUser.Id, state); err != nil {
 		return s.reply(ctx, b, "Failed to start wizard.")
 	}
-	return s.reply(ctx, b, "Wizard started. Send provider type: openai-compat or custom-http")
+	text := "Wizard started for " + state.label() + ". " + wizardStepPrompt(state)
+	for _, o := range others {
+		if o.TargetChatID != targetChatID {
+			text += "\nYou have other wizards open; switch between them with /wizards."
+			break
+		}
+	}
+	return s.reply(ctx, b, text)
 }
 
 func (s *Service) finishWizard(actorUserID int64, state *llmWizardState, apiKey string) error {
+	inst, err := s.wizardInstance(state, apiKey)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.UpsertProviderInstance(context.Background(), inst); err != nil {
+		return err
+	}
+	_ = s.audit(state.TargetChatID, actorUserID, "provider_add", map[string]any{"name": state.Name, "kind": state.Kind})
+	_ = s.store.SetChatSetting(context.Background(), state.TargetChatID, storage.SettingOnboarding, storage.OnboardingDone)
+	return nil
+}
+
+// wizardInstance encrypts the wizard answers into the provider row it saves.
+func (s *Service) wizardInstance(state *llmWizardState, apiKey string) (storage.ProviderInstance, error) {
 	var encAPIKey *string
 	if strings.TrimSpace(apiKey) != "" {
 		v, err := s.crypto.MarshalEncryptedString(apiKey)
 		if err != nil {
-			return err
+			return storage.ProviderInstance{}, err
 		}
 		encAPIKey = &v
 	}
@@ -439,7 +805,7 @@ func (s *Service) finishWizard(actorUserID int64, state *llmWizardState, apiKey
 	if strings.TrimSpace(state.HeadersJSON) != "" {
 		v, err := s.crypto.MarshalEncryptedString(state.HeadersJSON)
 		if err != nil {
-			return err
+			return storage.ProviderInstance{}, err
 		}
 		encHeaders = &v
 	}
@@ -448,9 +814,26 @@ func (s *Service) finishWizard(actorUserID int64, state *llmWizardState, apiKey
 	if state.Kind == "openai_compat" {
 		cfg["endpoint"] = state.Endpoint
 	}
+	if state.ResponsePath != "" {
+		cfg["response_path"] = state.ResponsePath
+	}
+	if strings.TrimSpace(state.SigningJSON) != "" {
+		v, err := s.crypto.MarshalEncryptedString(state.SigningJSON)
+		if err != nil {
+			return storage.ProviderInstance{}, err
+		}
+		cfg["enc_signing"] = v
+	}
+	if strings.TrimSpace(state.TLSJSON) != "" {
+		v, err := s.crypto.MarshalEncryptedString(state.TLSJSON)
+		if err != nil {
+			return storage.ProviderInstance{}, err
+		}
+		cfg["enc_tls"] = v
+	}
 	cfgJSON, _ := json.Marshal(cfg)
 
-	_, err := s.store.UpsertProviderInstance(context.Background(), storage.ProviderInstance{
+	return storage.ProviderInstance{
 		ChatID:         state.TargetChatID,
 		Name:           state.Name,
 		Kind:           state.Kind,
@@ -458,24 +841,30 @@ func (s *Service) finishWizard(actorUserID int64, state *llmWizardState, apiKey
 		EncAPIKey:      encAPIKey,
 		EncHeadersJSON: encHeaders,
 		ConfigJSON:     string(cfgJSON),
-	})
-	if err != nil {
-		return err
-	}
-	_ = s.audit(state.TargetChatID, actorUserID, "provider_add", map[string]any{"name": state.Name, "kind": state.Kind})
-	return nil
+	}, nil
 }
 
 func (s *Service) requireAdmin(b *gotgbot.Bot, ctx *ext.Context) (chatID int64, uid int64, ok bool) {
 	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil {
 		return 0, 0, false
 	}
-	if ctx.EffectiveChat.Type == "private" {
-		_ = s.reply(ctx, b, "Run this command in group/supergroup.")
-		return 0, 0, false
-	}
 	chatID = ctx.EffectiveChat.Id
 	uid = ctx.EffectiveUser.Id
+	if ctx.EffectiveChat.Type == "private" {
+		if !s.privateSelfService || !privateSelfServiceActions[adminAction(ctx)] {
+			_ = s.reply(ctx, b, "Run this command in group/supergroup.")
+			return 0, 0, false
+		}
+		// Users configure their own private chat with the bot.
+		if ctx.EffectiveMessage != nil {
+			s.ensureChat(context.Background(), ctx.EffectiveMessage)
+		}
+		return chatID, uid, true
+	}
+	if s.allowAnonymousAdmins && isAnonymousAdmin(ctx) {
+		s.ensureChat(context.Background(), ctx.EffectiveMessage)
+		return chatID, uid, true
+	}
 	admin, err := s.isAdmin(context.Background(), b, chatID, uid)
 	if err != nil {
 		s.logger.Error().Err(err).Int64("chat_id", chatID).Int64("user_id", uid).Msg("admin check failed")
@@ -492,8 +881,52 @@ func (s *Service) requireAdmin(b *gotgbot.Bot, ctx *ext.Context) (chatID int64,
 	return chatID, uid, true
 }
 
+// privateSelfServiceActions are the admin commands and buttons a user may
+// run in their private chat with the bot when PRIVATE_SELF_SERVICE is on.
+// Each only configures that chat; commands about other members, such as
+// /whois and /ratelimit_exempt, stay group-only.
+var privateSelfServiceActions = map[string]bool{
+	"ab_report": true, "ab_start": true, "ab_stop": true,
+	"ai_default": true, "ai_preset_add": true, "ai_preset_del": true, "ai_preset_param": true,
+	"bot_off": true, "bot_on": true,
+	"export_policy": true, "forget_chat": true,
+	"kb_del": true, "kb_refresh": true,
+	"llm_add": true, "llm_del": true, "llm_limits": true, "llm_list": true, "llm_set": true, "llm_show": true,
+	"mention_mode": true, "models": true,
+	"persona_clear": true, "persona_set": true, "prefixes": true,
+	"preset_add_template": true, "preset_history": true, "preset_rollback": true, "preset_stats": true,
+	"preview": true, "settings": true, "tpl_add": true, "tpl_del": true,
+	// Buttons, by callback data without cbPrefix and argument.
+	"list_providers": true, "act_llm_add": true, "act_llm_list": true,
+	"onb_shared": true, "onb_custom": true,
+	"set": true, "setup_def": true, "forget_chat_ok": true,
+}
+
+// adminAction names what ctx asks for: the command without the slash and
+// bot username, or the button's callback data without cbPrefix and argument.
+func adminAction(ctx *ext.Context) string {
+	if cq := ctx.CallbackQuery; cq != nil {
+		action, _, _ := strings.Cut(strings.TrimPrefix(cq.Data, cbPrefix), ":")
+		return action
+	}
+	if ctx.EffectiveMessage == nil {
+		return ""
+	}
+	first, _ := splitFirstWord(ctx.EffectiveMessage.GetText())
+	if !strings.HasPrefix(first, "/") {
+		return ""
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(first, "/"), "@")
+	return strings.ToLower(name)
+}
+
 func (s *Service) isAdmin(ctx context.Context, b *gotgbot.Bot, chatID, userID int64) (bool, error) {
-	cacheKey := fmt.Sprintf("hyprbot:admin:%d:%d", chatID, userID)
+	// A private chat has its user's id. Nobody administers it: what its user
+	// may configure there is privateSelfServiceActions, checked by callers.
+	if chatID == userID {
+		return false, nil
+	}
+	cacheKey := adminCacheKey(chatID, userID)
 	if v, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
 		return v == "1", nil
 	} else if err != redis.Nil {
@@ -516,24 +949,30 @@ func (s *Service) isAdmin(ctx context.Context, b *gotgbot.Bot, chatID, userID in
 	return admin, nil
 }
 
-func (s *Service) allowRate(chatID, userID int64, b *gotgbot.Bot, ctx *ext.Context) bool {
-	if userID == 0 || s.rateLimiter == nil {
-		return true
-	}
-	ok, _, resetAt, err := s.rateLimiter.Allow(context.Background(), chatID, userID, s.now())
-	if err != nil {
-		s.logger.Error().Err(err).Msg("rate limiter failed")
-		return true
-	}
-	if ok {
-		return true
-	}
-	_ = s.reply(ctx, b, "Rate limit exceeded. Try again after "+resetAt.Format("15:04 UTC"))
-	return false
+// isAnonymousAdmin reports a message sent on behalf of the group itself.
+// Only admins can post as the group; EffectiveUser is then GroupAnonymousBot,
+// which getChatMember cannot vouch for.
+func isAnonymousAdmin(ctx *ext.Context) bool {
+	msg := ctx.Message
+	return msg != nil && msg.SenderChat != nil && msg.SenderChat.Id == msg.Chat.Id
 }
 
+func adminCacheKey(chatID, userID int64) string {
+	return fmt.Sprintf("hyprbot:admin:%d:%d", chatID, userID)
+}
+
+// audit records an admin action and reports it to the owner's webhooks as a
+// config change.
 func (s *Service) audit(chatID, userID int64, action string, meta map[string]any) error {
 	b, _ := json.Marshal(meta)
+	s.notify.Emit(context.Background(), notify.Event{
+		Type:   notify.EventConfigChanged,
+		ChatID: chatID,
+		UserID: userID,
+		Text:   auditText(chatID, action),
+		Data:   map[string]any{"action": action, "meta": meta},
+	})
+	s.stream.Publish(eventstream.EventConfigChanged, chatID, userID, map[string]any{"action": action})
 	return s.store.LogAction(context.Background(), storage.AuditEntry{
 		ChatID:   chatID,
 		UserID:   userID,
@@ -542,12 +981,24 @@ func (s *Service) audit(chatID, userID int64, action string, meta map[string]any
 	})
 }
 
+func auditText(chatID int64, action string) string {
+	if chatID == 0 {
+		return "Bot config changed: " + action
+	}
+	return fmt.Sprintf("Chat %d config changed: %s", chatID, action)
+}
+
 func (s *Service) reply(ctx *ext.Context, b *gotgbot.Bot, text string) error {
-	if ctx.EffectiveChat == nil {
-		return nil
+	return s.replyWithMarkup(ctx, b, text, nil)
+}
+
+// threadID is the forum topic the update came from, so answers stay in it
+// instead of landing in General.
+func threadID(ctx *ext.Context) int64 {
+	if ctx == nil || ctx.EffectiveMessage == nil || !ctx.EffectiveMessage.IsTopicMessage {
+		return 0
 	}
-	_, err := b.SendMessage(ctx.EffectiveChat.Id, text, nil)
-	return err
+	return ctx.EffectiveMessage.MessageThreadId
 }
 
 func commandRemainder(text string) string {
@@ -574,6 +1025,8 @@ func normalizeProviderKind(v string) string {
 	switch strings.ToLower(strings.TrimSpace(v)) {
 	case "openai", "openai_compat", "openai-compatible", "openai-compat":
 		return "openai_compat"
+	case "anthropic", "anthropic_messages", "anthropic-messages", "claude":
+		return "anthropic"
 	case "custom_http", "custom-http", "custom":
 		return "custom_http"
 	default:
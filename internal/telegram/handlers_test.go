@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+// fakeBotClient answers every Bot API call with a sent message and keeps
+// the texts sent.
+type fakeBotClient struct {
+	sent []string
+}
+
+func (c *fakeBotClient) RequestWithContext(_ context.Context, _ string, method string, params map[string]string, _ map[string]gotgbot.FileReader, _ *gotgbot.RequestOpts) (json.RawMessage, error) {
+	if method == "sendMessage" {
+		c.sent = append(c.sent, params["text"])
+	}
+	return json.RawMessage(`{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}`), nil
+}
+
+func (c *fakeBotClient) GetAPIURL(*gotgbot.RequestOpts) string { return "" }
+
+func (c *fakeBotClient) FileURL(string, string, *gotgbot.RequestOpts) string { return "" }
+
+func newTestBot() (*gotgbot.Bot, *fakeBotClient) {
+	client := &fakeBotClient{}
+	return &gotgbot.Bot{User: gotgbot.User{Id: 1, IsBot: true, Username: "hyprbot"}, BotClient: client}, client
+}
+
+func privateCommand(b *gotgbot.Bot, userID int64, text string) *ext.Context {
+	msg := &gotgbot.Message{Chat: gotgbot.Chat{Id: userID, Type: "private"}, From: &gotgbot.User{Id: userID}, Text: text}
+	return ext.NewContext(b, &gotgbot.Update{Message: msg}, nil)
+}
+
+func TestRequireAdminPrivateAllowlist(t *testing.T) {
+	store := openTestStore(t)
+	bot, client := newTestBot()
+	s := &Service{store: store, sender: tgsend.New(bot, tgsend.Options{}), privateSelfService: true, logger: zerolog.Nop()}
+
+	for text, want := range map[string]bool{
+		"/settings":                  true,
+		"/llm_list@hyprbot":          true,
+		"/whois @alice":              false,
+		"/ratelimit_exempt add 42":   false,
+		"/WHOIS@hyprbot 42":          false,
+		"/unknown_admin_command arg": false,
+	} {
+		client.sent = nil
+		_, _, ok := s.requireAdmin(bot, privateCommand(bot, 42, text))
+		if ok != want {
+			t.Errorf("requireAdmin(%q) = %v, want %v", text, ok, want)
+		}
+		if !ok && (len(client.sent) != 1 || client.sent[0] != "Run this command in group/supergroup.") {
+			t.Errorf("requireAdmin(%q) replied %q", text, client.sent)
+		}
+	}
+
+	s.privateSelfService = false
+	if _, _, ok := s.requireAdmin(bot, privateCommand(bot, 42, "/settings")); ok {
+		t.Error("expected /settings refused with self-service off")
+	}
+}
+
+func TestWhoisRefusedInPrivateChat(t *testing.T) {
+	store := openTestStore(t)
+	bot, client := newTestBot()
+	s := &Service{store: store, sender: tgsend.New(bot, tgsend.Options{}), privateSelfService: true, logger: zerolog.Nop()}
+
+	if err := s.whois(bot, privateCommand(bot, 42, "/whois 42")); err != nil {
+		t.Fatalf("whois: %v", err)
+	}
+	if len(client.sent) != 1 || client.sent[0] != "Use /whois in a group, about its members." {
+		t.Fatalf("whois replied %q", client.sent)
+	}
+}
+
+func TestForgetMePromptListsEverythingDeleted(t *testing.T) {
+	bot, client := newTestBot()
+	s := &Service{sender: tgsend.New(bot, tgsend.Options{}), logger: zerolog.Nop()}
+
+	if err := s.forgetMe(bot, privateCommand(bot, 42, "/forget_me")); err != nil {
+		t.Fatalf("forgetMe: %v", err)
+	}
+	if len(client.sent) != 1 {
+		t.Fatalf("forgetMe sent %d messages", len(client.sent))
+	}
+	for _, what := range storage.ForgetUserScope() {
+		if !strings.Contains(client.sent[0], "- "+what+"\n") {
+			t.Errorf("prompt does not mention %q:\n%s", what, client.sent[0])
+		}
+	}
+}
+
+func TestAdminActionFromCallback(t *testing.T) {
+	bot, _ := newTestBot()
+	for data, want := range map[string]string{
+		cbSettingToggle + "ack": "set",
+		cbSetupDefault + "gpt":  "setup_def",
+		cbForgetChatConfirm:     "forget_chat_ok",
+	} {
+		ctx := ext.NewContext(bot, &gotgbot.Update{CallbackQuery: &gotgbot.CallbackQuery{Data: data}}, nil)
+		if got := adminAction(ctx); got != want {
+			t.Errorf("adminAction(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+)
+
+// command registers handler for /name with per-command metrics.
+func (s *Service) command(name string, handler handlers.Response) handlers.Command {
+	return handlers.NewCommand(name, s.instrument(name, handler))
+}
+
+// instrument counts each call of handler by chat type and outcome and times
+// it. Commands with many arguments share one label, so cardinality stays at
+// the number of registered commands.
+func (s *Service) instrument(name string, handler handlers.Response) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		started := time.Now()
+		err := handler(b, ctx)
+		chatType := "unknown"
+		if ctx.EffectiveChat != nil {
+			chatType = ctx.EffectiveChat.Type
+		}
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		s.metrics.Commands.WithLabelValues(name, chatType, outcome).Inc()
+		s.metrics.CommandDuration.WithLabelValues(name).Observe(time.Since(started).Seconds())
+		return err
+	}
+}
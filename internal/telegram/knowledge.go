@@ -0,0 +1,175 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+// maxKnowledgeEntryRunes bounds each stored pinned message.
+const maxKnowledgeEntryRunes = 2000
+
+func matchPinned(msg *gotgbot.Message) bool {
+	return msg != nil && msg.PinnedMessage != nil && msg.Chat.Type != "private"
+}
+
+// pinned records a newly pinned message for chats with the knowledge
+// setting on. Telegram sends no update for unpins; /kb_refresh and /kb_del
+// drop pins that no longer apply.
+func (s *Service) pinned(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	mode, err := s.store.GetChatSetting(context.Background(), msg.Chat.Id, storage.SettingKnowledge)
+	if err != nil || mode == storage.SettingOff {
+		return nil
+	}
+	pm, ok := msg.PinnedMessage.(gotgbot.Message)
+	if !ok {
+		return nil
+	}
+	s.saveKnowledge(msg.Chat.Id, pm.MessageId, pm.GetText())
+	if mode == storage.KnowledgePinsDescription {
+		// Description edits have no update either; pins are a good moment.
+		if chat, err := b.GetChat(msg.Chat.Id, nil); err == nil {
+			s.saveKnowledge(msg.Chat.Id, 0, chat.Description)
+		}
+	}
+	return nil
+}
+
+func (s *Service) saveKnowledge(chatID, messageID int64, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if messageID == 0 {
+			_ = s.store.DeleteKnowledge(context.Background(), chatID, 0)
+		}
+		return
+	}
+	if r := []rune(text); len(r) > maxKnowledgeEntryRunes {
+		text = string(r[:maxKnowledgeEntryRunes])
+	}
+	if err := s.store.SaveKnowledge(context.Background(), storage.KnowledgeEntry{ChatID: chatID, MessageID: messageID, Text: text}); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to save chat knowledge")
+	}
+}
+
+// refreshKnowledge rebuilds the chat's knowledge from what Telegram reports
+// now: the latest pinned message and, in pins_description mode, the
+// description. Earlier pins are only known from pin updates and are lost.
+func (s *Service) refreshKnowledge(b *gotgbot.Bot, chatID int64, mode string) error {
+	if err := s.store.ClearKnowledge(context.Background(), chatID); err != nil {
+		return err
+	}
+	if mode == storage.SettingOff {
+		return nil
+	}
+	chat, err := b.GetChat(chatID, nil)
+	if err != nil {
+		return fmt.Errorf("get chat: %w", err)
+	}
+	if chat.PinnedMessage != nil {
+		s.saveKnowledge(chatID, chat.PinnedMessage.MessageId, chat.PinnedMessage.GetText())
+	}
+	if mode == storage.KnowledgePinsDescription {
+		s.saveKnowledge(chatID, 0, chat.Description)
+	}
+	return nil
+}
+
+// settingChanged applies side effects of a settings change.
+func (s *Service) settingChanged(b *gotgbot.Bot, chatID int64, key, value string) {
+	if key != storage.SettingKnowledge {
+		return
+	}
+	if err := s.refreshKnowledge(b, chatID, value); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to refresh chat knowledge")
+	}
+}
+
+func (s *Service) kb(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil {
+		return nil
+	}
+	chatID := ctx.EffectiveChat.Id
+	mode, err := s.store.GetChatSetting(context.Background(), chatID, storage.SettingKnowledge)
+	if err != nil {
+		return s.reply(ctx, b, "Failed to load settings.")
+	}
+	if mode == storage.SettingOff {
+		return s.reply(ctx, b, "Knowledge is off. Admins turn it on with /settings knowledge pins (or pins_description) so answers can use pinned messages.")
+	}
+	entries, err := s.store.ListKnowledge(context.Background(), chatID)
+	if err != nil {
+		return s.reply(ctx, b, "Failed to load knowledge.")
+	}
+	if len(entries) == 0 {
+		return s.reply(ctx, b, "No pinned messages known yet. New pins are picked up automatically; admins can run /kb_refresh.")
+	}
+	lines := []string{"Knowledge (" + mode + "):"}
+	for _, e := range entries {
+		text := strings.Join(strings.Fields(e.Text), " ")
+		if r := []rune(text); len(r) > 80 {
+			text = string(r[:80]) + "..."
+		}
+		if e.MessageID == 0 {
+			lines = append(lines, "- description: "+text)
+		} else {
+			lines = append(lines, fmt.Sprintf("- #%d: %s", e.MessageID, text))
+		}
+	}
+	lines = append(lines, "", "Admins: /kb_del <#id> drops an unpinned message, /kb_refresh reloads from the chat.")
+	return s.reply(ctx, b, strings.Join(lines, "\n"))
+}
+
+func (s *Service) kbRefresh(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	mode, err := s.store.GetChatSetting(context.Background(), chatID, storage.SettingKnowledge)
+	if err != nil {
+		return s.reply(ctx, b, "Failed to load settings.")
+	}
+	if mode == storage.SettingOff {
+		return s.reply(ctx, b, "Knowledge is off. Turn it on with /settings knowledge pins.")
+	}
+	if err := s.refreshKnowledge(b, chatID, mode); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("refresh chat knowledge failed")
+		return s.reply(ctx, b, "Failed to refresh knowledge.")
+	}
+	_ = s.audit(chatID, uid, "knowledge_refresh", nil)
+	what := "the latest pinned message"
+	if mode == storage.KnowledgePinsDescription {
+		what += " and the chat description"
+	}
+	return s.reply(ctx, b, "Knowledge reloaded from "+what+". Older pins are added again when re-pinned.")
+}
+
+func (s *Service) kbDel(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	arg := strings.TrimPrefix(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())), "#")
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if arg == "description" {
+		id, err = 0, nil
+	}
+	if err != nil {
+		return s.reply(ctx, b, "Usage: /kb_del <#id|description>")
+	}
+	if err := s.store.DeleteKnowledge(context.Background(), chatID, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Not in the knowledge base. See /kb.")
+		}
+		return s.reply(ctx, b, "Failed to delete knowledge entry.")
+	}
+	_ = s.audit(chatID, uid, "knowledge_del", map[string]any{"message_id": id})
+	return s.reply(ctx, b, "Removed from the knowledge base.")
+}
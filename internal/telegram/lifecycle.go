@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func isMemberStatus(status string) bool {
+	return status == "member" || status == "administrator" || status == "creator"
+}
+
+// myChatMember tracks the bot joining and leaving chats: a new group gets the
+// welcome message, a chat the bot was removed from is marked so its provider
+// secrets can be purged after LEFT_CHAT_PURGE_AFTER.
+func (s *Service) myChatMember(b *gotgbot.Bot, ctx *ext.Context) error {
+	upd := ctx.MyChatMember
+	if upd == nil || upd.Chat.Type == "channel" {
+		return nil
+	}
+	wasMember := isMemberStatus(upd.OldChatMember.GetStatus())
+	isMember := isMemberStatus(upd.NewChatMember.GetStatus())
+	chat := upd.Chat
+
+	switch {
+	case isMember && !wasMember:
+		if err := s.store.EnsureChat(context.Background(), chat.Id, chat.Type, chat.Title); err != nil {
+			s.logger.Error().Err(err).Int64("chat_id", chat.Id).Msg("failed to register chat on join")
+		}
+		_ = s.audit(chat.Id, upd.From.Id, "bot_added", nil)
+		if chat.Type == "group" || chat.Type == "supergroup" {
+			s.welcome(chat.Id)
+		}
+	case wasMember && !isMember:
+		if err := s.store.MarkChatLeft(context.Background(), chat.Id); err != nil {
+			s.logger.Error().Err(err).Int64("chat_id", chat.Id).Msg("failed to mark chat left")
+		}
+		_ = s.audit(chat.Id, upd.From.Id, "bot_removed", map[string]any{"status": upd.NewChatMember.GetStatus()})
+	}
+	return nil
+}
+
+// RunLeftChatPurge periodically drops provider secrets of chats the bot left
+// more than grace ago. A zero grace keeps secrets until /forget_chat.
+func (s *Service) RunLeftChatPurge(ctx context.Context, grace time.Duration) error {
+	if grace <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			n, err := s.store.PurgeLeftChatSecrets(ctx, time.Now().Add(-grace))
+			if err != nil {
+				s.logger.Error().Err(err).Msg("left chat secret purge failed")
+				continue
+			}
+			if n > 0 {
+				s.logger.Info().Int64("providers", n).Msg("purged secrets of chats the bot left")
+			}
+		}
+	}
+}
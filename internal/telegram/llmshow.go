@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/storage"
+)
+
+// llmShow describes a provider without revealing secrets: header values are
+// masked and the API key is only identified by a hash prefix, enough to tell
+// whether two providers share a key.
+func (s *Service) llmShow(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if name == "" {
+		return s.reply(ctx, b, "Usage: /llm_show <name>")
+	}
+	bg := context.Background()
+	p, err := s.store.GetProviderByName(bg, chatID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Provider not found.")
+		}
+		return s.reply(ctx, b, "Failed to load provider.")
+	}
+
+	cfg := map[string]any{}
+	_ = json.Unmarshal([]byte(p.ConfigJSON), &cfg)
+	lines := []string{
+		"Provider " + p.Name,
+		"kind: " + p.Kind,
+		"base_url: " + providers.RedactURL(p.BaseURL),
+	}
+	if p.Kind == "openai_compat" {
+		endpoint, _ := cfg["endpoint"].(string)
+		if endpoint == "" {
+			endpoint = "chat_completions"
+		}
+		lines = append(lines, "endpoint: "+endpoint)
+	}
+
+	keyLine := "api_key: none"
+	if p.EncAPIKey != nil && strings.TrimSpace(*p.EncAPIKey) != "" {
+		key, err := s.crypto.UnmarshalEncryptedString(*p.EncAPIKey)
+		if err != nil {
+			keyLine = "api_key: set (cannot be decrypted)"
+		} else {
+			sum := sha256.Sum256([]byte(key))
+			keyLine = "api_key: sha256 " + hex.EncodeToString(sum[:])[:12]
+		}
+	}
+	lines = append(lines, keyLine)
+
+	if p.EncHeadersJSON != nil && strings.TrimSpace(*p.EncHeadersJSON) != "" {
+		headers := map[string]string{}
+		raw, err := s.crypto.UnmarshalEncryptedString(*p.EncHeadersJSON)
+		if err == nil {
+			err = json.Unmarshal([]byte(raw), &headers)
+		}
+		if err != nil {
+			lines = append(lines, "headers: set (cannot be read)")
+		} else if len(headers) > 0 {
+			names := make([]string, 0, len(headers))
+			for k := range headers {
+				names = append(names, k+": ***")
+			}
+			sort.Strings(names)
+			lines = append(lines, "headers: "+strings.Join(names, ", "))
+		}
+	}
+
+	var settings []string
+	for k, v := range cfg {
+		switch k {
+		case "endpoint":
+		case "enc_tls":
+			settings = append(settings, "tls: custom")
+		case "enc_signing":
+			settings = append(settings, "signing: on")
+		case "body_template":
+			settings = append(settings, "body_template: set")
+		default:
+			raw, _ := json.Marshal(v)
+			settings = append(settings, k+": "+string(raw))
+		}
+	}
+	sort.Strings(settings)
+	lines = append(lines, settings...)
+
+	lines = append(lines, "created: "+p.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	if health := providerHealthLine(p); health != "" {
+		lines = append(lines, "health: "+health)
+	}
+	lastUsed, err := s.store.ProviderLastUsed(bg, chatID, p.ID)
+	switch {
+	case err != nil:
+		s.logger.Warn().Err(err).Str("provider", p.Name).Msg("provider last used lookup failed")
+	case lastUsed == nil:
+		lines = append(lines, "last used: never")
+	default:
+		lines = append(lines, "last used: "+lastUsed.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	return s.reply(ctx, b, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+// showFullAnswer handles "Show full answer" under a preview, as the chat's
+// long_answers setting was when the answer was sent.
+func (s *Service) showFullAnswer(b *gotgbot.Bot, ctx *ext.Context, jobID string) error {
+	msg := ctx.EffectiveMessage
+	if s.fullAnswers == nil || msg == nil {
+		return nil
+	}
+	answer, found, err := s.fullAnswers.Get(context.Background(), jobID)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to load full answer")
+		s.answerCallback(b, ctx, "Failed to load the answer.", true)
+		return nil
+	}
+	if !found || answer.ChatID != msg.Chat.Id {
+		s.answerCallback(b, ctx, "This answer is no longer available.", true)
+		return nil
+	}
+	bg := context.Background()
+	markup := withoutExpandButton(msg.ReplyMarkup)
+
+	switch answer.Mode {
+	case storage.LongAnswersDM:
+		if ctx.EffectiveUser == nil {
+			return nil
+		}
+		if _, err := s.sender.Send(bg, tgsend.Message{ChatID: ctx.EffectiveUser.Id, Text: answer.Text}); err != nil {
+			s.answerCallback(b, ctx, "Start a private chat with the bot first, then tap again.", true)
+			return nil
+		}
+		s.answerCallback(b, ctx, "Sent to your private chat.", false)
+		return nil
+	case storage.LongAnswersFile:
+		_, err := b.SendDocumentWithContext(bg, msg.Chat.Id, gotgbot.InputFileByReader("answer.txt", strings.NewReader(answer.Text)), &gotgbot.SendDocumentOpts{
+			MessageThreadId: threadID(ctx),
+			ReplyParameters: &gotgbot.ReplyParameters{MessageId: msg.MessageId, AllowSendingWithoutReply: true},
+		})
+		if err != nil {
+			s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to send full answer file")
+			s.answerCallback(b, ctx, "Failed to send the answer.", true)
+			return nil
+		}
+	default:
+		if utf8.RuneCountInString(answer.Text) <= tgsend.MaxMessageRunes {
+			if err := s.sender.Edit(bg, msg.Chat.Id, msg.MessageId, answer.Text, markup); err != nil {
+				s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to expand answer")
+				s.answerCallback(b, ctx, "Failed to expand the answer.", true)
+			}
+			return nil
+		}
+		_, err := s.sender.Send(bg, tgsend.Message{
+			ChatID:   msg.Chat.Id,
+			ThreadID: threadID(ctx),
+			ReplyTo:  msg.MessageId,
+			Text:     strings.TrimSpace(answer.Rest()),
+		})
+		if err != nil {
+			s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to post rest of answer")
+			s.answerCallback(b, ctx, "Failed to send the answer.", true)
+			return nil
+		}
+	}
+	// The rest is in the chat now; a second tap would only repeat it.
+	_, _, err = b.EditMessageReplyMarkupWithContext(bg, &gotgbot.EditMessageReplyMarkupOpts{
+		ChatId:      msg.Chat.Id,
+		MessageId:   msg.MessageId,
+		ReplyMarkup: derefMarkup(markup),
+	})
+	if err != nil && !isStaleMessageErr(err) {
+		s.logger.Debug().Err(err).Str("job_id", jobID).Msg("failed to remove show full answer button")
+	}
+	return nil
+}
+
+// sendPrivateAnswer hands over an answer the worker could not send privately
+// because the asker had not started the bot yet.
+func (s *Service) sendPrivateAnswer(ctx *ext.Context, b *gotgbot.Bot, jobID string) error {
+	if s.fullAnswers == nil || ctx.EffectiveUser == nil {
+		return s.sendMainMenu(ctx, b)
+	}
+	answer, found, err := s.fullAnswers.Get(context.Background(), jobID)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("job_id", jobID).Msg("failed to load private answer")
+		return s.reply(ctx, b, "Failed to load the answer. Try the link again later.")
+	}
+	if !found || answer.UserID != ctx.EffectiveUser.Id {
+		return s.reply(ctx, b, "This answer is no longer available.")
+	}
+	return s.reply(ctx, b, answer.Text)
+}
+
+// withoutExpandButton drops the "Show full answer" row, keeping the vote
+// buttons.
+func withoutExpandButton(markup *gotgbot.InlineKeyboardMarkup) *gotgbot.InlineKeyboardMarkup {
+	if markup == nil {
+		return nil
+	}
+	var rows [][]gotgbot.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		if len(row) > 0 && strings.HasPrefix(row[0].CallbackData, queue.ExpandCallbackPrefix) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func derefMarkup(markup *gotgbot.InlineKeyboardMarkup) gotgbot.InlineKeyboardMarkup {
+	if markup == nil {
+		return gotgbot.InlineKeyboardMarkup{}
+	}
+	return *markup
+}
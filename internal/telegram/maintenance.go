@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/tgsend"
+)
+
+const (
+	maintenanceUsage    = "Usage: /owner_maintenance <on [message]|off>"
+	maintenanceOverText = "Maintenance is over and the bot is back. Please send your question again."
+)
+
+// ownerMaintenance drains the queue: while on, ingress answers new questions
+// with a maintenance notice and workers finish what is already queued. When
+// it ends, the notices are edited to say the bot is back.
+func (s *Service) ownerMaintenance(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) || s.maintenance == nil {
+		return nil
+	}
+	mode, message := splitFirstWord(commandRemainder(ctx.EffectiveMessage.Text))
+	uid := ctx.EffectiveUser.Id
+	switch strings.ToLower(mode) {
+	case "":
+		state, on, err := s.maintenance.State(context.Background())
+		if err != nil {
+			return s.reply(ctx, b, "Failed to read the maintenance state.")
+		}
+		if !on {
+			return s.reply(ctx, b, "Maintenance is off.\n"+maintenanceUsage)
+		}
+		return s.reply(ctx, b, fmt.Sprintf("Maintenance is on since %s.\n%s", state.Since.Format(time.RFC3339), maintenanceUsage))
+	case "on":
+		ok, err := s.maintenance.Enable(context.Background(), queue.MaintenanceState{Since: s.now(), By: uid, Message: message})
+		if err != nil {
+			s.logger.Error().Err(err).Msg("enable maintenance failed")
+			return s.reply(ctx, b, "Failed to enable maintenance.")
+		}
+		if !ok {
+			return s.reply(ctx, b, "Maintenance is already on.")
+		}
+		_ = s.audit(0, uid, "maintenance_on", map[string]any{"message": message})
+		return s.reply(ctx, b, "Maintenance is on. New questions are refused; queued ones still get answered.")
+	case "off":
+		notices, was, err := s.maintenance.Disable(context.Background())
+		if err != nil {
+			s.logger.Error().Err(err).Msg("disable maintenance failed")
+			return s.reply(ctx, b, "Failed to disable maintenance.")
+		}
+		if !was {
+			return s.reply(ctx, b, "Maintenance is not on.")
+		}
+		_ = s.audit(0, uid, "maintenance_off", map[string]any{"notices": len(notices)})
+		go AnnounceMaintenanceOver(s.sender, s.logger, notices)
+		return s.reply(ctx, b, fmt.Sprintf("Maintenance is off. Updating %d maintenance notices.", len(notices)))
+	default:
+		return s.reply(ctx, b, maintenanceUsage)
+	}
+}
+
+// AnnounceMaintenanceOver edits the notices one by one, paced to stay under
+// Telegram's flood limits. The control plane uses it too.
+func AnnounceMaintenanceOver(sender *tgsend.Sender, logger zerolog.Logger, notices []queue.MaintenanceNotice) {
+	for _, n := range notices {
+		if err := sender.Edit(context.Background(), n.ChatID, n.MessageID, maintenanceOverText, nil); err != nil {
+			logger.Debug().Err(err).Int64("chat_id", n.ChatID).Msg("failed to edit maintenance notice")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// refuseDuringMaintenance answers the job's prompt with the maintenance
+// notice and reports true while maintenance is on. Redis errors let the
+// question through.
+func (s *Service) refuseDuringMaintenance(b *gotgbot.Bot, job queue.AskJob) bool {
+	if s.maintenance == nil {
+		return false
+	}
+	state, on, err := s.maintenance.State(context.Background())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to read maintenance state")
+		return false
+	}
+	if !on {
+		return false
+	}
+	text := "The bot is under maintenance. Questions already queued are still answered; please ask again later."
+	if state.Message != "" {
+		text += "\n" + state.Message
+	}
+	msg, err := s.sender.Send(context.Background(), tgsend.Message{ChatID: job.ChatID, ThreadID: job.ThreadID, ReplyTo: job.MessageID, Text: text})
+	if err != nil || msg == nil {
+		if err != nil {
+			s.logger.Debug().Err(err).Int64("chat_id", job.ChatID).Msg("failed to send maintenance notice")
+		}
+		return true
+	}
+	if err := s.maintenance.AddNotice(context.Background(), queue.MaintenanceNotice{ChatID: job.ChatID, MessageID: msg.MessageId}); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to remember maintenance notice")
+	}
+	return true
+}
+
+func (s *Service) maintenanceStatusLine() string {
+	if s.maintenance == nil {
+		return ""
+	}
+	state, on, err := s.maintenance.State(context.Background())
+	if err != nil || !on {
+		return ""
+	}
+	return "maintenance: on since " + state.Since.Format(time.RFC3339)
+}
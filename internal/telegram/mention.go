@@ -0,0 +1,123 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// matchMentionCandidate is a cheap pre-filter; mentionAsk checks that the
+// mention or reply actually targets this bot and that the chat opted in.
+func matchMentionCandidate(msg *gotgbot.Message) bool {
+	if msg == nil || msg.Chat.Type == "private" || msg.From == nil || msg.From.IsBot {
+		return false
+	}
+	if msg.Text == "" || strings.HasPrefix(msg.Text, "/") {
+		return false
+	}
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.IsBot {
+		return true
+	}
+	for _, e := range msg.Entities {
+		if e.Type == "mention" {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionAsk treats "@bot <question>" or a reply to the bot as /ask with the
+// default preset, for chats that enabled /mention_mode.
+func (s *Service) mentionAsk(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	if msg == nil || ctx.EffectiveChat == nil {
+		return nil
+	}
+	prompt, ok := mentionPrompt(msg, b.Id, b.Username)
+	if !ok {
+		return nil
+	}
+	enabled, err := s.store.GetMentionTrigger(context.Background(), msg.Chat.Id)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", msg.Chat.Id).Msg("failed to load mention mode")
+		return nil
+	}
+	if !enabled {
+		return nil
+	}
+	if prompt == "" {
+		return s.reply(ctx, b, "Ask a question after the mention, e.g. @"+b.Username+" what is a monad?")
+	}
+
+	if !s.allowRate(ctx.EffectiveChat.Id, userID(ctx), b, ctx) {
+		return nil
+	}
+
+	s.ensureChat(context.Background(), msg)
+	author, quoted := quotedContext(msg)
+	return s.enqueueAsk(b, ctx, queue.AskJob{
+		ChatID:       ctx.EffectiveChat.Id,
+		ChatType:     ctx.EffectiveChat.Type,
+		UserID:       userID(ctx),
+		MessageID:    msg.MessageId,
+		Prompt:       prompt,
+		QuotedText:   quoted,
+		QuotedAuthor: author,
+	})
+}
+
+// mentionPrompt reports whether msg addresses the bot and returns the text
+// with the bot's @mentions removed.
+func mentionPrompt(msg *gotgbot.Message, botID int64, botUsername string) (string, bool) {
+	addressed := msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.Id == botID
+	text := msg.Text
+	for _, e := range msg.ParseEntities() {
+		if e.Type == "mention" && strings.EqualFold(e.Text, "@"+botUsername) {
+			addressed = true
+			text = strings.ReplaceAll(text, e.Text, "")
+		}
+	}
+	if !addressed {
+		return "", false
+	}
+	return strings.Join(strings.Fields(text), " "), true
+}
+
+func (s *Service) mentionMode(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	arg := strings.ToLower(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())))
+	if arg == "" {
+		enabled, err := s.store.GetMentionTrigger(context.Background(), chatID)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to load mention mode.")
+		}
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		return s.reply(ctx, b, "Mention mode is "+state+". Usage: /mention_mode <on|off>")
+	}
+	if arg != "on" && arg != "off" {
+		return s.reply(ctx, b, "Usage: /mention_mode <on|off>")
+	}
+	if err := s.store.SetMentionTrigger(context.Background(), chatID, arg == "on"); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		return s.reply(ctx, b, "Failed to save mention mode.")
+	}
+	_ = s.audit(chatID, uid, "mention_mode", map[string]any{"enabled": arg == "on"})
+	if arg == "on" {
+		return s.reply(ctx, b, "Mention mode enabled: mention me or reply to my messages to ask the default preset.")
+	}
+	return s.reply(ctx, b, "Mention mode disabled.")
+}
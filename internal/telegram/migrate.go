@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+func matchChatMigration(msg *gotgbot.Message) bool {
+	return msg != nil && (msg.MigrateToChatId != 0 || msg.MigrateFromChatId != 0)
+}
+
+// chatMigrated moves providers, presets and settings to the new id when a
+// group is upgraded to a supergroup. Telegram posts a service message in both
+// chats; whichever arrives second finds nothing left to move.
+func (s *Service) chatMigrated(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	fromID, toID := msg.Chat.Id, msg.MigrateToChatId
+	if msg.MigrateFromChatId != 0 {
+		fromID, toID = msg.MigrateFromChatId, msg.Chat.Id
+	}
+	err := s.store.MigrateChat(context.Background(), fromID, toID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Int64("from_chat_id", fromID).Int64("to_chat_id", toID).Msg("chat migration failed")
+		return nil
+	}
+	s.logger.Info().Int64("from_chat_id", fromID).Int64("to_chat_id", toID).Msg("chat migrated to supergroup")
+	_ = s.audit(toID, 0, "chat_migrated", map[string]any{"from_chat_id": fromID})
+	return nil
+}
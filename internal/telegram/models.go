@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/redis/go-redis/v9"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/registry"
+	"hyprbot/internal/storage"
+)
+
+const (
+	modelsCacheTTL   = time.Hour
+	modelsFetchLimit = 20 * time.Second
+	maxModelsListed  = 100
+)
+
+func (s *Service) models(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, rest := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if name == "" {
+		return s.reply(ctx, b, "Usage: /models <provider> [refresh]")
+	}
+	refresh := strings.EqualFold(rest, "refresh")
+
+	p, err := s.store.GetProviderByName(context.Background(), chatID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Provider not found.")
+		}
+		return s.reply(ctx, b, "Failed to load provider.")
+	}
+
+	cacheKey := fmt.Sprintf("hyprbot:models:%d", p.ID)
+	var ids []string
+	if !refresh {
+		if raw, err := s.redis.Get(context.Background(), cacheKey).Bytes(); err == nil {
+			_ = json.Unmarshal(raw, &ids)
+		} else if err != redis.Nil {
+			s.logger.Warn().Err(err).Msg("failed to read models cache")
+		}
+	}
+
+	if ids == nil {
+		prov, err := registry.FromInstance(p, s.crypto, registry.BuildOptions{HTTPClient: s.providerHTTP})
+		if err != nil {
+			s.logger.Error().Err(err).Str("provider", name).Msg("build provider for model listing failed")
+			return s.reply(ctx, b, "Failed to initialize provider.")
+		}
+		lister, ok := prov.(providers.ModelLister)
+		if !ok {
+			return s.reply(ctx, b, fmt.Sprintf("Model listing is not supported for %s providers.", p.Kind))
+		}
+		fetchCtx, cancel := context.WithTimeout(context.Background(), modelsFetchLimit)
+		defer cancel()
+		ids, err = lister.ListModels(fetchCtx)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("provider", name).Msg("list models failed")
+			return s.reply(ctx, b, "Provider did not return a model list.")
+		}
+		if raw, err := json.Marshal(ids); err == nil {
+			_ = s.redis.Set(context.Background(), cacheKey, raw, modelsCacheTTL).Err()
+		}
+	}
+
+	if len(ids) == 0 {
+		return s.reply(ctx, b, "Provider reported no models.")
+	}
+	lines := []string{fmt.Sprintf("Models for %s (%d):", name, len(ids))}
+	for i, id := range ids {
+		if i == maxModelsListed {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(ids)-maxModelsListed))
+			break
+		}
+		lines = append(lines, "- "+id)
+	}
+	text := strings.Join(lines, "\n")
+	if r := []rune(text); len(r) > 4000 {
+		text = string(r[:4000])
+	}
+	return s.reply(ctx, b, text)
+}
+
+// verifyWizardKey lists the models of the provider the wizard is about to
+// save, the cheapest call that needs a valid key. It returns nil when the
+// check is disabled or the provider has no model list, and an error wrapping
+// providers.ErrUnauthorized when the key was refused.
+func (s *Service) verifyWizardKey(state *llmWizardState, apiKey string) error {
+	if !s.verifyKeys || apiKey == "" {
+		return nil
+	}
+	inst, err := s.wizardInstance(state, apiKey)
+	if err != nil {
+		return err
+	}
+	prov, err := registry.FromInstance(inst, s.crypto, registry.BuildOptions{HTTPClient: s.providerHTTP})
+	if err != nil {
+		return err
+	}
+	lister, ok := prov.(providers.ModelLister)
+	if !ok {
+		return nil
+	}
+	fetchCtx, cancel := context.WithTimeout(context.Background(), modelsFetchLimit)
+	defer cancel()
+	if _, err := lister.ListModels(fetchCtx); err != nil {
+		s.logger.Warn().Err(err).Str("provider", state.Name).Msg("wizard key check failed")
+		return err
+	}
+	return nil
+}
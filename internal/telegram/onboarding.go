@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+const (
+	cbOnboardShared = cbPrefix + "onb_shared"
+	cbOnboardCustom = cbPrefix + "onb_custom"
+)
+
+// welcome greets a group the bot was just added to and offers the two ways
+// to get going: the owner's shared fallback preset, if there is one, or the
+// provider wizard.
+func (s *Service) welcome(chatID int64) {
+	bg := context.Background()
+	if err := s.store.SetChatSetting(bg, chatID, storage.SettingOnboarding, storage.OnboardingPending); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to mark onboarding pending")
+	}
+	shared := s.hasSharedPreset(bg)
+	lines := []string{
+		"Hi! I answer questions with the AI providers this group sets up.",
+		"",
+	}
+	if shared {
+		lines = append(lines, "Quick setup: start right away with the bot's shared provider, with a limited number of questions per hour.")
+	}
+	lines = append(lines,
+		"Custom setup: an admin connects the group's own provider and API key in a private chat with me.",
+		"",
+		"Anyone can then ask with /ask <question>.",
+	)
+	var rows [][]gotgbot.InlineKeyboardButton
+	if shared {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Quick setup with shared provider", CallbackData: cbOnboardShared}})
+	}
+	rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Custom setup", CallbackData: cbOnboardCustom}})
+	if s.docsURL != "" {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Docs", Url: s.docsURL}})
+	}
+	_, err := s.sender.Send(bg, tgsend.Message{
+		ChatID: chatID,
+		Text:   strings.Join(lines, "\n"),
+		Markup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	if err != nil {
+		s.logger.Debug().Err(err).Int64("chat_id", chatID).Msg("failed to send welcome message")
+	}
+}
+
+func (s *Service) hasSharedPreset(ctx context.Context) bool {
+	if s.fallback == nil {
+		return false
+	}
+	_, ok, err := s.fallback.Get(ctx)
+	return err == nil && ok
+}
+
+// onboardShared settles a new group on the owner's fallback preset. Nothing
+// has to be configured: the worker uses it for chats without presets.
+func (s *Service) onboardShared(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		s.answerCallback(b, ctx, "Only chat admins can run the setup.", true)
+		return nil
+	}
+	bg := context.Background()
+	if !s.hasSharedPreset(bg) {
+		s.answerCallback(b, ctx, "The shared provider is not available anymore. Use Custom setup.", true)
+		return nil
+	}
+	if err := s.store.SetChatSetting(bg, chatID, storage.SettingOnboarding, storage.OnboardingShared); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to store onboarding state")
+	}
+	return s.editOrReplyCallback(ctx, b, strings.Join([]string{
+		"Quick setup done. /ask <question> now answers with the bot's shared provider, within a limited number of questions per hour.",
+		"For your own models and limits, an admin can add a provider any time with /llm_add.",
+	}, "\n"), nil)
+}
+
+// onboardCustom starts the provider wizard as /llm_add does.
+func (s *Service) onboardCustom(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		s.answerCallback(b, ctx, "Only chat admins can run the setup.", true)
+		return nil
+	}
+	if err := s.store.SetChatSetting(context.Background(), chatID, storage.SettingOnboarding, storage.OnboardingCustom); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to store onboarding state")
+	}
+	if err := s.llmAdd(b, ctx); err != nil {
+		return err
+	}
+	s.answerCallback(b, ctx, "Continue in the private chat.", false)
+	return nil
+}
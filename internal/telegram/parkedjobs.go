@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/queue"
+)
+
+// pickPreset re-enqueues a job the worker parked because its preset was
+// missing, using the preset the asker tapped. data is "<token>:<index>".
+func (s *Service) pickPreset(b *gotgbot.Bot, ctx *ext.Context, data string) error {
+	token, rawIdx, _ := strings.Cut(data, ":")
+	idx, err := strconv.Atoi(rawIdx)
+	if err != nil {
+		s.answerCallback(b, ctx, "Unknown preset.", true)
+		return nil
+	}
+	pick, ok := s.loadPick(b, ctx, token)
+	if !ok {
+		return nil
+	}
+	if idx < 0 || idx >= len(pick.Presets) {
+		s.answerCallback(b, ctx, "Unknown preset.", true)
+		return nil
+	}
+	if taken, err := s.picks.Delete(context.Background(), token); err != nil || !taken {
+		return nil
+	}
+	preset := pick.Presets[idx]
+	pick.Job.PresetName = preset
+	_ = s.editOrReplyCallback(ctx, b, "Answering with preset "+preset+".", nil)
+	return s.requeueParked(b, ctx, pick.Job)
+}
+
+// retryJob re-enqueues a job that failed terminally. It counts against the
+// asker's rate limit like a new /ask.
+func (s *Service) retryJob(b *gotgbot.Bot, ctx *ext.Context, token string) error {
+	pick, ok := s.loadPick(b, ctx, token)
+	if !ok {
+		return nil
+	}
+	if !s.allowRate(pick.Job.ChatID, pick.Job.UserID, b, ctx) {
+		return nil
+	}
+	if taken, err := s.picks.Delete(context.Background(), token); err != nil || !taken {
+		return nil
+	}
+	_ = s.editOrReplyCallback(ctx, b, "Retrying...", nil)
+	return s.requeueParked(b, ctx, pick.Job)
+}
+
+// loadPick fetches a parked job and checks that the tap comes from the asker.
+func (s *Service) loadPick(b *gotgbot.Bot, ctx *ext.Context, token string) (queue.PresetPick, bool) {
+	if s.picks == nil || ctx.EffectiveUser == nil {
+		return queue.PresetPick{}, false
+	}
+	pick, found, err := s.picks.Get(context.Background(), token)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to load parked job")
+		s.answerCallback(b, ctx, "Failed to load the question. Ask again.", true)
+		return queue.PresetPick{}, false
+	}
+	if !found {
+		_ = s.editOrReplyCallback(ctx, b, "This question expired. Ask again.", nil)
+		return queue.PresetPick{}, false
+	}
+	if pick.Job.UserID != ctx.EffectiveUser.Id {
+		s.answerCallback(b, ctx, "Only the person who asked can do this.", true)
+		return queue.PresetPick{}, false
+	}
+	return pick, true
+}
+
+// requeueParked enqueues a parked job as a fresh one with its own
+// acknowledgement and retry budget.
+func (s *Service) requeueParked(b *gotgbot.Bot, ctx *ext.Context, job queue.AskJob) error {
+	job.JobID = ""
+	job.StatusMessageID = 0
+	job.AckReaction = false
+	job.Attempts = 0
+	job.EnqueuedAt = time.Time{}
+	return s.enqueueAsk(b, ctx, job)
+}
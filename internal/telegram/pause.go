@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/redis/go-redis/v9"
+
+	"hyprbot/internal/storage"
+)
+
+const defaultPauseCacheTTL = 10 * time.Minute
+
+// ChatPauses answers whether the bot is paused in a chat. The processor asks
+// for every update, so the chat setting is cached in Redis; /bot_off and
+// /bot_on write through so every replica sees the change at once.
+type ChatPauses struct {
+	redis *redis.Client
+	store *storage.Store
+	ttl   time.Duration
+}
+
+func NewChatPauses(rdb *redis.Client, store *storage.Store, ttl time.Duration) *ChatPauses {
+	if ttl <= 0 {
+		ttl = defaultPauseCacheTTL
+	}
+	return &ChatPauses{redis: rdb, store: store, ttl: ttl}
+}
+
+func (p *ChatPauses) Paused(ctx context.Context, chatID int64) (bool, error) {
+	if v, err := p.redis.Get(ctx, pauseCacheKey(chatID)).Result(); err == nil {
+		return v == "1", nil
+	} else if err != redis.Nil {
+		return false, fmt.Errorf("read pause cache: %w", err)
+	}
+	value, err := p.store.GetChatSetting(ctx, chatID, storage.SettingPaused)
+	if err != nil {
+		return false, err
+	}
+	paused := value == storage.SettingOn
+	_ = p.redis.Set(ctx, pauseCacheKey(chatID), pauseCacheValue(paused), p.ttl).Err()
+	return paused, nil
+}
+
+func (p *ChatPauses) set(ctx context.Context, chatID int64, paused bool) error {
+	value := storage.SettingOff
+	if paused {
+		value = storage.SettingOn
+	}
+	if err := p.store.SetChatSetting(ctx, chatID, storage.SettingPaused, value); err != nil {
+		return err
+	}
+	return p.redis.Set(ctx, pauseCacheKey(chatID), pauseCacheValue(paused), p.ttl).Err()
+}
+
+func pauseCacheKey(chatID int64) string {
+	return fmt.Sprintf("hyprbot:paused:%d", chatID)
+}
+
+func pauseCacheValue(paused bool) string {
+	if paused {
+		return "1"
+	}
+	return "0"
+}
+
+// passesPause lets through what a paused chat still needs: /bot_on, and the
+// membership and migration updates that keep stored state correct.
+func passesPause(ctx *ext.Context) bool {
+	if ctx.MyChatMember != nil || ctx.ChatMember != nil {
+		return true
+	}
+	msg := ctx.Message
+	if msg == nil {
+		return false
+	}
+	if msg.MigrateToChatId != 0 || msg.MigrateFromChatId != 0 {
+		return true
+	}
+	cmd, _ := splitFirstWord(msg.Text)
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return cmd == "/bot_on"
+}
+
+func (s *Service) botOff(b *gotgbot.Bot, ctx *ext.Context) error {
+	return s.setPaused(b, ctx, true)
+}
+
+func (s *Service) botOn(b *gotgbot.Bot, ctx *ext.Context) error {
+	return s.setPaused(b, ctx, false)
+}
+
+func (s *Service) setPaused(b *gotgbot.Bot, ctx *ext.Context, paused bool) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok || s.pauses == nil {
+		return nil
+	}
+	if err := s.pauses.set(context.Background(), chatID, paused); err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("set chat pause failed")
+		return s.reply(ctx, b, "Failed to update the bot state.")
+	}
+	if paused {
+		_ = s.audit(chatID, uid, "bot_off", nil)
+		return s.reply(ctx, b, "Bot paused in this chat. It ignores everything except /bot_on from an admin.")
+	}
+	_ = s.audit(chatID, uid, "bot_on", nil)
+	return s.reply(ctx, b, "Bot resumed in this chat.")
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const maxPersonaRunes = 1000
+
+func (s *Service) personaSet(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	text := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if text == "" {
+		current, err := s.store.GetChatPersona(context.Background(), chatID)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to load persona.")
+		}
+		if current == "" {
+			return s.reply(ctx, b, "No persona set. Usage: /persona_set <text> (prepended to every preset's system prompt)")
+		}
+		return s.reply(ctx, b, "Persona:\n"+current)
+	}
+	if n := len([]rune(text)); n > maxPersonaRunes {
+		return s.reply(ctx, b, fmt.Sprintf("Persona is too long (%d characters, max %d).", n, maxPersonaRunes))
+	}
+	return s.savePersona(b, ctx, chatID, uid, text)
+}
+
+func (s *Service) personaClear(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	return s.savePersona(b, ctx, chatID, uid, "")
+}
+
+func (s *Service) savePersona(b *gotgbot.Bot, ctx *ext.Context, chatID, uid int64, persona string) error {
+	if err := s.store.SetChatPersona(context.Background(), chatID, persona); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		s.logger.Error().Err(err).Msg("set chat persona failed")
+		return s.reply(ctx, b, "Failed to save persona.")
+	}
+	_ = s.audit(chatID, uid, "chat_persona", map[string]any{"length": len([]rune(persona))})
+	if persona == "" {
+		return s.reply(ctx, b, "Persona cleared.")
+	}
+	return s.reply(ctx, b, "Persona saved. It is prepended to every preset's system prompt in this chat.")
+}
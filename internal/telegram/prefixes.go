@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const maxCommandPrefixes = 5
+
+// prefixRouterGroup runs before the default handler group so rewritten
+// messages are matched by the regular command handlers.
+const prefixRouterGroup = -1
+
+const (
+	// prefixCacheTTL bounds how long another node keeps serving prefixes a
+	// chat changed; the node that handled /prefixes drops its entry at once.
+	prefixCacheTTL = time.Minute
+	// prefixCacheMax caps the cached chats; the cache starts over past it.
+	prefixCacheMax = 10000
+)
+
+// prefixCache keeps each chat's alias prefixes in memory, so messages that
+// merely start with punctuation, a mention or an emoji do not each cost a
+// database query.
+type prefixCache struct {
+	mu      sync.Mutex
+	entries map[int64]prefixEntry
+}
+
+type prefixEntry struct {
+	prefixes string
+	expires  time.Time
+}
+
+func newPrefixCache() *prefixCache {
+	return &prefixCache{entries: map[int64]prefixEntry{}}
+}
+
+func (c *prefixCache) get(chatID int64, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[chatID]
+	if !ok || now.After(e.expires) {
+		return "", false
+	}
+	return e.prefixes, true
+}
+
+func (c *prefixCache) set(chatID int64, prefixes string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= prefixCacheMax {
+		c.entries = map[int64]prefixEntry{}
+	}
+	c.entries[chatID] = prefixEntry{prefixes: prefixes, expires: now.Add(prefixCacheTTL)}
+}
+
+func (c *prefixCache) invalidate(chatID int64) {
+	c.mu.Lock()
+	delete(c.entries, chatID)
+	c.mu.Unlock()
+}
+
+// chatPrefixes returns the chat's alias prefixes, from the cache when it
+// can. A failed load counts as none and is not cached.
+func (s *Service) chatPrefixes(chatID int64) string {
+	now := s.now()
+	if prefixes, ok := s.prefixCache.get(chatID, now); ok {
+		return prefixes
+	}
+	prefixes, err := s.store.GetCommandPrefixes(context.Background(), chatID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to load command prefixes")
+		return ""
+	}
+	s.prefixCache.set(chatID, prefixes, now)
+	return prefixes
+}
+
+func (s *Service) matchAliasPrefix(msg *gotgbot.Message) bool {
+	if msg == nil || msg.Chat.Type == "private" {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(msg.Text)
+	if !isAliasPrefixRune(r) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(msg.Text[size:])
+	return unicode.IsLetter(next) && strings.ContainsRune(s.chatPrefixes(msg.Chat.Id), r)
+}
+
+// routeAliasPrefix rewrites "!ask foo" into "/ask foo" for chats that enabled
+// "!" as a command prefix; matchAliasPrefix checked the prefix. The update
+// then continues to the command handlers. Entity offsets and lengths count
+// UTF-16 code units, so a prefix outside the BMP shifts every entity by one.
+func (s *Service) routeAliasPrefix(_ *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.Message
+	if msg == nil {
+		return nil
+	}
+	r, size := utf8.DecodeRuneInString(msg.Text)
+	msg.Text = "/" + msg.Text[size:]
+	shift := int64(utf16.RuneLen(r)) - 1
+	for i := range msg.Entities {
+		if msg.Entities[i].Offset == 0 {
+			msg.Entities[i].Length -= shift
+		} else {
+			msg.Entities[i].Offset -= shift
+		}
+	}
+	cmdLen := utf16Len(strings.Fields(msg.Text)[0])
+	if len(msg.Entities) == 0 || msg.Entities[0].Offset != 0 || msg.Entities[0].Type != "bot_command" {
+		msg.Entities = append([]gotgbot.MessageEntity{{Type: "bot_command", Offset: 0, Length: cmdLen}}, msg.Entities...)
+	}
+	return nil
+}
+
+func utf16Len(s string) int64 {
+	var n int64
+	for _, r := range s {
+		n += int64(utf16.RuneLen(r))
+	}
+	return n
+}
+
+func (s *Service) prefixes(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	arg := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if arg == "" {
+		current, err := s.store.GetCommandPrefixes(context.Background(), chatID)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to load command prefixes.")
+		}
+		if current == "" {
+			return s.reply(ctx, b, "No alias prefixes configured. Usage: /prefixes <chars> (example: /prefixes !.) or /prefixes off")
+		}
+		return s.reply(ctx, b, "Alias prefixes: "+current+"\nExample: "+string([]rune(current)[0])+"ask <text>")
+	}
+
+	value := ""
+	if !strings.EqualFold(arg, "off") {
+		normalized, ok := normalizeCommandPrefixes(arg)
+		if !ok {
+			return s.reply(ctx, b, "Prefixes must be up to 5 punctuation characters other than '/' (example: /prefixes !.)")
+		}
+		value = normalized
+	}
+	if err := s.store.SetCommandPrefixes(context.Background(), chatID, value); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		s.logger.Error().Err(err).Msg("set command prefixes failed")
+		return s.reply(ctx, b, "Failed to save command prefixes.")
+	}
+	s.prefixCache.invalidate(chatID)
+	_ = s.audit(chatID, uid, "command_prefixes", map[string]any{"prefixes": value})
+	if value == "" {
+		return s.reply(ctx, b, "Alias prefixes disabled.")
+	}
+	return s.reply(ctx, b, "Alias prefixes updated: "+value)
+}
+
+func normalizeCommandPrefixes(raw string) (string, bool) {
+	seen := map[rune]bool{}
+	out := make([]rune, 0, maxCommandPrefixes)
+	for _, r := range strings.Join(strings.Fields(raw), "") {
+		if !isAliasPrefixRune(r) {
+			return "", false
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		out = append(out, r)
+	}
+	if len(out) == 0 || len(out) > maxCommandPrefixes {
+		return "", false
+	}
+	return string(out), true
+}
+
+func isAliasPrefixRune(r rune) bool {
+	if r == '/' || r == utf8.RuneError {
+		return false
+	}
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
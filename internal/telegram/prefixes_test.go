@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/storage"
+)
+
+func openTestStore(t *testing.T) *storage.Store {
+	t.Helper()
+	s, err := storage.Open(context.Background(), "sqlite", filepath.Join(t.TempDir(), "bot.db"), true, "")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func groupMessage(chatID int64, text string) *gotgbot.Message {
+	return &gotgbot.Message{Chat: gotgbot.Chat{Id: chatID, Type: "supergroup"}, Text: text}
+}
+
+func TestMatchAliasPrefixCachesPrefixes(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	if err := store.SetCommandPrefixes(ctx, -100, "!"); err != nil {
+		t.Fatalf("set prefixes: %v", err)
+	}
+	s := &Service{store: store, prefixCache: newPrefixCache(), logger: zerolog.Nop()}
+
+	if !s.matchAliasPrefix(groupMessage(-100, "!ask hi")) {
+		t.Fatal("expected !ask to match")
+	}
+	// With the database gone, the cached prefixes still answer.
+	_ = store.Close()
+	for text, want := range map[string]bool{"!ask hi": true, "@bob hi": false, "#tag": false, "plain": false} {
+		if got := s.matchAliasPrefix(groupMessage(-100, text)); got != want {
+			t.Errorf("match %q = %v, want %v", text, got, want)
+		}
+	}
+	if s.matchAliasPrefix(groupMessage(-200, "!ask hi")) {
+		t.Error("expected a failed load to match nothing")
+	}
+
+	s.prefixCache.invalidate(-100)
+	if s.matchAliasPrefix(groupMessage(-100, "!ask hi")) {
+		t.Error("expected the invalidated entry to be loaded again")
+	}
+}
+
+func TestRouteAliasPrefixShiftsEntities(t *testing.T) {
+	bot, _ := newTestBot()
+	for prefix, shift := range map[string]int64{"!": 0, "\u2605": 0, "\U0001F916": 1} {
+		text := prefix + "ask hi @bob"
+		msg := groupMessage(-100, text)
+		// Offsets in UTF-16 code units: "@bob" starts after the prefix and
+		// "ask hi ".
+		mention := int64(len("ask hi ")) + 1 + shift
+		msg.Entities = []gotgbot.MessageEntity{{Type: "mention", Offset: mention, Length: 4}}
+		ctx := ext.NewContext(bot, &gotgbot.Update{Message: msg}, nil)
+
+		if err := (&Service{}).routeAliasPrefix(bot, ctx); err != nil {
+			t.Fatalf("route %q: %v", text, err)
+		}
+		if msg.Text != "/ask hi @bob" {
+			t.Errorf("route %q: text %q", text, msg.Text)
+		}
+		want := []gotgbot.MessageEntity{
+			{Type: "bot_command", Offset: 0, Length: 4},
+			{Type: "mention", Offset: 8, Length: 4},
+		}
+		if !reflect.DeepEqual(msg.Entities, want) {
+			t.Errorf("route %q: entities %+v, want %+v", text, msg.Entities, want)
+		}
+	}
+}
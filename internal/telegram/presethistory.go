@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const presetHistorySnippetRunes = 80
+
+func (s *Service) presetHistory(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name := strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if name == "" {
+		return s.reply(ctx, b, "Usage: /preset_history <name>")
+	}
+	revs, err := s.store.ListPresetRevisions(context.Background(), chatID, name)
+	if err != nil {
+		s.logger.Error().Err(err).Str("preset", name).Msg("list preset revisions failed")
+		return s.reply(ctx, b, "Failed to load preset history.")
+	}
+	providerNames := map[int64]string{}
+	if providers, err := s.store.ListProviders(context.Background(), chatID); err == nil {
+		for _, p := range providers {
+			providerNames[p.ID] = p.Name
+		}
+	}
+	describe := func(providerID int64, model, systemPrompt, params string) []string {
+		provider, ok := providerNames[providerID]
+		if !ok {
+			provider = "deleted provider"
+		}
+		return []string{
+			fmt.Sprintf("  %s via %s, params %s", model, provider, params),
+			"  prompt: " + snippet(systemPrompt, presetHistorySnippetRunes),
+		}
+	}
+
+	lines := []string{"History of preset " + name + ":"}
+	current, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name)
+	switch {
+	case err == nil:
+		lines = append(lines, "current:")
+		lines = append(lines, describe(current.Preset.ProviderInstanceID, current.Preset.Model, current.Preset.SystemPrompt, current.Preset.ParamsJSON)...)
+	case errors.Is(err, storage.ErrNotFound):
+		if len(revs) == 0 {
+			return s.reply(ctx, b, "Preset not found.")
+		}
+		lines = append(lines, "current: deleted")
+	default:
+		s.logger.Error().Err(err).Str("preset", name).Msg("get preset failed")
+		return s.reply(ctx, b, "Failed to load preset.")
+	}
+	if len(revs) == 0 {
+		lines = append(lines, "No earlier revisions.")
+	}
+	for _, r := range revs {
+		lines = append(lines, fmt.Sprintf("r%d, replaced %s UTC:", r.Rev, r.CreatedAt.UTC().Format("2006-01-02 15:04")))
+		lines = append(lines, describe(r.ProviderInstanceID, r.Model, r.SystemPrompt, r.ParamsJSON)...)
+	}
+	if len(revs) > 0 {
+		lines = append(lines, "", "Restore one with /preset_rollback "+name+" <rev>.")
+	}
+	out := strings.Join(lines, "\n")
+	if r := []rune(out); len(r) > 4000 {
+		out = string(r[:4000])
+	}
+	return s.reply(ctx, b, out)
+}
+
+func (s *Service) presetRollback(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, userID, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, rest := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	rev, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(rest), "r"))
+	if name == "" || err != nil || rev <= 0 {
+		return s.reply(ctx, b, "Usage: /preset_rollback <name> <rev> (see /preset_history <name>)")
+	}
+	if err := s.store.RollbackPreset(context.Background(), chatID, name, rev); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return s.reply(ctx, b, fmt.Sprintf("Preset %s has no revision %d.", name, rev))
+		case errors.Is(err, storage.ErrProviderGone):
+			return s.reply(ctx, b, "The provider used by that revision was deleted. Re-add it or pick another revision.")
+		}
+		s.logger.Error().Err(err).Str("preset", name).Msg("rollback preset failed")
+		return s.reply(ctx, b, "Failed to roll back preset.")
+	}
+	_ = s.audit(chatID, userID, "preset_rollback", map[string]any{"name": name, "rev": rev})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("rolled preset %s back to revision %d", name, rev))
+	return s.reply(ctx, b, fmt.Sprintf("Preset %s restored to revision %d. The replaced configuration was kept as a new revision.", name, rev))
+}
+
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	if text == "" {
+		return "(empty)"
+	}
+	return text
+}
@@ -0,0 +1,166 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/jsonschema"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/storage"
+)
+
+const aiPresetParamUsage = "Usage: /ai_preset_param <name> [key] [value|-]\n" +
+	"keys: max_tokens, context_window (tokens the model accepts, default from the model name), temperature, reasoning_effort (minimal|low|medium|high), thinking_budget (tokens, anthropic), show_reasoning (on|off),\n" +
+	"response_format (text|json_object|json_schema), json_schema (JSON Schema object),\n" +
+	"route_code, route_chat, route_long (preset answering that class of prompt, makes this preset a router), route_mode (heuristic|model)"
+
+func (s *Service) aiPresetParam(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, rest := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	key, value := splitFirstWord(rest)
+	value = strings.TrimSpace(value)
+	if name == "" || (key != "" && value == "") {
+		return s.reply(ctx, b, aiPresetParamUsage)
+	}
+
+	pp, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Preset not found.")
+		}
+		s.logger.Error().Err(err).Msg("get preset failed")
+		return s.reply(ctx, b, "Failed to load preset.")
+	}
+	params := map[string]any{}
+	if raw := strings.TrimSpace(pp.Preset.ParamsJSON); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &params); err != nil {
+			s.logger.Error().Err(err).Str("preset", name).Msg("invalid preset params json")
+			return s.reply(ctx, b, "Preset params are corrupted.")
+		}
+	}
+
+	if key == "" {
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lines := []string{"Params for " + name + ":"}
+		for _, k := range keys {
+			v, _ := json.Marshal(params[k])
+			lines = append(lines, fmt.Sprintf("%s: %s", k, v))
+		}
+		return s.reply(ctx, b, strings.Join(lines, "\n"))
+	}
+
+	if value == "-" {
+		delete(params, key)
+	} else {
+		parsed, err := parsePresetParam(key, value)
+		if err != nil {
+			return s.reply(ctx, b, "Invalid value: "+err.Error()+"\n"+aiPresetParamUsage)
+		}
+		if strings.HasPrefix(key, "route_") && key != "route_mode" {
+			if value == name {
+				return s.reply(ctx, b, "A router cannot route to itself.")
+			}
+			if _, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, value); err != nil {
+				return s.reply(ctx, b, "Preset "+value+" not found.")
+			}
+		}
+		params[key] = parsed
+	}
+
+	paramsJSON, _ := json.Marshal(params)
+	if err := s.store.SetPresetParams(context.Background(), chatID, name, string(paramsJSON)); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Preset not found.")
+		}
+		s.logger.Error().Err(err).Msg("set preset params failed")
+		return s.reply(ctx, b, "Failed to save preset.")
+	}
+	_ = s.audit(chatID, uid, "preset_param", map[string]any{"name": name, "key": key})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("changed %s of preset %s", key, name))
+	return s.reply(ctx, b, fmt.Sprintf("Preset %s: %s updated.", name, key))
+}
+
+func parsePresetParam(key, value string) (any, error) {
+	switch key {
+	case "max_tokens":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 200000 {
+			return nil, fmt.Errorf("max_tokens must be between 1 and 200000")
+		}
+		return n, nil
+	case "context_window":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1024 || n > 10000000 {
+			return nil, fmt.Errorf("context_window must be between 1024 and 10000000")
+		}
+		return n, nil
+	case "temperature":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 2 {
+			return nil, fmt.Errorf("temperature must be between 0 and 2")
+		}
+		return f, nil
+	case "reasoning_effort":
+		v := strings.ToLower(value)
+		switch v {
+		case "minimal", "low", "medium", "high":
+			return v, nil
+		}
+		return nil, fmt.Errorf("reasoning_effort must be minimal, low, medium or high")
+	case "thinking_budget":
+		// Anthropic rejects budgets below 1024 tokens.
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1024 || n > 128000 {
+			return nil, fmt.Errorf("thinking_budget must be between 1024 and 128000")
+		}
+		return n, nil
+	case "show_reasoning":
+		switch strings.ToLower(value) {
+		case "on", "true", "yes", "1":
+			return true, nil
+		case "off", "false", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("show_reasoning must be on or off")
+	case "response_format":
+		v := strings.ToLower(value)
+		switch v {
+		case "text", providers.ResponseFormatJSONObject, providers.ResponseFormatJSONSchema:
+			return v, nil
+		}
+		return nil, fmt.Errorf("response_format must be text, json_object or json_schema")
+	case "route_code", "route_chat", "route_long":
+		if strings.ContainsAny(value, " \t\n") {
+			return nil, fmt.Errorf("%s must be a preset name", key)
+		}
+		return value, nil
+	case "route_mode":
+		v := strings.ToLower(value)
+		switch v {
+		case "heuristic", "model":
+			return v, nil
+		}
+		return nil, fmt.Errorf("route_mode must be heuristic or model")
+	case "json_schema":
+		if _, err := jsonschema.Parse([]byte(value)); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(value), nil
+	}
+	return nil, fmt.Errorf("unknown param %s", key)
+}
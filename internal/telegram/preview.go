@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/storage"
+)
+
+// preview shows the system and user prompt a preset would receive, with the
+// chat persona, reply language and quoted context applied, without calling
+// the provider.
+func (s *Service) preview(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, _, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, text := splitFirstWord(strings.TrimSpace(commandRemainder(ctx.EffectiveMessage.GetText())))
+	if name == "" || text == "" {
+		return s.reply(ctx, b, "Usage: /preview <preset> <text>")
+	}
+	pp, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Preset not found.")
+		}
+		return s.reply(ctx, b, "Failed to read preset.")
+	}
+	settings, err := s.store.GetChatSettings(context.Background(), chatID)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("get chat settings failed")
+		return s.reply(ctx, b, "Failed to load chat settings.")
+	}
+
+	author, quoted := quotedContext(ctx.EffectiveMessage)
+	system := prompt.System(pp.Preset.SystemPrompt, settings)
+	if settings.Get(storage.SettingKnowledge) != storage.SettingOff {
+		if texts, err := s.store.KnowledgeTexts(context.Background(), chatID); err == nil {
+			system = prompt.WithKnowledge(system, texts)
+		}
+	}
+	if system == "" {
+		system = "(empty)"
+	}
+	out := strings.Join([]string{
+		fmt.Sprintf("Preview for %s (%s via %s). Nothing was sent to the provider.", pp.Preset.Name, pp.Preset.Model, pp.Provider.Name),
+		"",
+		"System prompt:",
+		system,
+		"",
+		"User prompt:",
+		prompt.User(text, author, quoted),
+	}, "\n")
+	if r := []rune(out); len(r) > 4000 {
+		out = string(r[:4000])
+	}
+	return s.reply(ctx, b, out)
+}
@@ -14,6 +14,8 @@ import (
 type Processor struct {
 	Base          ext.BaseProcessor
 	Dedupe        *queue.UpdateDeduplicator
+	Activity      *ActivityRecorder
+	Pauses        *ChatPauses
 	Metrics       *metrics.Metrics
 	Logger        zerolog.Logger
 	AllowedUserID int64
@@ -30,11 +32,35 @@ func (p Processor) ProcessUpdate(d *ext.Dispatcher, b *gotgbot.Bot, ctx *ext.Con
 	}
 	if p.Dedupe != nil {
 		first, err := p.Dedupe.MarkFirst(context.Background(), ctx.UpdateId)
-		if err != nil {
+		result := "first"
+		switch {
+		case err != nil:
+			result = "error"
 			p.Logger.Error().Err(err).Int64("update_id", ctx.UpdateId).Msg("failed to dedupe update")
-		} else if !first {
+		case !first:
+			result = "duplicate"
+		}
+		if p.Metrics != nil {
+			p.Metrics.UpdateDedupe.WithLabelValues(result).Inc()
+		}
+		if result == "duplicate" {
 			return nil
 		}
 	}
+	if p.Pauses != nil && ctx.EffectiveChat != nil && !passesPause(ctx) {
+		paused, err := p.Pauses.Paused(context.Background(), ctx.EffectiveChat.Id)
+		if err != nil {
+			p.Logger.Warn().Err(err).Int64("chat_id", ctx.EffectiveChat.Id).Msg("failed to check chat pause")
+		} else if paused {
+			return nil
+		}
+	}
+	if ctx.EffectiveUser != nil {
+		var chatID int64
+		if ctx.EffectiveChat != nil {
+			chatID = ctx.EffectiveChat.Id
+		}
+		p.Activity.Record(chatID, ctx.EffectiveUser, ctx.Message != nil)
+	}
 	return p.Base.ProcessUpdate(d, b, ctx)
 }
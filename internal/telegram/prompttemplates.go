@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+// promptTemplate is a built-in preset. Bump Version when the prompt changes;
+// it is recorded in params_json so existing presets can be told apart.
+type promptTemplate struct {
+	Version      int
+	Description  string
+	SystemPrompt string
+	Temperature  float64
+}
+
+var promptTemplates = map[string]promptTemplate{
+	"translator": {
+		Version:     1,
+		Description: "translate between languages, keeping tone and formatting",
+		SystemPrompt: "You are a professional translator. If the text is in English, translate it into the language the user asks for " +
+			"(Russian when unspecified); otherwise translate it into English. Preserve tone, formatting, names and code. " +
+			"Reply with the translation only.",
+		Temperature: 0.2,
+	},
+	"coder": {
+		Version:     1,
+		Description: "programming help with short, runnable answers",
+		SystemPrompt: "You are a senior software engineer. Answer programming questions with correct, idiomatic code and a brief explanation. " +
+			"Prefer the language used in the question, point out bugs and edge cases, and keep answers short enough for a chat message.",
+		Temperature: 0.2,
+	},
+	"summarizer": {
+		Version:     1,
+		Description: "condense text or threads into key points",
+		SystemPrompt: "You summarize text for a busy group chat. Return at most 5 concise bullet points with the key facts, decisions " +
+			"and open questions. Do not add information that is not in the text. Answer in the language of the text.",
+		Temperature: 0.3,
+	},
+	"proofreader": {
+		Version:     1,
+		Description: "fix grammar, spelling and style",
+		SystemPrompt: "You are a careful proofreader. Correct grammar, spelling, punctuation and awkward phrasing while keeping the author's " +
+			"voice and language. Reply with the corrected text, then a short list of the most important changes.",
+		Temperature: 0.2,
+	},
+}
+
+func promptTemplateNames() []string {
+	names := make([]string, 0, len(promptTemplates))
+	for name := range promptTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func promptTemplatesText() string {
+	lines := []string{"Built-in templates:"}
+	for _, name := range promptTemplateNames() {
+		lines = append(lines, fmt.Sprintf("- %s: %s", name, promptTemplates[name].Description))
+	}
+	lines = append(lines, "", "Usage: /preset_add_template <template> <provider> <model> [preset_name]")
+	return strings.Join(lines, "\n")
+}
+
+func (s *Service) presetAddTemplate(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, userID, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if len(args) == 0 {
+		return s.reply(ctx, b, promptTemplatesText())
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return s.reply(ctx, b, "Usage: /preset_add_template <template> <provider> <model> [preset_name]")
+	}
+	templateName, providerName, model := strings.ToLower(args[0]), args[1], args[2]
+	tpl, found := promptTemplates[templateName]
+	if !found {
+		return s.reply(ctx, b, "Unknown template.\n\n"+promptTemplatesText())
+	}
+	name := templateName
+	if len(args) == 4 {
+		name = args[3]
+	}
+
+	provider, err := s.store.GetProviderByName(context.Background(), chatID, providerName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Provider not found.")
+		}
+		s.logger.Error().Err(err).Msg("get provider failed")
+		return s.reply(ctx, b, "Failed to read provider.")
+	}
+
+	params, _ := json.Marshal(map[string]any{
+		"max_tokens":  1024,
+		"temperature": tpl.Temperature,
+		"allow_tools": false,
+		"template":    fmt.Sprintf("%s@%d", templateName, tpl.Version),
+	})
+	if err := s.store.UpsertPreset(context.Background(), storage.Preset{
+		ChatID:             chatID,
+		Name:               name,
+		ProviderInstanceID: provider.ID,
+		Model:              model,
+		SystemPrompt:       tpl.SystemPrompt,
+		ParamsJSON:         string(params),
+	}); err != nil {
+		s.logger.Error().Err(err).Msg("upsert preset failed")
+		return s.reply(ctx, b, "Failed to save preset.")
+	}
+
+	if _, err := s.store.GetDefaultPresetName(context.Background(), chatID); errors.Is(err, storage.ErrNotFound) {
+		_ = s.store.SetDefaultPreset(context.Background(), chatID, name)
+	}
+
+	_ = s.audit(chatID, userID, "preset_add", map[string]any{"name": name, "provider": providerName, "model": model, "template": templateName, "template_version": tpl.Version})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("saved preset %s from template %s (%s via %s)", name, templateName, model, providerName))
+	return s.reply(ctx, b, fmt.Sprintf("Preset %s saved from template %s (v%d). Use /ai %s <text>.", name, templateName, tpl.Version, name))
+}
@@ -0,0 +1,213 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/custom_http"
+	"hyprbot/internal/storage"
+)
+
+const maxProviderBodyLimit = 64 << 20
+
+const maxProviderConcurrency = 100
+
+const llmLimitsUsage = "Usage: /llm_limits <name> [max_request_bytes|-] [max_response_bytes|-]\n'-' resets to the default (4194304)."
+
+func (s *Service) llmLimits(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.GetText()))
+	if len(args) == 0 || len(args) > 3 {
+		return s.reply(ctx, b, llmLimitsUsage)
+	}
+	name := args[0]
+
+	_, cfg, ok := s.loadProviderConfig(b, ctx, chatID, name)
+	if !ok {
+		return nil
+	}
+
+	if len(args) == 1 {
+		return s.reply(ctx, b, fmt.Sprintf("Limits for %s:\nrequest: %s\nresponse: %s",
+			name, describeBodyLimit(cfg["max_request_bytes"]), describeBodyLimit(cfg["max_response_bytes"])))
+	}
+
+	keys := []string{"max_request_bytes", "max_response_bytes"}
+	for i, raw := range args[1:] {
+		if raw == "-" {
+			delete(cfg, keys[i])
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1024 || n > maxProviderBodyLimit {
+			return s.reply(ctx, b, fmt.Sprintf("Limits must be between 1024 and %d bytes.\n%s", maxProviderBodyLimit, llmLimitsUsage))
+		}
+		cfg[keys[i]] = n
+	}
+
+	if !s.saveProviderConfig(b, ctx, chatID, name, cfg) {
+		return nil
+	}
+	_ = s.audit(chatID, uid, "provider_limits", map[string]any{
+		"name":               name,
+		"max_request_bytes":  cfg["max_request_bytes"],
+		"max_response_bytes": cfg["max_response_bytes"],
+	})
+	s.notifyChange(b, ctx, chatID, "changed body limits of provider "+name)
+	return s.reply(ctx, b, "Provider limits updated.")
+}
+
+func describeBodyLimit(v any) string {
+	if n, ok := v.(float64); ok && n > 0 {
+		return fmt.Sprintf("%d bytes", int64(n))
+	}
+	return fmt.Sprintf("%d bytes (default)", providers.DefaultMaxBodyBytes)
+}
+
+// providerSettings lists the plain config_json keys editable via /llm_set and
+// the provider kinds they apply to; an empty kind means every provider.
+var providerSettings = map[string]string{
+	"max_concurrency": "",
+	"endpoint":        "openai_compat",
+	"method":          "custom_http",
+	"body_template":   "custom_http",
+	"query":           "custom_http",
+	"response_path":   "custom_http",
+}
+
+const llmSetUsage = "Usage: /llm_set <name> <key> <value|->\n" +
+	"all providers: max_concurrency (requests in flight across workers, 1-100)\n" +
+	"openai_compat keys: endpoint (chat_completions|responses)\n" +
+	"custom_http keys: method (GET|POST|PUT|PATCH), body_template, query (JSON object of templates), response_path"
+
+func (s *Service) llmSet(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	name, rest := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	key, value := splitFirstWord(rest)
+	if name == "" || key == "" || value == "" {
+		return s.reply(ctx, b, llmSetUsage)
+	}
+	kind, known := providerSettings[key]
+	if !known {
+		return s.reply(ctx, b, "Unknown setting.\n"+llmSetUsage)
+	}
+
+	p, cfg, ok := s.loadProviderConfig(b, ctx, chatID, name)
+	if !ok {
+		return nil
+	}
+	if kind != "" && p.Kind != kind {
+		return s.reply(ctx, b, fmt.Sprintf("%s only applies to %s providers.", key, kind))
+	}
+
+	if value == "-" {
+		delete(cfg, key)
+	} else {
+		parsed, err := parseProviderSetting(key, value)
+		if err != nil {
+			return s.reply(ctx, b, "Invalid value: "+err.Error())
+		}
+		cfg[key] = parsed
+	}
+	if !s.saveProviderConfig(b, ctx, chatID, name, cfg) {
+		return nil
+	}
+	_ = s.audit(chatID, uid, "provider_set", map[string]any{"name": name, "key": key})
+	s.notifyChange(b, ctx, chatID, fmt.Sprintf("changed %s of provider %s", key, name))
+	return s.reply(ctx, b, fmt.Sprintf("Provider %s: %s updated.", name, key))
+}
+
+func parseProviderSetting(key, value string) (any, error) {
+	switch key {
+	case "max_concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxProviderConcurrency {
+			return nil, fmt.Errorf("max_concurrency must be between 1 and %d", maxProviderConcurrency)
+		}
+		return n, nil
+	case "endpoint":
+		v := strings.ToLower(value)
+		if v != "chat_completions" && v != "responses" {
+			return nil, fmt.Errorf("endpoint must be chat_completions or responses")
+		}
+		return v, nil
+	case "method":
+		v := strings.ToUpper(value)
+		switch v {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+			return v, nil
+		}
+		return nil, fmt.Errorf("method must be GET, POST, PUT or PATCH")
+	case "body_template":
+		if err := custom_http.ValidateTemplate(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case "query":
+		q := map[string]string{}
+		if err := json.Unmarshal([]byte(value), &q); err != nil {
+			return nil, fmt.Errorf(`query must be a JSON object like {"q":"{{.UserPrompt}}"}`)
+		}
+		for _, tpl := range q {
+			if err := custom_http.ValidateTemplate(tpl); err != nil {
+				return nil, err
+			}
+		}
+		return q, nil
+	case "response_path":
+		if err := custom_http.ValidateResponsePath(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown setting %s", key)
+}
+
+func (s *Service) loadProviderConfig(b *gotgbot.Bot, ctx *ext.Context, chatID int64, name string) (storage.ProviderInstance, map[string]any, bool) {
+	p, err := s.store.GetProviderByName(context.Background(), chatID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			_ = s.reply(ctx, b, "Provider not found.")
+		} else {
+			_ = s.reply(ctx, b, "Failed to load provider.")
+		}
+		return storage.ProviderInstance{}, nil, false
+	}
+	cfg := map[string]any{}
+	if strings.TrimSpace(p.ConfigJSON) != "" {
+		if err := json.Unmarshal([]byte(p.ConfigJSON), &cfg); err != nil {
+			s.logger.Error().Err(err).Str("provider", name).Msg("invalid provider config json")
+			_ = s.reply(ctx, b, "Provider config is corrupted.")
+			return storage.ProviderInstance{}, nil, false
+		}
+	}
+	return p, cfg, true
+}
+
+func (s *Service) saveProviderConfig(b *gotgbot.Bot, ctx *ext.Context, chatID int64, name string, cfg map[string]any) bool {
+	cfgJSON, _ := json.Marshal(cfg)
+	if err := s.store.SetProviderConfig(context.Background(), chatID, name, string(cfgJSON)); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			_ = s.reply(ctx, b, "Provider not found.")
+		} else {
+			_ = s.reply(ctx, b, "Failed to save provider config.")
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+)
+
+const (
+	// rateCountdownTick is how often a rate limit notice is edited to show
+	// the time left; rateCountdownMax bounds how long it is kept up to date.
+	rateCountdownTick = 15 * time.Second
+	rateCountdownMax  = 15 * time.Minute
+)
+
+// rateWindow is one limit a request counted toward.
+type rateWindow struct {
+	Label   string
+	Used    int64
+	Limit   int64
+	ResetAt time.Time
+}
+
+func (w rateWindow) exceeded() bool {
+	return w.Used > w.Limit
+}
+
+// allowRate counts the request toward the hourly limit and the chat's daily
+// cap, if any. A refused request gets a notice with both windows whose
+// countdown is edited until the user may ask again.
+func (s *Service) allowRate(chatID, userID int64, b *gotgbot.Bot, ctx *ext.Context) bool {
+	if userID == 0 || s.rateLimiter == nil || s.rateExempt(chatID, userID) {
+		return true
+	}
+	bg := context.Background()
+	now := s.now()
+	ok, used, resetAt, err := s.rateLimiter.Allow(bg, chatID, userID, now)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("rate limiter failed")
+		return true
+	}
+	windows := []rateWindow{{Label: "This hour", Used: used, Limit: s.rateLimiter.Limit(), ResetAt: resetAt}}
+	if daily := s.dailyLimit(chatID); daily > 0 {
+		// A request the hourly limit refused does not count toward the day.
+		day := rateWindow{Label: "Today", Limit: daily}
+		dayOK := true
+		if ok {
+			dayOK, day.Used, day.ResetAt, err = s.rateLimiter.AllowDaily(bg, chatID, userID, daily, now)
+		} else {
+			day.Used, day.ResetAt, err = s.rateLimiter.UsedDaily(bg, chatID, userID, now)
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("daily rate limiter failed")
+		} else {
+			ok = ok && dayOK
+			windows = append(windows, day)
+		}
+	}
+	if ok {
+		return true
+	}
+	s.sendRateNotice(ctx, b, windows)
+	return false
+}
+
+// dailyLimit is the chat's daily cap per user, 0 when it has none.
+func (s *Service) dailyLimit(chatID int64) int64 {
+	v, err := s.store.GetChatSetting(context.Background(), chatID, storage.SettingDailyLimit)
+	if err != nil || v == "" {
+		return 0
+	}
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n
+}
+
+func (s *Service) sendRateNotice(ctx *ext.Context, b *gotgbot.Bot, windows []rateWindow) {
+	if ctx.EffectiveChat == nil {
+		return
+	}
+	var until time.Time
+	for _, w := range windows {
+		if w.exceeded() && w.ResetAt.After(until) {
+			until = w.ResetAt
+		}
+	}
+	msg, err := s.sender.Send(context.Background(), tgsend.Message{ChatID: ctx.EffectiveChat.Id, ThreadID: threadID(ctx), Text: rateNoticeText(windows, until, s.now())})
+	if err != nil || msg == nil {
+		return
+	}
+	s.expire(msg.Chat.Id, msg.MessageId)
+	go s.runRateCountdown(msg.Chat.Id, msg.MessageId, windows, until)
+}
+
+// runRateCountdown edits the notice until the user may ask again, the
+// notice is deleted or rateCountdownMax has passed.
+func (s *Service) runRateCountdown(chatID, messageID int64, windows []rateWindow, until time.Time) {
+	stop := s.now().Add(rateCountdownMax)
+	if s.ephemeral != nil && s.ephemeralTTL > 0 {
+		stop = s.now().Add(s.ephemeralTTL)
+	}
+	ticker := time.NewTicker(rateCountdownTick)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := s.now()
+		if now.After(stop) {
+			return
+		}
+		text := rateNoticeText(windows, until, now)
+		if !now.Before(until) {
+			text = "Rate limit over. You can ask again now."
+		}
+		if err := s.sender.Edit(context.Background(), chatID, messageID, text, nil); err != nil {
+			s.logger.Debug().Err(err).Int64("chat_id", chatID).Msg("rate limit countdown stopped")
+			return
+		}
+		if !now.Before(until) {
+			return
+		}
+	}
+}
+
+func rateNoticeText(windows []rateWindow, until, now time.Time) string {
+	lines := []string{"Rate limit reached. You can ask again in " + formatCountdown(until.Sub(now)) + "."}
+	for _, w := range windows {
+		lines = append(lines, fmt.Sprintf("%s: %d/%d, resets in %s", w.Label, min(w.Used, w.Limit), w.Limit, formatCountdown(w.ResetAt.Sub(now))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCountdown renders d as e.g. "2h 05m", "12m 30s" or "40s".
+func formatCountdown(d time.Duration) string {
+	d = max(d.Round(time.Second), 0)
+	h, m, sec := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh %02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm %02ds", m, sec)
+	}
+	return fmt.Sprintf("%ds", sec)
+}
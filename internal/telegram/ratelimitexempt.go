@@ -0,0 +1,135 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const maxRateExempt = 50
+
+const rateExemptUsage = "Usage: /ratelimit_exempt <@username|user_id> to exempt a member from the rate limits, /ratelimit_exempt del <@username|user_id> to undo it, or reply to their message. Without arguments it lists exempt members."
+
+// rateExempt reports whether the chat exempted userID from the rate limits.
+// It is checked before the limiter so exempt members use no Redis calls.
+func (s *Service) rateExempt(chatID, userID int64) bool {
+	v, err := s.store.GetChatSetting(context.Background(), chatID, storage.SettingRateExempt)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to load rate limit exemptions")
+		return false
+	}
+	return slices.Contains(parseUserIDs(v), userID)
+}
+
+func parseUserIDs(v string) []int64 {
+	var out []int64
+	for _, part := range strings.Split(v, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil && id != 0 {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func formatUserIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *Service) rateLimitExempt(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	msg := ctx.EffectiveMessage
+	bg := context.Background()
+	current, err := s.store.GetChatSetting(bg, chatID, storage.SettingRateExempt)
+	if err != nil {
+		return s.reply(ctx, b, "Failed to load rate limit exemptions.")
+	}
+	ids := parseUserIDs(current)
+
+	arg := strings.TrimSpace(commandRemainder(msg.GetText()))
+	remove := false
+	if first, rest := splitFirstWord(arg); strings.EqualFold(first, "del") {
+		remove, arg = true, rest
+	}
+	if arg == "" && (msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil) {
+		if remove {
+			return s.reply(ctx, b, rateExemptUsage)
+		}
+		return s.reply(ctx, b, s.rateExemptList(ids))
+	}
+	user, err := s.lookupUser(chatID, msg, arg)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "User not seen in this chat yet.")
+		}
+		return s.reply(ctx, b, "Failed to load user.")
+	}
+
+	action := "ratelimit_exempt_add"
+	if remove {
+		if !slices.Contains(ids, user.ID) {
+			return s.reply(ctx, b, userLabel(user)+" is not exempt.")
+		}
+		ids = slices.DeleteFunc(ids, func(id int64) bool { return id == user.ID })
+		action = "ratelimit_exempt_del"
+	} else {
+		if slices.Contains(ids, user.ID) {
+			return s.reply(ctx, b, userLabel(user)+" is already exempt.")
+		}
+		if len(ids) >= maxRateExempt {
+			return s.reply(ctx, b, fmt.Sprintf("At most %d members can be exempt. Remove one first.", maxRateExempt))
+		}
+		ids = append(ids, user.ID)
+	}
+	if err := s.store.SetChatSetting(bg, chatID, storage.SettingRateExempt, formatUserIDs(ids)); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		return s.reply(ctx, b, "Failed to save rate limit exemptions.")
+	}
+	_ = s.audit(chatID, uid, action, map[string]any{"user_id": user.ID})
+	if remove {
+		return s.reply(ctx, b, userLabel(user)+" is subject to the rate limits again.")
+	}
+	return s.reply(ctx, b, userLabel(user)+" is exempt from the rate limits.")
+}
+
+func (s *Service) rateExemptList(ids []int64) string {
+	if len(ids) == 0 {
+		return "No members are exempt from the rate limits.\n" + rateExemptUsage
+	}
+	lines := []string{"Exempt from the rate limits:"}
+	for _, id := range ids {
+		user, err := s.store.GetUser(context.Background(), id)
+		if err != nil {
+			user = storage.User{ID: id}
+		}
+		lines = append(lines, "- "+userLabel(user))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// userLabel names a user as "@name (id)", or by id without a username.
+func userLabel(u storage.User) string {
+	if u.Username != "" {
+		return fmt.Sprintf("@%s (%d)", u.Username, u.ID)
+	}
+	if u.FirstName != "" {
+		return fmt.Sprintf("%s (%d)", u.FirstName, u.ID)
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const referralPrefix = "ref_"
+
+// referralStatsPeriod is the window of the requests column in /owner_stats.
+const referralStatsPeriod = 30 * 24 * time.Hour
+
+// Start parameters are limited to 64 characters of [A-Za-z0-9_-].
+var referralCodeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,60}$`)
+
+// referralCode extracts the code of a "ref_<code>" start parameter.
+func referralCode(param string) (string, bool) {
+	code, ok := strings.CutPrefix(param, referralPrefix)
+	if !ok || !referralCodeRe.MatchString(code) {
+		return "", false
+	}
+	return code, true
+}
+
+// recordReferral attributes the chat to the deep link's code. Private
+// chats come from ?start= links, groups from ?startgroup= links, which make
+// Telegram send /start <param> in the group once the bot is added.
+func (s *Service) recordReferral(ctx *ext.Context, code string) {
+	msg := ctx.EffectiveMessage
+	s.ensureChat(context.Background(), msg)
+	recorded, err := s.store.RecordReferral(context.Background(), storage.Referral{
+		ChatID: msg.Chat.Id,
+		Code:   code,
+		UserID: userID(ctx),
+	})
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", msg.Chat.Id).Msg("failed to record referral")
+		return
+	}
+	if recorded {
+		_ = s.audit(msg.Chat.Id, userID(ctx), "referral", map[string]any{"code": code})
+	}
+}
+
+// ownerStats reports bot-wide chat counts and which referral codes brought
+// which chats.
+func (s *Service) ownerStats(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	total, active, err := s.store.ChatCounts(context.Background())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("chat counts failed")
+		return s.reply(ctx, b, "Failed to load stats.")
+	}
+	stats, err := s.store.ReferralStats(context.Background(), referralStatsPeriod)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("referral stats failed")
+		return s.reply(ctx, b, "Failed to load stats.")
+	}
+
+	lines := []string{fmt.Sprintf("Chats: %d (%d with the bot)", total, active), ""}
+	if len(stats) == 0 {
+		lines = append(lines, "No referrals yet.")
+	} else {
+		lines = append(lines, "Referrals (chats, with the bot, requests in 30 days):")
+		var referred int64
+		for _, st := range stats {
+			lines = append(lines, fmt.Sprintf("%s: %d, %d, %d", st.Code, st.Chats, st.ActiveChats, st.Requests))
+			referred += st.Chats
+		}
+		lines = append(lines, fmt.Sprintf("Without referral: %d", total-referred))
+	}
+	if link := s.deepLink(b, referralPrefix+"<code>"); link != "" {
+		lines = append(lines, "", "Links: "+strings.Replace(link, "?start=", "?startgroup=", 1)+" for groups, "+link+" for private chats")
+	}
+	out := strings.Join(lines, "\n")
+	if r := []rune(out); len(r) > 4000 {
+		out = string(r[:4000])
+	}
+	return s.reply(ctx, b, out)
+}
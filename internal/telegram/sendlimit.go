@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/queue"
+)
+
+// limitedMethods are the Bot API calls that count against Telegram's
+// message limits.
+var limitedMethods = map[string]bool{
+	"sendMessage":            true,
+	"sendDocument":           true,
+	"sendPhoto":              true,
+	"sendMediaGroup":         true,
+	"sendInvoice":            true,
+	"copyMessage":            true,
+	"forwardMessage":         true,
+	"editMessageText":        true,
+	"editMessageCaption":     true,
+	"editMessageReplyMarkup": true,
+}
+
+// maxFloodRetries bounds how often one call is retried after 429s.
+const maxFloodRetries = 3
+
+// RateLimitedClient wraps a BotClient so every send and edit, from ingress
+// and workers alike, waits for a SendLimiter slot, and calls Telegram
+// answers with "Too Many Requests: retry after N" sleep and retry. Waits
+// longer than MaxWait fail the call instead of stalling it.
+type RateLimitedClient struct {
+	gotgbot.BotClient
+	Limiter *queue.SendLimiter
+	MaxWait time.Duration
+	Logger  zerolog.Logger
+}
+
+func (c *RateLimitedClient) RequestWithContext(ctx context.Context, token string, method string, params map[string]string, data map[string]gotgbot.FileReader, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	if !limitedMethods[method] || c.Limiter == nil {
+		return c.BotClient.RequestWithContext(ctx, token, method, params, data, opts)
+	}
+	chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForSlot(ctx, chatID); err != nil {
+			return nil, err
+		}
+		res, err := c.BotClient.RequestWithContext(ctx, token, method, params, data, opts)
+		wait, flooded := floodWait(err)
+		if !flooded || attempt >= maxFloodRetries || wait > c.maxWait() {
+			return res, err
+		}
+		c.Logger.Warn().Str("method", method).Int64("chat_id", chatID).Dur("retry_after", wait).Msg("telegram flood wait")
+		if err := c.Limiter.Block(ctx, chatID, wait); err != nil {
+			c.Logger.Warn().Err(err).Msg("failed to record telegram flood wait")
+			if err := sleepCtx(ctx, wait); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// waitForSlot sleeps until the limiter grants a send. Limiter errors let the
+// call through: Telegram's own 429 handling still applies.
+func (c *RateLimitedClient) waitForSlot(ctx context.Context, chatID int64) error {
+	deadline := time.Now().Add(c.maxWait())
+	for {
+		wait, err := c.Limiter.Reserve(ctx, chatID, time.Now())
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.Logger.Debug().Err(err).Msg("telegram send limiter unavailable")
+			return nil
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return errors.New("telegram send limit: wait exceeds " + c.maxWait().String())
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *RateLimitedClient) maxWait() time.Duration {
+	if c.MaxWait <= 0 {
+		return time.Minute
+	}
+	return c.MaxWait
+}
+
+func floodWait(err error) (time.Duration, bool) {
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) || tgErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+	retryAfter := int64(1)
+	if tgErr.ResponseParams != nil && tgErr.ResponseParams.RetryAfter > 0 {
+		retryAfter = tgErr.ResponseParams.RetryAfter
+	}
+	return time.Duration(retryAfter) * time.Second, true
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
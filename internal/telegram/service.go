@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"context"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -14,33 +15,76 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 
+	"hyprbot/internal/billing"
 	"hyprbot/internal/crypto"
+	"hyprbot/internal/eventstream"
 	"hyprbot/internal/metrics"
+	"hyprbot/internal/notify"
 	"hyprbot/internal/queue"
 	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
 )
 
 type Service struct {
 	store         *storage.Store
 	queue         *queue.StreamQueue
+	events        *queue.EventBus
+	picks         *queue.PickStore
+	answers       *queue.AnswerStore
+	fullAnswers   *queue.FullAnswerStore
+	quota         *queue.ChatQuota
+	maintenance   *queue.Maintenance
+	workers       *queue.WorkerRegistry
+	fallback      *queue.FallbackPresets
+	pauses        *ChatPauses
+	billing       billing.Config
 	crypto        *crypto.Manager
+	providerHTTP  *http.Client
 	rateLimiter   *queue.RateLimiter
 	wizard        *wizardStore
 	redis         *redis.Client
+	sender        *tgsend.Sender
+	ephemeral     *queue.EphemeralStore
+	ephemeralTTL  time.Duration
 	logger        zerolog.Logger
 	metrics       *metrics.Metrics
 	adminCacheTTL time.Duration
 	botUsername   string
 	accessMode    string
 	adminUserID   int64
+	askEditWindow time.Duration
+	verifyKeys    bool
+	docsURL       string
+	plugins       []string
+	prefixCache   *prefixCache
+	notify        *notify.Dispatcher
+	stream        *eventstream.Publisher
+
+	privateSelfService bool
+
+	allowAnonymousAdmins bool
 }
 
 type Config struct {
 	Store         *storage.Store
 	Queue         *queue.StreamQueue
+	Events        *queue.EventBus
+	Picks         *queue.PickStore
+	Answers       *queue.AnswerStore
+	FullAnswers   *queue.FullAnswerStore
+	Quota         *queue.ChatQuota
+	Maintenance   *queue.Maintenance
+	Workers       *queue.WorkerRegistry
+	Fallback      *queue.FallbackPresets
+	Pauses        *ChatPauses
+	Billing       billing.Config
 	Crypto        *crypto.Manager
+	ProviderHTTP  *http.Client
 	RateLimiter   *queue.RateLimiter
 	Redis         *redis.Client
+	Sender        *tgsend.Sender
+	Ephemeral     *queue.EphemeralStore
+	EphemeralTTL  time.Duration
 	Logger        zerolog.Logger
 	Metrics       *metrics.Metrics
 	AdminCacheTTL time.Duration
@@ -48,6 +92,25 @@ type Config struct {
 	BotUsername   string
 	AccessMode    string
 	AdminUserID   int64
+	AskEditWindow time.Duration
+	// VerifyKeys checks provider keys against the provider before the
+	// wizard saves them.
+	VerifyKeys bool
+	DocsURL    string
+	// Plugins names the configured plugins chats can pick with /settings
+	// plugins.
+	Plugins []string
+	// Notify sends config changes to the owner's webhooks; the owner
+	// manages them with /owner_webhook_add.
+	Notify *notify.Dispatcher
+	// EventStream gets an anonymized config.changed event per audited
+	// action; nil publishes nothing.
+	EventStream *eventstream.Publisher
+	// PrivateSelfService lets users set up providers and presets for their
+	// own private chat with the bot.
+	PrivateSelfService bool
+
+	AllowAnonymousAdmins bool
 }
 
 func NewService(cfg Config) *Service {
@@ -64,36 +127,120 @@ func NewService(cfg Config) *Service {
 	return &Service{
 		store:         cfg.Store,
 		queue:         cfg.Queue,
+		events:        cfg.Events,
+		picks:         cfg.Picks,
+		answers:       cfg.Answers,
+		fullAnswers:   cfg.FullAnswers,
+		quota:         cfg.Quota,
+		maintenance:   cfg.Maintenance,
+		workers:       cfg.Workers,
+		fallback:      cfg.Fallback,
+		pauses:        cfg.Pauses,
+		billing:       cfg.Billing,
 		crypto:        cfg.Crypto,
+		providerHTTP:  cfg.ProviderHTTP,
 		rateLimiter:   cfg.RateLimiter,
 		wizard:        newWizardStore(cfg.Redis, cfg.WizardTTL),
 		redis:         cfg.Redis,
+		sender:        cfg.Sender,
+		ephemeral:     cfg.Ephemeral,
+		ephemeralTTL:  cfg.EphemeralTTL,
 		logger:        cfg.Logger,
 		metrics:       m,
 		adminCacheTTL: cfg.AdminCacheTTL,
 		botUsername:   cfg.BotUsername,
 		accessMode:    cfg.AccessMode,
 		adminUserID:   cfg.AdminUserID,
+		askEditWindow: cfg.AskEditWindow,
+		verifyKeys:    cfg.VerifyKeys,
+		docsURL:       cfg.DocsURL,
+		plugins:       cfg.Plugins,
+		prefixCache:   newPrefixCache(),
+		notify:        cfg.Notify,
+		stream:        cfg.EventStream,
+
+		privateSelfService: cfg.PrivateSelfService,
+
+		allowAnonymousAdmins: cfg.AllowAnonymousAdmins,
 	}
 }
 
 func (s *Service) Register(d *ext.Dispatcher) {
-	d.AddHandler(handlers.NewCommand("help", s.help))
-	d.AddHandler(handlers.NewCommand("start", s.start))
-	d.AddHandler(handlers.NewCommand("menu", s.menu))
-	d.AddHandler(handlers.NewCommand("setup", s.setup))
-	d.AddHandler(handlers.NewCommand("status", s.status))
-	d.AddHandler(handlers.NewCommand("cancel", s.cancelWizard))
-	d.AddHandler(handlers.NewCommand("ask", s.ask))
-	d.AddHandler(handlers.NewCommand("ai", s.ai))
-	d.AddHandler(handlers.NewCommand("ai_list", s.aiList))
-	d.AddHandler(handlers.NewCommand("ai_preset_add", s.aiPresetAdd))
-	d.AddHandler(handlers.NewCommand("ai_preset_del", s.aiPresetDel))
-	d.AddHandler(handlers.NewCommand("ai_default", s.aiDefault))
-	d.AddHandler(handlers.NewCommand("llm_add", s.llmAdd))
-	d.AddHandler(handlers.NewCommand("llm_list", s.llmList))
-	d.AddHandler(handlers.NewCommand("llm_del", s.llmDel))
-	d.AddHandler(handlers.NewCallback(callbackquery.Prefix(cbPrefix), s.onCallback))
+	d.AddHandlerToGroup(handlers.NewMessage(s.matchAliasPrefix, s.routeAliasPrefix), prefixRouterGroup)
+	d.AddHandler(s.command("help", s.help))
+	d.AddHandler(s.command("start", s.start))
+	d.AddHandler(s.command("menu", s.menu))
+	d.AddHandler(s.command("setup", s.setup))
+	d.AddHandler(s.command("status", s.status))
+	d.AddHandler(s.command("stats", s.stats))
+	d.AddHandler(s.command("cancel", s.cancelWizard))
+	d.AddHandler(s.command("wizards", s.wizards))
+	d.AddHandler(s.command("ask", s.ask))
+	d.AddHandler(s.command("ai", s.ai))
+	d.AddHandler(s.command("code", s.code))
+	d.AddHandler(s.command("translate", s.tr))
+	d.AddHandler(s.command("tr", s.tr))
+	d.AddHandler(s.command("tpl", s.tpl))
+	d.AddHandler(s.command("tpl_list", s.tplList))
+	d.AddHandler(s.command("tpl_add", s.tplAdd))
+	d.AddHandler(s.command("tpl_del", s.tplDel))
+	d.AddHandler(s.command("kb", s.kb))
+	d.AddHandler(s.command("kb_refresh", s.kbRefresh))
+	d.AddHandler(s.command("kb_del", s.kbDel))
+	d.AddHandler(s.command("ai_list", s.aiList))
+	d.AddHandler(s.command("ai_preset_add", s.aiPresetAdd))
+	d.AddHandler(s.command("ai_preset_del", s.aiPresetDel))
+	d.AddHandler(s.command("ai_preset_param", s.aiPresetParam))
+	d.AddHandler(s.command("preset_add_template", s.presetAddTemplate))
+	d.AddHandler(s.command("ai_default", s.aiDefault))
+	d.AddHandler(s.command("preset_stats", s.presetStats))
+	d.AddHandler(s.command("preview", s.preview))
+	d.AddHandler(s.command("preset_history", s.presetHistory))
+	d.AddHandler(s.command("preset_rollback", s.presetRollback))
+	d.AddHandler(s.command("ab_start", s.abStart))
+	d.AddHandler(s.command("ab_stop", s.abStop))
+	d.AddHandler(s.command("ab_report", s.abReport))
+	d.AddHandler(s.command("llm_add", s.llmAdd))
+	d.AddHandler(s.command("llm_list", s.llmList))
+	d.AddHandler(s.command("llm_show", s.llmShow))
+	d.AddHandler(s.command("llm_del", s.llmDel))
+	d.AddHandler(s.command("llm_limits", s.llmLimits))
+	d.AddHandler(s.command("llm_set", s.llmSet))
+	d.AddHandler(s.command("models", s.models))
+	d.AddHandler(s.command("whois", s.whois))
+	d.AddHandler(s.command("ratelimit_exempt", s.rateLimitExempt))
+	d.AddHandler(s.command("admin_refresh", s.adminRefresh))
+	d.AddHandler(s.command("prefixes", s.prefixes))
+	d.AddHandler(s.command("persona_set", s.personaSet))
+	d.AddHandler(s.command("persona_clear", s.personaClear))
+	d.AddHandler(s.command("mention_mode", s.mentionMode))
+	d.AddHandler(s.command("settings", s.settings))
+	d.AddHandler(s.command("bot_off", s.botOff))
+	d.AddHandler(s.command("bot_on", s.botOn))
+	d.AddHandler(s.command("backup", s.backup))
+	d.AddHandler(s.command("owner_grant", s.ownerGrant))
+	d.AddHandler(s.command("owner_stats", s.ownerStats))
+	d.AddHandler(s.command("owner_maintenance", s.ownerMaintenance))
+	d.AddHandler(s.command("owner_fallback", s.ownerFallback))
+	d.AddHandler(s.command("owner_workers", s.ownerWorkers))
+	d.AddHandler(s.command("owner_webhook_add", s.ownerWebhookAdd))
+	d.AddHandler(s.command("owner_webhook_del", s.ownerWebhookDel))
+	d.AddHandler(s.command("owner_webhooks", s.ownerWebhooks))
+	d.AddHandler(s.command("forget_me", s.forgetMe))
+	d.AddHandler(s.command("forget_chat", s.forgetChat))
+	d.AddHandler(s.command("export", s.export))
+	d.AddHandler(s.command("export_policy", s.exportPolicy))
+	d.AddHandler(s.command("balance", s.balance))
+	d.AddHandler(s.command("buy", s.buy))
+	d.AddHandler(handlers.NewPreCheckoutQuery(nil, s.preCheckout))
+	d.AddHandler(handlers.NewMessage(matchSuccessfulPayment, s.successfulPayment))
+	d.AddHandler(handlers.NewMyChatMember(nil, s.myChatMember))
+	d.AddHandler(handlers.NewChatMember(nil, s.chatMember))
+	d.AddHandler(handlers.NewMessage(matchChatMigration, s.chatMigrated))
+	d.AddHandler(handlers.NewMessage(matchPinned, s.pinned))
+	d.AddHandler(handlers.NewMessage(matchEditedAsk, s.editedAsk).SetAllowEdited(true))
+	d.AddHandler(handlers.NewCallback(callbackquery.Prefix(cbPrefix), s.instrument("callback", s.onCallback)))
+	d.AddHandler(handlers.NewMessage(matchMentionCandidate, s.mentionAsk))
 	d.AddHandler(handlers.NewMessage(func(msg *gotgbot.Message) bool {
 		return message.Private(msg) && message.Text(msg)
 	}, s.privateText))
@@ -0,0 +1,277 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/sanitize"
+	"hyprbot/internal/storage"
+)
+
+// cbSettingToggle is followed by a setting key; pressing the button advances
+// that setting to its next value.
+const cbSettingToggle = cbPrefix + "set:"
+
+const (
+	maxReplyLanguageRunes = 40
+	maxDisclosureRunes    = 200
+	maxDailyLimit         = 100000
+)
+
+// settingToggle is a /settings menu entry cycling through Values.
+type settingToggle struct {
+	Key    string
+	Label  string
+	Values []string
+}
+
+var settingToggles = []settingToggle{
+	{Key: storage.SettingAck, Label: "Ack", Values: []string{storage.AckStyleMessage, storage.AckStyleReaction}},
+	{Key: storage.SettingMention, Label: "Mentions", Values: []string{storage.SettingOff, storage.SettingOn}},
+	{Key: storage.SettingExports, Label: "Exports", Values: []string{storage.SettingOn, storage.SettingOff}},
+	{Key: storage.SettingFormatting, Label: "Formatting", Values: []string{storage.FormattingPlain, storage.FormattingMarkdown}},
+	{Key: storage.SettingFooter, Label: "Footer", Values: []string{storage.SettingOff, storage.SettingOn}},
+	{Key: storage.SettingChangeNotices, Label: "Change notices", Values: []string{storage.SettingOff, storage.SettingOn}},
+	{Key: storage.SettingDigest, Label: "Digest", Values: []string{storage.SettingOff, storage.DigestAdmins, storage.DigestGroup}},
+	{Key: storage.SettingPrivateAnswers, Label: "Private answers", Values: []string{storage.SettingOff, storage.SettingOn}},
+	{Key: storage.SettingKnowledge, Label: "Knowledge", Values: []string{storage.SettingOff, storage.KnowledgePins, storage.KnowledgePinsDescription}},
+	{Key: storage.SettingLongAnswers, Label: "Long answers", Values: []string{storage.LongAnswersSplit, storage.LongAnswersExpand, storage.LongAnswersDM, storage.LongAnswersFile}},
+}
+
+const settingsUsage = "Usage: /settings <key> <value>\n" +
+	"ack <message|reaction> - acknowledge /ask with a status message, or a 👀 reaction that turns into 👍/👎 when done\n" +
+	"mention <on|off> - answer @mentions and replies with the default preset\n" +
+	"exports <on|off> - allow members to export chat history\n" +
+	"formatting <plain|markdown> - send answers as plain text or Telegram Markdown\n" +
+	"footer <on|off> - end answers with the model, response time and token count\n" +
+	"change_notices <on|off> - announce provider and preset changes by admins in the group\n" +
+	"digest <off|admins|group> - periodic usage digest, sent to admins privately or posted in the group\n" +
+	"private_answers <on|off> - send answers to the asker in private chat and leave a short note in the group\n" +
+	"knowledge <off|pins|pins_description> - give the model the chat's pinned messages (and description) to answer questions about the chat, see /kb\n" +
+	"long_answers <split|expand|dm|file> - send long answers in several messages, or as a preview whose button expands it, DMs it or sends it as a file\n" +
+	"reply_language <language|auto> - ask the model to always answer in this language\n" +
+	"disclosure <text|off> - end every answer with this line, e.g. #AIgenerated, whatever the preset\n" +
+	"daily_limit <number|off> - requests per member and UTC day, on top of the hourly limit\n" +
+	"output_filter <all|off|commands,links,mentions> - defuse /commands, tg:// links and @mentions in model answers\n" +
+	"plugins <name,...|default|off> - operator plugins whose tools and filters this chat uses"
+
+func findSettingToggle(key string) (settingToggle, bool) {
+	for _, t := range settingToggles {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return settingToggle{}, false
+}
+
+func (t settingToggle) next(current string) string {
+	for i, v := range t.Values {
+		if v == current {
+			return t.Values[(i+1)%len(t.Values)]
+		}
+	}
+	return t.Values[0]
+}
+
+func settingsText(cs storage.ChatSettings) string {
+	lang := cs.Get(storage.SettingReplyLanguage)
+	if lang == "" {
+		lang = "auto"
+	}
+	lines := []string{"Chat settings"}
+	for _, t := range settingToggles {
+		lines = append(lines, fmt.Sprintf("%s: %s", t.Key, cs.Get(t.Key)))
+	}
+	disclosure := cs.Get(storage.SettingDisclosure)
+	if disclosure == "" {
+		disclosure = storage.SettingOff
+	}
+	daily := cs.Get(storage.SettingDailyLimit)
+	if daily == "" {
+		daily = storage.SettingOff
+	}
+	filter := cs.Get(storage.SettingOutputFilter)
+	if filter == "" {
+		filter = "all"
+	}
+	plugins := cs.Get(storage.SettingPlugins)
+	if plugins == "" {
+		plugins = "default"
+	}
+	lines = append(lines, storage.SettingReplyLanguage+": "+lang, storage.SettingDisclosure+": "+disclosure, storage.SettingDailyLimit+": "+daily, storage.SettingOutputFilter+": "+filter, storage.SettingPlugins+": "+plugins, "", "Tap a button to toggle.", settingsUsage)
+	return strings.Join(lines, "\n")
+}
+
+func settingsKeyboard(cs storage.ChatSettings) *gotgbot.InlineKeyboardMarkup {
+	rows := make([][]gotgbot.InlineKeyboardButton, 0, len(settingToggles))
+	for _, t := range settingToggles {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{
+			Text:         t.Label + ": " + cs.Get(t.Key),
+			CallbackData: cbSettingToggle + t.Key,
+		}})
+	}
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (s *Service) settings(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		return nil
+	}
+	key, value := splitFirstWord(commandRemainder(ctx.EffectiveMessage.GetText()))
+	key = strings.ToLower(key)
+	value = strings.TrimSpace(value)
+	if key == "" {
+		cs, err := s.store.GetChatSettings(context.Background(), chatID)
+		if err != nil {
+			return s.reply(ctx, b, "Failed to load settings.")
+		}
+		return s.replyWithMarkup(ctx, b, settingsText(cs), settingsKeyboard(cs))
+	}
+
+	switch key {
+	case storage.SettingReplyLanguage:
+		if value == "" || utf8.RuneCountInString(value) > maxReplyLanguageRunes {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		if value == "-" || strings.EqualFold(value, "auto") {
+			value = ""
+		}
+	case storage.SettingDisclosure:
+		if value == "" || utf8.RuneCountInString(value) > maxDisclosureRunes {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		if value == "-" || strings.EqualFold(value, storage.SettingOff) {
+			value = ""
+		}
+	case storage.SettingDailyLimit:
+		if strings.EqualFold(value, storage.SettingOff) || value == "0" {
+			value = ""
+			break
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxDailyLimit {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		value = strconv.Itoa(n)
+	case storage.SettingOutputFilter:
+		if value == "" {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		if strings.EqualFold(value, "all") {
+			value = ""
+		}
+		o, err := sanitize.ParseFilter(value)
+		if err != nil {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		value = o.String()
+	case storage.SettingPlugins:
+		if value == "" {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		if len(s.plugins) == 0 {
+			return s.reply(ctx, b, "No plugins are configured for this bot.")
+		}
+		names, ok := s.parsePlugins(value)
+		if !ok {
+			return s.reply(ctx, b, "Unknown plugin. Available: "+strings.Join(s.plugins, ", ")+"\n"+settingsUsage)
+		}
+		value = names
+	default:
+		t, found := findSettingToggle(key)
+		if !found {
+			return s.reply(ctx, b, settingsUsage)
+		}
+		value = strings.ToLower(value)
+		valid := false
+		for _, v := range t.Values {
+			valid = valid || v == value
+		}
+		if !valid {
+			return s.reply(ctx, b, settingsUsage)
+		}
+	}
+
+	if err := s.store.SetChatSetting(context.Background(), chatID, key, value); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Chat is not registered yet. Try again.")
+		}
+		return s.reply(ctx, b, "Failed to save settings.")
+	}
+	_ = s.audit(chatID, uid, "chat_settings", map[string]any{key: value})
+	s.settingChanged(b, chatID, key, value)
+	if value == "" && (key == storage.SettingDisclosure || key == storage.SettingDailyLimit) {
+		value = storage.SettingOff
+	} else if value == "" && key == storage.SettingOutputFilter {
+		value = "all"
+	} else if value == "" && key == storage.SettingPlugins {
+		value = "default"
+	} else if value == "" {
+		value = "auto"
+	}
+	return s.reply(ctx, b, "Settings updated: "+key+" = "+value)
+}
+
+func (s *Service) toggleSetting(b *gotgbot.Bot, ctx *ext.Context, key string) error {
+	t, found := findSettingToggle(key)
+	if !found {
+		s.answerCallback(b, ctx, "Unknown setting.", true)
+		return nil
+	}
+	chatID, uid, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		s.answerCallback(b, ctx, "Only chat admins can change settings.", true)
+		return nil
+	}
+	current, err := s.store.GetChatSetting(context.Background(), chatID, key)
+	if err != nil {
+		s.answerCallback(b, ctx, "Failed to load settings.", true)
+		return nil
+	}
+	value := t.next(current)
+	if err := s.store.SetChatSetting(context.Background(), chatID, key, value); err != nil {
+		s.answerCallback(b, ctx, "Failed to save settings.", true)
+		return nil
+	}
+	_ = s.audit(chatID, uid, "chat_settings", map[string]any{key: value})
+	s.settingChanged(b, chatID, key, value)
+
+	cs, err := s.store.GetChatSettings(context.Background(), chatID)
+	if err != nil {
+		s.answerCallback(b, ctx, "Failed to load settings.", true)
+		return nil
+	}
+	return s.editOrReplyCallback(ctx, b, settingsText(cs), settingsKeyboard(cs))
+}
+
+// parsePlugins normalizes a plugins setting value: "default" is stored as
+// empty, "off" as is, otherwise names of configured plugins.
+func (s *Service) parsePlugins(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "default", "-":
+		return "", true
+	case storage.SettingOff:
+		return storage.SettingOff, true
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(s.plugins, name) {
+			return "", false
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ","), true
+}
@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const (
+	cbSetupTemplates = cbPrefix + "setup_tpl"
+	// cbSetupDefault is followed by a preset name.
+	cbSetupDefault = cbPrefix + "setup_def:"
+	// maxSetupDefaultButtons bounds the "use as default" buttons.
+	maxSetupDefaultButtons = 6
+)
+
+// setupView is /setup: a checklist computed from what the chat has
+// configured so far, or the static guide in private chats that cannot be
+// configured.
+func (s *Service) setupView(ctx *ext.Context) (string, *gotgbot.InlineKeyboardMarkup) {
+	chatID, ok := s.callbackChatID(ctx)
+	if !ok || ctx.EffectiveChat == nil || (ctx.EffectiveChat.Type == "private" && !s.privateSelfService) {
+		return s.setupText(), s.setupKeyboard()
+	}
+	return s.setupChecklist(chatID)
+}
+
+func (s *Service) setupChecklist(chatID int64) (string, *gotgbot.InlineKeyboardMarkup) {
+	bg := context.Background()
+	providers, _ := s.store.ListProviders(bg, chatID)
+	presets, _ := s.store.ListPresets(bg, chatID)
+	defaultName, _ := s.store.GetDefaultPresetName(bg, chatID)
+	asked, _ := s.store.ChatHasUsage(bg, chatID)
+	onboarding, _ := s.store.GetChatSetting(bg, chatID, storage.SettingOnboarding)
+	shared := len(presets) == 0 && onboarding == storage.OnboardingShared && s.hasSharedPreset(bg)
+
+	mark := func(done bool) string {
+		if done {
+			return "✅"
+		}
+		return "❌"
+	}
+	lines := []string{"Setup checklist", ""}
+	var rows [][]gotgbot.InlineKeyboardButton
+	switch {
+	case shared:
+		lines = append(lines, "✅ Using the bot's shared provider (quick setup). Add your own with /llm_add for more questions per hour.")
+	default:
+		lines = append(lines, fmt.Sprintf("%s Provider added (%d)", mark(len(providers) > 0), len(providers)))
+		lines = append(lines, fmt.Sprintf("%s Preset created (%d)", mark(len(presets) > 0), len(presets)))
+		if defaultName != "" {
+			lines = append(lines, "✅ Default preset: "+defaultName)
+		} else {
+			lines = append(lines, "❌ No default preset")
+		}
+	}
+	lines = append(lines, mark(asked)+" First question asked")
+
+	switch {
+	case shared:
+	case len(providers) == 0:
+		lines = append(lines, "", "Next: an admin adds a provider with its API key, in a private chat with the bot.")
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Add provider", CallbackData: cbActLlmAdd}})
+	case len(presets) == 0:
+		lines = append(lines, "", "Next: create a preset, a model with a system prompt, e.g. from a template.")
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Preset templates", CallbackData: cbSetupTemplates}})
+	case defaultName == "":
+		lines = append(lines, "", "Next: pick the preset /ask uses.")
+		for _, p := range presets {
+			if len(rows) == maxSetupDefaultButtons {
+				break
+			}
+			// Telegram caps callback data at 64 bytes.
+			if len(cbSetupDefault+p.Name) > 64 {
+				continue
+			}
+			rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Use " + p.Name + " as default", CallbackData: cbSetupDefault + p.Name}})
+		}
+	case !asked:
+		lines = append(lines, "", "Next: ask something with /ask <question>.")
+	default:
+		lines = append(lines, "", "All set.")
+	}
+	if !asked {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "How /ask works", CallbackData: cbHowAsk}})
+	}
+	rows = append(rows, []gotgbot.InlineKeyboardButton{
+		{Text: "Refresh", CallbackData: cbSetup},
+		{Text: "Back to menu", CallbackData: cbMenu},
+	})
+	return strings.Join(lines, "\n"), &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// setupTemplates shows how to create the first preset, with the chat's
+// provider names filled in where possible.
+func (s *Service) setupTemplates(b *gotgbot.Bot, ctx *ext.Context) error {
+	chatID, ok := s.callbackChatID(ctx)
+	if !ok {
+		return nil
+	}
+	text := promptTemplatesText()
+	if providers, err := s.store.ListProviders(context.Background(), chatID); err == nil && len(providers) > 0 {
+		names := make([]string, 0, len(providers))
+		for _, p := range providers {
+			names = append(names, p.Name)
+		}
+		text += "\n\nProviders in this chat: " + strings.Join(names, ", ") + "\nList a provider's models with /models <provider>."
+	}
+	return s.editOrReplyCallback(ctx, b, text, &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+		{{Text: "Back to checklist", CallbackData: cbSetup}},
+	}})
+}
+
+func (s *Service) setupDefault(b *gotgbot.Bot, ctx *ext.Context, name string) error {
+	chatID, userID, ok := s.requireAdmin(b, ctx)
+	if !ok {
+		s.answerCallback(b, ctx, "Only chat admins can set the default preset.", true)
+		return nil
+	}
+	if _, err := s.store.GetPresetWithProviderByName(context.Background(), chatID, name); err != nil {
+		s.answerCallback(b, ctx, "That preset no longer exists.", true)
+		return nil
+	}
+	if err := s.store.SetDefaultPreset(context.Background(), chatID, name); err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("set default preset from setup failed")
+		s.answerCallback(b, ctx, "Failed to set the default preset.", true)
+		return nil
+	}
+	_ = s.audit(chatID, userID, "preset_default", map[string]any{"name": name})
+	s.notifyChange(b, ctx, chatID, "set default preset to "+name)
+	text, markup := s.setupChecklist(chatID)
+	return s.editOrReplyCallback(ctx, b, text, markup)
+}
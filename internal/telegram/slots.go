@@ -0,0 +1,46 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+const aiDefaultUsage = "Usage: /ai_default <name>, or /ai_default <code|translate> <name|off>"
+
+func (s *Service) code(b *gotgbot.Bot, ctx *ext.Context) error {
+	return s.slotAsk(b, ctx, storage.SlotCode, "Usage: /code [--debug] <text>")
+}
+
+// defaultSlotsText lists each default slot and its preset.
+func (s *Service) defaultSlotsText(chatID int64) string {
+	lines := []string{"Default presets:"}
+	for _, slot := range storage.DefaultSlots {
+		name, err := s.store.GetSlotPresetName(context.Background(), chatID, slot)
+		switch {
+		case err == nil:
+			lines = append(lines, fmt.Sprintf("- %s: %s", slot, name))
+		case slot == storage.SlotGeneral:
+			lines = append(lines, fmt.Sprintf("- %s: <not set>", slot))
+		default:
+			lines = append(lines, fmt.Sprintf("- %s: <general>", slot))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// presetSlots returns the non-general slots each preset is the default of.
+func (s *Service) presetSlots(chatID int64) map[string][]string {
+	out := map[string][]string{}
+	for _, slot := range storage.DefaultSlots[1:] {
+		if name, err := s.store.GetSlotPresetName(context.Background(), chatID, slot); err == nil {
+			out[name] = append(out[name], slot)
+		}
+	}
+	return out
+}
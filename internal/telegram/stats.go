@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/storage"
+)
+
+// stats shows the caller what they would otherwise learn from refusals:
+// their usage this month, what is left of their rate limits and the
+// chat's default preset. Anyone in the chat may run it; it changes nothing.
+func (s *Service) stats(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil {
+		return nil
+	}
+	return s.reply(ctx, b, s.statsText(ctx.EffectiveChat.Id, ctx.EffectiveUser.Id))
+}
+
+func (s *Service) statsText(chatID, userID int64) string {
+	bg := context.Background()
+	now := s.now()
+	y, m, _ := now.UTC().Date()
+	monthStart := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+
+	lines := []string{"Your stats in this chat"}
+	if sum, err := s.store.UserUsage(bg, chatID, userID, now.Sub(monthStart)); err != nil {
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("failed to load user usage")
+		lines = append(lines, "This month: unavailable")
+	} else {
+		lines = append(lines, fmt.Sprintf("This month: %d requests (%d failed), %d tokens in, %d out", sum.Requests, sum.Failed, sum.InputTokens, sum.OutputTokens))
+	}
+
+	lines = append(lines, s.rateStatsLines(chatID, userID, now)...)
+
+	if s.billing.FreeRequests > 0 && s.quota != nil {
+		if used, err := s.quota.Used(bg, chatID, now); err == nil {
+			lines = append(lines, fmt.Sprintf("Chat free requests left this month: %d of %d", max(s.billing.FreeRequests-used, 0), s.billing.FreeRequests))
+		}
+	}
+
+	name, err := s.store.GetDefaultPresetName(bg, chatID)
+	defaultPreset := name
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		defaultPreset = "<not set>"
+		if s.fallback != nil {
+			if _, ok, err := s.fallback.Get(bg); err == nil && ok {
+				defaultPreset = "<not set>, the bot's trial preset answers /ask"
+			}
+		}
+	case err != nil:
+		s.logger.Error().Err(err).Int64("chat_id", chatID).Msg("failed to load default preset")
+		defaultPreset = "unavailable"
+	}
+	lines = append(lines, "Default preset: "+defaultPreset)
+	return strings.Join(lines, "\n")
+}
+
+// rateStatsLines reports what is left of the hourly limit and the chat's
+// daily cap without counting toward them.
+func (s *Service) rateStatsLines(chatID, userID int64, now time.Time) []string {
+	if s.rateLimiter == nil {
+		return []string{"Rate limit: none"}
+	}
+	if s.rateExempt(chatID, userID) {
+		return []string{"Rate limit: you are exempt in this chat"}
+	}
+	bg := context.Background()
+	used, resetAt, err := s.rateLimiter.Used(bg, chatID, userID, now)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to read rate limit")
+		return []string{"Rate limit: unavailable"}
+	}
+	windows := []rateWindow{{Label: "This hour", Used: used, Limit: s.rateLimiter.Limit(), ResetAt: resetAt}}
+	if daily := s.dailyLimit(chatID); daily > 0 {
+		if used, resetAt, err := s.rateLimiter.UsedDaily(bg, chatID, userID, now); err == nil {
+			windows = append(windows, rateWindow{Label: "Today", Used: used, Limit: daily, ResetAt: resetAt})
+		}
+	}
+	var lines []string
+	for _, w := range windows {
+		lines = append(lines, fmt.Sprintf("%s: %d of %d requests left, resets in %s", w.Label, max(w.Limit-w.Used, 0), w.Limit, formatCountdown(w.ResetAt.Sub(now))))
+	}
+	return lines
+}
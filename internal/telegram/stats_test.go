@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+func TestStatsText(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	store := openTestStore(t)
+	if err := store.EnsureChat(ctx, -100, "supergroup", "team"); err != nil {
+		t.Fatalf("ensure chat: %v", err)
+	}
+	limiter := queue.NewRateLimiter(rdb, 5)
+	s := &Service{store: store, rateLimiter: limiter, logger: zerolog.Nop()}
+
+	now := time.Now().UTC()
+	if _, _, _, err := limiter.Allow(ctx, -100, 7, now); err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	if _, _, _, err := limiter.AllowDaily(ctx, -100, 7, 10, now); err != nil {
+		t.Fatalf("allow daily: %v", err)
+	}
+	if err := store.SetChatSetting(ctx, -100, storage.SettingDailyLimit, "10"); err != nil {
+		t.Fatalf("set daily limit: %v", err)
+	}
+	checkLines(t, s.statsText(-100, 7),
+		"This month: 0 requests (0 failed)", "This hour: 4 of 5 requests left", "Today: 9 of 10 requests left", "Default preset: <not set>")
+
+	if err := store.SetChatSetting(ctx, -100, storage.SettingRateExempt, "7"); err != nil {
+		t.Fatalf("set exempt: %v", err)
+	}
+	text := s.statsText(-100, 7)
+	checkLines(t, text, "Rate limit: you are exempt in this chat")
+	if strings.Contains(text, "This hour") {
+		t.Errorf("exempt stats show the hourly limit:\n%s", text)
+	}
+
+	// With the database and Redis gone every part says so instead of
+	// passing for an empty value.
+	_ = store.Close()
+	mr.Close()
+	checkLines(t, s.statsText(-100, 7), "This month: unavailable", "Rate limit: unavailable", "Default preset: unavailable")
+}
+
+func checkLines(t *testing.T, text string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(text, w) {
+			t.Errorf("stats missing %q:\n%s", w, text)
+		}
+	}
+}
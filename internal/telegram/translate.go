@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+const trUsage = "Usage: /tr [to:<language>] <text>, or reply /tr to a message. The source language is detected; the target defaults to the chat's reply language or English."
+
+// tr translates text, or the message it replies to, with the chat's
+// translate default preset. Buttons under the answer translate it again
+// into other languages.
+func (s *Service) tr(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	if msg == nil || ctx.EffectiveChat == nil {
+		return nil
+	}
+	text := strings.TrimSpace(commandRemainder(msg.GetText()))
+	target := ""
+	if first, rest := splitFirstWord(text); strings.HasPrefix(strings.ToLower(first), "to:") && len(first) > 3 {
+		target, text = prompt.LanguageName(first[3:]), strings.TrimSpace(rest)
+	}
+	if text == "" {
+		text = replyText(msg)
+	}
+	if text == "" {
+		return s.reply(ctx, b, trUsage)
+	}
+	if target == "" {
+		target = "English"
+		if lang, err := s.store.GetChatSetting(context.Background(), ctx.EffectiveChat.Id, storage.SettingReplyLanguage); err == nil && lang != "" {
+			target = lang
+		}
+	}
+
+	if !s.allowRate(ctx.EffectiveChat.Id, userID(ctx), b, ctx) {
+		return nil
+	}
+	s.ensureChat(context.Background(), msg)
+	return s.enqueueAsk(b, ctx, queue.AskJob{
+		ChatID:      ctx.EffectiveChat.Id,
+		ChatType:    ctx.EffectiveChat.Type,
+		UserID:      userID(ctx),
+		MessageID:   msg.MessageId,
+		Prompt:      text,
+		Slot:        storage.SlotTranslate,
+		TranslateTo: target,
+	})
+}
+
+// replyText is the text of the message msg replies to, or the part of it
+// msg quotes. Unlike quotedContext it accepts one's own messages.
+func replyText(msg *gotgbot.Message) string {
+	if msg.Quote != nil && strings.TrimSpace(msg.Quote.Text) != "" {
+		return strings.TrimSpace(msg.Quote.Text)
+	}
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.ForumTopicCreated != nil {
+		return ""
+	}
+	text := strings.TrimSpace(reply.GetText())
+	if r := []rune(text); len(r) > maxQuotedRunes {
+		text = string(r[:maxQuotedRunes])
+	}
+	return text
+}
+
+// translateAgain handles the language buttons under a /tr answer. Anyone in
+// the chat may tap them; the new job counts against the tapper's rate limit.
+func (s *Service) translateAgain(b *gotgbot.Bot, ctx *ext.Context, data string) error {
+	token, code, _ := strings.Cut(data, ":")
+	if s.picks == nil || ctx.EffectiveUser == nil || code == "" {
+		return nil
+	}
+	pick, found, err := s.picks.Get(context.Background(), token)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to load translation job")
+		s.answerCallback(b, ctx, "Failed to load the text. Use /tr again.", true)
+		return nil
+	}
+	if !found {
+		s.answerCallback(b, ctx, "This translation expired. Use /tr again.", true)
+		return nil
+	}
+	if !s.allowRate(pick.Job.ChatID, ctx.EffectiveUser.Id, b, ctx) {
+		return nil
+	}
+	job := pick.Job
+	job.UserID = ctx.EffectiveUser.Id
+	job.TranslateTo = prompt.LanguageName(code)
+	return s.requeueParked(b, ctx, job)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 
 	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
 )
 
 const (
@@ -32,7 +33,8 @@ func (s *Service) menu(b *gotgbot.Bot, ctx *ext.Context) error {
 }
 
 func (s *Service) setup(b *gotgbot.Bot, ctx *ext.Context) error {
-	return s.replyWithMarkup(ctx, b, s.setupText(), s.setupKeyboard())
+	text, markup := s.setupView(ctx)
+	return s.replyWithMarkup(ctx, b, text, markup)
 }
 
 func (s *Service) status(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -56,12 +58,22 @@ func (s *Service) mainMenuText(ctx *ext.Context) string {
 		"Quick commands:",
 		"/ask <text> - ask using default preset",
 		"/ai <preset> <text> - ask using explicit preset",
+		"/code <text> - ask using the code default preset",
+		"/tr [to:<language>] <text> - translate, or reply /tr to a message",
+		"/tpl <name> [var=value ...] <input> - ask with a chat template, /tpl_list lists them",
+		"/kb - pinned messages answers can draw on",
 		"/ai_list - list chat presets",
 		"/status - chat status",
+		"/stats - your usage, rate limit left and the default preset",
+		"/export [md|json] - export your conversation",
+		"/forget_me - delete your data",
+		"/balance, /buy - credits left, buy a pack",
+		"/admin_refresh - recheck admin rights",
 		"",
 		"Admin commands (group/supergroup):",
-		"/llm_add, /llm_list, /llm_del",
-		"/ai_preset_add, /ai_preset_del, /ai_default",
+		"/llm_add, /llm_list, /llm_show, /llm_del, /llm_limits, /llm_set, /models",
+		"/ai_preset_add, /preset_add_template, /ai_preset_del, /ai_preset_param, /ai_default, /preset_history, /preset_rollback, /preview, /preset_stats, /ab_start, /ab_report, /ab_stop",
+		"/whois, /ratelimit_exempt, /settings, /bot_off, /bot_on, /prefixes, /mention_mode, /persona_set, /persona_clear, /export_policy, /forget_chat",
 		"",
 		fmt.Sprintf("Chat type: %s", chatType),
 		fmt.Sprintf("Access mode: %s", s.accessMode),
@@ -78,6 +90,7 @@ func (s *Service) setupText() string {
 		"3) Finish provider wizard in private chat",
 		"4) Back in group, create preset:",
 		"   /ai_preset_add <name> <provider> <model> <system_prompt...>",
+		"   or from a template: /preset_add_template",
 		"5) Set default preset: /ai_default <name>",
 		"6) Ask: /ask <text>",
 	}, "\n")
@@ -94,6 +107,8 @@ func (s *Service) askUsageText() string {
 		"- Uses the chat default preset",
 		"- Queues request asynchronously",
 		"- Sends reply when worker finishes",
+		"- Editing the message while it is still queued replaces the question",
+		"- Admins: /ask --debug <text> also sends the resolved preset, provider endpoint, params, tokens, attempts and latency",
 	}, "\n")
 }
 
@@ -108,6 +123,7 @@ func (s *Service) aiUsageText() string {
 		"- Uses explicit preset",
 		"- Good when chat has several presets",
 		"- Use /ai_list to see available names",
+		"- Admins: /ai --debug <preset> <text> adds request details",
 	}, "\n")
 }
 
@@ -117,13 +133,45 @@ func (s *Service) adminHelpText() string {
 		"",
 		"Providers:",
 		"/llm_add",
+		"/wizards (private chat: switch between open provider wizards)",
 		"/llm_list",
+		"/llm_show <name>",
 		"/llm_del <name>",
+		"/llm_limits <name> [max_request_bytes|-] [max_response_bytes|-]",
+		"/llm_set <name> <key> <value|->",
+		"/models <provider> [refresh]",
 		"",
 		"Presets:",
 		"/ai_preset_add <name> <provider> <model> <system_prompt...>",
+		"/preset_add_template <template> <provider> <model> [name]",
 		"/ai_preset_del <name>",
+		"/ai_preset_param <name> [key] [value|-]",
 		"/ai_default <name>",
+		"/ai_default <code|translate> <name|off>",
+		"/tpl_add <name> <prompt with {{variables}}>, /tpl_del <name>",
+		"/kb_refresh, /kb_del <#id|description> - maintain the knowledge base",
+		"/preset_history <name> - earlier configurations of a preset",
+		"/preset_rollback <name> <rev>",
+		"/preview <preset> <text> - show the final prompts without calling the provider",
+		"/preset_stats - answer votes and latency per preset",
+		"/ab_start <presetA> <presetB> [percent for A]",
+		"/ab_report, /ab_stop",
+		"",
+		"Users:",
+		"/whois <@username|user_id>",
+		"/ratelimit_exempt [del] <@username|user_id> - let trusted members skip the rate limits",
+		"/admin_refresh - recheck admin rights after promotions or demotions",
+		"",
+		"Chat:",
+		"/prefixes <chars|off> - alias prefixes like !ask or .ai",
+		"/settings - toggle menu for ack, mention, exports, formatting",
+		"/settings <key> <value> - e.g. reply_language German, ack reaction",
+		"/mention_mode <on|off> - answer @mentions and replies to the bot",
+		"/persona_set <text> - prompt prefix for every preset",
+		"/persona_clear - remove the chat persona",
+		"/export_policy <on|off> - allow /export in this chat",
+		"/forget_chat - delete all data stored for this chat",
+		"/bot_off, /bot_on - pause the bot in this chat and resume it",
 	}, "\n")
 }
 
@@ -136,21 +184,37 @@ func (s *Service) statusText(ctx *ext.Context) string {
 	chatType := ctx.EffectiveChat.Type
 
 	presetCount := 0
+	var degraded []string
 	if presets, err := s.store.ListPresets(context.Background(), chatID); err == nil {
 		presetCount = len(presets)
+		for _, p := range presets {
+			if p.DegradedReason != "" {
+				degraded = append(degraded, fmt.Sprintf("- %s: %s", p.Name, p.DegradedReason))
+			}
+		}
 	}
 
 	providerCount := 0
+	var failing []string
 	if providers, err := s.store.ListProviders(context.Background(), chatID); err == nil {
 		providerCount = len(providers)
+		for _, p := range providers {
+			if p.Failing() {
+				failing = append(failing, fmt.Sprintf("- %s: %s", p.Name, providerHealthLine(p)))
+			}
+		}
 	}
 
 	defaultPreset := "<not set>"
 	if name, err := s.store.GetDefaultPresetName(context.Background(), chatID); err == nil {
 		defaultPreset = name
+	} else if presetCount == 0 && s.fallback != nil {
+		if _, ok, err := s.fallback.Get(context.Background()); err == nil && ok {
+			defaultPreset = "<not set>, the bot's trial preset answers /ask"
+		}
 	}
 
-	return strings.Join([]string{
+	lines := []string{
 		"Chat status",
 		fmt.Sprintf("chat_id: %d", chatID),
 		fmt.Sprintf("chat_type: %s", chatType),
@@ -158,7 +222,34 @@ func (s *Service) statusText(ctx *ext.Context) string {
 		fmt.Sprintf("presets: %d", presetCount),
 		fmt.Sprintf("default_preset: %s", defaultPreset),
 		fmt.Sprintf("access_mode: %s", s.accessMode),
-	}, "\n")
+	}
+	for _, slot := range storage.DefaultSlots[1:] {
+		if name, err := s.store.GetSlotPresetName(context.Background(), chatID, slot); err == nil {
+			lines = append(lines, fmt.Sprintf("default_preset_%s: %s", slot, name))
+		}
+	}
+	if onboarding, err := s.store.GetChatSetting(context.Background(), chatID, storage.SettingOnboarding); err == nil && onboarding != "" {
+		lines = append(lines, fmt.Sprintf("onboarding: %s", onboarding))
+	}
+	if persona, err := s.store.GetChatPersona(context.Background(), chatID); err == nil && persona != "" {
+		r := []rune(persona)
+		if len(r) > 120 {
+			persona = string(r[:120]) + "..."
+		}
+		lines = append(lines, fmt.Sprintf("persona: %s", persona))
+	}
+	if line := s.maintenanceStatusLine(); line != "" {
+		lines = append(lines, line)
+	}
+	if len(degraded) > 0 {
+		lines = append(lines, "degraded_presets:")
+		lines = append(lines, degraded...)
+	}
+	if len(failing) > 0 {
+		lines = append(lines, "failing_providers:")
+		lines = append(lines, failing...)
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (s *Service) buildPresetListText(chatID int64) (string, error) {
@@ -171,12 +262,19 @@ func (s *Service) buildPresetListText(chatID int64) (string, error) {
 	}
 
 	defaultName, _ := s.store.GetDefaultPresetName(context.Background(), chatID)
+	slots := s.presetSlots(chatID)
 	lines := []string{"Presets:"}
 	for _, p := range presets {
 		line := fmt.Sprintf("- %s (%s)", p.Name, p.Model)
 		if p.Name == defaultName {
 			line += " [default]"
 		}
+		for _, slot := range slots[p.Name] {
+			line += " [" + slot + "]"
+		}
+		if p.DegradedReason != "" {
+			line += " [degraded]"
+		}
 		lines = append(lines, line)
 	}
 	return strings.Join(lines, "\n"), nil
@@ -241,11 +339,12 @@ func (s *Service) replyWithMarkup(ctx *ext.Context, b *gotgbot.Bot, text string,
 	if ctx == nil || ctx.EffectiveChat == nil {
 		return nil
 	}
-	opts := &gotgbot.SendMessageOpts{}
-	if markup != nil {
-		opts.ReplyMarkup = *markup
-	}
-	_, err := b.SendMessage(ctx.EffectiveChat.Id, text, opts)
+	_, err := s.sender.Send(context.Background(), tgsend.Message{
+		ChatID:   ctx.EffectiveChat.Id,
+		ThreadID: threadID(ctx),
+		Text:     text,
+		Markup:   markup,
+	})
 	return err
 }
 
@@ -0,0 +1,167 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"hyprbot/internal/notify"
+	"hyprbot/internal/storage"
+)
+
+var webhookNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// recentDeliveries is how many deliveries /owner_webhooks lists.
+const recentDeliveries = 10
+
+// ownerWebhookAdd adds or replaces an outbound webhook. Without a secret
+// one is generated and shown once; a secret typed by the owner is deleted
+// from the chat.
+func (s *Service) ownerWebhookAdd(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	usage := "Usage: /owner_webhook_add <name> <url> [all|" + strings.Join(notify.EventTypes, ",") + "] [secret]"
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.Text))
+	if len(args) < 2 || len(args) > 4 {
+		return s.reply(ctx, b, usage)
+	}
+	name := strings.ToLower(args[0])
+	if !webhookNameRe.MatchString(name) {
+		return s.reply(ctx, b, "Webhook names are up to 32 lowercase letters, digits, - or _.")
+	}
+	u, err := url.Parse(args[1])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return s.reply(ctx, b, "The webhook URL must be an http(s) URL.")
+	}
+	var events []string
+	if len(args) > 2 && args[2] != "all" {
+		for _, ev := range strings.Split(args[2], ",") {
+			ev = strings.TrimSpace(ev)
+			if !slices.Contains(notify.EventTypes, ev) {
+				return s.reply(ctx, b, fmt.Sprintf("Unknown event %q. Events: %s.", ev, strings.Join(notify.EventTypes, ", ")))
+			}
+			if !slices.Contains(events, ev) {
+				events = append(events, ev)
+			}
+		}
+	}
+	secret, note := "", ""
+	if len(args) == 4 {
+		secret = args[3]
+		note = " " + s.deleteSecretMessage(b, ctx)
+	} else {
+		if secret, err = notify.NewSecret(); err != nil {
+			s.logger.Error().Err(err).Msg("generate webhook secret failed")
+			return s.reply(ctx, b, "Failed to add the webhook.")
+		}
+		note = "\nSigning secret (shown once): " + secret
+	}
+	enc, err := s.crypto.MarshalEncryptedString(secret)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("encrypt webhook secret failed")
+		return s.reply(ctx, b, "Failed to add the webhook.")
+	}
+	uid := ctx.EffectiveUser.Id
+	if err := s.store.SaveWebhook(context.Background(), storage.Webhook{
+		Name:      name,
+		URL:       u.String(),
+		EncSecret: enc,
+		Events:    events,
+		CreatedBy: uid,
+	}); err != nil {
+		s.logger.Error().Err(err).Str("webhook", name).Msg("save webhook failed")
+		return s.reply(ctx, b, "Failed to add the webhook.")
+	}
+	s.notify.Invalidate()
+	_ = s.audit(0, uid, "webhook_add", map[string]any{"name": name, "host": u.Host, "events": events})
+	return s.reply(ctx, b, fmt.Sprintf("Webhook %s saved for %s.%s", name, eventsText(events), note))
+}
+
+// deleteSecretMessage removes the message carrying a webhook secret and
+// returns the sentence telling the owner whether that worked.
+func (s *Service) deleteSecretMessage(b *gotgbot.Bot, ctx *ext.Context) string {
+	msg := ctx.EffectiveMessage
+	if _, err := b.DeleteMessageWithContext(context.Background(), msg.Chat.Id, msg.MessageId, nil); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to delete webhook secret message")
+		return "Delete your message with the secret yourself."
+	}
+	return "Your message with the secret was deleted."
+}
+
+func (s *Service) ownerWebhookDel(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	args := strings.Fields(commandRemainder(ctx.EffectiveMessage.Text))
+	if len(args) != 1 {
+		return s.reply(ctx, b, "Usage: /owner_webhook_del <name>")
+	}
+	name := strings.ToLower(args[0])
+	if err := s.store.DeleteWebhook(context.Background(), name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return s.reply(ctx, b, "Unknown webhook.")
+		}
+		s.logger.Error().Err(err).Str("webhook", name).Msg("delete webhook failed")
+		return s.reply(ctx, b, "Failed to delete the webhook.")
+	}
+	s.notify.Invalidate()
+	_ = s.audit(0, ctx.EffectiveUser.Id, "webhook_del", map[string]any{"name": name})
+	return s.reply(ctx, b, "Webhook "+name+" deleted.")
+}
+
+// ownerWebhooks lists the webhooks and the latest deliveries.
+func (s *Service) ownerWebhooks(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) {
+		return nil
+	}
+	hooks, err := s.store.ListWebhooks(context.Background())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list webhooks failed")
+		return s.reply(ctx, b, "Failed to load webhooks.")
+	}
+	if len(hooks) == 0 {
+		return s.reply(ctx, b, "No webhooks. Add one with /owner_webhook_add <name> <url>.")
+	}
+	lines := []string{"Webhooks:"}
+	for _, h := range hooks {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", h.Name, h.URL, eventsText(h.Events)))
+	}
+	deliveries, err := s.store.RecentWebhookDeliveries(context.Background(), recentDeliveries)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list webhook deliveries failed")
+	}
+	if len(deliveries) > 0 {
+		lines = append(lines, "", "Latest deliveries:")
+	}
+	for _, d := range deliveries {
+		line := fmt.Sprintf("%s %s -> %s: %s after %d attempts", d.CreatedAt.UTC().Format("01-02 15:04"), d.EventType, d.WebhookName, d.Status, d.Attempts)
+		if d.Status != storage.DeliveryDelivered && d.LastError != "" {
+			errText := []rune(d.LastError)
+			if len(errText) > 80 {
+				errText = append(errText[:80], '…')
+			}
+			line += " (" + string(errText) + ")"
+		}
+		lines = append(lines, line)
+	}
+	out := strings.Join(lines, "\n")
+	if r := []rune(out); len(r) > 4000 {
+		out = string(r[:4000])
+	}
+	return s.reply(ctx, b, out)
+}
+
+func eventsText(events []string) string {
+	if len(events) == 0 {
+		return "all events"
+	}
+	return strings.Join(events, ", ")
+}
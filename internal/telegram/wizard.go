@@ -4,21 +4,42 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/redis/go-redis/v9"
+
+	"hyprbot/internal/providers/anthropic_messages"
 )
 
 type llmWizardState struct {
-	TargetChatID int64  `json:"target_chat_id"`
+	TargetChatID int64 `json:"target_chat_id"`
+	// TargetTitle labels the session in the /wizards picker.
+	TargetTitle  string `json:"target_title,omitempty"`
 	Step         string `json:"step"`
 	Kind         string `json:"kind"`
 	Name         string `json:"name"`
 	BaseURL      string `json:"base_url"`
 	Endpoint     string `json:"endpoint"`
 	HeadersJSON  string `json:"headers_json"`
+	TLSJSON      string `json:"tls_json,omitempty"`
+	ResponsePath string `json:"response_path,omitempty"`
+	SigningJSON  string `json:"signing_json,omitempty"`
+}
+
+func (st llmWizardState) label() string {
+	if st.TargetTitle != "" {
+		return st.TargetTitle
+	}
+	return fmt.Sprintf("chat %d", st.TargetChatID)
 }
 
+// wizardStore keeps one wizard session per (admin, target chat), so an admin
+// can set up providers for several chats at once. A sorted set per admin
+// indexes the sessions by expiry and a pointer names the one private
+// messages go to.
 type wizardStore struct {
 	redis *redis.Client
 	ttl   time.Duration
@@ -28,20 +49,127 @@ func newWizardStore(rdb *redis.Client, ttl time.Duration) *wizardStore {
 	return &wizardStore{redis: rdb, ttl: ttl}
 }
 
-func (w *wizardStore) key(userID int64) string {
-	return fmt.Sprintf("hyprbot:wizard:%d", userID)
+func (w *wizardStore) key(userID, chatID int64) string {
+	return fmt.Sprintf("hyprbot:wizard:%d:%d", userID, chatID)
+}
+
+func (w *wizardStore) indexKey(userID int64) string {
+	return fmt.Sprintf("hyprbot:wizards:%d", userID)
+}
+
+func (w *wizardStore) activeKey(userID int64) string {
+	return fmt.Sprintf("hyprbot:wizards:%d:active", userID)
 }
 
+// Set saves the session for state.TargetChatID and makes it the active one.
 func (w *wizardStore) Set(ctx context.Context, userID int64, state llmWizardState) error {
 	b, err := json.Marshal(state)
 	if err != nil {
 		return err
 	}
-	return w.redis.Set(ctx, w.key(userID), string(b), w.ttl).Err()
+	expires := time.Now().Add(w.ttl)
+	_, err = w.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, w.key(userID, state.TargetChatID), string(b), w.ttl)
+		p.ZAdd(ctx, w.indexKey(userID), redis.Z{Score: float64(expires.Unix()), Member: state.TargetChatID})
+		p.Expire(ctx, w.indexKey(userID), w.ttl)
+		p.Set(ctx, w.activeKey(userID), state.TargetChatID, w.ttl)
+		return nil
+	})
+	return err
 }
 
+// Get returns the active session, or the only one when none is active. It
+// returns nil when there is no session or several to choose from.
 func (w *wizardStore) Get(ctx context.Context, userID int64) (*llmWizardState, error) {
-	raw, err := w.redis.Get(ctx, w.key(userID)).Result()
+	chatID, err := w.redis.Get(ctx, w.activeKey(userID)).Int64()
+	if err == nil {
+		state, err := w.get(ctx, userID, chatID)
+		if err != nil || state != nil {
+			return state, err
+		}
+	} else if err != redis.Nil {
+		return nil, err
+	}
+	sessions, err := w.Sessions(ctx, userID)
+	if err != nil || len(sessions) != 1 {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// Sessions lists the user's open sessions, dropping expired ones from the
+// index.
+func (w *wizardStore) Sessions(ctx context.Context, userID int64) ([]llmWizardState, error) {
+	index := w.indexKey(userID)
+	if err := w.redis.ZRemRangeByScore(ctx, index, "-inf", strconv.FormatInt(time.Now().Unix(), 10)).Err(); err != nil {
+		return nil, err
+	}
+	members, err := w.redis.ZRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var out []llmWizardState
+	for _, m := range members {
+		chatID, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		state, err := w.get(ctx, userID, chatID)
+		if err != nil {
+			return nil, err
+		}
+		if state == nil {
+			_ = w.redis.ZRem(ctx, index, m).Err()
+			continue
+		}
+		out = append(out, *state)
+	}
+	return out, nil
+}
+
+// Activate makes the session for chatID the active one. It returns nil when
+// that session has expired.
+func (w *wizardStore) Activate(ctx context.Context, userID, chatID int64) (*llmWizardState, error) {
+	state, err := w.get(ctx, userID, chatID)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	return state, w.redis.Set(ctx, w.activeKey(userID), chatID, w.ttl).Err()
+}
+
+// Clear ends the session for chatID.
+func (w *wizardStore) Clear(ctx context.Context, userID, chatID int64) error {
+	_, err := w.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, w.key(userID, chatID))
+		p.ZRem(ctx, w.indexKey(userID), chatID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if active, err := w.redis.Get(ctx, w.activeKey(userID)).Int64(); err == nil && active == chatID {
+		return w.redis.Del(ctx, w.activeKey(userID)).Err()
+	}
+	return nil
+}
+
+// ClearAll ends every session of the user.
+func (w *wizardStore) ClearAll(ctx context.Context, userID int64) error {
+	members, err := w.redis.ZRange(ctx, w.indexKey(userID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{w.indexKey(userID), w.activeKey(userID)}
+	for _, m := range members {
+		if chatID, err := strconv.ParseInt(m, 10, 64); err == nil {
+			keys = append(keys, w.key(userID, chatID))
+		}
+	}
+	return w.redis.Del(ctx, keys...).Err()
+}
+
+func (w *wizardStore) get(ctx context.Context, userID, chatID int64) (*llmWizardState, error) {
+	raw, err := w.redis.Get(ctx, w.key(userID, chatID)).Result()
 	if err == redis.Nil {
 		return nil, nil
 	}
@@ -55,6 +183,90 @@ func (w *wizardStore) Get(ctx context.Context, userID int64) (*llmWizardState, e
 	return &state, nil
 }
 
-func (w *wizardStore) Clear(ctx context.Context, userID int64) error {
-	return w.redis.Del(ctx, w.key(userID)).Err()
+// cbWizardPick is followed by a target chat id; it makes that chat's wizard
+// session the active one.
+const cbWizardPick = cbPrefix + "wiz:"
+
+// wizardStepPrompt repeats what the wizard asks for at state.Step.
+func wizardStepPrompt(state llmWizardState) string {
+	switch state.Step {
+	case "kind":
+		return "Send provider type: openai-compat, anthropic or custom-http"
+	case "name":
+		return "Send provider name (letters, digits, _ or -, max 64)."
+	case "base_url":
+		switch state.Kind {
+		case "openai_compat":
+			return "Send base URL (example: https://api.x.ai/v1)"
+		case "anthropic":
+			return "Send base URL or '-' for " + anthropic_messages.DefaultBaseURL
+		}
+		return "Send custom endpoint URL"
+	case "endpoint":
+		return "Send endpoint mode: chat_completions or responses"
+	case "headers":
+		return `Send headers JSON template (example: {"Authorization":"Bearer {{api_key}}"}) or '-'`
+	case "response_path":
+		return "Send response path to the answer (JSONPath like $.data.answer or template like {{.data.answer}}) or '-' to auto-detect"
+	case "signing":
+		return signingWizardPrompt
+	case "tls":
+		return tlsWizardPrompt
+	case "api_key":
+		return "Send API key (or '-' for empty)."
+	}
+	return ""
+}
+
+// wizards lists the admin's open wizard sessions with buttons to switch.
+func (s *Service) wizards(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveUser == nil || ctx.EffectiveChat.Type != "private" {
+		return nil
+	}
+	sessions, err := s.wizard.Sessions(context.Background(), ctx.EffectiveUser.Id)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("wizard sessions load failed")
+		return s.reply(ctx, b, "Failed to load wizards.")
+	}
+	if len(sessions) == 0 {
+		return s.reply(ctx, b, "No wizard is open. Start one with /llm_add in a group.")
+	}
+	return s.replyWithMarkup(ctx, b, "Open wizards. Pick the one your next messages are for:", wizardPickerKeyboard(sessions))
+}
+
+// offerWizardPicker answers a private message that could belong to several
+// wizard sessions.
+func (s *Service) offerWizardPicker(ctx *ext.Context, b *gotgbot.Bot) error {
+	sessions, err := s.wizard.Sessions(context.Background(), ctx.EffectiveUser.Id)
+	if err != nil || len(sessions) < 2 {
+		return nil
+	}
+	return s.replyWithMarkup(ctx, b, "You have wizards open for several chats. Pick one, then send your message again:", wizardPickerKeyboard(sessions))
+}
+
+func wizardPickerKeyboard(sessions []llmWizardState) *gotgbot.InlineKeyboardMarkup {
+	rows := make([][]gotgbot.InlineKeyboardButton, 0, len(sessions))
+	for _, st := range sessions {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{
+			Text:         st.label() + " (" + st.Step + ")",
+			CallbackData: fmt.Sprintf("%s%d", cbWizardPick, st.TargetChatID),
+		}})
+	}
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (s *Service) pickWizard(b *gotgbot.Bot, ctx *ext.Context, data string) error {
+	chatID, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || ctx.EffectiveUser == nil {
+		return nil
+	}
+	state, err := s.wizard.Activate(context.Background(), ctx.EffectiveUser.Id, chatID)
+	if err != nil {
+		s.answerCallback(b, ctx, "Failed to switch wizards.", true)
+		return nil
+	}
+	if state == nil {
+		return s.editOrReplyCallback(ctx, b, "That wizard has expired. Start again with /llm_add in the group.", nil)
+	}
+	return s.editOrReplyCallback(ctx, b, "Continuing the wizard for "+state.label()+". "+wizardStepPrompt(*state), nil)
 }
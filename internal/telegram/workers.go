@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// ownerWorkers lists the workers that sent a heartbeat recently.
+func (s *Service) ownerWorkers(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat == nil || ctx.EffectiveChat.Type != "private" || !s.isOwner(ctx) || s.workers == nil {
+		return nil
+	}
+	now := s.now()
+	workers, err := s.workers.Live(context.Background(), now)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list workers failed")
+		return s.reply(ctx, b, "Failed to load workers.")
+	}
+	if len(workers) == 0 {
+		return s.reply(ctx, b, "No live workers.")
+	}
+	lines := []string{fmt.Sprintf("Live workers: %d", len(workers))}
+	for _, w := range workers {
+		line := fmt.Sprintf("%s on %s: up %s, %d consumers, %d done, %d failed, last progress %s ago",
+			w.Consumer, w.Hostname, formatCountdown(now.Sub(w.StartedAt)), w.Consumers,
+			w.JobsProcessed, w.JobsFailed, formatCountdown(now.Sub(w.LastProgress)))
+		if w.Stalled {
+			line += " (STALLED)"
+		}
+		lines = append(lines, line)
+	}
+	out := strings.Join(lines, "\n")
+	if r := []rune(out); len(r) > 4000 {
+		out = string(r[:4000])
+	}
+	return s.reply(ctx, b, out)
+}
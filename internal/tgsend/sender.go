@@ -0,0 +1,257 @@
+// Package tgsend is the one path bot messages take to Telegram. It owns the
+// details every caller used to repeat: forum topic threads, reply targets
+// the user deleted, markup Telegram refuses to parse and texts over the
+// message limit. Flood waits are handled below it by the rate-limited client.
+package tgsend
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/metrics"
+)
+
+// MaxMessageRunes is Telegram's limit for one text message.
+const MaxMessageRunes = 4096
+
+type Message struct {
+	ChatID int64
+	// ThreadID keeps the message in a forum topic; 0 posts to General.
+	ThreadID int64
+	// ReplyTo is the message answered, if any.
+	ReplyTo   int64
+	Text      string
+	ParseMode string
+	// Plain is sent instead of Text when Telegram cannot parse the
+	// formatted text or it has to be split. Empty means Text.
+	Plain  string
+	Markup *gotgbot.InlineKeyboardMarkup
+	// Disclosure is appended as the last line of the text in its parse
+	// mode, after the last chunk when the text is split. Model answers
+	// carry the chat's disclosure policy here so no path leaves it out.
+	Disclosure string
+}
+
+type Options struct {
+	// DropOrphanReplies drops replies whose target was deleted instead of
+	// sending them as plain messages.
+	DropOrphanReplies bool
+	Logger            zerolog.Logger
+	Metrics           *metrics.Metrics
+}
+
+type Sender struct {
+	bot               *gotgbot.Bot
+	dropOrphanReplies bool
+	logger            zerolog.Logger
+	metrics           *metrics.Metrics
+}
+
+func New(bot *gotgbot.Bot, opts Options) *Sender {
+	m := opts.Metrics
+	if m == nil {
+		m = metrics.Global()
+	}
+	return &Sender{bot: bot, dropOrphanReplies: opts.DropOrphanReplies, logger: opts.Logger, metrics: m}
+}
+
+// Send delivers m and returns the first message sent. Texts over
+// MaxMessageRunes are sent unformatted in several messages; the reply goes
+// on the first and the markup on the last. A nil message with a nil error
+// means the reply was dropped because its target is gone.
+func (s *Sender) Send(ctx context.Context, m Message) (*gotgbot.Message, error) {
+	m = m.disclosed()
+	if len([]rune(m.Text)) <= MaxMessageRunes {
+		return s.sendOne(ctx, m)
+	}
+	var first *gotgbot.Message
+	chunks := Split(m.plain(), MaxMessageRunes)
+	for i, chunk := range chunks {
+		part := Message{ChatID: m.ChatID, ThreadID: m.ThreadID, Text: chunk}
+		if i == 0 {
+			part.ReplyTo = m.ReplyTo
+		}
+		if i == len(chunks)-1 {
+			part.Markup = m.Markup
+		}
+		msg, err := s.sendOne(ctx, part)
+		if err != nil {
+			return first, err
+		}
+		if msg == nil && i == 0 {
+			return nil, nil
+		}
+		if first == nil {
+			first = msg
+		}
+	}
+	return first, nil
+}
+
+// Edit replaces the text of a message the bot sent. Edits that change
+// nothing are not errors.
+func (s *Sender) Edit(ctx context.Context, chatID, messageID int64, text string, markup *gotgbot.InlineKeyboardMarkup) error {
+	return s.edit(ctx, Message{ChatID: chatID, Text: text, Markup: markup}, messageID)
+}
+
+// Replace edits messageID, a placeholder the bot sent, into m. When m does
+// not fit one message or the edit fails, m is sent as a new message instead.
+// It reports whether the placeholder now holds m.
+func (s *Sender) Replace(ctx context.Context, messageID int64, m Message) (bool, error) {
+	m = m.disclosed()
+	if messageID > 0 && len([]rune(m.Text)) <= MaxMessageRunes {
+		err := s.edit(ctx, m, messageID)
+		if err != nil && m.ParseMode != "" && isParseError(err) {
+			m.Text, m.ParseMode = m.plain(), ""
+			err = s.edit(ctx, m, messageID)
+		}
+		if err == nil {
+			s.metrics.TelegramMessagesSent.Inc()
+			return true, nil
+		}
+		s.logger.Debug().Err(err).Int64("chat_id", m.ChatID).Msg("placeholder edit failed, sending a new message")
+	}
+	_, err := s.Send(ctx, m)
+	return false, err
+}
+
+func (s *Sender) edit(ctx context.Context, m Message, messageID int64) error {
+	opts := &gotgbot.EditMessageTextOpts{ChatId: m.ChatID, MessageId: messageID, ParseMode: m.ParseMode}
+	if m.Markup != nil {
+		opts.ReplyMarkup = *m.Markup
+	}
+	_, _, err := s.bot.EditMessageTextWithContext(ctx, m.Text, opts)
+	if err != nil && isNotModified(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Sender) sendOne(ctx context.Context, m Message) (*gotgbot.Message, error) {
+	msg, err := s.send(ctx, m)
+	if err != nil && m.ParseMode != "" && isParseError(err) {
+		m.Text, m.ParseMode = m.plain(), ""
+		msg, err = s.send(ctx, m)
+	}
+	if err != nil && m.ReplyTo > 0 && isReplyTargetMissing(err) {
+		if s.dropOrphanReplies {
+			s.logger.Info().Int64("chat_id", m.ChatID).Int64("reply_to", m.ReplyTo).Msg("reply target deleted, dropping message")
+			return nil, nil
+		}
+		m.ReplyTo = 0
+		msg, err = s.send(ctx, m)
+	}
+	if err != nil {
+		s.metrics.TelegramSendFailures.Inc()
+		return nil, err
+	}
+	s.metrics.TelegramMessagesSent.Inc()
+	return msg, nil
+}
+
+func (s *Sender) send(ctx context.Context, m Message) (*gotgbot.Message, error) {
+	opts := &gotgbot.SendMessageOpts{ParseMode: m.ParseMode, MessageThreadId: m.ThreadID}
+	if m.ReplyTo > 0 {
+		opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: m.ReplyTo}
+	}
+	if m.Markup != nil {
+		opts.ReplyMarkup = *m.Markup
+	}
+	return s.bot.SendMessageWithContext(ctx, m.ChatID, m.Text, opts)
+}
+
+// disclosed returns m with its disclosure added to both the formatted and
+// the plain text.
+func (m Message) disclosed() Message {
+	if m.Disclosure == "" {
+		return m
+	}
+	if m.ParseMode != "" {
+		m.Plain = WithDisclosure(m.plain(), m.Disclosure, "")
+	}
+	m.Text = WithDisclosure(m.Text, m.Disclosure, m.ParseMode)
+	m.Disclosure = ""
+	return m
+}
+
+// WithDisclosure appends disclosure to text as its last paragraph, escaped
+// for parseMode.
+func WithDisclosure(text, disclosure, parseMode string) string {
+	if disclosure == "" {
+		return text
+	}
+	switch parseMode {
+	case "HTML":
+		disclosure = html.EscapeString(disclosure)
+	case "Markdown":
+		disclosure = markdownEscaper.Replace(disclosure)
+	}
+	return text + "\n\n" + disclosure
+}
+
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+func (m Message) plain() string {
+	if m.Plain != "" {
+		return m.Plain
+	}
+	return m.Text
+}
+
+// Split cuts text into chunks of at most limit runes, preferring line breaks
+// and then spaces so words are not cut in half.
+func Split(text string, limit int) []string {
+	var chunks []string
+	r := []rune(text)
+	for len(r) > limit {
+		cut := lastIndex(r[:limit], '\n')
+		if cut <= 0 {
+			cut = lastIndex(r[:limit], ' ')
+		}
+		if cut <= 0 {
+			cut = limit
+		}
+		chunks = append(chunks, string(r[:cut]))
+		r = r[cut:]
+		if len(r) > 0 && (r[0] == '\n' || r[0] == ' ') {
+			r = r[1:]
+		}
+	}
+	if len(r) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, string(r))
+	}
+	return chunks
+}
+
+func lastIndex(r []rune, c rune) int {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsUnreachable reports that Telegram refused to deliver to a user who
+// never started the bot, blocked it or deleted their account.
+func IsUnreachable(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "forbidden")
+}
+
+func isParseError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "can't parse entities")
+}
+
+func isNotModified(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "message is not modified")
+}
+
+func isReplyTargetMissing(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "message to be replied not found") ||
+		strings.Contains(msg, "replied message not found")
+}
@@ -0,0 +1,39 @@
+package tgsend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitPrefersLineBreaks(t *testing.T) {
+	text := strings.Repeat("a", 6) + "\n" + strings.Repeat("b", 3) + " " + strings.Repeat("c", 3)
+	got := Split(text, 8)
+	want := []string{"aaaaaa", "bbb ccc"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("Split = %q, want %q", got, want)
+	}
+}
+
+func TestSplitHardCutsLongWords(t *testing.T) {
+	got := Split(strings.Repeat("я", 10), 4)
+	if len(got) != 3 || got[0] != "яяяя" || got[2] != "яя" {
+		t.Fatalf("Split = %q", got)
+	}
+	if got := Split("", 4); len(got) != 1 || got[0] != "" {
+		t.Fatalf("Split(\"\") = %q", got)
+	}
+}
+
+func TestDisclosedEscapesForParseMode(t *testing.T) {
+	m := Message{Text: "*hi*", ParseMode: "Markdown", Disclosure: "#AI_generated"}.disclosed()
+	if m.Text != "*hi*\n\n#AI\\_generated" || m.Plain != "*hi*\n\n#AI_generated" || m.Disclosure != "" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	m = Message{Text: "<b>x</b>", Plain: "x", ParseMode: "HTML", Disclosure: "AI <bot>"}.disclosed()
+	if m.Text != "<b>x</b>\n\nAI &lt;bot&gt;" || m.Plain != "x\n\nAI <bot>" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	if got := (Message{Text: "x"}).disclosed(); got.Text != "x" {
+		t.Fatalf("empty disclosure changed text: %q", got.Text)
+	}
+}
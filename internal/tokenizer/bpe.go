@@ -0,0 +1,199 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPieceBytes bounds the text merged at once. Longer pieces, such as a
+// base64 blob, are cut so the quadratic merge stays cheap; counts differ
+// from tiktoken only at the cuts.
+const maxPieceBytes = 1024
+
+// BPE is a byte pair encoding read from a tiktoken rank file. It counts
+// the same tokens as tiktoken for ordinary text; special tokens such as
+// <|endoftext|> are counted as the text they are made of.
+type BPE struct {
+	name  string
+	ranks map[string]int
+	split *regexp.Regexp
+}
+
+// ws is Unicode whitespace as tiktoken's \s matches it; Go's \s is ASCII.
+const ws = `\t\n\v\f\r\x{85}\p{Z}`
+
+// Pre-tokenizer patterns of the tiktoken encodings, anchored for the
+// splitting loop. RE2 has no lookahead, so the `\s+(?!\S)` alternative is
+// left out here and applied by pieces.
+var (
+	gpt2Pattern = regexp.MustCompile(`^(?:'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^` + ws + `\p{L}\p{N}]+|[` + ws + `]+)`)
+
+	cl100kPattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^` + ws + `\p{L}\p{N}]+[\r\n]*|[` + ws + `]*[\r\n]+|[` + ws + `]+)`)
+
+	o200kPattern = regexp.MustCompile(`^(?:` +
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^` + ws + `\p{L}\p{N}]+[\r\n/]*|[` + ws + `]*[\r\n]+|[` + ws + `]+)`)
+)
+
+// bpeEncodings maps the tiktoken encodings this package reads to their
+// pre-tokenizer.
+var bpeEncodings = map[string]*regexp.Regexp{
+	"r50k_base":   gpt2Pattern,
+	"p50k_base":   gpt2Pattern,
+	"cl100k_base": cl100kPattern,
+	"o200k_base":  o200kPattern,
+}
+
+// LoadBPE reads the rank file of a tiktoken encoding, one base64 token and
+// its rank per line, as published for cl100k_base.tiktoken and the like.
+func LoadBPE(name string, r io.Reader) (*BPE, error) {
+	split, ok := bpeEncodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bpe encoding %q", name)
+	}
+	ranks := make(map[string]int, 1<<17)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, found := strings.Cut(text, " ")
+		if !found {
+			return nil, fmt.Errorf("%s line %d: want token and rank", name, line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		ranks[string(b)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", name)
+	}
+	return &BPE{name: name, ranks: ranks, split: split}, nil
+}
+
+// LoadBPEFile reads a rank file from disk.
+func LoadBPEFile(name, path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadBPE(name, f)
+}
+
+func (e *BPE) Name() string { return e.name }
+
+func (e *BPE) Count(text string) int {
+	n := 0
+	e.pieces(text, func(piece string) {
+		for len(piece) > maxPieceBytes {
+			cut := maxPieceBytes
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = maxPieceBytes
+			}
+			n += len(e.merge(piece[:cut]))
+			piece = piece[cut:]
+		}
+		n += len(e.merge(piece))
+	})
+	return n
+}
+
+// Encode returns the token ranks of text.
+func (e *BPE) Encode(text string) []int {
+	var tokens []int
+	e.pieces(text, func(piece string) {
+		tokens = append(tokens, e.merge(piece)...)
+	})
+	return tokens
+}
+
+// pieces splits text the way the encoding's pattern does.
+func (e *BPE) pieces(text string, fn func(string)) {
+	for text != "" {
+		loc := e.split.FindStringIndex(text)
+		end := 0
+		if loc != nil {
+			end = loc[1]
+		}
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		piece := text[:end]
+		// `\s+(?!\S)`: a run of spaces before a word leaves its last space
+		// to the word.
+		if end < len(text) && isSpaceRun(piece) {
+			if last, size := utf8.DecodeLastRuneInString(piece); last != '\r' && last != '\n' && size < len(piece) {
+				piece = piece[:len(piece)-size]
+			}
+		}
+		fn(piece)
+		text = text[len(piece):]
+	}
+}
+
+func isSpaceRun(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge applies the byte pair merges to one piece, lowest rank first.
+func (e *BPE) merge(piece string) []int {
+	if rank, ok := e.ranks[piece]; ok {
+		return []int{rank}
+	}
+	// bounds[i] is where part i starts; the last entry is the end.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	tokens := make([]int, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		rank, ok := e.ranks[piece[bounds[i]:bounds[i+1]]]
+		if !ok {
+			// Complete rank files cover every byte; a partial one still
+			// counts the byte as a token.
+			rank = -1
+		}
+		tokens = append(tokens, rank)
+	}
+	return tokens
+}
@@ -0,0 +1,171 @@
+// Package tokenizer counts the tokens a model makes of a text. OpenAI
+// models get their tiktoken byte pair encoding when its rank file is
+// available; every other model, and OpenAI ones without the file, get a
+// character based estimate.
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens of a text.
+type Tokenizer interface {
+	Count(text string) int
+	// Name is the encoding, e.g. "cl100k_base" or "heuristic".
+	Name() string
+}
+
+// EncodingHeuristic names the character based estimate in model tables.
+const EncodingHeuristic = "heuristic"
+
+// Heuristic estimates tokens from characters: ASCII text at CharsPerToken
+// characters a token, CJK characters at one token each and other scripts
+// at two characters a token.
+type Heuristic struct {
+	CharsPerToken float64
+}
+
+func (h Heuristic) Name() string { return EncodingHeuristic }
+
+func (h Heuristic) Count(text string) int {
+	var ascii, wide, other int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana):
+			wide++
+		default:
+			other++
+		}
+	}
+	n := float64(ascii)/h.CharsPerToken + float64(wide) + float64(other)/2
+	return int(n + 0.999)
+}
+
+var (
+	// fallback is deliberately pessimistic for models nothing is known
+	// about.
+	fallback = Heuristic{CharsPerToken: 3.5}
+	// openAIFallback stands in for a BPE encoding whose rank file is
+	// missing.
+	openAIFallback = Heuristic{CharsPerToken: 4}
+)
+
+// DefaultModels maps model name prefixes to encodings. Models matching no
+// prefix use the heuristic.
+var DefaultModels = map[string]string{
+	"gpt-4o":                 "o200k_base",
+	"gpt-4.1":                "o200k_base",
+	"gpt-4.5":                "o200k_base",
+	"gpt-5":                  "o200k_base",
+	"chatgpt-":               "o200k_base",
+	"o1":                     "o200k_base",
+	"o3":                     "o200k_base",
+	"o4":                     "o200k_base",
+	"gpt-4":                  "cl100k_base",
+	"gpt-3.5":                "cl100k_base",
+	"text-embedding-3":       "cl100k_base",
+	"text-embedding-ada-002": "cl100k_base",
+}
+
+// Registry picks the tokenizer of a model by the longest matching prefix
+// of its name, ignoring a "vendor/" prefix. A nil Registry uses the
+// heuristic for every model.
+type Registry struct {
+	// prefixes is sorted longest first.
+	prefixes  []string
+	models    map[string]string
+	encodings map[string]Tokenizer
+	missing   []string
+}
+
+// NewRegistry builds a registry from DefaultModels with models applied on
+// top; an empty encoding removes a default prefix. Rank files are read
+// from dir as <encoding>.tiktoken; an encoding whose file is missing falls
+// back to the heuristic and is reported by Missing.
+func NewRegistry(dir string, models map[string]string) (*Registry, error) {
+	r := &Registry{models: map[string]string{}, encodings: map[string]Tokenizer{EncodingHeuristic: fallback}}
+	for prefix, enc := range DefaultModels {
+		r.models[prefix] = enc
+	}
+	for prefix, enc := range models {
+		prefix = strings.ToLower(strings.TrimSpace(prefix))
+		enc = strings.TrimSpace(enc)
+		if prefix == "" {
+			return nil, errors.New("tokenizer model table: empty model prefix")
+		}
+		if enc == "" {
+			delete(r.models, prefix)
+			continue
+		}
+		if _, ok := bpeEncodings[enc]; !ok && enc != EncodingHeuristic {
+			return nil, fmt.Errorf("tokenizer model table: unknown encoding %q for %q", enc, prefix)
+		}
+		r.models[prefix] = enc
+	}
+	for prefix, enc := range r.models {
+		r.prefixes = append(r.prefixes, prefix)
+		if _, loaded := r.encodings[enc]; loaded {
+			continue
+		}
+		t, err := loadEncoding(dir, enc)
+		if errors.Is(err, os.ErrNotExist) {
+			r.missing = append(r.missing, enc)
+			r.encodings[enc] = openAIFallback
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.encodings[enc] = t
+	}
+	sort.Slice(r.prefixes, func(i, j int) bool {
+		if len(r.prefixes[i]) != len(r.prefixes[j]) {
+			return len(r.prefixes[i]) > len(r.prefixes[j])
+		}
+		return r.prefixes[i] < r.prefixes[j]
+	})
+	sort.Strings(r.missing)
+	return r, nil
+}
+
+func loadEncoding(dir, enc string) (Tokenizer, error) {
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	return LoadBPEFile(enc, filepath.Join(dir, enc+".tiktoken"))
+}
+
+// For returns the tokenizer of model.
+func (r *Registry) For(model string) Tokenizer {
+	if r == nil {
+		return fallback
+	}
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return r.encodings[r.models[prefix]]
+		}
+	}
+	return fallback
+}
+
+// Missing lists the encodings estimated because their rank file was not
+// found.
+func (r *Registry) Missing() []string {
+	if r == nil {
+		return nil
+	}
+	return r.missing
+}
@@ -0,0 +1,107 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rankFile is a tiny tiktoken rank file: every byte, then a few merges.
+func rankFile() string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, merge := range []string{"he", "ll", "hell", "hello", " w", "or", "ld"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), 256+i)
+	}
+	return b.String()
+}
+
+func TestBPEEncode(t *testing.T) {
+	e, err := LoadBPE("cl100k_base", strings.NewReader(rankFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := e.Encode("hello world!")
+	want := []int{259, 260, 261, 262, '!'}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Encode = %v, want %v", got, want)
+	}
+	if n := e.Count("hello world!"); n != len(want) {
+		t.Fatalf("Count = %d, want %d", n, len(want))
+	}
+	if n := e.Count(strings.Repeat("x", 3000)); n != 3000 {
+		t.Fatalf("Count(long piece) = %d, want 3000", n)
+	}
+	if _, err := LoadBPE("cl100k_base", strings.NewReader("not base64! 1\n")); err == nil {
+		t.Fatal("expected an error for a broken rank file")
+	}
+}
+
+func TestPiecesFollowTiktokenPatterns(t *testing.T) {
+	cases := []struct {
+		enc, text string
+		want      []string
+	}{
+		{"cl100k_base", "Hello world  foo's\n\n  bar 12345", []string{"Hello", " world", " ", " foo", "'s", "\n\n", " ", " bar", " ", "123", "45"}},
+		{"cl100k_base", "x  123", []string{"x", " ", " ", "123"}},
+		{"cl100k_base", "end   ", []string{"end", "   "}},
+		{"o200k_base", "Hello World's path/to", []string{"Hello", " World's", " path", "/to"}},
+		{"p50k_base", "it's  fine", []string{"it", "'s", " ", " fine"}},
+	}
+	for _, c := range cases {
+		e := &BPE{name: c.enc, split: bpeEncodings[c.enc]}
+		var got []string
+		e.pieces(c.text, func(p string) { got = append(got, p) })
+		if strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("%s pieces(%q) = %q, want %q", c.enc, c.text, got, c.want)
+		}
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	if n := (Heuristic{CharsPerToken: 4}).Count(strings.Repeat("a", 400)); n != 100 {
+		t.Fatalf("Count = %d, want 100", n)
+	}
+	if n := (Heuristic{CharsPerToken: 4}).Count("漢字漢字"); n != 4 {
+		t.Fatalf("Count(CJK) = %d, want 4", n)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), []byte(rankFile()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(dir, map[string]string{"my-gpt": "cl100k_base", "o1": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for model, want := range map[string]string{
+		"openai/gpt-4-turbo": "cl100k_base",
+		"My-GPT-large":       "cl100k_base",
+		"claude-sonnet-4":    EncodingHeuristic,
+		"gpt-4o-mini":        EncodingHeuristic, // o200k_base has no rank file
+	} {
+		if got := r.For(model).Name(); got != want {
+			t.Errorf("For(%q) = %s, want %s", model, got, want)
+		}
+	}
+	if fmt.Sprint(r.Missing()) != "[o200k_base]" {
+		t.Fatalf("Missing = %v", r.Missing())
+	}
+	if r.For("o1-preview") != fallback {
+		t.Fatal("removed prefix should use the fallback")
+	}
+	if _, err := NewRegistry(dir, map[string]string{"gpt": "gpt2"}); err == nil {
+		t.Fatal("expected an error for an unknown encoding")
+	}
+	var none *Registry
+	if none.For("gpt-4o").Name() != EncodingHeuristic {
+		t.Fatal("nil registry should use the heuristic")
+	}
+}
@@ -0,0 +1,77 @@
+package webfetch
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Boilerplate elements are dropped with their content before extraction.
+var boilerplate = func() []*regexp.Regexp {
+	tags := []string{"script", "style", "noscript", "template", "svg", "iframe", "nav", "header", "footer", "aside", "form"}
+	out := []*regexp.Regexp{regexp.MustCompile(`(?s)<!--.*?-->`)}
+	for _, t := range tags {
+		out = append(out, regexp.MustCompile(`(?is)<`+t+`\b.*?</`+t+`\s*>`))
+	}
+	return out
+}()
+
+var (
+	titleRe  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	blockRe  = regexp.MustCompile(`(?i)</?(p|div|br|li|ul|ol|h[1-6]|tr|table|section|article|main|blockquote|pre|dd|dt)\b[^>]*>`)
+	tagRe    = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacesRe = regexp.MustCompile(`[ \t\f\v\x{00a0}]+`)
+)
+
+// containers are tried in order; the first one holding enough text is taken
+// as the readable part of the page.
+var containers = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)<article\b[^>]*>(.*)</article\s*>`),
+	regexp.MustCompile(`(?is)<main\b[^>]*>(.*)</main\s*>`),
+	regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body\s*>`),
+}
+
+// minContainerRunes is how much text an article or main element needs before
+// the rest of the page is ignored.
+const minContainerRunes = 200
+
+// Extract returns the page title and its readable text: scripts, navigation
+// and other boilerplate are dropped and the article or main element is
+// preferred over the whole body.
+func Extract(page string) (title, text string) {
+	if m := titleRe.FindStringSubmatch(page); m != nil {
+		title = collapse(html.UnescapeString(tagRe.ReplaceAllString(m[1], "")))
+	}
+	for _, re := range boilerplate {
+		page = re.ReplaceAllString(page, " ")
+	}
+	text = toText(page)
+	for _, re := range containers {
+		m := re.FindStringSubmatch(page)
+		if m == nil {
+			continue
+		}
+		if t := toText(m[1]); len([]rune(t)) >= minContainerRunes || re == containers[len(containers)-1] {
+			text = t
+			break
+		}
+	}
+	return title, text
+}
+
+func toText(fragment string) string {
+	fragment = blockRe.ReplaceAllString(fragment, "\n")
+	fragment = html.UnescapeString(tagRe.ReplaceAllString(fragment, ""))
+	lines := strings.Split(fragment, "\n")
+	out := lines[:0]
+	for _, l := range lines {
+		if l = collapse(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(spacesRe.ReplaceAllString(s, " "))
+}
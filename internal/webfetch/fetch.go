@@ -0,0 +1,164 @@
+// Package webfetch downloads public web pages for the fetch_url tool and
+// reduces them to readable text. Connections to loopback, private,
+// link-local and other non-public addresses are refused after DNS
+// resolution, so redirects and rebinding cannot reach internal services.
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrBlockedAddress = errors.New("address is not public")
+	ErrUnsupportedURL = errors.New("only http and https urls can be fetched")
+	ErrNotText        = errors.New("page is not html or text")
+)
+
+// runesPerToken approximates the token budget in characters.
+const runesPerToken = 4
+
+type Config struct {
+	Timeout time.Duration
+	// MaxBytes caps the downloaded body; MaxTokens caps the extracted text.
+	MaxBytes  int64
+	MaxTokens int
+	UserAgent string
+}
+
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+}
+
+// Page is the readable part of a fetched page.
+type Page struct {
+	URL       string
+	Title     string
+	Text      string
+	Truncated bool
+}
+
+func New(cfg Config) *Fetcher {
+	return newFetcher(cfg, allowedAddr)
+}
+
+// newFetcher lets tests reach httptest servers on loopback.
+func newFetcher(cfg Config, allow func(netip.Addr) bool) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 2 << 20
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = 3000
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "hyprbot/1.0 (+https://github.com/Mimic890/hyprbot)"
+	}
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		// Control sees the resolved address, so every hop is checked.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !allow(ap.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			return nil
+		},
+	}
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		// No proxy: the address check must see the real destination.
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+	return &Fetcher{cfg: cfg, client: client}
+}
+
+// allowedAddr reports whether ip is a public unicast address.
+func allowedAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockedPrefixes are non-public ranges netip does not classify.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Fetch downloads rawURL and returns its readable text cut to MaxTokens.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Page{}, ErrUnsupportedURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Page{}, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,text/plain;q=0.9")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return Page{}, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" && mediaType != "text/plain" {
+		return Page{}, fmt.Errorf("%w: %s", ErrNotText, mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxBytes))
+	if err != nil {
+		return Page{}, err
+	}
+	raw := strings.ToValidUTF8(string(body), "")
+	page := Page{URL: resp.Request.URL.String()}
+	if mediaType == "text/plain" {
+		page.Text = strings.TrimSpace(raw)
+	} else {
+		page.Title, page.Text = Extract(raw)
+	}
+	if limit := f.cfg.MaxTokens * runesPerToken; utf8.RuneCountInString(page.Text) > limit {
+		page.Text = string([]rune(page.Text)[:limit])
+		page.Truncated = true
+	}
+	return page, nil
+}
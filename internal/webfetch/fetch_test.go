@@ -0,0 +1,71 @@
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestExtractPrefersArticle(t *testing.T) {
+	body := strings.Repeat("Readable sentence about the topic. ", 10)
+	page := `<html><head><title>News &amp; Views</title><style>p{}</style></head><body>
+<nav><a href="/">Home</a></nav>
+<article><h1>Headline</h1><p>` + body + `</p><script>track()</script></article>
+<footer>Copyright</footer></body></html>`
+
+	title, text := Extract(page)
+	if title != "News & Views" {
+		t.Fatalf("title = %q", title)
+	}
+	if !strings.HasPrefix(text, "Headline\nReadable sentence") {
+		t.Fatalf("unexpected text %q", text)
+	}
+	for _, junk := range []string{"Home", "track", "Copyright", "p{}"} {
+		if strings.Contains(text, junk) {
+			t.Fatalf("text kept %q: %q", junk, text)
+		}
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("loopback server must not be reached")
+	}))
+	defer srv.Close()
+
+	if _, err := New(Config{}).Fetch(context.Background(), srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("expected ErrBlockedAddress, got %v", err)
+	}
+	if _, err := New(Config{}).Fetch(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrUnsupportedURL) {
+		t.Fatalf("expected ErrUnsupportedURL, got %v", err)
+	}
+	for _, ip := range []string{"10.1.2.3", "169.254.169.254", "100.64.0.1", "::1", "fd00::1", "::ffff:127.0.0.1"} {
+		if allowedAddr(netip.MustParseAddr(ip)) {
+			t.Fatalf("%s should be blocked", ip)
+		}
+	}
+	if !allowedAddr(netip.MustParseAddr("93.184.216.34")) {
+		t.Fatal("public address should be allowed")
+	}
+}
+
+func TestFetchTruncatesToTokenBudget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer srv.Close()
+
+	f := newFetcher(Config{MaxTokens: 5}, func(netip.Addr) bool { return true })
+	page, err := f.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if page.Text != strings.Repeat("a", 20) || !page.Truncated {
+		t.Fatalf("unexpected page %+v", page)
+	}
+}
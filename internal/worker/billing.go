@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"hyprbot/internal/queue"
+)
+
+// invoiceWindow spaces the invoices refused requests post in a chat.
+const invoiceWindow = 15 * time.Minute
+
+// admitJob lets a job run while the chat has free requests left this month
+// or a positive credit balance. Otherwise it replies that credits ran out,
+// with an invoice at most once per invoiceWindow when packs are sold, and
+// reports false. Lookup errors let the job through: the answer matters more.
+func (w *Worker) admitJob(ctx context.Context, job queue.AskJob) bool {
+	if !w.billing.Limited() {
+		return true
+	}
+	if w.billing.FreeRequests > 0 && w.quota != nil {
+		used, err := w.quota.Used(ctx, job.ChatID, time.Now())
+		if err != nil {
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to check chat quota")
+			return true
+		}
+		if used < w.billing.FreeRequests {
+			return true
+		}
+	}
+	balance, err := w.store.CreditBalance(ctx, job.ChatID)
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to load credit balance")
+		return true
+	}
+	if balance > 0 {
+		return true
+	}
+
+	if !w.billing.PacksEnabled() {
+		_ = w.sendError(ctx, job, "This chat has no credits left. Ask the bot owner for more; /balance shows the balance.")
+		return false
+	}
+	invoice := true
+	if w.quota != nil {
+		claimed, err := w.quota.ClaimInvoice(ctx, job.ChatID, invoiceWindow)
+		if err != nil {
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to claim credits invoice")
+		}
+		invoice = claimed
+	}
+	if !invoice {
+		_ = w.sendError(ctx, job, "This chat has no free requests or credits left. Buy a pack with /buy to keep asking; /balance shows the balance.")
+		return false
+	}
+	_ = w.sendError(ctx, job, "This chat has no free requests or credits left. Buy a pack to keep asking; /balance shows the balance.")
+	if err := w.billing.SendInvoice(ctx, w.bot, job.ChatID, 0); err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to send credits invoice")
+	}
+	return false
+}
+
+// chargeJob bills an answered job: to the free quota while it lasts, then in
+// credits computed from the tokens used. Failed jobs are never charged.
+func (w *Worker) chargeJob(ctx context.Context, job queue.AskJob, tokens int64) {
+	if !w.billing.Limited() {
+		return
+	}
+	if w.billing.FreeRequests > 0 && w.quota != nil {
+		free, err := w.quota.Take(ctx, job.ChatID, time.Now())
+		if err != nil {
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to count chat quota")
+			return
+		}
+		if free {
+			w.alertQuota(ctx, job)
+			return
+		}
+	}
+	cost := w.billing.Cost(tokens)
+	if err := w.store.DeductCredits(ctx, job.ChatID, job.UserID, cost); err != nil {
+		w.logger.Error().Err(err).Int64("chat_id", job.ChatID).Msg("failed to deduct credits")
+		return
+	}
+	w.alertCredits(ctx, job, cost)
+}
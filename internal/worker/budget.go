@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"hyprbot/internal/metrics"
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+)
+
+// fitContext shortens req to the model's context window, leaving room for
+// max_tokens of answer. Chat knowledge and the quoted message give way
+// before the question; only a question that cannot fit is an error.
+func (w *Worker) fitContext(job queue.AskJob, model string, params presetParams, req prompt.Request) (prompt.Request, error) {
+	window := params.ContextWindow
+	if window <= 0 {
+		window = prompt.ContextWindow(model)
+	}
+	reserve := params.MaxTokens
+	if reserve <= 0 {
+		reserve = prompt.DefaultOutputReserve
+	}
+	fitted, trimmed, err := prompt.Fit(req, prompt.Budget{Window: window, Reserve: reserve, Tokenizer: w.tokenizers.For(model)})
+	if err != nil {
+		w.logger.Info().Err(err).Str("job_id", job.JobID).Str("model", model).Int("context_window", window).Msg("prompt does not fit the context window")
+		return req, err
+	}
+	if trimmed.Any() {
+		if trimmed.Knowledge > 0 {
+			metrics.Global().PromptTrimmed.WithLabelValues("knowledge").Inc()
+		}
+		if trimmed.Quoted {
+			metrics.Global().PromptTrimmed.WithLabelValues("quoted").Inc()
+		}
+		w.logger.Info().
+			Str("job_id", job.JobID).
+			Str("model", model).
+			Int("context_window", window).
+			Int("knowledge_dropped", trimmed.Knowledge).
+			Bool("quote_cut", trimmed.Quoted).
+			Msg("trimmed prompt to fit the context window")
+	}
+	return fitted, nil
+}
+
+// estimateUsage counts the tokens of a request and its answer for providers
+// that report no usage, so billing and the footer still see a size. Tool
+// rounds are not included.
+func (w *Worker) estimateUsage(model, system, user, answer string) providers.Usage {
+	t := w.tokenizers.For(model)
+	return providers.Usage{InputTokens: t.Count(system) + t.Count(user), OutputTokens: t.Count(answer)}
+}
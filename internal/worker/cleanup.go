@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// Cleanup removes consumers of the group that have been idle for IdleAfter,
+// at start and every Interval, after putting their pending jobs back on
+// the stream. Zero IdleAfter disables it. Workers with a live heartbeat
+// are never removed, however long they have been busy.
+type Cleanup struct {
+	IdleAfter time.Duration
+	Interval  time.Duration
+}
+
+func (w *Worker) cleanupLoop(ctx context.Context) {
+	if w.cleanup.IdleAfter <= 0 {
+		return
+	}
+	interval := w.cleanup.Interval
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.removeDeadConsumers(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) removeDeadConsumers(ctx context.Context) {
+	live := map[string]bool{}
+	if w.heartbeat.Registry != nil {
+		workers, err := w.heartbeat.Registry.Live(ctx, time.Now())
+		if err != nil {
+			// Without the registry a long job could pass for a dead worker.
+			if ctx.Err() == nil {
+				w.logger.Warn().Err(err).Msg("skipping consumer cleanup, worker registry unavailable")
+			}
+			return
+		}
+		for _, info := range workers {
+			live[info.Consumer] = true
+		}
+	}
+	removed, err := w.queue.RemoveDeadConsumers(ctx, w.cleanup.IdleAfter, func(name string) bool { return live[name] })
+	for _, c := range removed {
+		w.logger.Info().Str("consumer", c.Name).Dur("idle", c.Idle).Int("requeued", c.Requeued).Msg("removed dead stream consumer")
+	}
+	if err != nil && ctx.Err() == nil {
+		w.logger.Error().Err(err).Msg("consumer cleanup failed")
+	}
+}
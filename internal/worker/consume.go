@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"hyprbot/internal/queue"
+)
+
+// maxAckBatch caps how many stream entries one pipelined ack removes.
+const maxAckBatch = 128
+
+// consumerPool connects readLoop to the consume loops. Idle slots announce
+// themselves on idle, so readLoop reads no more messages than it can hand
+// out right away and the rest stays in the stream for other replicas.
+// Slots added by grow after the base ones are the only ones shrink stops,
+// newest first.
+type consumerPool struct {
+	worker *Worker
+	ctx    context.Context
+	wg     sync.WaitGroup
+	idle   chan struct{}
+	jobs   chan queue.Message
+	acks   chan string
+
+	mu       sync.Mutex
+	stops    []chan struct{}
+	nextSlot int
+}
+
+func newConsumerPool(w *Worker, ctx context.Context, capacity int) *consumerPool {
+	return &consumerPool{
+		worker: w,
+		ctx:    ctx,
+		idle:   make(chan struct{}, capacity),
+		jobs:   make(chan queue.Message),
+		acks:   make(chan string, maxAckBatch),
+	}
+}
+
+func (p *consumerPool) grow() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stop := make(chan struct{})
+	p.stops = append(p.stops, stop)
+	slot := p.nextSlot
+	p.nextSlot++
+
+	p.wg.Add(1)
+	p.worker.metrics.ActiveConsumers.Inc()
+	go func() {
+		defer p.wg.Done()
+		defer p.worker.metrics.ActiveConsumers.Dec()
+		p.worker.consumeLoop(p.ctx, slot, p, stop)
+	}()
+}
+
+func (p *consumerPool) shrink() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := len(p.stops) - 1
+	close(p.stops[last])
+	p.stops = p.stops[:last]
+}
+
+func (p *consumerPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+func (p *consumerPool) wait() {
+	p.wg.Wait()
+}
+
+// next waits for a message after the slot announced itself idle. A slot
+// stopped meanwhile withdraws an announcement; if readLoop already counted
+// them all, the slot still takes one message so the count stays right.
+func (p *consumerPool) next(ctx context.Context, stop <-chan struct{}) (queue.Message, bool) {
+	select {
+	case msg := <-p.jobs:
+		return msg, true
+	case <-ctx.Done():
+		return queue.Message{}, false
+	case <-stop:
+	}
+	select {
+	case <-p.idle:
+		return queue.Message{}, false
+	default:
+	}
+	select {
+	case msg := <-p.jobs:
+		return msg, true
+	case <-ctx.Done():
+		return queue.Message{}, false
+	}
+}
+
+// ack hands the entry to ackLoop. It is not tied to the job's ctx, so jobs
+// finishing during shutdown are still acked.
+func (p *consumerPool) ack(id string) {
+	p.acks <- id
+}
+
+// readLoop reads as many messages as there are idle slots in one XREADGROUP
+// and hands them out.
+func (w *Worker) readLoop(ctx context.Context, pool *consumerPool) {
+	ready := 0
+	for {
+		if ready == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pool.idle:
+				ready++
+			}
+		}
+	drain:
+		for {
+			select {
+			case <-pool.idle:
+				ready++
+			default:
+				break drain
+			}
+		}
+
+		messages, err := w.queue.Read(ctx, int64(ready))
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.logger.Error().Err(err).Msg("failed to read queue")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		w.liveness.progressed()
+		for _, msg := range messages {
+			select {
+			case pool.jobs <- msg:
+				ready--
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// ackLoop acks entries in pipelined batches of whatever piled up while the
+// previous batch was in flight, until acks is closed.
+func (w *Worker) ackLoop(acks <-chan string) {
+	for id := range acks {
+		ids := []string{id}
+	collect:
+		for len(ids) < maxAckBatch {
+			select {
+			case next, ok := <-acks:
+				if !ok {
+					break collect
+				}
+				ids = append(ids, next)
+			default:
+				break collect
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := w.queue.Ack(ctx, ids...); err != nil {
+			w.logger.Error().Err(err).Strs("msg_ids", ids).Msg("failed to ack messages")
+		}
+		cancel()
+	}
+}
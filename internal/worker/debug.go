@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// debugReport is what /ask --debug shows after the answer, or after a failed
+// provider call, to troubleshoot preset configuration.
+type debugReport struct {
+	preset       storage.PresetWithProvider
+	arm          string
+	route        string
+	params       presetParams
+	systemPrompt string
+	resp         providers.ChatResponse
+	started      time.Time
+	latency      time.Duration
+	err          error
+}
+
+func (w *Worker) sendDebug(ctx context.Context, job queue.AskJob, r debugReport) {
+	if err := w.reply(ctx, job, truncateRunes(w.debugText(job, r), 4000), nil); err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to send debug report")
+	}
+}
+
+func (w *Worker) debugText(job queue.AskJob, r debugReport) string {
+	route := "chat default"
+	switch {
+	case job.PresetName != "":
+		route = "requested"
+	case r.arm != "":
+		route = "A/B arm " + r.arm
+	}
+	endpoint := r.resp.Endpoint
+	if endpoint == "" {
+		endpoint = r.preset.Provider.BaseURL
+	}
+	model := r.preset.Preset.Model
+	if r.resp.Model != "" && r.resp.Model != model {
+		model += " (reported: " + r.resp.Model + ")"
+	}
+	params, _ := json.Marshal(r.params)
+
+	lines := []string{
+		"Debug, job " + job.JobID,
+		fmt.Sprintf("Preset: %s (%s)", r.preset.Preset.Name, route),
+		fmt.Sprintf("Provider: %s (%s)", r.preset.Provider.Name, r.preset.Provider.Kind),
+		"Endpoint: " + providers.RedactURL(endpoint),
+		"Model: " + model,
+		"Params: " + string(params),
+	}
+	if r.route != "" {
+		lines = append(lines, "Router: "+r.route)
+	}
+	lines = append(lines,
+		fmt.Sprintf("System prompt: %d chars, prompt: %d chars", len([]rune(r.systemPrompt)), len([]rune(prompt.User(job.Prompt, job.QuotedAuthor, job.QuotedText)))),
+	)
+	if r.resp.Usage.Total() > 0 {
+		lines = append(lines, fmt.Sprintf("Tokens: %d in, %d out", r.resp.Usage.InputTokens, r.resp.Usage.OutputTokens))
+	} else {
+		lines = append(lines, "Tokens: not reported")
+	}
+	lines = append(lines, fmt.Sprintf("Attempts: %d HTTP, job attempt %d of %d", r.resp.Attempts, job.Attempts+1, w.maxJobRetries+1))
+	latency := fmt.Sprintf("Latency: provider %.1fs", r.latency.Seconds())
+	if !job.EnqueuedAt.IsZero() {
+		latency += fmt.Sprintf(", queued %.1fs", r.started.Sub(job.EnqueuedAt).Seconds())
+	}
+	lines = append(lines, latency)
+	if r.err != nil {
+		// Transport and provider errors quote the request URL, query keys
+		// included.
+		lines = append(lines, "Error: "+truncateRunes(providers.RedactText(r.err.Error()), 500))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,26 @@
+package worker
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+func TestDebugTextRedactsErrorURL(t *testing.T) {
+	w := &Worker{}
+	r := debugReport{
+		preset: storage.PresetWithProvider{Provider: storage.ProviderInstance{BaseURL: "https://generativelanguage.googleapis.com/v1beta"}},
+		err:    &url.Error{Op: "Post", URL: "https://generativelanguage.googleapis.com/v1beta/models/gemini:generateContent?key=AIzaSecret123", Err: errors.New("connection refused")},
+	}
+	text := w.debugText(queue.AskJob{JobID: "j1"}, r)
+	if strings.Contains(text, "AIzaSecret123") {
+		t.Fatalf("debug report leaks the key:\n%s", text)
+	}
+	if !strings.Contains(text, "connection refused") {
+		t.Errorf("debug report lost the error:\n%s", text)
+	}
+}
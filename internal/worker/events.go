@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"time"
+
+	"hyprbot/internal/eventstream"
+	"hyprbot/internal/queue"
+)
+
+// streamJob puts an ask job event on the event stream. Only counts and
+// timings go out: no prompt, answer, preset name or job id.
+func (w *Worker) streamJob(job queue.AskJob, typ string, data map[string]any) {
+	if w.stream == nil {
+		return
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["chat_type"] = job.ChatType
+	data["attempt"] = job.Attempts + 1
+	if typ == eventstream.EventJobFinished && !job.EnqueuedAt.IsZero() {
+		data["latency_ms"] = time.Since(job.EnqueuedAt).Milliseconds()
+	}
+	w.stream.Publish(typ, job.ChatID, job.UserID, data)
+}
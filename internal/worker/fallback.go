@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// fallbackPreset returns the owner's fallback preset for a default-preset job
+// in a chat that has no presets at all. Chats with presets but no default
+// get the picker instead.
+func (w *Worker) fallbackPreset(ctx context.Context, job queue.AskJob) (storage.PresetWithProvider, bool) {
+	if w.fallback == nil || job.PresetName != "" {
+		return storage.PresetWithProvider{}, false
+	}
+	presets, err := w.store.ListPresets(ctx, job.ChatID)
+	if err != nil || len(presets) > 0 {
+		return storage.PresetWithProvider{}, false
+	}
+	fb, ok, err := w.fallback.Get(ctx)
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("failed to load fallback preset")
+		return storage.PresetWithProvider{}, false
+	}
+	if !ok {
+		return storage.PresetWithProvider{}, false
+	}
+	pp, err := w.store.GetPresetWithProviderByName(ctx, fb.ChatID, fb.Preset)
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", fb.ChatID).Str("preset", fb.Preset).Msg("fallback preset unavailable")
+		return storage.PresetWithProvider{}, false
+	}
+	return pp, true
+}
+
+// allowFallback applies the stricter per-chat limit of the fallback preset
+// and tells the asker when it is used up.
+func (w *Worker) allowFallback(ctx context.Context, job queue.AskJob) bool {
+	if w.fallbackLimiter == nil {
+		return true
+	}
+	ok, _, resetAt, err := w.fallbackLimiter.Allow(ctx, job.ChatID, 0, time.Now())
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("fallback rate limit check failed")
+		return true
+	}
+	if !ok {
+		_ = w.sendError(ctx, job, fmt.Sprintf("This chat has no presets yet and its trial questions are used up until %s. Ask an admin to set up a provider with /llm_add.", resetAt.Format("15:04 UTC")))
+	}
+	return ok
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+)
+
+var fetchURLTool = providers.Tool{
+	Name:        "fetch_url",
+	Description: "Download a public web page and return its readable text. Use it when the question refers to a URL or needs the content of a specific page.",
+	Parameters:  json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"Absolute http or https URL."}},"required":["url"]}`),
+}
+
+func (w *Worker) fetchURL(ctx context.Context, job queue.AskJob, arguments string) toolRun {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || strings.TrimSpace(args.URL) == "" {
+		return toolRun{Report: "error: arguments must be a JSON object with url"}
+	}
+	page, err := w.fetcher.Fetch(ctx, args.URL)
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Int64("chat_id", job.ChatID).Msg("url fetch failed")
+		return toolRun{Report: "error: " + err.Error(), Note: "🔗 Could not read " + truncateRunes(args.URL, 200)}
+	}
+	report := "url: " + page.URL + "\n"
+	if page.Title != "" {
+		report += "title: " + page.Title + "\n"
+	}
+	if page.Truncated {
+		report += "content truncated\n"
+	}
+	note := "🔗 Read " + truncateRunes(page.URL, 200)
+	if page.Title != "" {
+		note = "🔗 Read " + truncateRunes(page.Title, 100) + " (" + truncateRunes(page.URL, 200) + ")"
+	}
+	return toolRun{Report: report + "\n" + page.Text, Note: note}
+}
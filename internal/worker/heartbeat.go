@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"hyprbot/internal/queue"
+)
+
+// Liveness tracks whether the consume loop still makes progress: reading
+// the stream or finishing a job. It is created before the worker so the
+// health endpoint can hold it.
+type Liveness struct {
+	stallAfter time.Duration
+	started    time.Time
+	progress   atomic.Int64
+	processed  atomic.Int64
+	failed     atomic.Int64
+}
+
+// NewLiveness reports a stall once stallAfter passes without progress.
+func NewLiveness(stallAfter time.Duration) *Liveness {
+	if stallAfter <= 0 {
+		stallAfter = 10 * time.Minute
+	}
+	l := &Liveness{stallAfter: stallAfter, started: time.Now()}
+	l.progressed()
+	return l
+}
+
+func (l *Liveness) progressed() {
+	l.progress.Store(time.Now().UnixNano())
+}
+
+func (l *Liveness) LastProgress() time.Time {
+	return time.Unix(0, l.progress.Load())
+}
+
+// Stalled reports whether the consume loop made no progress for the stall
+// timeout. A nil Liveness never stalls.
+func (l *Liveness) Stalled(now time.Time) bool {
+	return l != nil && now.Sub(l.LastProgress()) > l.stallAfter
+}
+
+// Heartbeat publishes the worker to the registry every Interval. A nil
+// Registry disables it.
+type Heartbeat struct {
+	Registry *queue.WorkerRegistry
+	Interval time.Duration
+}
+
+func (w *Worker) heartbeatLoop(ctx context.Context, pool *consumerPool) {
+	if w.heartbeat.Registry == nil {
+		return
+	}
+	interval := w.heartbeat.Interval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	hostname, _ := os.Hostname()
+	beat := func(ctx context.Context) {
+		now := time.Now()
+		err := w.heartbeat.Registry.Beat(ctx, queue.WorkerInfo{
+			Consumer:      w.queue.Consumer(),
+			Hostname:      hostname,
+			StartedAt:     w.liveness.started,
+			BeatAt:        now,
+			LastProgress:  w.liveness.LastProgress(),
+			Consumers:     pool.size(),
+			JobsProcessed: w.liveness.processed.Load(),
+			JobsFailed:    w.liveness.failed.Load(),
+			Stalled:       w.liveness.Stalled(now),
+		})
+		if err != nil && ctx.Err() == nil {
+			w.logger.Warn().Err(err).Msg("failed to send worker heartbeat")
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	beat(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			// Not tied to ctx: deregister even though the worker is stopping.
+			rctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := w.heartbeat.Registry.Remove(rctx, w.queue.Consumer()); err != nil {
+				w.logger.Warn().Err(err).Msg("failed to deregister worker")
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			beat(ctx)
+		}
+	}
+}
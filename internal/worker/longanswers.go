@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/tgsend"
+)
+
+// Answers longer than longAnswerRunes are sent as a preview of about
+// longAnswerPreviewRunes when the chat's long_answers setting is not split.
+const (
+	longAnswerRunes        = 1500
+	longAnswerPreviewRunes = 1000
+)
+
+// previewLongAnswer keeps a long reply, with the footer and disclosure of
+// entry, for the "Show full answer" button and returns its preview. The
+// reply is returned unchanged when it is short or cannot be kept.
+func (w *Worker) previewLongAnswer(ctx context.Context, job queue.AskJob, reply string, entry queue.OutboxEntry, mode string) (string, bool) {
+	if w.fullAnswers == nil || job.JobID == "" || utf8.RuneCountInString(reply) <= longAnswerRunes {
+		return reply, false
+	}
+	preview := tgsend.Split(reply, longAnswerPreviewRunes)[0]
+	err := w.fullAnswers.Save(ctx, job.JobID, queue.FullAnswer{
+		ChatID:       job.ChatID,
+		Text:         tgsend.WithDisclosure(withFooter(reply, entry.Footer), entry.Disclosure, ""),
+		PreviewRunes: utf8.RuneCountInString(preview),
+		Mode:         mode,
+	})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to save full answer")
+		return reply, false
+	}
+	return preview + " …", true
+}
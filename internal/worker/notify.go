@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"hyprbot/internal/notify"
+	"hyprbot/internal/queue"
+)
+
+// BudgetAlerts are the budget.threshold events: a chat reaching each of
+// QuotaPercents of its free monthly requests, and its credit balance
+// falling to LowCredits or below. LowCredits 0 sends no credit alerts.
+type BudgetAlerts struct {
+	QuotaPercents []int
+	LowCredits    int64
+}
+
+// emitJob reports the final outcome of an ask job to the webhooks.
+func (w *Worker) emitJob(ctx context.Context, job queue.AskJob, typ string) {
+	if w.notify == nil {
+		return
+	}
+	outcome := "answered"
+	if typ == notify.EventJobFailed {
+		outcome = "failed"
+	}
+	data := map[string]any{"job_id": job.JobID, "preset": job.PresetName, "attempts": job.Attempts + 1}
+	if !job.EnqueuedAt.IsZero() {
+		data["latency_ms"] = time.Since(job.EnqueuedAt).Milliseconds()
+	}
+	w.notify.Emit(ctx, notify.Event{
+		Type:   typ,
+		ChatID: job.ChatID,
+		UserID: job.UserID,
+		Text:   fmt.Sprintf("Job %s %s in chat %d", job.JobID, outcome, job.ChatID),
+		Data:   data,
+	})
+}
+
+// alertQuota reports the chat's free requests reaching a threshold. The
+// counter grows by one per answer, so each threshold is hit exactly once a
+// month.
+func (w *Worker) alertQuota(ctx context.Context, job queue.AskJob) {
+	if w.notify == nil || len(w.budgetAlerts.QuotaPercents) == 0 {
+		return
+	}
+	used, err := w.quota.Used(ctx, job.ChatID, time.Now())
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to check chat quota for alerts")
+		return
+	}
+	free := w.billing.FreeRequests
+	for _, pct := range w.budgetAlerts.QuotaPercents {
+		if used != (free*int64(pct)+99)/100 {
+			continue
+		}
+		w.notify.Emit(ctx, notify.Event{
+			Type:   notify.EventBudgetThreshold,
+			ChatID: job.ChatID,
+			Text:   fmt.Sprintf("Chat %d used %d%% of its free requests this month (%d of %d)", job.ChatID, pct, used, free),
+			Data:   map[string]any{"kind": "free_quota", "percent": pct, "used": used, "limit": free},
+		})
+	}
+}
+
+// alertCredits reports the chat's balance falling to the low credits mark
+// with the cost just charged.
+func (w *Worker) alertCredits(ctx context.Context, job queue.AskJob, cost int64) {
+	low := w.budgetAlerts.LowCredits
+	if w.notify == nil || low <= 0 {
+		return
+	}
+	balance, err := w.store.CreditBalance(ctx, job.ChatID)
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to load credit balance for alerts")
+		return
+	}
+	if balance > low || balance+cost <= low {
+		return
+	}
+	w.notify.Emit(ctx, notify.Event{
+		Type:   notify.EventBudgetThreshold,
+		ChatID: job.ChatID,
+		Text:   fmt.Sprintf("Chat %d is down to %d credits", job.ChatID, balance),
+		Data:   map[string]any{"kind": "credits", "balance": balance, "threshold": low},
+	})
+}
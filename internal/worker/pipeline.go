@@ -0,0 +1,223 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/sanitize"
+	"hyprbot/internal/storage"
+)
+
+// Stage is one step of the answer pipeline. Before runs ahead of the
+// provider call and may change the request; After runs on the answer
+// before it is rendered and sent. Either may be nil.
+//
+// A stage returning a *Refusal stops the job before the provider call, or
+// replaces the answer after it; any other error is logged and the stage
+// skipped, since the answer matters more than any one step.
+type Stage struct {
+	Name   string
+	Order  int
+	Before func(ctx context.Context, a *Ask) error
+	After  func(ctx context.Context, a *Ask, ans *Answer) error
+}
+
+// Orders of the built-in stages and plugin filters. Stages run by
+// ascending Order, ties in registration order, so others slot in between.
+const (
+	OrderKnowledge    = 100 // Before: adds the chat knowledge
+	OrderContext      = 900 // Before: trims the request to the context window
+	OrderUsage        = 100 // After: estimates usage the provider did not report
+	OrderFormatting   = 200 // After: picks Markdown from the formatting setting
+	OrderPlugin       = 400 // After: plugin filters whose config sets no order
+	OrderOutputFilter = 500 // After: the output_filter setting
+	OrderFooter       = 800 // After: the footer setting
+	OrderDisclosure   = 900 // After: the disclosure setting
+)
+
+// Ask is a job on its way to the provider.
+type Ask struct {
+	Job      queue.AskJob
+	Preset   storage.Preset
+	Model    string
+	Settings storage.ChatSettings
+	Request  prompt.Request
+
+	params presetParams
+}
+
+// Answer is the provider's answer on its way to the chat.
+type Answer struct {
+	// Text is the answer; with JSON set it is the validated, indented
+	// document.
+	Text string
+	JSON bool
+	// Reasoning is shown above Text when the preset has show_reasoning.
+	Reasoning string
+	// Notes lists what tools did, shown under Text.
+	Notes string
+	// Markdown sends the answer with Telegram Markdown.
+	Markdown bool
+
+	Model   string
+	Route   string
+	Latency time.Duration
+	Usage   providers.Usage
+	// UsageEstimated is set when Usage was counted here because the
+	// provider reported none.
+	UsageEstimated bool
+
+	Footer     string
+	Disclosure string
+}
+
+// Refusal is returned by a stage to withhold the answer; Reply is sent to
+// the asker instead.
+type Refusal struct {
+	Reply string
+}
+
+func (r *Refusal) Error() string {
+	return "refused: " + r.Reply
+}
+
+// Refuse returns a *Refusal with reply.
+func Refuse(reply string) error {
+	return &Refusal{Reply: reply}
+}
+
+type pipeline struct {
+	stages []Stage
+}
+
+// register adds s after every stage of a lower or equal Order.
+func (p *pipeline) register(s Stage) {
+	i := sort.Search(len(p.stages), func(i int) bool { return p.stages[i].Order > s.Order })
+	p.stages = append(p.stages, Stage{})
+	copy(p.stages[i+1:], p.stages[i:])
+	p.stages[i] = s
+}
+
+// Use registers a stage. Call it before Start.
+func (w *Worker) Use(s Stage) {
+	w.pipeline.register(s)
+}
+
+func (w *Worker) useBuiltinStages() {
+	w.Use(Stage{Name: "knowledge", Order: OrderKnowledge, Before: w.addKnowledge})
+	w.Use(Stage{Name: "context", Order: OrderContext, Before: w.fitStage})
+	w.Use(Stage{Name: "usage", Order: OrderUsage, After: w.usageStage})
+	w.Use(Stage{Name: "formatting", Order: OrderFormatting, After: formattingStage})
+	w.Use(Stage{Name: "output_filter", Order: OrderOutputFilter, After: outputFilterStage})
+	w.Use(Stage{Name: "footer", Order: OrderFooter, After: footerStage})
+	w.Use(Stage{Name: "disclosure", Order: OrderDisclosure, After: disclosureStage})
+}
+
+// runBefore runs the Before stages and reports false when one refused the
+// job; the refusal has then been sent.
+func (w *Worker) runBefore(ctx context.Context, a *Ask) bool {
+	for _, s := range w.pipeline.stages {
+		if s.Before == nil {
+			continue
+		}
+		err := s.Before(ctx, a)
+		var refusal *Refusal
+		if errors.As(err, &refusal) {
+			w.logger.Info().Str("job_id", a.Job.JobID).Str("stage", s.Name).Msg("stage refused the job")
+			_ = w.sendError(ctx, a.Job, refusal.Reply)
+			return false
+		}
+		if err != nil {
+			w.logger.Warn().Err(err).Str("job_id", a.Job.JobID).Str("stage", s.Name).Msg("pipeline stage failed")
+		}
+	}
+	return true
+}
+
+// runAfter runs the After stages. A refusal replaces the answer with its
+// reply and the remaining stages still run, so footers and disclosures
+// stay.
+func (w *Worker) runAfter(ctx context.Context, a *Ask, ans *Answer) {
+	for _, s := range w.pipeline.stages {
+		if s.After == nil {
+			continue
+		}
+		err := s.After(ctx, a, ans)
+		var refusal *Refusal
+		if errors.As(err, &refusal) {
+			w.logger.Info().Str("job_id", a.Job.JobID).Str("stage", s.Name).Msg("stage withheld the answer")
+			ans.Text, ans.JSON, ans.Reasoning, ans.Notes = refusal.Reply, false, "", ""
+			continue
+		}
+		if err != nil {
+			w.logger.Warn().Err(err).Str("job_id", a.Job.JobID).Str("stage", s.Name).Msg("pipeline stage failed")
+		}
+	}
+}
+
+func (w *Worker) addKnowledge(ctx context.Context, a *Ask) error {
+	if a.Job.TranslateTo != "" || a.Settings.Get(storage.SettingKnowledge) == storage.SettingOff {
+		return nil
+	}
+	texts, err := w.store.KnowledgeTexts(ctx, a.Job.ChatID)
+	if err != nil {
+		return fmt.Errorf("load chat knowledge: %w", err)
+	}
+	a.Request.Knowledge = append(a.Request.Knowledge, texts...)
+	return nil
+}
+
+func (w *Worker) fitStage(_ context.Context, a *Ask) error {
+	req, err := w.fitContext(a.Job, a.Model, a.params, a.Request)
+	var tooLarge *prompt.TooLargeError
+	if errors.As(err, &tooLarge) {
+		return Refuse(fmt.Sprintf("The question is too long for %s: about %d tokens, the model accepts %d here. Shorten it and ask again.", a.Preset.Name, tooLarge.Tokens, tooLarge.Limit))
+	}
+	if err != nil {
+		return err
+	}
+	a.Request = req
+	return nil
+}
+
+func (w *Worker) usageStage(_ context.Context, a *Ask, ans *Answer) error {
+	if ans.Usage.Total() == 0 {
+		ans.Usage = w.estimateUsage(ans.Model, a.Request.SystemPrompt(), a.Request.UserPrompt(), ans.Text)
+		ans.UsageEstimated = true
+	}
+	return nil
+}
+
+func formattingStage(_ context.Context, a *Ask, ans *Answer) error {
+	ans.Markdown = !ans.JSON && a.Settings.Get(storage.SettingFormatting) == storage.FormattingMarkdown
+	return nil
+}
+
+func outputFilterStage(_ context.Context, a *Ask, ans *Answer) error {
+	if ans.JSON {
+		return nil
+	}
+	filter := outputFilter(a.Settings, ans.Markdown)
+	ans.Text = sanitize.Text(ans.Text, filter)
+	ans.Reasoning = sanitize.Text(ans.Reasoning, filter)
+	ans.Notes = sanitize.Text(ans.Notes, filter)
+	return nil
+}
+
+func footerStage(_ context.Context, a *Ask, ans *Answer) error {
+	if a.Settings.Bool(storage.SettingFooter) {
+		ans.Footer = traceFooter(ans.Route, ans.Model, ans.Latency, ans.Usage, ans.UsageEstimated)
+	}
+	return nil
+}
+
+func disclosureStage(_ context.Context, a *Ask, ans *Answer) error {
+	ans.Disclosure = a.Settings.Get(storage.SettingDisclosure)
+	return nil
+}
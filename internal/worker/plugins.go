@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"hyprbot/internal/plugin"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// chatPlugins returns the plugins the chat's plugins setting enables: the
+// default ones when the chat picked none.
+func (w *Worker) chatPlugins(settings storage.ChatSettings) []*plugin.Plugin {
+	value := settings.Get(storage.SettingPlugins)
+	if value == storage.SettingOff || len(w.plugins) == 0 {
+		return nil
+	}
+	picked := strings.Split(value, ",")
+	var out []*plugin.Plugin
+	for _, p := range w.plugins {
+		if (value == "" && p.Default()) || slices.Contains(picked, p.Name()) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// pluginStage runs a plugin's filter on answers in the chats that enable
+// the plugin.
+func (w *Worker) pluginStage(p *plugin.Plugin) Stage {
+	order := p.Order()
+	if order == 0 {
+		order = OrderPlugin
+	}
+	return Stage{
+		Name:  "plugin:" + p.Name(),
+		Order: order,
+		After: func(ctx context.Context, a *Ask, ans *Answer) error {
+			if !slices.Contains(w.chatPlugins(a.Settings), p) {
+				return nil
+			}
+			res, err := p.Filter(ctx, plugin.FilterRequest{
+				ChatID:   a.Job.ChatID,
+				UserID:   a.Job.UserID,
+				Preset:   a.Preset.Name,
+				Model:    ans.Model,
+				Text:     ans.Text,
+				Markdown: ans.Markdown,
+			})
+			if err != nil {
+				return err
+			}
+			if res.Refuse != "" {
+				return Refuse(res.Refuse)
+			}
+			if res.Text != nil && !ans.JSON {
+				ans.Text = *res.Text
+			}
+			return nil
+		},
+	}
+}
+
+// pluginTools lists the tools of plugins whose names are not taken yet.
+func pluginTools(plugins []*plugin.Plugin, taken []providers.Tool) []providers.Tool {
+	var out []providers.Tool
+	for _, p := range plugins {
+		for _, t := range p.Info().Tools {
+			if toolTaken(t.Name, taken) || toolTaken(t.Name, out) {
+				continue
+			}
+			out = append(out, providers.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+		}
+	}
+	return out
+}
+
+func toolTaken(name string, tools []providers.Tool) bool {
+	return slices.ContainsFunc(tools, func(t providers.Tool) bool { return t.Name == name })
+}
+
+// runPluginTool answers a call to a plugin tool; ok is false when no
+// plugin offers it.
+func (w *Worker) runPluginTool(ctx context.Context, job queue.AskJob, plugins []*plugin.Plugin, call providers.ToolCall) (toolRun, bool) {
+	for _, p := range plugins {
+		if !slices.ContainsFunc(p.Info().Tools, func(t plugin.Tool) bool { return t.Name == call.Name }) {
+			continue
+		}
+		res, err := p.CallTool(ctx, plugin.ToolCall{Name: call.Name, Arguments: call.Arguments, ChatID: job.ChatID, UserID: job.UserID})
+		if err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Str("plugin", p.Name()).Str("tool", call.Name).Msg("plugin tool failed")
+			return toolRun{Report: "error: " + err.Error(), Note: "🧩 " + call.Name + " failed"}, true
+		}
+		return toolRun{Report: res.Report, Note: truncateRunes(res.Note, 500)}, true
+	}
+	return toolRun{}, false
+}
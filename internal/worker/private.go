@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"hyprbot/internal/queue"
+	"hyprbot/internal/tgsend"
+)
+
+// deliverPrivately sends the answer to the asker's private chat and leaves a
+// note in the group. A bot cannot message users who never started it, so
+// they get a deep link instead that hands over the kept answer on /start.
+// Without a way to keep it, the answer goes to the group as usual.
+func (w *Worker) deliverPrivately(ctx context.Context, job *queue.AskJob, entry queue.OutboxEntry, m tgsend.Message) error {
+	dm := m
+	dm.ChatID, dm.ThreadID, dm.ReplyTo = job.UserID, 0, 0
+	_, err := w.sender.Send(ctx, dm)
+	if err == nil {
+		w.sendPrivateNote(ctx, job, answerMessage(*job, "Answered in private chat.", nil))
+		return nil
+	}
+	if !tgsend.IsUnreachable(err) {
+		return err
+	}
+	link := w.privateAnswerLink(ctx, *job, tgsend.WithDisclosure(withFooter(entry.Reply, entry.Footer), entry.Disclosure, ""))
+	if link == "" {
+		return w.sendAnswer(ctx, job, m)
+	}
+	note := answerMessage(*job, "Your answer is ready. Open a private chat with the bot to read it.", &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{Text: "Open private chat", Url: link}}},
+	})
+	w.sendPrivateNote(ctx, job, note)
+	return nil
+}
+
+// privateAnswerLink keeps text for the asker and returns the deep link that
+// delivers it, or "" when it cannot be kept.
+func (w *Worker) privateAnswerLink(ctx context.Context, job queue.AskJob, text string) string {
+	if w.fullAnswers == nil || w.bot == nil || w.bot.User.Username == "" || job.JobID == "" {
+		return ""
+	}
+	err := w.fullAnswers.Save(ctx, job.JobID, queue.FullAnswer{ChatID: job.ChatID, UserID: job.UserID, Text: text})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to keep private answer")
+		return ""
+	}
+	return "https://t.me/" + w.bot.User.Username + "?start=" + queue.PrivateAnswerStartPrefix + job.JobID
+}
+
+// sendPrivateNote tells the group where the answer went. The answer is out
+// already, so a failed note is only logged.
+func (w *Worker) sendPrivateNote(ctx context.Context, job *queue.AskJob, note tgsend.Message) {
+	if err := w.sendAnswer(ctx, job, note); err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to send private answer note")
+	}
+}
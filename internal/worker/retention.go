@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// Retention bounds the job stream. Every worker replica trims on its own;
+// XTRIM is idempotent, so there is no need to elect one.
+type Retention struct {
+	MaxLen   int64
+	MaxAge   time.Duration
+	Interval time.Duration
+}
+
+func (w *Worker) trimLoop(ctx context.Context) {
+	r := w.retention
+	if r.MaxLen <= 0 && r.MaxAge <= 0 {
+		return
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := w.queue.Trim(ctx, r.MaxLen, r.MaxAge, time.Now())
+			if n > 0 {
+				w.metrics.TrimmedJobs.Add(float64(n))
+				w.logger.Warn().Int64("trimmed", n).Msg("trimmed stale entries from the job stream")
+			}
+			if err != nil && ctx.Err() == nil {
+				w.logger.Error().Err(err).Msg("job stream trim failed")
+			}
+		}
+	}
+}
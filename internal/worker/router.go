@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/providers/registry"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// classifyTimeout bounds the classification call of a router preset; the
+// heuristic decides when it runs out.
+const classifyTimeout = 10 * time.Second
+
+var errUnclassified = errors.New("classifier reply names no class")
+
+// routerParams make a preset a router: instead of answering, it dispatches
+// each prompt to the preset of its class. Classes without a preset use the
+// chat route, and the router answers itself when that is unset too.
+type routerParams struct {
+	Code string `json:"route_code"`
+	Chat string `json:"route_chat"`
+	Long string `json:"route_long"`
+	// Mode is "model" to classify with a call to the router's own model,
+	// or "heuristic" (the default) to classify the text locally.
+	Mode string `json:"route_mode"`
+}
+
+func (r routerParams) enabled() bool {
+	return r.Code != "" || r.Chat != "" || r.Long != ""
+}
+
+func (r routerParams) target(class string) string {
+	switch {
+	case class == prompt.ClassCode && r.Code != "":
+		return r.Code
+	case class == prompt.ClassLong && r.Long != "":
+		return r.Long
+	}
+	return r.Chat
+}
+
+// routeJob dispatches a job resolved to a router preset. It returns the
+// preset that answers and the routing decision for the footer and debug
+// report, which is empty when pp is not a router.
+func (w *Worker) routeJob(ctx context.Context, job queue.AskJob, pp storage.PresetWithProvider) (storage.PresetWithProvider, string) {
+	var router routerParams
+	if raw := strings.TrimSpace(pp.Preset.ParamsJSON); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &router)
+	}
+	if !router.enabled() {
+		return pp, ""
+	}
+	class, how := "", "heuristic"
+	if router.Mode == "model" {
+		if c, err := w.classify(ctx, job, pp); err == nil {
+			class, how = c, "model"
+		} else {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Str("preset", pp.Preset.Name).Msg("router classification failed, using heuristic")
+		}
+	}
+	if class == "" {
+		class = prompt.Classify(job.Prompt, job.QuotedText)
+	}
+	why := class + ", " + how
+
+	name := router.target(class)
+	if name == "" || name == pp.Preset.Name {
+		return pp, pp.Preset.Name + " (" + why + ")"
+	}
+	target, err := w.store.GetPresetWithProviderByName(ctx, job.ChatID, name)
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Str("preset", name).Msg("router target unavailable")
+		return pp, pp.Preset.Name + " (" + why + "; " + name + " unavailable)"
+	}
+	return target, pp.Preset.Name + " → " + name + " (" + why + ")"
+}
+
+// classify asks the router preset's model which class the prompt is.
+func (w *Worker) classify(ctx context.Context, job queue.AskJob, pp storage.PresetWithProvider) (string, error) {
+	p, err := registry.FromInstance(pp.Provider, w.crypto, registry.BuildOptions{
+		HTTPClient:  w.httpClient,
+		BackoffBase: w.backoffBase,
+	})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+	resp, err := p.Chat(ctx, providers.ChatRequest{
+		Model:        pp.Preset.Model,
+		SystemPrompt: prompt.ClassifierSystem,
+		UserPrompt:   prompt.User(truncateRunes(job.Prompt, 2000), job.QuotedAuthor, truncateRunes(job.QuotedText, 2000)),
+		MaxTokens:    8,
+	})
+	w.recordProviderHealth(ctx, pp.Provider.ID, err)
+	if err != nil {
+		return "", err
+	}
+	class, ok := prompt.ParseClass(resp.Text)
+	if !ok {
+		return "", errUnclassified
+	}
+	return class, nil
+}
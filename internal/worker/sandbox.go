@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/sandbox"
+)
+
+// maxCodeOutputRunes caps each run's output shown under the answer.
+const maxCodeOutputRunes = 800
+
+var runPythonTool = providers.Tool{
+	Name:        "run_python",
+	Description: "Run a Python 3 program in an isolated sandbox without network access and return its stdout, stderr and exit code. Use it to compute or check results instead of guessing.",
+	Parameters:  json.RawMessage(`{"type":"object","properties":{"code":{"type":"string","description":"Complete Python 3 program; print what you need to see."}},"required":["code"]}`),
+}
+
+func (w *Worker) runPython(ctx context.Context, job queue.AskJob, arguments string) toolRun {
+	var args struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || strings.TrimSpace(args.Code) == "" {
+		return toolRun{Report: "error: arguments must be a JSON object with code"}
+	}
+	res, err := w.sandbox.Run(ctx, sandbox.LanguagePython, args.Code)
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Int64("chat_id", job.ChatID).Msg("sandbox run failed")
+		return toolRun{Report: "error: " + err.Error(), Note: "▶ Code run failed: " + truncateRunes(err.Error(), 200)}
+	}
+	return toolRun{Report: codeReport(res), Note: codeNote(res)}
+}
+
+// codeReport is the run result sent back to the model.
+func codeReport(res sandbox.Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "exit_code: %d\n", res.ExitCode)
+	if res.TimedOut {
+		b.WriteString("timed out: the run was stopped at the time limit\n")
+	}
+	if res.Truncated {
+		b.WriteString("output truncated\n")
+	}
+	b.WriteString("stdout:\n" + res.Stdout)
+	if res.Stderr != "" {
+		b.WriteString("\nstderr:\n" + res.Stderr)
+	}
+	return b.String()
+}
+
+func codeNote(res sandbox.Result) string {
+	head := fmt.Sprintf("▶ Python run, exit code %d", res.ExitCode)
+	if res.TimedOut {
+		head = "▶ Python run timed out"
+	}
+	output := strings.TrimSpace(res.Stdout + "\n" + res.Stderr)
+	if output == "" {
+		output = "(no output)"
+	}
+	return head + ":\n" + truncateRunes(output, maxCodeOutputRunes)
+}
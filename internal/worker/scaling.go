@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// Scaling lets a worker run more consumers than its base concurrency while
+// the stream backlog is high. Max at or below the base concurrency disables
+// it; the backlog gauge is still kept up to date.
+type Scaling struct {
+	Max int
+	// UpBacklog is the number of unread jobs above which a consumer is added
+	// on each Interval tick.
+	UpBacklog int64
+	Interval  time.Duration
+	// DownAfter is how long the backlog must stay empty before one extra
+	// consumer is removed. The gap between the two rules is the hysteresis.
+	DownAfter time.Duration
+}
+
+func (s Scaling) withDefaults() Scaling {
+	if s.UpBacklog <= 0 {
+		s.UpBacklog = 10
+	}
+	if s.Interval <= 0 {
+		s.Interval = 5 * time.Second
+	}
+	if s.DownAfter <= 0 {
+		s.DownAfter = time.Minute
+	}
+	return s
+}
+
+func (w *Worker) autoscale(ctx context.Context, pool *consumerPool, base int) {
+	ticker := time.NewTicker(w.scaling.Interval)
+	defer ticker.Stop()
+
+	var idleSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		depth, err := w.queue.Depth(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.Warn().Err(err).Msg("failed to read queue depth")
+			}
+			continue
+		}
+		w.metrics.QueueBacklog.Set(float64(depth.Waiting))
+		if w.scaling.Max <= base {
+			continue
+		}
+
+		now := time.Now()
+		if depth.Waiting > 0 {
+			idleSince = time.Time{}
+		} else if idleSince.IsZero() {
+			idleSince = now
+		}
+
+		active := pool.size()
+		switch {
+		case depth.Waiting > w.scaling.UpBacklog && active < w.scaling.Max:
+			pool.grow()
+			w.logger.Info().Int("consumers", active+1).Int64("backlog", depth.Waiting).Msg("worker scaled up")
+		case !idleSince.IsZero() && now.Sub(idleSince) >= w.scaling.DownAfter && active > base:
+			pool.shrink()
+			// Step down one consumer per idle period.
+			idleSince = now
+			w.logger.Info().Int("consumers", active-1).Msg("worker scaled down")
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/storage"
+)
+
+// renderTemplate replaces the prompt of a /tpl job with its rendered chat
+// template. It reports false when the job was answered with an error
+// instead.
+func (w *Worker) renderTemplate(ctx context.Context, job *queue.AskJob) bool {
+	if job.Template == "" {
+		return true
+	}
+	tpl, err := w.store.GetChatTemplate(ctx, job.ChatID, job.Template)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Str("template", job.Template).Msg("failed to load chat template")
+		}
+		_ = w.sendError(ctx, *job, "Template "+job.Template+" is not available. See /tpl_list.")
+		return false
+	}
+	vars := maps.Clone(job.TemplateVars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars[prompt.TemplateInput] = job.Prompt
+	// Retries re-enqueue the job; they must not render it twice.
+	job.Prompt, job.Template, job.TemplateVars = prompt.Render(tpl.Body, vars), "", nil
+	return true
+}
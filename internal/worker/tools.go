@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"hyprbot/internal/plugin"
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+)
+
+// maxToolRounds bounds how many times one answer may go back to the model
+// with tool results. The request after the last round offers no tools, so
+// the model has to answer in text.
+const maxToolRounds = 3
+
+// toolRun is one tool call answered while answering a job. Report goes back
+// to the model, Note is shown to the user under the answer.
+type toolRun struct {
+	Report string
+	Note   string
+}
+
+// tools lists the tools this worker can answer, those of the chat's
+// plugins included. Built-in tools win over plugin tools of the same name.
+func (w *Worker) tools(plugins []*plugin.Plugin) []providers.Tool {
+	var out []providers.Tool
+	if w.sandbox != nil {
+		out = append(out, runPythonTool)
+	}
+	if w.fetcher != nil {
+		out = append(out, fetchURLTool)
+	}
+	return append(out, pluginTools(plugins, out)...)
+}
+
+// chat sends req to p. With allow_tools set the model may call the tools
+// the worker is configured for; each call is answered and the results go
+// back to the model until it replies. Usage covers every round.
+func (w *Worker) chat(ctx context.Context, p providers.Provider, job queue.AskJob, plugins []*plugin.Plugin, req providers.ChatRequest) (providers.ChatResponse, []toolRun, error) {
+	if req.AllowTools {
+		req.Tools = w.tools(plugins)
+	}
+	if len(req.Tools) == 0 {
+		resp, err := p.Chat(ctx, req)
+		return resp, nil, err
+	}
+	var runs []toolRun
+	var usage providers.Usage
+	for round := 0; ; round++ {
+		resp, err := p.Chat(ctx, req)
+		usage.InputTokens += resp.Usage.InputTokens
+		usage.OutputTokens += resp.Usage.OutputTokens
+		resp.Usage = usage
+		if err != nil || len(resp.ToolCalls) == 0 || len(req.Tools) == 0 {
+			return resp, runs, err
+		}
+		turn := providers.ToolTurn{Text: resp.Text, Calls: resp.ToolCalls}
+		for _, call := range resp.ToolCalls {
+			run := w.runTool(ctx, job, plugins, call)
+			runs = append(runs, run)
+			turn.Results = append(turn.Results, providers.ToolResult{CallID: call.ID, Content: run.Report})
+		}
+		req.Turns = append(req.Turns, turn)
+		if round+1 == maxToolRounds {
+			req.Tools = nil
+		}
+	}
+}
+
+func (w *Worker) runTool(ctx context.Context, job queue.AskJob, plugins []*plugin.Plugin, call providers.ToolCall) toolRun {
+	switch {
+	case call.Name == runPythonTool.Name && w.sandbox != nil:
+		return w.runPython(ctx, job, call.Arguments)
+	case call.Name == fetchURLTool.Name && w.fetcher != nil:
+		return w.fetchURL(ctx, job, call.Arguments)
+	}
+	if run, ok := w.runPluginTool(ctx, job, plugins, call); ok {
+		return run
+	}
+	return toolRun{Report: fmt.Sprintf("error: unknown tool %q", call.Name)}
+}
+
+// toolNotes lists what the tools did under the answer so users can see
+// what was executed or read.
+func toolNotes(runs []toolRun) string {
+	notes := make([]string, 0, len(runs))
+	for _, r := range runs {
+		if r.Note != "" {
+			notes = append(notes, r.Note)
+		}
+	}
+	return strings.Join(notes, "\n\n")
+}
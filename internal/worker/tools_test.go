@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"hyprbot/internal/providers"
+	"hyprbot/internal/queue"
+	"hyprbot/internal/webfetch"
+)
+
+// toolHappyProvider calls a tool whenever the request offers one.
+type toolHappyProvider struct {
+	calls int
+}
+
+func (p *toolHappyProvider) Chat(_ context.Context, req providers.ChatRequest) (providers.ChatResponse, error) {
+	p.calls++
+	resp := providers.ChatResponse{Usage: providers.Usage{InputTokens: 10, OutputTokens: 1}}
+	if len(req.Tools) == 0 {
+		resp.Text = "final answer"
+		return resp, nil
+	}
+	resp.ToolCalls = []providers.ToolCall{{ID: "call", Name: fetchURLTool.Name, Arguments: "{}"}}
+	return resp, nil
+}
+
+func TestChatAnswersInTextAfterToolRoundLimit(t *testing.T) {
+	w := &Worker{fetcher: webfetch.New(webfetch.Config{}), logger: zerolog.Nop()}
+	p := &toolHappyProvider{}
+
+	resp, runs, err := w.chat(context.Background(), p, queue.AskJob{}, nil, providers.ChatRequest{AllowTools: true})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if resp.Text != "final answer" || len(resp.ToolCalls) != 0 {
+		t.Fatalf("chat returned %+v, want the text answer", resp)
+	}
+	if len(runs) != maxToolRounds || p.calls != maxToolRounds+1 {
+		t.Errorf("got %d tool runs in %d calls, want %d in %d", len(runs), p.calls, maxToolRounds, maxToolRounds+1)
+	}
+	if resp.Usage.InputTokens != 10*p.calls {
+		t.Errorf("usage %+v does not cover every call", resp.Usage)
+	}
+}
+
+func TestAnswerReplyKeepsToolNotesWithReasoning(t *testing.T) {
+	got := answerReply("42", "▶ Ran code", "thought about it")
+	want := "Reasoning:\nthought about it\n\nAnswer:\n42\n\n▶ Ran code"
+	if got != want {
+		t.Errorf("answerReply = %q, want %q", got, want)
+	}
+	if got := answerReply("42", "▶ Ran code", ""); got != "42\n\n▶ Ran code" {
+		t.Errorf("answerReply without reasoning = %q", got)
+	}
+}
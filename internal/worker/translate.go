@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"hyprbot/internal/prompt"
+	"hyprbot/internal/queue"
+)
+
+// translationAnswer renders a /tr reply under a "source → target" line and
+// parks the job so the language buttons can translate it again. token is
+// empty when the job could not be parked.
+func (w *Worker) translationAnswer(ctx context.Context, job queue.AskJob, text string) (reply, translation, token string) {
+	source, translation := prompt.ParseTranslation(text)
+	if translation == "" {
+		translation = text
+	}
+	header := "🌐 " + job.TranslateTo
+	if source != "" {
+		header = "🌐 " + source + " → " + job.TranslateTo
+	}
+	if w.picks != nil {
+		t, err := w.picks.Save(ctx, queue.PresetPick{Job: job})
+		if err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to park translation job")
+		}
+		token = t
+	}
+	return header + "\n\n" + translation, translation, token
+}
+
+func translateButtons(token string) []gotgbot.InlineKeyboardButton {
+	row := make([]gotgbot.InlineKeyboardButton, 0, len(prompt.TranslateLanguages))
+	for _, l := range prompt.TranslateLanguages {
+		row = append(row, gotgbot.InlineKeyboardButton{Text: strings.ToUpper(l.Code), CallbackData: queue.TranslateCallbackPrefix + token + ":" + l.Code})
+	}
+	return row
+}
@@ -1,50 +1,142 @@
 package worker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"math/rand/v2"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/rs/zerolog"
 
+	"hyprbot/internal/billing"
 	"hyprbot/internal/crypto"
+	"hyprbot/internal/eventstream"
+	"hyprbot/internal/jsonschema"
 	"hyprbot/internal/metrics"
+	"hyprbot/internal/notify"
+	"hyprbot/internal/plugin"
+	"hyprbot/internal/prompt"
 	"hyprbot/internal/providers"
 	"hyprbot/internal/providers/registry"
 	"hyprbot/internal/queue"
+	"hyprbot/internal/sandbox"
+	"hyprbot/internal/sanitize"
 	"hyprbot/internal/storage"
+	"hyprbot/internal/tgsend"
+	"hyprbot/internal/tokenizer"
+	"hyprbot/internal/webfetch"
 )
 
 type Worker struct {
 	bot             *gotgbot.Bot
 	store           *storage.Store
 	queue           *queue.StreamQueue
+	events          *queue.EventBus
+	throttle        *queue.ProviderThrottle
+	slots           *queue.ProviderSlots
+	picks           *queue.PickStore
+	answers         *queue.AnswerStore
+	fullAnswers     *queue.FullAnswerStore
+	fallback        *queue.FallbackPresets
+	fallbackLimiter *queue.RateLimiter
+	outbox          *queue.OutboxStore
+	quota           *queue.ChatQuota
+	billing         billing.Config
 	crypto          *crypto.Manager
 	httpClient      *http.Client
 	providerRetries int
 	backoffBase     time.Duration
+	maxRetryAfter   time.Duration
 	maxJobRetries   int
+	sandbox         *sandbox.Client
+	fetcher         *webfetch.Fetcher
+	sender          *tgsend.Sender
+	answerInStatus  bool
+	storeHistory    bool
+	scaling         Scaling
+	retention       Retention
+	heartbeat       Heartbeat
+	cleanup         Cleanup
+	liveness        *Liveness
+	tokenizers      *tokenizer.Registry
+	pipeline        pipeline
+	plugins         []*plugin.Plugin
+	notify          *notify.Dispatcher
+	budgetAlerts    BudgetAlerts
+	stream          *eventstream.Publisher
+	handlers        map[queue.JobType]jobHandler
 	logger          zerolog.Logger
 	metrics         *metrics.Metrics
 }
 
+// jobHandler runs one stream entry of its job type and reports whether the
+// entry should be acked.
+type jobHandler func(ctx context.Context, log zerolog.Logger, msg queue.Message) bool
+
 type Config struct {
-	Bot             *gotgbot.Bot
-	Store           *storage.Store
-	Queue           *queue.StreamQueue
+	Bot         *gotgbot.Bot
+	Store       *storage.Store
+	Queue       *queue.StreamQueue
+	Events      *queue.EventBus
+	Throttle    *queue.ProviderThrottle
+	Slots       *queue.ProviderSlots
+	Picks       *queue.PickStore
+	Answers     *queue.AnswerStore
+	FullAnswers *queue.FullAnswerStore
+	// Fallback answers chats without presets, at most FallbackLimiter
+	// allows. Nil disables it.
+	Fallback        *queue.FallbackPresets
+	FallbackLimiter *queue.RateLimiter
+	Outbox          *queue.OutboxStore
+	Quota           *queue.ChatQuota
+	Billing         billing.Config
 	Crypto          *crypto.Manager
 	HTTPClient      *http.Client
 	ProviderRetries int
 	BackoffBase     time.Duration
+	MaxRetryAfter   time.Duration
 	MaxJobRetries   int
-	Logger          zerolog.Logger
-	Metrics         *metrics.Metrics
+	// Sandbox runs code for presets with allow_tools. Nil offers no tools.
+	Sandbox *sandbox.Client
+	// Fetcher reads web pages for presets with allow_tools. Nil disables
+	// the fetch_url tool.
+	Fetcher *webfetch.Fetcher
+	// Sender defaults to one without DropOrphanReplies.
+	Sender *tgsend.Sender
+	// AnswerInStatus edits the "Accepted" status message into the answer
+	// instead of sending a separate reply.
+	AnswerInStatus bool
+	StoreHistory   bool
+	Scaling        Scaling
+	Retention      Retention
+	Heartbeat      Heartbeat
+	Cleanup        Cleanup
+	Logger         zerolog.Logger
+	Metrics        *metrics.Metrics
+	// Liveness defaults to one with the default stall timeout.
+	Liveness *Liveness
+	// Tokenizers count prompt tokens for context trimming and for usage
+	// the provider does not report. Nil estimates from characters.
+	Tokenizers *tokenizer.Registry
+	// Stages are added to the answer pipeline after the built-in ones, see
+	// Use.
+	Stages []Stage
+	// Plugins offer tools and filters to the chats that enable them.
+	Plugins []*plugin.Plugin
+	// Notify sends job outcomes and budget thresholds to the owner's
+	// webhooks; nil sends nothing.
+	Notify       *notify.Dispatcher
+	BudgetAlerts BudgetAlerts
+	// EventStream publishes anonymized job events to NATS or MQTT; nil
+	// publishes nothing.
+	EventStream *eventstream.Publisher
 }
 
 func New(cfg Config) *Worker {
@@ -58,21 +150,70 @@ func New(cfg Config) *Worker {
 	if cfg.BackoffBase <= 0 {
 		cfg.BackoffBase = 400 * time.Millisecond
 	}
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = 30 * time.Second
+	}
 	if cfg.MaxJobRetries < 0 {
 		cfg.MaxJobRetries = 0
 	}
-	return &Worker{
+	if cfg.Liveness == nil {
+		cfg.Liveness = NewLiveness(0)
+	}
+	if cfg.Sender == nil {
+		cfg.Sender = tgsend.New(cfg.Bot, tgsend.Options{Logger: cfg.Logger, Metrics: m})
+	}
+	w := &Worker{
 		bot:             cfg.Bot,
 		store:           cfg.Store,
 		queue:           cfg.Queue,
+		events:          cfg.Events,
+		throttle:        cfg.Throttle,
+		slots:           cfg.Slots,
+		picks:           cfg.Picks,
+		answers:         cfg.Answers,
+		fullAnswers:     cfg.FullAnswers,
+		fallback:        cfg.Fallback,
+		fallbackLimiter: cfg.FallbackLimiter,
+		outbox:          cfg.Outbox,
+		quota:           cfg.Quota,
+		billing:         cfg.Billing,
 		crypto:          cfg.Crypto,
 		httpClient:      cfg.HTTPClient,
 		providerRetries: cfg.ProviderRetries,
 		backoffBase:     cfg.BackoffBase,
+		maxRetryAfter:   cfg.MaxRetryAfter,
 		maxJobRetries:   cfg.MaxJobRetries,
+		sandbox:         cfg.Sandbox,
+		fetcher:         cfg.Fetcher,
+		sender:          cfg.Sender,
+		answerInStatus:  cfg.AnswerInStatus,
+		storeHistory:    cfg.StoreHistory,
+		scaling:         cfg.Scaling.withDefaults(),
+		retention:       cfg.Retention,
+		heartbeat:       cfg.Heartbeat,
+		cleanup:         cfg.Cleanup,
+		liveness:        cfg.Liveness,
+		tokenizers:      cfg.Tokenizers,
+		plugins:         cfg.Plugins,
+		notify:          cfg.Notify,
+		budgetAlerts:    cfg.BudgetAlerts,
+		stream:          cfg.EventStream,
 		logger:          cfg.Logger,
 		metrics:         m,
 	}
+	w.handlers = map[queue.JobType]jobHandler{
+		queue.JobTypeAsk: w.handleAsk,
+	}
+	w.useBuiltinStages()
+	for _, s := range cfg.Stages {
+		w.Use(s)
+	}
+	for _, p := range cfg.Plugins {
+		if p.Info().Filter {
+			w.Use(w.pluginStage(p))
+		}
+	}
+	return w
 }
 
 func (w *Worker) Start(ctx context.Context, concurrency int) error {
@@ -83,112 +224,178 @@ func (w *Worker) Start(ctx context.Context, concurrency int) error {
 		concurrency = 1
 	}
 
-	wg := sync.WaitGroup{}
+	pool := newConsumerPool(w, ctx, max(concurrency, w.scaling.Max))
 	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go func(slot int) {
-			defer wg.Done()
-			w.consumeLoop(ctx, slot)
-		}(i)
+		pool.grow()
 	}
+	go w.autoscale(ctx, pool, concurrency)
+	go w.trimLoop(ctx)
+	go w.heartbeatLoop(ctx, pool)
+	go w.cleanupLoop(ctx)
+	go w.readLoop(ctx, pool)
+	acked := make(chan struct{})
+	go func() {
+		defer close(acked)
+		w.ackLoop(pool.acks)
+	}()
 
 	<-ctx.Done()
-	wg.Wait()
+	pool.wait()
+	close(pool.acks)
+	<-acked
 	return nil
 }
 
-func (w *Worker) consumeLoop(ctx context.Context, slot int) {
+// consumeLoop processes messages handed out by readLoop until ctx is done or
+// stop is closed. A stopped slot finishes its current job first.
+func (w *Worker) consumeLoop(ctx context.Context, slot int, pool *consumerPool, stop <-chan struct{}) {
 	log := w.logger.With().Int("slot", slot).Logger()
 	for {
-		if err := ctx.Err(); err != nil {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		select {
+		case pool.idle <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		msg, ok := pool.next(ctx, stop)
+		if !ok {
 			return
 		}
 
-		messages, err := w.queue.Read(ctx, 1)
-		if err != nil {
-			if ctx.Err() != nil {
-				return
-			}
-			log.Error().Err(err).Msg("failed to read queue")
-			time.Sleep(1 * time.Second)
+		if msg.Err != nil {
+			w.quarantine(log, msg, msg.Err.Error())
 			continue
 		}
-		if len(messages) == 0 {
+		handle, known := w.handlers[msg.Envelope.Type]
+		if !known {
+			w.quarantine(log, msg, "unknown job type "+string(msg.Envelope.Type))
 			continue
 		}
+		if handle(ctx, log, msg) {
+			pool.ack(msg.ID)
+		}
+		w.liveness.progressed()
+	}
+}
 
-		for _, msg := range messages {
-			err := w.processJob(ctx, msg.Job)
-			if err == nil {
-				w.metrics.ProcessedJobs.Inc()
-				if ackErr := w.queue.Ack(ctx, msg.ID); ackErr != nil {
-					log.Error().Err(ackErr).Str("msg_id", msg.ID).Msg("failed to ack message")
-				}
-				continue
-			}
+// quarantine moves an entry the worker cannot handle out of the stream.
+// Nothing else in the group takes an entry this consumer read, so it would
+// otherwise stay pending forever.
+func (w *Worker) quarantine(log zerolog.Logger, msg queue.Message, reason string) {
+	raw := string(msg.Envelope.Payload)
+	if raw == "" {
+		raw = fmt.Sprint(msg.Values)
+	}
+	log.Error().Str("msg_id", msg.ID).Str("reason", reason).Str("raw_payload", truncateRunes(raw, maxQuarantineLogRunes)).Msg("quarantining stream entry")
+	// Not tied to the job ctx: a shutdown must not leave the entry behind.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.queue.Quarantine(ctx, msg, reason); err != nil {
+		log.Error().Err(err).Str("msg_id", msg.ID).Msg("failed to quarantine stream entry")
+		return
+	}
+	w.metrics.QuarantinedJobs.Inc()
+}
 
-			w.metrics.FailedJobs.Inc()
-			log.Error().Err(err).Str("job_id", msg.Job.JobID).Int("attempt", msg.Job.Attempts).Msg("job failed")
-
-			if msg.Job.Attempts < w.maxJobRetries {
-				msg.Job.Attempts++
-				if _, enqueueErr := w.queue.Enqueue(ctx, msg.Job); enqueueErr != nil {
-					log.Error().Err(enqueueErr).Str("job_id", msg.Job.JobID).Msg("failed to re-enqueue failed job")
-					continue
-				}
-				if ackErr := w.queue.Ack(ctx, msg.ID); ackErr != nil {
-					log.Error().Err(ackErr).Str("msg_id", msg.ID).Msg("failed to ack after re-enqueue")
-				}
-				continue
-			}
+// handleAsk runs an AskJob, re-enqueueing it while retries are left. It
+// reports whether the entry should be acked.
+func (w *Worker) handleAsk(ctx context.Context, log zerolog.Logger, msg queue.Message) bool {
+	if msg.Job.Version > queue.AskJobVersion {
+		log.Warn().Str("job_id", msg.Job.JobID).Int("version", msg.Job.Version).Msg("job written by a newer release, decoding best effort")
+	}
+	if claimed, err := w.queue.Claim(ctx, msg); err != nil {
+		log.Warn().Err(err).Str("job_id", msg.Job.JobID).Msg("failed to claim job, processing anyway")
+	} else if !claimed {
+		log.Debug().Str("job_id", msg.Job.JobID).Msg("job canceled by prompt edit")
+		return true
+	}
+	w.streamJob(msg.Job, eventstream.EventJobStarted, nil)
+	started := time.Now()
+	err := w.processJob(ctx, &msg.Job)
+	if err == nil {
+		w.metrics.ProcessedJobs.Inc()
+		w.liveness.processed.Add(1)
+		w.observeJob(msg.Job, started, "done")
+		w.publish(ctx, msg.Job, queue.JobStateDone)
+		w.emitJob(ctx, msg.Job, notify.EventJobDone)
+		w.streamJob(msg.Job, eventstream.EventJobFinished, map[string]any{"outcome": "done"})
+		w.react(ctx, msg.Job, doneReactionEmoji)
+		return true
+	}
 
-			_ = w.sendError(ctx, msg.Job.ChatID, msg.Job.MessageID, "LLM provider error. Please try again later.")
-			if ackErr := w.queue.Ack(ctx, msg.ID); ackErr != nil {
-				log.Error().Err(ackErr).Str("msg_id", msg.ID).Msg("failed to ack terminal failed message")
-			}
+	w.metrics.FailedJobs.Inc()
+	w.liveness.failed.Add(1)
+	log.Error().Err(err).Str("job_id", msg.Job.JobID).Int("attempt", msg.Job.Attempts).Msg("job failed")
+	w.streamJob(msg.Job, eventstream.EventJobError, map[string]any{"will_retry": msg.Job.Attempts < w.maxJobRetries})
+
+	if msg.Job.Attempts < w.maxJobRetries {
+		msg.Job.Attempts++
+		if _, enqueueErr := w.queue.Enqueue(ctx, msg.Job); enqueueErr != nil {
+			log.Error().Err(enqueueErr).Str("job_id", msg.Job.JobID).Msg("failed to re-enqueue failed job")
+			return false
 		}
+		w.observeJob(msg.Job, started, "retry")
+		w.publish(ctx, msg.Job, queue.JobStateQueued)
+		return true
 	}
+
+	w.observeJob(msg.Job, started, "failed")
+	w.recordUsage(ctx, msg.Job, storage.UsageEvent{PresetName: msg.Job.PresetName, Failed: true})
+	w.offerRetry(ctx, msg.Job)
+	w.publish(ctx, msg.Job, queue.JobStateFailed)
+	w.emitJob(ctx, msg.Job, notify.EventJobFailed)
+	w.streamJob(msg.Job, eventstream.EventJobFinished, map[string]any{"outcome": "failed"})
+	w.react(ctx, msg.Job, failedReactionEmoji)
+	return true
 }
 
-func (w *Worker) processJob(ctx context.Context, job queue.AskJob) error {
-	presetWithProvider, err := w.resolvePreset(ctx, job.ChatID, job.PresetName)
-	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			_ = w.sendError(ctx, job.ChatID, job.MessageID, "Preset not found. Configure /ai_default or use /ai <preset>.")
-			return nil
-		}
-		return err
+// observeJob times an ask job attempt; end-to-end latency is recorded only
+// for final outcomes.
+func (w *Worker) observeJob(job queue.AskJob, started time.Time, outcome string) {
+	jobType := string(queue.JobTypeAsk)
+	metrics.ObserveWithTrace(w.metrics.JobDuration.WithLabelValues(jobType, outcome), time.Since(started).Seconds(), job.JobID)
+	if outcome != "retry" && !job.EnqueuedAt.IsZero() {
+		metrics.ObserveWithTrace(w.metrics.JobLatency.WithLabelValues(jobType, outcome), time.Since(job.EnqueuedAt).Seconds(), job.JobID)
 	}
+}
 
-	apiKey, err := w.decryptOptional(presetWithProvider.Provider.EncAPIKey)
-	if err != nil {
-		return fmt.Errorf("decrypt api key: %w", err)
+func (w *Worker) processJob(ctx context.Context, job *queue.AskJob) error {
+	if resent, err := w.resendFromOutbox(ctx, job); resent {
+		return err
 	}
-	headers := map[string]string{}
-	if raw, err := w.decryptOptional(presetWithProvider.Provider.EncHeadersJSON); err != nil {
-		return fmt.Errorf("decrypt headers: %w", err)
-	} else if strings.TrimSpace(raw) != "" {
-		if err := json.Unmarshal([]byte(raw), &headers); err != nil {
-			return fmt.Errorf("parse headers json: %w", err)
-		}
+	w.publish(ctx, *job, queue.JobStateRunning)
+	if !w.renderTemplate(ctx, job) {
+		return nil
 	}
-
-	providerCfg := map[string]any{}
-	if strings.TrimSpace(presetWithProvider.Provider.ConfigJSON) != "" {
-		if err := json.Unmarshal([]byte(presetWithProvider.Provider.ConfigJSON), &providerCfg); err != nil {
-			return fmt.Errorf("parse provider config: %w", err)
+	presetWithProvider, arm, err := w.resolveJobPreset(ctx, *job)
+	if errors.Is(err, storage.ErrNotFound) {
+		fallback, ok := w.fallbackPreset(ctx, *job)
+		if !ok {
+			w.offerPresetPicker(ctx, *job)
+			return nil
 		}
+		if !w.allowFallback(ctx, *job) {
+			return nil
+		}
+		presetWithProvider, err = fallback, nil
 	}
+	if err != nil {
+		return err
+	}
+	if !w.admitJob(ctx, *job) {
+		return nil
+	}
+	presetWithProvider, route := w.routeJob(ctx, *job, presetWithProvider)
 
-	p, err := registry.Build(registry.BuildOptions{
-		Kind:        presetWithProvider.Provider.Kind,
-		BaseURL:     presetWithProvider.Provider.BaseURL,
-		APIKey:      apiKey,
-		Headers:     headers,
-		Config:      providerCfg,
-		HTTPClient:  w.httpClient,
-		MaxRetries:  w.providerRetries,
-		BackoffBase: w.backoffBase,
+	p, err := registry.FromInstance(presetWithProvider.Provider, w.crypto, registry.BuildOptions{
+		HTTPClient:    w.httpClient,
+		MaxRetries:    w.providerRetries,
+		BackoffBase:   w.backoffBase,
+		MaxRetryAfter: w.maxRetryAfter,
 	})
 	if err != nil {
 		return fmt.Errorf("build provider: %w", err)
@@ -198,64 +405,752 @@ func (w *Worker) processJob(ctx context.Context, job queue.AskJob) error {
 	if raw := strings.TrimSpace(presetWithProvider.Preset.ParamsJSON); raw != "" {
 		_ = json.Unmarshal([]byte(raw), &params)
 	}
+	if job.TranslateTo != "" {
+		// Translations are plain text whatever the preset is tuned for.
+		params.ResponseFormat, params.JSONSchema, params.ShowReasoning = "", nil, false
+		params.AllowTools = false
+	}
 
-	resp, err := p.Chat(ctx, providers.ChatRequest{
-		Model:        presetWithProvider.Preset.Model,
-		SystemPrompt: presetWithProvider.Preset.SystemPrompt,
-		UserPrompt:   job.Prompt,
-		MaxTokens:    params.MaxTokens,
-		Temperature:  params.Temperature,
-		AllowTools:   params.AllowTools,
+	providerID := presetWithProvider.Provider.ID
+	if err := w.waitForProvider(ctx, providerID); err != nil {
+		return err
+	}
+	release, err := w.acquireProviderSlot(ctx, presetWithProvider.Provider)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	settings, err := w.store.GetChatSettings(ctx, job.ChatID)
+	if err != nil {
+		// Defaults are a safe fallback; the answer matters more than the tweaks.
+		w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to load chat settings")
+		settings = storage.ChatSettings{}
+	}
+	ask := &Ask{
+		Job:      *job,
+		Preset:   presetWithProvider.Preset,
+		Model:    presetWithProvider.Preset.Model,
+		Settings: settings,
+		Request: prompt.Request{
+			System:       prompt.System(presetWithProvider.Preset.SystemPrompt, settings),
+			QuotedAuthor: job.QuotedAuthor,
+			QuotedText:   job.QuotedText,
+			Text:         job.Prompt,
+		},
+		params: params,
+	}
+	if job.TranslateTo != "" {
+		ask.Request.System = prompt.TranslateSystem(job.TranslateTo)
+	}
+	if !w.runBefore(ctx, ask) {
+		return nil
+	}
+	systemPrompt := ask.Request.SystemPrompt()
+
+	started := time.Now()
+	resp, runs, err := w.chat(ctx, p, *job, w.chatPlugins(settings), providers.ChatRequest{
+		Model:           ask.Model,
+		SystemPrompt:    systemPrompt,
+		UserPrompt:      ask.Request.UserPrompt(),
+		MaxTokens:       params.MaxTokens,
+		Temperature:     params.Temperature,
+		AllowTools:      params.AllowTools,
+		ReasoningEffort: params.ReasoningEffort,
+		ThinkingBudget:  params.ThinkingBudget,
+		ResponseFormat:  params.ResponseFormat,
+		JSONSchema:      params.JSONSchema,
 	})
+	debug := debugReport{
+		preset:       presetWithProvider,
+		arm:          arm,
+		route:        route,
+		params:       params,
+		systemPrompt: systemPrompt,
+		resp:         resp,
+		started:      started,
+		latency:      time.Since(started),
+		err:          err,
+	}
+	w.recordProviderHealth(ctx, providerID, err)
 	if err != nil {
+		if job.Debug {
+			w.sendDebug(ctx, *job, debug)
+		}
+		failed := storage.UsageEvent{
+			PresetName: presetWithProvider.Preset.Name,
+			Model:      presetWithProvider.Preset.Model,
+			LatencyMs:  debug.latency.Milliseconds(),
+			Failed:     true,
+		}
+		var tooLarge *providers.BodyTooLargeError
+		if errors.As(err, &tooLarge) {
+			w.recordUsage(ctx, *job, failed)
+			// Retrying cannot help; tell the user instead of the generic error.
+			_ = w.sendError(ctx, *job, fmt.Sprintf("Provider %s exceeds the %d byte limit for this provider. Ask an admin to adjust /llm_limits.", tooLarge.Direction, tooLarge.Limit))
+			return nil
+		}
+		if errors.Is(err, providers.ErrModelNotFound) {
+			w.recordUsage(ctx, *job, failed)
+			w.handleModelNotFound(ctx, *job, presetWithProvider)
+			return nil
+		}
+		w.recordThrottle(ctx, providerID, err)
 		return fmt.Errorf("provider chat: %w", err)
 	}
+	if presetWithProvider.Preset.DegradedReason != "" {
+		if err := w.store.ClearPresetDegraded(ctx, job.ChatID, presetWithProvider.Preset.Name); err != nil {
+			w.logger.Warn().Err(err).Str("preset", presetWithProvider.Preset.Name).Msg("failed to clear degraded preset flag")
+		}
+	}
+
+	latency := debug.latency
+	model := resp.Model
+	if model == "" {
+		model = presetWithProvider.Preset.Model
+	}
+	ans := &Answer{
+		Text:      strings.TrimSpace(resp.Text),
+		Reasoning: strings.TrimSpace(resp.Reasoning),
+		Notes:     toolNotes(runs),
+		Model:     model,
+		Route:     route,
+		Latency:   latency,
+		Usage:     resp.Usage,
+	}
+	if ans.Text == "" {
+		ans.Text = "Provider returned an empty response."
+	}
+	if params.wantsJSON() {
+		formatted, err := structuredAnswer(ans.Text, params.JSONSchema)
+		if err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Str("preset", presetWithProvider.Preset.Name).Msg("structured output rejected")
+			w.recordUsage(ctx, *job, storage.UsageEvent{
+				PresetName:   presetWithProvider.Preset.Name,
+				Model:        model,
+				InputTokens:  resp.Usage.InputTokens,
+				OutputTokens: resp.Usage.OutputTokens,
+				LatencyMs:    latency.Milliseconds(),
+				Failed:       true,
+			})
+			_ = w.sendError(ctx, *job, "Provider returned invalid JSON: "+truncateRunes(err.Error(), 300))
+			return nil
+		}
+		ans.Text, ans.JSON = formatted, true
+	}
+	w.runAfter(ctx, ask, ans)
+
+	w.publishAnswering(ctx, *job)
+	entry := queue.OutboxEntry{
+		Format:       queue.ReplyPlain,
+		Feedback:     w.saveAnswerMeta(ctx, *job, presetWithProvider.Preset, arm, latency),
+		PresetName:   presetWithProvider.Preset.Name,
+		Model:        model,
+		ABArm:        arm,
+		InputTokens:  ans.Usage.InputTokens,
+		OutputTokens: ans.Usage.OutputTokens,
+		LatencyMs:    latency.Milliseconds(),
+		Footer:       ans.Footer,
+		Disclosure:   ans.Disclosure,
+	}
+	entry.Private = settings.Bool(storage.SettingPrivateAnswers) && job.ChatType != "private" && job.UserID > 0
+
+	text := ans.Text
+	if ans.JSON {
+		entry.Format = queue.ReplyJSON
+		entry.Reply = text
+		entry.Answer = text
+	} else {
+		reasoning := ""
+		if params.ShowReasoning {
+			reasoning = ans.Reasoning
+		}
+		reply := answerReply(text, ans.Notes, reasoning)
+		if ans.Markdown {
+			entry.Format = queue.ReplyMarkdown
+		}
+		if job.TranslateTo != "" {
+			reply, text, entry.Translate = w.translationAnswer(ctx, *job, text)
+		}
+		entry.Reply = reply
+		entry.Answer = truncateRunes(text, 4000)
+		if mode := settings.Get(storage.SettingLongAnswers); mode != storage.LongAnswersSplit && !entry.Private {
+			entry.Reply, entry.More = w.previewLongAnswer(ctx, *job, reply, entry, mode)
+		}
+	}
+
+	// The provider call is the expensive part: keep the answer so a failed
+	// send is retried from the outbox rather than by asking again.
+	if w.outbox != nil && job.JobID != "" {
+		if err := w.outbox.Save(ctx, job.JobID, entry); err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to save answer to outbox")
+		}
+	}
+	if err := w.deliver(ctx, job, entry); err != nil {
+		return err
+	}
+	if job.Debug {
+		w.sendDebug(ctx, *job, debug)
+	}
+	return nil
+}
+
+// answerReply is the reply text of an answer: the reasoning, when shown,
+// before it and the tool notes after it.
+func answerReply(text, notes, reasoning string) string {
+	reply := text
+	if notes != "" {
+		reply += "\n\n" + notes
+	}
+	if reasoning != "" {
+		reply = "Reasoning:\n" + truncateRunes(reasoning, maxReasoningRunes) + "\n\nAnswer:\n" + reply
+	}
+	return reply
+}
 
-	text := strings.TrimSpace(resp.Text)
-	if text == "" {
-		text = "Provider returned an empty response."
+// resendFromOutbox delivers an answer a previous attempt of the job rendered
+// but failed to send. It reports false when there is none.
+func (w *Worker) resendFromOutbox(ctx context.Context, job *queue.AskJob) (bool, error) {
+	if w.outbox == nil || job.JobID == "" {
+		return false, nil
 	}
-	if len([]rune(text)) > 4000 {
-		r := []rune(text)
-		text = string(r[:4000])
+	entry, found, err := w.outbox.Get(ctx, job.JobID)
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to read outbox")
+		return false, nil
+	}
+	if !found {
+		return false, nil
 	}
+	w.logger.Info().Str("job_id", job.JobID).Int("attempt", job.Attempts).Msg("resending answer from outbox")
+	w.publishAnswering(ctx, *job)
+	return true, w.deliver(ctx, job, entry)
+}
 
-	sendOpts := &gotgbot.SendMessageOpts{}
-	if job.MessageID > 0 {
-		sendOpts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: job.MessageID}
+// publishAnswering reports that the answer is being sent. It is skipped when
+// the answer will replace the status message: the event could otherwise be
+// applied after the edit and overwrite the answer.
+func (w *Worker) publishAnswering(ctx context.Context, job queue.AskJob) {
+	if !w.answerInStatus || job.StatusMessageID <= 0 {
+		w.publish(ctx, job, queue.JobStateAnswering)
+	}
+}
+
+// deliver sends a rendered answer and, once it is out, records what the
+// answer cost. Bookkeeping only runs after a successful send, so a retried
+// delivery is never counted twice.
+func (w *Worker) deliver(ctx context.Context, job *queue.AskJob, entry queue.OutboxEntry) error {
+	markup := answerButtons(job.JobID, entry)
+	var m tgsend.Message
+	switch entry.Format {
+	case queue.ReplyJSON:
+		m = jsonAnswer(*job, entry.Reply, entry.Footer, markup)
+	case queue.ReplyMarkdown:
+		m = markdownAnswer(*job, entry.Reply, entry.Footer, markup)
+	default:
+		m = answerMessage(*job, withFooter(entry.Reply, entry.Footer), markup)
+	}
+	m.Disclosure = entry.Disclosure
+	var err error
+	if entry.Private {
+		err = w.deliverPrivately(ctx, job, entry, m)
+	} else {
+		err = w.sendAnswer(ctx, job, m)
 	}
-	_, err = w.bot.SendMessageWithContext(ctx, job.ChatID, text, sendOpts)
 	if err != nil {
 		return fmt.Errorf("send telegram response: %w", err)
 	}
+	if w.outbox != nil && job.JobID != "" {
+		if err := w.outbox.Delete(ctx, job.JobID); err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to clear outbox")
+		}
+	}
+
+	w.recordUsage(ctx, *job, answerUsage(entry))
+	w.chargeJob(ctx, *job, int64(entry.InputTokens+entry.OutputTokens))
+	if entry.ABArm != "" {
+		if err := w.store.RecordABServe(ctx, job.ChatID, entry.ABArm, entry.LatencyMs); err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to record ab serve")
+		}
+	}
+	if w.storeHistory {
+		if err := w.store.SaveConversationMessage(ctx, storage.ConversationMessage{
+			ChatID:     job.ChatID,
+			UserID:     job.UserID,
+			JobID:      job.JobID,
+			PresetName: entry.PresetName,
+			Prompt:     job.Prompt,
+			Answer:     entry.Answer,
+		}); err != nil {
+			w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to store conversation history")
+		}
+	}
 	return nil
 }
 
-func (w *Worker) resolvePreset(ctx context.Context, chatID int64, presetName string) (storage.PresetWithProvider, error) {
+func answerUsage(entry queue.OutboxEntry) storage.UsageEvent {
+	return storage.UsageEvent{
+		PresetName:   entry.PresetName,
+		Model:        entry.Model,
+		InputTokens:  entry.InputTokens,
+		OutputTokens: entry.OutputTokens,
+		LatencyMs:    entry.LatencyMs,
+	}
+}
+
+// recordUsage stores a usage event for the job's chat and asker. Usage feeds
+// the admin digest only, so failures are logged and otherwise ignored.
+func (w *Worker) recordUsage(ctx context.Context, job queue.AskJob, e storage.UsageEvent) {
+	e.ChatID = job.ChatID
+	e.UserID = job.UserID
+	if err := w.store.RecordUsage(ctx, e); err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to record usage")
+	}
+}
+
+// recordProviderHealth keeps the outcome of the latest provider call for
+// /llm_list and /status. Like usage, it is best effort.
+func (w *Worker) recordProviderHealth(ctx context.Context, providerID int64, callErr error) {
+	if ctx.Err() != nil {
+		// Shutting down says nothing about the provider.
+		return
+	}
+	var err error
+	if callErr == nil {
+		err = w.store.RecordProviderSuccess(ctx, providerID)
+	} else {
+		err = w.store.RecordProviderError(ctx, providerID, truncateRunes(providers.RedactText(callErr.Error()), maxProviderErrorRunes))
+	}
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("provider_id", providerID).Msg("failed to record provider health")
+	}
+}
+
+// resolveJobPreset routes jobs without an explicit preset or slot through the
+// chat's A/B experiment, if any. arm is empty when no experiment picked the
+// preset.
+func (w *Worker) resolveJobPreset(ctx context.Context, job queue.AskJob) (storage.PresetWithProvider, string, error) {
+	if strings.TrimSpace(job.PresetName) == "" && job.Slot == "" {
+		exp, err := w.store.GetABExperiment(ctx, job.ChatID)
+		switch {
+		case err == nil:
+			arm, name := exp.Route(rand.IntN(100))
+			pp, err := w.store.GetPresetWithProviderByName(ctx, job.ChatID, name)
+			if err == nil {
+				return pp, arm, nil
+			}
+			// A deleted arm must not break /ask; fall back to the default.
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Str("preset", name).Msg("ab experiment preset unavailable")
+		case !errors.Is(err, storage.ErrNotFound):
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to load ab experiment")
+		}
+	}
+	pp, err := w.resolvePreset(ctx, job.ChatID, job.PresetName, job.Slot)
+	return pp, "", err
+}
+
+func (w *Worker) resolvePreset(ctx context.Context, chatID int64, presetName, slot string) (storage.PresetWithProvider, error) {
 	if strings.TrimSpace(presetName) == "" {
+		if slot != "" {
+			return w.store.GetSlotPresetWithProvider(ctx, chatID, slot)
+		}
 		return w.store.GetDefaultPresetWithProvider(ctx, chatID)
 	}
 	return w.store.GetPresetWithProviderByName(ctx, chatID, presetName)
 }
 
-func (w *Worker) decryptOptional(raw *string) (string, error) {
-	if raw == nil || strings.TrimSpace(*raw) == "" {
-		return "", nil
+// maxPickerPresets bounds the picker keyboard; larger chats can still type
+// /ai <preset>.
+const maxPickerPresets = 10
+
+// offerPresetPicker answers a job whose preset is missing with buttons for the
+// chat's presets. Tapping one re-enqueues the job (see telegram pickPreset).
+func (w *Worker) offerPresetPicker(ctx context.Context, job queue.AskJob) {
+	const fallback = "Preset not found. Configure /ai_default or use /ai <preset>."
+	if w.picks == nil {
+		_ = w.sendError(ctx, job, fallback)
+		return
+	}
+	presets, err := w.store.ListPresets(ctx, job.ChatID)
+	if err != nil || len(presets) == 0 {
+		_ = w.sendError(ctx, job, fallback)
+		return
+	}
+	names := make([]string, 0, maxPickerPresets)
+	for _, p := range presets {
+		if len(names) == maxPickerPresets {
+			break
+		}
+		names = append(names, p.Name)
+	}
+	token, err := w.picks.Save(ctx, queue.PresetPick{Job: job, Presets: names})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to save preset pick")
+		_ = w.sendError(ctx, job, fallback)
+		return
+	}
+
+	text := "No default preset is set. Pick one to answer with:"
+	if job.PresetName != "" {
+		text = fmt.Sprintf("Preset %s not found. Pick one to answer with:", job.PresetName)
+	}
+	rows := make([][]gotgbot.InlineKeyboardButton, 0, (len(names)+1)/2)
+	for i, name := range names {
+		btn := gotgbot.InlineKeyboardButton{Text: name, CallbackData: fmt.Sprintf("%s%s:%d", queue.PickCallbackPrefix, token, i)}
+		if i%2 == 0 {
+			rows = append(rows, []gotgbot.InlineKeyboardButton{btn})
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], btn)
+		}
+	}
+	if err := w.reply(ctx, job, text, &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}); err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to send preset picker")
 	}
-	return w.crypto.UnmarshalEncryptedString(*raw)
 }
 
-func (w *Worker) sendError(ctx context.Context, chatID, replyTo int64, text string) error {
-	opts := &gotgbot.SendMessageOpts{}
-	if replyTo > 0 {
-		opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: replyTo}
+// offerRetry reports a terminal failure with a Retry button that re-enqueues
+// the job (see telegram retryJob).
+func (w *Worker) offerRetry(ctx context.Context, job queue.AskJob) {
+	const text = "LLM provider error. Please try again later."
+	if w.picks == nil {
+		_ = w.sendError(ctx, job, text)
+		return
+	}
+	token, err := w.picks.Save(ctx, queue.PresetPick{Job: job})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to save retry")
+		_ = w.sendError(ctx, job, text)
+		return
 	}
-	_, err := w.bot.SendMessageWithContext(ctx, chatID, text, opts)
+	markup := &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+		{{Text: "Retry", CallbackData: queue.RetryCallbackPrefix + token}},
+	}}
+	if err := w.reply(ctx, job, text, markup); err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to send retry button")
+	}
+}
+
+// handleModelNotFound flags the preset as degraded and, the first time only,
+// tells the chat so admins can fix it. Retrying would fail identically.
+func (w *Worker) handleModelNotFound(ctx context.Context, job queue.AskJob, pp storage.PresetWithProvider) {
+	name, model := pp.Preset.Name, pp.Preset.Model
+	first, err := w.store.MarkPresetDegraded(ctx, job.ChatID, name, fmt.Sprintf("model %s not found at provider %s", model, pp.Provider.Name))
+	if err != nil {
+		w.logger.Warn().Err(err).Str("preset", name).Msg("failed to mark preset degraded")
+	}
+	if first {
+		w.logger.Warn().Int64("chat_id", job.ChatID).Str("preset", name).Str("model", model).Msg("preset model not found, marked degraded")
+		alert := fmt.Sprintf("Admins: preset %s uses model %s, which provider %s no longer accepts. Update it with /ai_preset_add or switch /ai_default. Use /models %s to see available models.",
+			name, model, pp.Provider.Name, pp.Provider.Name)
+		if _, err := w.sender.Send(ctx, tgsend.Message{ChatID: job.ChatID, ThreadID: job.ThreadID, Text: alert}); err != nil {
+			w.logger.Warn().Err(err).Int64("chat_id", job.ChatID).Msg("failed to send degraded preset alert")
+		}
+	}
+	_ = w.sendError(ctx, job, fmt.Sprintf("Preset %s is unavailable: its model was not found at the provider. Chat admins have been notified.", name))
+}
+
+// waitForProvider sleeps while another worker has seen the provider throttle
+// us. The wait is capped so a bogus reset header cannot stall a slot.
+func (w *Worker) waitForProvider(ctx context.Context, providerID int64) error {
+	if w.throttle == nil {
+		return nil
+	}
+	wait, err := w.throttle.Remaining(ctx, providerID, time.Now())
+	if err != nil {
+		w.logger.Warn().Err(err).Int64("provider_id", providerID).Msg("failed to read provider throttle")
+		return nil
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if wait > w.maxRetryAfter {
+		wait = w.maxRetryAfter
+	}
+	w.logger.Info().Int64("provider_id", providerID).Dur("wait", wait).Msg("provider throttled, backing off")
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// acquireProviderSlot waits for one of the provider's max_concurrency slots.
+// Redis errors let the request through: the limit protects the provider, it
+// is not worth failing the answer over.
+func (w *Worker) acquireProviderSlot(ctx context.Context, p storage.ProviderInstance) (func(), error) {
+	limit := registry.MaxConcurrency(p)
+	if w.slots == nil || limit <= 0 {
+		return func() {}, nil
+	}
+	if token, ok, err := w.slots.TryAcquire(ctx, p.ID, limit, time.Now()); err == nil && ok {
+		return w.releaseProviderSlot(p.ID, token), nil
+	}
+	w.logger.Debug().Int64("provider_id", p.ID).Int64("limit", limit).Msg("provider at max concurrency, waiting for a slot")
+	token, err := w.slots.Acquire(ctx, p.ID, limit)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		w.logger.Warn().Err(err).Int64("provider_id", p.ID).Msg("failed to acquire provider slot")
+		return func() {}, nil
+	}
+	return w.releaseProviderSlot(p.ID, token), nil
+}
+
+func (w *Worker) releaseProviderSlot(providerID int64, token string) func() {
+	return func() {
+		// Release even when the job's ctx is done so the slot frees now
+		// rather than when the lease runs out.
+		if err := w.slots.Release(context.Background(), providerID, token); err != nil {
+			w.logger.Warn().Err(err).Int64("provider_id", providerID).Msg("failed to release provider slot")
+		}
+	}
+}
+
+func (w *Worker) recordThrottle(ctx context.Context, providerID int64, err error) {
+	var rl *providers.RateLimitError
+	if w.throttle == nil || !errors.As(err, &rl) {
+		return
+	}
+	wait := rl.RetryAfter
+	if wait <= 0 {
+		wait = w.backoffBase
+	}
+	now := time.Now()
+	if err := w.throttle.Mark(ctx, providerID, now.Add(wait), now); err != nil {
+		w.logger.Warn().Err(err).Int64("provider_id", providerID).Msg("failed to record provider throttle")
+	}
+}
+
+func (w *Worker) publish(ctx context.Context, job queue.AskJob, state string) {
+	if w.events == nil {
+		return
+	}
+	err := w.events.Publish(ctx, queue.JobEvent{
+		JobID:           job.JobID,
+		ChatID:          job.ChatID,
+		StatusMessageID: job.StatusMessageID,
+		State:           state,
+		Attempt:         job.Attempts,
+	})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Str("state", state).Msg("failed to publish job event")
+	}
+}
+
+// Bot API reactions are limited to a fixed emoji set that has no ✅/❌, so
+// thumbs up/down stand in for them.
+const (
+	doneReactionEmoji   = "👍"
+	failedReactionEmoji = "👎"
+)
+
+// react replaces the 👀 acknowledgement on jobs from chats using reaction acks.
+func (w *Worker) react(ctx context.Context, job queue.AskJob, emoji string) {
+	if !job.AckReaction || job.MessageID <= 0 {
+		return
+	}
+	_, err := w.bot.SetMessageReactionWithContext(ctx, job.ChatID, job.MessageID, &gotgbot.SetMessageReactionOpts{
+		Reaction: []gotgbot.ReactionType{gotgbot.ReactionTypeEmoji{Emoji: emoji}},
+	})
+	if err != nil {
+		w.logger.Debug().Err(err).Str("job_id", job.JobID).Msg("set message reaction failed")
+	}
+}
+
+func (w *Worker) sendError(ctx context.Context, job queue.AskJob, text string) error {
+	return w.reply(ctx, job, text, nil)
+}
+
+// reply answers the job's prompt in its topic. If the user deleted the
+// prompt while the job was queued, the sender drops the answer or sends it
+// without the reply reference.
+func (w *Worker) reply(ctx context.Context, job queue.AskJob, text string, markup *gotgbot.InlineKeyboardMarkup) error {
+	_, err := w.sender.Send(ctx, answerMessage(job, text, markup))
 	return err
 }
 
+// sendAnswer sends m in the job's chat. With answerInStatus it replaces the
+// job's status message; job.StatusMessageID is then cleared so the done event
+// does not delete it.
+func (w *Worker) sendAnswer(ctx context.Context, job *queue.AskJob, m tgsend.Message) error {
+	if !w.answerInStatus || job.StatusMessageID <= 0 {
+		_, err := w.sender.Send(ctx, m)
+		return err
+	}
+	replaced, err := w.sender.Replace(ctx, job.StatusMessageID, m)
+	if replaced {
+		job.StatusMessageID = 0
+	}
+	return err
+}
+
+// jsonAnswer renders a validated JSON answer as an HTML code block. Answers
+// too long for one message are split as plain text.
+func jsonAnswer(job queue.AskJob, doc, footer string, markup *gotgbot.InlineKeyboardMarkup) tgsend.Message {
+	m := answerMessage(job, withFooter(`<pre><code class="language-json">`+html.EscapeString(doc)+"</code></pre>", html.EscapeString(footer)), markup)
+	m.ParseMode, m.Plain = "HTML", withFooter(doc, footer)
+	return m
+}
+
+// markdownAnswer renders text with Telegram Markdown. Model output is not
+// guaranteed to be valid markup, so the sender falls back to plain text.
+func markdownAnswer(job queue.AskJob, text, footer string, markup *gotgbot.InlineKeyboardMarkup) tgsend.Message {
+	m := answerMessage(job, withFooter(text, markdownEscaper.Replace(footer)), markup)
+	m.ParseMode, m.Plain = "Markdown", withFooter(text, footer)
+	return m
+}
+
+func answerMessage(job queue.AskJob, text string, markup *gotgbot.InlineKeyboardMarkup) tgsend.Message {
+	return tgsend.Message{ChatID: job.ChatID, ThreadID: job.ThreadID, ReplyTo: job.MessageID, Text: text, Markup: markup}
+}
+
+// outputFilter is what the chat's output_filter setting neutralizes in an
+// answer. A value the setting no longer accepts falls back to every filter
+// rather than none.
+func outputFilter(settings storage.ChatSettings, markdown bool) sanitize.Options {
+	o, err := sanitize.ParseFilter(settings.Get(storage.SettingOutputFilter))
+	if err != nil {
+		o = sanitize.All()
+	}
+	o.Markdown = markdown
+	return o
+}
+
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// traceFooter describes which model answered and how it went, e.g.
+// "model: gpt-4.1 · 2.3s · 812 tok". Estimated usage is marked "~812 tok"
+// and zero usage is left out; route is the router decision, if any.
+func traceFooter(route, model string, latency time.Duration, usage providers.Usage, estimated bool) string {
+	parts := []string{"model: " + truncateRunes(model, 64), fmt.Sprintf("%.1fs", latency.Seconds())}
+	if route != "" {
+		parts = append([]string{"route: " + truncateRunes(route, 128)}, parts...)
+	}
+	if total := usage.Total(); total > 0 && estimated {
+		parts = append(parts, fmt.Sprintf("~%d tok", total))
+	} else if total > 0 {
+		parts = append(parts, fmt.Sprintf("%d tok", total))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func withFooter(text, footer string) string {
+	if footer == "" {
+		return text
+	}
+	return text + "\n\n" + footer
+}
+
+// saveAnswerMeta records what a vote on the answer is attributed to. It
+// reports false when the answer cannot be attributed, which leaves it without
+// vote buttons.
+func (w *Worker) saveAnswerMeta(ctx context.Context, job queue.AskJob, preset storage.Preset, arm string, latency time.Duration) bool {
+	if w.answers == nil || job.JobID == "" {
+		return false
+	}
+	err := w.answers.Save(ctx, job.JobID, queue.AnswerMeta{
+		ChatID:     job.ChatID,
+		PresetName: preset.Name,
+		Model:      preset.Model,
+		LatencyMs:  latency.Milliseconds(),
+		ABArm:      arm,
+	})
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", job.JobID).Msg("failed to save answer meta")
+		return false
+	}
+	return true
+}
+
+// answerButtons puts "Show full answer" under previews and the vote buttons
+// under answers that can be attributed.
+func answerButtons(jobID string, entry queue.OutboxEntry) *gotgbot.InlineKeyboardMarkup {
+	var rows [][]gotgbot.InlineKeyboardButton
+	if entry.More {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{Text: "Show full answer", CallbackData: queue.ExpandCallbackPrefix + jobID}})
+	}
+	if entry.Translate != "" {
+		rows = append(rows, translateButtons(entry.Translate))
+	}
+	if entry.Feedback {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{
+			{Text: "👍", CallbackData: queue.FeedbackCallbackPrefix + jobID + ":up"},
+			{Text: "👎", CallbackData: queue.FeedbackCallbackPrefix + jobID + ":down"},
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// maxReasoningRunes caps the reasoning shown with show_reasoning so the
+// answer still fits in one Telegram message.
+const maxReasoningRunes = 1500
+
+// maxProviderErrorRunes bounds the last provider error kept for admins.
+const maxProviderErrorRunes = 300
+
+// maxQuarantineLogRunes bounds the raw payload logged for a quarantined
+// entry; the full entry stays in the quarantine stream.
+const maxQuarantineLogRunes = 2000
+
 type presetParams struct {
-	MaxTokens   int     `json:"max_tokens"`
-	Temperature float64 `json:"temperature"`
-	AllowTools  bool    `json:"allow_tools"`
+	MaxTokens       int     `json:"max_tokens"`
+	Temperature     float64 `json:"temperature"`
+	AllowTools      bool    `json:"allow_tools"`
+	ReasoningEffort string  `json:"reasoning_effort"`
+	ThinkingBudget  int     `json:"thinking_budget"`
+	// ShowReasoning prepends the provider's reasoning text to the reply;
+	// by default it is withheld.
+	ShowReasoning bool `json:"show_reasoning"`
+	// ResponseFormat is "json_object" or "json_schema" for structured
+	// output; JSONSchema is used with the latter.
+	ResponseFormat string          `json:"response_format"`
+	JSONSchema     json.RawMessage `json:"json_schema"`
+	// ContextWindow overrides the model's known context window in tokens;
+	// 0 uses prompt.ContextWindow.
+	ContextWindow int `json:"context_window"`
+}
+
+func (p presetParams) wantsJSON() bool {
+	return providers.ChatRequest{ResponseFormat: p.ResponseFormat}.WantsJSON()
+}
+
+// structuredAnswer strips a Markdown code fence if the model added one,
+// validates the JSON (against schema when set) and pretty-prints it.
+func structuredAnswer(text string, schema json.RawMessage) (string, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	doc := []byte(strings.TrimSpace(text))
+	if len(schema) > 0 {
+		s, err := jsonschema.Parse(schema)
+		if err != nil {
+			return "", fmt.Errorf("preset schema: %w", err)
+		}
+		if err := s.Validate(doc); err != nil {
+			return "", err
+		}
+	} else if !json.Valid(doc) {
+		return "", fmt.Errorf("answer is not valid json")
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, doc, "", "  "); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
 }
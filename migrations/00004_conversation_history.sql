@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    job_id TEXT NOT NULL DEFAULT '',
+    preset_name TEXT NOT NULL DEFAULT '',
+    prompt TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_chat_user ON conversation_messages(chat_id, user_id, created_at);
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS exports_allowed BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS exports_allowed;
+DROP TABLE IF EXISTS conversation_messages;
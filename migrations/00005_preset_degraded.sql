@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE presets ADD COLUMN IF NOT EXISTS degraded_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE presets ADD COLUMN IF NOT EXISTS degraded_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE presets DROP COLUMN IF EXISTS degraded_at;
+ALTER TABLE presets DROP COLUMN IF EXISTS degraded_reason;
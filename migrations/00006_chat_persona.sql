@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS persona TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS persona;
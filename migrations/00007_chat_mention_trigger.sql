@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS mention_trigger BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS mention_trigger;
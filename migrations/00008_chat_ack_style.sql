@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS ack_style TEXT NOT NULL DEFAULT 'message';
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS ack_style;
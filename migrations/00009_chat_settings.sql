@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, key)
+);
+
+INSERT INTO chat_settings (chat_id, key, value)
+SELECT id, 'prefixes', command_prefixes FROM chats WHERE command_prefixes <> '';
+INSERT INTO chat_settings (chat_id, key, value)
+SELECT id, 'exports', 'off' FROM chats WHERE NOT exports_allowed;
+INSERT INTO chat_settings (chat_id, key, value)
+SELECT id, 'persona', persona FROM chats WHERE persona <> '';
+INSERT INTO chat_settings (chat_id, key, value)
+SELECT id, 'mention', 'on' FROM chats WHERE mention_trigger;
+INSERT INTO chat_settings (chat_id, key, value)
+SELECT id, 'ack', ack_style FROM chats WHERE ack_style <> 'message';
+
+ALTER TABLE chats DROP COLUMN IF EXISTS command_prefixes;
+ALTER TABLE chats DROP COLUMN IF EXISTS exports_allowed;
+ALTER TABLE chats DROP COLUMN IF EXISTS persona;
+ALTER TABLE chats DROP COLUMN IF EXISTS mention_trigger;
+ALTER TABLE chats DROP COLUMN IF EXISTS ack_style;
+
+-- +goose Down
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS command_prefixes TEXT NOT NULL DEFAULT '';
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS exports_allowed BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS persona TEXT NOT NULL DEFAULT '';
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS mention_trigger BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS ack_style TEXT NOT NULL DEFAULT 'message';
+
+UPDATE chats SET command_prefixes = s.value FROM chat_settings s WHERE s.chat_id = chats.id AND s.key = 'prefixes';
+UPDATE chats SET exports_allowed = (s.value = 'on') FROM chat_settings s WHERE s.chat_id = chats.id AND s.key = 'exports';
+UPDATE chats SET persona = s.value FROM chat_settings s WHERE s.chat_id = chats.id AND s.key = 'persona';
+UPDATE chats SET mention_trigger = (s.value = 'on') FROM chat_settings s WHERE s.chat_id = chats.id AND s.key = 'mention';
+UPDATE chats SET ack_style = s.value FROM chat_settings s WHERE s.chat_id = chats.id AND s.key = 'ack';
+
+DROP TABLE IF EXISTS chat_settings;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS left_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS left_at;
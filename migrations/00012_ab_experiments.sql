@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS ab_experiments (
+    chat_id BIGINT PRIMARY KEY REFERENCES chats(id) ON DELETE CASCADE,
+    preset_a TEXT NOT NULL,
+    preset_b TEXT NOT NULL,
+    split_pct INTEGER NOT NULL,
+    served_a BIGINT NOT NULL DEFAULT 0,
+    served_b BIGINT NOT NULL DEFAULT 0,
+    latency_ms_a BIGINT NOT NULL DEFAULT 0,
+    latency_ms_b BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE answer_feedback ADD COLUMN IF NOT EXISTS ab_arm TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE answer_feedback DROP COLUMN IF EXISTS ab_arm;
+DROP TABLE IF EXISTS ab_experiments;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS preset_revisions (
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    preset_name TEXT NOT NULL,
+    rev INTEGER NOT NULL,
+    provider_instance_id BIGINT NOT NULL,
+    model TEXT NOT NULL,
+    system_prompt TEXT NOT NULL DEFAULT '',
+    params_json JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, preset_name, rev)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS preset_revisions;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS credit_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    delta BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    charge_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_ledger_chat ON credit_ledger(chat_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_ledger_charge ON credit_ledger(charge_id) WHERE charge_id <> '';
+
+-- +goose Down
+DROP TABLE IF EXISTS credit_ledger;
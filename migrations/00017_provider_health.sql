@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE provider_instances ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ;
+ALTER TABLE provider_instances ADD COLUMN IF NOT EXISTS last_error_at TIMESTAMPTZ;
+ALTER TABLE provider_instances ADD COLUMN IF NOT EXISTS last_error_text TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE provider_instances DROP COLUMN IF EXISTS last_error_text;
+ALTER TABLE provider_instances DROP COLUMN IF EXISTS last_error_at;
+ALTER TABLE provider_instances DROP COLUMN IF EXISTS last_success_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_users (
+    chat_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_active_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    message_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_users_user ON chat_users(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS chat_users;